@@ -0,0 +1,202 @@
+//go:build !ios && !android && (amd64 || arm64)
+
+package ffgo
+
+import (
+	"errors"
+	"fmt"
+	"unsafe"
+
+	"github.com/obinnaokechukwu/ffgo/avutil"
+)
+
+// ASRSampleRate is the output sample rate of ASRFrontend (16 kHz).
+const ASRSampleRate = 16000
+
+// ASRFrontend converts arbitrary decoded audio into fixed-size windows of
+// 16 kHz mono float32 samples, the input most speech recognition models expect.
+//
+// It combines resampling, downmixing and re-chunking: Push accepts frames of
+// any size in the source format, and Pull returns exactly windowSamples
+// samples per frame.
+//
+// Example:
+//
+//	asr, err := ffgo.NewASRFrontend(srcFormat, 16000) // 1 second windows
+//	if err != nil {
+//	    return err
+//	}
+//	defer asr.Close()
+//
+//	for {
+//	    frame, err := decoder.DecodeAudio()
+//	    if err != nil || frame.IsNil() {
+//	        break
+//	    }
+//	    if err := asr.Push(frame); err != nil {
+//	        return err
+//	    }
+//	    for {
+//	        window, ok, err := asr.Pull()
+//	        if err != nil {
+//	            return err
+//	        }
+//	        if !ok {
+//	            break
+//	        }
+//	        runInference(window)
+//	        window.Free()
+//	    }
+//	}
+type ASRFrontend struct {
+	resampler     *Resampler
+	windowSamples int
+	pending       []float32
+	flushed       bool
+	closed        bool
+}
+
+// NewASRFrontend creates a frontend that converts audio in srcFormat into
+// 16 kHz mono SampleFormatFlt windows of windowSamples samples each.
+func NewASRFrontend(srcFormat AudioFormat, windowSamples int) (*ASRFrontend, error) {
+	if windowSamples <= 0 {
+		return nil, fmt.Errorf("ffgo: invalid ASR window size: %d", windowSamples)
+	}
+
+	resampler, err := NewResampler(srcFormat, AudioFormat{
+		SampleRate:    ASRSampleRate,
+		Channels:      1,
+		ChannelLayout: ChannelLayoutMono,
+		SampleFormat:  SampleFormatFlt,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &ASRFrontend{
+		resampler:     resampler,
+		windowSamples: windowSamples,
+	}, nil
+}
+
+// Push resamples frame and queues its samples for windowing.
+// The input frame is not modified or freed.
+func (a *ASRFrontend) Push(frame Frame) error {
+	if a.closed {
+		return errors.New("ffgo: ASR frontend is closed")
+	}
+	if a.flushed {
+		return errors.New("ffgo: ASR frontend already flushed")
+	}
+	if frame.IsNil() {
+		return nil
+	}
+
+	out, err := a.resampler.Resample(frame)
+	if err != nil {
+		return err
+	}
+	a.appendFrame(out)
+	return nil
+}
+
+// Pull returns the next complete window, or false if not enough samples are
+// queued. An error means the window could not be allocated; its samples stay
+// queued for the next call. The returned frame is owned by the caller and
+// must be freed.
+func (a *ASRFrontend) Pull() (Frame, bool, error) {
+	if a.closed {
+		return Frame{}, false, errors.New("ffgo: ASR frontend is closed")
+	}
+	if len(a.pending) < a.windowSamples {
+		return Frame{}, false, nil
+	}
+
+	frame, err := newFloatAudioFrame(a.pending[:a.windowSamples], ASRSampleRate)
+	if err != nil {
+		return Frame{}, false, err
+	}
+	a.pending = a.pending[a.windowSamples:]
+	return frame, true, nil
+}
+
+// Flush drains the resampler at end of stream. Any trailing partial window is
+// padded with silence so that a final Pull returns it. After Flush, Push
+// returns an error.
+func (a *ASRFrontend) Flush() error {
+	if a.closed {
+		return errors.New("ffgo: ASR frontend is closed")
+	}
+	if a.flushed {
+		return nil
+	}
+	a.flushed = true
+
+	out, err := a.resampler.Flush()
+	if err != nil {
+		return err
+	}
+	a.appendFrame(out)
+
+	if rem := len(a.pending) % a.windowSamples; rem != 0 {
+		a.pending = append(a.pending, make([]float32, a.windowSamples-rem)...)
+	}
+	return nil
+}
+
+// Buffered returns the number of queued samples not yet returned by Pull.
+func (a *ASRFrontend) Buffered() int {
+	return len(a.pending)
+}
+
+// WindowSamples returns the number of samples in each output window.
+func (a *ASRFrontend) WindowSamples() int {
+	return a.windowSamples
+}
+
+// Close releases the underlying resampler.
+func (a *ASRFrontend) Close() error {
+	if a.closed {
+		return nil
+	}
+	a.closed = true
+	a.pending = nil
+	return a.resampler.Close()
+}
+
+// appendFrame copies the samples of an owned mono float frame into the queue
+// and frees the frame.
+func (a *ASRFrontend) appendFrame(frame Frame) {
+	if frame.IsNil() {
+		return
+	}
+	defer frame.Free()
+
+	n := int(avutil.GetFrameNbSamples(frame.ptr))
+	data := avutil.GetFrameDataPlane(frame.ptr, 0)
+	if n <= 0 || data == nil {
+		return
+	}
+	a.pending = append(a.pending, unsafe.Slice((*float32)(data), n)...)
+}
+
+// newFloatAudioFrame allocates an owned mono SampleFormatFlt frame holding samples.
+func newFloatAudioFrame(samples []float32, sampleRate int) (Frame, error) {
+	ptr := avutil.FrameAlloc()
+	if ptr == nil {
+		return Frame{}, errors.New("ffgo: failed to allocate frame")
+	}
+
+	avutil.FrameSetSampleRate(ptr, int32(sampleRate))
+	avutil.FrameSetChannels(ptr, 1)
+	avutil.FrameSetFormat(ptr, int32(SampleFormatFlt))
+	avutil.FrameSetNbSamples(ptr, int32(len(samples)))
+	if err := avutil.FrameGetBufferErr(ptr, 0); err != nil {
+		avutil.FrameFree(&ptr)
+		return Frame{}, fmt.Errorf("ffgo: failed to allocate frame buffer: %w", err)
+	}
+
+	data := avutil.GetFrameDataPlane(ptr, 0)
+	copy(unsafe.Slice((*float32)(data), len(samples)), samples)
+	return Frame{ptr: ptr, owned: true}, nil
+}
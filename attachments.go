@@ -4,6 +4,7 @@ package ffgo
 
 import (
 	"errors"
+	"strings"
 
 	"github.com/obinnaokechukwu/ffgo/avcodec"
 	"github.com/obinnaokechukwu/ffgo/avformat"
@@ -20,9 +21,12 @@ type Attachment struct {
 	Data        []byte // The attachment data
 }
 
-// GetAttachments returns all attachments from the media file.
-// Returns an empty slice if there are no attachments.
-func (d *Decoder) GetAttachments() []Attachment {
+// Attachments returns the files embedded as attachment streams, such as the
+// fonts an MKV carries for its ASS/SSA subtitles, in stream order.
+// Filename and MimeType come from the stream's "filename" and "mimetype"
+// metadata and Data is a copy of its extradata.
+// Returns nil if there are no attachments.
+func (d *Decoder) Attachments() []Attachment {
 	d.mu.Lock()
 	defer d.mu.Unlock()
 
@@ -77,6 +81,17 @@ func (d *Decoder) GetAttachments() []Attachment {
 	return attachments
 }
 
+// GetAttachments is an alias for Attachments.
+func (d *Decoder) GetAttachments() []Attachment {
+	return d.Attachments()
+}
+
+// IsFont reports whether the attachment is a font, judged by its MIME type
+// or, failing that, its file extension.
+func (a Attachment) IsFont() bool {
+	return guessAttachmentCodecID(strings.ToLower(a.MimeType), strings.ToLower(a.Filename)) == avcodec.CodecIDTTF
+}
+
 // getMetadataValue retrieves a value from a metadata dictionary.
 func getMetadataValue(dict avutil.Dictionary, key string) string {
 	if dict == nil {
@@ -134,6 +149,12 @@ func (e *Encoder) AddAttachment(att Attachment) error {
 	if e.formatCtx == nil {
 		return errors.New("ffgo: encoder not initialized")
 	}
+	return e.addAttachmentLocked(att)
+}
+
+// addAttachmentLocked adds att as an attachment stream. Must be called with
+// e.mu held, before the header is written.
+func (e *Encoder) addAttachmentLocked(att Attachment) error {
 	if len(att.Data) == 0 {
 		return errors.New("ffgo: attachment data is empty")
 	}
@@ -178,8 +199,10 @@ func (e *Encoder) AddAttachment(att Attachment) error {
 func guessAttachmentCodecID(mimeType, filename string) avcodec.CodecID {
 	// Check MIME type first
 	switch mimeType {
-	case "application/x-truetype-font", "font/ttf", "font/otf",
-		"application/x-font-ttf", "application/x-font-opentype":
+	case "application/x-truetype-font", "font/ttf", "font/otf", "font/sfnt",
+		"application/x-font-ttf", "application/x-font-opentype",
+		"application/x-font-otf", "application/vnd.ms-opentype",
+		"application/font-sfnt":
 		return avcodec.CodecIDTTF
 	case "image/png":
 		return avcodec.CodecIDPNG
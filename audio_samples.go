@@ -0,0 +1,189 @@
+//go:build !ios && !android && (amd64 || arm64)
+
+package ffgo
+
+import (
+	"errors"
+	"fmt"
+	"time"
+	"unsafe"
+
+	"github.com/obinnaokechukwu/ffgo/avutil"
+)
+
+// ExtractPCM decodes the remaining audio stream and returns it as raw
+// interleaved PCM in the requested sample format, rate and channel count,
+// e.g. ExtractPCM(SampleFormatS16, 16000, 1) for the 16 kHz mono s16 input
+// speech models expect. A zero sampleRate or channels keeps the source's.
+//
+// format must be a packed (interleaved) format such as SampleFormatS16 or
+// SampleFormatFlt. The whole stream is held in memory, and samples are in
+// native byte order.
+func (d *Decoder) ExtractPCM(format SampleFormat, sampleRate, channels int) ([]byte, error) {
+	bytes, planar := sampleFormatSize(format)
+	if bytes == 0 {
+		return nil, fmt.Errorf("ffgo: unsupported sample format %d", format)
+	}
+	if planar {
+		return nil, errors.New("ffgo: ExtractPCM needs an interleaved sample format")
+	}
+	var pcm []byte
+	err := d.decodeResampledAudio(format, sampleRate, channels, func(f Frame, dst AudioFormat, _ int64) {
+		n := int(avutil.GetFrameNbSamples(f.ptr))
+		if data := avutil.GetFrameDataPlane(f.ptr, 0); data != nil && n > 0 {
+			pcm = append(pcm, unsafe.Slice((*byte)(data), n*bytes*dst.Channels)...)
+		}
+	})
+	if err != nil {
+		return nil, err
+	}
+	return pcm, nil
+}
+
+// PeakPair is the lowest and highest sample value within one bucket of an
+// audio waveform, normalized to [-1, 1].
+type PeakPair struct {
+	Min, Max float32
+}
+
+// AudioPeaks decodes the remaining audio stream and returns the min/max
+// sample values over consecutive buckets of bucketDuration, across all
+// channels, for drawing waveforms. Samples are converted to float whatever
+// the source sample format, so values are normalized to [-1, 1].
+//
+// Buckets are positioned by the frames' PTS relative to the first decoded
+// frame, so gaps in the stream show up as {0, 0} buckets rather than
+// shifting later audio earlier.
+func (d *Decoder) AudioPeaks(bucketDuration time.Duration) ([]PeakPair, error) {
+	if bucketDuration <= 0 {
+		return nil, errors.New("ffgo: bucket duration must be positive")
+	}
+	var tb Rational
+	if info := d.AudioStream(); info != nil {
+		tb = info.TimeBase
+	}
+
+	var (
+		peaks    []PeakPair
+		pos      int64 // position of the next sample, in samples
+		last     = -1  // last bucket that received samples
+		firstPTS = avutil.AV_NOPTS_VALUE
+	)
+	err := d.decodeResampledAudio(SampleFormatFlt, 0, 0, func(f Frame, dst AudioFormat, srcPTS int64) {
+		if srcPTS != avutil.AV_NOPTS_VALUE && tb.Den != 0 {
+			if firstPTS == avutil.AV_NOPTS_VALUE {
+				firstPTS = srcPTS
+			}
+			// Only jump forward: the resampler keeps the rate, so a frame
+			// starting past pos means the stream has a gap.
+			if p := rescaleTS(srcPTS-firstPTS, tb, NewRational(1, int32(dst.SampleRate))); p > pos {
+				pos = p
+			}
+		}
+		n := int(avutil.GetFrameNbSamples(f.ptr))
+		data := avutil.GetFrameDataPlane(f.ptr, 0)
+		if data == nil || n <= 0 || dst.Channels <= 0 {
+			return
+		}
+		samples := unsafe.Slice((*float32)(data), n*dst.Channels)
+		perBucket := bucketDuration.Seconds() * float64(dst.SampleRate)
+		for i := 0; i < n; i++ {
+			b := int(float64(pos+int64(i)) / perBucket)
+			for len(peaks) <= b {
+				peaks = append(peaks, PeakPair{})
+			}
+			if b > last {
+				peaks[b] = PeakPair{Min: 1, Max: -1}
+				last = b
+			}
+			p := &peaks[b]
+			for _, v := range samples[i*dst.Channels : (i+1)*dst.Channels] {
+				v = max(-1, min(1, v))
+				p.Min = min(p.Min, v)
+				p.Max = max(p.Max, v)
+			}
+		}
+		pos += int64(n)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return peaks, nil
+}
+
+// decodeResampledAudio decodes the remaining audio stream, converts every
+// frame to format at sampleRate and channels (zero keeps the source's), and
+// passes each converted frame to fn together with the output format and the
+// PTS of the decoded frame it came from (AV_NOPTS_VALUE for the samples
+// flushed from the resampler at the end). The frame is only valid during
+// the call.
+func (d *Decoder) decodeResampledAudio(format SampleFormat, sampleRate, channels int, fn func(f Frame, dst AudioFormat, srcPTS int64)) error {
+	if sampleRate < 0 || channels < 0 {
+		return errors.New("ffgo: sample rate and channels must not be negative")
+	}
+	if !d.HasAudio() {
+		return ErrNoAudioStream
+	}
+	if err := d.OpenAudioDecoder(); err != nil {
+		return err
+	}
+
+	var (
+		resampler *Resampler
+		dst       AudioFormat
+	)
+	defer func() {
+		if resampler != nil {
+			_ = resampler.Close()
+		}
+	}()
+
+	for {
+		frame, err := d.DecodeAudio()
+		if err != nil && !IsEOF(err) {
+			return err
+		}
+		if err != nil || frame.IsNil() {
+			break
+		}
+
+		if resampler == nil {
+			src := AudioFormat{
+				SampleRate:   int(avutil.GetFrameSampleRate(frame.ptr)),
+				Channels:     int(avutil.GetFrameChannels(frame.ptr)),
+				SampleFormat: SampleFormat(avutil.GetFrameFormat(frame.ptr)),
+			}
+			dst = AudioFormat{SampleRate: sampleRate, Channels: channels, SampleFormat: format}
+			if dst.SampleRate == 0 {
+				dst.SampleRate = src.SampleRate
+			}
+			if dst.Channels == 0 {
+				dst.Channels = src.Channels
+			}
+			if resampler, err = NewResampler(src, dst); err != nil {
+				return err
+			}
+		}
+
+		out, err := resampler.Resample(frame)
+		if err != nil {
+			return err
+		}
+		if !out.IsNil() {
+			fn(out, dst, avutil.GetFramePTS(frame.ptr))
+		}
+		_ = FrameFree(&out)
+	}
+
+	if resampler != nil {
+		out, err := resampler.Flush()
+		if err != nil {
+			return err
+		}
+		if !out.IsNil() {
+			fn(out, dst, avutil.AV_NOPTS_VALUE)
+		}
+		_ = FrameFree(&out)
+	}
+	return nil
+}
@@ -0,0 +1,117 @@
+//go:build !ios && !android && (amd64 || arm64)
+
+package ffgo
+
+import (
+	"encoding/binary"
+	"math"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"testing"
+	"time"
+)
+
+// createSineWAV writes a one-second 44.1 kHz stereo s16 sine tone at the
+// given amplitude (0-1) and returns its path.
+func createSineWAV(t *testing.T, amplitude float64) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "sine.wav")
+	cmd := exec.Command("ffmpeg", "-y",
+		"-f", "lavfi", "-i", "sine=frequency=440:duration=1:sample_rate=44100",
+		"-af", "volume="+strconv.FormatFloat(amplitude*8, 'f', -1, 64), // the sine source peaks at 1/8
+		"-ac", "2", "-c:a", "pcm_s16le", path)
+	if err := cmd.Run(); err != nil {
+		t.Skipf("ffmpeg CLI not available: %v", err)
+	}
+	return path
+}
+
+func TestExtractPCM(t *testing.T) {
+	if !requireFFmpeg(t) {
+		return
+	}
+	dec, err := NewDecoder(createSineWAV(t, 0.5))
+	if err != nil {
+		t.Fatalf("NewDecoder failed: %v", err)
+	}
+	defer dec.Close()
+
+	if _, err := dec.ExtractPCM(SampleFormatS16P, 16000, 1); err == nil {
+		t.Error("ExtractPCM with a planar format succeeded, want error")
+	}
+
+	pcm, err := dec.ExtractPCM(SampleFormatS16, 16000, 1)
+	if err != nil {
+		t.Fatalf("ExtractPCM failed: %v", err)
+	}
+	samples := len(pcm) / 2
+	if samples < 15500 || samples > 16500 {
+		t.Errorf("got %d samples, want about 16000 for 1s at 16kHz mono", samples)
+	}
+	peak := 0
+	for i := 0; i+1 < len(pcm); i += 2 {
+		v := int(int16(binary.LittleEndian.Uint16(pcm[i:])))
+		if v < 0 {
+			v = -v
+		}
+		peak = max(peak, v)
+	}
+	if want := 0.5 * math.MaxInt16; math.Abs(float64(peak)-want) > want*0.1 {
+		t.Errorf("peak sample = %d, want about %.0f", peak, want)
+	}
+}
+
+func TestAudioPeaks(t *testing.T) {
+	if !requireFFmpeg(t) {
+		return
+	}
+	dec, err := NewDecoder(createSineWAV(t, 0.5))
+	if err != nil {
+		t.Fatalf("NewDecoder failed: %v", err)
+	}
+	defer dec.Close()
+
+	if _, err := dec.AudioPeaks(0); err == nil {
+		t.Error("AudioPeaks(0) succeeded, want error")
+	}
+
+	peaks, err := dec.AudioPeaks(100 * time.Millisecond)
+	if err != nil {
+		t.Fatalf("AudioPeaks failed: %v", err)
+	}
+	if len(peaks) < 10 || len(peaks) > 11 {
+		t.Fatalf("got %d buckets, want 10 for 1s at 100ms", len(peaks))
+	}
+	for i, p := range peaks[:10] {
+		if math.Abs(float64(p.Max)-0.5) > 0.05 || math.Abs(float64(p.Min)+0.5) > 0.05 {
+			t.Errorf("bucket %d = %+v, want about {-0.5 0.5}", i, p)
+		}
+	}
+}
+
+func TestDecodeAudioCopy(t *testing.T) {
+	if !requireFFmpeg(t) {
+		return
+	}
+	dec, err := NewDecoder(createSineWAV(t, 0.5))
+	if err != nil {
+		t.Fatalf("NewDecoder failed: %v", err)
+	}
+	defer dec.Close()
+
+	first, err := dec.DecodeAudioCopy()
+	if err != nil || first.IsNil() {
+		t.Fatalf("DecodeAudioCopy failed: %v", err)
+	}
+	defer first.Free()
+	n := GetFrameInfo(first).PTS
+
+	// The copy is unaffected by later decodes into the decoder's frame.
+	if _, err := dec.DecodeAudio(); err != nil {
+		t.Fatalf("DecodeAudio failed: %v", err)
+	}
+	if got := GetFrameInfo(first).PTS; got != n {
+		t.Errorf("copied frame PTS changed from %d to %d", n, got)
+	}
+}
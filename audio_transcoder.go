@@ -0,0 +1,297 @@
+//go:build !ios && !android && (amd64 || arm64)
+
+package ffgo
+
+import (
+	"errors"
+	"time"
+	"unsafe"
+
+	"github.com/obinnaokechukwu/ffgo/avutil"
+	"github.com/obinnaokechukwu/ffgo/swresample"
+)
+
+// AudioTranscoder wires decode → resample → encode for the audio stream of
+// a Decoder. It converts decoded audio to the encoder's sample rate, channel
+// count and sample format, regroups samples into frames of the encoder's
+// frame size, and writes them with Encoder.WriteAudioFrame.
+//
+// The transcoder reads packets with Decoder.DecodeAudio, which discards
+// packets of other streams; use a separate Decoder to transcode video from
+// the same input. It does not close the decoder or the encoder.
+//
+// Example:
+//
+//	at, err := ffgo.NewAudioTranscoder(dec, enc)
+//	if err != nil {
+//	    return err
+//	}
+//	defer at.Close()
+//	if err := at.Run(); err != nil {
+//	    return err
+//	}
+//	return enc.Close()
+type AudioTranscoder struct {
+	dec *Decoder
+	enc *Encoder
+
+	dst       AudioFormat
+	frameSize int // encoder frame size in samples; 0 = any size
+
+	resampler *Resampler
+
+	// Sample FIFO: one byte slice per plane (a single plane for packed formats)
+	fifo          [][]byte
+	sampleBytes   int // bytes per sample per plane
+	bufferedCount int // samples per channel buffered in fifo
+	encFrame      Frame
+
+	// Source timing, set by syncTo. nextPTS is the timestamp of the first
+	// sample in fifo, in 1/SampleRate units from start.
+	timed   bool
+	start   time.Duration
+	srcTB   Rational
+	nextPTS int64
+
+	done   bool
+	closed bool
+}
+
+// NewAudioTranscoder creates a transcoder from dec's audio stream to enc's
+// audio stream. Both must have audio; the decoder's audio decoder is opened
+// if necessary.
+func NewAudioTranscoder(dec *Decoder, enc *Encoder) (*AudioTranscoder, error) {
+	if dec == nil || enc == nil {
+		return nil, errors.New("ffgo: decoder and encoder cannot be nil")
+	}
+	if !dec.HasAudio() {
+		return nil, errors.New("ffgo: decoder has no audio stream")
+	}
+	if !enc.HasAudio() || enc.audioCodecCtx == nil {
+		return nil, errors.New("ffgo: encoder was not configured with audio encoding")
+	}
+	if err := dec.OpenAudioDecoder(); err != nil {
+		return nil, err
+	}
+
+	t := &AudioTranscoder{
+		dec: dec,
+		enc: enc,
+		dst: AudioFormat{
+			SampleRate:   enc.SampleRate(),
+			Channels:     enc.Channels(),
+			SampleFormat: enc.AudioSampleFormat(),
+		},
+		frameSize: enc.AudioFrameSize(),
+		nextPTS:   avutil.AV_NOPTS_VALUE,
+	}
+
+	bytes, planar := sampleFormatSize(t.dst.SampleFormat)
+	if bytes == 0 {
+		return nil, errors.New("ffgo: unsupported encoder sample format")
+	}
+	planes := 1
+	t.sampleBytes = bytes * t.dst.Channels
+	if planar {
+		planes = t.dst.Channels
+		t.sampleBytes = bytes
+	}
+	t.fifo = make([][]byte, planes)
+	return t, nil
+}
+
+// Run transcodes the remaining audio stream. It does not flush the encoder;
+// Encoder.Close does that.
+func (t *AudioTranscoder) Run() error {
+	for {
+		more, err := t.Step()
+		if err != nil {
+			return err
+		}
+		if !more {
+			return nil
+		}
+	}
+}
+
+// Step decodes one audio frame and writes every complete encoder frame it
+// yields. It returns false once the input is exhausted and all buffered
+// samples, including a final partial frame, have been written.
+func (t *AudioTranscoder) Step() (bool, error) {
+	if t.closed {
+		return false, errors.New("ffgo: audio transcoder is closed")
+	}
+	if t.done {
+		return false, nil
+	}
+
+	frame, err := t.dec.DecodeAudio()
+	if err != nil && !IsEOF(err) {
+		return false, err
+	}
+	if err != nil || frame.IsNil() {
+		return false, t.finish()
+	}
+	return true, t.feed(frame)
+}
+
+// syncTo makes the transcoder write audio with Encoder.WriteAudioFrameWithPTS
+// at the source timestamps of its samples, measured from start, instead of
+// counting samples from zero. Video written with WriteFrameWithPTS from the
+// same start then stays in sync across A/V offsets and gaps in the source.
+func (t *AudioTranscoder) syncTo(start time.Duration) {
+	t.timed = true
+	t.start = start
+	if info := t.dec.AudioStream(); info != nil {
+		t.srcTB = info.TimeBase
+	}
+}
+
+// feed resamples a decoded frame into the FIFO and writes every complete
+// encoder frame.
+func (t *AudioTranscoder) feed(frame Frame) error {
+	var err error
+	if t.resampler == nil {
+		src := AudioFormat{
+			SampleRate:   int(avutil.GetFrameSampleRate(frame.ptr)),
+			Channels:     int(avutil.GetFrameChannels(frame.ptr)),
+			SampleFormat: SampleFormat(avutil.GetFrameFormat(frame.ptr)),
+		}
+		if t.resampler, err = NewResampler(src, t.dst); err != nil {
+			return err
+		}
+	}
+	if t.timed {
+		t.anchor(frame)
+	}
+
+	out, err := t.resampler.Resample(frame)
+	if err != nil {
+		return err
+	}
+	t.push(out)
+	_ = FrameFree(&out)
+
+	return t.writeFrames(false)
+}
+
+// anchor updates nextPTS from the timestamp of a decoded frame. The samples
+// still held by the resampler and the FIFO come just before it. Drift of up
+// to 10ms is left alone so rounding jitter does not shift the output; larger
+// differences, such as a gap in the source, move the FIFO to the new time.
+func (t *AudioTranscoder) anchor(frame Frame) {
+	ts := frameTimestamp(frame.ptr)
+	if ts == avutil.AV_NOPTS_VALUE || t.srcTB.Num <= 0 || t.srcTB.Den <= 0 {
+		return
+	}
+	rate := NewRational(1, int32(t.dst.SampleRate))
+	pts := durationToPTS(ptsToDuration(ts, t.srcTB)-t.start, rate)
+	pts -= swresample.GetDelay(t.resampler.ctx, int64(t.dst.SampleRate)) + int64(t.bufferedCount)
+
+	tolerance := int64(t.dst.SampleRate / 100)
+	if diff := pts - t.nextPTS; t.nextPTS == avutil.AV_NOPTS_VALUE || diff > tolerance || diff < -tolerance {
+		t.nextPTS = pts
+	}
+}
+
+// finish drains the resampler and writes the remaining samples.
+func (t *AudioTranscoder) finish() error {
+	t.done = true
+	if t.resampler != nil {
+		out, err := t.resampler.Flush()
+		if err != nil {
+			return err
+		}
+		t.push(out)
+		_ = FrameFree(&out)
+	}
+	return t.writeFrames(true)
+}
+
+// push appends the samples of a resampled frame to the FIFO.
+func (t *AudioTranscoder) push(f Frame) {
+	if f.IsNil() {
+		return
+	}
+	n := int(avutil.GetFrameNbSamples(f.ptr))
+	if n <= 0 {
+		return
+	}
+	for p := range t.fifo {
+		data := avutil.GetFrameDataPlane(f.ptr, p)
+		t.fifo[p] = append(t.fifo[p], unsafe.Slice((*byte)(data), n*t.sampleBytes)...)
+	}
+	t.bufferedCount += n
+}
+
+// writeFrames encodes full frames from the FIFO; with final set, it also
+// writes the remaining partial frame.
+func (t *AudioTranscoder) writeFrames(final bool) error {
+	for t.bufferedCount > 0 {
+		n := t.frameSize
+		if n <= 0 || n > t.bufferedCount {
+			if n > 0 && !final {
+				return nil
+			}
+			n = t.bufferedCount
+		}
+		if err := t.writeFrame(n); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// writeFrame moves n samples from the FIFO into the encoder frame and writes it.
+func (t *AudioTranscoder) writeFrame(n int) error {
+	capacity := t.frameSize
+	if capacity <= 0 {
+		capacity = n
+	}
+	if !t.encFrame.IsNil() && audioFrameCapacity(t.encFrame, t.dst) < n {
+		_ = FrameFree(&t.encFrame)
+	}
+	if t.encFrame.IsNil() {
+		frame, err := newAudioFrame(t.dst, capacity)
+		if err != nil {
+			return err
+		}
+		t.encFrame = frame
+	} else if err := avutil.FrameMakeWritable(t.encFrame.ptr); err != nil {
+		return err
+	}
+
+	for p := range t.fifo {
+		data := avutil.GetFrameDataPlane(t.encFrame.ptr, p)
+		copy(unsafe.Slice((*byte)(data), n*t.sampleBytes), t.fifo[p][:n*t.sampleBytes])
+		t.fifo[p] = t.fifo[p][:copy(t.fifo[p], t.fifo[p][n*t.sampleBytes:])]
+	}
+	t.bufferedCount -= n
+	avutil.FrameSetNbSamples(t.encFrame.ptr, int32(n))
+	avutil.SetFramePTS(t.encFrame.ptr, avutil.AV_NOPTS_VALUE)
+
+	if t.timed && t.nextPTS != avutil.AV_NOPTS_VALUE {
+		pts := t.nextPTS
+		t.nextPTS += int64(n)
+		return t.enc.WriteAudioFrameWithPTS(t.encFrame, pts, NewRational(1, int32(t.dst.SampleRate)))
+	}
+	return t.enc.WriteAudioFrame(t.encFrame)
+}
+
+// Close releases the resampler and buffers. It does not close the decoder
+// or the encoder.
+func (t *AudioTranscoder) Close() error {
+	if t.closed {
+		return nil
+	}
+	t.closed = true
+	if t.resampler != nil {
+		_ = t.resampler.Close()
+		t.resampler = nil
+	}
+	if !t.encFrame.IsNil() {
+		_ = FrameFree(&t.encFrame)
+	}
+	t.fifo = nil
+	return nil
+}
@@ -0,0 +1,97 @@
+//go:build !ios && !android && (amd64 || arm64)
+
+package ffgo
+
+import (
+	"os/exec"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestAudioTranscoder(t *testing.T) {
+	if !requireFFmpeg(t) {
+		return
+	}
+
+	tmpDir := t.TempDir()
+	srcPath := filepath.Join(tmpDir, "src.wav")
+	cmd := exec.Command("ffmpeg", "-y",
+		"-f", "lavfi", "-i", "sine=frequency=440:duration=1:sample_rate=44100",
+		"-ac", "1", "-c:a", "pcm_s16le", srcPath)
+	if err := cmd.Run(); err != nil {
+		t.Skipf("ffmpeg CLI not available: %v", err)
+	}
+
+	dec, err := NewDecoder(srcPath)
+	if err != nil {
+		t.Fatalf("NewDecoder failed: %v", err)
+	}
+	defer dec.Close()
+
+	outPath := filepath.Join(tmpDir, "out.mkv")
+	enc, err := NewEncoderWithOptions(outPath, &EncoderOptions{
+		Video: &VideoEncoderConfig{
+			Codec:       CodecIDMJPEG,
+			Width:       160,
+			Height:      120,
+			PixelFormat: PixelFormatYUVJ420P,
+		},
+		Audio: &AudioEncoderConfig{
+			Codec:      CodecIDAAC,
+			SampleRate: 48000,
+			Channels:   2,
+		},
+	})
+	if err != nil {
+		t.Fatalf("NewEncoderWithOptions failed: %v", err)
+	}
+	defer enc.Close()
+
+	at, err := NewAudioTranscoder(dec, enc)
+	if err != nil {
+		t.Fatalf("NewAudioTranscoder failed: %v", err)
+	}
+	defer at.Close()
+	if err := at.Run(); err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+	if more, err := at.Step(); more || err != nil {
+		t.Errorf("Step after Run = %v, %v; want false, nil", more, err)
+	}
+	if err := enc.Close(); err != nil {
+		t.Fatalf("encoder Close failed: %v", err)
+	}
+
+	out, err := NewDecoder(outPath)
+	if err != nil {
+		t.Fatalf("NewDecoder(output) failed: %v", err)
+	}
+	defer out.Close()
+	as := out.AudioStream()
+	if as == nil {
+		t.Fatal("output has no audio stream")
+	}
+	if as.SampleRate != 48000 || as.Channels != 2 {
+		t.Errorf("output audio = %d Hz, %d channels; want 48000 Hz, 2 channels", as.SampleRate, as.Channels)
+	}
+
+	samples := 0
+	for {
+		f, err := out.DecodeAudio()
+		if err != nil || f.IsNil() {
+			break
+		}
+		samples += WrapFrame(f, MediaTypeAudio).NumSamples()
+	}
+	got := time.Duration(samples) * time.Second / 48000
+	if got < 900*time.Millisecond || got > 1200*time.Millisecond {
+		t.Errorf("transcoded audio lasts %v, want about 1s", got)
+	}
+}
+
+func TestAudioTranscoderValidation(t *testing.T) {
+	if _, err := NewAudioTranscoder(nil, nil); err == nil {
+		t.Error("expected error for nil decoder and encoder")
+	}
+}
@@ -49,11 +49,24 @@ var (
 	avPacketFree  func(pkt *unsafe.Pointer)
 	avPacketRef   func(dst, src uintptr) int32
 	avPacketUnref func(pkt uintptr)
+	avNewPacket   func(pkt uintptr, size int32) int32
 
 	// Subtitle decoding
 	avcodecDecodeSubtitle2 func(ctx, sub, gotSubPtr, pkt uintptr) int32
 	avsubtitleFree         func(sub uintptr)
 
+	// Codec enumeration (optional)
+	avCodecIterate   func(opaque *unsafe.Pointer) uintptr
+	avCodecIsEncoder func(codec uintptr) int32
+	avCodecIsDecoder func(codec uintptr) int32
+
+	// Hardware configurations (optional)
+	avcodecGetHWConfig func(codec uintptr, index int32) uintptr
+
+	// Codec descriptors (optional)
+	avcodecDescriptorGet       func(id int32) uintptr
+	avcodecDescriptorGetByName func(name string) uintptr
+
 	bindingsRegistered bool
 )
 
@@ -96,11 +109,20 @@ func registerBindings() {
 	purego.RegisterLibFunc(&avPacketFree, lib, "av_packet_free")
 	purego.RegisterLibFunc(&avPacketRef, lib, "av_packet_ref")
 	purego.RegisterLibFunc(&avPacketUnref, lib, "av_packet_unref")
+	purego.RegisterLibFunc(&avNewPacket, lib, "av_new_packet")
 
 	// Subtitle decoding
 	purego.RegisterLibFunc(&avcodecDecodeSubtitle2, lib, "avcodec_decode_subtitle2")
 	purego.RegisterLibFunc(&avsubtitleFree, lib, "avsubtitle_free")
 
+	// Codec enumeration
+	registerOptionalLibFunc(&avCodecIterate, lib, "av_codec_iterate")
+	registerOptionalLibFunc(&avCodecIsEncoder, lib, "av_codec_is_encoder")
+	registerOptionalLibFunc(&avCodecIsDecoder, lib, "av_codec_is_decoder")
+	registerOptionalLibFunc(&avcodecGetHWConfig, lib, "avcodec_get_hw_config")
+	registerOptionalLibFunc(&avcodecDescriptorGet, lib, "avcodec_descriptor_get")
+	registerOptionalLibFunc(&avcodecDescriptorGetByName, lib, "avcodec_descriptor_get_by_name")
+
 	bindingsRegistered = true
 }
 
@@ -384,23 +406,176 @@ func PacketUnref(pkt Packet) {
 	avPacketUnref(uintptr(pkt))
 }
 
-// AVCodec struct field offset for name (const char *name at offset 0)
-const offsetCodecName = 8 // After enum AVMediaType type (4 bytes + padding)
+// NewPacket allocates a payload of size bytes for pkt (av_new_packet) and
+// resets its other fields to their defaults.
+func NewPacket(pkt Packet, size int) error {
+	if avNewPacket == nil {
+		return bindings.ErrNotLoaded
+	}
+	ret := avNewPacket(uintptr(pkt), int32(size))
+	if ret < 0 {
+		return avutil.NewError(ret, "av_new_packet")
+	}
+	return nil
+}
 
-// GetCodecName returns the name of the codec.
+// AVCodec struct field offsets (public part of the struct, stable since FFmpeg 5.x)
+const (
+	offsetCodecName         = 0  // const char *name
+	offsetCodecLongName     = 8  // const char *long_name
+	offsetCodecType         = 16 // enum AVMediaType type
+	offsetCodecID           = 20 // enum AVCodecID id
+	offsetCodecCapabilities = 24 // int capabilities
+)
+
+// Codec capability flags (AV_CODEC_CAP_*).
+const (
+	CodecCapHardware = 1 << 18 // Codec is backed by a hardware implementation
+	CodecCapHybrid   = 1 << 19 // Codec may be backed by hardware or software
+)
+
+// GetCodecName returns the short name of the codec (e.g. "libx264").
 func GetCodecName(codec Codec) string {
 	if codec == nil {
 		return ""
 	}
-	// AVCodec.name is at offset 8 (after type field)
 	namePtr := *(*unsafe.Pointer)(unsafe.Pointer(uintptr(codec) + offsetCodecName))
-	if namePtr == nil {
+	return goString(namePtr)
+}
+
+// GetCodecLongName returns the descriptive name of the codec.
+func GetCodecLongName(codec Codec) string {
+	if codec == nil {
 		return ""
 	}
-	// Read null-terminated string
+	namePtr := *(*unsafe.Pointer)(unsafe.Pointer(uintptr(codec) + offsetCodecLongName))
 	return goString(namePtr)
 }
 
+// GetCodecType returns the media type handled by the codec.
+func GetCodecType(codec Codec) avutil.MediaType {
+	if codec == nil {
+		return avutil.MediaTypeUnknown
+	}
+	return avutil.MediaType(*(*int32)(unsafe.Pointer(uintptr(codec) + offsetCodecType)))
+}
+
+// GetCodecID returns the codec ID implemented by the codec.
+func GetCodecID(codec Codec) CodecID {
+	if codec == nil {
+		return CodecIDNone
+	}
+	return CodecID(*(*int32)(unsafe.Pointer(uintptr(codec) + offsetCodecID)))
+}
+
+// GetCodecCapabilities returns the codec's AV_CODEC_CAP_* flags.
+func GetCodecCapabilities(codec Codec) int32 {
+	if codec == nil {
+		return 0
+	}
+	return *(*int32)(unsafe.Pointer(uintptr(codec) + offsetCodecCapabilities))
+}
+
+// IsEncoder reports whether the codec is an encoder.
+func IsEncoder(codec Codec) bool {
+	if codec == nil || avCodecIsEncoder == nil {
+		return false
+	}
+	return avCodecIsEncoder(uintptr(codec)) != 0
+}
+
+// IsDecoder reports whether the codec is a decoder.
+func IsDecoder(codec Codec) bool {
+	if codec == nil || avCodecIsDecoder == nil {
+		return false
+	}
+	return avCodecIsDecoder(uintptr(codec)) != 0
+}
+
+// Codecs returns all codecs (encoders and decoders) registered in the FFmpeg build.
+// On builds where av_codec_iterate is missing, it returns nil.
+func Codecs() []Codec {
+	if avCodecIterate == nil {
+		return nil
+	}
+	var opaque unsafe.Pointer
+	var out []Codec
+	for {
+		c := unsafe.Pointer(avCodecIterate(&opaque))
+		if c == nil {
+			break
+		}
+		out = append(out, c)
+	}
+	return out
+}
+
+// Hardware configuration methods (AV_CODEC_HW_CONFIG_METHOD_*).
+const (
+	HWConfigMethodHWDeviceCtx = 0x01 // set AVCodecContext.hw_device_ctx
+	HWConfigMethodHWFramesCtx = 0x02 // set AVCodecContext.hw_frames_ctx
+	HWConfigMethodInternal    = 0x04 // codec handles the hardware itself
+	HWConfigMethodAdHoc       = 0x08 // deprecated codec-specific setup
+)
+
+// HWConfig mirrors AVCodecHWConfig.
+type HWConfig struct {
+	PixFmt     avutil.PixelFormat // hardware pixel format
+	Methods    int32              // HWConfigMethod* flags
+	DeviceType avutil.HWDeviceType
+}
+
+// GetHWConfig returns the codec's hardware configuration at index
+// (avcodec_get_hw_config). ok is false past the last configuration.
+func GetHWConfig(codec Codec, index int) (cfg HWConfig, ok bool) {
+	if codec == nil || avcodecGetHWConfig == nil {
+		return HWConfig{}, false
+	}
+	p := unsafe.Pointer(avcodecGetHWConfig(uintptr(codec), int32(index)))
+	if p == nil {
+		return HWConfig{}, false
+	}
+	// struct { enum AVPixelFormat pix_fmt; int methods; enum AVHWDeviceType device_type; }
+	fields := (*[3]int32)(p)
+	return HWConfig{
+		PixFmt:     avutil.PixelFormat(fields[0]),
+		Methods:    fields[1],
+		DeviceType: avutil.HWDeviceType(fields[2]),
+	}, true
+}
+
+// AVCodecDescriptor field offsets
+const (
+	offsetDescriptorID   = 0 // enum AVCodecID id
+	offsetDescriptorName = 8 // const char *name (after id and type)
+)
+
+// DescriptorName returns the canonical codec name from FFmpeg's codec
+// descriptor table (e.g. "hevc" for CodecIDHEVC), or "" if unknown.
+func DescriptorName(id CodecID) string {
+	if avcodecDescriptorGet == nil {
+		return ""
+	}
+	desc := unsafe.Pointer(avcodecDescriptorGet(int32(id)))
+	if desc == nil {
+		return ""
+	}
+	return goString(*(*unsafe.Pointer)(unsafe.Pointer(uintptr(desc) + offsetDescriptorName)))
+}
+
+// DescriptorID returns the codec ID whose descriptor has the given name.
+func DescriptorID(name string) (CodecID, bool) {
+	if avcodecDescriptorGetByName == nil {
+		return CodecIDNone, false
+	}
+	desc := unsafe.Pointer(avcodecDescriptorGetByName(name))
+	runtime.KeepAlive(name)
+	if desc == nil {
+		return CodecIDNone, false
+	}
+	return CodecID(*(*int32)(unsafe.Pointer(uintptr(desc) + offsetDescriptorID))), true
+}
+
 // goString converts a C string to a Go string.
 func goString(ptr unsafe.Pointer) string {
 	if ptr == nil {
@@ -546,6 +721,7 @@ const (
 	offsetCtxGopSize     = 132 // int gop_size
 	offsetCtxPixFmt      = 136 // enum AVPixelFormat pix_fmt
 	offsetCtxMaxBFrames  = 160 // int max_b_frames
+	offsetCtxSAR         = 216 // AVRational sample_aspect_ratio
 	offsetCtxSampleRate  = 352 // int sample_rate
 	offsetCtxSampleFmt   = 360 // enum AVSampleFormat sample_fmt
 	offsetCtxFrameSize   = 364 // int frame_size
@@ -698,6 +874,33 @@ func SetCtxFlags(ctx Context, flags int32) {
 	*(*int32)(unsafe.Pointer(uintptr(ctx) + offsetCtxFlags)) = flags
 }
 
+// GetCtxSampleAspectRatio returns the sample (pixel) aspect ratio from codec context.
+func GetCtxSampleAspectRatio(ctx Context) (num, den int32) {
+	if ctx == nil {
+		return 0, 1
+	}
+	_ = ffshim.Load()
+	if n, d, err := ffshim.CodecCtxSampleAspectRatio(ctx); err == nil {
+		return n, d
+	}
+	num = *(*int32)(unsafe.Pointer(uintptr(ctx) + offsetCtxSAR))
+	den = *(*int32)(unsafe.Pointer(uintptr(ctx) + offsetCtxSAR + 4))
+	return num, den
+}
+
+// SetCtxSampleAspectRatio sets the sample (pixel) aspect ratio in codec context.
+func SetCtxSampleAspectRatio(ctx Context, num, den int32) {
+	if ctx == nil {
+		return
+	}
+	_ = ffshim.Load()
+	if err := ffshim.CodecCtxSetSampleAspectRatio(ctx, num, den); err == nil {
+		return
+	}
+	*(*int32)(unsafe.Pointer(uintptr(ctx) + offsetCtxSAR)) = num
+	*(*int32)(unsafe.Pointer(uintptr(ctx) + offsetCtxSAR + 4)) = den
+}
+
 // SetCtxFramerate sets the framerate in codec context.
 func SetCtxFramerate(ctx Context, num, den int32) {
 	if ctx == nil {
@@ -1104,3 +1307,16 @@ func SetCtxHWFramesCtx(ctx Context, hwFramesCtx avutil.HWFramesContext) {
 	}
 	*(*unsafe.Pointer)(unsafe.Pointer(uintptr(ctx) + offsetCtxHWFramesCtx)) = ref
 }
+
+// SetCtxHWGetFormat installs a get_format callback on a decoder context
+// that selects the hardware pixel format hwPixFmt, so decoded frames stay in
+// GPU memory. Decoder setup fails if the codec does not offer hwPixFmt.
+// Must be called before Open2. Requires the shim, since purego cannot
+// provide the C callback; the callback uses AVCodecContext.opaque.
+func SetCtxHWGetFormat(ctx Context, hwPixFmt avutil.PixelFormat) error {
+	if ctx == nil {
+		return nil
+	}
+	_ = ffshim.Load()
+	return ffshim.CodecCtxSetHWGetFormat(ctx, int32(hwPixFmt))
+}
@@ -6,6 +6,7 @@ import (
 	"os"
 	"testing"
 
+	"github.com/obinnaokechukwu/ffgo/avutil"
 	"github.com/obinnaokechukwu/ffgo/internal/bindings"
 )
 
@@ -83,8 +84,18 @@ func TestFindDecoderByName(t *testing.T) {
 		t.Fatalf("h264 decoder not found by name")
 	}
 
-	name := GetCodecName(codec)
-	t.Logf("Found decoder: %s", name)
+	if name := GetCodecName(codec); name != "h264" {
+		t.Errorf("GetCodecName = %q, want %q", name, "h264")
+	}
+	if id := GetCodecID(codec); id != CodecIDH264 {
+		t.Errorf("GetCodecID = %d, want %d", id, CodecIDH264)
+	}
+	if mt := GetCodecType(codec); mt != avutil.MediaTypeVideo {
+		t.Errorf("GetCodecType = %d, want video", mt)
+	}
+	if !IsDecoder(codec) || IsEncoder(codec) {
+		t.Error("h264 decoder misreported as encoder")
+	}
 }
 
 func TestAllocContext3(t *testing.T) {
@@ -173,3 +184,42 @@ func TestVersion(t *testing.T) {
 	}
 	t.Logf("avcodec version: %d.%d.%d", ver>>16, (ver>>8)&0xFF, ver&0xFF)
 }
+
+func TestParseCodecID(t *testing.T) {
+	tests := []struct {
+		name string
+		want CodecID
+	}{
+		{"h264", CodecIDH264},
+		{"HEVC", CodecIDHEVC},
+		{"h265", CodecIDHEVC},
+		{" vp9 ", CodecIDVP9},
+		{"opus", CodecIDOPUS},
+	}
+	for _, tt := range tests {
+		got, ok := ParseCodecID(tt.name)
+		if !ok || got != tt.want {
+			t.Errorf("ParseCodecID(%q) = %d, %v; want %d", tt.name, got, ok, tt.want)
+		}
+	}
+	for _, name := range []string{"", "none", "not-a-codec"} {
+		if _, ok := ParseCodecID(name); ok {
+			t.Errorf("ParseCodecID(%q) succeeded, want failure", name)
+		}
+	}
+}
+
+func TestParseCodecIDEncoderName(t *testing.T) {
+	if !requireFFmpeg(t) {
+		return
+	}
+	if FindEncoderByName("libx264") == nil {
+		t.Skip("libx264 not available")
+	}
+	if id, ok := ParseCodecID("libx264"); !ok || id != CodecIDH264 {
+		t.Errorf("ParseCodecID(libx264) = %d, %v", id, ok)
+	}
+	if got := CodecIDTHEORA.String(); got != "theora" {
+		t.Errorf("CodecIDTHEORA.String() = %q, want descriptor name", got)
+	}
+}
@@ -2,6 +2,8 @@
 
 package avcodec
 
+import "strings"
+
 // CodecID represents FFmpeg codec identifiers.
 type CodecID int32
 
@@ -40,6 +42,8 @@ const (
 	CodecIDINDEO3     CodecID = 28
 	CodecIDVP3        CodecID = 29
 	CodecIDTHEORA     CodecID = 30
+	CodecIDFFV1       CodecID = 33
+	CodecIDProRes     CodecID = 147
 
 	CodecIDVP5 CodecID = 60
 	CodecIDVP6 CodecID = 61
@@ -107,11 +111,61 @@ func (id CodecID) String() string {
 		return "opus"
 	case CodecIDFLAC:
 		return "flac"
+	case CodecIDProRes:
+		return "prores"
+	case CodecIDFFV1:
+		return "ffv1"
 	default:
+		if name := DescriptorName(id); name != "" {
+			return name
+		}
 		return "unknown"
 	}
 }
 
+// namedCodecIDs lists the IDs that String names without FFmpeg's help.
+var namedCodecIDs = []CodecID{
+	CodecIDH264, CodecIDHEVC, CodecIDAV1, CodecIDVP8, CodecIDVP9, CodecIDMPEG4,
+	CodecIDMJPEG, CodecIDAAC, CodecIDMP3, CodecIDOPUS, CodecIDFLAC, CodecIDProRes,
+	CodecIDFFV1,
+}
+
+// codecAliases maps common names that are not FFmpeg codec names to IDs.
+var codecAliases = map[string]CodecID{
+	"h265": CodecIDHEVC,
+	"avc":  CodecIDH264,
+}
+
+// ParseCodecID resolves a codec name to its ID. It accepts canonical codec
+// names ("hevc", "vp9"), encoder and decoder names ("libx264", "libopus"),
+// and a few common aliases ("h265"). Matching is case-insensitive.
+func ParseCodecID(name string) (CodecID, bool) {
+	name = strings.ToLower(strings.TrimSpace(name))
+	if name == "" || name == "none" || name == "unknown" {
+		return CodecIDNone, false
+	}
+	if id, ok := codecAliases[name]; ok {
+		return id, true
+	}
+	if codec := FindEncoderByName(name); codec != nil {
+		return GetCodecID(codec), true
+	}
+	if codec := FindDecoderByName(name); codec != nil {
+		return GetCodecID(codec), true
+	}
+	if id, ok := DescriptorID(name); ok {
+		return id, true
+	}
+	// Fall back to the static names so common codecs resolve even before
+	// FFmpeg is loaded.
+	for _, id := range namedCodecIDs {
+		if id.String() == name {
+			return id, true
+		}
+	}
+	return CodecIDNone, false
+}
+
 // IsVideo returns true if the codec ID is for a video codec.
 func (id CodecID) IsVideo() bool {
 	return id > 0 && id < 65536
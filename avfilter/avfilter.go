@@ -38,6 +38,7 @@ var (
 		avfilter_graph_config        func(graphctx, log_ctx uintptr) int32
 		avfilter_graph_parse2        func(graph uintptr, filters *byte, inputs, outputs *InOut) int32
 		avfilter_graph_create_filter func(filt_ctx *Context, filt, namePtr, argsPtr, opaque, graphCtx uintptr) int32
+		avfilter_graph_send_command  func(graph uintptr, target, cmd, arg, res *byte, resLen, flags int32) int32
 
 		// Filter lookup
 		avfilter_get_by_name func(name *byte) uintptr
@@ -50,6 +51,11 @@ var (
 		av_buffersink_get_frame_flags func(ctx, frame uintptr, flags int32) int32
 		av_buffersink_get_frame       func(ctx, frame uintptr) int32
 
+		// Buffer source parameters
+		av_buffersrc_parameters_alloc func() uintptr
+		av_buffersrc_parameters_set   func(ctx, param uintptr) int32
+		av_free                       func(ptr uintptr)
+
 		// InOut management
 		avfilter_inout_alloc func() uintptr
 		avfilter_inout_free  func(inout *InOut)
@@ -68,6 +74,12 @@ const (
 	AV_BUFFERSRC_FLAG_KEEP_REF        = 8 // Keep reference to frame
 )
 
+// Graph command flags
+const (
+	AVFILTER_CMD_FLAG_ONE  = 1 // Stop once a filter understood the command
+	AVFILTER_CMD_FLAG_FAST = 2 // Only execute the command when it is fast
+)
+
 // Buffer sink flags
 const (
 	AV_BUFFERSINK_FLAG_PEEK       = 1 // Peek without consuming
@@ -96,6 +108,7 @@ func initLibrary() error {
 	purego.RegisterLibFunc(&avfilter_graph_config, libAVFilter, "avfilter_graph_config")
 	purego.RegisterLibFunc(&avfilter_graph_parse2, libAVFilter, "avfilter_graph_parse2")
 	purego.RegisterLibFunc(&avfilter_graph_create_filter, libAVFilter, "avfilter_graph_create_filter")
+	purego.RegisterLibFunc(&avfilter_graph_send_command, libAVFilter, "avfilter_graph_send_command")
 	purego.RegisterLibFunc(&avfilter_get_by_name, libAVFilter, "avfilter_get_by_name")
 	purego.RegisterLibFunc(&avfilter_link, libAVFilter, "avfilter_link")
 	purego.RegisterLibFunc(&avfilter_inout_alloc, libAVFilter, "avfilter_inout_alloc")
@@ -106,6 +119,13 @@ func initLibrary() error {
 	purego.RegisterLibFunc(&av_buffersrc_add_frame_flags, libAVFilter, "av_buffersrc_add_frame_flags")
 	purego.RegisterLibFunc(&av_buffersink_get_frame_flags, libAVFilter, "av_buffersink_get_frame_flags")
 	purego.RegisterLibFunc(&av_buffersink_get_frame, libAVFilter, "av_buffersink_get_frame")
+	purego.RegisterLibFunc(&av_buffersrc_parameters_alloc, libAVFilter, "av_buffersrc_parameters_alloc")
+	purego.RegisterLibFunc(&av_buffersrc_parameters_set, libAVFilter, "av_buffersrc_parameters_set")
+
+	// av_free lives in libavutil
+	if libAVUtil := bindings.LibAVUtil(); libAVUtil != 0 {
+		purego.RegisterLibFunc(&av_free, libAVUtil, "av_free")
+	}
 
 	return nil
 }
@@ -217,6 +237,34 @@ func GraphCreateFilter(graph Graph, filter Filter, name, args string) (Context,
 	return ctx, nil
 }
 
+// GraphSendCommand sends a command to the filters in graph matching target,
+// which may be a filter instance name, a filter type name, or "all".
+// It returns the filter's textual response, if any.
+func GraphSendCommand(graph Graph, target, cmd, arg string, flags int32) (string, error) {
+	if graph == nil {
+		return "", fmt.Errorf("avfilter: nil graph")
+	}
+	if err := Init(); err != nil {
+		return "", err
+	}
+
+	// Some filters dereference arg unconditionally, so never pass NULL.
+	argBuf := append([]byte(arg), 0)
+	res := make([]byte, 256)
+	ret := avfilter_graph_send_command(uintptr(graph), cString(target), cString(cmd), &argBuf[0], &res[0], int32(len(res)), flags)
+	response := res[:0]
+	for i, b := range res {
+		if b == 0 {
+			response = res[:i]
+			break
+		}
+	}
+	if ret < 0 {
+		return string(response), fmt.Errorf("avfilter_graph_send_command failed: %d", ret)
+	}
+	return string(response), nil
+}
+
 // GetByName finds a filter by name (e.g., "buffer", "buffersink", "scale").
 func GetByName(name string) Filter {
 	if err := Init(); err != nil {
@@ -255,6 +303,39 @@ func BufferSrcAddFrameFlags(ctx Context, frame unsafe.Pointer, flags int32) erro
 	return nil
 }
 
+// AVBufferSrcParameters struct offsets (for FFmpeg 6.x)
+const (
+	offsetBufferSrcParamsHWFramesCtx = 40 // AVBufferRef *hw_frames_ctx
+)
+
+// BufferSrcSetHWFramesCtx attaches a hardware frames context to a buffersrc
+// filter so that it accepts hardware frames. The buffersrc takes its own
+// reference; the caller keeps ownership of hwFramesCtx.
+func BufferSrcSetHWFramesCtx(ctx Context, hwFramesCtx unsafe.Pointer) error {
+	if ctx == nil {
+		return fmt.Errorf("avfilter: nil context")
+	}
+	if hwFramesCtx == nil {
+		return fmt.Errorf("avfilter: nil hardware frames context")
+	}
+	if err := Init(); err != nil {
+		return err
+	}
+	params := unsafe.Pointer(av_buffersrc_parameters_alloc())
+	if params == nil {
+		return fmt.Errorf("av_buffersrc_parameters_alloc failed")
+	}
+	*(*unsafe.Pointer)(unsafe.Pointer(uintptr(params) + offsetBufferSrcParamsHWFramesCtx)) = hwFramesCtx
+	ret := av_buffersrc_parameters_set(uintptr(ctx), uintptr(params))
+	if av_free != nil {
+		av_free(uintptr(params))
+	}
+	if ret < 0 {
+		return fmt.Errorf("av_buffersrc_parameters_set failed: %d", ret)
+	}
+	return nil
+}
+
 // BufferSinkGetFrameFlags retrieves a frame from a buffersink filter.
 // Returns the FFmpeg error code (0 on success, AVERROR_EAGAIN, AVERROR_EOF, or negative on error).
 func BufferSinkGetFrameFlags(ctx Context, frame unsafe.Pointer, flags int32) int32 {
@@ -348,6 +429,26 @@ func InOutSetNext(inout InOut, next InOut) {
 	*(*unsafe.Pointer)(unsafe.Pointer(ptr)) = next
 }
 
+// InOutGetName gets the label name from an AVFilterInOut ("" if unlabeled).
+func InOutGetName(inout InOut) string {
+	if inout == nil {
+		return ""
+	}
+	namePtr := *(*unsafe.Pointer)(unsafe.Pointer(uintptr(inout) + offsetInOutName))
+	if namePtr == nil {
+		return ""
+	}
+	var buf []byte
+	for i := uintptr(0); ; i++ {
+		b := *(*byte)(unsafe.Pointer(uintptr(namePtr) + i))
+		if b == 0 {
+			break
+		}
+		buf = append(buf, b)
+	}
+	return string(buf)
+}
+
 // InOutGetFilterCtx gets the filter_ctx from an AVFilterInOut.
 func InOutGetFilterCtx(inout InOut) Context {
 	if inout == nil {
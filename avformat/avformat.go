@@ -5,7 +5,10 @@
 package avformat
 
 import (
+	"errors"
 	"runtime"
+	"sync"
+	"sync/atomic"
 	"unsafe"
 
 	"github.com/ebitengine/purego"
@@ -61,6 +64,15 @@ var (
 	avFindInputFormat func(name string) uintptr
 	avDemuxerIterate  func(opaque *unsafe.Pointer) uintptr
 
+	avformatNetworkInit func() int32
+	avioEnumProtocols   func(opaque *unsafe.Pointer, output int32) uintptr
+
+	avStreamGetSideData func(stream uintptr, typ int32, size *uint64) uintptr
+	avStreamNewSideData func(stream uintptr, typ int32, size uint64) uintptr
+
+	avformatIndexGetEntriesCount func(stream uintptr) int32
+	avformatIndexGetEntry        func(stream uintptr, idx int32) uintptr
+
 	avioOpen         func(ctx *unsafe.Pointer, url string, flags int32) int32
 	avioOpen2        func(ctx *unsafe.Pointer, url string, flags int32, intCb uintptr, options *unsafe.Pointer) int32
 	avioClose        func(ctx uintptr) int32
@@ -121,6 +133,14 @@ func registerBindings() {
 	purego.RegisterLibFunc(&avFindBestStream, lib, "av_find_best_stream")
 	purego.RegisterLibFunc(&avFindInputFormat, lib, "av_find_input_format")
 	registerOptionalLibFunc(&avDemuxerIterate, lib, "av_demuxer_iterate")
+	registerOptionalLibFunc(&avformatNetworkInit, lib, "avformat_network_init")
+	registerOptionalLibFunc(&avioEnumProtocols, lib, "avio_enum_protocols")
+	// Deprecated in FFmpeg 6.1 and removed in FFmpeg 8; looked up on every
+	// version that still exports them.
+	registerOptionalLibFunc(&avStreamGetSideData, lib, "av_stream_get_side_data")
+	registerOptionalLibFunc(&avStreamNewSideData, lib, "av_stream_new_side_data")
+	registerOptionalLibFunc(&avformatIndexGetEntriesCount, lib, "avformat_index_get_entries_count")
+	registerOptionalLibFunc(&avformatIndexGetEntry, lib, "avformat_index_get_entry")
 
 	purego.RegisterLibFunc(&avioOpen, lib, "avio_open")
 	registerOptionalLibFunc(&avioOpen2, lib, "avio_open2")
@@ -151,6 +171,20 @@ func registerOptionalLibFunc(fptr any, handle uintptr, name string) {
 	purego.RegisterLibFunc(fptr, handle, name)
 }
 
+var networkInitOnce sync.Once
+
+// NetworkInit initializes FFmpeg's network libraries (avformat_network_init).
+// FFmpeg no longer requires it, but it is still recommended before opening
+// network URLs so TLS libraries are set up thread-safely. Only the first
+// call has an effect.
+func NetworkInit() {
+	networkInitOnce.Do(func() {
+		if avformatNetworkInit != nil {
+			avformatNetworkInit()
+		}
+	})
+}
+
 // AllocContext allocates an AVFormatContext.
 func AllocContext() FormatContext {
 	if avformatAllocContext == nil {
@@ -425,6 +459,8 @@ const (
 	offsetInputFormat     = 8   // AVInputFormat *iformat
 	offsetNumStreams      = 44  // unsigned int nb_streams
 	offsetStreams         = 48  // AVStream **streams
+	offsetURL             = 56  // char *url
+	offsetStartTime       = 64  // int64_t start_time
 	offsetDuration        = 72  // int64_t duration
 	offsetBitRate         = 80  // int64_t bit_rate
 	offsetNbPrograms      = 132 // unsigned int nb_programs
@@ -611,6 +647,29 @@ func DemuxerNames() []string {
 	return out
 }
 
+// ProtocolNames returns the names of the I/O protocols (file, rtmp, srt, ...)
+// compiled into the loaded libavformat, for input or for output. It returns
+// nil if avio_enum_protocols is unavailable.
+func ProtocolNames(output bool) []string {
+	if avioEnumProtocols == nil {
+		return nil
+	}
+	var dir int32
+	if output {
+		dir = 1
+	}
+	var opaque unsafe.Pointer
+	var out []string
+	for {
+		name := unsafe.Pointer(avioEnumProtocols(&opaque, dir))
+		if name == nil {
+			break
+		}
+		out = append(out, goString(name))
+	}
+	return out
+}
+
 // GetNumStreams returns the number of streams in the context.
 func GetNumStreams(ctx FormatContext) int {
 	if ctx == nil {
@@ -653,6 +712,22 @@ func GetDuration(ctx FormatContext) int64 {
 	return *(*int64)(unsafe.Pointer(uintptr(ctx) + offsetDuration))
 }
 
+// GetURL returns the input/output URL of the format context.
+func GetURL(ctx FormatContext) string {
+	if ctx == nil {
+		return ""
+	}
+	return goString(*(*unsafe.Pointer)(unsafe.Pointer(uintptr(ctx) + offsetURL)))
+}
+
+// GetStartTime returns the container start time in AV_TIME_BASE units.
+func GetStartTime(ctx FormatContext) int64 {
+	if ctx == nil {
+		return avutil.AV_NOPTS_VALUE
+	}
+	return *(*int64)(unsafe.Pointer(uintptr(ctx) + offsetStartTime))
+}
+
 // GetBitRate returns the bit rate.
 func GetBitRate(ctx FormatContext) int64 {
 	if ctx == nil {
@@ -681,15 +756,89 @@ func SetIOContext(ctx FormatContext, pb IOContext) {
 	*(*unsafe.Pointer)(unsafe.Pointer(uintptr(ctx) + offsetIOContext)) = pb
 }
 
+// offsetInterruptCallback is the offset of AVIOInterruptCB
+// interrupt_callback in the avformat 60 AVFormatContext layout.
+const offsetInterruptCallback = 200
+
+var (
+	interruptCBOnce sync.Once
+	interruptCBPtr  uintptr
+)
+
+// SetInterruptFlag installs an interrupt callback on ctx that makes blocking
+// I/O (av_read_frame, avio reads, network waits) fail with AVERROR_EXIT once
+// the int32 pointed to by flag becomes non-zero. flag must point to C memory
+// (e.g. from av_malloc) that stays valid until the callback is removed with a
+// nil flag or the context is closed.
+//
+// The shim's C callback is used when available. Without it, a Go callback is
+// installed by field offset, which is only supported for avformat 60.
+func SetInterruptFlag(ctx FormatContext, flag unsafe.Pointer) error {
+	if ctx == nil {
+		return errors.New("ffgo: format context is nil")
+	}
+	_ = ffshim.Load()
+	if err := ffshim.FormatCtxSetInterruptFlag(ctx, flag); err == nil {
+		return nil
+	}
+	if bindings.AVFormatVersion()>>16 != 60 {
+		return errors.New("ffgo: interrupt callback requires the ffshim library for this FFmpeg version")
+	}
+
+	interruptCBOnce.Do(func() {
+		interruptCBPtr = purego.NewCallback(func(_ purego.CDecl, opaque uintptr) int32 {
+			if opaque == 0 {
+				return 0
+			}
+			return atomic.LoadInt32((*int32)(unsafe.Pointer(opaque)))
+		})
+	})
+	cb := (*[2]uintptr)(unsafe.Pointer(uintptr(ctx) + offsetInterruptCallback))
+	if flag == nil {
+		cb[0], cb[1] = 0, 0
+		return nil
+	}
+	cb[0], cb[1] = interruptCBPtr, uintptr(flag)
+	return nil
+}
+
 // AVStream struct field offsets (for FFmpeg 6.x/7.x)
 // Verified with offsetof() on FFmpeg 7.1.1
 const (
-	offsetStreamIndex        = 8  // int index
-	offsetStreamID           = 12 // int id
-	offsetStreamCodecPar     = 16 // AVCodecParameters *codecpar
-	offsetStreamTimeBase     = 32 // AVRational time_base
-	offsetStreamMetadata     = 80 // AVDictionary *metadata
-	offsetStreamAvgFrameRate = 88 // AVRational avg_frame_rate
+	offsetStreamIndex        = 8   // int index
+	offsetStreamID           = 12  // int id
+	offsetStreamCodecPar     = 16  // AVCodecParameters *codecpar
+	offsetStreamTimeBase     = 32  // AVRational time_base
+	offsetStreamStartTime    = 40  // int64_t start_time
+	offsetStreamDuration     = 48  // int64_t duration
+	offsetStreamNbFrames     = 56  // int64_t nb_frames
+	offsetStreamDisposition  = 64  // int disposition
+	offsetStreamSAR          = 72  // AVRational sample_aspect_ratio
+	offsetStreamMetadata     = 80  // AVDictionary *metadata
+	offsetStreamAvgFrameRate = 88  // AVRational avg_frame_rate
+	offsetStreamRFrameRate   = 216 // AVRational r_frame_rate
+)
+
+// Stream disposition flags (AV_DISPOSITION_*).
+const (
+	AV_DISPOSITION_DEFAULT          = 0x00001
+	AV_DISPOSITION_DUB              = 0x00002
+	AV_DISPOSITION_ORIGINAL         = 0x00004
+	AV_DISPOSITION_COMMENT          = 0x00008
+	AV_DISPOSITION_LYRICS           = 0x00010
+	AV_DISPOSITION_KARAOKE          = 0x00020
+	AV_DISPOSITION_FORCED           = 0x00040
+	AV_DISPOSITION_HEARING_IMPAIRED = 0x00080
+	AV_DISPOSITION_VISUAL_IMPAIRED  = 0x00100
+	AV_DISPOSITION_CLEAN_EFFECTS    = 0x00200
+	AV_DISPOSITION_ATTACHED_PIC     = 0x00400
+	AV_DISPOSITION_TIMED_THUMBNAILS = 0x00800
+	AV_DISPOSITION_NON_DIEGETIC     = 0x01000
+	AV_DISPOSITION_CAPTIONS         = 0x10000
+	AV_DISPOSITION_DESCRIPTIONS     = 0x20000
+	AV_DISPOSITION_METADATA         = 0x40000
+	AV_DISPOSITION_DEPENDENT        = 0x80000
+	AV_DISPOSITION_STILL_IMAGE      = 0x100000
 )
 
 // GetStreamIndex returns the stream index.
@@ -716,8 +865,10 @@ const (
 	offsetCodecParExtradata     = 16  // uint8_t *extradata
 	offsetCodecParExtradataSize = 24  // int extradata_size
 	offsetCodecParFormat        = 28  // int format (pixel format or sample format)
+	offsetCodecParBitRate       = 32  // int64_t bit_rate
 	offsetCodecParWidth         = 56  // int width
 	offsetCodecParHeight        = 60  // int height
+	offsetCodecParSAR           = 64  // AVRational sample_aspect_ratio
 	offsetCodecParSampleRate    = 116 // int sample_rate
 	offsetCodecParChannels      = 148 // ch_layout.nb_channels (int in AVChannelLayout at offset 136 + 12)
 )
@@ -760,6 +911,17 @@ func GetCodecParHeight(par avcodec.Parameters) int32 {
 	return *(*int32)(unsafe.Pointer(uintptr(par) + offsetCodecParHeight))
 }
 
+// GetCodecParSampleAspectRatio returns the video sample (pixel) aspect
+// ratio from codec parameters. 0/1 means unknown.
+func GetCodecParSampleAspectRatio(par avcodec.Parameters) (num, den int32) {
+	if par == nil {
+		return 0, 1
+	}
+	num = *(*int32)(unsafe.Pointer(uintptr(par) + offsetCodecParSAR))
+	den = *(*int32)(unsafe.Pointer(uintptr(par) + offsetCodecParSAR + 4))
+	return
+}
+
 // GetCodecParFormat returns the pixel format (video) or sample format (audio).
 func GetCodecParFormat(par avcodec.Parameters) int32 {
 	if par == nil {
@@ -771,6 +933,14 @@ func GetCodecParFormat(par avcodec.Parameters) int32 {
 	return *(*int32)(unsafe.Pointer(uintptr(par) + offsetCodecParFormat))
 }
 
+// GetCodecParBitRate returns the average bit rate from codec parameters.
+func GetCodecParBitRate(par avcodec.Parameters) int64 {
+	if par == nil {
+		return 0
+	}
+	return *(*int64)(unsafe.Pointer(uintptr(par) + offsetCodecParBitRate))
+}
+
 // GetCodecParSampleRate returns the audio sample rate.
 func GetCodecParSampleRate(par avcodec.Parameters) int32 {
 	if par == nil {
@@ -837,6 +1007,22 @@ func SetCodecParCodecID(par avcodec.Parameters, codecID avcodec.CodecID) {
 	*(*int32)(unsafe.Pointer(uintptr(par) + offsetCodecParCodecID)) = int32(codecID)
 }
 
+// SetCodecParWidth sets the video width in codec parameters.
+func SetCodecParWidth(par avcodec.Parameters, width int32) {
+	if par == nil {
+		return
+	}
+	*(*int32)(unsafe.Pointer(uintptr(par) + offsetCodecParWidth)) = width
+}
+
+// SetCodecParHeight sets the video height in codec parameters.
+func SetCodecParHeight(par avcodec.Parameters, height int32) {
+	if par == nil {
+		return
+	}
+	*(*int32)(unsafe.Pointer(uintptr(par) + offsetCodecParHeight)) = height
+}
+
 // SetCodecParExtradata sets the extradata in codec parameters.
 // The data is copied into memory allocated by FFmpeg's allocator.
 // Any existing extradata is freed first.
@@ -882,6 +1068,202 @@ func GetStreamAvgFrameRate(stream Stream) (num, den int32) {
 	return
 }
 
+// GetStreamRFrameRate returns the real base frame rate (num/den), i.e. the
+// lowest frame rate that can represent all timestamps exactly.
+func GetStreamRFrameRate(stream Stream) (num, den int32) {
+	if stream == nil {
+		return 0, 1
+	}
+	num = *(*int32)(unsafe.Pointer(uintptr(stream) + offsetStreamRFrameRate))
+	den = *(*int32)(unsafe.Pointer(uintptr(stream) + offsetStreamRFrameRate + 4))
+	return
+}
+
+// GetStreamSampleAspectRatio returns the stream's sample aspect ratio (num/den).
+func GetStreamSampleAspectRatio(stream Stream) (num, den int32) {
+	if stream == nil {
+		return 0, 1
+	}
+	num = *(*int32)(unsafe.Pointer(uintptr(stream) + offsetStreamSAR))
+	den = *(*int32)(unsafe.Pointer(uintptr(stream) + offsetStreamSAR + 4))
+	return
+}
+
+// SetStreamSampleAspectRatio sets the stream's sample aspect ratio. Muxers
+// use it (rather than the codec parameters) when writing container-level
+// aspect ratio information.
+func SetStreamSampleAspectRatio(stream Stream, num, den int32) {
+	if stream == nil {
+		return
+	}
+	*(*int32)(unsafe.Pointer(uintptr(stream) + offsetStreamSAR)) = num
+	*(*int32)(unsafe.Pointer(uintptr(stream) + offsetStreamSAR + 4)) = den
+}
+
+// GetStreamStartTime returns the stream start time in stream time base units.
+func GetStreamStartTime(stream Stream) int64 {
+	if stream == nil {
+		return avutil.AV_NOPTS_VALUE
+	}
+	return *(*int64)(unsafe.Pointer(uintptr(stream) + offsetStreamStartTime))
+}
+
+// GetStreamDuration returns the stream duration in stream time base units.
+func GetStreamDuration(stream Stream) int64 {
+	if stream == nil {
+		return avutil.AV_NOPTS_VALUE
+	}
+	return *(*int64)(unsafe.Pointer(uintptr(stream) + offsetStreamDuration))
+}
+
+// GetStreamNbFrames returns the number of frames in the stream, or 0 if unknown.
+func GetStreamNbFrames(stream Stream) int64 {
+	if stream == nil {
+		return 0
+	}
+	return *(*int64)(unsafe.Pointer(uintptr(stream) + offsetStreamNbFrames))
+}
+
+// GetStreamDisposition returns the stream's AV_DISPOSITION_* flags.
+func GetStreamDisposition(stream Stream) int32 {
+	if stream == nil {
+		return 0
+	}
+	return *(*int32)(unsafe.Pointer(uintptr(stream) + offsetStreamDisposition))
+}
+
+// pktDataDisplayMatrix is AV_PKT_DATA_DISPLAYMATRIX.
+const pktDataDisplayMatrix = 5
+
+// avformatNoStreamSideData is the libavformat major version (FFmpeg 8) that
+// removed av_stream_get_side_data and av_stream_new_side_data.
+const avformatNoStreamSideData = 62
+
+// GetStreamDisplayMatrix returns the stream's display matrix side data
+// (AV_PKT_DATA_DISPLAYMATRIX) and whether it was present. The matrix is
+// row-major with 16.16 fixed-point entries, except the last column which is
+// 2.30.
+//
+// The shim is used when available. Without it the matrix is read with
+// av_stream_get_side_data, which is deprecated from FFmpeg 6.1 but still
+// exported up to FFmpeg 7; FFmpeg 8 (libavformat 62) removed it, so on
+// FFmpeg 8 without the shim this always reports false.
+func GetStreamDisplayMatrix(stream Stream) ([9]int32, bool) {
+	var m [9]int32
+	if stream == nil {
+		return m, false
+	}
+	_ = ffshim.Load()
+	data, err := ffshim.StreamDisplayMatrix(stream)
+	if err != nil {
+		if avStreamGetSideData == nil || bindings.AVFormatVersion()>>16 >= avformatNoStreamSideData {
+			return m, false
+		}
+		var size uint64
+		data = unsafe.Pointer(avStreamGetSideData(uintptr(stream), pktDataDisplayMatrix, &size))
+		if uint32(size) < uint32(unsafe.Sizeof(m)) {
+			return m, false
+		}
+	}
+	if data == nil {
+		return m, false
+	}
+	m = *(*[9]int32)(data)
+	return m, true
+}
+
+// avformatCodecParSideData is the libavformat version (6.1) from which
+// stream side data lives in AVCodecParameters.coded_side_data.
+const avformatCodecParSideData = 60<<16 | 15<<8 | 100
+
+// maxPktDataType bounds the AV_PKT_DATA_* types CopyStreamSideData probes.
+// It is above AV_PKT_DATA_NB of every supported FFmpeg version.
+const maxPktDataType = 64
+
+// CopyStreamSideData copies the stream-level side data of src (display
+// matrix, stereo 3D, spherical mapping, mastering display metadata, ...) to
+// dst, so that e.g. a remuxed phone recording keeps its rotation.
+//
+// From FFmpeg 6.1 the side data is part of the codec parameters and is
+// copied by avcodec.ParametersCopy, so this is a no-op there.
+func CopyStreamSideData(dst, src Stream) error {
+	if dst == nil || src == nil {
+		return nil
+	}
+	if bindings.AVFormatVersion() >= avformatCodecParSideData || avStreamGetSideData == nil || avStreamNewSideData == nil {
+		return nil
+	}
+	for typ := int32(0); typ < maxPktDataType; typ++ {
+		// size is an int before FFmpeg 5, so only its low half is reliable.
+		var size uint64
+		data := avStreamGetSideData(uintptr(src), typ, &size)
+		n := int(uint32(size))
+		if data == 0 {
+			continue
+		}
+		out := avStreamNewSideData(uintptr(dst), typ, uint64(n))
+		if out == 0 {
+			return errors.New("ffgo: failed to allocate stream side data")
+		}
+		copy(unsafe.Slice((*byte)(unsafe.Pointer(out)), n), unsafe.Slice((*byte)(unsafe.Pointer(data)), n))
+	}
+	return nil
+}
+
+// IndexFlagKeyframe is AVINDEX_KEYFRAME.
+const IndexFlagKeyframe = 1
+
+// IndexEntry is an entry of a stream's seek index (AVIndexEntry).
+type IndexEntry struct {
+	Pos         int64 // byte position
+	Timestamp   int64 // in stream time base
+	Flags       int32 // AVINDEX_* flags
+	Size        int32
+	MinDistance int32
+}
+
+// StreamIndexEntries returns the seek index demuxers build for stream from
+// the container's index (e.g. Matroska Cues) or while reading packets.
+// It requires avformat_index_get_entries_count (FFmpeg 4.4+).
+func StreamIndexEntries(stream Stream) ([]IndexEntry, error) {
+	if stream == nil {
+		return nil, errors.New("ffgo: stream is nil")
+	}
+	if avformatIndexGetEntriesCount == nil || avformatIndexGetEntry == nil {
+		return nil, errors.New("ffgo: avformat_index_get_entry not available")
+	}
+	n := avformatIndexGetEntriesCount(uintptr(stream))
+	entries := make([]IndexEntry, 0, n)
+	for i := int32(0); i < n; i++ {
+		p := avformatIndexGetEntry(uintptr(stream), i)
+		if p == 0 {
+			continue
+		}
+		// AVIndexEntry: int64_t pos; int64_t timestamp; int flags:2, size:30;
+		// int min_distance.
+		raw := (*struct {
+			pos, timestamp int64
+			bits, minDist  int32
+		})(unsafe.Pointer(p))
+		entries = append(entries, IndexEntry{
+			Pos:         raw.pos,
+			Timestamp:   raw.timestamp,
+			Flags:       raw.bits & 3,
+			Size:        int32(uint32(raw.bits) >> 2),
+			MinDistance: raw.minDist,
+		})
+	}
+	return entries, nil
+}
+
+// SetStreamDisposition sets the stream's AV_DISPOSITION_* flags.
+func SetStreamDisposition(stream Stream, disposition int32) {
+	if stream == nil {
+		return
+	}
+	*(*int32)(unsafe.Pointer(uintptr(stream) + offsetStreamDisposition)) = disposition
+}
+
 // AVFormatContext output field offsets (for FFmpeg 6.x)
 const (
 	offsetOformat = 16 // AVOutputFormat *oformat
@@ -889,6 +1271,7 @@ const (
 
 // AVOutputFormat field offsets (for FFmpeg 6.x)
 const (
+	offsetOutputFormatName  = 0  // const char *name
 	offsetOutputFormatFlags = 44 // int flags
 )
 
@@ -959,6 +1342,15 @@ func GetOutputFormatFlags(oformat OutputFormat) int32 {
 	return *(*int32)(unsafe.Pointer(uintptr(oformat) + offsetOutputFormatFlags))
 }
 
+// OutputFormatName returns the muxer short name.
+func OutputFormatName(oformat OutputFormat) string {
+	if oformat == nil {
+		return ""
+	}
+	namePtr := *(*unsafe.Pointer)(unsafe.Pointer(uintptr(oformat) + offsetOutputFormatName))
+	return goString(namePtr)
+}
+
 // NeedsGlobalHeader returns true if the output format needs global header.
 func NeedsGlobalHeader(ctx FormatContext) bool {
 	oformat := GetOutputFormat(ctx)
@@ -6,10 +6,13 @@
 package avutil
 
 import (
+	"errors"
+	"sync"
 	"unsafe"
 
 	"github.com/ebitengine/purego"
 	"github.com/obinnaokechukwu/ffgo/internal/bindings"
+	ffshim "github.com/obinnaokechukwu/ffgo/internal/shim"
 )
 
 // Frame is an opaque FFmpeg AVFrame pointer.
@@ -35,6 +38,7 @@ var (
 	avFrameUnref        func(frame uintptr)
 	avFrameGetBuffer    func(frame uintptr, align int32) int32
 	avFrameMakeWritable func(frame uintptr) int32
+	avFrameIsWritable   func(frame uintptr) int32
 
 	avMalloc func(size uintptr) uintptr
 	avFree   func(ptr uintptr)
@@ -46,9 +50,12 @@ var (
 
 	avStrerror func(errnum int32, errbuf *byte, errbufSize uintptr) int32
 
+	avLogGetLevel func() int32
+
 	// Channel layout functions (FFmpeg 5.1+)
-	avChannelLayoutDefault func(chLayout uintptr, nbChannels int32)
-	avChannelLayoutCopy    func(dst, src uintptr) int32
+	avChannelLayoutDefault  func(chLayout uintptr, nbChannels int32)
+	avChannelLayoutCopy     func(dst, src uintptr) int32
+	avChannelLayoutFromMask func(chLayout uintptr, mask uint64) int32
 
 	// AVOptions API (for setting codec options like preset, profile, etc.)
 	avOptSet       func(obj uintptr, name, val string, searchFlags int32) int32
@@ -60,6 +67,15 @@ var (
 	avHWDeviceFindTypeByName func(name string) int32
 	avHWDeviceGetTypeName    func(deviceType int32) uintptr
 	avHWFrameTransferData    func(dst, src uintptr, flags int32) int32
+	avHWFrameCtxAlloc        func(deviceCtx uintptr) uintptr
+	avHWFrameCtxInit         func(ref uintptr) int32
+	avHWFrameGetBuffer       func(framesCtx, frame uintptr, flags int32) int32
+
+	// Pixel format helpers
+	avGetPixFmtName  func(pixFmt int32) uintptr
+	avGetPixFmt      func(name string) int32
+	avPixFmtDescGet  func(pixFmt int32) uintptr
+	avImageFillBlack func(dstData *[4]unsafe.Pointer, dstLinesize *[4]int64, pixFmt int32, colorRange int32, width, height int32) int32
 
 	// Buffer reference functions
 	avBufferCreate func(data uintptr, size int32, freeCb uintptr, opaque uintptr, flags int32) uintptr
@@ -96,6 +112,7 @@ func registerBindings() {
 	purego.RegisterLibFunc(&avFrameUnref, lib, "av_frame_unref")
 	purego.RegisterLibFunc(&avFrameGetBuffer, lib, "av_frame_get_buffer")
 	purego.RegisterLibFunc(&avFrameMakeWritable, lib, "av_frame_make_writable")
+	purego.RegisterLibFunc(&avFrameIsWritable, lib, "av_frame_is_writable")
 
 	purego.RegisterLibFunc(&avMalloc, lib, "av_malloc")
 	purego.RegisterLibFunc(&avFree, lib, "av_free")
@@ -107,9 +124,12 @@ func registerBindings() {
 
 	purego.RegisterLibFunc(&avStrerror, lib, "av_strerror")
 
+	purego.RegisterLibFunc(&avLogGetLevel, lib, "av_log_get_level")
+
 	// Channel layout functions (FFmpeg 5.1+)
 	purego.RegisterLibFunc(&avChannelLayoutDefault, lib, "av_channel_layout_default")
 	purego.RegisterLibFunc(&avChannelLayoutCopy, lib, "av_channel_layout_copy")
+	purego.RegisterLibFunc(&avChannelLayoutFromMask, lib, "av_channel_layout_from_mask")
 
 	// AVOptions API
 	purego.RegisterLibFunc(&avOptSet, lib, "av_opt_set")
@@ -121,6 +141,15 @@ func registerBindings() {
 	purego.RegisterLibFunc(&avHWDeviceFindTypeByName, lib, "av_hwdevice_find_type_by_name")
 	purego.RegisterLibFunc(&avHWDeviceGetTypeName, lib, "av_hwdevice_get_type_name")
 	purego.RegisterLibFunc(&avHWFrameTransferData, lib, "av_hwframe_transfer_data")
+	purego.RegisterLibFunc(&avHWFrameCtxAlloc, lib, "av_hwframe_ctx_alloc")
+	purego.RegisterLibFunc(&avHWFrameCtxInit, lib, "av_hwframe_ctx_init")
+	purego.RegisterLibFunc(&avHWFrameGetBuffer, lib, "av_hwframe_get_buffer")
+
+	// Pixel format helpers
+	purego.RegisterLibFunc(&avGetPixFmtName, lib, "av_get_pix_fmt_name")
+	purego.RegisterLibFunc(&avGetPixFmt, lib, "av_get_pix_fmt")
+	purego.RegisterLibFunc(&avPixFmtDescGet, lib, "av_pix_fmt_desc_get")
+	purego.RegisterLibFunc(&avImageFillBlack, lib, "av_image_fill_black")
 
 	// Buffer reference functions
 	purego.RegisterLibFunc(&avBufferCreate, lib, "av_buffer_create")
@@ -198,6 +227,15 @@ func FrameMakeWritable(frame Frame) error {
 	return nil
 }
 
+// FrameIsWritable reports whether the frame has buffers that are not
+// shared with any other frame, i.e. whether it can be written without a copy.
+func FrameIsWritable(frame Frame) bool {
+	if avFrameIsWritable == nil || frame == nil {
+		return false
+	}
+	return avFrameIsWritable(uintptr(frame)) > 0
+}
+
 // NoPTSValue is the value used to indicate no PTS.
 const NoPTSValue int64 = -9223372036854775808 // 0x8000000000000000
 
@@ -220,14 +258,21 @@ const (
 	offsetNbSamples = 112 // int nb_samples at offset 112
 	offsetFormat    = 116 // int format at offset 116
 
-	// Key frame flag
+	// Key frame flag and picture type
 	offsetKeyFrame = 120 // int key_frame at offset 120
+	offsetPictType = 124 // enum AVPictureType pict_type at offset 124
+
+	// Sample (pixel) aspect ratio
+	offsetSampleAspectRatio = 128 // AVRational sample_aspect_ratio at offset 128
 
 	// Timing fields
 	offsetPts = 136 // int64 pts at offset 136
 
 	// Audio fields
 	offsetSampleRate = 216 // int sample_rate at offset 216 (FFmpeg 6.x)
+
+	// Hardware fields
+	offsetHWFramesCtx = 392 // AVBufferRef *hw_frames_ctx at offset 392
 )
 
 // GetFrameWidth returns the width of the frame.
@@ -278,6 +323,26 @@ func SetFrameFormat(frame Frame, format int32) {
 	*(*int32)(unsafe.Pointer(uintptr(frame) + offsetFormat)) = format
 }
 
+// GetFrameSampleAspectRatio returns the frame's sample (pixel) aspect ratio.
+// 0/1 means unknown.
+func GetFrameSampleAspectRatio(frame Frame) (num, den int32) {
+	if frame == nil {
+		return 0, 1
+	}
+	num = *(*int32)(unsafe.Pointer(uintptr(frame) + offsetSampleAspectRatio))
+	den = *(*int32)(unsafe.Pointer(uintptr(frame) + offsetSampleAspectRatio + 4))
+	return
+}
+
+// SetFrameSampleAspectRatio sets the frame's sample (pixel) aspect ratio.
+func SetFrameSampleAspectRatio(frame Frame, num, den int32) {
+	if frame == nil {
+		return
+	}
+	*(*int32)(unsafe.Pointer(uintptr(frame) + offsetSampleAspectRatio)) = num
+	*(*int32)(unsafe.Pointer(uintptr(frame) + offsetSampleAspectRatio + 4)) = den
+}
+
 // GetFramePTS returns the presentation timestamp.
 func GetFramePTS(frame Frame) int64 {
 	if frame == nil {
@@ -331,7 +396,10 @@ func FrameSetSampleRate(frame Frame, sampleRate int32) {
 	SetFrameSampleRate(frame, sampleRate)
 }
 
-// FrameSetChannels sets the number of audio channels in the frame.
+// FrameSetChannels sets the number of audio channels in the frame
+// (ch_layout.nb_channels). It leaves the channel order as is; use
+// GetFrameChLayoutPtr with ChannelLayoutFromMask or ChannelLayoutDefault to
+// set a complete layout.
 // Note: In FFmpeg 5.1+, this should be done via AVChannelLayout, but we support legacy mode.
 const offsetChannels = 148 // nb_channels in FFmpeg 5.x+ (via ch_layout.nb_channels)
 
@@ -339,7 +407,7 @@ func FrameSetChannels(frame Frame, channels int32) {
 	if frame == nil {
 		return
 	}
-	*(*int32)(unsafe.Pointer(uintptr(frame) + offsetChannels)) = channels
+	*(*int32)(unsafe.Add(frame, frameChannelsOffset())) = channels
 }
 
 // GetFrameChannels returns the number of audio channels.
@@ -347,7 +415,60 @@ func GetFrameChannels(frame Frame) int32 {
 	if frame == nil {
 		return 0
 	}
-	return *(*int32)(unsafe.Pointer(uintptr(frame) + offsetChannels))
+	return *(*int32)(unsafe.Add(frame, frameChannelsOffset()))
+}
+
+// GetFrameChLayoutPtr returns a pointer to the frame's AVChannelLayout
+// ch_layout, or nil if its offset is not known for the loaded FFmpeg.
+func GetFrameChLayoutPtr(frame Frame) unsafe.Pointer {
+	if frame == nil {
+		return nil
+	}
+	off := frameChLayoutOffset()
+	if off == 0 {
+		return nil
+	}
+	return unsafe.Add(frame, off)
+}
+
+// GetFrameChannelLayoutMask returns the AV_CH_* mask of the frame's channel
+// layout, or 0 if the layout is not in native order or cannot be read.
+func GetFrameChannelLayoutMask(frame Frame) uint64 {
+	chLayout := GetFrameChLayoutPtr(frame)
+	if chLayout == nil {
+		return 0
+	}
+	// AVChannelLayout: enum order; int nb_channels; union { uint64_t mask; ... }
+	if *(*int32)(chLayout) != channelOrderNative {
+		return 0
+	}
+	return *(*uint64)(unsafe.Add(chLayout, 8))
+}
+
+// channelOrderNative is AV_CHANNEL_ORDER_NATIVE.
+const channelOrderNative = 1
+
+// frameChLayoutOffset returns the offset of AVFrame.ch_layout. In FFmpeg 6
+// and 7 (avutil 58 and 59) it directly precedes the 64-bit duration field,
+// the last one of the struct. It is 0 for other versions.
+func frameChLayoutOffset() uintptr {
+	switch bindings.AVUtilVersion() >> 16 {
+	case 58, 59:
+		if _, duration := frameTimingOffsets(); duration != 0 {
+			const sizeofAVChannelLayout = 24
+			return duration - sizeofAVChannelLayout
+		}
+	}
+	return 0
+}
+
+// frameChannelsOffset returns the offset of ch_layout.nb_channels, falling
+// back to the legacy offsetChannels when ch_layout cannot be located.
+func frameChannelsOffset() uintptr {
+	if off := frameChLayoutOffset(); off != 0 {
+		return off + 4
+	}
+	return offsetChannels
 }
 
 // FrameSetFormat is an alias for SetFrameFormat
@@ -376,6 +497,88 @@ func GetFrameKeyFrame(frame Frame) int32 {
 	return *(*int32)(unsafe.Pointer(uintptr(frame) + offsetKeyFrame))
 }
 
+// PictureType mirrors FFmpeg's AVPictureType.
+type PictureType int32
+
+// Picture types (AV_PICTURE_TYPE_*).
+const (
+	PictureTypeNone PictureType = 0 // Undefined; the encoder decides
+	PictureTypeI    PictureType = 1 // Intra
+	PictureTypeP    PictureType = 2 // Predicted
+	PictureTypeB    PictureType = 3 // Bi-directionally predicted
+)
+
+// GetFramePictType returns the frame's picture type.
+func GetFramePictType(frame Frame) PictureType {
+	if frame == nil {
+		return PictureTypeNone
+	}
+	return *(*PictureType)(unsafe.Pointer(uintptr(frame) + offsetPictType))
+}
+
+// SetFramePictType sets the frame's picture type. Encoders treat
+// PictureTypeI on an input frame as a request to start a new keyframe there.
+func SetFramePictType(frame Frame, t PictureType) {
+	if frame == nil {
+		return
+	}
+	*(*PictureType)(unsafe.Pointer(uintptr(frame) + offsetPictType)) = t
+}
+
+// GetFrameBestEffortTimestamp returns the frame timestamp estimated by the
+// decoder from PTS and DTS (best_effort_timestamp), which is set for frames
+// whose PTS is missing. It returns NoPTSValue if the field cannot be
+// located.
+func GetFrameBestEffortTimestamp(frame Frame) int64 {
+	if frame == nil {
+		return NoPTSValue
+	}
+	off, _ := frameTimingOffsets()
+	if off == 0 {
+		return NoPTSValue
+	}
+	return *(*int64)(unsafe.Add(frame, off))
+}
+
+// GetFrameDuration returns the frame duration in the stream time base
+// (duration, or pkt_duration before FFmpeg 6), or 0 if unknown.
+func GetFrameDuration(frame Frame) int64 {
+	if frame == nil {
+		return 0
+	}
+	_, off := frameTimingOffsets()
+	if off == 0 {
+		return 0
+	}
+	return *(*int64)(unsafe.Add(frame, off))
+}
+
+var (
+	frameTimingOnce     sync.Once
+	offsetBestEffortTS  uintptr
+	offsetFrameDuration uintptr
+)
+
+// frameTimingOffsets returns the offsets of best_effort_timestamp and
+// duration, from the shim if loaded, otherwise from the AVFrame layouts of
+// FFmpeg 6 (avutil 58) and 7 (avutil 59). Both are 0 for other versions.
+func frameTimingOffsets() (bestEffort, duration uintptr) {
+	frameTimingOnce.Do(func() {
+		_ = ffshim.Load()
+		if b, d, err := ffshim.AVFrameTimingOffsets(); err == nil {
+			offsetBestEffortTS, offsetFrameDuration = uintptr(b), uintptr(d)
+			return
+		}
+		switch bindings.AVUtilVersion() >> 16 {
+		case 58:
+			offsetBestEffortTS, offsetFrameDuration = 344, 472
+		case 59:
+			offsetBestEffortTS, offsetFrameDuration = 320, 432
+		}
+	})
+	return offsetBestEffortTS, offsetFrameDuration
+}
+
 // GetFrameLinesizePlane returns the linesize for a given plane.
 func GetFrameLinesizePlane(frame Frame, plane int) int32 {
 	if frame == nil || plane < 0 || plane >= 8 {
@@ -411,6 +614,15 @@ func GetFrameLinesize(frame Frame) [8]int32 {
 	return *linesizeArray
 }
 
+// LogGetLevel returns FFmpeg's current log level (av_log_get_level), or
+// AV_LOG_INFO (32), FFmpeg's default, if libavutil is not loaded.
+func LogGetLevel() int32 {
+	if avLogGetLevel == nil {
+		return 32
+	}
+	return avLogGetLevel()
+}
+
 // Malloc allocates memory using FFmpeg's allocator.
 func Malloc(size uintptr) unsafe.Pointer {
 	if avMalloc == nil {
@@ -475,6 +687,19 @@ func ChannelLayoutDefault(chLayout unsafe.Pointer, nbChannels int32) {
 	avChannelLayoutDefault(uintptr(chLayout), nbChannels)
 }
 
+// ChannelLayoutFromMask initializes chLayout as a native layout with the
+// channels in mask (AV_CH_* bits).
+func ChannelLayoutFromMask(chLayout unsafe.Pointer, mask uint64) error {
+	if avChannelLayoutFromMask == nil || chLayout == nil {
+		return errors.New("avutil: av_channel_layout_from_mask not available")
+	}
+	ret := avChannelLayoutFromMask(uintptr(chLayout), mask)
+	if ret < 0 {
+		return NewError(ret, "av_channel_layout_from_mask")
+	}
+	return nil
+}
+
 // ChannelLayoutCopy copies a channel layout from src to dst.
 func ChannelLayoutCopy(dst, src unsafe.Pointer) error {
 	if avChannelLayoutCopy == nil {
@@ -609,6 +834,226 @@ func HWFrameTransferData(dst, src Frame, flags int32) error {
 	return nil
 }
 
+// GetFrameHWFramesCtx returns the hardware frames context of a hardware frame.
+// Returns nil for software frames.
+func GetFrameHWFramesCtx(frame Frame) HWFramesContext {
+	if frame == nil {
+		return nil
+	}
+	return *(*unsafe.Pointer)(unsafe.Pointer(uintptr(frame) + offsetHWFramesCtx))
+}
+
+// HWFramesConfig holds the AVHWFramesContext fields that must be set before
+// the frames context is initialized.
+type HWFramesConfig struct {
+	Format          PixelFormat // hardware pixel format (e.g. "vaapi", "cuda")
+	SWFormat        PixelFormat // software format of the surfaces (e.g. NV12)
+	Width, Height   int
+	InitialPoolSize int // surfaces to preallocate; 0 for a dynamic pool
+}
+
+// HWFramesCtxCreate allocates and initializes a hardware frames context on
+// the given device (av_hwframe_ctx_alloc + av_hwframe_ctx_init).
+// The returned reference must be freed with FreeBufferRef.
+//
+// Setting the frames parameters requires the shim; without it the fields are
+// written at their FFmpeg 5-7 offsets.
+func HWFramesCtxCreate(device HWDeviceContext, cfg HWFramesConfig) (HWFramesContext, error) {
+	if avHWFrameCtxAlloc == nil || avHWFrameCtxInit == nil {
+		return nil, bindings.ErrNotLoaded
+	}
+	if device == nil {
+		return nil, NewError(-22, "av_hwframe_ctx_alloc: nil device")
+	}
+	ref := unsafe.Pointer(avHWFrameCtxAlloc(uintptr(device)))
+	if ref == nil {
+		return nil, NewError(-12, "av_hwframe_ctx_alloc")
+	}
+	setHWFramesParams(ref, cfg)
+	if ret := avHWFrameCtxInit(uintptr(ref)); ret < 0 {
+		FreeBufferRef(&ref)
+		return nil, NewError(ret, "av_hwframe_ctx_init")
+	}
+	return ref, nil
+}
+
+// setHWFramesParams writes cfg into the AVHWFramesContext behind ref.
+func setHWFramesParams(ref unsafe.Pointer, cfg HWFramesConfig) {
+	_ = ffshim.Load()
+	if err := ffshim.HWFramesCtxSetParams(ref, int32(cfg.Format), int32(cfg.SWFormat),
+		int32(cfg.Width), int32(cfg.Height), int32(cfg.InitialPoolSize)); err == nil {
+		return
+	}
+
+	fields := hwFramesFields(ref)
+	fields[0] = int32(cfg.InitialPoolSize)
+	fields[1] = int32(cfg.Format)
+	fields[2] = int32(cfg.SWFormat)
+	fields[3] = int32(cfg.Width)
+	fields[4] = int32(cfg.Height)
+}
+
+// GetHWFramesParams returns the parameters of a hardware frames context and
+// its AVHWDeviceContext (the data of the device's AVBufferRef), which can
+// be compared to tell whether two contexts live on the same device.
+func GetHWFramesParams(ref HWFramesContext) (cfg HWFramesConfig, device unsafe.Pointer) {
+	if ref == nil {
+		return HWFramesConfig{}, nil
+	}
+	_ = ffshim.Load()
+	if dev, format, swFormat, w, h, pool, err := ffshim.HWFramesCtxParams(ref); err == nil {
+		return HWFramesConfig{
+			Format:          PixelFormat(format),
+			SWFormat:        PixelFormat(swFormat),
+			Width:           int(w),
+			Height:          int(h),
+			InitialPoolSize: int(pool),
+		}, dev
+	}
+
+	fields := hwFramesFields(ref)
+	cfg = HWFramesConfig{
+		InitialPoolSize: int(fields[0]),
+		Format:          PixelFormat(fields[1]),
+		SWFormat:        PixelFormat(fields[2]),
+		Width:           int(fields[3]),
+		Height:          int(fields[4]),
+	}
+	// device_ctx follows device_ref, which is the first (FFmpeg 7) or
+	// second (FFmpeg 5-6) field after av_class.
+	fc := *(*unsafe.Pointer)(unsafe.Add(ref, 8))
+	devOff := uintptr(24)
+	if bindings.AVUtilVersion()>>16 >= 59 {
+		devOff = 16
+	}
+	return cfg, *(*unsafe.Pointer)(unsafe.Add(fc, devOff))
+}
+
+// hwFramesFields returns the initial_pool_size, format, sw_format, width
+// and height fields of the AVHWFramesContext behind ref. AVBufferRef.data
+// is the AVHWFramesContext; FFmpeg 7 (avutil 59) removed the internal
+// pointer that preceded device_ref, shifting the fields down by 8 bytes.
+func hwFramesFields(ref unsafe.Pointer) *[5]int32 {
+	fc := *(*unsafe.Pointer)(unsafe.Add(ref, 8))
+	off := uintptr(64)
+	if bindings.AVUtilVersion()>>16 >= 59 {
+		off = 56
+	}
+	return (*[5]int32)(unsafe.Add(fc, off))
+}
+
+// HWFrameGetBuffer allocates a hardware surface from the frames context
+// into frame (av_hwframe_get_buffer). frame should be freshly allocated or
+// unreferenced.
+func HWFrameGetBuffer(framesCtx HWFramesContext, frame Frame) error {
+	if avHWFrameGetBuffer == nil {
+		return bindings.ErrNotLoaded
+	}
+	if framesCtx == nil || frame == nil {
+		return NewError(-22, "av_hwframe_get_buffer: nil argument")
+	}
+	ret := avHWFrameGetBuffer(uintptr(framesCtx), uintptr(frame), 0)
+	if ret < 0 {
+		return NewError(ret, "av_hwframe_get_buffer")
+	}
+	return nil
+}
+
+// GetPixFmt returns the pixel format with the given FFmpeg name
+// (e.g., "yuv420p", "vaapi"), or PixelFormatNone if it is unknown.
+func GetPixFmt(name string) PixelFormat {
+	if avGetPixFmt == nil {
+		return PixelFormatNone
+	}
+	return PixelFormat(avGetPixFmt(name))
+}
+
+// GetPixFmtName returns the FFmpeg name of a pixel format (e.g., "yuv420p").
+// Returns an empty string if the format is unknown.
+func GetPixFmtName(pixFmt PixelFormat) string {
+	if avGetPixFmtName == nil {
+		return ""
+	}
+	ptr := unsafe.Pointer(avGetPixFmtName(int32(pixFmt)))
+	if ptr == nil {
+		return ""
+	}
+	return goString(ptr)
+}
+
+// Pixel format descriptor flags (AV_PIX_FMT_FLAG_*).
+const (
+	PixFmtFlagBE        = 1 << 0 // big-endian
+	PixFmtFlagPAL       = 1 << 1 // palette in data[1]
+	PixFmtFlagBitstream = 1 << 2 // packed bits, not byte-addressable
+	PixFmtFlagHWAccel   = 1 << 3 // hardware surface
+	PixFmtFlagPlanar    = 1 << 4 // at least one component in its own plane
+	PixFmtFlagRGB       = 1 << 5 // RGB-like
+	PixFmtFlagAlpha     = 1 << 7 // has an alpha channel
+)
+
+// ComponentDescriptor mirrors AVComponentDescriptor: where one color
+// component of a pixel format lives in memory.
+type ComponentDescriptor struct {
+	Plane  int32 // plane holding the component
+	Step   int32 // bytes (bits for bitstream formats) between horizontally adjacent pixels
+	Offset int32 // bytes (bits) before the component's first pixel
+	Shift  int32 // least significant bit holding the component
+	Depth  int32 // bits per component
+}
+
+// PixFmtDescriptor mirrors the layout fields of AVPixFmtDescriptor.
+type PixFmtDescriptor struct {
+	NbComponents int
+	Log2ChromaW  int // chroma planes are width >> Log2ChromaW wide
+	Log2ChromaH  int // chroma planes are height >> Log2ChromaH high
+	Flags        uint64
+	Comp         [4]ComponentDescriptor
+}
+
+// avPixFmtDescriptor is the C layout of the leading AVPixFmtDescriptor fields.
+type avPixFmtDescriptor struct {
+	name         uintptr
+	nbComponents uint8
+	log2ChromaW  uint8
+	log2ChromaH  uint8
+	flags        uint64
+	comp         [4]ComponentDescriptor
+}
+
+// GetPixFmtDescriptor returns the layout of a pixel format, or nil if the
+// format is unknown.
+func GetPixFmtDescriptor(pixFmt PixelFormat) *PixFmtDescriptor {
+	if avPixFmtDescGet == nil {
+		return nil
+	}
+	p := avPixFmtDescGet(int32(pixFmt))
+	if p == 0 {
+		return nil
+	}
+	d := (*avPixFmtDescriptor)(unsafe.Pointer(p))
+	return &PixFmtDescriptor{
+		NbComponents: int(d.nbComponents),
+		Log2ChromaW:  int(d.log2ChromaW),
+		Log2ChromaH:  int(d.log2ChromaH),
+		Flags:        d.flags,
+		Comp:         d.comp,
+	}
+}
+
+// ImageFillBlack wraps av_image_fill_black, filling a width x height image
+// with black. colorRange is an AVColorRange (0 or 1 for limited, 2 for
+// full range).
+func ImageFillBlack(data [4]unsafe.Pointer, linesize [4]int64, pixFmt PixelFormat, colorRange int32, width, height int) error {
+	if avImageFillBlack == nil {
+		return errors.New("ffgo: av_image_fill_black not available")
+	}
+	if ret := avImageFillBlack(&data, &linesize, int32(pixFmt), colorRange, int32(width), int32(height)); ret < 0 {
+		return NewError(ret, "av_image_fill_black")
+	}
+	return nil
+}
+
 // BufferCreate wraps av_buffer_create.
 //
 // freeCb is a purego callback pointer for: void free(void *opaque, uint8_t *data).
@@ -188,3 +188,63 @@ func TestErrorString(t *testing.T) {
 		t.Error("ErrorString should return non-empty string for unknown error")
 	}
 }
+
+func TestPixelFormatValues(t *testing.T) {
+	formats := map[string]PixelFormat{
+		"yuv420p":      PixelFormatYUV420P,
+		"yuyv422":      PixelFormatYUYV422,
+		"rgb24":        PixelFormatRGB24,
+		"bgr24":        PixelFormatBGR24,
+		"yuv422p":      PixelFormatYUV422P,
+		"yuv444p":      PixelFormatYUV444P,
+		"yuv410p":      PixelFormatYUV410P,
+		"yuv411p":      PixelFormatYUV411P,
+		"gray":         PixelFormatGray8,
+		"monow":        PixelFormatMonoW,
+		"monob":        PixelFormatMonoB,
+		"pal8":         PixelFormatPAL8,
+		"yuvj420p":     PixelFormatYUVJ420P,
+		"yuvj422p":     PixelFormatYUVJ422P,
+		"yuvj444p":     PixelFormatYUVJ444P,
+		"nv12":         PixelFormatNV12,
+		"nv21":         PixelFormatNV21,
+		"argb":         PixelFormatARGB,
+		"rgba":         PixelFormatRGBA,
+		"abgr":         PixelFormatABGR,
+		"bgra":         PixelFormatBGRA,
+		"gray16be":     PixelFormatGray16BE,
+		"gray16le":     PixelFormatGray16LE,
+		"rgb48be":      PixelFormatRGB48BE,
+		"rgb48le":      PixelFormatRGB48LE,
+		"yuv420p10le":  PixelFormatYUV420P10LE,
+		"yuv422p10le":  PixelFormatYUV422P10LE,
+		"yuv444p10le":  PixelFormatYUV444P10LE,
+		"gbrp":         PixelFormatGBRP,
+		"gbrp10le":     PixelFormatGBRP10LE,
+		"gbrp16le":     PixelFormatGBRP16LE,
+		"yuva444p10le": PixelFormatYUVA444P10LE,
+		"rgba64be":     PixelFormatRGBA64BE,
+		"rgba64le":     PixelFormatRGBA64LE,
+		"yuv420p12le":  PixelFormatYUV420P12LE,
+		"yuv422p12le":  PixelFormatYUV422P12LE,
+		"yuv444p12le":  PixelFormatYUV444P12LE,
+		"gbrp12le":     PixelFormatGBRP12LE,
+	}
+
+	seen := make(map[PixelFormat]string)
+	for name, f := range formats {
+		if other, ok := seen[f]; ok {
+			t.Errorf("%s and %s share value %d", name, other, f)
+		}
+		seen[f] = name
+	}
+
+	if !requireFFmpeg(t) {
+		return
+	}
+	for name, f := range formats {
+		if got := GetPixFmt(name); got != f {
+			t.Errorf("av_get_pix_fmt(%q) = %d, constant is %d", name, got, f)
+		}
+	}
+}
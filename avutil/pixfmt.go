@@ -33,10 +33,23 @@ const (
 	PixelFormatGray16BE PixelFormat = 29 // 16-bit grayscale (big endian)
 	PixelFormatGray16LE PixelFormat = 30 // 16-bit grayscale (little endian)
 
-	PixelFormatRGB48BE PixelFormat = 41 // Packed RGB 16:16:16 (big endian)
-	PixelFormatRGB48LE PixelFormat = 42 // Packed RGB 16:16:16 (little endian)
-	PixelFormatRGBA64BE PixelFormat = 63 // Packed RGBA 16:16:16:16 (big endian)
-	PixelFormatRGBA64LE PixelFormat = 64 // Packed RGBA 16:16:16:16 (little endian)
+	PixelFormatRGB48BE PixelFormat = 34 // Packed RGB 16:16:16 (big endian)
+	PixelFormatRGB48LE PixelFormat = 35 // Packed RGB 16:16:16 (little endian)
+
+	// High bit depth formats (FFmpeg 5.x+ numbering)
+	PixelFormatYUV420P10LE  PixelFormat = 62  // Planar YUV 4:2:0, 10-bit (little endian)
+	PixelFormatYUV422P10LE  PixelFormat = 64  // Planar YUV 4:2:2, 10-bit (little endian)
+	PixelFormatYUV444P10LE  PixelFormat = 68  // Planar YUV 4:4:4, 10-bit (little endian)
+	PixelFormatGBRP         PixelFormat = 71  // Planar GBR 8:8:8
+	PixelFormatGBRP10LE     PixelFormat = 75  // Planar GBR 4:4:4, 10-bit (little endian)
+	PixelFormatGBRP16LE     PixelFormat = 77  // Planar GBR 4:4:4, 16-bit (little endian)
+	PixelFormatYUVA444P10LE PixelFormat = 91  // Planar YUVA 4:4:4:4, 10-bit (little endian)
+	PixelFormatRGBA64BE     PixelFormat = 104 // Packed RGBA 16:16:16:16 (big endian)
+	PixelFormatRGBA64LE     PixelFormat = 105 // Packed RGBA 16:16:16:16 (little endian)
+	PixelFormatYUV420P12LE  PixelFormat = 123 // Planar YUV 4:2:0, 12-bit (little endian)
+	PixelFormatYUV422P12LE  PixelFormat = 127 // Planar YUV 4:2:2, 12-bit (little endian)
+	PixelFormatYUV444P12LE  PixelFormat = 131 // Planar YUV 4:4:4, 12-bit (little endian)
+	PixelFormatGBRP12LE     PixelFormat = 135 // Planar GBR 4:4:4, 12-bit (little endian)
 )
 
 // MediaType represents FFmpeg media types.
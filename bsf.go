@@ -34,6 +34,8 @@ var (
 	avBsfSendPacket    func(ctx, pkt uintptr) int32
 	avBsfReceivePacket func(ctx, pkt uintptr) int32
 
+	avPacketGetSideData func(pkt uintptr, typ int32, size *uint64) uintptr
+
 	bsfBindingsRegistered bool
 )
 
@@ -57,6 +59,7 @@ func registerBSFBindings() {
 	purego.RegisterLibFunc(&avBsfFree, lib, "av_bsf_free")
 	purego.RegisterLibFunc(&avBsfSendPacket, lib, "av_bsf_send_packet")
 	purego.RegisterLibFunc(&avBsfReceivePacket, lib, "av_bsf_receive_packet")
+	purego.RegisterLibFunc(&avPacketGetSideData, lib, "av_packet_get_side_data")
 
 	bsfBindingsRegistered = true
 }
@@ -183,6 +186,99 @@ func (f *BitstreamFilter) Init() error {
 	return nil
 }
 
+// InitFromParameters sets the input codec parameters and time base, then
+// initializes the filter. It is the usual way to set up a filter for the
+// packets of one stream, e.g. from StreamInfo.CodecParameters() and
+// StreamInfo.TimeBase.
+func (f *BitstreamFilter) InitFromParameters(params avcodec.Parameters, timeBase Rational) error {
+	if params == nil {
+		return errors.New("ffgo: codec parameters are required")
+	}
+	if err := f.SetInputCodecParameters(params); err != nil {
+		return err
+	}
+	f.SetInputTimeBase(timeBase.Num, timeBase.Den)
+	return f.Init()
+}
+
+// SendPacket submits a packet to the filter. The filter takes over the
+// packet's data reference and leaves pkt blank; clone it first if it is
+// still needed. A nil packet signals the end of input so the filter can
+// flush buffered data.
+//
+// An error for which IsAgain is true means filtered packets must be
+// drained with ReceivePacket before more input is accepted.
+func (f *BitstreamFilter) SendPacket(pkt *Packet) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.closed || f.ctx == nil {
+		return errors.New("ffgo: filter is closed")
+	}
+
+	var raw uintptr
+	if pkt != nil {
+		if pkt.ptr == nil {
+			return errors.New("ffgo: packet is nil")
+		}
+		raw = uintptr(pkt.ptr)
+	}
+	if ret := avBsfSendPacket(uintptr(f.ctx), raw); ret < 0 {
+		return avutil.NewError(ret, "av_bsf_send_packet")
+	}
+	return nil
+}
+
+// ReceivePacket returns the next filtered packet. The packet is owned by
+// the caller and must be freed.
+//
+// When the filter needs more input it returns an error for which IsAgain is
+// true; once the input has been flushed (SendPacket(nil)) and fully drained
+// it returns an error for which IsEOF is true.
+func (f *BitstreamFilter) ReceivePacket() (*Packet, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.closed || f.ctx == nil {
+		return nil, errors.New("ffgo: filter is closed")
+	}
+
+	out := PacketAlloc()
+	if out.ptr == nil {
+		return nil, errors.New("ffgo: failed to allocate packet")
+	}
+	if ret := avBsfReceivePacket(uintptr(f.ctx), uintptr(out.ptr)); ret < 0 {
+		_ = out.Free()
+		return nil, avutil.NewError(ret, "av_bsf_receive_packet")
+	}
+	return out, nil
+}
+
+// Apply sends in through the filter and returns every packet the filter
+// produces for it, which may be none (the filter is buffering) or several.
+// The returned packets are owned by the caller and must be freed. Pass nil
+// after the last packet to flush the filter.
+func (f *BitstreamFilter) Apply(in *Packet) ([]*Packet, error) {
+	if err := f.SendPacket(in); err != nil {
+		return nil, err
+	}
+
+	var out []*Packet
+	for {
+		pkt, err := f.ReceivePacket()
+		if err != nil {
+			if code := avutil.Code(err); isEAGAIN(code) || isEOF(code) {
+				return out, nil
+			}
+			for _, p := range out {
+				_ = p.Free()
+			}
+			return nil, err
+		}
+		out = append(out, pkt)
+	}
+}
+
 // Filter sends a packet through the filter and receives the filtered packet.
 // The input packet's data is consumed. Returns the filtered packet or error.
 // Returns nil, nil if more input is needed (EAGAIN).
@@ -4,6 +4,7 @@ package ffgo
 
 import (
 	"errors"
+	"fmt"
 	"time"
 	"unsafe"
 
@@ -21,8 +22,10 @@ type Chapter struct {
 	Metadata Metadata      // Full chapter metadata
 }
 
-// GetChapters returns all chapters from the media file.
-func (d *Decoder) GetChapters() []Chapter {
+// Chapters returns all chapters from the media file, in container order.
+// Start and End are converted from each chapter's time base.
+// Returns nil if the input has no chapters.
+func (d *Decoder) Chapters() []Chapter {
 	d.mu.Lock()
 	defer d.mu.Unlock()
 
@@ -31,7 +34,7 @@ func (d *Decoder) GetChapters() []Chapter {
 	}
 
 	numChapters := avformat.GetNumChapters(d.formatCtx)
-	if numChapters == 0 {
+	if numChapters <= 0 {
 		return nil
 	}
 
@@ -43,19 +46,19 @@ func (d *Decoder) GetChapters() []Chapter {
 			continue
 		}
 
-		// Get time base for conversion
+		// Get time base for conversion; a non-positive time base means the
+		// chapter could not be read reliably.
 		tbNum, tbDen := avformat.GetChapterTimeBase(ch)
-		if tbDen == 0 {
-			tbDen = 1
+		if tbNum <= 0 || tbDen <= 0 {
+			continue
 		}
+		tb := avutil.NewRational(tbNum, tbDen)
 
-		// Convert start and end to time.Duration
-		startPTS := avformat.GetChapterStart(ch)
-		endPTS := avformat.GetChapterEnd(ch)
-
-		// Convert to microseconds: pts * num / den * 1000000
-		startUS := startPTS * int64(tbNum) * 1000000 / int64(tbDen)
-		endUS := endPTS * int64(tbNum) * 1000000 / int64(tbDen)
+		start := chapterTime(avformat.GetChapterStart(ch), tb)
+		end := chapterTime(avformat.GetChapterEnd(ch), tb)
+		if end < start {
+			end = start
+		}
 
 		// Get metadata
 		meta := getChapterMetadata(ch)
@@ -68,8 +71,8 @@ func (d *Decoder) GetChapters() []Chapter {
 
 		chapters = append(chapters, Chapter{
 			ID:       avformat.GetChapterID(ch),
-			Start:    time.Duration(startUS) * time.Microsecond,
-			End:      time.Duration(endUS) * time.Microsecond,
+			Start:    start,
+			End:      end,
 			Title:    title,
 			Metadata: meta,
 		})
@@ -78,6 +81,22 @@ func (d *Decoder) GetChapters() []Chapter {
 	return chapters
 }
 
+// GetChapters is an alias for Chapters.
+func (d *Decoder) GetChapters() []Chapter {
+	return d.Chapters()
+}
+
+// chapterTime converts a chapter timestamp to a duration without
+// overflowing for fine-grained time bases (e.g. 1/1000000000).
+func chapterTime(pts int64, tb Rational) time.Duration {
+	if pts == avutil.AV_NOPTS_VALUE || pts < 0 {
+		return 0
+	}
+	secs := pts / int64(tb.Den) * int64(tb.Num)
+	rem := pts % int64(tb.Den) * int64(tb.Num)
+	return time.Duration(secs)*time.Second + time.Duration(rem*int64(time.Second)/int64(tb.Den))
+}
+
 // getChapterMetadata extracts metadata from a chapter as a Metadata map.
 func getChapterMetadata(ch avformat.Chapter) Metadata {
 	dict := avformat.GetChapterMetadata(ch)
@@ -105,6 +124,11 @@ func getChapterMetadata(ch avformat.Chapter) Metadata {
 
 // SetChapters sets chapters for the output file.
 // Must be called before WriteHeader.
+//
+// Chapters are written with a 1/1000 time base; Title is stored as the
+// "title" metadata entry alongside any extra Metadata. A zero ID is replaced
+// by the chapter's position in the slice. Chapter writing requires the ffshim
+// helper library.
 func (e *Encoder) SetChapters(chapters []Chapter) error {
 	e.mu.Lock()
 	defer e.mu.Unlock()
@@ -119,9 +143,9 @@ func (e *Encoder) SetChapters(chapters []Chapter) error {
 		return errors.New("ffgo: encoder not initialized")
 	}
 
-	// Check if shim is loaded (required for chapter writing)
-	if !shim.IsLoaded() {
-		return errors.New("ffgo: shim not loaded, chapter writing not available")
+	// The shim is required to append AVChapters to the output context.
+	if err := shim.Load(); err != nil {
+		return fmt.Errorf("ffgo: chapter writing requires the ffshim library: %w", err)
 	}
 
 	// Use millisecond time base for chapters (1/1000)
@@ -129,6 +153,10 @@ func (e *Encoder) SetChapters(chapters []Chapter) error {
 	const tbDen int32 = 1000
 
 	for i, ch := range chapters {
+		if ch.Start < 0 || ch.End < ch.Start {
+			return fmt.Errorf("ffgo: chapter %d has invalid range %v-%v", i, ch.Start, ch.End)
+		}
+
 		// Convert time.Duration to PTS in milliseconds
 		startPTS := int64(ch.Start / time.Millisecond)
 		endPTS := int64(ch.End / time.Millisecond)
@@ -139,15 +167,21 @@ func (e *Encoder) SetChapters(chapters []Chapter) error {
 			id = int64(i)
 		}
 
-		// Create metadata dictionary for title (errors are non-fatal for metadata)
+		// Build the metadata dictionary; the chapter takes ownership of it.
 		var metadata avutil.Dictionary
+		for k, v := range ch.Metadata {
+			if k == "title" && ch.Title != "" {
+				continue // Title field takes precedence
+			}
+			if err := avutil.DictSet(&metadata, k, v, 0); err != nil {
+				avutil.DictFree(&metadata)
+				return err
+			}
+		}
 		if ch.Title != "" {
-			_ = avutil.DictSet(&metadata, "title", ch.Title, 0)
-			// Add any additional metadata
-			for k, v := range ch.Metadata {
-				if k != "title" { // Don't duplicate title
-					_ = avutil.DictSet(&metadata, k, v, 0)
-				}
+			if err := avutil.DictSet(&metadata, "title", ch.Title, 0); err != nil {
+				avutil.DictFree(&metadata)
+				return err
 			}
 		}
 
@@ -160,6 +194,9 @@ func (e *Encoder) SetChapters(chapters []Chapter) error {
 			metadata,
 		)
 		if err != nil {
+			if metadata != nil {
+				avutil.DictFree(&metadata)
+			}
 			return err
 		}
 	}
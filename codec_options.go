@@ -56,6 +56,16 @@ const (
 	ProfileHEVCMain10 VideoProfile = "main10" // Main 10-bit profile
 )
 
+// Apple ProRes profiles (prores_ks/prores_aw)
+const (
+	ProfileProResProxy    VideoProfile = "proxy"    // ProRes 422 Proxy
+	ProfileProResLT       VideoProfile = "lt"       // ProRes 422 LT
+	ProfileProResStandard VideoProfile = "standard" // ProRes 422
+	ProfileProResHQ       VideoProfile = "hq"       // ProRes 422 HQ
+	ProfileProRes4444     VideoProfile = "4444"     // ProRes 4444 (4:4:4, optional alpha)
+	ProfileProRes4444XQ   VideoProfile = "4444xq"   // ProRes 4444 XQ
+)
+
 // VideoLevel specifies the H.264/H.265 level.
 // Higher levels support higher resolution and bitrates.
 type VideoLevel string
@@ -93,6 +103,8 @@ const (
 
 	// RateControlCBR uses Constant Bit Rate.
 	// Good for streaming where consistent bitrate is required.
+	// minrate and maxrate are pinned to Bitrate and a VBV buffer is enforced
+	// (BufferSize, default Bitrate/2); x264 also signals CBR HRD.
 	RateControlCBR
 
 	// RateControlCRF uses Constant Rate Factor (quality-based).
@@ -0,0 +1,55 @@
+//go:build !ios && !android && (amd64 || arm64)
+
+package ffgo
+
+import (
+	"github.com/obinnaokechukwu/ffgo/avcodec"
+)
+
+// CodecInfo describes an encoder or decoder available in the loaded FFmpeg build.
+type CodecInfo struct {
+	Name       string    // Codec name as accepted by FindEncoderByName/FindDecoderByName (e.g. "libx264")
+	LongName   string    // Human-readable description
+	ID         CodecID   // Codec ID implemented (several codecs may share one ID)
+	MediaType  MediaType // Video, audio, subtitle, ...
+	IsHardware bool      // Backed (or possibly backed) by a hardware implementation
+}
+
+// ListEncoders returns the encoders available in the loaded FFmpeg build, in
+// FFmpeg's registration order. Returns nil if FFmpeg is not loaded or the
+// build does not support codec enumeration.
+func ListEncoders() []CodecInfo {
+	return listCodecs(avcodec.IsEncoder)
+}
+
+// ListDecoders returns the decoders available in the loaded FFmpeg build, in
+// FFmpeg's registration order. Returns nil if FFmpeg is not loaded or the
+// build does not support codec enumeration.
+func ListDecoders() []CodecInfo {
+	return listCodecs(avcodec.IsDecoder)
+}
+
+// ParseCodecID resolves a user-supplied codec name such as "hevc", "vp9" or
+// "libx264" to a CodecID. Encoder and decoder names are resolved through the
+// loaded FFmpeg build, so the result reflects what the build knows about.
+func ParseCodecID(name string) (CodecID, bool) {
+	return avcodec.ParseCodecID(name)
+}
+
+func listCodecs(match func(avcodec.Codec) bool) []CodecInfo {
+	var out []CodecInfo
+	for _, c := range avcodec.Codecs() {
+		if !match(c) {
+			continue
+		}
+		caps := avcodec.GetCodecCapabilities(c)
+		out = append(out, CodecInfo{
+			Name:       avcodec.GetCodecName(c),
+			LongName:   avcodec.GetCodecLongName(c),
+			ID:         avcodec.GetCodecID(c),
+			MediaType:  avcodec.GetCodecType(c),
+			IsHardware: caps&(avcodec.CodecCapHardware|avcodec.CodecCapHybrid) != 0,
+		})
+	}
+	return out
+}
@@ -3,8 +3,10 @@
 package ffgo
 
 import (
+	"os/exec"
 	"path/filepath"
 	"testing"
+	"time"
 
 	"github.com/obinnaokechukwu/ffgo/avutil"
 )
@@ -107,3 +109,171 @@ func countVideoFrames(d *Decoder) (int, error) {
 		n++
 	}
 }
+
+func TestFrameConcatenator(t *testing.T) {
+	if !requireFFmpeg(t) {
+		return
+	}
+
+	in := filepath.Join("testdata", "test.mp4")
+
+	base, err := NewDecoder(in)
+	if err != nil {
+		t.Fatalf("NewDecoder failed: %v", err)
+	}
+	count1, err := countVideoFrames(base)
+	base.Close()
+	if err != nil {
+		t.Fatalf("countVideoFrames failed: %v", err)
+	}
+
+	cat, err := NewFrameConcatenator(VideoFormat{Width: 96, Height: 64, FrameRate: NewRational(25, 1)}, AudioFormat{})
+	if err != nil {
+		t.Fatalf("NewFrameConcatenator failed: %v", err)
+	}
+	defer cat.Close()
+
+	for i := 0; i < 2; i++ {
+		d, err := NewDecoder(in)
+		if err != nil {
+			t.Fatalf("NewDecoder failed: %v", err)
+		}
+		defer d.Close()
+		if err := cat.AddClip(d); err != nil {
+			t.Fatalf("AddClip failed: %v", err)
+		}
+	}
+
+	frames := 0
+	lastPTS := int64(-1)
+	for {
+		frame, err := cat.Next()
+		if err != nil {
+			t.Fatalf("Next failed: %v", err)
+		}
+		if frame.IsNil() {
+			break
+		}
+		if cat.MediaType() != MediaTypeVideo {
+			t.Fatalf("unexpected media type %v with audio disabled", cat.MediaType())
+		}
+		info := GetFrameInfo(frame)
+		if info.Width != 96 || info.Height != 64 {
+			t.Fatalf("frame %d is %dx%d, want 96x64", frames, info.Width, info.Height)
+		}
+		if pts := info.PTS; pts <= lastPTS {
+			t.Fatalf("frame %d PTS %d not after %d", frames, pts, lastPTS)
+		} else {
+			lastPTS = pts
+		}
+		frames++
+	}
+
+	if frames != 2*count1 {
+		t.Errorf("got %d frames, want %d", frames, 2*count1)
+	}
+
+	if _, err := NewFrameConcatenator(VideoFormat{}, AudioFormat{}); err == nil {
+		t.Error("expected error for zero video target")
+	}
+	if _, err := NewFrameConcatenator(VideoFormat{Width: 96, Height: 64}, AudioFormat{Channels: 2}); err == nil {
+		t.Error("expected error for audio target without sample rate")
+	}
+}
+
+func TestFrameConcatenatorCrossfade(t *testing.T) {
+	if !requireFFmpeg(t) {
+		return
+	}
+
+	// Two one-second clips, red with a tone and blue with silence, stored
+	// losslessly so the colors survive.
+	tmpDir := t.TempDir()
+	clips := map[string][2]string{
+		"red.mkv":  {"color=red:size=64x48:rate=25:duration=1", "sine=frequency=440:sample_rate=48000:duration=1"},
+		"blue.mkv": {"color=blue:size=64x48:rate=25:duration=1", "anullsrc=sample_rate=48000:channel_layout=mono:duration=1"},
+	}
+	for name, src := range clips {
+		if err := exec.Command("ffmpeg", "-y",
+			"-f", "lavfi", "-i", src[0], "-f", "lavfi", "-i", src[1],
+			"-c:v", "ffv1", "-c:a", "pcm_s16le", "-ac", "1",
+			filepath.Join(tmpDir, name)).Run(); err != nil {
+			t.Skipf("ffmpeg CLI not available: %v", err)
+		}
+	}
+
+	cat, err := NewFrameConcatenator(
+		VideoFormat{Width: 64, Height: 48, PixelFormat: PixelFormatRGB24, FrameRate: NewRational(25, 1)},
+		AudioFormat{SampleRate: 48000, Channels: 1, SampleFormat: SampleFormatS16},
+	)
+	if err != nil {
+		t.Fatalf("NewFrameConcatenator failed: %v", err)
+	}
+	defer cat.Close()
+	if err := cat.SetCrossfade(-time.Second); err == nil {
+		t.Error("expected error for negative crossfade")
+	}
+	if err := cat.SetCrossfade(400 * time.Millisecond); err != nil {
+		t.Fatalf("SetCrossfade failed: %v", err)
+	}
+
+	for _, name := range []string{"red.mkv", "blue.mkv"} {
+		d, err := NewDecoder(filepath.Join(tmpDir, name))
+		if err != nil {
+			t.Fatalf("NewDecoder failed: %v", err)
+		}
+		defer d.Close()
+		if err := cat.AddClip(d); err != nil {
+			t.Fatalf("AddClip failed: %v", err)
+		}
+	}
+
+	// Red and blue level of the top-left pixel of each video frame by PTS.
+	type rgb struct{ r, b byte }
+	colors := map[int64]rgb{}
+	nextVideo, nextAudio := int64(0), int64(0)
+	for {
+		frame, err := cat.Next()
+		if err != nil {
+			t.Fatalf("Next failed: %v", err)
+		}
+		if frame.IsNil() {
+			break
+		}
+		pts := avutil.GetFramePTS(frame.ptr)
+		switch cat.MediaType() {
+		case MediaTypeVideo:
+			if pts != nextVideo {
+				t.Fatalf("video PTS %d, want %d", pts, nextVideo)
+			}
+			pix := WrapFrame(frame, MediaTypeVideo).Data(0)
+			colors[pts] = rgb{pix[0], pix[2]}
+			nextVideo++
+		case MediaTypeAudio:
+			if pts != nextAudio {
+				t.Fatalf("audio PTS %d, want %d", pts, nextAudio)
+			}
+			nextAudio += int64(avutil.GetFrameNbSamples(frame.ptr))
+		}
+		if err := cat.SetCrossfade(0); err == nil {
+			t.Fatal("SetCrossfade after Next should fail")
+		}
+	}
+
+	// 25 + 25 frames overlapping by 10, and 1s + 1s of audio by 0.4s.
+	if nextVideo != 40 {
+		t.Errorf("got %d video frames, want 40", nextVideo)
+	}
+	if nextAudio != 76800 {
+		t.Errorf("got %d audio samples, want 76800", nextAudio)
+	}
+	if c := colors[10]; c.r < 200 || c.b > 50 {
+		t.Errorf("frame 10 = %+v, want red", c)
+	}
+	if c := colors[20]; c.r < 60 || c.r > 200 || c.b < 60 || c.b > 200 {
+		t.Errorf("frame 20 = %+v, want a red/blue mix", c)
+	}
+	if c := colors[30]; c.r > 50 || c.b < 200 {
+		t.Errorf("frame 30 = %+v, want blue", c)
+	}
+}
@@ -0,0 +1,227 @@
+//go:build !ios && !android && (amd64 || arm64)
+
+package ffgo
+
+import (
+	"errors"
+	"fmt"
+	"time"
+	"unsafe"
+
+	"github.com/obinnaokechukwu/ffgo/avutil"
+)
+
+// ContactSheetOptions configures GenerateContactSheetWithOptions.
+type ContactSheetOptions struct {
+	// Cols and Rows give the grid size; Cols*Rows frames are extracted.
+	Cols, Rows int
+
+	// ThumbWidth is the width of each tile in pixels. The tile height
+	// follows the source aspect ratio.
+	ThumbWidth int
+
+	// Timestamps draws each tile's position in the video (HH:MM:SS) in its
+	// bottom-left corner, using FFmpeg's drawtext filter.
+	Timestamps bool
+
+	// FontFile is the font used for Timestamps. If empty, drawtext's
+	// fontconfig default is used, which requires an FFmpeg built with
+	// fontconfig.
+	FontFile string
+}
+
+// GenerateContactSheet writes a single image of cols*rows thumbnails, evenly
+// spaced over input's duration and tiled left to right, top to bottom, to
+// outPath (png, jpg or bmp, as for SaveFrame). It is typically used as a
+// sprite sheet for video scrubbing previews.
+func GenerateContactSheet(input string, cols, rows int, thumbWidth int, outPath string) error {
+	return GenerateContactSheetWithOptions(input, outPath, ContactSheetOptions{
+		Cols:       cols,
+		Rows:       rows,
+		ThumbWidth: thumbWidth,
+	})
+}
+
+// GenerateContactSheetWithOptions is GenerateContactSheet with additional
+// options such as timestamp labels.
+func GenerateContactSheetWithOptions(input, outPath string, opts ContactSheetOptions) error {
+	if opts.Cols <= 0 || opts.Rows <= 0 {
+		return errors.New("ffgo: contact sheet needs at least one column and row")
+	}
+	if opts.ThumbWidth <= 0 {
+		return errors.New("ffgo: thumbnail width must be positive")
+	}
+
+	dec, err := NewDecoder(input)
+	if err != nil {
+		return err
+	}
+	defer dec.Close()
+	if err := dec.OpenVideoDecoder(); err != nil {
+		return err
+	}
+	info := dec.VideoStream()
+	if info == nil {
+		return ErrNoVideoStream
+	}
+	if info.Width <= 0 || info.Height <= 0 {
+		return errors.New("ffgo: video stream has invalid dimensions")
+	}
+	duration := dec.Duration()
+	if duration <= 0 {
+		return errors.New("ffgo: cannot determine duration")
+	}
+
+	thumbW := opts.ThumbWidth
+	thumbH := max(2, (thumbW*info.Height/info.Width+1)&^1)
+
+	sheet, err := newVideoFrame(thumbW*opts.Cols, thumbH*opts.Rows, PixelFormatRGBA)
+	if err != nil {
+		return err
+	}
+	defer FrameFree(&sheet)
+	fillOpaqueBlack(sheet)
+
+	times := thumbnailTimes(duration, opts.Cols*opts.Rows)
+
+	// One drawtext graph labels every tile; its text is changed per tile.
+	var labels *FilterGraph
+	if opts.Timestamps {
+		labels, err = NewVideoFilterGraph(timestampFilter(times[0], thumbH, opts.FontFile), thumbW, thumbH, PixelFormatRGBA)
+		if err != nil {
+			return err
+		}
+		defer labels.Close()
+	}
+
+	for i, ts := range times {
+		frame, err := dec.ExtractThumbnail(ts)
+		if err != nil {
+			return err
+		}
+		if frame.IsNil() {
+			break // ran out of frames; leave the remaining tiles black
+		}
+		thumb, err := contactSheetTile(frame, thumbW, thumbH)
+		_ = FrameFree(&frame)
+		if err == nil && labels != nil {
+			thumb, err = labelTile(labels, thumb, i, ts)
+		}
+		if err != nil {
+			return err
+		}
+		copyRGBA(sheet, thumb, (i%opts.Cols)*thumbW, (i/opts.Cols)*thumbH, thumbW, thumbH)
+		_ = FrameFree(&thumb)
+	}
+
+	return SaveFrame(sheet, outPath)
+}
+
+// contactSheetTile scales frame to a w x h RGBA tile.
+func contactSheetTile(frame Frame, w, h int) (Frame, error) {
+	scaler, err := NewScaler(int(avutil.GetFrameWidth(frame.ptr)), int(avutil.GetFrameHeight(frame.ptr)),
+		PixelFormat(avutil.GetFrameFormat(frame.ptr)), w, h, PixelFormatRGBA, ScaleBilinear)
+	if err != nil {
+		return Frame{}, err
+	}
+	defer scaler.Close()
+
+	tile, err := newVideoFrame(w, h, PixelFormatRGBA)
+	if err != nil {
+		return Frame{}, err
+	}
+	if err := scaler.ScaleTo(tile, frame); err != nil {
+		_ = FrameFree(&tile)
+		return Frame{}, err
+	}
+	return tile, nil
+}
+
+// labelTile draws ts on tile through graph, a timestampFilter graph, and
+// frees tile. n is the tile's index, used as its PTS so timestamps increase
+// through the graph.
+func labelTile(graph *FilterGraph, tile Frame, n int, ts time.Duration) (Frame, error) {
+	if err := graph.SendCommand("drawtext", "reinit", "text="+timestampText(ts)); err != nil {
+		_ = FrameFree(&tile)
+		return Frame{}, err
+	}
+	avutil.SetFramePTS(tile.ptr, int64(n))
+	out, err := graph.Filter(&tile)
+	_ = FrameFree(&tile)
+	if err != nil || len(out) == 0 {
+		for i := range out {
+			_ = out[i].Free()
+		}
+		if err == nil {
+			err = errors.New("ffgo: drawtext produced no frame")
+		}
+		return Frame{}, err
+	}
+	for i := range out[1:] {
+		_ = out[i+1].Free()
+	}
+	return out[0], nil
+}
+
+// timestampFilter returns a drawtext filter that writes ts in the
+// bottom-left corner of a tile h pixels high.
+func timestampFilter(ts time.Duration, h int, fontFile string) string {
+	f := fmt.Sprintf("drawtext=text=%s:x=4:y=h-th-4:fontsize=%d:fontcolor=white:box=1:boxcolor=black@0.5",
+		timestampText(ts), max(10, h/8))
+	if fontFile != "" {
+		f += ":fontfile=" + escapeFilterPath(fontFile)
+	}
+	// Pin the output to RGBA so the tile can be copied into the sheet.
+	return f + ",format=rgba"
+}
+
+// timestampText formats ts as HH:MM:SS with the colons escaped for a
+// drawtext option value.
+func timestampText(ts time.Duration) string {
+	s := int64(ts / time.Second)
+	return fmt.Sprintf("%02d\\:%02d\\:%02d", s/3600, s/60%60, s%60)
+}
+
+// newVideoFrame allocates an owned frame with buffers for the given size and
+// pixel format.
+func newVideoFrame(width, height int, pixFmt PixelFormat) (Frame, error) {
+	f := FrameAlloc()
+	if f.IsNil() {
+		return Frame{}, errors.New("ffgo: failed to allocate frame")
+	}
+	avutil.SetFrameWidth(f.ptr, int32(width))
+	avutil.SetFrameHeight(f.ptr, int32(height))
+	avutil.SetFrameFormat(f.ptr, int32(pixFmt))
+	if err := avutil.FrameGetBufferErr(f.ptr, 0); err != nil {
+		_ = FrameFree(&f)
+		return Frame{}, err
+	}
+	return f, nil
+}
+
+// fillOpaqueBlack sets every pixel of an RGBA frame to opaque black.
+func fillOpaqueBlack(f Frame) {
+	w := int(avutil.GetFrameWidth(f.ptr))
+	h := int(avutil.GetFrameHeight(f.ptr))
+	stride := int(avutil.GetFrameLinesizePlane(f.ptr, 0))
+	pix := unsafe.Slice((*byte)(avutil.GetFrameDataPlane(f.ptr, 0)), stride*h)
+	for y := 0; y < h; y++ {
+		row := pix[y*stride : y*stride+w*4]
+		for x := 0; x < len(row); x += 4 {
+			row[x], row[x+1], row[x+2], row[x+3] = 0, 0, 0, 255
+		}
+	}
+}
+
+// copyRGBA copies the top-left w x h pixels of the RGBA frame src into the
+// RGBA frame dst at (x, y).
+func copyRGBA(dst, src Frame, x, y, w, h int) {
+	dstStride := int(avutil.GetFrameLinesizePlane(dst.ptr, 0))
+	srcStride := int(avutil.GetFrameLinesizePlane(src.ptr, 0))
+	dstPix := unsafe.Slice((*byte)(avutil.GetFrameDataPlane(dst.ptr, 0)), dstStride*int(avutil.GetFrameHeight(dst.ptr)))
+	srcPix := unsafe.Slice((*byte)(avutil.GetFrameDataPlane(src.ptr, 0)), srcStride*h)
+	for row := 0; row < h; row++ {
+		d := (y+row)*dstStride + x*4
+		copy(dstPix[d:d+w*4], srcPix[row*srcStride:row*srcStride+w*4])
+	}
+}
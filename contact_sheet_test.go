@@ -0,0 +1,135 @@
+//go:build !ios && !android && (amd64 || arm64)
+
+package ffgo
+
+import (
+	"image"
+	"image/png"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/obinnaokechukwu/ffgo/avfilter"
+)
+
+func TestThumbnailTimes(t *testing.T) {
+	got := thumbnailTimes(10*time.Second, 4)
+	want := []time.Duration{2 * time.Second, 4 * time.Second, 6 * time.Second, 8 * time.Second}
+	if len(got) != len(want) {
+		t.Fatalf("got %v, want %v", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("time %d = %v, want %v", i, got[i], want[i])
+		}
+	}
+}
+
+func TestTimestampFilter(t *testing.T) {
+	got := timestampFilter(time.Hour+2*time.Minute+3500*time.Millisecond, 90, "")
+	want := `drawtext=text=01\:02\:03:x=4:y=h-th-4:fontsize=11:fontcolor=white:box=1:boxcolor=black@0.5,format=rgba`
+	if got != want {
+		t.Errorf("timestampFilter =\n%s\nwant\n%s", got, want)
+	}
+}
+
+func TestGenerateContactSheet(t *testing.T) {
+	if !requireFFmpeg(t) {
+		return
+	}
+	out := filepath.Join(t.TempDir(), "sheet.png")
+	if err := GenerateContactSheet(createTestVideo(t), 3, 2, 80, out); err != nil {
+		t.Fatalf("GenerateContactSheet failed: %v", err)
+	}
+
+	f, err := os.Open(out)
+	if err != nil {
+		t.Fatalf("opening sheet: %v", err)
+	}
+	defer f.Close()
+	cfg, err := png.DecodeConfig(f)
+	if err != nil {
+		t.Fatalf("decoding sheet: %v", err)
+	}
+	if cfg.Width != 3*80 {
+		t.Errorf("sheet width = %d, want %d", cfg.Width, 3*80)
+	}
+	if cfg.Height <= 0 || cfg.Height%2 != 0 {
+		t.Errorf("sheet height = %d, want a positive multiple of 2 rows of even tiles", cfg.Height)
+	}
+}
+
+func TestGenerateContactSheetTimestamps(t *testing.T) {
+	if !requireFFmpeg(t) {
+		return
+	}
+	// drawtext is only built into FFmpeg with libfreetype.
+	if avfilter.GetByName("drawtext") == nil {
+		t.Skip("drawtext filter not available")
+	}
+	var font string
+	for _, p := range []string{
+		"/usr/share/fonts/truetype/dejavu/DejaVuSans.ttf",
+		"/usr/share/fonts/dejavu/DejaVuSans.ttf",
+		"/System/Library/Fonts/Supplemental/Arial.ttf",
+	} {
+		if _, err := os.Stat(p); err == nil {
+			font = p
+			break
+		}
+	}
+
+	input := createTestVideo(t)
+	dir := t.TempDir()
+	plain := filepath.Join(dir, "plain.png")
+	labeled := filepath.Join(dir, "labeled.png")
+	opts := ContactSheetOptions{Cols: 3, Rows: 2, ThumbWidth: 80}
+	if err := GenerateContactSheetWithOptions(input, plain, opts); err != nil {
+		t.Fatalf("GenerateContactSheetWithOptions failed: %v", err)
+	}
+	opts.Timestamps = true
+	opts.FontFile = font
+	if err := GenerateContactSheetWithOptions(input, labeled, opts); err != nil {
+		if font == "" {
+			t.Skipf("no font file found and fontconfig default failed: %v", err)
+		}
+		t.Fatalf("GenerateContactSheetWithOptions with timestamps failed: %v", err)
+	}
+
+	a, b := decodePNG(t, plain), decodePNG(t, labeled)
+	if a.Bounds() != b.Bounds() {
+		t.Fatalf("labeled sheet is %v, plain sheet is %v", b.Bounds(), a.Bounds())
+	}
+	// Every tile carries a label in its bottom-left corner.
+	tileH := a.Bounds().Dy() / opts.Rows
+	for i := 0; i < opts.Cols*opts.Rows; i++ {
+		x0, y0 := (i%opts.Cols)*opts.ThumbWidth, (i/opts.Cols+1)*tileH
+		changed := false
+		for y := y0 - tileH/4; y < y0 && !changed; y++ {
+			for x := x0; x < x0+opts.ThumbWidth/2; x++ {
+				if a.At(x, y) != b.At(x, y) {
+					changed = true
+					break
+				}
+			}
+		}
+		if !changed {
+			t.Errorf("tile %d has no timestamp label", i)
+		}
+	}
+}
+
+func decodePNG(t *testing.T, path string) image.Image {
+	t.Helper()
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	img, err := png.Decode(f)
+	if err != nil {
+		t.Fatalf("decoding %s: %v", path, err)
+	}
+	return img
+}
@@ -0,0 +1,140 @@
+//go:build !ios && !android && (amd64 || arm64)
+
+package ffgo
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"image"
+	_ "image/jpeg" // register decoders for image.DecodeConfig
+	_ "image/png"
+	"unsafe"
+
+	"github.com/obinnaokechukwu/ffgo/avcodec"
+	"github.com/obinnaokechukwu/ffgo/avformat"
+	"github.com/obinnaokechukwu/ffgo/avutil"
+)
+
+// SetCoverArt embeds img as the output's cover art (album art).
+// mimeType is "image/jpeg" or "image/png"; if empty it is detected from the
+// data. Must be called before WriteHeader, at most once.
+//
+// For MP4/MOV/M4A, MP3 and FLAC the picture is added as a video stream with
+// the attached_pic disposition holding a single packet, which those muxers
+// write as a "covr" atom, an ID3v2 APIC frame or a METADATA_BLOCK_PICTURE
+// respectively. For Matroska it is added as a "cover.jpg"/"cover.png"
+// attachment, as the Matroska cover art convention expects. Other formats
+// return an error.
+func (e *Encoder) SetCoverArt(img []byte, mimeType string) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	if e.closed || e.formatCtx == nil {
+		return ErrEncoderClosed
+	}
+	if e.headerWritten {
+		return ErrHeaderAlreadyWritten
+	}
+	if e.coverArt != nil {
+		return errors.New("ffgo: cover art already set")
+	}
+
+	codecID, mimeType, err := coverArtCodec(img, mimeType)
+	if err != nil {
+		return err
+	}
+	cfg, _, err := image.DecodeConfig(bytes.NewReader(img))
+	if err != nil {
+		return fmt.Errorf("ffgo: invalid cover art image: %w", err)
+	}
+
+	muxer := avformat.OutputFormatName(avformat.GetOutputFormat(e.formatCtx))
+	switch muxer {
+	case "matroska":
+		name := "cover.jpg"
+		if codecID == avcodec.CodecIDPNG {
+			name = "cover.png"
+		}
+		return e.addAttachmentLocked(Attachment{Filename: name, MimeType: mimeType, Data: img})
+	case "mp4", "mov", "ipod", "mp3", "flac":
+	default:
+		return fmt.Errorf("ffgo: format %q does not support cover art", muxer)
+	}
+
+	stream := avformat.NewStream(e.formatCtx, nil)
+	if stream == nil {
+		return errors.New("ffgo: failed to create cover art stream")
+	}
+	par := avformat.GetStreamCodecPar(stream)
+	avformat.SetCodecParType(par, avutil.MediaTypeVideo)
+	avformat.SetCodecParCodecID(par, codecID)
+	avformat.SetCodecParWidth(par, int32(cfg.Width))
+	avformat.SetCodecParHeight(par, int32(cfg.Height))
+	avformat.SetStreamDisposition(stream, avformat.AV_DISPOSITION_ATTACHED_PIC)
+	avformat.SetStreamTimeBase(stream, 1, 90000)
+
+	e.coverArt = bytes.Clone(img)
+	e.coverStream = stream
+	return nil
+}
+
+// coverArtCodec returns the codec and MIME type for a cover image, checking
+// the declared MIME type against the data's signature.
+func coverArtCodec(img []byte, mimeType string) (avcodec.CodecID, string, error) {
+	var detected string
+	switch {
+	case bytes.HasPrefix(img, []byte{0xFF, 0xD8, 0xFF}):
+		detected = "image/jpeg"
+	case bytes.HasPrefix(img, []byte("\x89PNG\r\n\x1a\n")):
+		detected = "image/png"
+	default:
+		return avcodec.CodecIDNone, "", errors.New("ffgo: cover art must be a JPEG or PNG image")
+	}
+
+	switch mimeType {
+	case "":
+	case "image/jpg":
+		mimeType = "image/jpeg"
+	case "image/jpeg", "image/png":
+	default:
+		return avcodec.CodecIDNone, "", fmt.Errorf("ffgo: unsupported cover art MIME type %q", mimeType)
+	}
+	if mimeType != "" && mimeType != detected {
+		return avcodec.CodecIDNone, "", fmt.Errorf("ffgo: cover art data is %s, not %s", detected, mimeType)
+	}
+
+	if detected == "image/png" {
+		return avcodec.CodecIDPNG, detected, nil
+	}
+	return avcodec.CodecIDMJPEG, detected, nil
+}
+
+// writeCoverArtLocked writes the cover picture set by SetCoverArt as the
+// attached-picture stream's only packet. It bypasses the interleaving queue
+// so muxers that hold back audio until the picture arrives (MP3, FLAC) get
+// it right after the header. Must be called with e.mu held.
+func (e *Encoder) writeCoverArtLocked() error {
+	if e.coverArt == nil || e.coverStream == nil {
+		return nil
+	}
+
+	pkt := avcodec.PacketAlloc()
+	if pkt == nil {
+		return ErrOutOfMemory
+	}
+	defer avcodec.PacketFree(&pkt)
+
+	if err := avcodec.NewPacket(pkt, len(e.coverArt)); err != nil {
+		return err
+	}
+	copy(unsafe.Slice((*byte)(avcodec.GetPacketData(pkt)), len(e.coverArt)), e.coverArt)
+	avcodec.SetPacketStreamIndex(pkt, avformat.GetStreamIndex(e.coverStream))
+	avcodec.SetPacketPTS(pkt, 0)
+	avcodec.SetPacketDTS(pkt, 0)
+	avcodec.SetPacketFlags(pkt, avcodec.PacketFlagKey)
+
+	err := avformat.WriteFrame(e.formatCtx, pkt)
+	e.coverArt = nil
+	return err
+}
@@ -0,0 +1,139 @@
+//go:build !ios && !android && (amd64 || arm64)
+
+package ffgo
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/png"
+	"path/filepath"
+	"testing"
+
+	"github.com/obinnaokechukwu/ffgo/avcodec"
+	"github.com/obinnaokechukwu/ffgo/avformat"
+)
+
+func testCoverPNG(t *testing.T) []byte {
+	t.Helper()
+	img := image.NewRGBA(image.Rect(0, 0, 16, 8))
+	for i := range img.Pix {
+		img.Pix[i] = 0xff
+	}
+	img.Set(1, 1, color.RGBA{R: 255, A: 255})
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		t.Fatalf("png.Encode failed: %v", err)
+	}
+	return buf.Bytes()
+}
+
+func TestCoverArtCodec(t *testing.T) {
+	pngData := testCoverPNG(t)
+	jpegData := []byte{0xFF, 0xD8, 0xFF, 0xE0, 0, 0x10}
+
+	if id, mime, err := coverArtCodec(pngData, ""); err != nil || id != avcodec.CodecIDPNG || mime != "image/png" {
+		t.Errorf("png detection: id=%v mime=%q err=%v", id, mime, err)
+	}
+	if id, mime, err := coverArtCodec(jpegData, "image/jpg"); err != nil || id != avcodec.CodecIDMJPEG || mime != "image/jpeg" {
+		t.Errorf("jpeg detection: id=%v mime=%q err=%v", id, mime, err)
+	}
+	if _, _, err := coverArtCodec(pngData, "image/jpeg"); err == nil {
+		t.Error("expected mismatched MIME type to be rejected")
+	}
+	if _, _, err := coverArtCodec([]byte("GIF89a"), ""); err == nil {
+		t.Error("expected GIF data to be rejected")
+	}
+	if _, _, err := coverArtCodec(pngData, "image/webp"); err == nil {
+		t.Error("expected unsupported MIME type to be rejected")
+	}
+}
+
+func writeAudioWithCover(t *testing.T, path string, cover []byte) {
+	t.Helper()
+	enc, err := NewEncoderWithOptions(path, &EncoderOptions{
+		Audio: &AudioEncoderConfig{Codec: CodecIDAAC, SampleRate: 48000, Channels: 2},
+	})
+	if err != nil {
+		t.Fatalf("NewEncoderWithOptions failed: %v", err)
+	}
+	defer enc.Close()
+
+	if err := enc.SetCoverArt(cover, "image/png"); err != nil {
+		t.Fatalf("SetCoverArt failed: %v", err)
+	}
+	if err := enc.SetCoverArt(cover, "image/png"); err == nil {
+		t.Fatal("expected second SetCoverArt to fail")
+	}
+
+	size := enc.AudioFrameSize()
+	if size <= 0 {
+		size = 1024
+	}
+	frame, err := newAudioFrame(AudioFormat{SampleRate: 48000, Channels: 2, SampleFormat: SampleFormatFLTP}, size)
+	if err != nil {
+		t.Fatalf("newAudioFrame failed: %v", err)
+	}
+	defer FrameFree(&frame)
+	for i := 0; i < 20; i++ {
+		if err := enc.WriteAudioFrame(frame); err != nil {
+			t.Fatalf("WriteAudioFrame failed: %v", err)
+		}
+	}
+	if err := enc.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+}
+
+func TestEncoderSetCoverArtMP4(t *testing.T) {
+	if !requireFFmpeg(t) {
+		return
+	}
+	path := filepath.Join(t.TempDir(), "cover.m4a")
+	cover := testCoverPNG(t)
+	writeAudioWithCover(t, path, cover)
+
+	dec, err := NewDecoder(path)
+	if err != nil {
+		t.Fatalf("NewDecoder failed: %v", err)
+	}
+	defer dec.Close()
+
+	found := false
+	for i := 0; i < avformat.GetNumStreams(dec.formatCtx); i++ {
+		st := avformat.GetStream(dec.formatCtx, i)
+		if avformat.GetStreamDisposition(st)&avformat.AV_DISPOSITION_ATTACHED_PIC == 0 {
+			continue
+		}
+		found = true
+		if id := avformat.GetCodecParCodecID(avformat.GetStreamCodecPar(st)); id != avcodec.CodecIDPNG {
+			t.Errorf("cover codec = %v, want PNG", id)
+		}
+	}
+	if !found {
+		t.Fatal("no attached picture stream in output")
+	}
+}
+
+func TestEncoderSetCoverArtMatroska(t *testing.T) {
+	if !requireFFmpeg(t) {
+		return
+	}
+	path := filepath.Join(t.TempDir(), "cover.mka")
+	cover := testCoverPNG(t)
+	writeAudioWithCover(t, path, cover)
+
+	dec, err := NewDecoder(path)
+	if err != nil {
+		t.Fatalf("NewDecoder failed: %v", err)
+	}
+	defer dec.Close()
+
+	atts := dec.Attachments()
+	if len(atts) != 1 {
+		t.Fatalf("attachments = %d, want 1", len(atts))
+	}
+	if atts[0].Filename != "cover.png" || atts[0].MimeType != "image/png" || !bytes.Equal(atts[0].Data, cover) {
+		t.Errorf("unexpected attachment %q %q (%d bytes)", atts[0].Filename, atts[0].MimeType, len(atts[0].Data))
+	}
+}
@@ -0,0 +1,197 @@
+//go:build !ios && !android && (amd64 || arm64)
+
+package ffgo
+
+import (
+	"fmt"
+	"math"
+	"unsafe"
+
+	"github.com/obinnaokechukwu/ffgo/avutil"
+)
+
+// checkBlendable reports an error if frames in pixFmt cannot be mixed
+// component by component: every component must be 8 bits, or 9 to 16 bits
+// stored little-endian in its own 16-bit word.
+func checkBlendable(pixFmt PixelFormat) error {
+	desc := avutil.GetPixFmtDescriptor(pixFmt)
+	if desc == nil {
+		return fmt.Errorf("ffgo: unknown pixel format %d", pixFmt)
+	}
+	unsupported := fmt.Errorf("ffgo: cannot blend pixel format %s", avutil.GetPixFmtName(pixFmt))
+	if desc.Flags&(avutil.PixFmtFlagBitstream|avutil.PixFmtFlagHWAccel|avutil.PixFmtFlagPAL) != 0 {
+		return unsupported
+	}
+	depth := desc.Comp[0].Depth
+	for c := 0; c < desc.NbComponents; c++ {
+		comp := desc.Comp[c]
+		switch {
+		case comp.Depth != depth:
+			return unsupported
+		case comp.Depth == 8:
+		case comp.Depth > 8 && comp.Depth <= 16 && comp.Shift == 0 && comp.Step%2 == 0 && desc.Flags&avutil.PixFmtFlagBE == 0:
+		default:
+			return unsupported
+		}
+	}
+	return nil
+}
+
+// blendVideo mixes src into dst in place, weighting dst by gain and src by
+// 1-gain. Both frames have the same size in pixFmt, which checkBlendable
+// must accept, and dst must be writable.
+func blendVideo(dst, src Frame, pixFmt PixelFormat, height int, gain float64) {
+	desc := avutil.GetPixFmtDescriptor(pixFmt)
+	if desc == nil {
+		return
+	}
+	wide := desc.Comp[0].Depth > 8
+	for p := 0; p < 4; p++ {
+		d := avutil.GetFrameDataPlane(dst.ptr, p)
+		s := avutil.GetFrameDataPlane(src.ptr, p)
+		if d == nil || s == nil {
+			break
+		}
+		rows := height
+		if p == 1 || p == 2 {
+			rows = -(-height >> desc.Log2ChromaH)
+		}
+		dstride := int(avutil.GetFrameLinesizePlane(dst.ptr, p))
+		sstride := int(avutil.GetFrameLinesizePlane(src.ptr, p))
+		n := min(dstride, sstride)
+		if n <= 0 {
+			continue
+		}
+		for y := 0; y < rows; y++ {
+			drow := unsafe.Add(d, y*dstride)
+			srow := unsafe.Add(s, y*sstride)
+			if wide {
+				dv := unsafe.Slice((*uint16)(drow), n/2)
+				sv := unsafe.Slice((*uint16)(srow), n/2)
+				for i := range dv {
+					dv[i] = uint16(math.Round(float64(dv[i])*gain + float64(sv[i])*(1-gain)))
+				}
+				continue
+			}
+			dv := unsafe.Slice((*byte)(drow), n)
+			sv := unsafe.Slice((*byte)(srow), n)
+			for i := range dv {
+				dv[i] = byte(math.Round(float64(dv[i])*gain + float64(sv[i])*(1-gain)))
+			}
+		}
+	}
+}
+
+// checkMixable reports an error if samples in format cannot be converted
+// to and from float64 by appendSamples and writeSamples.
+func checkMixable(format SampleFormat) error {
+	if _, _, ok := sampleLayout(format); !ok {
+		return fmt.Errorf("ffgo: cannot mix sample format %d", format)
+	}
+	return nil
+}
+
+// sampleLayout returns the packed equivalent of format and whether it is
+// planar.
+func sampleLayout(format SampleFormat) (packed SampleFormat, planar, ok bool) {
+	switch format {
+	case SampleFormatU8, SampleFormatS16, SampleFormatS32, SampleFormatFlt, SampleFormatDbl, SampleFormatS64:
+		return format, false, true
+	case SampleFormatU8P:
+		return SampleFormatU8, true, true
+	case SampleFormatS16P:
+		return SampleFormatS16, true, true
+	case SampleFormatS32P:
+		return SampleFormatS32, true, true
+	case SampleFormatFLTP:
+		return SampleFormatFlt, true, true
+	case SampleFormatDblP:
+		return SampleFormatDbl, true, true
+	case SampleFormatS64P:
+		return SampleFormatS64, true, true
+	}
+	return SampleFormatNone, false, false
+}
+
+// appendSamples appends the samples of frame, in format f, to dst as
+// interleaved float64 values scaled so that full scale is 1.
+func appendSamples(dst []float64, frame Frame, f AudioFormat) []float64 {
+	packed, planar, ok := sampleLayout(f.SampleFormat)
+	n := int(avutil.GetFrameNbSamples(frame.ptr))
+	if !ok || n <= 0 || f.Channels <= 0 {
+		return dst
+	}
+	for i := 0; i < n; i++ {
+		for ch := 0; ch < f.Channels; ch++ {
+			plane, idx := 0, i*f.Channels+ch
+			if planar {
+				plane, idx = ch, i
+			}
+			data := avutil.GetFrameDataPlane(frame.ptr, plane)
+			if data == nil {
+				dst = append(dst, 0)
+				continue
+			}
+			dst = append(dst, readSample(data, packed, idx))
+		}
+	}
+	return dst
+}
+
+// writeSamples stores the interleaved float64 samples src into frame, which
+// has room for len(src)/f.Channels samples in format f.
+func writeSamples(frame Frame, f AudioFormat, src []float64) {
+	packed, planar, ok := sampleLayout(f.SampleFormat)
+	if !ok || f.Channels <= 0 {
+		return
+	}
+	for i := 0; i < len(src)/f.Channels; i++ {
+		for ch := 0; ch < f.Channels; ch++ {
+			plane, idx := 0, i*f.Channels+ch
+			if planar {
+				plane, idx = ch, i
+			}
+			if data := avutil.GetFrameDataPlane(frame.ptr, plane); data != nil {
+				writeSample(data, packed, idx, src[i*f.Channels+ch])
+			}
+		}
+	}
+}
+
+func readSample(data unsafe.Pointer, format SampleFormat, i int) float64 {
+	switch format {
+	case SampleFormatU8:
+		return (float64(*(*uint8)(unsafe.Add(data, i))) - 128) / 128
+	case SampleFormatS16:
+		return float64(*(*int16)(unsafe.Add(data, 2*i))) / (1 << 15)
+	case SampleFormatS32:
+		return float64(*(*int32)(unsafe.Add(data, 4*i))) / (1 << 31)
+	case SampleFormatS64:
+		return float64(*(*int64)(unsafe.Add(data, 8*i))) / (1 << 63)
+	case SampleFormatFlt:
+		return float64(*(*float32)(unsafe.Add(data, 4*i)))
+	case SampleFormatDbl:
+		return *(*float64)(unsafe.Add(data, 8*i))
+	}
+	return 0
+}
+
+func writeSample(data unsafe.Pointer, format SampleFormat, i int, v float64) {
+	// Integer formats are clamped to their range.
+	clamped := math.Max(-1, math.Min(1, v))
+	switch format {
+	case SampleFormatU8:
+		*(*uint8)(unsafe.Add(data, i)) = uint8(math.Min(255, math.Round(clamped*128+128)))
+	case SampleFormatS16:
+		*(*int16)(unsafe.Add(data, 2*i)) = int16(math.Min(math.MaxInt16, math.Round(clamped*(1<<15))))
+	case SampleFormatS32:
+		*(*int32)(unsafe.Add(data, 4*i)) = int32(math.Min(math.MaxInt32, math.Round(clamped*(1<<31))))
+	case SampleFormatS64:
+		// float64 cannot hold MaxInt64; stay one ULP below 2^63.
+		*(*int64)(unsafe.Add(data, 8*i)) = int64(math.Min(math.Nextafter(1<<63, 0), math.Round(clamped*(1<<63))))
+	case SampleFormatFlt:
+		*(*float32)(unsafe.Add(data, 4*i)) = float32(v)
+	case SampleFormatDbl:
+		*(*float64)(unsafe.Add(data, 8*i)) = v
+	}
+}
@@ -77,7 +77,7 @@ func (d *Decoder) ReadDataPacket() (*Packet, error) {
 			if avutil.IsEOF(err) {
 				return nil, nil
 			}
-			return nil, err
+			return nil, d.interrupt.err(err)
 		}
 		si := int(avcodec.GetPacketStreamIndex(d.packet))
 		if _, ok := data[si]; ok {
@@ -3,11 +3,13 @@
 package ffgo
 
 import (
+	"context"
 	"errors"
 	"strconv"
 	"strings"
 	"sync"
 	"time"
+	"unsafe"
 
 	"github.com/obinnaokechukwu/ffgo/avcodec"
 	"github.com/obinnaokechukwu/ffgo/avformat"
@@ -34,9 +36,36 @@ type Decoder struct {
 
 	videoDecoderOpen bool
 	audioDecoderOpen bool
-	customIO         *CustomIOContext
-	cleanup          func()
-	closed           bool
+
+	// videoPending/audioPending are set once a packet has been sent to the
+	// codec and cleared when it is flushed or fully drained.
+	videoPending bool
+	audioPending bool
+
+	// heldVideo is a decoded frame that the next DecodeVideo or ReadFrame
+	// returns before decoding further (set by indexed SeekToFrame).
+	heldVideo    avutil.Frame
+	hasHeldVideo bool
+
+	// frameIndex, if set by UseFrameIndex, makes SeekToFrame exact.
+	frameIndex []FrameIndexEntry
+
+	// interrupt aborts blocking I/O when the context set by
+	// DecoderOptions.InterruptContext or SetInterruptContext is done.
+	interrupt decoderInterrupt
+
+	// autoRotate applies the stream's display matrix to decoded video
+	// frames through rotateGraph, built on first use.
+	autoRotate  bool
+	rotateGraph *FilterGraph
+
+	// logCapture attributes FFmpeg log messages to this decoder
+	// (DecoderOptions.CaptureLog); nil when not capturing.
+	logCapture *logCapture
+
+	customIO *CustomIOContext
+	cleanup  func()
+	closed   bool
 }
 
 // DecoderOptions configures decoder behavior.
@@ -76,6 +105,61 @@ type DecoderOptions struct {
 
 	// HWDevice specifies the hardware device for hardware acceleration (e.g., "cuda", "vaapi")
 	HWDevice string
+
+	// RTSPTransport selects the lower transport for rtsp:// inputs
+	// ("tcp", "udp", "udp_multicast" or "http"). TCP interleaving avoids
+	// the packet loss UDP suffers on lossy networks. Empty uses FFmpeg's
+	// default, which tries UDP first.
+	RTSPTransport string
+
+	// Timeout bounds how long network reads may block. For RTSP it sets the
+	// socket timeout ("timeout", or "stimeout" before FFmpeg 5); for other
+	// inputs it sets the generic I/O timeout ("rw_timeout"). Zero waits
+	// indefinitely.
+	Timeout time.Duration
+
+	// BufferSize sets the underlying socket buffer size in bytes
+	// ("buffer_size"), e.g. to absorb bursts from high-bitrate UDP or
+	// RTSP-over-UDP cameras. Zero keeps FFmpeg's default.
+	BufferSize int
+
+	// Reconnect makes http(s):// inputs reconnect after a dropped
+	// connection instead of failing the read ("reconnect"). It applies
+	// to seekable resources; set ReconnectStreamed as well for live
+	// streams.
+	Reconnect bool
+
+	// ReconnectStreamed also reconnects non-seekable (streamed) HTTP
+	// inputs such as live MPEG-TS or HLS ("reconnect_streamed").
+	ReconnectStreamed bool
+
+	// ReconnectDelayMax caps the exponential backoff between reconnection
+	// attempts ("reconnect_delay_max", whole seconds, rounded up). Zero
+	// keeps FFmpeg's default of 120s.
+	ReconnectDelayMax time.Duration
+
+	// AutoRotate turns decoded video frames upright when the stream carries
+	// a display matrix rotation of 90, 180 or 270 degrees, as phone
+	// recordings do. The video StreamInfo then describes the rotated frames:
+	// for 90 and 270 degrees Width and Height are swapped and
+	// SampleAspectRatio is inverted, while Rotation still reports the
+	// stream's rotation.
+	AutoRotate bool
+
+	// CaptureLog keeps the warnings and errors FFmpeg logs for this
+	// decoder's demuxer and codecs: errors returned by ReadPacket and the
+	// decode methods carry the message logged during the failing call, and
+	// LastLogMessage returns the latest one. Messages are still passed to
+	// any SetLogCallback/SetLogHandler handler, or printed to stderr.
+	// Requires the ffshim library; without it nothing is captured.
+	CaptureLog bool
+
+	// InterruptContext aborts blocking I/O on the input, including opening
+	// it, when the context is cancelled or its deadline passes. The
+	// callback is installed before avformat_open_input so that it reaches
+	// the network protocol; see Decoder.SetInterruptContext. Errors caused
+	// by the interrupt wrap ctx.Err().
+	InterruptContext context.Context
 }
 
 // DecoderOption is a functional option for configuring a decoder.
@@ -175,6 +259,61 @@ func WithTryMultipleFormats(enabled bool) DecoderOption {
 	}
 }
 
+// WithRTSPTransport selects the RTSP lower transport ("tcp" or "udp").
+func WithRTSPTransport(transport string) DecoderOption {
+	return func(o *DecoderOptions) {
+		o.RTSPTransport = transport
+	}
+}
+
+// WithTimeout sets the network read timeout (see DecoderOptions.Timeout).
+func WithTimeout(d time.Duration) DecoderOption {
+	return func(o *DecoderOptions) {
+		o.Timeout = d
+	}
+}
+
+// WithReconnect enables reconnection for HTTP inputs. streamed also covers
+// live (non-seekable) streams; delayMax caps the backoff (0 = default).
+func WithReconnect(streamed bool, delayMax time.Duration) DecoderOption {
+	return func(o *DecoderOptions) {
+		o.Reconnect = true
+		o.ReconnectStreamed = streamed
+		o.ReconnectDelayMax = delayMax
+	}
+}
+
+// WithAutoRotate makes the decoder return video frames upright according to
+// the stream's display matrix. See DecoderOptions.AutoRotate.
+func WithAutoRotate() DecoderOption {
+	return func(o *DecoderOptions) {
+		o.AutoRotate = true
+	}
+}
+
+// WithLogCapture makes the decoder capture FFmpeg's log messages for its
+// contexts. See DecoderOptions.CaptureLog.
+func WithLogCapture() DecoderOption {
+	return func(o *DecoderOptions) {
+		o.CaptureLog = true
+	}
+}
+
+// WithInterruptContext aborts blocking I/O on the input when ctx is done.
+// See DecoderOptions.InterruptContext.
+func WithInterruptContext(ctx context.Context) DecoderOption {
+	return func(o *DecoderOptions) {
+		o.InterruptContext = ctx
+	}
+}
+
+// WithBufferSize sets the socket buffer size in bytes (FFmpeg "buffer_size").
+func WithBufferSize(n int) DecoderOption {
+	return func(o *DecoderOptions) {
+		o.BufferSize = n
+	}
+}
+
 func buildDecoderAVOptions(opts *DecoderOptions) map[string]string {
 	if opts == nil {
 		return nil
@@ -199,9 +338,61 @@ func buildDecoderAVOptions(opts *DecoderOptions) map[string]string {
 	if len(opts.CodecWhitelist) > 0 {
 		out["codec_whitelist"] = strings.Join(opts.CodecWhitelist, ",")
 	}
+	if opts.RTSPTransport != "" {
+		out["rtsp_transport"] = opts.RTSPTransport
+	}
+	if opts.BufferSize > 0 {
+		out["buffer_size"] = strconv.Itoa(opts.BufferSize)
+	}
 	return out
 }
 
+// addNetworkAVOptions adds the options whose name depends on the input
+// protocol or FFmpeg version. avformatMajor is libavformat's major version.
+func addNetworkAVOptions(out map[string]string, path string, opts *DecoderOptions, avformatMajor uint32) {
+	if opts == nil {
+		return
+	}
+	if isHTTPURL(path) && (opts.Reconnect || opts.ReconnectStreamed) {
+		out["reconnect"] = "1"
+		if opts.ReconnectStreamed {
+			out["reconnect_streamed"] = "1"
+		}
+		if opts.ReconnectDelayMax > 0 {
+			secs := (opts.ReconnectDelayMax + time.Second - 1) / time.Second
+			out["reconnect_delay_max"] = strconv.FormatInt(int64(secs), 10)
+		}
+	}
+	if opts.Timeout <= 0 {
+		return
+	}
+	us := strconv.FormatInt(opts.Timeout.Microseconds(), 10)
+	if isRTSPURL(path) {
+		// FFmpeg 5 (libavformat 59) renamed the RTSP socket timeout from
+		// "stimeout" to "timeout", which previously meant the listen timeout
+		// in seconds.
+		if avformatMajor >= 59 {
+			out["timeout"] = us
+		} else {
+			out["stimeout"] = us
+		}
+		return
+	}
+	out["rw_timeout"] = us
+}
+
+// isHTTPURL reports whether path is an http:// or https:// URL.
+func isHTTPURL(path string) bool {
+	lower := strings.ToLower(path)
+	return strings.HasPrefix(lower, "http://") || strings.HasPrefix(lower, "https://")
+}
+
+// isRTSPURL reports whether path is an rtsp:// or rtsps:// URL.
+func isRTSPURL(path string) bool {
+	lower := strings.ToLower(path)
+	return strings.HasPrefix(lower, "rtsp://") || strings.HasPrefix(lower, "rtsps://")
+}
+
 // NewDecoder opens a media file for decoding.
 // Optional functional options can be passed to configure the decoder.
 func NewDecoder(path string, options ...DecoderOption) (*Decoder, error) {
@@ -224,15 +415,46 @@ func NewDecoderWithOptions(path string, opts *DecoderOptions) (*Decoder, error)
 		audioStreamIdx: -1,
 	}
 
-	// Open input file (with optional retry logic for ambiguous probing).
-	var err error
-	d.formatCtx, err = openInputWithRetries(path, opts)
+	opened := false
+	defer func() {
+		if !opened {
+			// Runs after the early returns below have closed the input.
+			d.interrupt.free()
+		}
+	}()
+
+	// Install the interrupt before opening: FFmpeg copies the callback into
+	// the I/O contexts avformat_open_input creates. Without a context the
+	// flag is installed cleared so SetInterruptContext can arm it later.
+	var (
+		interrupt unsafe.Pointer
+		err       error
+	)
+	if opts != nil && opts.InterruptContext != nil {
+		interrupt, err = d.interrupt.arm(opts.InterruptContext)
+	} else {
+		interrupt, err = d.interrupt.prepare()
+	}
 	if err != nil {
 		return nil, err
 	}
 
+	// Open input file (with optional retry logic for ambiguous probing).
+	d.formatCtx, err = openInputWithRetries(path, opts, interrupt)
+	if err != nil {
+		return nil, d.interrupt.err(err)
+	}
+	if opts != nil && opts.CaptureLog {
+		// Best effort: without the shim there is nothing to capture.
+		d.logCapture, _ = startLogCapture()
+		d.logCapture.watch(d.formatCtx)
+	}
+
 	// Find stream info
+	mark := d.logCapture.mark()
 	if err := avformat.FindStreamInfo(d.formatCtx, nil); err != nil {
+		err = d.logCapture.annotate(d.interrupt.err(err), mark)
+		d.logCapture.stop()
 		avformat.CloseInput(&d.formatCtx)
 		return nil, err
 	}
@@ -253,6 +475,7 @@ func NewDecoderWithOptions(path string, opts *DecoderOptions) (*Decoder, error)
 
 	if opts != nil && opts.ProgramID > 0 {
 		if err := d.selectProgramStreams(opts.ProgramID, wantVideo, wantAudio); err != nil {
+			d.logCapture.stop()
 			avformat.CloseInput(&d.formatCtx)
 			return nil, err
 		}
@@ -272,6 +495,17 @@ func NewDecoderWithOptions(path string, opts *DecoderOptions) (*Decoder, error)
 		}
 	}
 
+	if opts != nil && opts.AutoRotate && d.videoInfo != nil {
+		d.autoRotate = true
+		if r := d.videoInfo.Rotation; r == 90 || r == 270 {
+			v := d.videoInfo
+			v.Width, v.Height = v.Height, v.Width
+			if v.SampleAspectRatio.Num > 0 {
+				v.SampleAspectRatio = avutil.NewRational(v.SampleAspectRatio.Den, v.SampleAspectRatio.Num)
+			}
+		}
+	}
+
 	// Allocate packet and frame
 	d.packet = avcodec.PacketAlloc()
 	if d.packet == nil {
@@ -285,12 +519,18 @@ func NewDecoderWithOptions(path string, opts *DecoderOptions) (*Decoder, error)
 		return nil, errors.New("ffgo: failed to allocate frame")
 	}
 
+	opened = true
 	return d, nil
 }
 
 // getStreamInfo extracts stream information.
 func (d *Decoder) getStreamInfo(streamIdx int) *StreamInfo {
-	stream := avformat.GetStream(d.formatCtx, streamIdx)
+	return streamInfoFromContext(d.formatCtx, streamIdx)
+}
+
+// streamInfoFromContext extracts stream information from an open format context.
+func streamInfoFromContext(formatCtx avformat.FormatContext, streamIdx int) *StreamInfo {
+	stream := avformat.GetStream(formatCtx, streamIdx)
 	if stream == nil {
 		return nil
 	}
@@ -329,6 +569,22 @@ func (d *Decoder) getStreamInfo(streamIdx int) *StreamInfo {
 		// Get frame rate
 		frNum, frDen := avformat.GetStreamAvgFrameRate(stream)
 		info.FrameRate = avutil.NewRational(frNum, frDen)
+		rNum, rDen := avformat.GetStreamRFrameRate(stream)
+		info.RFrameRate = avutil.NewRational(rNum, rDen)
+
+		// Prefer the container's aspect ratio over the bitstream's, as
+		// av_guess_sample_aspect_ratio does.
+		sarNum, sarDen := avformat.GetStreamSampleAspectRatio(stream)
+		if sarNum <= 0 || sarDen <= 0 {
+			sarNum, sarDen = avformat.GetCodecParSampleAspectRatio(codecPar)
+		}
+		if sarNum > 0 && sarDen > 0 {
+			info.SampleAspectRatio = avutil.NewRational(sarNum, sarDen)
+		}
+
+		if m, ok := avformat.GetStreamDisplayMatrix(stream); ok {
+			info.Rotation = displayMatrixRotation(m)
+		}
 	} else if codecType == avutil.MediaTypeAudio {
 		info.SampleRate = int(avformat.GetCodecParSampleRate(codecPar))
 		info.Channels = int(avformat.GetCodecParChannels(codecPar))
@@ -349,6 +605,100 @@ func (d *Decoder) AudioStream() *StreamInfo {
 	return d.audioInfo
 }
 
+// StreamInfoByIndex returns information about the stream at index, including
+// subtitle, data and attachment streams. Returns nil if index is out of range.
+func (d *Decoder) StreamInfoByIndex(index int) *StreamInfo {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if d.closed || index < 0 || index >= d.NumStreams() {
+		return nil
+	}
+	return d.getStreamInfo(index)
+}
+
+// Streams returns information about every stream in the container, ordered
+// by stream index. Use StreamInfo.Type to distinguish media types.
+func (d *Decoder) Streams() []*StreamInfo {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if d.closed {
+		return nil
+	}
+	n := d.NumStreams()
+	streams := make([]*StreamInfo, 0, n)
+	for i := 0; i < n; i++ {
+		if info := d.getStreamInfo(i); info != nil {
+			streams = append(streams, info)
+		}
+	}
+	return streams
+}
+
+// SelectVideoStream makes the stream at index the decoder's video stream.
+// Any open video codec context is closed so the next OpenVideoDecoder or
+// DecodeVideo call opens a decoder for the new stream.
+//
+// It fails if index is not a video stream, or if the current video decoder
+// still holds buffered frames (call FlushDecoder or seek first).
+func (d *Decoder) SelectVideoStream(index int) error {
+	return d.selectStream(index, MediaTypeVideo)
+}
+
+// SelectAudioStream makes the stream at index the decoder's audio stream.
+// See SelectVideoStream for details.
+func (d *Decoder) SelectAudioStream(index int) error {
+	return d.selectStream(index, MediaTypeAudio)
+}
+
+func (d *Decoder) selectStream(index int, mediaType MediaType) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if d.closed {
+		return errors.New("ffgo: decoder is closed")
+	}
+	if index < 0 || index >= d.NumStreams() {
+		return ErrInvalidStream
+	}
+	kind := "video"
+	if mediaType == MediaTypeAudio {
+		kind = "audio"
+	}
+	info := d.getStreamInfo(index)
+	if info == nil || info.Type != mediaType {
+		return errors.New("ffgo: stream " + strconv.Itoa(index) + " is not a " + kind + " stream")
+	}
+
+	switch mediaType {
+	case MediaTypeVideo:
+		if d.videoPending {
+			return errors.New("ffgo: " + kind + " decoder has buffered frames; flush before switching streams")
+		}
+		if d.videoCodecCtx != nil {
+			d.logCapture.unwatch(d.videoCodecCtx)
+			avcodec.FreeContext(&d.videoCodecCtx)
+			d.codecCtx = nil
+		}
+		d.videoDecoderOpen = false
+		d.videoStreamIdx = index
+		d.videoInfo = info
+	case MediaTypeAudio:
+		if d.audioPending {
+			return errors.New("ffgo: " + kind + " decoder has buffered frames; flush before switching streams")
+		}
+		if d.audioCodecCtx != nil {
+			d.logCapture.unwatch(d.audioCodecCtx)
+			avcodec.FreeContext(&d.audioCodecCtx)
+		}
+		d.audioDecoderOpen = false
+		d.audioStreamIdx = index
+		d.audioInfo = info
+	}
+	return nil
+}
+
 // HasVideo returns true if the file has a video stream.
 func (d *Decoder) HasVideo() bool {
 	return d.videoStreamIdx >= 0
@@ -398,6 +748,15 @@ func (d *Decoder) BitRate() int64 {
 	return avformat.GetBitRate(d.formatCtx)
 }
 
+// LastLogMessage returns the most recent warning or error FFmpeg logged for
+// this decoder's demuxer or codecs, or "" if there is none or the decoder
+// was opened without DecoderOptions.CaptureLog. Codecs that decode on
+// worker threads may log through per-thread contexts that are not
+// attributed to the decoder.
+func (d *Decoder) LastLogMessage() string {
+	return d.logCapture.message()
+}
+
 // ReadPacket reads the next packet from the file.
 // Returns (nil, nil) on EOF.
 //
@@ -415,11 +774,12 @@ func (d *Decoder) ReadPacket() (*Packet, error) {
 	avcodec.PacketUnref(d.packet)
 
 	// Read next packet
+	mark := d.logCapture.mark()
 	if err := avformat.ReadFrame(d.formatCtx, d.packet); err != nil {
 		if avutil.IsEOF(err) {
 			return nil, nil
 		}
-		return nil, err
+		return nil, d.logCapture.annotate(d.interrupt.err(err), mark)
 	}
 
 	return &Packet{ptr: d.packet, owned: false}, nil
@@ -454,15 +814,20 @@ func (d *Decoder) OpenVideoDecoder() error {
 	if d.videoCodecCtx == nil {
 		return errors.New("ffgo: failed to allocate codec context")
 	}
+	d.logCapture.watch(d.videoCodecCtx)
 
 	// Copy codec parameters
 	if err := avcodec.ParametersToContext(d.videoCodecCtx, codecPar); err != nil {
+		d.logCapture.unwatch(d.videoCodecCtx)
 		avcodec.FreeContext(&d.videoCodecCtx)
 		return err
 	}
 
 	// Open codec
+	mark := d.logCapture.mark()
 	if err := avcodec.Open2(d.videoCodecCtx, codec, nil); err != nil {
+		err = d.logCapture.annotate(err, mark)
+		d.logCapture.unwatch(d.videoCodecCtx)
 		avcodec.FreeContext(&d.videoCodecCtx)
 		return err
 	}
@@ -500,15 +865,20 @@ func (d *Decoder) OpenAudioDecoder() error {
 	if d.audioCodecCtx == nil {
 		return errors.New("ffgo: failed to allocate audio codec context")
 	}
+	d.logCapture.watch(d.audioCodecCtx)
 
 	// Copy codec parameters
 	if err := avcodec.ParametersToContext(d.audioCodecCtx, codecPar); err != nil {
+		d.logCapture.unwatch(d.audioCodecCtx)
 		avcodec.FreeContext(&d.audioCodecCtx)
 		return err
 	}
 
 	// Open codec
+	mark := d.logCapture.mark()
 	if err := avcodec.Open2(d.audioCodecCtx, codec, nil); err != nil {
+		err = d.logCapture.annotate(err, mark)
+		d.logCapture.unwatch(d.audioCodecCtx)
 		avcodec.FreeContext(&d.audioCodecCtx)
 		return err
 	}
@@ -533,17 +903,28 @@ func (d *Decoder) DecodeVideoPacket(pkt *Packet) (Frame, error) {
 	if pkt != nil {
 		raw = pkt.ptr
 	}
+	mark := d.logCapture.mark()
 	if err := avcodec.SendPacket(d.videoCodecCtx, raw); err != nil {
-		return Frame{}, err
+		return Frame{}, d.logCapture.annotate(err, mark)
+	}
+	if raw != nil {
+		d.videoPending = true
 	}
 
 	// Receive decoded frame
 	avutil.FrameUnref(d.frame)
 	err := avcodec.ReceiveFrame(d.videoCodecCtx, d.frame)
 	if err != nil {
-		if avutil.IsAgain(err) || avutil.IsEOF(err) {
+		if avutil.IsEOF(err) {
+			d.videoPending = false
+			return Frame{}, nil
+		}
+		if avutil.IsAgain(err) {
 			return Frame{}, nil
 		}
+		return Frame{}, d.logCapture.annotate(err, mark)
+	}
+	if err := d.autoRotateLocked(); err != nil {
 		return Frame{}, err
 	}
 
@@ -579,18 +960,26 @@ func (d *Decoder) DecodeAudioPacket(pkt *Packet) (Frame, error) {
 	if pkt != nil {
 		raw = pkt.ptr
 	}
+	mark := d.logCapture.mark()
 	if err := avcodec.SendPacket(d.audioCodecCtx, raw); err != nil {
-		return Frame{}, err
+		return Frame{}, d.logCapture.annotate(err, mark)
+	}
+	if raw != nil {
+		d.audioPending = true
 	}
 
 	// Receive decoded frame
 	avutil.FrameUnref(d.frame)
 	err := avcodec.ReceiveFrame(d.audioCodecCtx, d.frame)
 	if err != nil {
-		if avutil.IsAgain(err) || avutil.IsEOF(err) {
+		if avutil.IsEOF(err) {
+			d.audioPending = false
 			return Frame{}, nil
 		}
-		return Frame{}, err
+		if avutil.IsAgain(err) {
+			return Frame{}, nil
+		}
+		return Frame{}, d.logCapture.annotate(err, mark)
 	}
 
 	return Frame{ptr: d.frame, owned: false}, nil
@@ -611,8 +1000,9 @@ func (d *Decoder) DecodeAudioPacketCopy(pkt *Packet) (Frame, error) {
 
 // DecodeVideo reads and decodes the next video frame.
 // This is a convenience method that handles packet reading internally.
-// The returned frame is owned by the decoder; do not call FrameFree on it.
-// If you need to keep the frame beyond the next decode call, make a copy.
+// The returned frame is borrowed: it is owned and reused by the decoder and
+// is overwritten by the next decode, seek or Close. Do not call FrameFree
+// on it; use DecodeVideoCopy (or FrameClone) to keep a frame.
 // Returns nil frame on EOF.
 func (d *Decoder) DecodeVideo() (Frame, error) {
 	if !d.videoDecoderOpen {
@@ -621,6 +1011,10 @@ func (d *Decoder) DecodeVideo() (Frame, error) {
 		}
 	}
 
+	if frame, ok := d.takeHeldVideo(); ok {
+		return frame, nil
+	}
+
 	for {
 		pkt, err := d.ReadPacket()
 		if err != nil {
@@ -654,8 +1048,9 @@ func (d *Decoder) DecodeVideo() (Frame, error) {
 
 // DecodeVideoCopy reads and decodes the next video frame and returns an owned frame.
 //
-// The caller MUST free the returned frame with FrameFree.
-// Returns nil frame on EOF.
+// The frame is a FrameClone of the one DecodeVideo would return, so it stays
+// valid across later decode calls. The caller MUST free the returned frame
+// with FrameFree. Returns nil frame on EOF.
 func (d *Decoder) DecodeVideoCopy() (Frame, error) {
 	frame, err := d.DecodeVideo()
 	if err != nil || frame.IsNil() {
@@ -664,11 +1059,69 @@ func (d *Decoder) DecodeVideoCopy() (Frame, error) {
 	return FrameClone(frame)
 }
 
+// VideoFrames decodes the video stream in a goroutine and sends every frame
+// on the returned channel until the end of the input, a decode error, or
+// the cancellation of ctx. The frames are clones owned by the receiver,
+// who must free each one with FrameFree; a frame that was decoded but not
+// yet received when ctx is cancelled is freed by VideoFrames.
+//
+// The frame channel is closed when decoding stops. The error channel then
+// yields the error that stopped it, or ctx.Err() after cancellation, and is
+// closed; it yields nothing at the end of the input. Do not use the decoder
+// from other goroutines until the frame channel is closed.
+//
+//	frames, errc := dec.VideoFrames(ctx)
+//	for f := range frames {
+//	    process(f)
+//	    ffgo.FrameFree(&f)
+//	}
+//	if err := <-errc; err != nil {
+//	    return err
+//	}
+func (d *Decoder) VideoFrames(ctx context.Context) (<-chan Frame, <-chan error) {
+	frames := make(chan Frame)
+	errc := make(chan error, 1)
+
+	go func() {
+		defer close(errc)
+		defer close(frames)
+
+		if err := d.OpenVideoDecoder(); err != nil {
+			errc <- err
+			return
+		}
+		for {
+			if err := ctx.Err(); err != nil {
+				errc <- err
+				return
+			}
+			frame, err := d.DecodeVideoCopy()
+			if err != nil {
+				if !IsEOF(err) {
+					errc <- err
+				}
+				return
+			}
+			if frame.IsNil() {
+				return
+			}
+			select {
+			case frames <- frame:
+			case <-ctx.Done():
+				_ = FrameFree(&frame)
+				errc <- ctx.Err()
+				return
+			}
+		}
+	}()
+
+	return frames, errc
+}
+
 // DecodeAudio reads and decodes the next audio frame.
 // This is a convenience method that handles packet reading internally.
-// The returned frame is owned by the decoder; do not call FrameFree on it.
-// If you need to keep the frame beyond the next decode call, make a copy.
-// Returns nil frame on EOF.
+// The returned frame is borrowed, like DecodeVideo's; use DecodeAudioCopy
+// (or FrameClone) to keep it. Returns nil frame on EOF.
 func (d *Decoder) DecodeAudio() (Frame, error) {
 	if !d.audioDecoderOpen {
 		if err := d.OpenAudioDecoder(); err != nil {
@@ -707,11 +1160,31 @@ func (d *Decoder) DecodeAudio() (Frame, error) {
 	}
 }
 
-// ReadFrame reads and decodes the next frame (video or audio).
-// Returns a FrameWrapper with the MediaType set.
-// The frame is owned by the decoder; call Copy() if you need to keep it.
-// Returns nil, nil on EOF.
-func (d *Decoder) ReadFrame() (*FrameWrapper, error) {
+// DecodeAudioCopy reads and decodes the next audio frame and returns an owned frame.
+//
+// The caller MUST free the returned frame with FrameFree.
+// Returns nil frame on EOF.
+func (d *Decoder) DecodeAudioCopy() (Frame, error) {
+	frame, err := d.DecodeAudio()
+	if err != nil || frame.IsNil() {
+		return Frame{}, err
+	}
+	return FrameClone(frame)
+}
+
+// DecodedFrame is a decoded video or audio frame returned by ReadFrame.
+// MediaType reports which stream it came from; Width/Height are meaningful
+// for video frames and NumSamples for audio frames.
+type DecodedFrame = FrameWrapper
+
+// ReadFrame reads and decodes the next frame from whichever of the video and
+// audio streams comes next in the container, opening both decoders on demand.
+//
+// The frame is owned by the decoder and is only valid until the next read;
+// call Copy() if you need to keep it. Once the input is exhausted the frames
+// still buffered in the decoders are returned (video first, then audio), after
+// which ReadFrame returns (nil, err) with IsEOF(err) reporting true.
+func (d *Decoder) ReadFrame() (*DecodedFrame, error) {
 	// Open decoders if needed
 	if d.HasVideo() && !d.videoDecoderOpen {
 		if err := d.OpenVideoDecoder(); err != nil {
@@ -723,6 +1196,9 @@ func (d *Decoder) ReadFrame() (*FrameWrapper, error) {
 			return nil, err
 		}
 	}
+	if frame, ok := d.takeHeldVideo(); ok {
+		return WrapFrame(frame, MediaTypeVideo), nil
+	}
 
 	for {
 		pkt, err := d.ReadPacket()
@@ -730,7 +1206,9 @@ func (d *Decoder) ReadFrame() (*FrameWrapper, error) {
 			return nil, err
 		}
 		if pkt == nil {
-			// EOF: Flush video decoder first
+			// EOF: drain the video decoder first, then the audio decoder.
+			// Flushing a drained decoder again just reports EOF, so repeated
+			// calls walk through every buffered frame.
 			if d.videoDecoderOpen {
 				frame, err := d.DecodeVideoPacket(nil)
 				if err != nil {
@@ -740,7 +1218,6 @@ func (d *Decoder) ReadFrame() (*FrameWrapper, error) {
 					return WrapFrame(frame, MediaTypeVideo), nil
 				}
 			}
-			// Flush audio decoder
 			if d.audioDecoderOpen {
 				frame, err := d.DecodeAudioPacket(nil)
 				if err != nil {
@@ -750,7 +1227,7 @@ func (d *Decoder) ReadFrame() (*FrameWrapper, error) {
 					return WrapFrame(frame, MediaTypeAudio), nil
 				}
 			}
-			return nil, nil // EOF
+			return nil, avutil.NewError(avutil.AVERROR_EOF, "av_read_frame")
 		}
 
 		// Decode video packet
@@ -780,8 +1257,8 @@ func (d *Decoder) ReadFrame() (*FrameWrapper, error) {
 // ReadFrameCopy reads and decodes the next frame (video or audio) and returns an owned frame wrapper.
 //
 // The returned wrapper owns its underlying frame; the caller MUST call Free() when done.
-// Returns (nil, nil) on EOF.
-func (d *Decoder) ReadFrameCopy() (*FrameWrapper, error) {
+// Like ReadFrame, it reports the end of input with an error for which IsEOF is true.
+func (d *Decoder) ReadFrameCopy() (*DecodedFrame, error) {
 	fw, err := d.ReadFrame()
 	if err != nil || fw == nil {
 		return nil, err
@@ -794,12 +1271,59 @@ func (d *Decoder) FlushDecoder() {
 	d.mu.Lock()
 	defer d.mu.Unlock()
 
+	d.flushCodecsLocked()
+}
+
+// flushCodecsLocked drops all frames buffered in the open codec contexts.
+func (d *Decoder) flushCodecsLocked() {
 	if d.videoCodecCtx != nil {
 		avcodec.FlushBuffers(d.videoCodecCtx)
 	}
 	if d.audioCodecCtx != nil {
 		avcodec.FlushBuffers(d.audioCodecCtx)
 	}
+	d.videoPending = false
+	d.audioPending = false
+	d.dropHeldVideoLocked()
+}
+
+// holdVideoLocked keeps a reference to frame for the next DecodeVideo.
+func (d *Decoder) holdVideoLocked(frame avutil.Frame) error {
+	if d.heldVideo == nil {
+		if d.heldVideo = avutil.FrameAlloc(); d.heldVideo == nil {
+			return errors.New("ffgo: failed to allocate frame")
+		}
+	}
+	avutil.FrameUnref(d.heldVideo)
+	if err := avutil.FrameRef(d.heldVideo, frame); err != nil {
+		return err
+	}
+	d.hasHeldVideo = true
+	return nil
+}
+
+// takeHeldVideo moves the held frame, if any, into the decoder frame.
+func (d *Decoder) takeHeldVideo() (Frame, bool) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if !d.hasHeldVideo {
+		return Frame{}, false
+	}
+	avutil.FrameUnref(d.frame)
+	err := avutil.FrameRef(d.frame, d.heldVideo)
+	d.dropHeldVideoLocked()
+	if err != nil {
+		return Frame{}, false
+	}
+	return Frame{ptr: d.frame, owned: false}, true
+}
+
+func (d *Decoder) dropHeldVideoLocked() {
+	if d.heldVideo != nil {
+		avutil.FrameUnref(d.heldVideo)
+	}
+	d.hasHeldVideo = false
 }
 
 // Seek seeks to a position in the file.
@@ -824,12 +1348,7 @@ func (d *Decoder) SeekTimestamp(timestamp int64) error {
 	}
 
 	// Flush decoder buffers
-	if d.videoCodecCtx != nil {
-		avcodec.FlushBuffers(d.videoCodecCtx)
-	}
-	if d.audioCodecCtx != nil {
-		avcodec.FlushBuffers(d.audioCodecCtx)
-	}
+	d.flushCodecsLocked()
 
 	return nil
 }
@@ -850,10 +1369,21 @@ func (d *Decoder) Close() error {
 	}
 	d.closed = true
 
+	// Detach the log capture before its contexts are freed
+	d.logCapture.stop()
+
 	// Free frame
 	if d.frame != nil {
 		avutil.FrameFree(&d.frame)
 	}
+	if d.heldVideo != nil {
+		avutil.FrameFree(&d.heldVideo)
+		d.hasHeldVideo = false
+	}
+	if d.rotateGraph != nil {
+		_ = d.rotateGraph.Close()
+		d.rotateGraph = nil
+	}
 
 	// Free packet
 	if d.packet != nil {
@@ -877,6 +1407,7 @@ func (d *Decoder) Close() error {
 	if d.formatCtx != nil {
 		avformat.CloseInput(&d.formatCtx)
 	}
+	d.interrupt.free()
 
 	// Cleanup any extra resources (e.g. custom I/O, temp files).
 	if d.cleanup != nil {
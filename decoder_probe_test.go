@@ -39,3 +39,71 @@ func TestBuildDecoderAVOptions_TypedOverridesRaw(t *testing.T) {
 	}
 }
 
+func TestBuildDecoderAVOptions_Network(t *testing.T) {
+	opts := &DecoderOptions{
+		RTSPTransport: "tcp",
+		Timeout:       5 * time.Second,
+		BufferSize:    1 << 20,
+	}
+
+	m := buildDecoderAVOptions(opts)
+	if got := m["rtsp_transport"]; got != "tcp" {
+		t.Fatalf("rtsp_transport: expected tcp, got %q", got)
+	}
+	if got := m["buffer_size"]; got != "1048576" {
+		t.Fatalf("buffer_size: expected 1048576, got %q", got)
+	}
+
+	rtsp := buildDecoderAVOptions(opts)
+	addNetworkAVOptions(rtsp, "rtsp://camera.local/stream", opts, 60)
+	if got := rtsp["timeout"]; got != "5000000" {
+		t.Fatalf("rtsp timeout (FFmpeg 5+): expected 5000000, got %q", got)
+	}
+	if _, ok := rtsp["stimeout"]; ok {
+		t.Fatal("stimeout should not be set for FFmpeg 5+")
+	}
+
+	old := buildDecoderAVOptions(opts)
+	addNetworkAVOptions(old, "RTSP://camera.local/stream", opts, 58)
+	if got := old["stimeout"]; got != "5000000" {
+		t.Fatalf("rtsp stimeout (FFmpeg 4): expected 5000000, got %q", got)
+	}
+	if _, ok := old["timeout"]; ok {
+		t.Fatal("timeout should not be set for FFmpeg 4 RTSP")
+	}
+
+	http := buildDecoderAVOptions(opts)
+	addNetworkAVOptions(http, "http://example.com/live.ts", opts, 60)
+	if got := http["rw_timeout"]; got != "5000000" {
+		t.Fatalf("rw_timeout: expected 5000000, got %q", got)
+	}
+	if _, ok := http["timeout"]; ok {
+		t.Fatal("timeout should not be set for non-RTSP inputs")
+	}
+}
+
+func TestAddNetworkAVOptions_Reconnect(t *testing.T) {
+	opts := &DecoderOptions{
+		Reconnect:         true,
+		ReconnectStreamed: true,
+		ReconnectDelayMax: 1500 * time.Millisecond,
+	}
+
+	m := map[string]string{}
+	addNetworkAVOptions(m, "https://example.com/live.m3u8", opts, 60)
+	if got := m["reconnect"]; got != "1" {
+		t.Fatalf("reconnect: expected 1, got %q", got)
+	}
+	if got := m["reconnect_streamed"]; got != "1" {
+		t.Fatalf("reconnect_streamed: expected 1, got %q", got)
+	}
+	if got := m["reconnect_delay_max"]; got != "2" {
+		t.Fatalf("reconnect_delay_max: expected 2, got %q", got)
+	}
+
+	rtmp := map[string]string{}
+	addNetworkAVOptions(rtmp, "rtmp://example.com/live/key", opts, 60)
+	if len(rtmp) != 0 {
+		t.Fatalf("reconnect options should only apply to HTTP inputs, got %v", rtmp)
+	}
+}
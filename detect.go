@@ -0,0 +1,324 @@
+//go:build !ios && !android && (amd64 || arm64)
+
+package ffgo
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/obinnaokechukwu/ffgo/avutil"
+)
+
+// TimeRange is a span of media time, End exclusive.
+type TimeRange struct {
+	Start, End time.Duration
+}
+
+// Duration returns the length of the range.
+func (r TimeRange) Duration() time.Duration {
+	return r.End - r.Start
+}
+
+// DetectBlackFrames decodes the remaining video stream through FFmpeg's
+// blackdetect filter and returns the ranges of black video lasting at least
+// minDuration, e.g. to trim leaders and trailers. threshold is the
+// luminance below which a pixel counts as black, from 0 to 1 (blackdetect's
+// pix_th; 0.10 is its default). A frame is black when 98% of its pixels are.
+//
+// Ranges come from the frame metadata blackdetect sets, which requires the
+// shim (see Frame.Metadata).
+func (d *Decoder) DetectBlackFrames(minDuration time.Duration, threshold float64) ([]TimeRange, error) {
+	if minDuration < 0 {
+		return nil, errors.New("ffgo: minimum duration must not be negative")
+	}
+	if threshold < 0 || threshold > 1 {
+		return nil, errors.New("ffgo: black threshold must be between 0 and 1")
+	}
+	if !d.HasVideo() {
+		return nil, ErrNoVideoStream
+	}
+	if !frameMetadataAvailable() {
+		return nil, errNoFrameMetadata
+	}
+	info := d.VideoStream()
+
+	// blackdetect only applies d= to its log output, so keep every range
+	// and filter by duration below.
+	filter := fmt.Sprintf("blackdetect=d=%s:pix_th=%s", formatSeconds(minDuration),
+		strconv.FormatFloat(threshold, 'f', -1, 64))
+	var (
+		tracker  = rangeTracker{startKey: "lavfi.black_start", endKey: "lavfi.black_end"}
+		frameDur time.Duration
+	)
+	if info.FrameRate.Num > 0 && info.FrameRate.Den > 0 {
+		frameDur = time.Duration(float64(time.Second) * float64(info.FrameRate.Den) / float64(info.FrameRate.Num))
+	}
+	err := d.runAnalysisFilter(true, filter, 0, func(f Frame) {
+		var end time.Duration
+		if pts := avutil.GetFramePTS(f.ptr); pts != avutil.AV_NOPTS_VALUE {
+			end = ptsToDuration(pts, info.TimeBase) + frameDur
+		}
+		tracker.add(f.Metadata(), end)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return tracker.finish(minDuration), nil
+}
+
+// DetectSilence decodes the remaining audio stream through FFmpeg's
+// silencedetect filter and returns the ranges where the audio stays below
+// noiseDB (e.g. -50) for at least minDuration.
+//
+// Ranges come from the frame metadata silencedetect sets, which requires
+// the shim (see Frame.Metadata).
+func (d *Decoder) DetectSilence(noiseDB float64, minDuration time.Duration) ([]TimeRange, error) {
+	if minDuration < 0 {
+		return nil, errors.New("ffgo: minimum duration must not be negative")
+	}
+	if !d.HasAudio() {
+		return nil, ErrNoAudioStream
+	}
+	if !frameMetadataAvailable() {
+		return nil, errNoFrameMetadata
+	}
+
+	filter := fmt.Sprintf("silencedetect=n=%sdB:d=%s",
+		strconv.FormatFloat(noiseDB, 'f', -1, 64), formatSeconds(minDuration))
+	tracker := rangeTracker{startKey: "lavfi.silence_start", endKey: "lavfi.silence_end"}
+	err := d.runAnalysisFilter(false, filter, 0, func(f Frame) {
+		var end time.Duration
+		if pts := avutil.GetFramePTS(f.ptr); pts != avutil.AV_NOPTS_VALUE {
+			rate := int64(avutil.GetFrameSampleRate(f.ptr))
+			if rate > 0 {
+				end = time.Duration(pts+int64(avutil.GetFrameNbSamples(f.ptr))) * time.Second / time.Duration(rate)
+			}
+		}
+		tracker.add(f.Metadata(), end)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return tracker.finish(minDuration), nil
+}
+
+// formatSeconds formats d as decimal seconds for a filter option.
+func formatSeconds(d time.Duration) string {
+	return strconv.FormatFloat(d.Seconds(), 'f', -1, 64)
+}
+
+// rangeTracker assembles TimeRanges from the start/end metadata pairs that
+// detection filters attach to their output frames.
+type rangeTracker struct {
+	startKey, endKey string
+
+	ranges  []TimeRange
+	open    bool
+	start   time.Duration
+	lastEnd time.Duration // end of the last frame seen
+}
+
+// add records the metadata of one output frame ending at end.
+func (t *rangeTracker) add(md map[string]string, end time.Duration) {
+	t.lastEnd = max(t.lastEnd, end)
+	if v, ok := md[t.startKey]; ok {
+		if s, err := strconv.ParseFloat(strings.TrimSpace(v), 64); err == nil && !t.open {
+			t.open = true
+			t.start = time.Duration(s * float64(time.Second))
+		}
+	}
+	if v, ok := md[t.endKey]; ok {
+		if e, err := strconv.ParseFloat(strings.TrimSpace(v), 64); err == nil && t.open {
+			t.open = false
+			t.ranges = append(t.ranges, TimeRange{Start: t.start, End: time.Duration(e * float64(time.Second))})
+		}
+	}
+}
+
+// finish closes a range still open at the end of the stream and returns the
+// ranges lasting at least minDuration, in order.
+func (t *rangeTracker) finish(minDuration time.Duration) []TimeRange {
+	if t.open && t.lastEnd > t.start {
+		t.ranges = append(t.ranges, TimeRange{Start: t.start, End: t.lastEnd})
+		t.open = false
+	}
+	var out []TimeRange
+	for _, r := range t.ranges {
+		if r.Duration() >= minDuration {
+			out = append(out, r)
+		}
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Start < out[j].Start })
+	return out
+}
+
+// errNoFramesDecoded is returned by runAnalysisFilter when the stream has
+// no frames left to analyze.
+var errNoFramesDecoded = errors.New("ffgo: no frames decoded")
+
+// runAnalysisFilter decodes the remaining video (or audio) stream through a
+// filter graph running filters and passes every output frame to fn, which
+// must not keep it. If maxFrames is positive, it stops after that many
+// decoded frames. Output timestamps are in the stream time base for video
+// and in samples (1/sample rate) for audio.
+func (d *Decoder) runAnalysisFilter(video bool, filters string, maxFrames int, fn func(f Frame)) error {
+	var info *StreamInfo
+	if video {
+		if err := d.OpenVideoDecoder(); err != nil {
+			return err
+		}
+		info = d.VideoStream()
+	} else {
+		if err := d.OpenAudioDecoder(); err != nil {
+			return err
+		}
+		info = d.AudioStream()
+	}
+	if info == nil {
+		if video {
+			return ErrNoVideoStream
+		}
+		return ErrNoAudioStream
+	}
+
+	var graph *FilterGraph
+	defer func() {
+		if graph != nil {
+			_ = graph.Close()
+		}
+	}()
+	emit := func(frames []Frame) {
+		for i := range frames {
+			fn(frames[i])
+			_ = frames[i].Free()
+		}
+	}
+
+	for n := 0; maxFrames <= 0 || n < maxFrames; n++ {
+		var (
+			frame Frame
+			err   error
+		)
+		if video {
+			frame, err = d.DecodeVideo()
+		} else {
+			frame, err = d.DecodeAudio()
+		}
+		if err != nil && !IsEOF(err) {
+			return err
+		}
+		if err != nil || frame.IsNil() {
+			break
+		}
+
+		if graph == nil {
+			cfg := FilterGraphConfig{Filters: filters}
+			if video {
+				cfg.Width = int(avutil.GetFrameWidth(frame.ptr))
+				cfg.Height = int(avutil.GetFrameHeight(frame.ptr))
+				cfg.PixelFmt = PixelFormat(avutil.GetFrameFormat(frame.ptr))
+				cfg.TimeBase = info.TimeBase
+			} else {
+				cfg.SampleRate = int(avutil.GetFrameSampleRate(frame.ptr))
+				cfg.Channels = int(avutil.GetFrameChannels(frame.ptr))
+				cfg.SampleFmt = SampleFormat(avutil.GetFrameFormat(frame.ptr))
+			}
+			if graph, err = NewFilterGraph(cfg); err != nil {
+				return err
+			}
+		}
+		if !video {
+			// abuffer runs in 1/sample_rate.
+			if pts := avutil.GetFramePTS(frame.ptr); pts != avutil.AV_NOPTS_VALUE {
+				rate := NewRational(1, avutil.GetFrameSampleRate(frame.ptr))
+				avutil.SetFramePTS(frame.ptr, rescaleTS(pts, info.TimeBase, rate))
+			}
+		}
+
+		out, err := graph.Filter(&frame)
+		emit(out)
+		if err != nil {
+			return err
+		}
+	}
+	if graph == nil {
+		return errNoFramesDecoded
+	}
+	out, err := graph.Flush()
+	emit(out)
+	return err
+}
+
+// CropRect is a rectangle within a video frame, in pixels.
+type CropRect struct {
+	X, Y, Width, Height int
+}
+
+// DetectCrop runs FFmpeg's cropdetect filter over the next sampleFrames
+// decoded video frames and returns the crop rectangle it suggested most
+// often, i.e. the picture area without letterbox or pillarbox bars. Seek
+// past intros first for a representative sample; a few hundred frames is
+// usually enough. The dimensions are rounded to multiples of 16, as
+// cropdetect does by default.
+//
+// The suggestions are read from frame metadata, which requires the shim
+// (see Frame.Metadata).
+func (d *Decoder) DetectCrop(sampleFrames int) (CropRect, error) {
+	if sampleFrames <= 0 {
+		return CropRect{}, errors.New("ffgo: sample frame count must be positive")
+	}
+	if !d.HasVideo() {
+		return CropRect{}, ErrNoVideoStream
+	}
+	if !frameMetadataAvailable() {
+		return CropRect{}, errNoFrameMetadata
+	}
+
+	counts := make(map[CropRect]int)
+	var order []CropRect // first-seen order, to break ties deterministically
+	err := d.runAnalysisFilter(true, "cropdetect", sampleFrames, func(f Frame) {
+		r, ok := parseCropMetadata(f.Metadata())
+		if !ok {
+			return
+		}
+		if counts[r] == 0 {
+			order = append(order, r)
+		}
+		counts[r]++
+	})
+	if err != nil {
+		return CropRect{}, err
+	}
+	if len(order) == 0 {
+		return CropRect{}, errors.New("ffgo: cropdetect made no suggestion")
+	}
+
+	best := order[0]
+	for _, r := range order[1:] {
+		if counts[r] > counts[best] {
+			best = r
+		}
+	}
+	return best, nil
+}
+
+// parseCropMetadata reads the crop rectangle cropdetect attaches to a frame.
+func parseCropMetadata(md map[string]string) (CropRect, bool) {
+	var vals [4]int
+	for i, key := range [4]string{"x", "y", "w", "h"} {
+		v, err := strconv.Atoi(strings.TrimSpace(md["lavfi.cropdetect."+key]))
+		if err != nil {
+			return CropRect{}, false
+		}
+		vals[i] = v
+	}
+	r := CropRect{X: vals[0], Y: vals[1], Width: vals[2], Height: vals[3]}
+	if r.Width <= 0 || r.Height <= 0 || r.X < 0 || r.Y < 0 {
+		return CropRect{}, false
+	}
+	return r, true
+}
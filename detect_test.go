@@ -0,0 +1,137 @@
+//go:build !ios && !android && (amd64 || arm64)
+
+package ffgo
+
+import (
+	"os/exec"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestRangeTracker(t *testing.T) {
+	tr := rangeTracker{startKey: "lavfi.black_start", endKey: "lavfi.black_end"}
+	tr.add(map[string]string{"lavfi.black_start": "0"}, 40*time.Millisecond)
+	tr.add(nil, 80*time.Millisecond)
+	tr.add(map[string]string{"lavfi.black_end": "0.5"}, 540*time.Millisecond)
+	tr.add(map[string]string{"lavfi.black_start": "1.0"}, 1040*time.Millisecond)
+	tr.add(map[string]string{"lavfi.black_end": "1.1"}, 1140*time.Millisecond)
+	tr.add(map[string]string{"lavfi.black_start": "2.25"}, 2290*time.Millisecond)
+	tr.add(nil, 3*time.Second)
+
+	got := tr.finish(200 * time.Millisecond)
+	want := []TimeRange{
+		{Start: 0, End: 500 * time.Millisecond},
+		{Start: 2250 * time.Millisecond, End: 3 * time.Second}, // still open at the end
+	}
+	if len(got) != len(want) {
+		t.Fatalf("ranges = %v, want %v", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("range %d = %v, want %v", i, got[i], want[i])
+		}
+	}
+}
+
+func TestDetectSilence(t *testing.T) {
+	if !requireFFmpeg(t) {
+		return
+	}
+	path := filepath.Join(t.TempDir(), "gap.wav")
+	cmd := exec.Command("ffmpeg", "-y",
+		"-f", "lavfi", "-i", "sine=frequency=440:duration=2:sample_rate=44100",
+		"-af", "volume=8,volume=enable='between(t,0.5,1.5)':volume=0",
+		"-c:a", "pcm_s16le", path)
+	if err := cmd.Run(); err != nil {
+		t.Skipf("ffmpeg CLI not available: %v", err)
+	}
+
+	dec, err := NewDecoder(path)
+	if err != nil {
+		t.Fatalf("NewDecoder failed: %v", err)
+	}
+	defer dec.Close()
+	ranges, err := dec.DetectSilence(-50, 500*time.Millisecond)
+	if err != nil {
+		t.Skipf("DetectSilence unavailable: %v", err)
+	}
+	if len(ranges) != 1 {
+		t.Fatalf("ranges = %v, want one silent range", ranges)
+	}
+	r := ranges[0]
+	if (r.Start-500*time.Millisecond).Abs() > 50*time.Millisecond || (r.End-1500*time.Millisecond).Abs() > 50*time.Millisecond {
+		t.Errorf("silence = %v, want about 0.5s-1.5s", r)
+	}
+}
+
+func TestDetectBlackFrames(t *testing.T) {
+	if !requireFFmpeg(t) {
+		return
+	}
+	dec, err := NewDecoder(createTestVideo(t))
+	if err != nil {
+		t.Fatalf("NewDecoder failed: %v", err)
+	}
+	defer dec.Close()
+
+	if _, err := dec.DetectBlackFrames(0, 2); err == nil {
+		t.Error("DetectBlackFrames with threshold 2 succeeded, want error")
+	}
+	ranges, err := dec.DetectBlackFrames(100*time.Millisecond, 0.1)
+	if err != nil {
+		t.Skipf("DetectBlackFrames unavailable: %v", err)
+	}
+	for _, r := range ranges {
+		if r.Duration() < 100*time.Millisecond {
+			t.Errorf("range %v is shorter than the minimum duration", r)
+		}
+	}
+}
+
+func TestParseCropMetadata(t *testing.T) {
+	md := map[string]string{
+		"lavfi.cropdetect.x1": "0", "lavfi.cropdetect.x2": "1919",
+		"lavfi.cropdetect.x": "0", "lavfi.cropdetect.y": "138",
+		"lavfi.cropdetect.w": "1920", "lavfi.cropdetect.h": "800",
+	}
+	r, ok := parseCropMetadata(md)
+	if !ok || r != (CropRect{X: 0, Y: 138, Width: 1920, Height: 800}) {
+		t.Errorf("parseCropMetadata = %+v, %v", r, ok)
+	}
+
+	delete(md, "lavfi.cropdetect.h")
+	if _, ok := parseCropMetadata(md); ok {
+		t.Error("incomplete metadata parsed")
+	}
+	md["lavfi.cropdetect.h"] = "-16" // cropdetect's "nothing found yet"
+	if _, ok := parseCropMetadata(md); ok {
+		t.Error("negative height accepted")
+	}
+}
+
+func TestDetectCrop(t *testing.T) {
+	if !requireFFmpeg(t) {
+		return
+	}
+	path := filepath.Join(t.TempDir(), "letterbox.mp4")
+	cmd := exec.Command("ffmpeg", "-y",
+		"-f", "lavfi", "-i", "testsrc2=size=320x180:rate=25:duration=1",
+		"-vf", "pad=320:240:0:30:black", "-pix_fmt", "yuv420p", path)
+	if err := cmd.Run(); err != nil {
+		t.Skipf("ffmpeg CLI not available: %v", err)
+	}
+
+	dec, err := NewDecoder(path)
+	if err != nil {
+		t.Fatalf("NewDecoder failed: %v", err)
+	}
+	defer dec.Close()
+	r, err := dec.DetectCrop(20)
+	if err != nil {
+		t.Skipf("DetectCrop unavailable: %v", err)
+	}
+	if r.Width != 320 || r.Height < 160 || r.Height > 192 || r.Y < 24 || r.Y > 40 {
+		t.Errorf("DetectCrop = %+v, want about {0 30 320 180}", r)
+	}
+}
@@ -4,8 +4,12 @@ package ffgo
 
 import (
 	"errors"
+	"fmt"
+	"sort"
+	"strconv"
 	"strings"
 	"sync"
+	"time"
 	"unsafe"
 
 	"github.com/obinnaokechukwu/ffgo/avcodec"
@@ -20,6 +24,7 @@ type Encoder struct {
 
 	formatCtx avformat.FormatContext
 	ioCtx     avformat.IOContext
+	customIO  *CustomIOContext // set for encoders writing through IOCallbacks
 	path      string
 
 	// Optional: used when I/O is opened lazily (e.g. network outputs) or needs avio_open2 options.
@@ -55,10 +60,20 @@ type Encoder struct {
 	width       int
 	height      int
 	pixFmt      PixelFormat
+	bufSize     int64 // VBV buffer size given to the video encoder
 	frameCount  int64
 	timeBaseNum int32
 	timeBaseDen int32
 
+	// Video timestamps: nextVideoPTS is the PTS WriteFrame assigns next;
+	// videoPTS maps source timestamps for WriteFrameWithPTS.
+	nextVideoPTS int64
+	videoPTS     *PTSMapper
+	// audioPTS maps source timestamps for WriteAudioFrameWithPTS.
+	audioPTS     *PTSMapper
+	useSourcePTS bool // keep caller-set frame PTS (EncoderOptions.UseSourcePTS)
+	forceKey     bool // next video frame must be a keyframe (ForceKeyframe)
+
 	// Audio properties
 	sampleRate    int
 	channels      int
@@ -69,6 +84,27 @@ type Encoder struct {
 	closed        bool
 	hasVideo      bool
 	hasAudio      bool
+
+	// Cover picture (SetCoverArt), written after the header.
+	coverArt    []byte
+	coverStream avformat.Stream
+
+	// Output reconnection (StreamingOptions.Reconnect); formatName is the
+	// muxer used to rebuild the output context.
+	formatName string
+	reconnect  *reconnectPolicy
+
+	// Wall-clock pacing (StreamingOptions.Realtime)
+	pacer *realtimePacer
+
+	// Progress reporting (EncoderOptions.OnProgress)
+	onProgress      func(encoded time.Duration)
+	progress        time.Duration
+	progressPending bool
+
+	// logCapture attributes FFmpeg log messages to this encoder
+	// (EncoderOptions.CaptureLog); nil when not capturing.
+	logCapture *logCapture
 }
 
 // EncoderConfig configures encoder behavior (video-only, for compatibility).
@@ -159,11 +195,16 @@ type VideoEncoderConfig struct {
 	MinBitrate int64
 
 	// MaxBitrate is the maximum bitrate for VBV (bits/second).
-	// Used for rate-constrained encoding.
+	// Used for rate-constrained encoding. Combined with RateControlCRF it
+	// caps the bitrate of constant-quality encodes (capped CRF).
+	// x264/x265 ignore it without a VBV buffer, so BufferSize defaults to
+	// 2*MaxBitrate; Encoder.VideoBufferSize reports the size used.
 	MaxBitrate int64
 
 	// BufferSize is the VBV buffer size (bits).
 	// Controls rate variation. Larger = more variation allowed.
+	// With RateControlCBR, defaults to Bitrate/2; otherwise, when
+	// MaxBitrate is set, to 2*MaxBitrate.
 	BufferSize int64
 
 	// BFrameStrategy controls B-frame placement (0-2).
@@ -182,6 +223,20 @@ type VideoEncoderConfig struct {
 	// Keys and values are passed directly to av_opt_set.
 	// Example: {"x264-params": "rc-lookahead=40"}
 	CodecOptions map[string]string
+
+	// DisplayAspectRatio is the shape the video should be shown at (e.g. 16/9).
+	// When set, the sample aspect ratio is derived from the coded Width/Height
+	// and written to both the codec and the stream, so anamorphic encodes
+	// (e.g. 1440x1080 displayed as 16:9) play back correctly.
+	// Zero value leaves square pixels.
+	DisplayAspectRatio Rational
+
+	// SampleAspectRatio is the pixel shape written to the codec and the
+	// stream, typically copied from the source's
+	// StreamInfo.SampleAspectRatio so anamorphic video (e.g. 720x480 DVD)
+	// keeps its display shape. DisplayAspectRatio takes precedence when
+	// both are set. Zero value leaves square pixels.
+	SampleAspectRatio Rational
 }
 
 // AudioEncoderConfig configures audio encoding parameters.
@@ -227,6 +282,42 @@ type EncoderOptions struct {
 	// MuxerOptions are passed to avformat_write_header.
 	MuxerOptions map[string]string
 
+	// HLS writes an HLS playlist plus segments using FFmpeg's "hls" muxer.
+	// It implies Format "hls"; its settings are translated into muxer
+	// options, and entries in MuxerOptions take precedence over them.
+	HLS *HLSOptions
+
+	// DASH writes an MPEG-DASH manifest plus segments using FFmpeg's "dash"
+	// muxer. It implies Format "dash"; like HLS, entries in MuxerOptions
+	// take precedence over the options derived from it.
+	DASH *DASHOptions
+
+	// MatroskaCues configures the Cues (seek index) of Matroska/WebM
+	// output. Like HLS, entries in MuxerOptions take precedence over the
+	// options derived from it; it is ignored for other containers.
+	MatroskaCues *MatroskaCueOptions
+
+	// Faststart moves the MP4/MOV index (moov atom) to the front of the file
+	// so playback can begin before the whole file is downloaded. It adds
+	// "+faststart" to the "movflags" muxer option and is ignored for other
+	// containers. The trailer rewrites the file, so the output must be
+	// seekable (a regular file, or custom I/O with a Seek callback).
+	Faststart bool
+
+	// StrictOptions makes encoder construction fail if the encoder rejects
+	// any of the video options (Preset, Tune, Profile, Level, rate control,
+	// CodecOptions, ...), e.g. because of a typo or a preset name the codec
+	// doesn't use. By default rejected options are silently skipped.
+	StrictOptions bool
+
+	// UseSourcePTS makes WriteFrame and WriteAudioFrame keep a PTS already
+	// set on the frame instead of overwriting it with a running count. Video
+	// PTS must be in the encoder time base (1/FrameRate) and audio PTS in
+	// samples (1/SampleRate), increasing from frame to frame. Frames whose
+	// PTS is AV_NOPTS_VALUE are still numbered automatically, continuing
+	// after the last written frame.
+	UseSourcePTS bool
+
 	// Video contains video encoding settings. Required for video output when not copying.
 	Video *VideoEncoderConfig
 
@@ -240,6 +331,8 @@ type EncoderOptions struct {
 
 	// CopyAudio enables audio stream copy mode (no re-encoding).
 	// When true, SourceStreams.AudioParams must be set.
+	// Combined with Video, video frames are encoded while audio packets are
+	// copied; write the latter with WritePacketFromSource.
 	CopyAudio bool
 
 	// SourceStreams provides codec parameters from the source for stream copy.
@@ -257,6 +350,37 @@ type EncoderOptions struct {
 	// PassOutput optionally overrides the output path for pass 1.
 	// If empty, TwoPassTranscode will create a temporary file.
 	PassOutput string
+
+	// customIO routes output through user callbacks instead of opening path.
+	// Set by NewEncoderToIO.
+	customIO *CustomIOContext
+
+	// hw selects a hardware encoder by name and attaches its device and
+	// frames contexts. Set by NewHWEncoder.
+	hw *hwEncoderSetup
+
+	// reconnect re-opens the output after write errors and realtime paces
+	// video to wall-clock time. Set by WithStreamingOptions.
+	reconnect *reconnectPolicy
+	realtime  *realtimePacer
+
+	// srt holds StreamingOptions.SRT for NewStreamingEncoder to validate.
+	srt *SRTOptions
+
+	// OnProgress, if set, is called after frames (or copied packets) are
+	// written with the output position reached so far, derived from the
+	// timestamps assigned to the written data. It is called without the
+	// encoder lock held and only when the position advances.
+	OnProgress func(encoded time.Duration)
+
+	// CaptureLog keeps the warnings and errors FFmpeg logs for this
+	// encoder's muxer and codecs: errors returned while opening the codecs,
+	// writing the header, encoding and writing the trailer carry the message
+	// logged during the failing call, and LastLogMessage returns the latest
+	// one. Messages are still passed to any SetLogCallback/SetLogHandler
+	// handler, or printed to stderr. Requires the ffshim library; without it
+	// nothing is captured.
+	CaptureLog bool
 }
 
 // NewEncoder creates a new video encoder.
@@ -401,6 +525,39 @@ func NewEncoderWithOptions(path string, opts *EncoderOptions) (*Encoder, error)
 		return nil, errors.New("ffgo: must specify Video config, Audio config, CopyVideo, or CopyAudio")
 	}
 
+	if opts.HLS != nil {
+		if opts.Format != "" && opts.Format != "hls" {
+			return nil, fmt.Errorf("ffgo: HLS options require the hls format, not %q", opts.Format)
+		}
+		if err := opts.HLS.validate(); err != nil {
+			return nil, err
+		}
+		withFormat := *opts
+		withFormat.Format = "hls"
+		opts = &withFormat
+	}
+	if opts.DASH != nil {
+		if opts.HLS != nil {
+			return nil, errors.New("ffgo: HLS and DASH options are mutually exclusive")
+		}
+		if opts.Format != "" && opts.Format != "dash" {
+			return nil, fmt.Errorf("ffgo: DASH options require the dash format, not %q", opts.Format)
+		}
+		streams := 0
+		if hasVideoEncode || hasVideoCopy {
+			streams++
+		}
+		if hasAudioEncode || hasAudioCopy {
+			streams++
+		}
+		if err := opts.DASH.validate(streams); err != nil {
+			return nil, err
+		}
+		withFormat := *opts
+		withFormat.Format = "dash"
+		opts = &withFormat
+	}
+
 	// Validate stream copy options
 	if hasVideoCopy && (opts.SourceStreams == nil || opts.SourceStreams.VideoParams == nil) {
 		return nil, errors.New("ffgo: SourceStreams.VideoParams required when CopyVideo is true")
@@ -414,10 +571,14 @@ func NewEncoderWithOptions(path string, opts *EncoderOptions) (*Encoder, error)
 		return nil, err
 	}
 
-	// Handle stream copy mode
-	if hasVideoCopy || hasAudioCopy {
+	// Handle stream copy mode. Encoded video with copied audio is handled by
+	// the encoding path below.
+	if hasVideoCopy || (hasAudioCopy && !hasVideoEncode) {
 		return newEncoderStreamCopy(path, opts)
 	}
+	if hasAudioCopy && hasAudioEncode {
+		return nil, errors.New("ffgo: Audio and CopyAudio are mutually exclusive")
+	}
 
 	// Clone video config so we can safely inject encoder-specific options (e.g. 2-pass for libx265)
 	// without mutating caller-owned config.
@@ -436,13 +597,27 @@ func NewEncoderWithOptions(path string, opts *EncoderOptions) (*Encoder, error)
 	if codecID == CodecIDNone {
 		codecID = CodecIDH264
 	}
+	// ProRes and FFV1 are intra-only archival codecs: they need high bit depth
+	// formats and are configured by profile/level instead of a target bitrate.
+	archival := isArchivalCodec(codecID)
+	if archival {
+		var err error
+		if pixFmt, err = archivalPixelFormat(codecID, video.PixelFormat, video.Profile); err != nil {
+			return nil, err
+		}
+		applyArchivalDefaults(codecID, video, pixFmt)
+	}
 	bitrate := video.Bitrate
-	if bitrate <= 0 && video.RateControl != RateControlCRF && video.RateControl != RateControlCQP {
+	if bitrate <= 0 && !archival && video.RateControl != RateControlCRF && video.RateControl != RateControlCQP {
 		bitrate = 2000000
 	}
+	video.Bitrate = bitrate // effective target, used by applyVideoOptions for CBR
 	gopSize := video.GOPSize
 	if gopSize <= 0 {
 		gopSize = 12
+		if archival {
+			gopSize = 1
+		}
 	}
 
 	// Handle frame rate
@@ -454,15 +629,20 @@ func NewEncoderWithOptions(path string, opts *EncoderOptions) (*Encoder, error)
 	}
 
 	e := &Encoder{
-		width:         video.Width,
-		height:        video.Height,
-		pixFmt:        pixFmt,
-		timeBaseNum:   1,
-		timeBaseDen:   int32(frameRateNum / frameRateDen),
-		hasVideo:      true,
-		path:          path,
-		ioOptions:     opts.IOOptions,
-		headerOptions: opts.MuxerOptions,
+		width:       video.Width,
+		height:      video.Height,
+		pixFmt:      pixFmt,
+		timeBaseNum: 1,
+		timeBaseDen: int32(frameRateNum / frameRateDen),
+		hasVideo:    true,
+		path:        path,
+		ioOptions:   opts.IOOptions,
+		onProgress:  opts.OnProgress,
+
+		useSourcePTS: opts.UseSourcePTS,
+
+		videoStreamIdx: -1,
+		audioStreamIdx: -1,
 	}
 
 	// Determine output format (optionally forced).
@@ -473,14 +653,28 @@ func NewEncoderWithOptions(path string, opts *EncoderOptions) (*Encoder, error)
 	if formatName == "" {
 		return nil, errors.New("ffgo: cannot determine output format from filename")
 	}
+	e.headerOptions = muxerHeaderOptions(formatName, opts)
+	e.formatName = formatName
+	e.reconnect = opts.reconnect
+	if opts.realtime != nil {
+		e.pacer = &realtimePacer{maxAhead: opts.realtime.maxAhead}
+	}
 
 	// Create output format context
 	if err := avformat.AllocOutputContext2(&e.formatCtx, nil, formatName, path); err != nil {
 		return nil, err
 	}
+	if opts.CaptureLog {
+		e.startLogCapture()
+	}
 
 	// Find encoder
-	codec := avcodec.FindEncoder(codecID)
+	var codec avcodec.Codec
+	if opts.hw != nil {
+		codec = avcodec.FindEncoderByName(opts.hw.codecName)
+	} else {
+		codec = avcodec.FindEncoder(codecID)
+	}
 	if codec == nil {
 		e.cleanup()
 		return nil, errors.New("ffgo: encoder not found")
@@ -518,6 +712,7 @@ func NewEncoderWithOptions(path string, opts *EncoderOptions) (*Encoder, error)
 		return nil, errors.New("ffgo: failed to allocate codec context")
 	}
 	e.codecCtx = e.videoCodecCtx // Backward compatibility
+	e.logCapture.watch(e.videoCodecCtx)
 
 	// Configure basic codec context parameters
 	avcodec.SetCtxWidth(e.codecCtx, int32(video.Width))
@@ -528,16 +723,29 @@ func NewEncoderWithOptions(path string, opts *EncoderOptions) (*Encoder, error)
 	avcodec.SetCtxGopSize(e.codecCtx, int32(gopSize))
 	avcodec.SetCtxMaxBFrames(e.codecCtx, int32(video.MaxBFrames))
 
+	// Derive the sample aspect ratio from the requested display aspect
+	// ratio, or use the one given explicitly
+	var sar Rational
+	if video.DisplayAspectRatio.Num > 0 && video.DisplayAspectRatio.Den > 0 {
+		sar = sampleAspectRatioForDisplay(video.DisplayAspectRatio, video.Width, video.Height)
+	} else if video.SampleAspectRatio.Num > 0 && video.SampleAspectRatio.Den > 0 {
+		sar = reduceRational(int64(video.SampleAspectRatio.Num), int64(video.SampleAspectRatio.Den))
+	}
+	if sar.Num > 0 {
+		avcodec.SetCtxSampleAspectRatio(e.codecCtx, sar.Num, sar.Den)
+	}
+
 	// Set bitrate for ABR/CBR modes
 	if bitrate > 0 {
 		avcodec.SetCtxBitRate(e.codecCtx, bitrate)
 	}
 
 	// Apply advanced codec options via av_opt_set (before opening codec)
-	if err := applyVideoOptions(unsafe.Pointer(e.codecCtx), video); err != nil {
+	if err := applyVideoOptions(unsafe.Pointer(e.codecCtx), video, opts.StrictOptions); err != nil {
 		e.cleanup()
 		return nil, err
 	}
+	e.bufSize = videoBufferSize(video)
 
 	// Set global header flag if needed by container format
 	if avformat.NeedsGlobalHeader(e.formatCtx) {
@@ -557,6 +765,12 @@ func NewEncoderWithOptions(path string, opts *EncoderOptions) (*Encoder, error)
 		avcodec.SetCtxFlags(e.codecCtx, flags)
 	}
 
+	// Hardware encoders need the device and frames contexts before opening
+	if opts.hw != nil {
+		avcodec.SetCtxHWDeviceCtx(e.codecCtx, opts.hw.device)
+		avcodec.SetCtxHWFramesCtx(e.codecCtx, opts.hw.frames)
+	}
+
 	// Open codec (pass pass/passlogfile via AVDictionary** to ensure the encoder's
 	// private options (e.g. libx264/libx265) are applied before priv_data is allocated).
 	var openDict avutil.Dictionary
@@ -581,7 +795,9 @@ func NewEncoderWithOptions(path string, opts *EncoderOptions) (*Encoder, error)
 	}
 
 	// Open codec
+	mark := e.logCapture.mark()
 	if err := avcodec.Open2(e.codecCtx, codec, &openDict); err != nil {
+		err = e.logCapture.annotate(err, mark)
 		if openDict != nil {
 			avutil.DictFree(&openDict)
 		}
@@ -601,9 +817,14 @@ func NewEncoderWithOptions(path string, opts *EncoderOptions) (*Encoder, error)
 
 	// Set stream time base
 	avformat.SetStreamTimeBase(e.stream, 1, int32(frameRateNum/frameRateDen))
+	if sar.Num > 0 {
+		avformat.SetStreamSampleAspectRatio(e.stream, sar.Num, sar.Den)
+	}
 
 	// Open output file if needed
-	if !avformat.HasNoFile(e.formatCtx) {
+	if opts.customIO != nil {
+		e.attachCustomIO(opts.customIO)
+	} else if !avformat.HasNoFile(e.formatCtx) {
 		// For network-style outputs (or when IOOptions are provided), open lazily on header write.
 		// This avoids connecting during encoder construction.
 		if !looksLikeURL(path) && len(opts.IOOptions) == 0 {
@@ -629,6 +850,11 @@ func NewEncoderWithOptions(path string, opts *EncoderOptions) (*Encoder, error)
 			e.Close()
 			return nil, err
 		}
+	} else if opts.CopyAudio {
+		if err := e.setupAudioCopy(opts.SourceStreams); err != nil {
+			e.Close()
+			return nil, err
+		}
 	}
 
 	return e, nil
@@ -659,7 +885,7 @@ func (e *Encoder) ensureIOOpenLocked() error {
 	if avformat.HasNoFile(e.formatCtx) {
 		return nil
 	}
-	if e.ioCtx != nil {
+	if e.ioCtx != nil || e.customIO != nil {
 		return nil
 	}
 	if e.path == "" {
@@ -698,6 +924,52 @@ func (e *Encoder) ensureIOOpenLocked() error {
 	return nil
 }
 
+// muxerHeaderOptions returns the options passed to avformat_write_header,
+// merging derived options such as Faststart, HLS, DASH and MatroskaCues into
+// a copy of opts.MuxerOptions.
+func muxerHeaderOptions(formatName string, opts *EncoderOptions) map[string]string {
+	faststart := opts.Faststart && isMOVFamilyFormat(formatName)
+	var derived map[string]string
+	switch {
+	case opts.HLS != nil && formatName == "hls":
+		derived = opts.HLS.muxerOptions()
+	case opts.DASH != nil && formatName == "dash":
+		derived = opts.DASH.muxerOptions()
+	case opts.MatroskaCues != nil && (formatName == "matroska" || formatName == "webm"):
+		derived = opts.MatroskaCues.muxerOptions()
+	}
+	if !faststart && derived == nil {
+		return opts.MuxerOptions
+	}
+
+	out := make(map[string]string, len(derived)+len(opts.MuxerOptions)+1)
+	for k, v := range derived {
+		out[k] = v
+	}
+	for k, v := range opts.MuxerOptions {
+		out[k] = v
+	}
+	if !faststart {
+		return out
+	}
+	movflags := out["movflags"]
+	if !strings.Contains(movflags, "faststart") {
+		movflags += "+faststart"
+	}
+	out["movflags"] = movflags
+	return out
+}
+
+// isMOVFamilyFormat reports whether formatName is handled by FFmpeg's mov
+// muxer, which is the one that understands "movflags".
+func isMOVFamilyFormat(formatName string) bool {
+	switch formatName {
+	case "mp4", "mov", "ipod", "ismv", "3gp", "3g2", "psp", "f4v":
+		return true
+	}
+	return false
+}
+
 func (e *Encoder) writeHeaderLocked() error {
 	if e.headerWritten {
 		return nil
@@ -724,11 +996,12 @@ func (e *Encoder) writeHeaderLocked() error {
 		}
 	}()
 
+	mark := e.logCapture.mark()
 	if err := avformat.WriteHeader(e.formatCtx, &dict); err != nil {
-		return err
+		return e.logCapture.annotate(err, mark)
 	}
 	e.headerWritten = true
-	return nil
+	return e.writeCoverArtLocked()
 }
 
 // newEncoderStreamCopy creates an encoder in stream copy mode.
@@ -753,15 +1026,17 @@ func newEncoderStreamCopy(path string, opts *EncoderOptions) (*Encoder, error) {
 		audioStreamIdx: -1,
 		path:           path,
 		ioOptions:      opts.IOOptions,
-		headerOptions:  opts.MuxerOptions,
+		headerOptions:  muxerHeaderOptions(formatName, opts),
+		onProgress:     opts.OnProgress,
 	}
 
 	// Create output format context
 	if err := avformat.AllocOutputContext2(&e.formatCtx, nil, formatName, path); err != nil {
 		return nil, err
 	}
-
-	streamIdx := 0
+	if opts.CaptureLog {
+		e.startLogCapture()
+	}
 
 	// Setup video stream for copy mode
 	if opts.CopyVideo && opts.SourceStreams != nil && opts.SourceStreams.VideoParams != nil {
@@ -772,8 +1047,7 @@ func newEncoderStreamCopy(path string, opts *EncoderOptions) (*Encoder, error) {
 			return nil, errors.New("ffgo: failed to create video stream for copy")
 		}
 		e.videoStream = stream
-		e.videoStreamIdx = streamIdx
-		streamIdx++
+		e.videoStreamIdx = int(avformat.GetStreamIndex(stream))
 
 		// Copy codec parameters from source
 		codecPar := avformat.GetStreamCodecPar(stream)
@@ -789,26 +1063,10 @@ func newEncoderStreamCopy(path string, opts *EncoderOptions) (*Encoder, error) {
 
 	// Setup audio stream for copy mode
 	if opts.CopyAudio && opts.SourceStreams != nil && opts.SourceStreams.AudioParams != nil {
-		// Create stream without codec
-		stream := avformat.NewStream(e.formatCtx, nil)
-		if stream == nil {
+		if err := e.setupAudioCopy(opts.SourceStreams); err != nil {
 			e.cleanup()
-			return nil, errors.New("ffgo: failed to create audio stream for copy")
-		}
-		e.audioStream = stream
-		e.audioStreamIdx = streamIdx
-		// Note: streamIdx++ omitted since this is the last stream
-
-		// Copy codec parameters from source
-		codecPar := avformat.GetStreamCodecPar(stream)
-		if err := avcodec.ParametersCopy(codecPar, opts.SourceStreams.AudioParams); err != nil {
-			e.cleanup()
-			return nil, errors.New("ffgo: failed to copy audio codec parameters")
+			return nil, err
 		}
-
-		// Store time base for timestamp rescaling
-		e.audioTimeBase = opts.SourceStreams.AudioTimeBase
-		e.hasAudio = true
 	}
 
 	// Setup audio encoding if CopyVideo but encoding audio
@@ -820,7 +1078,9 @@ func newEncoderStreamCopy(path string, opts *EncoderOptions) (*Encoder, error) {
 	}
 
 	// Open output file if needed
-	if !avformat.HasNoFile(e.formatCtx) {
+	if opts.customIO != nil {
+		e.attachCustomIO(opts.customIO)
+	} else if !avformat.HasNoFile(e.formatCtx) {
 		if !looksLikeURL(path) && len(opts.IOOptions) == 0 {
 			if err := avformat.IOOpen(&e.ioCtx, path, avformat.IOFlagWrite); err != nil {
 				e.cleanup()
@@ -841,10 +1101,14 @@ func newEncoderStreamCopy(path string, opts *EncoderOptions) (*Encoder, error) {
 }
 
 // WritePacket writes a packet directly to the output (for stream copy mode).
-// The packet's stream index should match the source stream.
-// For video packets, set streamIndex to match the source video stream.
-// For audio packets, set streamIndex to match the source audio stream.
+// Packets with stream index 0 go to the copied video stream (if any); all
+// other packets go to the copied audio stream. Timestamps are rescaled from
+// the source time bases given in EncoderOptions.SourceStreams.
+//
+// For explicit control over the target stream, e.g. when mixing encoded
+// video with copied audio, use WritePacketFromSource.
 func (e *Encoder) WritePacket(packet *Packet) error {
+	defer e.emitProgress()
 	e.mu.Lock()
 	defer e.mu.Unlock()
 
@@ -860,39 +1124,77 @@ func (e *Encoder) WritePacket(packet *Packet) error {
 		return errors.New("ffgo: packet cannot be nil")
 	}
 
-	// Write header if not yet written
-	if !e.headerWritten {
-		if err := e.writeHeaderLocked(); err != nil {
-			return err
-		}
-	}
-
-	// Determine output stream index based on packet media type
+	// Map the source stream to an output copy stream
 	packetStreamIdx := avcodec.GetPacketStreamIndex(packet.ptr)
 
 	var outputStreamIdx int
-	var srcTimeBase, dstTimeBase Rational
-
-	// Check if this is a video or audio packet based on context
-	// In copy mode, we need to map source stream to output stream
+	var srcTimeBase Rational
 	if e.copyVideo && e.videoStreamIdx >= 0 && packetStreamIdx == 0 {
-		// Video packet
 		outputStreamIdx = e.videoStreamIdx
 		srcTimeBase = e.videoTimeBase
-		stream := avformat.GetStream(e.formatCtx, e.videoStreamIdx)
-		tbNum, tbDen := avformat.GetStreamTimeBase(stream)
-		dstTimeBase = NewRational(tbNum, tbDen)
 	} else if e.copyAudio && e.audioStreamIdx >= 0 {
-		// Audio packet
 		outputStreamIdx = e.audioStreamIdx
 		srcTimeBase = e.audioTimeBase
-		stream := avformat.GetStream(e.formatCtx, e.audioStreamIdx)
-		tbNum, tbDen := avformat.GetStreamTimeBase(stream)
-		dstTimeBase = NewRational(tbNum, tbDen)
 	} else {
 		return errors.New("ffgo: cannot determine output stream for packet")
 	}
 
+	return e.writeCopyPacketLocked(packet, outputStreamIdx, srcTimeBase)
+}
+
+// WritePacketFromSource writes an already-encoded packet to output stream
+// outputStreamIndex, rescaling its timestamps from srcTimeBase (the time base
+// of the stream the packet was read from) to the output stream's time base.
+//
+// The target stream must have been created as a copy stream (CopyVideo or
+// CopyAudio). This works alongside frame encoding: an encoder created with
+// Video and CopyAudio takes encoded frames via WriteFrame and copied audio
+// packets via WritePacketFromSource in the same output.
+func (e *Encoder) WritePacketFromSource(packet *Packet, outputStreamIndex int, srcTimeBase Rational) error {
+	defer e.emitProgress()
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	if e.closed {
+		return errors.New("ffgo: encoder is closed")
+	}
+	if packet == nil || packet.ptr == nil {
+		return errors.New("ffgo: packet cannot be nil")
+	}
+	if srcTimeBase.Num <= 0 || srcTimeBase.Den <= 0 {
+		return errors.New("ffgo: invalid source time base")
+	}
+	if outputStreamIndex < 0 || outputStreamIndex >= avformat.GetNumStreams(e.formatCtx) {
+		return fmt.Errorf("ffgo: output stream index %d out of range", outputStreamIndex)
+	}
+	isCopy := (e.copyVideo && outputStreamIndex == e.videoStreamIdx) ||
+		(e.copyAudio && outputStreamIndex == e.audioStreamIdx)
+	if !isCopy {
+		return fmt.Errorf("ffgo: output stream %d is not a stream copy stream", outputStreamIndex)
+	}
+
+	return e.writeCopyPacketLocked(packet, outputStreamIndex, srcTimeBase)
+}
+
+// writeCopyPacketLocked rescales packet from srcTimeBase to the time base of
+// output stream outputStreamIdx and writes it. Must be called with e.mu held.
+func (e *Encoder) writeCopyPacketLocked(packet *Packet, outputStreamIdx int, srcTimeBase Rational) error {
+	// Write header if not yet written (the muxer may adjust stream time bases)
+	if !e.headerWritten {
+		if err := e.writeHeaderLocked(); err != nil {
+			return err
+		}
+	}
+
+	stream := avformat.GetStream(e.formatCtx, outputStreamIdx)
+	tbNum, tbDen := avformat.GetStreamTimeBase(stream)
+	dstTimeBase := NewRational(tbNum, tbDen)
+
+	// Track progress from the source timestamps before rescaling
+	if pts := avcodec.GetPacketPTS(packet.ptr); pts != avutil.AV_NOPTS_VALUE {
+		e.advanceProgressLocked(pts+avcodec.GetPacketDuration(packet.ptr), srcTimeBase)
+	}
+
 	// Rescale timestamps
 	avcodec.RescalePacketTS(packet.ptr, srcTimeBase, dstTimeBase)
 
@@ -903,92 +1205,278 @@ func (e *Encoder) WritePacket(packet *Packet) error {
 	return avformat.InterleavedWriteFrame(e.formatCtx, packet.ptr)
 }
 
+// advanceProgressLocked records that output has been written up to ts (in
+// time base tb). Must be called with e.mu held.
+func (e *Encoder) advanceProgressLocked(ts int64, tb Rational) {
+	if e.onProgress == nil || ts == avutil.AV_NOPTS_VALUE || tb.Num <= 0 || tb.Den <= 0 {
+		return
+	}
+	pos := time.Duration(float64(ts) * float64(tb.Num) / float64(tb.Den) * float64(time.Second))
+	if pos > e.progress {
+		e.progress = pos
+		e.progressPending = true
+	}
+}
+
+// emitProgress invokes the OnProgress callback if the position advanced.
+// It must be called without e.mu held so the callback may use the encoder.
+func (e *Encoder) emitProgress() {
+	e.mu.Lock()
+	cb := e.onProgress
+	pos := e.progress
+	pending := e.progressPending
+	e.progressPending = false
+	e.mu.Unlock()
+
+	if cb != nil && pending {
+		cb(pos)
+	}
+}
+
 // applyVideoOptions applies advanced video encoding options via av_opt_set.
 // This must be called BEFORE avcodec_open2.
-func applyVideoOptions(ctx unsafe.Pointer, cfg *VideoEncoderConfig) error {
+//
+// By default options the encoder rejects are skipped. With strict set, the
+// first rejected option is returned as an error (best-effort options such as
+// x264's nal-hrd are still skipped).
+func applyVideoOptions(ctx unsafe.Pointer, cfg *VideoEncoderConfig, strict bool) error {
 	if ctx == nil {
 		return nil
 	}
 
-	// Preset (speed/quality tradeoff)
-	if cfg.Preset != "" {
-		if err := avutil.OptSet(ctx, "preset", string(cfg.Preset), avutil.AV_OPT_SEARCH_CHILDREN); err != nil {
-			// Some codecs don't support preset, ignore error
+	for _, opt := range videoCodecOptions(cfg) {
+		if err := avutil.OptSet(ctx, opt.name, opt.value, avutil.AV_OPT_SEARCH_CHILDREN); err != nil {
+			if strict && !opt.bestEffort {
+				return fmt.Errorf("ffgo: encoder rejected option %s=%q: %w", opt.name, opt.value, err)
+			}
+			// Not every encoder supports every option (e.g. preset); skip
 			_ = err
 		}
 	}
 
+	return nil
+}
+
+// videoBufferSize returns the VBV buffer size applyVideoOptions sets for
+// cfg, or 0 for none. Without an explicit BufferSize, CBR gets half a second
+// of data, and a MaxBitrate gets two seconds at that rate since x264/x265
+// ignore maxrate without a VBV buffer.
+func videoBufferSize(cfg *VideoEncoderConfig) int64 {
+	switch {
+	case cfg.BufferSize > 0:
+		return cfg.BufferSize
+	case cfg.RateControl == RateControlCBR && cfg.Bitrate > 0:
+		return cfg.Bitrate / 2
+	case cfg.MaxBitrate > 0:
+		return 2 * cfg.MaxBitrate
+	}
+	return 0
+}
+
+// codecOption is a single AVOption assignment made by applyVideoOptions.
+type codecOption struct {
+	name  string
+	value string
+
+	// bestEffort options are encoder-specific extras that are skipped even
+	// in strict mode when the encoder doesn't know them.
+	bestEffort bool
+}
+
+// videoCodecOptions returns the AVOptions applyVideoOptions sets for cfg, in
+// the order they are applied. Unknown options are ignored by the encoder, so
+// the list is codec-independent.
+func videoCodecOptions(cfg *VideoEncoderConfig) []codecOption {
+	var opts []codecOption
+	add := func(name, value string) {
+		opts = append(opts, codecOption{name: name, value: value})
+	}
+	addInt := func(name string, value int64) {
+		add(name, strconv.FormatInt(value, 10))
+	}
+
+	// Preset (speed/quality tradeoff)
+	if cfg.Preset != "" {
+		add("preset", string(cfg.Preset))
+	}
+
 	// Tune (content-specific optimization)
 	if cfg.Tune != "" {
-		if err := avutil.OptSet(ctx, "tune", string(cfg.Tune), avutil.AV_OPT_SEARCH_CHILDREN); err != nil {
-			_ = err
-		}
+		add("tune", string(cfg.Tune))
 	}
 
 	// Profile
 	if cfg.Profile != "" {
-		if err := avutil.OptSet(ctx, "profile", string(cfg.Profile), avutil.AV_OPT_SEARCH_CHILDREN); err != nil {
-			_ = err
-		}
+		add("profile", string(cfg.Profile))
 	}
 
 	// Level
 	if cfg.Level != "" {
-		if err := avutil.OptSet(ctx, "level", string(cfg.Level), avutil.AV_OPT_SEARCH_CHILDREN); err != nil {
-			_ = err
-		}
+		add("level", string(cfg.Level))
 	}
 
 	// Rate control
 	switch cfg.RateControl {
 	case RateControlCRF:
 		if cfg.CRF > 0 {
-			if err := avutil.OptSetInt(ctx, "crf", int64(cfg.CRF), avutil.AV_OPT_SEARCH_CHILDREN); err != nil {
-				_ = err
-			}
+			addInt("crf", int64(cfg.CRF))
 		}
 	case RateControlCQP:
 		if cfg.CQP > 0 {
-			if err := avutil.OptSetInt(ctx, "qp", int64(cfg.CQP), avutil.AV_OPT_SEARCH_CHILDREN); err != nil {
-				_ = err
-			}
+			addInt("qp", int64(cfg.CQP))
 		}
 	}
 
-	// VBV buffer settings (for CBR/constrained VBR)
-	if cfg.MaxBitrate > 0 {
-		if err := avutil.OptSetInt(ctx, "maxrate", cfg.MaxBitrate, avutil.AV_OPT_SEARCH_CHILDREN); err != nil {
-			_ = err
+	// VBV buffer settings
+	bufSize := videoBufferSize(cfg)
+	if cfg.RateControl == RateControlCBR && cfg.Bitrate > 0 {
+		// True CBR: pin min/max rate to the target and enforce it with a VBV
+		// buffer. MaxBitrate is ignored.
+		addInt("minrate", cfg.Bitrate)
+		addInt("maxrate", cfg.Bitrate)
+		addInt("bufsize", bufSize)
+		// x264 pads the stream with filler data to keep the rate constant;
+		// other encoders don't have this option.
+		opts = append(opts, codecOption{name: "nal-hrd", value: "cbr", bestEffort: true})
+	} else {
+		// Constrained VBR, or capped CRF when combined with RateControlCRF.
+		if cfg.MaxBitrate > 0 {
+			addInt("maxrate", cfg.MaxBitrate)
 		}
-	}
-	if cfg.BufferSize > 0 {
-		if err := avutil.OptSetInt(ctx, "bufsize", cfg.BufferSize, avutil.AV_OPT_SEARCH_CHILDREN); err != nil {
-			_ = err
+		if bufSize > 0 {
+			addInt("bufsize", bufSize)
 		}
 	}
 
 	// Reference frames
 	if cfg.RefFrames > 0 {
-		if err := avutil.OptSetInt(ctx, "refs", int64(cfg.RefFrames), avutil.AV_OPT_SEARCH_CHILDREN); err != nil {
-			_ = err
-		}
+		addInt("refs", int64(cfg.RefFrames))
 	}
 
 	// Threading
 	if cfg.Threads > 0 {
-		if err := avutil.OptSetInt(ctx, "threads", int64(cfg.Threads), avutil.AV_OPT_SEARCH_CHILDREN); err != nil {
-			_ = err
+		addInt("threads", int64(cfg.Threads))
+	}
+
+	// Custom codec options, in a stable order
+	keys := make([]string, 0, len(cfg.CodecOptions))
+	for key := range cfg.CodecOptions {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		add(key, cfg.CodecOptions[key])
+	}
+
+	return opts
+}
+
+// sampleAspectRatioForDisplay returns the sample aspect ratio that makes a
+// width x height picture display at dar (SAR = DAR * height / width).
+func sampleAspectRatioForDisplay(dar Rational, width, height int) Rational {
+	if width <= 0 || height <= 0 {
+		return Rational{Num: 1, Den: 1}
+	}
+	return reduceRational(int64(dar.Num)*int64(height), int64(dar.Den)*int64(width))
+}
+
+// reduceRational returns num/den in lowest terms. Both must be positive.
+func reduceRational(num, den int64) Rational {
+	a, b := num, den
+	for b != 0 {
+		a, b = b, a%b
+	}
+	return Rational{Num: int32(num / a), Den: int32(den / a)}
+}
+
+// isArchivalCodec reports whether codecID is an intra-only mastering or
+// archival codec (ProRes, FFV1).
+func isArchivalCodec(codecID CodecID) bool {
+	return codecID == CodecIDProRes || codecID == CodecIDFFV1
+}
+
+// archivalPixelFormat picks and validates the pixel format for ProRes/FFV1.
+//
+// ProRes only accepts 10-bit 4:2:2/4:4:4 input, so the zero value
+// (PixelFormatYUV420P) is treated as "unset" and replaced by a format matching
+// the profile. FFV1 accepts almost any format, including 8-bit, so the
+// caller's choice is kept unless it is PixelFormatNone.
+func archivalPixelFormat(codecID CodecID, pixFmt PixelFormat, profile VideoProfile) (PixelFormat, error) {
+	switch codecID {
+	case CodecIDProRes:
+		switch pixFmt {
+		case PixelFormatNone, PixelFormatYUV420P:
+			if profile == ProfileProRes4444 || profile == ProfileProRes4444XQ {
+				return PixelFormatYUV444P10LE, nil
+			}
+			return PixelFormatYUV422P10LE, nil
+		case PixelFormatYUV422P10LE, PixelFormatYUV444P10LE, PixelFormatYUVA444P10LE:
+			return pixFmt, nil
+		default:
+			return pixFmt, errors.New("ffgo: ProRes requires yuv422p10le, yuv444p10le or yuva444p10le input")
 		}
+	case CodecIDFFV1:
+		if pixFmt == PixelFormatNone {
+			return PixelFormatYUV422P10LE, nil
+		}
+		return pixFmt, nil
 	}
+	return pixFmt, nil
+}
 
-	// Custom codec options
-	for key, value := range cfg.CodecOptions {
-		if err := avutil.OptSet(ctx, key, value, avutil.AV_OPT_SEARCH_CHILDREN); err != nil {
-			// Don't fail on unknown options, just skip
-			_ = err
+// applyArchivalDefaults fills in the codec options ProRes/FFV1 need for
+// lossless or visually lossless output. Caller-provided values win.
+func applyArchivalDefaults(codecID CodecID, cfg *VideoEncoderConfig, pixFmt PixelFormat) {
+	// Copy so defaults never leak into the caller's map.
+	opts := make(map[string]string, len(cfg.CodecOptions)+2)
+	for k, v := range cfg.CodecOptions {
+		opts[k] = v
+	}
+
+	switch codecID {
+	case CodecIDProRes:
+		if cfg.Profile == "" {
+			if pixFmt == PixelFormatYUV422P10LE {
+				cfg.Profile = ProfileProResHQ
+			} else {
+				cfg.Profile = ProfileProRes4444
+			}
+		}
+	case CodecIDFFV1:
+		// Level 3 adds multithreaded slices; slice CRCs detect archive corruption.
+		if _, ok := opts["level"]; !ok && cfg.Level == "" {
+			opts["level"] = "3"
+		}
+		if _, ok := opts["slicecrc"]; !ok {
+			opts["slicecrc"] = "1"
 		}
 	}
 
+	cfg.CodecOptions = opts
+}
+
+// setupAudioCopy adds an output stream that receives audio packets copied
+// from the source described by src.
+func (e *Encoder) setupAudioCopy(src *StreamCopySource) error {
+	// Create stream without codec
+	stream := avformat.NewStream(e.formatCtx, nil)
+	if stream == nil {
+		return errors.New("ffgo: failed to create audio stream for copy")
+	}
+
+	// Copy codec parameters from source
+	codecPar := avformat.GetStreamCodecPar(stream)
+	if err := avcodec.ParametersCopy(codecPar, src.AudioParams); err != nil {
+		return errors.New("ffgo: failed to copy audio codec parameters")
+	}
+
+	e.audioStream = stream
+	e.audioStreamIdx = int(avformat.GetStreamIndex(stream))
+	e.copyAudio = true
+
+	// Store time base for timestamp rescaling
+	e.audioTimeBase = src.AudioTimeBase
+	e.hasAudio = true
 	return nil
 }
 
@@ -1029,6 +1517,7 @@ func (e *Encoder) setupAudio(cfg *AudioEncoderConfig) error {
 	if e.audioCodecCtx == nil {
 		return errors.New("ffgo: failed to allocate audio codec context")
 	}
+	e.logCapture.watch(e.audioCodecCtx)
 
 	// Configure audio codec context
 	avcodec.SetCtxSampleRate(e.audioCodecCtx, int32(sampleRate))
@@ -1044,7 +1533,10 @@ func (e *Encoder) setupAudio(cfg *AudioEncoderConfig) error {
 	}
 
 	// Open audio codec
+	mark := e.logCapture.mark()
 	if err := avcodec.Open2(e.audioCodecCtx, audioCodec, nil); err != nil {
+		err = e.logCapture.annotate(err, mark)
+		e.logCapture.unwatch(e.audioCodecCtx)
 		avcodec.FreeContext(&e.audioCodecCtx)
 		return err
 	}
@@ -1089,7 +1581,13 @@ func (e *Encoder) WriteHeader() error {
 
 // WriteFrame encodes and writes a frame.
 // The frame must have the correct format, width, and height.
+//
+// Frames are timestamped with consecutive PTS values in the encoder time
+// base (1/FrameRate), i.e. constant frame rate, overwriting any PTS already
+// on the frame unless EncoderOptions.UseSourcePTS is set. Use
+// WriteFrameWithPTS to keep the timing of frames from another time base.
 func (e *Encoder) WriteFrame(frame Frame) error {
+	defer e.emitProgress()
 	e.mu.Lock()
 	defer e.mu.Unlock()
 
@@ -1097,6 +1595,58 @@ func (e *Encoder) WriteFrame(frame Frame) error {
 		return errors.New("ffgo: encoder is closed")
 	}
 
+	pts := e.nextVideoPTS
+	if e.useSourcePTS && frame.ptr != nil {
+		if framePTS := avutil.GetFramePTS(frame.ptr); framePTS != avutil.AV_NOPTS_VALUE {
+			pts = framePTS
+		}
+	}
+	return e.writeVideoFrameLocked(frame, pts)
+}
+
+// WriteFrameWithPTS encodes and writes a frame, deriving its PTS from the
+// source timestamp srcPTS expressed in srcTimeBase (typically the decoder's
+// video stream time base) instead of counting frames. This preserves the
+// timing of variable-frame-rate input and keeps video aligned with audio.
+//
+// Timestamps are rescaled to the encoder time base with a PTSMapper, so
+// frames closer together than one encoder tick are pushed to the next tick.
+// A srcPTS of AV_NOPTS_VALUE continues from the previous frame.
+func (e *Encoder) WriteFrameWithPTS(frame Frame, srcPTS int64, srcTimeBase Rational) error {
+	if frame.ptr == nil {
+		return errors.New("ffgo: frame is nil; use Flush to drain the encoder")
+	}
+	if srcTimeBase.Num <= 0 || srcTimeBase.Den <= 0 {
+		return errors.New("ffgo: invalid source time base")
+	}
+
+	defer e.emitProgress()
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	if e.closed {
+		return errors.New("ffgo: encoder is closed")
+	}
+
+	if e.videoPTS == nil || e.videoPTS.SrcTimeBase() != srcTimeBase {
+		e.videoPTS = NewPTSMapper(srcTimeBase, NewRational(e.timeBaseNum, e.timeBaseDen))
+	}
+	e.videoPTS.SetMinPTS(e.nextVideoPTS)
+	return e.writeVideoFrameLocked(frame, e.videoPTS.Map(srcPTS))
+}
+
+// ForceKeyframe makes the next video frame passed to WriteFrame (or
+// WriteFrameWithPTS) a keyframe, independent of GOPSize. Use it to align
+// keyframes with scene cuts or segment boundaries. The GOP restarts there.
+func (e *Encoder) ForceKeyframe() {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.forceKey = true
+}
+
+// writeVideoFrameLocked stamps frame with pts (in the codec time base),
+// encodes it, and writes the resulting packets. A nil frame flushes.
+func (e *Encoder) writeVideoFrameLocked(frame Frame, pts int64) error {
 	// Auto-write header if not done
 	if !e.headerWritten {
 		if err := e.writeHeaderLocked(); err != nil {
@@ -1104,17 +1654,34 @@ func (e *Encoder) WriteFrame(frame Frame) error {
 		}
 	}
 
+	// Hold the frame back until its presentation time when pacing.
+	if frame.ptr != nil && e.pacer != nil {
+		e.pacer.wait(ptsToDuration(pts, NewRational(e.timeBaseNum, e.timeBaseDen)))
+	}
+
 	// Set frame PTS
 	if frame.ptr != nil {
-		avutil.SetFramePTS(frame.ptr, e.frameCount)
+		avutil.SetFramePTS(frame.ptr, pts)
 		e.frameCount++
+		e.nextVideoPTS = pts + 1
+		e.advanceProgressLocked(e.nextVideoPTS, NewRational(e.timeBaseNum, e.timeBaseDen))
+	}
+
+	// Request a keyframe by marking the frame as intra for this send only,
+	// so a caller-reused frame doesn't force every following frame too.
+	if frame.ptr != nil && e.forceKey {
+		prev := avutil.GetFramePictType(frame.ptr)
+		avutil.SetFramePictType(frame.ptr, avutil.PictureTypeI)
+		defer avutil.SetFramePictType(frame.ptr, prev)
+		e.forceKey = false
 	}
 
 	// Send frame to encoder
+	mark := e.logCapture.mark()
 	if err := avcodec.SendFrame(e.codecCtx, frame.ptr); err != nil {
 		// EAGAIN means we need to receive packets first
 		if !avutil.IsAgain(err) {
-			return err
+			return e.logCapture.annotate(err, mark)
 		}
 	}
 
@@ -1122,24 +1689,42 @@ func (e *Encoder) WriteFrame(frame Frame) error {
 	for {
 		avcodec.PacketUnref(e.packet)
 
+		mark := e.logCapture.mark()
 		err := avcodec.ReceivePacket(e.codecCtx, e.packet)
 		if err != nil {
 			if avutil.IsAgain(err) || avutil.IsEOF(err) {
 				return nil // No more packets available
 			}
-			return err
+			return e.logCapture.annotate(err, mark)
 		}
 
 		// Rescale timestamps
 		avcodec.SetPacketStreamIndex(e.packet, avformat.GetStreamIndex(e.stream))
+		e.rescaleVideoPacketLocked(e.packet)
 
-		// Write packet
+		// Write packet; after a successful reconnect the packet is dropped
+		// and encoding continues on the new connection.
 		if err := avformat.InterleavedWriteFrame(e.formatCtx, e.packet); err != nil {
-			return err
+			if err := e.reconnectLocked(err); err != nil {
+				return err
+			}
 		}
 	}
 }
 
+// rescaleVideoPacketLocked converts an encoded video packet from the codec
+// time base to the stream time base, which the muxer may have changed when
+// writing the header (e.g. MP4 uses a finer timescale).
+func (e *Encoder) rescaleVideoPacketLocked(pkt avcodec.Packet) {
+	streamTbNum, streamTbDen := avformat.GetStreamTimeBase(e.stream)
+	if streamTbNum <= 0 || streamTbDen <= 0 || (streamTbNum == e.timeBaseNum && streamTbDen == e.timeBaseDen) {
+		return
+	}
+	avcodec.RescalePacketTS(pkt,
+		avutil.NewRational(e.timeBaseNum, e.timeBaseDen),
+		avutil.NewRational(streamTbNum, streamTbDen))
+}
+
 // WriteVideoFrame encodes and writes a video frame.
 // This is an alias for WriteFrame for semantic clarity.
 func (e *Encoder) WriteVideoFrame(frame Frame) error {
@@ -1148,6 +1733,44 @@ func (e *Encoder) WriteVideoFrame(frame Frame) error {
 
 // WriteAudioFrame encodes and writes an audio frame.
 func (e *Encoder) WriteAudioFrame(frame Frame) error {
+	defer e.emitProgress()
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	if e.closed {
+		return errors.New("ffgo: encoder is closed")
+	}
+	if !e.hasAudio {
+		return errors.New("ffgo: encoder was not configured with audio")
+	}
+	if e.audioCodecCtx == nil {
+		return errors.New("ffgo: audio codec context not initialized")
+	}
+
+	pts := e.audioFrameCnt
+	if frame.ptr != nil && e.useSourcePTS {
+		if framePTS := avutil.GetFramePTS(frame.ptr); framePTS != avutil.AV_NOPTS_VALUE {
+			pts = framePTS
+		}
+	}
+	return e.writeAudioFrameLocked(frame, pts)
+}
+
+// WriteAudioFrameWithPTS encodes and writes an audio frame, deriving its
+// PTS from the source timestamp srcPTS expressed in srcTimeBase (typically
+// the decoder's audio stream time base). Use it together with
+// WriteFrameWithPTS so audio and video packets carry timestamps from the
+// same clock and interleave correctly, e.g. when restreaming to RTMP.
+// A srcPTS of AV_NOPTS_VALUE continues from the previous frame.
+func (e *Encoder) WriteAudioFrameWithPTS(frame Frame, srcPTS int64, srcTimeBase Rational) error {
+	if frame.ptr == nil {
+		return errors.New("ffgo: frame is nil; use Flush to drain the encoder")
+	}
+	if srcTimeBase.Num <= 0 || srcTimeBase.Den <= 0 {
+		return errors.New("ffgo: invalid source time base")
+	}
+
+	defer e.emitProgress()
 	e.mu.Lock()
 	defer e.mu.Unlock()
 
@@ -1161,6 +1784,16 @@ func (e *Encoder) WriteAudioFrame(frame Frame) error {
 		return errors.New("ffgo: audio codec context not initialized")
 	}
 
+	if e.audioPTS == nil || e.audioPTS.SrcTimeBase() != srcTimeBase {
+		e.audioPTS = NewPTSMapper(srcTimeBase, avcodec.GetCtxTimeBase(e.audioCodecCtx))
+	}
+	e.audioPTS.SetMinPTS(e.audioFrameCnt)
+	return e.writeAudioFrameLocked(frame, e.audioPTS.Map(srcPTS))
+}
+
+// writeAudioFrameLocked stamps frame with pts (in the audio codec time
+// base), encodes it, and writes the resulting packets. A nil frame flushes.
+func (e *Encoder) writeAudioFrameLocked(frame Frame, pts int64) error {
 	// Ensure header is written
 	if !e.headerWritten {
 		if err := e.writeHeaderLocked(); err != nil {
@@ -1168,31 +1801,40 @@ func (e *Encoder) WriteAudioFrame(frame Frame) error {
 		}
 	}
 
+	// Audio-only outputs are paced by the audio timestamps.
+	if frame.ptr != nil && e.pacer != nil && !e.hasVideo {
+		e.pacer.wait(ptsToDuration(pts, avcodec.GetCtxTimeBase(e.audioCodecCtx)))
+	}
+
 	// Set PTS for audio frame
 	if frame.ptr != nil {
-		pts := e.audioFrameCnt
 		avutil.SetFramePTS(frame.ptr, pts)
-		e.audioFrameCnt += int64(avutil.GetFrameNbSamples(frame.ptr))
+		e.audioFrameCnt = pts + int64(avutil.GetFrameNbSamples(frame.ptr))
+		if e.sampleRate > 0 {
+			e.advanceProgressLocked(e.audioFrameCnt, NewRational(1, int32(e.sampleRate)))
+		}
 	}
 
 	// Send frame to encoder
+	mark := e.logCapture.mark()
 	if err := avcodec.SendFrame(e.audioCodecCtx, frame.ptr); err != nil {
 		if avutil.IsEOF(err) {
 			return nil
 		}
-		return err
+		return e.logCapture.annotate(err, mark)
 	}
 
 	// Receive and write packets
 	for {
 		avcodec.PacketUnref(e.audioPacket)
 
+		mark := e.logCapture.mark()
 		err := avcodec.ReceivePacket(e.audioCodecCtx, e.audioPacket)
 		if err != nil {
 			if avutil.IsEOF(err) || avutil.IsAgain(err) {
 				break
 			}
-			return err
+			return e.logCapture.annotate(err, mark)
 		}
 
 		// Set stream index
@@ -1206,7 +1848,9 @@ func (e *Encoder) WriteAudioFrame(frame Frame) error {
 
 		// Write packet
 		if err := avformat.InterleavedWriteFrame(e.formatCtx, e.audioPacket); err != nil {
-			return err
+			if err := e.reconnectLocked(err); err != nil {
+				return err
+			}
 		}
 	}
 
@@ -1234,6 +1878,30 @@ func (e *Encoder) PixelFormat() PixelFormat {
 	return e.pixFmt
 }
 
+// VideoBufferSize returns the VBV buffer size, in bits, given to the video
+// encoder, including the default chosen when VideoEncoderConfig.BufferSize
+// is zero. It is 0 when no buffer size was set.
+func (e *Encoder) VideoBufferSize() int64 {
+	return e.bufSize
+}
+
+// LastLogMessage returns the most recent warning or error FFmpeg logged for
+// this encoder's muxer or codecs, or "" if there is none or the encoder was
+// created without EncoderOptions.CaptureLog. Codecs that encode on worker
+// threads may log through per-thread contexts that are not attributed to
+// the encoder.
+func (e *Encoder) LastLogMessage() string {
+	return e.logCapture.message()
+}
+
+// startLogCapture begins capturing the log of the encoder's format context;
+// codec contexts are watched as they are allocated. Without the shim there
+// is nothing to capture and logCapture stays nil.
+func (e *Encoder) startLogCapture() {
+	e.logCapture, _ = startLogCapture()
+	e.logCapture.watch(e.formatCtx)
+}
+
 // FrameCount returns the number of frames written.
 func (e *Encoder) FrameCount() int64 {
 	e.mu.Lock()
@@ -1301,6 +1969,7 @@ func (e *Encoder) Close() error {
 				break
 			}
 			avcodec.SetPacketStreamIndex(e.videoPacket, avformat.GetStreamIndex(e.videoStream))
+			e.rescaleVideoPacketLocked(e.videoPacket)
 			_ = avformat.InterleavedWriteFrame(e.formatCtx, e.videoPacket)
 		}
 	}
@@ -1328,8 +1997,9 @@ func (e *Encoder) Close() error {
 
 	// Write trailer
 	if e.formatCtx != nil && e.headerWritten {
+		mark := e.logCapture.mark()
 		if err := avformat.WriteTrailer(e.formatCtx); err != nil && firstErr == nil {
-			firstErr = err
+			firstErr = e.logCapture.annotate(err, mark)
 		}
 	}
 
@@ -1337,8 +2007,24 @@ func (e *Encoder) Close() error {
 	return firstErr
 }
 
+// abort releases the encoder like Close but without flushing the codecs or
+// writing the trailer, leaving the output truncated.
+func (e *Encoder) abort() {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	if e.closed {
+		return
+	}
+	e.closed = true
+	e.cleanup()
+}
+
 // cleanup releases all resources.
 func (e *Encoder) cleanup() {
+	// Detach the log capture before its contexts are freed
+	e.logCapture.stop()
+
 	// Free video packet
 	if e.videoPacket != nil {
 		avcodec.PacketFree(&e.videoPacket)
@@ -1373,6 +2059,12 @@ func (e *Encoder) cleanup() {
 		avformat.FreeContext(e.formatCtx)
 		e.formatCtx = nil
 	}
+
+	// Release custom I/O after the format context no longer references it
+	if e.customIO != nil {
+		_ = e.customIO.Close()
+		e.customIO = nil
+	}
 }
 
 // guessFormatFromPath determines the output format from filename extension.
@@ -1382,6 +2074,12 @@ func guessFormatFromPath(path string) string {
 		return "image2"
 	}
 
+	return formatFromExtension(path)
+}
+
+// formatFromExtension maps the filename extension of path to an FFmpeg
+// format name, ignoring any image sequence pattern in the name.
+func formatFromExtension(path string) string {
 	// Get extension
 	ext := ""
 	for i := len(path) - 1; i >= 0; i-- {
@@ -1398,8 +2096,14 @@ func guessFormatFromPath(path string) string {
 	switch ext {
 	case "mp4", "m4v":
 		return "mp4"
-	case "mkv":
+	case "m4a":
+		return "ipod"
+	case "mkv", "mka":
 		return "matroska"
+	case "mp3":
+		return "mp3"
+	case "flac":
+		return "flac"
 	case "webm":
 		return "webm"
 	case "avi":
@@ -1410,6 +2114,10 @@ func guessFormatFromPath(path string) string {
 		return "flv"
 	case "ts", "m2ts":
 		return "mpegts"
+	case "m3u8":
+		return "hls"
+	case "mpd":
+		return "dash"
 	case "mpg", "mpeg":
 		return "mpeg"
 	case "ogg", "ogv":
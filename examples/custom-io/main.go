@@ -89,9 +89,6 @@ func main() {
 			fmt.Fprintf(os.Stderr, "Read error: %v\n", err)
 			os.Exit(1)
 		}
-		if frame == nil {
-			break // EOF
-		}
 
 		switch frame.MediaType() {
 		case ffgo.MediaTypeVideo:
@@ -160,7 +157,7 @@ func demonstrateCustomCallbacks(inputFile string) {
 	frameCount := 0
 	for frameCount < 10 {
 		frame, err := decoder.ReadFrame()
-		if err != nil || frame == nil {
+		if err != nil {
 			break
 		}
 		if frame.MediaType() == ffgo.MediaTypeVideo {
@@ -8,6 +8,8 @@
 package main
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"os"
 
@@ -76,31 +78,28 @@ func main() {
 	if decoder.HasVideo() {
 		fmt.Println("\nDecoding video frames...")
 
-		if err := decoder.OpenVideoDecoder(); err != nil {
-			fmt.Fprintf(os.Stderr, "Failed to open video decoder: %v\n", err)
-			os.Exit(1)
-		}
+		// Stop after the first 30 frames for the demo by cancelling the
+		// context; frames from VideoFrames are owned by us and must be freed.
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		frames, errc := decoder.VideoFrames(ctx)
 
 		frameCount := 0
-		maxFrames := 30 // Limit to first 30 frames for demo
-
-		for frameCount < maxFrames {
-			frame, err := decoder.DecodeVideo()
-			if err != nil {
-				if ffgo.IsEOF(err) {
-					break
-				}
-				fmt.Fprintf(os.Stderr, "Decode error: %v\n", err)
-				break
-			}
-			if frame.IsNil() {
-				continue
-			}
-
+		maxFrames := 30
+		for frame := range frames {
 			frameCount++
 			info := ffgo.GetFrameInfo(frame)
 			fmt.Printf("Frame %3d: %dx%d, pts=%d\n",
 				frameCount, info.Width, info.Height, info.PTS)
+			ffgo.FrameFree(&frame)
+
+			if frameCount == maxFrames {
+				cancel()
+				break
+			}
+		}
+		if err := <-errc; err != nil && !errors.Is(err, context.Canceled) {
+			fmt.Fprintf(os.Stderr, "Decode error: %v\n", err)
 		}
 
 		fmt.Printf("\nDecoded %d frames\n", frameCount)
@@ -62,6 +62,9 @@ func main() {
 		ffgo.WithStreamingOptions(&ffgo.StreamingOptions{
 			Timeout:  10 * time.Second,
 			MaxDelay: 500 * time.Millisecond,
+			// Emit frames at the source frame rate, like a live feed.
+			Realtime:       true,
+			MaxAheadBuffer: 500 * time.Millisecond,
 		}),
 	)
 	if err != nil {
@@ -4,12 +4,14 @@
 //
 // Usage: transcode <input_file> <output_file>
 //
-// This example reads a video file and transcodes it to H.264.
+// This example reads a video file and transcodes it to H.264, with audio
+// re-encoded to AAC.
 package main
 
 import (
 	"fmt"
 	"os"
+	"time"
 
 	"github.com/obinnaokechukwu/ffgo"
 )
@@ -63,93 +65,33 @@ func main() {
 			audioInfo.SampleRate, audioInfo.Channels, audioInfo.CodecID.String())
 	}
 
-	// Create encoder with same dimensions as input
+	// Transcode to H.264 (and AAC, if the input has audio) at the input's size
 	fmt.Printf("\nCreating output: %s\n", outputFile)
-	encoder, err := ffgo.NewEncoder(outputFile, ffgo.EncoderConfig{
-		Width:       videoInfo.Width,
-		Height:      videoInfo.Height,
-		PixelFormat: ffgo.PixelFormatYUV420P,
-		CodecID:     ffgo.CodecIDH264,
-		BitRate:     2000000, // 2 Mbps
-		FrameRate:   30,
-		GOPSize:     12,
-		MaxBFrames:  0,
+	transcoder, err := ffgo.NewTranscoder(inputFile, outputFile, ffgo.TranscodeConfig{
+		Video: &ffgo.VideoEncoderConfig{
+			Codec:   ffgo.CodecIDH264,
+			Bitrate: 2000000, // 2 Mbps
+			GOPSize: 12,
+		},
+		OnProgress: func(p ffgo.Progress) {
+			fmt.Printf("\rProgress: %5.1f%% (%v / %v)", p.Percent,
+				p.TimeDone.Truncate(time.Second), p.TotalTime.Truncate(time.Second))
+		},
 	})
 	if err != nil {
-		fmt.Fprintf(os.Stderr, "Failed to create encoder: %v\n", err)
+		fmt.Fprintf(os.Stderr, "Failed to create transcoder: %v\n", err)
 		os.Exit(1)
 	}
-	defer encoder.Close()
-
-	// Create scaler if pixel format conversion is needed
-	var scaler *ffgo.Scaler
-	if videoInfo.PixelFmt != ffgo.PixelFormatYUV420P {
-		fmt.Printf("Creating scaler: %v -> YUV420P\n", videoInfo.PixelFmt)
-		scaler, err = ffgo.NewScalerWithConfig(ffgo.ScalerConfig{
-			SrcWidth:  videoInfo.Width,
-			SrcHeight: videoInfo.Height,
-			SrcFormat: videoInfo.PixelFmt,
-			DstWidth:  videoInfo.Width,
-			DstHeight: videoInfo.Height,
-			DstFormat: ffgo.PixelFormatYUV420P,
-			Flags:     ffgo.ScaleBilinear,
-		})
-		if err != nil {
-			fmt.Fprintf(os.Stderr, "Failed to create scaler: %v\n", err)
-			os.Exit(1)
-		}
-		defer scaler.Close()
-	}
+	defer transcoder.Close()
 
 	fmt.Println("\nTranscoding...")
 
-	// Transcode frames
-	frameCount := 0
-	for {
-		// Decode next video frame
-		frame, err := decoder.DecodeVideo()
-		if err != nil {
-			if ffgo.IsEOF(err) {
-				break
-			}
-			fmt.Fprintf(os.Stderr, "Decode error: %v\n", err)
-			os.Exit(1)
-		}
-		if frame.IsNil() {
-			break // EOF
-		}
-
-		// Scale if necessary
-		var encFrame ffgo.Frame = frame
-		if scaler != nil {
-			encFrame, err = scaler.Scale(frame)
-			if err != nil {
-				fmt.Fprintf(os.Stderr, "Scale error: %v\n", err)
-				os.Exit(1)
-			}
-		}
-
-		// Encode frame
-		if err := encoder.WriteFrame(encFrame); err != nil {
-			fmt.Fprintf(os.Stderr, "Encode error: %v\n", err)
-			os.Exit(1)
-		}
-
-		frameCount++
-
-		// Progress indicator
-		if frameCount%30 == 0 {
-			fmt.Printf("\rFrames processed: %d", frameCount)
-		}
-	}
-
-	fmt.Printf("\rFrames processed: %d\n", frameCount)
-
-	// Close encoder (flushes and writes trailer)
-	if err := encoder.Close(); err != nil {
-		fmt.Fprintf(os.Stderr, "Failed to finalize output: %v\n", err)
+	// Run decodes, converts and encodes every frame, then writes the trailer
+	if err := transcoder.Run(); err != nil {
+		fmt.Fprintf(os.Stderr, "\nTranscode error: %v\n", err)
 		os.Exit(1)
 	}
+	fmt.Println()
 
 	// Verify output file
 	info, err := os.Stat(outputFile)
@@ -160,5 +102,4 @@ func main() {
 
 	fmt.Printf("\nTranscode complete!\n")
 	fmt.Printf("Output: %s (%d bytes)\n", outputFile, info.Size())
-	fmt.Printf("Transcoded %d frames\n", frameCount)
 }
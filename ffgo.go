@@ -13,6 +13,7 @@ import (
 	"errors"
 	"fmt"
 	"runtime"
+	"unsafe"
 
 	"github.com/obinnaokechukwu/ffgo/avcodec"
 	"github.com/obinnaokechukwu/ffgo/avformat"
@@ -190,6 +191,17 @@ type (
 
 	// CodecID represents codec identifiers.
 	CodecID = avcodec.CodecID
+
+	// PictureType is a video frame's coding type (I, P or B).
+	PictureType = avutil.PictureType
+)
+
+// Picture types reported in FrameInfo.PictType.
+const (
+	PictureTypeNone = avutil.PictureTypeNone
+	PictureTypeI    = avutil.PictureTypeI
+	PictureTypeP    = avutil.PictureTypeP
+	PictureTypeB    = avutil.PictureTypeB
 )
 
 // IsNil reports whether the packet pointer is nil.
@@ -260,6 +272,39 @@ func (p *Packet) Pos() int64 {
 	return avcodec.GetPacketPos(p.ptr)
 }
 
+// Duration returns the packet duration in stream time base units, or 0 if unknown.
+func (p *Packet) Duration() int64 {
+	if p == nil || p.ptr == nil {
+		return 0
+	}
+	return avcodec.GetPacketDuration(p.ptr)
+}
+
+// IsKeyframe reports whether the packet contains a keyframe.
+func (p *Packet) IsKeyframe() bool {
+	if p == nil || p.ptr == nil {
+		return false
+	}
+	return avcodec.GetPacketFlags(p.ptr)&avcodec.PacketFlagKey != 0
+}
+
+// Data returns the packet payload.
+//
+// The slice aliases the packet's buffer: it is only valid while the packet is,
+// which for decoder-owned packets means until the next ReadPacket call.
+// Copy it (or use PacketClone) to keep the data longer.
+func (p *Packet) Data() []byte {
+	if p == nil || p.ptr == nil {
+		return nil
+	}
+	data := avcodec.GetPacketData(p.ptr)
+	size := avcodec.GetPacketSize(p.ptr)
+	if data == nil || size <= 0 {
+		return nil
+	}
+	return unsafe.Slice((*byte)(data), int(size))
+}
+
 // PacketAlloc allocates a new owned packet.
 func PacketAlloc() *Packet {
 	return &Packet{ptr: avcodec.PacketAlloc(), owned: true}
@@ -294,9 +339,32 @@ func PacketClone(src *Packet) (*Packet, error) {
 // IsNil reports whether the frame pointer is nil.
 func (f Frame) IsNil() bool { return f.ptr == nil }
 
-// Clone returns an owned frame that references the same underlying buffers as f.
+// Ref returns an owned frame that references the same underlying buffers as
+// f (av_frame_ref), without copying pixel or sample data. This is the cheap
+// way to keep a decoded frame past the next decode call. The buffers are
+// shared, so neither frame should be written to while both exist.
 // The returned frame MUST be freed by the caller (via Frame.Free / FrameFree).
-func (f Frame) Clone() (Frame, error) { return FrameClone(f) }
+func (f Frame) Ref() (Frame, error) { return FrameClone(f) }
+
+// Clone returns an owned deep copy of f with its own, writable buffers and
+// the same properties (timestamps, color metadata, side data). Use it when
+// the copy will be modified; use Ref when a read-only reference suffices.
+// Hardware frames cannot be deep-copied; use Ref or transfer them to system
+// memory first. The returned frame MUST be freed by the caller (via
+// Frame.Free / FrameFree). If f is nil, it returns (nil, nil).
+func (f Frame) Clone() (Frame, error) {
+	dst, err := FrameClone(f)
+	if err != nil || dst.IsNil() {
+		return dst, err
+	}
+	// dst shares f's buffers, so making it writable copies the data into
+	// buffers of its own.
+	if err := avutil.FrameMakeWritable(dst.ptr); err != nil {
+		_ = dst.Free()
+		return Frame{}, err
+	}
+	return dst, nil
+}
 
 // Free releases an owned frame.
 //
@@ -326,6 +394,23 @@ const (
 	PixelFormatRGBA     = avutil.PixelFormatRGBA
 	PixelFormatBGRA     = avutil.PixelFormatBGRA
 	PixelFormatNV12     = avutil.PixelFormatNV12
+	PixelFormatYUV422P  = avutil.PixelFormatYUV422P
+	PixelFormatYUV444P  = avutil.PixelFormatYUV444P
+	PixelFormatGray8    = avutil.PixelFormatGray8
+	PixelFormatPAL8     = avutil.PixelFormatPAL8 // 8-bit palette (GIF)
+
+	// High bit depth pixel formats (ProRes, FFV1, 10-bit HEVC)
+	PixelFormatYUV420P10LE  = avutil.PixelFormatYUV420P10LE
+	PixelFormatYUV422P10LE  = avutil.PixelFormatYUV422P10LE
+	PixelFormatYUV444P10LE  = avutil.PixelFormatYUV444P10LE
+	PixelFormatYUVA444P10LE = avutil.PixelFormatYUVA444P10LE
+	PixelFormatYUV420P12LE  = avutil.PixelFormatYUV420P12LE
+	PixelFormatYUV422P12LE  = avutil.PixelFormatYUV422P12LE
+	PixelFormatYUV444P12LE  = avutil.PixelFormatYUV444P12LE
+	PixelFormatGBRP         = avutil.PixelFormatGBRP
+	PixelFormatGBRP10LE     = avutil.PixelFormatGBRP10LE
+	PixelFormatGBRP12LE     = avutil.PixelFormatGBRP12LE
+	PixelFormatGBRP16LE     = avutil.PixelFormatGBRP16LE
 
 	// Media types
 	MediaTypeUnknown    = avutil.MediaTypeUnknown
@@ -336,19 +421,21 @@ const (
 	MediaTypeAttachment = avutil.MediaTypeAttachment
 
 	// Common codec IDs
-	CodecIDNone  = avcodec.CodecIDNone
-	CodecIDH264  = avcodec.CodecIDH264
-	CodecIDHEVC  = avcodec.CodecIDHEVC
-	CodecIDAV1   = avcodec.CodecIDAV1
-	CodecIDVP8   = avcodec.CodecIDVP8
-	CodecIDVP9   = avcodec.CodecIDVP9
-	CodecIDAAC   = avcodec.CodecIDAAC
-	CodecIDMP3   = avcodec.CodecIDMP3
-	CodecIDOPUS  = avcodec.CodecIDOPUS
-	CodecIDMJPEG = avcodec.CodecIDMJPEG
-	CodecIDPNG   = avcodec.CodecIDPNG
-	CodecIDBMP   = avcodec.CodecIDBMP
-	CodecIDGIF   = avcodec.CodecIDGIF
+	CodecIDNone   = avcodec.CodecIDNone
+	CodecIDH264   = avcodec.CodecIDH264
+	CodecIDHEVC   = avcodec.CodecIDHEVC
+	CodecIDAV1    = avcodec.CodecIDAV1
+	CodecIDVP8    = avcodec.CodecIDVP8
+	CodecIDVP9    = avcodec.CodecIDVP9
+	CodecIDAAC    = avcodec.CodecIDAAC
+	CodecIDMP3    = avcodec.CodecIDMP3
+	CodecIDOPUS   = avcodec.CodecIDOPUS
+	CodecIDMJPEG  = avcodec.CodecIDMJPEG
+	CodecIDPNG    = avcodec.CodecIDPNG
+	CodecIDBMP    = avcodec.CodecIDBMP
+	CodecIDGIF    = avcodec.CodecIDGIF
+	CodecIDProRes = avcodec.CodecIDProRes
+	CodecIDFFV1   = avcodec.CodecIDFFV1
 
 	// Codec aliases (shorter names for convenience, as shown in user-guide)
 	CodecH264 = CodecIDH264
@@ -386,17 +473,39 @@ type StreamInfo struct {
 	Width      int         // Video only
 	Height     int         // Video only
 	PixelFmt   PixelFormat // Video only
-	FrameRate  Rational    // Video only - frames per second
+	FrameRate  Rational    // Video only - average frames per second (avg_frame_rate)
+	RFrameRate Rational    // Video only - base rate all timestamps fit (r_frame_rate)
+	Rotation   int         // Video only - clockwise degrees to display upright (display matrix)
 	SampleRate int         // Audio only
 	Channels   int         // Audio only
 	TimeBase   Rational
 	Duration   int64 // In time_base units
 	BitRate    int64
 
+	// SampleAspectRatio is the shape of one pixel (video only), e.g. 8/9
+	// for 4:3 NTSC DVD video stored as 720x480. 0/1 means unknown, which
+	// players treat as square pixels.
+	SampleAspectRatio Rational
+
 	// codecPar stores the codec parameters for stream copy operations.
 	codecPar avcodec.Parameters
 }
 
+// DisplayAspectRatio returns the shape the video is meant to be shown at,
+// Width*SampleAspectRatio : Height, reduced (e.g. 4/3 for anamorphic
+// 720x480 DVD video). Square pixels are assumed when the sample aspect
+// ratio is unknown. It returns 0/1 for streams without dimensions.
+func (s *StreamInfo) DisplayAspectRatio() Rational {
+	if s.Width <= 0 || s.Height <= 0 {
+		return Rational{Num: 0, Den: 1}
+	}
+	sar := s.SampleAspectRatio
+	if sar.Num <= 0 || sar.Den <= 0 {
+		sar = Rational{Num: 1, Den: 1}
+	}
+	return reduceRational(int64(s.Width)*int64(sar.Num), int64(s.Height)*int64(sar.Den))
+}
+
 // CodecParameters returns the codec parameters for this stream.
 // Used for stream copy operations where the codec parameters need to
 // be copied from source to destination without re-encoding.
@@ -405,6 +514,9 @@ func (s *StreamInfo) CodecParameters() avcodec.Parameters {
 }
 
 // FrameInfo contains information about a decoded frame.
+//
+// PTS, BestEffortPTS and Duration are in the time base of the stream the
+// frame was decoded from.
 type FrameInfo struct {
 	Width     int
 	Height    int
@@ -412,15 +524,27 @@ type FrameInfo struct {
 	PTS       int64
 	KeyFrame  bool
 	MediaType MediaType
+
+	// BestEffortPTS is the decoder's timestamp estimate, valid for frames
+	// whose PTS is AV_NOPTS_VALUE.
+	BestEffortPTS int64
+	// Duration is the frame duration, or 0 if unknown.
+	Duration int64
+	// PictType is the coding type of a video frame.
+	PictType PictureType
 }
 
 // GetFrameInfo returns information about a frame.
 func GetFrameInfo(frame Frame) FrameInfo {
 	return FrameInfo{
-		Width:  int(avutil.GetFrameWidth(frame.ptr)),
-		Height: int(avutil.GetFrameHeight(frame.ptr)),
-		Format: avutil.GetFrameFormat(frame.ptr),
-		PTS:    avutil.GetFramePTS(frame.ptr),
+		Width:         int(avutil.GetFrameWidth(frame.ptr)),
+		Height:        int(avutil.GetFrameHeight(frame.ptr)),
+		Format:        avutil.GetFrameFormat(frame.ptr),
+		PTS:           avutil.GetFramePTS(frame.ptr),
+		KeyFrame:      avutil.GetFrameKeyFrame(frame.ptr) != 0,
+		BestEffortPTS: avutil.GetFrameBestEffortTimestamp(frame.ptr),
+		Duration:      avutil.GetFrameDuration(frame.ptr),
+		PictType:      avutil.GetFramePictType(frame.ptr),
 	}
 }
 
@@ -460,6 +584,7 @@ func FrameRef(dst, src Frame) error {
 }
 
 // FrameClone creates a new frame that references the same underlying buffers as src.
+// It is equivalent to src.Ref(); use Frame.Clone for a deep copy.
 //
 // The returned frame is owned by the caller and must be freed with FrameFree.
 // If src is nil, it returns (nil, nil).
@@ -3,8 +3,14 @@
 package ffgo
 
 import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
 	"fmt"
+	"image"
 	"io"
+	"math"
 	"os"
 	"os/exec"
 	"path/filepath"
@@ -13,7 +19,9 @@ import (
 	"time"
 	"unsafe"
 
+	"github.com/obinnaokechukwu/ffgo/avformat"
 	"github.com/obinnaokechukwu/ffgo/avutil"
+	"github.com/obinnaokechukwu/ffgo/internal/shim"
 )
 
 var ffmpegAvailable bool
@@ -120,6 +128,61 @@ func TestDecoder(t *testing.T) {
 	t.Logf("Audio: sample_rate=%d, codec=%d", audioInfo.SampleRate, audioInfo.CodecID)
 }
 
+func TestDecoderReadPacketAccessors(t *testing.T) {
+	if !requireFFmpeg(t) {
+		return
+	}
+	testFile := createTestVideo(t)
+	if testFile == "" {
+		return
+	}
+
+	decoder, err := NewDecoder(testFile)
+	if err != nil {
+		t.Fatalf("NewDecoder failed: %v", err)
+	}
+	defer decoder.Close()
+	vs := decoder.VideoStream()
+	if vs == nil {
+		t.Fatal("expected a video stream")
+	}
+
+	videoPackets, keyframes := 0, 0
+	for {
+		pkt, err := decoder.ReadPacket()
+		if err != nil {
+			t.Fatalf("ReadPacket failed: %v", err)
+		}
+		if pkt == nil {
+			break
+		}
+		if len(pkt.Data()) != pkt.Size() {
+			t.Fatalf("len(Data()) = %d, Size() = %d", len(pkt.Data()), pkt.Size())
+		}
+		if pkt.StreamIndex() != vs.Index {
+			continue
+		}
+		if videoPackets == 0 && !pkt.IsKeyframe() {
+			t.Error("first video packet is not a keyframe")
+		}
+		if pkt.IsKeyframe() {
+			keyframes++
+		}
+		if pkt.DTS() == avutil.AV_NOPTS_VALUE && pkt.PTS() == avutil.AV_NOPTS_VALUE {
+			t.Errorf("video packet %d has no timestamps", videoPackets)
+		}
+		videoPackets++
+	}
+	if videoPackets == 0 || keyframes == 0 {
+		t.Fatalf("read %d video packets with %d keyframes", videoPackets, keyframes)
+	}
+
+	var nilPkt *Packet
+	if nilPkt.Data() != nil || nilPkt.IsKeyframe() || nilPkt.Duration() != 0 {
+		t.Error("nil packet accessors should return zero values")
+	}
+}
+
 func TestDecoderDecodeVideo(t *testing.T) {
 	if !requireFFmpeg(t) {
 		return
@@ -166,6 +229,111 @@ func TestDecoderDecodeVideo(t *testing.T) {
 	}
 }
 
+func TestGetFrameInfoTiming(t *testing.T) {
+	if !requireFFmpeg(t) {
+		return
+	}
+	testFile := createTestVideo(t)
+	if testFile == "" {
+		return
+	}
+
+	decoder, err := NewDecoder(testFile)
+	if err != nil {
+		t.Fatalf("NewDecoder failed: %v", err)
+	}
+	defer decoder.Close()
+
+	frame, err := decoder.DecodeVideo()
+	if err != nil {
+		t.Fatalf("DecodeVideo failed: %v", err)
+	}
+	info := GetFrameInfo(frame)
+	if !info.KeyFrame || info.PictType != PictureTypeI {
+		t.Errorf("first frame: KeyFrame=%v PictType=%d, want keyframe of type I", info.KeyFrame, info.PictType)
+	}
+	if info.BestEffortPTS == avutil.AV_NOPTS_VALUE {
+		t.Errorf("BestEffortPTS is AV_NOPTS_VALUE")
+	}
+	if info.PTS != avutil.AV_NOPTS_VALUE && info.BestEffortPTS != info.PTS {
+		t.Errorf("BestEffortPTS = %d, want PTS %d", info.BestEffortPTS, info.PTS)
+	}
+	if info.Duration <= 0 {
+		t.Logf("Duration not reported: %d", info.Duration)
+	}
+}
+
+func TestFrameTimestampFallsBackToBestEffort(t *testing.T) {
+	if !requireFFmpeg(t) {
+		return
+	}
+	testFile := createTestVideo(t)
+	if testFile == "" {
+		return
+	}
+
+	decoder, err := NewDecoder(testFile)
+	if err != nil {
+		t.Fatalf("NewDecoder failed: %v", err)
+	}
+	defer decoder.Close()
+
+	for i := 0; i < 3; i++ {
+		frame, err := decoder.DecodeVideo()
+		if err != nil {
+			t.Fatalf("DecodeVideo failed: %v", err)
+		}
+		want := avutil.GetFrameBestEffortTimestamp(frame.ptr)
+		if want == avutil.AV_NOPTS_VALUE {
+			t.Skip("best_effort_timestamp not available")
+		}
+		avutil.SetFramePTS(frame.ptr, avutil.AV_NOPTS_VALUE)
+		if got := frameTimestamp(frame.ptr); got != want {
+			t.Fatalf("frame %d: frameTimestamp = %d, want best-effort %d", i, got, want)
+		}
+	}
+}
+
+func TestFrameRefAndClone(t *testing.T) {
+	if !requireFFmpeg(t) {
+		return
+	}
+	src, err := newVideoFrame(16, 16, PixelFormatYUV420P)
+	if err != nil {
+		t.Fatalf("newVideoFrame failed: %v", err)
+	}
+	defer src.Free()
+	srcData := avutil.GetFrameDataPlane(src.ptr, 0)
+	*(*byte)(srcData) = 7
+	avutil.SetFramePTS(src.ptr, 42)
+
+	ref, err := src.Ref()
+	if err != nil {
+		t.Fatalf("Ref failed: %v", err)
+	}
+	defer ref.Free()
+	if avutil.GetFrameDataPlane(ref.ptr, 0) != srcData {
+		t.Error("Ref should share the source buffers")
+	}
+
+	clone, err := src.Clone()
+	if err != nil {
+		t.Fatalf("Clone failed: %v", err)
+	}
+	defer clone.Free()
+	cloneData := avutil.GetFrameDataPlane(clone.ptr, 0)
+	if cloneData == srcData {
+		t.Fatal("Clone should have its own buffers")
+	}
+	if *(*byte)(cloneData) != 7 || GetFrameInfo(clone).PTS != 42 {
+		t.Error("Clone did not copy data and properties")
+	}
+	*(*byte)(cloneData) = 9
+	if *(*byte)(srcData) != 7 {
+		t.Error("writing the clone modified the source")
+	}
+}
+
 func TestScaler(t *testing.T) {
 	if !requireFFmpeg(t) {
 		return
@@ -258,6 +426,186 @@ func TestScalerWithDecoder(t *testing.T) {
 	}
 }
 
+func TestScalerRegions(t *testing.T) {
+	base := ScalerConfig{SrcWidth: 640, SrcHeight: 480, DstWidth: 320, DstHeight: 240}
+
+	crop, pic, err := scalerRegions(base)
+	if err != nil || crop != image.Rect(0, 0, 640, 480) || pic != image.Rect(0, 0, 320, 240) {
+		t.Errorf("no crop/pad: %v %v %v", crop, pic, err)
+	}
+
+	cfg := base
+	cfg.SrcCropWidth, cfg.SrcCropHeight = 320, 240
+	if crop, _, err := scalerRegions(cfg); err != nil || crop != image.Rect(0, 0, 320, 240) {
+		t.Errorf("crop size only: %v %v", crop, err)
+	}
+
+	for name, cfg := range map[string]ScalerConfig{
+		"crop past right edge": {SrcWidth: 640, SrcHeight: 480, DstWidth: 320, DstHeight: 240, SrcCropWidth: 641},
+		"negative crop":        {SrcWidth: 640, SrcHeight: 480, DstWidth: 320, DstHeight: 240, SrcCropX: -2},
+		"padding fills output": {SrcWidth: 640, SrcHeight: 480, DstWidth: 320, DstHeight: 240, DstPadX: 160},
+	} {
+		if _, _, err := scalerRegions(cfg); err == nil {
+			t.Errorf("%s: scalerRegions succeeded, want error", name)
+		}
+	}
+}
+
+func TestScalerCropAndPad(t *testing.T) {
+	if !requireFFmpeg(t) {
+		return
+	}
+	// 8x8 source: left half red, right half green.
+	src, err := newVideoFrame(8, 8, PixelFormatRGBA)
+	if err != nil {
+		t.Fatalf("newVideoFrame failed: %v", err)
+	}
+	defer FrameFree(&src)
+	stride := int(avutil.GetFrameLinesizePlane(src.ptr, 0))
+	pix := unsafe.Slice((*byte)(avutil.GetFrameDataPlane(src.ptr, 0)), stride*8)
+	for y := 0; y < 8; y++ {
+		for x := 0; x < 8; x++ {
+			p := pix[y*stride+x*4:]
+			if x < 4 {
+				p[0], p[1], p[2], p[3] = 255, 0, 0, 255
+			} else {
+				p[0], p[1], p[2], p[3] = 0, 255, 0, 255
+			}
+		}
+	}
+
+	// Crop the green half and pillarbox it into an 8x4 output.
+	scaler, err := NewScalerWithConfig(ScalerConfig{
+		SrcWidth: 8, SrcHeight: 8, SrcFormat: PixelFormatRGBA,
+		DstWidth: 8, DstHeight: 4, DstFormat: PixelFormatRGBA,
+		Flags:    ScalePoint,
+		SrcCropX: 4, SrcCropWidth: 4,
+		DstPadX: 2,
+	})
+	if err != nil {
+		t.Fatalf("NewScalerWithConfig failed: %v", err)
+	}
+	defer scaler.Close()
+
+	out, err := scaler.Scale(src)
+	if err != nil {
+		t.Fatalf("Scale failed: %v", err)
+	}
+	outStride := int(avutil.GetFrameLinesizePlane(out.ptr, 0))
+	outPix := unsafe.Slice((*byte)(avutil.GetFrameDataPlane(out.ptr, 0)), outStride*4)
+	for y := 0; y < 4; y++ {
+		for x := 0; x < 8; x++ {
+			p := outPix[y*outStride+x*4:]
+			wantG := byte(255)
+			if x < 2 || x >= 6 {
+				wantG = 0 // border
+			}
+			if p[0] != 0 || p[1] != wantG || p[2] != 0 {
+				t.Fatalf("pixel %d,%d = %v, want green %d", x, y, p[:4], wantG)
+			}
+		}
+	}
+}
+
+func TestScalerReconfigure(t *testing.T) {
+	if !requireFFmpeg(t) {
+		return
+	}
+	src, err := newVideoFrame(64, 48, PixelFormatYUV420P)
+	if err != nil {
+		t.Fatalf("newVideoFrame failed: %v", err)
+	}
+	defer FrameFree(&src)
+
+	scaler, err := NewScaler(64, 48, PixelFormatYUV420P, 32, 24, PixelFormatRGBA, ScaleBilinear)
+	if err != nil {
+		t.Fatalf("NewScaler failed: %v", err)
+	}
+	defer scaler.Close()
+
+	for _, size := range [][2]int{{16, 12}, {48, 36}, {48, 36}, {8, 6}} {
+		cfg := ScalerConfig{
+			SrcWidth: 64, SrcHeight: 48, SrcFormat: PixelFormatYUV420P,
+			DstWidth: size[0], DstHeight: size[1], DstFormat: PixelFormatRGBA,
+		}
+		if err := scaler.Reconfigure(cfg); err != nil {
+			t.Fatalf("Reconfigure(%dx%d) failed: %v", size[0], size[1], err)
+		}
+		out, err := scaler.Scale(src)
+		if err != nil {
+			t.Fatalf("Scale after Reconfigure(%dx%d) failed: %v", size[0], size[1], err)
+		}
+		if w, h := avutil.GetFrameWidth(out.ptr), avutil.GetFrameHeight(out.ptr); int(w) != size[0] || int(h) != size[1] {
+			t.Errorf("scaled frame is %dx%d, want %dx%d", w, h, size[0], size[1])
+		}
+		if scaler.DstWidth() != size[0] || scaler.DstHeight() != size[1] {
+			t.Errorf("DstWidth/DstHeight = %dx%d, want %dx%d", scaler.DstWidth(), scaler.DstHeight(), size[0], size[1])
+		}
+	}
+
+	if err := scaler.Reconfigure(ScalerConfig{SrcWidth: 64, SrcHeight: 48, DstWidth: 0, DstHeight: 6}); err == nil {
+		t.Error("Reconfigure with zero width succeeded, want error")
+	}
+	if _, err := scaler.Scale(src); err != nil {
+		t.Errorf("Scale after rejected Reconfigure failed: %v", err)
+	}
+
+	_ = scaler.Close()
+	if err := scaler.Reconfigure(ScalerConfig{SrcWidth: 64, SrcHeight: 48, DstWidth: 8, DstHeight: 6}); err == nil {
+		t.Error("Reconfigure on closed scaler succeeded, want error")
+	}
+}
+
+func TestScalerSetDither(t *testing.T) {
+	if !requireFFmpeg(t) {
+		return
+	}
+	src, err := newVideoFrame(64, 48, PixelFormatRGBA)
+	if err != nil {
+		t.Fatalf("newVideoFrame failed: %v", err)
+	}
+	defer FrameFree(&src)
+
+	scaler, err := NewScaler(64, 48, PixelFormatRGBA, 32, 24, PixelFormatYUV420P, ScaleArea|ScaleAccurateRnd)
+	if err != nil {
+		t.Fatalf("NewScaler failed: %v", err)
+	}
+	defer scaler.Close()
+
+	if got := scaler.Dither(); got != DitherAuto {
+		t.Errorf("Dither() = %q, want %q", got, DitherAuto)
+	}
+	if err := scaler.SetDither("bogus"); err == nil {
+		t.Error("SetDither with unknown mode succeeded, want error")
+	}
+	for _, d := range []ScaleDither{DitherNone, DitherBayer, DitherErrorDiffusion, DitherAuto} {
+		if err := scaler.SetDither(d); err != nil {
+			t.Fatalf("SetDither(%q) failed: %v", d, err)
+		}
+		if _, err := scaler.Scale(src); err != nil {
+			t.Fatalf("Scale with dither %q failed: %v", d, err)
+		}
+	}
+
+	// The dither mode survives Reconfigure.
+	if err := scaler.SetDither(DitherBayer); err != nil {
+		t.Fatalf("SetDither failed: %v", err)
+	}
+	if err := scaler.Reconfigure(ScalerConfig{
+		SrcWidth: 64, SrcHeight: 48, SrcFormat: PixelFormatRGBA,
+		DstWidth: 16, DstHeight: 12, DstFormat: PixelFormatYUV420P,
+		Flags: ScaleLanczos | ScaleAccurateRnd,
+	}); err != nil {
+		t.Fatalf("Reconfigure failed: %v", err)
+	}
+	if got := scaler.Dither(); got != DitherBayer {
+		t.Errorf("Dither() after Reconfigure = %q, want %q", got, DitherBayer)
+	}
+	if _, err := scaler.Scale(src); err != nil {
+		t.Errorf("Scale after Reconfigure failed: %v", err)
+	}
+}
+
 func TestFrameAlloc(t *testing.T) {
 	if !requireFFmpeg(t) {
 		return
@@ -626,6 +974,90 @@ func TestDecoderFromReaderWithDecode(t *testing.T) {
 	t.Logf("Decoded %d frames from io.Reader", frameCount)
 }
 
+func TestSeekableDecoder(t *testing.T) {
+	if !requireFFmpeg(t) {
+		return
+	}
+	testFile := createTestVideo(t)
+
+	data, err := os.ReadFile(testFile)
+	if err != nil {
+		t.Fatalf("Failed to read file: %v", err)
+	}
+
+	// Hide Seek so the decoder only sees a plain io.Reader.
+	r := struct{ io.Reader }{strings.NewReader(string(data))}
+	decoder, err := NewSeekableDecoder(r, len(data), "")
+	if err != nil {
+		t.Fatalf("NewSeekableDecoder failed: %v", err)
+	}
+	defer decoder.Close()
+
+	if err := decoder.OpenVideoDecoder(); err != nil {
+		t.Fatalf("OpenVideoDecoder failed: %v", err)
+	}
+	if err := decoder.Seek(decoder.Duration() / 2); err != nil {
+		t.Fatalf("Seek failed: %v", err)
+	}
+	frame, err := decoder.DecodeVideo()
+	if err != nil {
+		t.Fatalf("DecodeVideo after seek failed: %v", err)
+	}
+	if frame.IsNil() {
+		t.Error("Got nil frame after seek")
+	}
+}
+
+func TestBufferedSeekReaderLimit(t *testing.T) {
+	src := strings.Repeat("x", 100)
+	br := &bufferedSeekReader{src: strings.NewReader(src), max: 64}
+
+	if _, err := br.Seek(10, io.SeekStart); err != nil {
+		t.Fatalf("Seek within limit failed: %v", err)
+	}
+	buf := make([]byte, 4)
+	if n, err := br.Read(buf); err != nil || n != 4 {
+		t.Fatalf("Read = %d, %v; want 4, nil", n, err)
+	}
+	if _, err := br.Seek(0, io.SeekEnd); err != ErrSeekBufferExceeded {
+		t.Errorf("Seek to end = %v; want ErrSeekBufferExceeded", err)
+	}
+
+	br = &bufferedSeekReader{src: strings.NewReader(src)}
+	if end, err := br.Seek(0, io.SeekEnd); err != nil || end != 100 {
+		t.Errorf("Seek to end = %d, %v; want 100, nil", end, err)
+	}
+}
+
+func TestBufferedSeekReaderLimitBoundary(t *testing.T) {
+	src := strings.Repeat("x", 64)
+
+	// A stream of exactly max bytes fits.
+	br := &bufferedSeekReader{src: strings.NewReader(src), max: len(src)}
+	data, err := io.ReadAll(br)
+	if err != nil || len(data) != len(src) {
+		t.Fatalf("ReadAll = %d bytes, %v; want %d, nil", len(data), err, len(src))
+	}
+	if n, err := br.Read(make([]byte, 4)); n != 0 || err != io.EOF {
+		t.Errorf("Read at end = %d, %v; want 0, io.EOF", n, err)
+	}
+
+	br = &bufferedSeekReader{src: strings.NewReader(src), max: len(src)}
+	if end, err := br.Seek(0, io.SeekEnd); err != nil || end != int64(len(src)) {
+		t.Errorf("Seek to end = %d, %v; want %d, nil", end, err, len(src))
+	}
+
+	// One byte more does not.
+	br = &bufferedSeekReader{src: strings.NewReader(src + "x"), max: len(src)}
+	if _, err := io.ReadAll(br); err != ErrSeekBufferExceeded {
+		t.Errorf("ReadAll = %v; want ErrSeekBufferExceeded", err)
+	}
+	br = &bufferedSeekReader{src: strings.NewReader(src + "x"), max: len(src)}
+	if _, err := br.Seek(0, io.SeekEnd); err != ErrSeekBufferExceeded {
+		t.Errorf("Seek to end = %v; want ErrSeekBufferExceeded", err)
+	}
+}
+
 func TestDecoderFromIOCallbacks(t *testing.T) {
 	if !requireFFmpeg(t) {
 		return
@@ -699,47 +1131,119 @@ func TestDecoderFromIOCallbacks(t *testing.T) {
 	}
 }
 
-func TestEncoderWithAudio(t *testing.T) {
+func TestEncoderToWriterNonSeekable(t *testing.T) {
 	if !requireFFmpeg(t) {
 		return
 	}
-	tmpDir := t.TempDir()
-	outFile := filepath.Join(tmpDir, "audio_video.mp4")
 
-	// Create encoder with audio
-	encoder, err := NewEncoderWithOptions(outFile, &EncoderOptions{
+	// bytes.Buffer is not an io.Seeker, so the muxer sees a non-seekable output.
+	var buf bytes.Buffer
+	enc, err := NewEncoderToWriterWithOptions(&buf, "mpegts", &EncoderOptions{
 		Video: &VideoEncoderConfig{
-			Codec:       CodecIDH264,
-			Width:       320,
-			Height:      240,
-			FrameRate:   NewRational(30, 1),
-			Bitrate:     500000,
+			Width:       160,
+			Height:      120,
+			FrameRate:   Rational{Num: 10, Den: 1},
+			Bitrate:     200000,
 			PixelFormat: PixelFormatYUV420P,
 			GOPSize:     10,
 		},
-		Audio: &AudioEncoderConfig{
-			Codec:      CodecIDAAC,
-			SampleRate: 48000,
-			Channels:   2,
-			Bitrate:    128000,
-		},
 	})
 	if err != nil {
-		t.Fatalf("NewEncoderWithOptions failed: %v", err)
+		t.Fatalf("NewEncoderToWriterWithOptions failed: %v", err)
 	}
-	defer encoder.Close()
 
-	// Verify encoder has both video and audio
-	if !encoder.HasVideo() {
-		t.Error("Encoder should have video")
-	}
-	if !encoder.HasAudio() {
-		t.Error("Encoder should have audio")
+	frame := FrameAlloc()
+	if frame.IsNil() {
+		t.Fatal("FrameAlloc returned nil")
 	}
-
-	// Check audio properties
-	if encoder.SampleRate() != 48000 {
-		t.Errorf("SampleRate = %d, want 48000", encoder.SampleRate())
+	defer func() { _ = FrameFree(&frame) }()
+	AVUtil.SetFrameWidth(frame, 160)
+	AVUtil.SetFrameHeight(frame, 120)
+	AVUtil.SetFrameFormat(frame, int32(PixelFormatYUV420P))
+	if err := AVUtil.FrameGetBuffer(frame, 0); err != nil {
+		t.Fatalf("FrameGetBuffer failed: %v", err)
+	}
+
+	for i := 0; i < 10; i++ {
+		if err := AVUtil.FrameMakeWritable(frame); err != nil {
+			t.Fatalf("FrameMakeWritable failed: %v", err)
+		}
+		fillTestFrame(frame, i, 160, 120)
+		if err := enc.WriteFrame(frame); err != nil {
+			t.Fatalf("WriteFrame failed at frame %d: %v", i, err)
+		}
+	}
+	if err := enc.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+
+	if buf.Len() == 0 {
+		t.Fatal("no bytes written through custom I/O")
+	}
+
+	decoder, err := NewDecoderFromReader(bytes.NewReader(buf.Bytes()), "mpegts")
+	if err != nil {
+		t.Fatalf("NewDecoderFromReader failed: %v", err)
+	}
+	defer decoder.Close()
+	if !decoder.HasVideo() {
+		t.Error("expected video stream in muxed output")
+	}
+}
+
+func TestEncoderToIOValidation(t *testing.T) {
+	if _, err := NewEncoderToIO(&IOCallbacks{}, nil); err == nil {
+		t.Error("expected error for nil options")
+	}
+	if _, err := NewEncoderToIO(&IOCallbacks{}, &EncoderOptions{}); err == nil {
+		t.Error("expected error for missing Format")
+	}
+	if _, err := NewEncoderToWriterWithOptions(nil, "mpegts", &EncoderOptions{}); err == nil {
+		t.Error("expected error for nil writer")
+	}
+}
+
+func TestEncoderWithAudio(t *testing.T) {
+	if !requireFFmpeg(t) {
+		return
+	}
+	tmpDir := t.TempDir()
+	outFile := filepath.Join(tmpDir, "audio_video.mp4")
+
+	// Create encoder with audio
+	encoder, err := NewEncoderWithOptions(outFile, &EncoderOptions{
+		Video: &VideoEncoderConfig{
+			Codec:       CodecIDH264,
+			Width:       320,
+			Height:      240,
+			FrameRate:   NewRational(30, 1),
+			Bitrate:     500000,
+			PixelFormat: PixelFormatYUV420P,
+			GOPSize:     10,
+		},
+		Audio: &AudioEncoderConfig{
+			Codec:      CodecIDAAC,
+			SampleRate: 48000,
+			Channels:   2,
+			Bitrate:    128000,
+		},
+	})
+	if err != nil {
+		t.Fatalf("NewEncoderWithOptions failed: %v", err)
+	}
+	defer encoder.Close()
+
+	// Verify encoder has both video and audio
+	if !encoder.HasVideo() {
+		t.Error("Encoder should have video")
+	}
+	if !encoder.HasAudio() {
+		t.Error("Encoder should have audio")
+	}
+
+	// Check audio properties
+	if encoder.SampleRate() != 48000 {
+		t.Errorf("SampleRate = %d, want 48000", encoder.SampleRate())
 	}
 	if encoder.Channels() != 2 {
 		t.Errorf("Channels = %d, want 2", encoder.Channels())
@@ -752,6 +1256,177 @@ func TestEncoderWithAudio(t *testing.T) {
 		encoder.SampleRate(), encoder.Channels(), encoder.AudioFrameSize())
 }
 
+func TestEncoderVideoEncodeWithAudioCopy(t *testing.T) {
+	if !requireFFmpeg(t) {
+		return
+	}
+
+	tmpDir := t.TempDir()
+	srcPath := filepath.Join(tmpDir, "src.mp4")
+	cmd := exec.Command("ffmpeg", "-y",
+		"-f", "lavfi", "-i", "testsrc=duration=1:size=160x120:rate=10",
+		"-f", "lavfi", "-i", "sine=frequency=440:duration=1",
+		"-pix_fmt", "yuv420p", "-c:a", "aac", "-shortest", srcPath)
+	if err := cmd.Run(); err != nil {
+		t.Skipf("ffmpeg CLI not available: %v", err)
+	}
+
+	dec, err := NewDecoder(srcPath)
+	if err != nil {
+		t.Fatalf("NewDecoder failed: %v", err)
+	}
+	defer dec.Close()
+	if err := dec.OpenVideoDecoder(); err != nil {
+		t.Fatalf("OpenVideoDecoder failed: %v", err)
+	}
+	vs, as := dec.VideoStream(), dec.AudioStream()
+	if vs == nil || as == nil {
+		t.Fatal("expected video and audio streams in source")
+	}
+
+	outPath := filepath.Join(tmpDir, "out.mkv")
+	enc, err := NewEncoderWithOptions(outPath, &EncoderOptions{
+		Video: &VideoEncoderConfig{
+			Width:       160,
+			Height:      120,
+			FrameRate:   Rational{Num: 10, Den: 1},
+			PixelFormat: PixelFormatYUV420P,
+		},
+		CopyAudio: true,
+		SourceStreams: &StreamCopySource{
+			AudioParams:   as.CodecParameters(),
+			AudioTimeBase: as.TimeBase,
+		},
+	})
+	if err != nil {
+		t.Fatalf("NewEncoderWithOptions failed: %v", err)
+	}
+
+	const audioOut = 1 // created after the encoded video stream
+	audioPackets := 0
+	for {
+		pkt, err := dec.ReadPacket()
+		if err != nil {
+			t.Fatalf("ReadPacket failed: %v", err)
+		}
+		if pkt == nil {
+			break
+		}
+		switch pkt.StreamIndex() {
+		case as.Index:
+			if err := enc.WritePacketFromSource(pkt, audioOut, as.TimeBase); err != nil {
+				t.Fatalf("WritePacketFromSource failed: %v", err)
+			}
+			audioPackets++
+		case vs.Index:
+			frame, err := dec.DecodeVideoPacket(pkt)
+			if err != nil {
+				t.Fatalf("DecodeVideoPacket failed: %v", err)
+			}
+			if !frame.IsNil() {
+				if err := enc.WriteFrame(frame); err != nil {
+					t.Fatalf("WriteFrame failed: %v", err)
+				}
+			}
+		}
+	}
+	if err := enc.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+	if audioPackets == 0 {
+		t.Fatal("no audio packets copied")
+	}
+
+	report, err := VerifyOutput(outPath, ExpectedStreams{Video: 1, Audio: 1})
+	if err != nil {
+		t.Fatalf("VerifyOutput failed: %v", err)
+	}
+	if !report.OK() {
+		t.Errorf("output problems: %v", report.Problems)
+	}
+}
+
+func TestDecoderReadFrameInterleaved(t *testing.T) {
+	if !requireFFmpeg(t) {
+		return
+	}
+
+	srcPath := filepath.Join(t.TempDir(), "av.mp4")
+	cmd := exec.Command("ffmpeg", "-y",
+		"-f", "lavfi", "-i", "testsrc=duration=1:size=160x120:rate=10",
+		"-f", "lavfi", "-i", "sine=frequency=440:duration=1",
+		"-pix_fmt", "yuv420p", "-c:a", "aac", "-shortest", srcPath)
+	if err := cmd.Run(); err != nil {
+		t.Skipf("ffmpeg CLI not available: %v", err)
+	}
+
+	dec, err := NewDecoder(srcPath)
+	if err != nil {
+		t.Fatalf("NewDecoder failed: %v", err)
+	}
+	defer dec.Close()
+
+	videoFrames, audioFrames := 0, 0
+	for {
+		frame, err := dec.ReadFrame()
+		if IsEOF(err) {
+			break
+		}
+		if err != nil {
+			t.Fatalf("ReadFrame failed: %v", err)
+		}
+		switch frame.MediaType() {
+		case MediaTypeVideo:
+			if frame.Width() != 160 || frame.Height() != 120 {
+				t.Errorf("video frame size = %dx%d, want 160x120", frame.Width(), frame.Height())
+			}
+			videoFrames++
+		case MediaTypeAudio:
+			if frame.NumSamples() <= 0 {
+				t.Errorf("audio frame has %d samples", frame.NumSamples())
+			}
+			audioFrames++
+		default:
+			t.Fatalf("unexpected media type %v", frame.MediaType())
+		}
+	}
+
+	// Every frame buffered in the decoders must be drained before EOF.
+	if videoFrames != 10 {
+		t.Errorf("decoded %d video frames, want 10", videoFrames)
+	}
+	if audioFrames == 0 {
+		t.Error("no audio frames decoded")
+	}
+
+	// EOF is sticky.
+	if _, err := dec.ReadFrame(); !IsEOF(err) {
+		t.Errorf("ReadFrame after EOF = %v, want EOF", err)
+	}
+}
+
+func TestEncoderWritePacketFromSourceRejectsEncodedStream(t *testing.T) {
+	if !requireFFmpeg(t) {
+		return
+	}
+	enc, err := NewEncoderWithOptions(filepath.Join(t.TempDir(), "out.mkv"), &EncoderOptions{
+		Video: &VideoEncoderConfig{Width: 160, Height: 120},
+	})
+	if err != nil {
+		t.Fatalf("NewEncoderWithOptions failed: %v", err)
+	}
+	defer enc.Close()
+
+	pkt := PacketAlloc()
+	if pkt.IsNil() {
+		t.Fatal("PacketAlloc returned nil")
+	}
+	defer pkt.Free()
+	if err := enc.WritePacketFromSource(pkt, 0, Rational{Num: 1, Den: 1000}); err == nil {
+		t.Error("expected error writing a packet to an encoded stream")
+	}
+}
+
 func TestEncoderWriteVideoAndAudioFrames(t *testing.T) {
 	if !requireFFmpeg(t) {
 		return
@@ -913,6 +1588,78 @@ func TestNewResampler(t *testing.T) {
 		resampler.DstFormat().SampleRate, resampler.DstFormat().Channels)
 }
 
+func TestResamplerConvertInto(t *testing.T) {
+	if !requireFFmpeg(t) {
+		return
+	}
+	src := AudioFormat{SampleRate: 44100, Channels: 2, SampleFormat: SampleFormatS16}
+	dst := AudioFormat{SampleRate: 48000, Channels: 2, SampleFormat: SampleFormatFLTP}
+	r, err := NewResampler(src, dst)
+	if err != nil {
+		t.Fatalf("NewResampler failed: %v", err)
+	}
+	defer r.Close()
+
+	const inSamples = 1024
+	in := FrameAlloc()
+	defer func() { _ = FrameFree(&in) }()
+	avutil.FrameSetSampleRate(in.ptr, int32(src.SampleRate))
+	avutil.FrameSetChannels(in.ptr, int32(src.Channels))
+	avutil.FrameSetFormat(in.ptr, int32(src.SampleFormat))
+	avutil.FrameSetNbSamples(in.ptr, inSamples)
+	if err := avutil.FrameGetBufferErr(in.ptr, 0); err != nil {
+		t.Fatalf("FrameGetBuffer failed: %v", err)
+	}
+
+	capacity := r.OutSamples(inSamples)
+	out, err := r.NewOutputFrame(capacity)
+	if err != nil {
+		t.Fatalf("NewOutputFrame failed: %v", err)
+	}
+	defer func() { _ = FrameFree(&out) }()
+
+	total := 0
+	for i := 0; i < 10; i++ {
+		n, err := r.ConvertInto(out, in)
+		if err != nil {
+			t.Fatalf("ConvertInto failed at %d: %v", i, err)
+		}
+		if n > capacity || int(avutil.GetFrameNbSamples(out.ptr)) != n {
+			t.Fatalf("ConvertInto wrote %d samples (nb_samples %d, capacity %d)", n, avutil.GetFrameNbSamples(out.ptr), capacity)
+		}
+		total += n
+	}
+	for {
+		n, err := r.ConvertInto(out, Frame{})
+		if err != nil {
+			t.Fatalf("ConvertInto drain failed: %v", err)
+		}
+		if n == 0 {
+			break
+		}
+		total += n
+	}
+	want := 10 * inSamples * dst.SampleRate / src.SampleRate
+	if total < want-64 || total > want+64 {
+		t.Errorf("converted %d samples, want about %d", total, want)
+	}
+
+	// Mismatched destination format is rejected.
+	other, err := NewResampler(src, AudioFormat{SampleRate: 48000, Channels: 1, SampleFormat: SampleFormatS16})
+	if err != nil {
+		t.Fatalf("NewResampler failed: %v", err)
+	}
+	defer other.Close()
+	mono, err := other.NewOutputFrame(capacity)
+	if err != nil {
+		t.Fatalf("NewOutputFrame failed: %v", err)
+	}
+	defer func() { _ = FrameFree(&mono) }()
+	if _, err := r.ConvertInto(mono, in); err == nil {
+		t.Error("expected error for mismatched destination frame")
+	}
+}
+
 func TestResamplerValidation(t *testing.T) {
 	if !requireFFmpeg(t) {
 		return
@@ -981,6 +1728,122 @@ func TestChannelLayoutConstants(t *testing.T) {
 	}
 }
 
+func TestResamplerChannelLayoutValidation(t *testing.T) {
+	// Channels must agree with an explicit layout.
+	_, err := NewResampler(
+		AudioFormat{SampleRate: 48000, Channels: 2, ChannelLayout: ChannelLayout5Point1, SampleFormat: SampleFormatFLTP},
+		AudioFormat{SampleRate: 48000, Channels: 2, SampleFormat: SampleFormatFLTP},
+	)
+	if err == nil {
+		t.Error("expected error for Channels/ChannelLayout mismatch")
+	}
+}
+
+func TestResamplerDownmix51(t *testing.T) {
+	if !requireFFmpeg(t) {
+		return
+	}
+	src := AudioFormat{SampleRate: 48000, ChannelLayout: ChannelLayout5Point1, SampleFormat: SampleFormatFLTP}
+	dst := AudioFormat{SampleRate: 48000, ChannelLayout: ChannelLayoutStereo, SampleFormat: SampleFormatFLTP}
+	r, err := NewResampler(src, dst)
+	if err != nil {
+		t.Fatalf("NewResampler failed: %v", err)
+	}
+	defer r.Close()
+	if got := r.SrcFormat().Channels; got != 6 {
+		t.Errorf("source channels = %d, want 6 derived from layout", got)
+	}
+
+	const n = 1024
+	in := FrameAlloc()
+	defer func() { _ = FrameFree(&in) }()
+	avutil.FrameSetSampleRate(in.ptr, 48000)
+	setFrameChannelLayout(in.ptr, r.SrcFormat())
+	avutil.FrameSetFormat(in.ptr, int32(SampleFormatFLTP))
+	avutil.FrameSetNbSamples(in.ptr, n)
+	if err := avutil.FrameGetBufferErr(in.ptr, 0); err != nil {
+		t.Fatalf("FrameGetBuffer failed: %v", err)
+	}
+	// Signal only on the center channel (plane 2 in 5.1 order).
+	for ch := 0; ch < 6; ch++ {
+		plane := unsafe.Slice((*float32)(avutil.GetFrameDataPlane(in.ptr, ch)), n)
+		for i := range plane {
+			plane[i] = 0
+			if ch == 2 {
+				plane[i] = 0.5
+			}
+		}
+	}
+
+	out, err := r.Resample(in)
+	if err != nil {
+		t.Fatalf("Resample failed: %v", err)
+	}
+	defer func() { _ = FrameFree(&out) }()
+	got := int(avutil.GetFrameNbSamples(out.ptr))
+	if got == 0 {
+		t.Fatal("no samples produced")
+	}
+	// The center channel is mixed equally into left and right.
+	left := unsafe.Slice((*float32)(avutil.GetFrameDataPlane(out.ptr, 0)), got)
+	right := unsafe.Slice((*float32)(avutil.GetFrameDataPlane(out.ptr, 1)), got)
+	mid := got / 2
+	if left[mid] < 0.1 || math.Abs(float64(left[mid]-right[mid])) > 1e-3 {
+		t.Errorf("downmixed center = L %.3f R %.3f, want equal and non-zero", left[mid], right[mid])
+	}
+}
+
+func TestResamplerOutputChannelLayout(t *testing.T) {
+	if !requireFFmpeg(t) {
+		return
+	}
+	src := AudioFormat{SampleRate: 48000, ChannelLayout: ChannelLayout5Point1, SampleFormat: SampleFormatFLTP}
+	for _, layout := range []ChannelLayout{ChannelLayoutStereo, ChannelLayoutQuad, ChannelLayout5Point1Back} {
+		dst := AudioFormat{SampleRate: 44100, ChannelLayout: layout, SampleFormat: SampleFormatS16}
+		r, err := NewResampler(src, dst)
+		if err != nil {
+			t.Fatalf("%s: NewResampler failed: %v", layout, err)
+		}
+
+		in := FrameAlloc()
+		avutil.FrameSetSampleRate(in.ptr, 48000)
+		setFrameChannelLayout(in.ptr, r.SrcFormat())
+		avutil.FrameSetFormat(in.ptr, int32(SampleFormatFLTP))
+		avutil.FrameSetNbSamples(in.ptr, 1024)
+		if err := avutil.FrameGetBufferErr(in.ptr, 0); err != nil {
+			t.Fatalf("FrameGetBuffer failed: %v", err)
+		}
+		for ch := 0; ch < 6; ch++ {
+			clear(unsafe.Slice((*float32)(avutil.GetFrameDataPlane(in.ptr, ch)), 1024))
+		}
+
+		out, err := r.Resample(in)
+		if err != nil {
+			t.Fatalf("%s: Resample failed: %v", layout, err)
+		}
+		if got := avutil.GetFrameChannelLayoutMask(out.ptr); avutil.GetFrameChLayoutPtr(out.ptr) != nil && got != uint64(layout) {
+			t.Errorf("%s: output layout mask = %#x, want %#x", layout, got, uint64(layout))
+		}
+		if got := int(avutil.GetFrameChannels(out.ptr)); got != layout.NumChannels() {
+			t.Errorf("%s: output channels = %d, want %d", layout, got, layout.NumChannels())
+		}
+
+		flushed, err := r.Flush()
+		if err != nil {
+			t.Fatalf("%s: Flush failed: %v", layout, err)
+		}
+		if !flushed.IsNil() && avutil.GetFrameChLayoutPtr(flushed.ptr) != nil {
+			if got := avutil.GetFrameChannelLayoutMask(flushed.ptr); got != uint64(layout) {
+				t.Errorf("%s: flushed layout mask = %#x, want %#x", layout, got, uint64(layout))
+			}
+		}
+		_ = FrameFree(&flushed)
+		_ = FrameFree(&out)
+		_ = FrameFree(&in)
+		r.Close()
+	}
+}
+
 func TestChannelLayoutString(t *testing.T) {
 	if !requireFFmpeg(t) {
 		return
@@ -1077,20 +1940,196 @@ func TestVideoFilterGraphWithChain(t *testing.T) {
 	t.Log("NewVideoFilterGraph with filter chain succeeded")
 }
 
-func TestFilterGraphValidation(t *testing.T) {
+func TestFilterGraphFPSUpsample(t *testing.T) {
 	if !requireFFmpeg(t) {
 		return
 	}
-	tests := []struct {
-		name    string
-		cfg     FilterGraphConfig
-		wantErr bool
-	}{
-		{
-			name:    "empty config",
-			cfg:     FilterGraphConfig{},
-			wantErr: true, // no video or audio params
-		},
+	graph, err := NewFilterGraph(FilterGraphConfig{
+		Width:     160,
+		Height:    120,
+		PixelFmt:  PixelFormatYUV420P,
+		TimeBase:  Rational{Num: 1, Den: 30},
+		FrameRate: Rational{Num: 30, Den: 1},
+		Filters:   "fps=60",
+	})
+	if err != nil {
+		t.Fatalf("NewFilterGraph failed: %v", err)
+	}
+	defer graph.Close()
+
+	frame := FrameAlloc()
+	if frame.IsNil() {
+		t.Fatal("FrameAlloc returned nil")
+	}
+	defer func() { _ = FrameFree(&frame) }()
+	AVUtil.SetFrameWidth(frame, 160)
+	AVUtil.SetFrameHeight(frame, 120)
+	AVUtil.SetFrameFormat(frame, int32(PixelFormatYUV420P))
+	if err := AVUtil.FrameGetBuffer(frame, 0); err != nil {
+		t.Fatalf("FrameGetBuffer failed: %v", err)
+	}
+
+	const inFrames = 30
+	outFrames := 0
+	for i := 0; i < inFrames; i++ {
+		if err := AVUtil.FrameMakeWritable(frame); err != nil {
+			t.Fatalf("FrameMakeWritable failed: %v", err)
+		}
+		fillTestFrame(frame, i, 160, 120)
+		avutil.SetFramePTS(frame.ptr, int64(i))
+
+		out, err := graph.Filter(&frame)
+		if err != nil {
+			t.Fatalf("Filter failed at frame %d: %v", i, err)
+		}
+		outFrames += len(out)
+		freeFrames(out)
+	}
+
+	// A nil frame flushes the remaining buffered output.
+	out, err := graph.Filter(nil)
+	if err != nil {
+		t.Fatalf("Filter(nil) failed: %v", err)
+	}
+	outFrames += len(out)
+	freeFrames(out)
+
+	if outFrames <= inFrames {
+		t.Errorf("fps=60 produced %d frames from %d inputs, want more", outFrames, inFrames)
+	}
+
+	// Flushing again is a no-op; pushing after a flush is rejected.
+	if out, err := graph.Flush(); err != nil || len(out) != 0 {
+		t.Errorf("second Flush() = %d frames, %v", len(out), err)
+	}
+	if _, err := graph.Filter(&frame); err == nil {
+		t.Error("expected error filtering after flush")
+	}
+}
+
+func TestFilterGraphMultiValidation(t *testing.T) {
+	input := func(name string) FilterGraphInput {
+		return FilterGraphInput{Name: name, Width: 64, Height: 48, PixelFmt: PixelFormatYUV420P}
+	}
+	tests := []struct {
+		name string
+		cfg  MultiInputFilterGraphConfig
+	}{
+		{"no inputs", MultiInputFilterGraphConfig{Filters: "[a][b]overlay"}},
+		{"no filters", MultiInputFilterGraphConfig{Inputs: []FilterGraphInput{input("a"), input("b")}}},
+		{"unnamed input", MultiInputFilterGraphConfig{Inputs: []FilterGraphInput{input(""), input("b")}, Filters: "[a][b]overlay"}},
+		{"duplicate input", MultiInputFilterGraphConfig{Inputs: []FilterGraphInput{input("a"), input("a")}, Filters: "[a][b]overlay"}},
+		{"missing size", MultiInputFilterGraphConfig{Inputs: []FilterGraphInput{input("a"), {Name: "b"}}, Filters: "[a][b]overlay"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if g, err := NewFilterGraphMulti(tt.cfg); err == nil {
+				g.Close()
+				t.Error("expected error, got nil")
+			}
+		})
+	}
+}
+
+func TestFilterGraphMultiOverlay(t *testing.T) {
+	if !requireFFmpeg(t) {
+		return
+	}
+	tb := Rational{Num: 1, Den: 30}
+	graph, err := NewFilterGraphMulti(MultiInputFilterGraphConfig{
+		Inputs: []FilterGraphInput{
+			{Name: "main", Width: 320, Height: 240, PixelFmt: PixelFormatYUV420P, TimeBase: tb},
+			{Name: "logo", Width: 64, Height: 48, PixelFmt: PixelFormatYUV420P, TimeBase: tb},
+		},
+		Filters: "[main][logo]overlay=10:10",
+	})
+	if err != nil {
+		t.Fatalf("NewFilterGraphMulti failed: %v", err)
+	}
+	defer graph.Close()
+
+	newFrame := func(w, h int32) Frame {
+		f := FrameAlloc()
+		AVUtil.SetFrameWidth(f, w)
+		AVUtil.SetFrameHeight(f, h)
+		AVUtil.SetFrameFormat(f, int32(PixelFormatYUV420P))
+		if err := AVUtil.FrameGetBuffer(f, 0); err != nil {
+			t.Fatalf("FrameGetBuffer failed: %v", err)
+		}
+		return f
+	}
+	main := newFrame(320, 240)
+	defer func() { _ = FrameFree(&main) }()
+	logo := newFrame(64, 48)
+	defer func() { _ = FrameFree(&logo) }()
+	fillTestFrame(logo, 0, 64, 48)
+	avutil.SetFramePTS(logo.ptr, 0)
+
+	// Send the still logo once and end its input; overlay repeats it.
+	out, err := graph.FilterFrames(map[string]Frame{"logo": logo})
+	if err != nil {
+		t.Fatalf("FilterFrames(logo) failed: %v", err)
+	}
+	total := len(out)
+	freeFrames(out)
+	if out, err = graph.FilterFrames(map[string]Frame{"logo": {}}); err != nil {
+		t.Fatalf("ending logo input failed: %v", err)
+	}
+	total += len(out)
+	freeFrames(out)
+
+	const n = 5
+	for i := 0; i < n; i++ {
+		if err := AVUtil.FrameMakeWritable(main); err != nil {
+			t.Fatalf("FrameMakeWritable failed: %v", err)
+		}
+		fillTestFrame(main, i, 320, 240)
+		avutil.SetFramePTS(main.ptr, int64(i))
+		out, err := graph.FilterFrames(map[string]Frame{"main": main})
+		if err != nil {
+			t.Fatalf("FilterFrames(main) failed at %d: %v", i, err)
+		}
+		for j := range out {
+			if w := avutil.GetFrameWidth(out[j].ptr); w != 320 {
+				t.Errorf("output width = %d, want 320", w)
+			}
+		}
+		total += len(out)
+		freeFrames(out)
+	}
+	out, err = graph.Flush()
+	if err != nil {
+		t.Fatalf("Flush failed: %v", err)
+	}
+	total += len(out)
+	freeFrames(out)
+
+	if total != n {
+		t.Errorf("overlay produced %d frames, want %d", total, n)
+	}
+
+	if _, err := graph.FilterFrames(map[string]Frame{"other": main}); err == nil {
+		t.Error("expected error for unknown input name")
+	}
+	if _, err := graph.Filter(&main); err == nil {
+		t.Error("expected Filter to reject a multi-input graph")
+	}
+}
+
+func TestFilterGraphValidation(t *testing.T) {
+	if !requireFFmpeg(t) {
+		return
+	}
+	tests := []struct {
+		name    string
+		cfg     FilterGraphConfig
+		wantErr bool
+	}{
+		{
+			name:    "empty config",
+			cfg:     FilterGraphConfig{},
+			wantErr: true, // no video or audio params
+		},
 		{
 			name: "video only",
 			cfg: FilterGraphConfig{
@@ -1177,6 +2216,36 @@ func TestFilterGraphClose(t *testing.T) {
 	}
 }
 
+func TestFilterGraphSendCommand(t *testing.T) {
+	if !requireFFmpeg(t) {
+		return
+	}
+	graph, err := NewFilterGraph(FilterGraphConfig{
+		SampleRate: 48000,
+		Channels:   2,
+		SampleFmt:  SampleFormatFLTP,
+		Filters:    "volume=1.0",
+	})
+	if err != nil {
+		t.Fatalf("NewFilterGraph failed: %v", err)
+	}
+
+	if err := graph.SendCommand("volume", "volume", "0.5"); err != nil {
+		t.Errorf("SendCommand(volume) failed: %v", err)
+	}
+	if err := graph.SendCommand("volume", "no_such_command", "1"); err == nil {
+		t.Error("expected error for unsupported command")
+	}
+	if err := graph.SendCommand("eq", "brightness", "0.2"); err == nil {
+		t.Error("expected error for filter not in graph")
+	}
+
+	graph.Close()
+	if err := graph.SendCommand("volume", "volume", "1.0"); err != ErrFilterGraphClosed {
+		t.Errorf("SendCommand on closed graph: got %v, want ErrFilterGraphClosed", err)
+	}
+}
+
 func TestAudioFilterGraphBasic(t *testing.T) {
 	if !requireFFmpeg(t) {
 		return
@@ -1253,940 +2322,1348 @@ func TestEncoderWithPreset(t *testing.T) {
 	t.Logf("Encoder with preset=%s created successfully", PresetUltrafast)
 }
 
-func TestEncoderWithCRF(t *testing.T) {
+func TestEncoderOnProgress(t *testing.T) {
 	if !requireFFmpeg(t) {
 		return
 	}
-	dir := t.TempDir()
-	outPath := filepath.Join(dir, "crf_test.mp4")
+	outPath := filepath.Join(t.TempDir(), "progress.mp4")
 
+	var reports []time.Duration
 	enc, err := NewEncoderWithOptions(outPath, &EncoderOptions{
 		Video: &VideoEncoderConfig{
-			Width:       320,
-			Height:      240,
-			FrameRate:   Rational{Num: 30, Den: 1},
+			Width:       160,
+			Height:      120,
+			FrameRate:   Rational{Num: 10, Den: 1},
+			Bitrate:     200000,
 			PixelFormat: PixelFormatYUV420P,
-			RateControl: RateControlCRF,
-			CRF:         23, // Typical quality setting
-			Preset:      PresetMedium,
 			GOPSize:     10,
 		},
+		OnProgress: func(encoded time.Duration) {
+			reports = append(reports, encoded)
+		},
 	})
 	if err != nil {
-		t.Fatalf("NewEncoderWithOptions with CRF failed: %v", err)
+		t.Fatalf("NewEncoderWithOptions failed: %v", err)
 	}
-	defer enc.Close()
 
-	t.Logf("Encoder with CRF=%d created successfully", 23)
+	frame := FrameAlloc()
+	if frame.IsNil() {
+		t.Fatal("FrameAlloc returned nil")
+	}
+	defer func() { _ = FrameFree(&frame) }()
+	AVUtil.SetFrameWidth(frame, 160)
+	AVUtil.SetFrameHeight(frame, 120)
+	AVUtil.SetFrameFormat(frame, int32(PixelFormatYUV420P))
+	if err := AVUtil.FrameGetBuffer(frame, 0); err != nil {
+		t.Fatalf("FrameGetBuffer failed: %v", err)
+	}
+
+	for i := 0; i < 10; i++ {
+		if err := AVUtil.FrameMakeWritable(frame); err != nil {
+			t.Fatalf("FrameMakeWritable failed: %v", err)
+		}
+		fillTestFrame(frame, i, 160, 120)
+		if err := enc.WriteFrame(frame); err != nil {
+			t.Fatalf("WriteFrame failed at frame %d: %v", i, err)
+		}
+	}
+	if err := enc.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+
+	if len(reports) != 10 {
+		t.Fatalf("got %d progress reports, want 10", len(reports))
+	}
+	for i := 1; i < len(reports); i++ {
+		if reports[i] <= reports[i-1] {
+			t.Errorf("progress not increasing: %v then %v", reports[i-1], reports[i])
+		}
+	}
+	if last := reports[len(reports)-1]; last != time.Second {
+		t.Errorf("final progress = %v, want 1s", last)
+	}
 }
 
-func TestEncoderWithProfile(t *testing.T) {
-	if !requireFFmpeg(t) {
-		return
+func TestMuxerHeaderOptionsFaststart(t *testing.T) {
+	user := map[string]string{"movflags": "frag_keyframe"}
+	got := muxerHeaderOptions("mp4", &EncoderOptions{MuxerOptions: user, Faststart: true})
+	if got["movflags"] != "frag_keyframe+faststart" {
+		t.Errorf("movflags = %q, want %q", got["movflags"], "frag_keyframe+faststart")
+	}
+	if user["movflags"] != "frag_keyframe" {
+		t.Error("caller's MuxerOptions were modified")
 	}
-	dir := t.TempDir()
-	outPath := filepath.Join(dir, "profile_test.mp4")
 
-	enc, err := NewEncoderWithOptions(outPath, &EncoderOptions{
-		Video: &VideoEncoderConfig{
-			Width:       320,
-			Height:      240,
-			FrameRate:   Rational{Num: 30, Den: 1},
-			Bitrate:     500000,
-			PixelFormat: PixelFormatYUV420P,
-			Profile:     ProfileBaseline, // Most compatible
-			Level:       Level3,
-			Preset:      PresetFast,
-			GOPSize:     10,
-		},
+	got = muxerHeaderOptions("mov", &EncoderOptions{Faststart: true})
+	if got["movflags"] != "+faststart" {
+		t.Errorf("movflags = %q, want %q", got["movflags"], "+faststart")
+	}
+
+	got = muxerHeaderOptions("matroska", &EncoderOptions{Faststart: true})
+	if _, ok := got["movflags"]; ok {
+		t.Error("movflags set for non-MP4 container")
+	}
+}
+
+func TestMuxerHeaderOptionsMatroskaCues(t *testing.T) {
+	cues := &MatroskaCueOptions{CuesToFront: true, ReserveIndexSpace: 4096}
+	got := muxerHeaderOptions("matroska", &EncoderOptions{
+		MatroskaCues: cues,
+		MuxerOptions: map[string]string{"reserve_index_space": "1024"},
 	})
-	if err != nil {
-		t.Fatalf("NewEncoderWithOptions with profile failed: %v", err)
+	if got["cues_to_front"] != "1" {
+		t.Errorf("cues_to_front = %q, want 1", got["cues_to_front"])
+	}
+	if got["reserve_index_space"] != "1024" {
+		t.Errorf("reserve_index_space = %q, want MuxerOptions to take precedence", got["reserve_index_space"])
 	}
-	defer enc.Close()
 
-	t.Logf("Encoder with profile=%s level=%s created successfully", ProfileBaseline, Level3)
+	got = muxerHeaderOptions("mp4", &EncoderOptions{MatroskaCues: cues})
+	if len(got) != 0 {
+		t.Errorf("options set for non-Matroska container: %v", got)
+	}
 }
 
-func TestEncoderWithCodecOptions(t *testing.T) {
+func TestEncoderWithCRF(t *testing.T) {
 	if !requireFFmpeg(t) {
 		return
 	}
 	dir := t.TempDir()
-	outPath := filepath.Join(dir, "options_test.mp4")
+	outPath := filepath.Join(dir, "crf_test.mp4")
 
 	enc, err := NewEncoderWithOptions(outPath, &EncoderOptions{
 		Video: &VideoEncoderConfig{
 			Width:       320,
 			Height:      240,
 			FrameRate:   Rational{Num: 30, Den: 1},
-			Bitrate:     500000,
 			PixelFormat: PixelFormatYUV420P,
-			Preset:      PresetFast,
+			RateControl: RateControlCRF,
+			CRF:         23, // Typical quality setting
+			Preset:      PresetMedium,
 			GOPSize:     10,
-			CodecOptions: map[string]string{
-				"bf": "0", // No B-frames
-			},
 		},
 	})
 	if err != nil {
-		t.Fatalf("NewEncoderWithOptions with codec options failed: %v", err)
+		t.Fatalf("NewEncoderWithOptions with CRF failed: %v", err)
 	}
 	defer enc.Close()
 
-	t.Log("Encoder with custom codec options created successfully")
+	t.Logf("Encoder with CRF=%d created successfully", 23)
 }
 
-func TestEncoderWithAdvancedOptionsEncode(t *testing.T) {
+func TestEncoderWithCBR(t *testing.T) {
 	if !requireFFmpeg(t) {
 		return
 	}
-	dir := t.TempDir()
-	outPath := filepath.Join(dir, "advanced_encode.mp4")
+	outPath := filepath.Join(t.TempDir(), "cbr_test.ts")
 
 	enc, err := NewEncoderWithOptions(outPath, &EncoderOptions{
 		Video: &VideoEncoderConfig{
-			Width:       160,
-			Height:      120,
+			Width:       320,
+			Height:      240,
 			FrameRate:   Rational{Num: 30, Den: 1},
 			PixelFormat: PixelFormatYUV420P,
-			RateControl: RateControlCRF,
-			CRF:         28,
-			Preset:      PresetUltrafast,
-			Profile:     ProfileHigh,
-			GOPSize:     5,
-			MaxBFrames:  0,
+			RateControl: RateControlCBR,
+			Bitrate:     500000,
+			GOPSize:     15,
 		},
 	})
 	if err != nil {
-		t.Fatalf("NewEncoderWithOptions failed: %v", err)
+		t.Fatalf("NewEncoderWithOptions with CBR failed: %v", err)
 	}
-	defer enc.Close()
 
-	// Create test frame
 	frame := FrameAlloc()
 	if frame.IsNil() {
+		enc.Close()
 		t.Fatal("FrameAlloc returned nil")
 	}
 	defer func() { _ = FrameFree(&frame) }()
-
-	AVUtil.SetFrameWidth(frame, 160)
-	AVUtil.SetFrameHeight(frame, 120)
+	AVUtil.SetFrameWidth(frame, 320)
+	AVUtil.SetFrameHeight(frame, 240)
 	AVUtil.SetFrameFormat(frame, int32(PixelFormatYUV420P))
-
-	// Allocate frame buffer
 	if err := AVUtil.FrameGetBuffer(frame, 0); err != nil {
+		enc.Close()
 		t.Fatalf("FrameGetBuffer failed: %v", err)
 	}
 
-	// Write some frames
-	for i := 0; i < 10; i++ {
-		avutil.SetFramePTS(frame.ptr, int64(i))
+	for i := 0; i < 30; i++ {
+		if err := AVUtil.FrameMakeWritable(frame); err != nil {
+			enc.Close()
+			t.Fatalf("FrameMakeWritable failed: %v", err)
+		}
+		fillTestFrame(frame, i, 320, 240)
 		if err := enc.WriteFrame(frame); err != nil {
-			t.Fatalf("WriteFrame failed: %v", err)
+			enc.Close()
+			t.Fatalf("WriteFrame failed at frame %d: %v", i, err)
 		}
 	}
+	if err := enc.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
 
-	// Verify output file exists and has content
-	stat, err := os.Stat(outPath)
+	info, err := os.Stat(outPath)
 	if err != nil {
-		t.Fatalf("Output file not created: %v", err)
+		t.Fatalf("output not created: %v", err)
 	}
-	if stat.Size() == 0 {
-		t.Error("Output file is empty")
+	if info.Size() == 0 {
+		t.Fatal("output file is empty")
 	}
-
-	t.Logf("Encoded 10 frames with advanced options to %s (%d bytes)", outPath, stat.Size())
 }
 
-// Tests for stream copy / remuxer
+func TestVideoCodecOptionsCappedCRF(t *testing.T) {
+	opts := videoCodecOptions(&VideoEncoderConfig{
+		RateControl: RateControlCRF,
+		CRF:         23,
+		MaxBitrate:  3000000,
+		BufferSize:  6000000,
+	})
+	got := make(map[string]string)
+	for _, o := range opts {
+		got[o.name] = o.value
+	}
+	want := map[string]string{"crf": "23", "maxrate": "3000000", "bufsize": "6000000"}
+	for k, v := range want {
+		if got[k] != v {
+			t.Errorf("option %s = %q, want %q (all: %v)", k, got[k], v, opts)
+		}
+	}
 
-func TestRemuxer(t *testing.T) {
-	if !requireFFmpeg(t) {
-		return
+	cbr := videoCodecOptions(&VideoEncoderConfig{RateControl: RateControlCBR, Bitrate: 1000000, MaxBitrate: 5000000})
+	for _, o := range cbr {
+		if o.name == "maxrate" && o.value != "1000000" {
+			t.Errorf("CBR maxrate = %s, want bitrate", o.value)
+		}
 	}
-	// Create test video file first
-	srcDir := t.TempDir()
-	srcPath := filepath.Join(srcDir, "source.mp4")
+}
 
-	// Create a source file with encoder
-	enc, err := NewEncoder(srcPath, EncoderConfig{
-		Width:     160,
-		Height:    120,
-		FrameRate: 30,
-		BitRate:   500000,
-		GOPSize:   5,
+func TestVideoCodecOptionsDefaultBufferSize(t *testing.T) {
+	tests := []struct {
+		name string
+		cfg  VideoEncoderConfig
+		want string
+	}{
+		{"capped CRF", VideoEncoderConfig{RateControl: RateControlCRF, CRF: 23, MaxBitrate: 3000000}, "6000000"},
+		{"constrained ABR", VideoEncoderConfig{RateControl: RateControlABR, Bitrate: 2000000, MaxBitrate: 4000000}, "8000000"},
+		{"explicit", VideoEncoderConfig{RateControl: RateControlCRF, MaxBitrate: 3000000, BufferSize: 1500000}, "1500000"},
+		{"no maxrate", VideoEncoderConfig{RateControl: RateControlCRF, CRF: 23}, ""},
+	}
+	for _, tt := range tests {
+		got := ""
+		for _, o := range videoCodecOptions(&tt.cfg) {
+			if o.name == "bufsize" {
+				got = o.value
+			}
+		}
+		if got != tt.want {
+			t.Errorf("%s: bufsize = %q, want %q", tt.name, got, tt.want)
+		}
+		want := tt.want
+		if want == "" {
+			want = "0"
+		}
+		if size := fmt.Sprint(videoBufferSize(&tt.cfg)); size != want {
+			t.Errorf("%s: videoBufferSize = %s, want %s", tt.name, size, want)
+		}
+	}
+}
+
+func TestEncoderVideoBufferSize(t *testing.T) {
+	if !requireFFmpeg(t) {
+		return
+	}
+	enc, err := NewEncoderWithOptions(filepath.Join(t.TempDir(), "capped.mp4"), &EncoderOptions{
+		Video: &VideoEncoderConfig{
+			Width:       160,
+			Height:      120,
+			FrameRate:   NewRational(25, 1),
+			PixelFormat: PixelFormatYUV420P,
+			RateControl: RateControlCRF,
+			MaxBitrate:  3000000,
+		},
 	})
 	if err != nil {
-		t.Fatalf("Failed to create encoder: %v", err)
+		t.Fatalf("NewEncoderWithOptions failed: %v", err)
+	}
+	defer enc.Close()
+	if got := enc.VideoBufferSize(); got != 6000000 {
+		t.Errorf("VideoBufferSize() = %d, want the 2*MaxBitrate default 6000000", got)
 	}
+}
 
-	// Create and write frames
-	frame := FrameAlloc()
-	if frame.IsNil() {
-		enc.Close()
-		t.Fatal("Failed to allocate frame")
+func TestEncoderWriteFrameWithPTS(t *testing.T) {
+	if !requireFFmpeg(t) {
+		return
+	}
+	out := filepath.Join(t.TempDir(), "vfr.mkv")
+	enc, err := NewEncoderWithOptions(out, &EncoderOptions{
+		Video: &VideoEncoderConfig{
+			Codec:       CodecIDMJPEG,
+			Width:       160,
+			Height:      120,
+			PixelFormat: PixelFormatYUVJ420P,
+			FrameRate:   NewRational(1000, 1), // 1ms ticks
+		},
+	})
+	if err != nil {
+		t.Fatalf("NewEncoderWithOptions failed: %v", err)
 	}
 
+	frame := FrameAlloc()
+	defer func() { _ = FrameFree(&frame) }()
 	AVUtil.SetFrameWidth(frame, 160)
 	AVUtil.SetFrameHeight(frame, 120)
-	AVUtil.SetFrameFormat(frame, int32(PixelFormatYUV420P))
+	AVUtil.SetFrameFormat(frame, int32(PixelFormatYUVJ420P))
 	if err := AVUtil.FrameGetBuffer(frame, 0); err != nil {
-		_ = FrameFree(&frame)
-		enc.Close()
 		t.Fatalf("FrameGetBuffer failed: %v", err)
 	}
 
-	for i := 0; i < 30; i++ {
-		if err := enc.WriteFrame(frame); err != nil {
-			_ = FrameFree(&frame)
-			enc.Close()
-			t.Fatalf("WriteFrame failed: %v", err)
+	// Variable frame rate source in a 90kHz time base: 0s, 0.1s, 0.5s, 2s.
+	srcTB := NewRational(1, 90000)
+	srcPTS := []int64{0, 9000, 45000, 180000}
+	for i, pts := range srcPTS {
+		if err := AVUtil.FrameMakeWritable(frame); err != nil {
+			t.Fatalf("FrameMakeWritable failed: %v", err)
+		}
+		fillTestFrame(frame, i, 160, 120)
+		if err := enc.WriteFrameWithPTS(frame, pts, srcTB); err != nil {
+			t.Fatalf("WriteFrameWithPTS failed at %d: %v", i, err)
 		}
 	}
-	_ = FrameFree(&frame)
-	enc.Close()
-
-	// Now remux to MKV
-	dstDir := t.TempDir()
-	dstPath := filepath.Join(dstDir, "remuxed.mkv")
-
-	decoder, err := NewDecoder(srcPath)
-	if err != nil {
-		t.Fatalf("Failed to open source: %v", err)
+	if err := enc.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
 	}
 
-	remuxer, err := NewRemuxer(dstPath, decoder, nil)
+	dec, err := NewDecoder(out)
 	if err != nil {
-		decoder.Close()
-		t.Fatalf("Failed to create remuxer: %v", err)
+		t.Fatalf("NewDecoder failed: %v", err)
 	}
-
-	// Remux all packets
-	if err := remuxer.Remux(decoder); err != nil {
-		remuxer.Close()
-		decoder.Close()
-		t.Fatalf("Remux failed: %v", err)
+	defer dec.Close()
+	tb := dec.VideoStream().TimeBase
+	var got []time.Duration
+	for {
+		f, err := dec.DecodeVideo()
+		if err != nil || f.IsNil() {
+			break
+		}
+		got = append(got, ptsToDuration(GetFrameInfo(f).PTS, tb))
 	}
-
-	// Close remuxer BEFORE checking file size (to write trailer)
-	remuxer.Close()
-	decoder.Close()
-
-	// Verify output file exists
-	stat, err := os.Stat(dstPath)
-	if err != nil {
-		t.Fatalf("Output file not created: %v", err)
+	want := []time.Duration{0, 100 * time.Millisecond, 500 * time.Millisecond, 2 * time.Second}
+	if len(got) != len(want) {
+		t.Fatalf("decoded %d frames, want %d", len(got), len(want))
 	}
-	if stat.Size() == 0 {
-		t.Error("Output file is empty")
+	for i := range want {
+		if d := got[i] - want[i]; d < -2*time.Millisecond || d > 2*time.Millisecond {
+			t.Errorf("frame %d at %v, want %v", i, got[i], want[i])
+		}
 	}
-
-	t.Logf("Remuxed %s to %s (%d bytes)", srcPath, dstPath, stat.Size())
 }
 
-func TestRemuxerSelectStreams(t *testing.T) {
+func TestEncoderForceKeyframe(t *testing.T) {
 	if !requireFFmpeg(t) {
 		return
 	}
-	// Create test video file
-	srcDir := t.TempDir()
-	srcPath := filepath.Join(srcDir, "source.mp4")
-
-	enc, err := NewEncoderWithOptions(srcPath, &EncoderOptions{
+	out := filepath.Join(t.TempDir(), "keys.mkv")
+	enc, err := NewEncoderWithOptions(out, &EncoderOptions{
 		Video: &VideoEncoderConfig{
-			Width:       160,
-			Height:      120,
-			FrameRate:   Rational{Num: 30, Den: 1},
-			Bitrate:     500000,
-			PixelFormat: PixelFormatYUV420P,
-			GOPSize:     5,
-		},
-		Audio: &AudioEncoderConfig{
-			SampleRate: 48000,
-			Channels:   2,
-			Bitrate:    64000,
+			Codec:     CodecIDH264,
+			Width:     160,
+			Height:    120,
+			FrameRate: NewRational(10, 1),
+			GOPSize:   250,
 		},
 	})
 	if err != nil {
-		t.Fatalf("Failed to create encoder: %v", err)
+		t.Skipf("H.264 encoder not available: %v", err)
 	}
 
-	// Write video frames
 	frame := FrameAlloc()
-	if frame.IsNil() {
-		enc.Close()
-		t.Fatal("Failed to allocate frame")
-	}
+	defer func() { _ = FrameFree(&frame) }()
 	AVUtil.SetFrameWidth(frame, 160)
 	AVUtil.SetFrameHeight(frame, 120)
 	AVUtil.SetFrameFormat(frame, int32(PixelFormatYUV420P))
-	_ = AVUtil.FrameGetBuffer(frame, 0)
-
-	for i := 0; i < 10; i++ {
-		_ = enc.WriteFrame(frame)
+	if err := AVUtil.FrameGetBuffer(frame, 0); err != nil {
+		t.Fatalf("FrameGetBuffer failed: %v", err)
 	}
-	_ = FrameFree(&frame)
-	enc.Close()
-
-	// Open source and remux only video stream
-	decoder, err := NewDecoder(srcPath)
-	if err != nil {
-		t.Fatalf("Failed to open source: %v", err)
+	const forced = 7
+	for i := 0; i < 15; i++ {
+		if err := AVUtil.FrameMakeWritable(frame); err != nil {
+			t.Fatalf("FrameMakeWritable failed: %v", err)
+		}
+		fillTestFrame(frame, i, 160, 120)
+		if i == forced {
+			enc.ForceKeyframe()
+		}
+		if err := enc.WriteFrame(frame); err != nil {
+			t.Fatalf("WriteFrame failed at %d: %v", i, err)
+		}
+		if got := avutil.GetFramePictType(frame.ptr); got == avutil.PictureTypeI {
+			t.Fatalf("frame %d left marked as intra after WriteFrame", i)
+		}
+	}
+	if err := enc.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
 	}
-	defer decoder.Close()
-
-	dstDir := t.TempDir()
-	dstPath := filepath.Join(dstDir, "video_only.mp4")
 
-	// Only copy video stream (index 0)
-	remuxer, err := NewRemuxer(dstPath, decoder, &RemuxerConfig{
-		InputStreams: []int{0}, // Only video
-	})
+	dec, err := NewDecoder(out)
 	if err != nil {
-		t.Fatalf("Failed to create remuxer: %v", err)
+		t.Fatalf("NewDecoder failed: %v", err)
 	}
-	defer remuxer.Close()
-
-	if remuxer.NumOutputStreams() != 1 {
-		t.Errorf("Expected 1 output stream, got %d", remuxer.NumOutputStreams())
+	defer dec.Close()
+	var keys []int
+	for i := 0; ; i++ {
+		f, err := dec.DecodeVideo()
+		if err != nil || f.IsNil() {
+			break
+		}
+		if avutil.GetFramePictType(f.ptr) == avutil.PictureTypeI {
+			keys = append(keys, i)
+		}
 	}
-
-	if err := remuxer.Remux(decoder); err != nil {
-		t.Fatalf("Remux failed: %v", err)
+	if fmt.Sprint(keys) != fmt.Sprint([]int{0, forced}) {
+		t.Errorf("keyframes at %v, want [0 %d]", keys, forced)
 	}
-
-	t.Log("Successfully remuxed video-only stream")
 }
 
-func TestMetadataRead(t *testing.T) {
+func TestEncoderUseSourcePTS(t *testing.T) {
 	if !requireFFmpeg(t) {
 		return
 	}
-	// Create test video with metadata using ffmpeg CLI
-	tmpDir := t.TempDir()
-	testFile := filepath.Join(tmpDir, "metadata_test.mp4")
+	encode := func(useSourcePTS bool) []time.Duration {
+		out := filepath.Join(t.TempDir(), "pts.mkv")
+		enc, err := NewEncoderWithOptions(out, &EncoderOptions{
+			UseSourcePTS: useSourcePTS,
+			Video: &VideoEncoderConfig{
+				Codec:       CodecIDMJPEG,
+				Width:       160,
+				Height:      120,
+				PixelFormat: PixelFormatYUVJ420P,
+				FrameRate:   NewRational(10, 1),
+			},
+		})
+		if err != nil {
+			t.Fatalf("NewEncoderWithOptions failed: %v", err)
+		}
 
-	cmd := exec.Command("ffmpeg", "-y",
-		"-f", "lavfi", "-i", "testsrc=duration=0.5:size=160x120:rate=30",
-		"-c:v", "libx264", "-preset", "ultrafast",
-		"-metadata", "title=Test Title",
-		"-metadata", "artist=Test Artist",
-		"-metadata", "album=Test Album",
-		"-metadata", "genre=Test Genre",
-		testFile)
+		frame := FrameAlloc()
+		defer func() { _ = FrameFree(&frame) }()
+		AVUtil.SetFrameWidth(frame, 160)
+		AVUtil.SetFrameHeight(frame, 120)
+		AVUtil.SetFrameFormat(frame, int32(PixelFormatYUVJ420P))
+		if err := AVUtil.FrameGetBuffer(frame, 0); err != nil {
+			t.Fatalf("FrameGetBuffer failed: %v", err)
+		}
+		// Caller-assigned PTS with gaps; the last frame has none.
+		for i, pts := range []int64{0, 2, 5, avutil.AV_NOPTS_VALUE} {
+			if err := AVUtil.FrameMakeWritable(frame); err != nil {
+				t.Fatalf("FrameMakeWritable failed: %v", err)
+			}
+			fillTestFrame(frame, i, 160, 120)
+			avutil.SetFramePTS(frame.ptr, pts)
+			if err := enc.WriteFrame(frame); err != nil {
+				t.Fatalf("WriteFrame failed at %d: %v", i, err)
+			}
+		}
+		if err := enc.Close(); err != nil {
+			t.Fatalf("Close failed: %v", err)
+		}
 
-	if err := cmd.Run(); err != nil {
-		t.Logf("ffmpeg not available or failed: %v", err)
-		return
+		dec, err := NewDecoder(out)
+		if err != nil {
+			t.Fatalf("NewDecoder failed: %v", err)
+		}
+		defer dec.Close()
+		tb := dec.VideoStream().TimeBase
+		var got []time.Duration
+		for {
+			f, err := dec.DecodeVideo()
+			if err != nil || f.IsNil() {
+				break
+			}
+			got = append(got, ptsToDuration(GetFrameInfo(f).PTS, tb).Round(10*time.Millisecond))
+		}
+		return got
 	}
 
-	// Open and read metadata
-	decoder, err := NewDecoder(testFile)
-	if err != nil {
-		t.Fatalf("Failed to open file: %v", err)
+	ms := time.Millisecond
+	for _, tt := range []struct {
+		useSourcePTS bool
+		want         []time.Duration
+	}{
+		{false, []time.Duration{0, 100 * ms, 200 * ms, 300 * ms}},
+		{true, []time.Duration{0, 200 * ms, 500 * ms, 600 * ms}},
+	} {
+		got := encode(tt.useSourcePTS)
+		if fmt.Sprint(got) != fmt.Sprint(tt.want) {
+			t.Errorf("UseSourcePTS=%v: frame times %v, want %v", tt.useSourcePTS, got, tt.want)
+		}
 	}
-	defer decoder.Close()
+}
 
-	meta := decoder.GetMetadata()
-	if meta == nil {
-		t.Log("No metadata found (some containers may not store metadata)")
+func TestEncoderStrictOptions(t *testing.T) {
+	if !requireFFmpeg(t) {
 		return
 	}
+	dir := t.TempDir()
 
-	t.Logf("Metadata entries: %d", len(meta))
-	for k, v := range meta {
-		t.Logf("  %s = %s", k, v)
+	// mjpeg has no "preset" option: skipped by default, rejected in strict mode.
+	video := &VideoEncoderConfig{
+		Codec:       CodecIDMJPEG,
+		Width:       160,
+		Height:      120,
+		PixelFormat: PixelFormatYUV420P,
+		Preset:      PresetUltrafast,
 	}
 
-	// Check if we got some metadata
-	if len(meta) == 0 {
-		t.Log("Metadata map is empty")
+	enc, err := NewEncoderWithOptions(filepath.Join(dir, "lenient.avi"), &EncoderOptions{Video: video})
+	if err != nil {
+		t.Fatalf("lenient NewEncoderWithOptions failed: %v", err)
+	}
+	enc.Close()
+
+	enc, err = NewEncoderWithOptions(filepath.Join(dir, "strict.avi"), &EncoderOptions{Video: video, StrictOptions: true})
+	if err == nil {
+		enc.Close()
+		t.Fatal("expected strict mode to reject unsupported preset option")
+	}
+	if !strings.Contains(err.Error(), "preset") {
+		t.Errorf("error %q does not name the rejected option", err)
 	}
 }
 
-func TestMetadataWrite(t *testing.T) {
+func TestEncoderProRes(t *testing.T) {
 	if !requireFFmpeg(t) {
 		return
 	}
-	tmpDir := t.TempDir()
-	testFile := filepath.Join(tmpDir, "metadata_write.mkv")
+	dir := t.TempDir()
+	outPath := filepath.Join(dir, "prores_test.mov")
 
-	// Create encoder with metadata
-	enc, err := NewEncoderWithOptions(testFile, &EncoderOptions{
+	enc, err := NewEncoderWithOptions(outPath, &EncoderOptions{
 		Video: &VideoEncoderConfig{
-			Width:       160,
-			Height:      120,
-			FrameRate:   Rational{Num: 30, Den: 1},
-			Bitrate:     500000,
-			PixelFormat: PixelFormatYUV420P,
-			GOPSize:     5,
+			Codec:     CodecIDProRes,
+			Width:     320,
+			Height:    240,
+			FrameRate: Rational{Num: 25, Den: 1},
 		},
 	})
 	if err != nil {
-		t.Fatalf("Failed to create encoder: %v", err)
+		t.Fatalf("NewEncoderWithOptions with ProRes failed: %v", err)
 	}
+	defer enc.Close()
 
-	// Set metadata before writing header
-	metadata := Metadata{
-		MetadataTitle:  "My Test Video",
-		MetadataArtist: "Test Author",
-		"custom_key":   "custom_value",
-	}
-	if err := enc.SetMetadata(metadata); err != nil {
-		enc.Close()
-		t.Fatalf("SetMetadata failed: %v", err)
-	}
-
-	// Write some frames
-	frame := FrameAlloc()
-	if frame.IsNil() {
-		enc.Close()
-		t.Fatal("Failed to allocate frame")
-	}
-	AVUtil.SetFrameWidth(frame, 160)
-	AVUtil.SetFrameHeight(frame, 120)
-	AVUtil.SetFrameFormat(frame, int32(PixelFormatYUV420P))
-	_ = AVUtil.FrameGetBuffer(frame, 0)
-
-	for i := 0; i < 10; i++ {
-		_ = enc.WriteFrame(frame)
-	}
-	_ = FrameFree(&frame)
-	enc.Close()
-
-	// Verify metadata using ffprobe
-	cmd := exec.Command("ffprobe", "-v", "quiet", "-print_format", "json", "-show_format", testFile)
-	output, err := cmd.Output()
-	if err != nil {
-		t.Logf("ffprobe not available, skipping verification: %v", err)
-	} else {
-		t.Logf("ffprobe output: %s", string(output))
+	if enc.PixelFormat() != PixelFormatYUV422P10LE {
+		t.Errorf("PixelFormat = %d, want yuv422p10le", enc.PixelFormat())
 	}
+}
 
-	// Also verify by opening with decoder
-	decoder, err := NewDecoder(testFile)
-	if err != nil {
-		t.Fatalf("Failed to open written file: %v", err)
+func TestArchivalPixelFormat(t *testing.T) {
+	tests := []struct {
+		codec   CodecID
+		in      PixelFormat
+		profile VideoProfile
+		want    PixelFormat
+		wantErr bool
+	}{
+		{CodecIDProRes, PixelFormatYUV420P, "", PixelFormatYUV422P10LE, false},
+		{CodecIDProRes, PixelFormatNone, ProfileProRes4444, PixelFormatYUV444P10LE, false},
+		{CodecIDProRes, PixelFormatYUVA444P10LE, "", PixelFormatYUVA444P10LE, false},
+		{CodecIDProRes, PixelFormatRGB24, "", PixelFormatRGB24, true},
+		{CodecIDFFV1, PixelFormatYUV420P, "", PixelFormatYUV420P, false},
+		{CodecIDFFV1, PixelFormatNone, "", PixelFormatYUV422P10LE, false},
 	}
-	defer decoder.Close()
-
-	readMeta := decoder.GetMetadata()
-	if readMeta != nil {
-		t.Logf("Read back %d metadata entries", len(readMeta))
-		for k, v := range readMeta {
-			t.Logf("  %s = %s", k, v)
+	for _, tt := range tests {
+		got, err := archivalPixelFormat(tt.codec, tt.in, tt.profile)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("archivalPixelFormat(%v, %d) error = %v, wantErr %v", tt.codec, tt.in, err, tt.wantErr)
+			continue
+		}
+		if !tt.wantErr && got != tt.want {
+			t.Errorf("archivalPixelFormat(%v, %d) = %d, want %d", tt.codec, tt.in, got, tt.want)
 		}
 	}
-}
 
-func TestAvailableHWDeviceTypes(t *testing.T) {
-	if !requireFFmpeg(t) {
-		return
+	userOpts := map[string]string{"slices": "4"}
+	cfg := &VideoEncoderConfig{CodecOptions: userOpts}
+	applyArchivalDefaults(CodecIDFFV1, cfg, PixelFormatYUV422P10LE)
+	if cfg.CodecOptions["level"] != "3" || cfg.CodecOptions["slicecrc"] != "1" {
+		t.Errorf("FFV1 defaults not applied: %v", cfg.CodecOptions)
 	}
-	types := AvailableHWDeviceTypes()
-	t.Logf("Available hardware device types: %d", len(types))
-	for _, hwType := range types {
-		name := GetHWDeviceTypeName(hwType)
-		t.Logf("  - %s (%d)", name, hwType)
+	if len(userOpts) != 1 {
+		t.Errorf("caller's CodecOptions was mutated: %v", userOpts)
 	}
 }
 
-func TestHWDevice(t *testing.T) {
+func TestEncoderWithProfile(t *testing.T) {
 	if !requireFFmpeg(t) {
 		return
 	}
-	// Find available hardware device
-	types := AvailableHWDeviceTypes()
-	if len(types) == 0 {
-		t.Log("No hardware acceleration available")
-		return
-	}
-
-	hwType := types[0]
-	t.Logf("Testing with %s", GetHWDeviceTypeName(hwType))
+	dir := t.TempDir()
+	outPath := filepath.Join(dir, "profile_test.mp4")
 
-	device, err := NewHWDevice(hwType, "")
+	enc, err := NewEncoderWithOptions(outPath, &EncoderOptions{
+		Video: &VideoEncoderConfig{
+			Width:       320,
+			Height:      240,
+			FrameRate:   Rational{Num: 30, Den: 1},
+			Bitrate:     500000,
+			PixelFormat: PixelFormatYUV420P,
+			Profile:     ProfileBaseline, // Most compatible
+			Level:       Level3,
+			Preset:      PresetFast,
+			GOPSize:     10,
+		},
+	})
 	if err != nil {
-		t.Logf("Failed to create HW device: %v", err)
-		return
-	}
-	defer device.Close()
-
-	if device.Type() != hwType {
-		t.Errorf("Expected type %d, got %d", hwType, device.Type())
-	}
-
-	if device.TypeName() == "" {
-		t.Error("TypeName returned empty string")
-	}
-
-	if device.Context() == nil {
-		t.Error("Context returned nil")
+		t.Fatalf("NewEncoderWithOptions with profile failed: %v", err)
 	}
+	defer enc.Close()
 
-	t.Logf("HW device created: %s", device.TypeName())
+	t.Logf("Encoder with profile=%s level=%s created successfully", ProfileBaseline, Level3)
 }
 
-func TestHWDecoder(t *testing.T) {
+func TestEncoderWithCodecOptions(t *testing.T) {
 	if !requireFFmpeg(t) {
 		return
 	}
-	// Find available hardware device
-	types := AvailableHWDeviceTypes()
-	if len(types) == 0 {
-		t.Log("No hardware acceleration available")
-		return
-	}
-
-	hwType := types[0]
-	t.Logf("Testing HW decoding with %s", GetHWDeviceTypeName(hwType))
-
-	// Create HW device
-	device, err := NewHWDevice(hwType, "")
-	if err != nil {
-		t.Logf("Failed to create HW device: %v", err)
-		return
-	}
-	defer device.Close()
-
-	// Create a test video file
-	testFile := createTestVideo(t)
-	if testFile == "" {
-		return
-	}
+	dir := t.TempDir()
+	outPath := filepath.Join(dir, "options_test.mp4")
 
-	// Create HW decoder
-	hwDec, err := NewHWDecoder(testFile, &HWDecoderConfig{
-		HWDevice:             device,
-		OutputSoftwareFrames: true,
+	enc, err := NewEncoderWithOptions(outPath, &EncoderOptions{
+		Video: &VideoEncoderConfig{
+			Width:       320,
+			Height:      240,
+			FrameRate:   Rational{Num: 30, Den: 1},
+			Bitrate:     500000,
+			PixelFormat: PixelFormatYUV420P,
+			Preset:      PresetFast,
+			GOPSize:     10,
+			CodecOptions: map[string]string{
+				"bf": "0", // No B-frames
+			},
+		},
 	})
 	if err != nil {
-		t.Logf("HW decoder not supported for this codec/device: %v", err)
-		return
-	}
-	defer hwDec.Close()
-
-	t.Logf("Video: %dx%d", hwDec.VideoStream().Width, hwDec.VideoStream().Height)
-
-	// Decode a few frames
-	frameCount := 0
-	for frameCount < 5 {
-		frame, err := hwDec.DecodeVideo()
-		if err != nil {
-			if IsEOF(err) {
-				break
-			}
-			t.Logf("Decode error (may be expected for some HW decoders): %v", err)
-			break
-		}
-		if !frame.IsNil() {
-			frameCount++
-		}
+		t.Fatalf("NewEncoderWithOptions with codec options failed: %v", err)
 	}
+	defer enc.Close()
 
-	t.Logf("Decoded %d frames using hardware acceleration", frameCount)
+	t.Log("Encoder with custom codec options created successfully")
 }
 
-func TestSeekPrecise(t *testing.T) {
+func TestEncoderWithAdvancedOptionsEncode(t *testing.T) {
 	if !requireFFmpeg(t) {
 		return
 	}
-	testFile := createTestVideo(t)
-	if testFile == "" {
-		return
-	}
+	dir := t.TempDir()
+	outPath := filepath.Join(dir, "advanced_encode.mp4")
 
-	decoder, err := NewDecoder(testFile)
+	enc, err := NewEncoderWithOptions(outPath, &EncoderOptions{
+		Video: &VideoEncoderConfig{
+			Width:       160,
+			Height:      120,
+			FrameRate:   Rational{Num: 30, Den: 1},
+			PixelFormat: PixelFormatYUV420P,
+			RateControl: RateControlCRF,
+			CRF:         28,
+			Preset:      PresetUltrafast,
+			Profile:     ProfileHigh,
+			GOPSize:     5,
+			MaxBFrames:  0,
+		},
+	})
 	if err != nil {
-		t.Fatalf("Failed to open file: %v", err)
+		t.Fatalf("NewEncoderWithOptions failed: %v", err)
 	}
-	defer decoder.Close()
+	defer enc.Close()
 
-	// Open video decoder
-	if err := decoder.OpenVideoDecoder(); err != nil {
-		t.Fatalf("Failed to open video decoder: %v", err)
+	// Create test frame
+	frame := FrameAlloc()
+	if frame.IsNil() {
+		t.Fatal("FrameAlloc returned nil")
 	}
+	defer func() { _ = FrameFree(&frame) }()
 
-	// Test seeking to middle of file
-	duration := decoder.Duration()
-	t.Logf("Duration: %v", duration)
+	AVUtil.SetFrameWidth(frame, 160)
+	AVUtil.SetFrameHeight(frame, 120)
+	AVUtil.SetFrameFormat(frame, int32(PixelFormatYUV420P))
 
-	midPoint := duration / 2
-	if err := decoder.SeekPrecise(midPoint); err != nil {
-		t.Fatalf("SeekPrecise failed: %v", err)
+	// Allocate frame buffer
+	if err := AVUtil.FrameGetBuffer(frame, 0); err != nil {
+		t.Fatalf("FrameGetBuffer failed: %v", err)
 	}
 
-	// Decode a frame to verify seek worked
-	frame, err := decoder.DecodeVideo()
+	// Write some frames
+	for i := 0; i < 10; i++ {
+		avutil.SetFramePTS(frame.ptr, int64(i))
+		if err := enc.WriteFrame(frame); err != nil {
+			t.Fatalf("WriteFrame failed: %v", err)
+		}
+	}
+
+	// Verify output file exists and has content
+	stat, err := os.Stat(outPath)
 	if err != nil {
-		t.Fatalf("Failed to decode after seek: %v", err)
+		t.Fatalf("Output file not created: %v", err)
 	}
-	if frame.IsNil() {
-		t.Error("Got nil frame after seek")
+	if stat.Size() == 0 {
+		t.Error("Output file is empty")
 	}
 
-	t.Logf("Successfully seeked to %v and decoded a frame", midPoint)
+	t.Logf("Encoded 10 frames with advanced options to %s (%d bytes)", outPath, stat.Size())
 }
 
-func TestSeekToFrame(t *testing.T) {
+// Tests for stream copy / remuxer
+
+func TestRemuxer(t *testing.T) {
 	if !requireFFmpeg(t) {
 		return
 	}
-	testFile := createTestVideo(t)
-	if testFile == "" {
-		return
-	}
-
-	decoder, err := NewDecoder(testFile)
-	if err != nil {
-		t.Fatalf("Failed to open file: %v", err)
-	}
-	defer decoder.Close()
+	// Create test video file first
+	srcDir := t.TempDir()
+	srcPath := filepath.Join(srcDir, "source.mp4")
 
-	// Get total frames estimate
-	totalFrames := decoder.TotalFrames()
-	t.Logf("Estimated total frames: %d", totalFrames)
+	// Create a source file with encoder
+	enc, err := NewEncoder(srcPath, EncoderConfig{
+		Width:     160,
+		Height:    120,
+		FrameRate: 30,
+		BitRate:   500000,
+		GOPSize:   5,
+	})
+	if err != nil {
+		t.Fatalf("Failed to create encoder: %v", err)
+	}
 
-	if totalFrames <= 0 {
-		t.Log("Cannot determine total frames")
-		return
+	// Create and write frames
+	frame := FrameAlloc()
+	if frame.IsNil() {
+		enc.Close()
+		t.Fatal("Failed to allocate frame")
 	}
 
-	// Open video decoder
-	if err := decoder.OpenVideoDecoder(); err != nil {
-		t.Fatalf("Failed to open video decoder: %v", err)
+	AVUtil.SetFrameWidth(frame, 160)
+	AVUtil.SetFrameHeight(frame, 120)
+	AVUtil.SetFrameFormat(frame, int32(PixelFormatYUV420P))
+	if err := AVUtil.FrameGetBuffer(frame, 0); err != nil {
+		_ = FrameFree(&frame)
+		enc.Close()
+		t.Fatalf("FrameGetBuffer failed: %v", err)
 	}
 
-	// Seek to frame 10
-	targetFrame := int64(10)
-	if targetFrame >= totalFrames {
-		targetFrame = totalFrames / 2
+	for i := 0; i < 30; i++ {
+		if err := enc.WriteFrame(frame); err != nil {
+			_ = FrameFree(&frame)
+			enc.Close()
+			t.Fatalf("WriteFrame failed: %v", err)
+		}
 	}
+	_ = FrameFree(&frame)
+	enc.Close()
 
-	if err := decoder.SeekToFrame(targetFrame); err != nil {
-		t.Fatalf("SeekToFrame failed: %v", err)
+	// Now remux to MKV
+	dstDir := t.TempDir()
+	dstPath := filepath.Join(dstDir, "remuxed.mkv")
+
+	decoder, err := NewDecoder(srcPath)
+	if err != nil {
+		t.Fatalf("Failed to open source: %v", err)
 	}
 
-	// Decode a frame
-	frame, err := decoder.DecodeVideo()
+	remuxer, err := NewRemuxer(dstPath, decoder, nil)
 	if err != nil {
-		t.Fatalf("Failed to decode after frame seek: %v", err)
+		decoder.Close()
+		t.Fatalf("Failed to create remuxer: %v", err)
 	}
-	if frame.IsNil() {
-		t.Error("Got nil frame after seek")
+
+	// Remux all packets
+	if err := remuxer.Remux(decoder); err != nil {
+		remuxer.Close()
+		decoder.Close()
+		t.Fatalf("Remux failed: %v", err)
 	}
 
-	t.Logf("Successfully seeked to frame %d", targetFrame)
+	// Close remuxer BEFORE checking file size (to write trailer)
+	remuxer.Close()
+	decoder.Close()
+
+	// Verify output file exists
+	stat, err := os.Stat(dstPath)
+	if err != nil {
+		t.Fatalf("Output file not created: %v", err)
+	}
+	if stat.Size() == 0 {
+		t.Error("Output file is empty")
+	}
+
+	t.Logf("Remuxed %s to %s (%d bytes)", srcPath, dstPath, stat.Size())
 }
 
-func TestExtractThumbnail(t *testing.T) {
+func TestRemuxerSelectStreams(t *testing.T) {
 	if !requireFFmpeg(t) {
 		return
 	}
-	testFile := createTestVideo(t)
-	if testFile == "" {
-		return
-	}
+	// Create test video file
+	srcDir := t.TempDir()
+	srcPath := filepath.Join(srcDir, "source.mp4")
 
-	decoder, err := NewDecoder(testFile)
+	enc, err := NewEncoderWithOptions(srcPath, &EncoderOptions{
+		Video: &VideoEncoderConfig{
+			Width:       160,
+			Height:      120,
+			FrameRate:   Rational{Num: 30, Den: 1},
+			Bitrate:     500000,
+			PixelFormat: PixelFormatYUV420P,
+			GOPSize:     5,
+		},
+		Audio: &AudioEncoderConfig{
+			SampleRate: 48000,
+			Channels:   2,
+			Bitrate:    64000,
+		},
+	})
 	if err != nil {
-		t.Fatalf("Failed to open file: %v", err)
+		t.Fatalf("Failed to create encoder: %v", err)
 	}
-	defer decoder.Close()
 
-	duration := decoder.Duration()
-	midPoint := duration / 2
+	// Write video frames
+	frame := FrameAlloc()
+	if frame.IsNil() {
+		enc.Close()
+		t.Fatal("Failed to allocate frame")
+	}
+	AVUtil.SetFrameWidth(frame, 160)
+	AVUtil.SetFrameHeight(frame, 120)
+	AVUtil.SetFrameFormat(frame, int32(PixelFormatYUV420P))
+	_ = AVUtil.FrameGetBuffer(frame, 0)
 
-	thumbnail, err := decoder.ExtractThumbnail(midPoint)
-	if err != nil {
-		t.Fatalf("ExtractThumbnail failed: %v", err)
+	for i := 0; i < 10; i++ {
+		_ = enc.WriteFrame(frame)
 	}
+	_ = FrameFree(&frame)
+	enc.Close()
 
-	if thumbnail.IsNil() {
-		t.Error("Got nil thumbnail")
+	// Open source and remux only video stream
+	decoder, err := NewDecoder(srcPath)
+	if err != nil {
+		t.Fatalf("Failed to open source: %v", err)
 	}
-	defer func() { _ = FrameFree(&thumbnail) }()
+	defer decoder.Close()
 
-	t.Logf("Extracted thumbnail at %v", midPoint)
-}
+	dstDir := t.TempDir()
+	dstPath := filepath.Join(dstDir, "video_only.mp4")
 
-func TestExtractThumbnails(t *testing.T) {
-	if !requireFFmpeg(t) {
-		return
+	// Only copy video stream (index 0)
+	remuxer, err := NewRemuxer(dstPath, decoder, &RemuxerConfig{
+		InputStreams: []int{0}, // Only video
+	})
+	if err != nil {
+		t.Fatalf("Failed to create remuxer: %v", err)
 	}
-	testFile := createTestVideo(t)
-	if testFile == "" {
-		return
+	defer remuxer.Close()
+
+	if remuxer.NumOutputStreams() != 1 {
+		t.Errorf("Expected 1 output stream, got %d", remuxer.NumOutputStreams())
 	}
 
-	decoder, err := NewDecoder(testFile)
-	if err != nil {
-		t.Fatalf("Failed to open file: %v", err)
+	if err := remuxer.Remux(decoder); err != nil {
+		t.Fatalf("Remux failed: %v", err)
 	}
-	defer decoder.Close()
 
-	// Extract 3 thumbnails
-	thumbnails, err := decoder.ExtractThumbnails(3)
+	t.Log("Successfully remuxed video-only stream")
+}
+
+func TestOrderedInputStreams(t *testing.T) {
+	got, err := orderedInputStreams([]StreamMapping{{Input: 0, Output: 1}, {Input: 2, Output: 0}})
 	if err != nil {
-		t.Fatalf("ExtractThumbnails failed: %v", err)
+		t.Fatalf("orderedInputStreams failed: %v", err)
 	}
-
-	if len(thumbnails) != 3 {
-		t.Errorf("Expected 3 thumbnails, got %d", len(thumbnails))
+	if len(got) != 2 || got[0] != 2 || got[1] != 0 {
+		t.Errorf("orderedInputStreams = %v, want [2 0]", got)
 	}
 
-	// Free the thumbnails
-	for i, th := range thumbnails {
-		if th.IsNil() {
-			t.Errorf("Thumbnail %d is nil", i)
-		} else {
-			_ = FrameFree(&th)
+	for _, bad := range [][]StreamMapping{
+		{{Input: 0, Output: 1}},
+		{{Input: 0, Output: 0}, {Input: 1, Output: 0}},
+		{{Input: 0, Output: 0}, {Input: 0, Output: 1}},
+		{{Input: 0, Output: -1}},
+	} {
+		if _, err := orderedInputStreams(bad); err == nil {
+			t.Errorf("orderedInputStreams(%v) succeeded, want error", bad)
 		}
 	}
-
-	t.Logf("Extracted %d thumbnails", len(thumbnails))
 }
 
-func TestTotalFrames(t *testing.T) {
+func TestRemuxerOutputMap(t *testing.T) {
 	if !requireFFmpeg(t) {
 		return
 	}
-	testFile := createTestVideo(t)
-	if testFile == "" {
-		return
+	srcPath := filepath.Join(t.TempDir(), "source.mp4")
+	enc, err := NewEncoderWithOptions(srcPath, &EncoderOptions{
+		Video: &VideoEncoderConfig{
+			Width:     160,
+			Height:    120,
+			FrameRate: Rational{Num: 30, Den: 1},
+			GOPSize:   5,
+		},
+		Audio: &AudioEncoderConfig{SampleRate: 48000, Channels: 2},
+	})
+	if err != nil {
+		t.Fatalf("Failed to create encoder: %v", err)
+	}
+	frame := FrameAlloc()
+	AVUtil.SetFrameWidth(frame, 160)
+	AVUtil.SetFrameHeight(frame, 120)
+	AVUtil.SetFrameFormat(frame, int32(PixelFormatYUV420P))
+	_ = AVUtil.FrameGetBuffer(frame, 0)
+	for i := 0; i < 10; i++ {
+		_ = enc.WriteFrame(frame)
 	}
+	_ = FrameFree(&frame)
+	enc.Close()
 
-	decoder, err := NewDecoder(testFile)
+	decoder, err := NewDecoder(srcPath)
 	if err != nil {
-		t.Fatalf("Failed to open file: %v", err)
+		t.Fatalf("Failed to open source: %v", err)
 	}
 	defer decoder.Close()
+	if decoder.VideoStream() == nil || decoder.AudioStream() == nil {
+		t.Fatal("source needs a video and an audio stream")
+	}
+	videoIdx, audioIdx := decoder.VideoStream().Index, decoder.AudioStream().Index
 
-	totalFrames := decoder.TotalFrames()
-	t.Logf("Total frames: %d", totalFrames)
+	dstPath := filepath.Join(t.TempDir(), "audio_first.mkv")
+	remuxer, err := NewRemuxer(dstPath, decoder, &RemuxerConfig{
+		OutputMap: []StreamMapping{
+			{Input: audioIdx, Output: 0},
+			{Input: videoIdx, Output: 1},
+		},
+	})
+	if err != nil {
+		t.Fatalf("Failed to create remuxer: %v", err)
+	}
+	if n := remuxer.NumOutputStreams(); n != 2 {
+		t.Errorf("NumOutputStreams = %d, want 2", n)
+	}
+	if m := remuxer.StreamMapping(); m[audioIdx] != 0 || m[videoIdx] != 1 {
+		t.Errorf("StreamMapping = %v, want audio->0, video->1", m)
+	}
+	if err := remuxer.Remux(decoder); err != nil {
+		remuxer.Close()
+		t.Fatalf("Remux failed: %v", err)
+	}
+	if err := remuxer.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
 
-	// The test video is 1 second at 30fps, so should be ~30 frames
-	if totalFrames < 20 || totalFrames > 40 {
-		t.Logf("Warning: unexpected frame count %d for 1s@30fps video", totalFrames)
+	out, err := NewDecoder(dstPath)
+	if err != nil {
+		t.Fatalf("Failed to open output: %v", err)
+	}
+	defer out.Close()
+	if s := out.StreamInfoByIndex(0); s == nil || s.Type != MediaTypeAudio {
+		t.Errorf("output stream 0 = %+v, want audio", s)
+	}
+	if s := out.StreamInfoByIndex(1); s == nil || s.Type != MediaTypeVideo {
+		t.Errorf("output stream 1 = %+v, want video", s)
 	}
 }
 
-func TestSubtitleDetection(t *testing.T) {
+func TestRemuxerStreamMetadataAndDisposition(t *testing.T) {
 	if !requireFFmpeg(t) {
 		return
 	}
-	// Test video without subtitles
-	testFile := createTestVideo(t)
-	if testFile == "" {
-		return
-	}
+	srcPath := createTestVideo(t)
 
-	decoder, err := NewDecoder(testFile)
+	decoder, err := NewDecoder(srcPath)
 	if err != nil {
-		t.Fatalf("Failed to open file: %v", err)
+		t.Fatalf("Failed to open source: %v", err)
 	}
 	defer decoder.Close()
-
-	hasSubtitle := decoder.HasSubtitle()
-	t.Logf("Has subtitle: %v", hasSubtitle)
-
-	// Our test video should not have subtitles
-	if hasSubtitle {
-		t.Log("Unexpectedly found subtitle stream")
+	video := decoder.VideoStream()
+	if video == nil {
+		t.Fatal("source has no video stream")
 	}
+	srcMeta := decoder.GetStreamMetadata(video.Index)
 
-	subInfo := decoder.SubtitleStream()
-	if subInfo != nil {
-		t.Logf("Subtitle stream: %+v", subInfo)
-	} else {
-		t.Log("No subtitle stream (expected)")
+	if _, err := NewRemuxer(filepath.Join(t.TempDir(), "bad.mkv"), decoder, &RemuxerConfig{
+		InputStreams: []int{video.Index},
+		Dispositions: map[int]int{1: avformat.AV_DISPOSITION_DEFAULT},
+	}); err == nil {
+		t.Error("NewRemuxer with disposition for a missing output stream succeeded, want error")
 	}
-}
-
-func createTestVideoWithSubtitles(t *testing.T) string {
-	t.Helper()
-
-	tmpDir := t.TempDir()
 
-	// Create an SRT subtitle file
-	srtFile := filepath.Join(tmpDir, "test.srt")
-	srtContent := `1
-00:00:00,000 --> 00:00:01,000
-Hello World
+	dstPath := filepath.Join(t.TempDir(), "tagged.mkv")
+	remuxer, err := NewRemuxer(dstPath, decoder, &RemuxerConfig{
+		InputStreams:       []int{video.Index},
+		CopyStreamMetadata: true,
+		Dispositions:       map[int]int{0: avformat.AV_DISPOSITION_DEFAULT | avformat.AV_DISPOSITION_FORCED},
+	})
+	if err != nil {
+		t.Fatalf("Failed to create remuxer: %v", err)
+	}
+	if err := remuxer.Remux(decoder); err != nil {
+		remuxer.Close()
+		t.Fatalf("Remux failed: %v", err)
+	}
+	if err := remuxer.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
 
-2
-00:00:01,000 --> 00:00:02,000
-Test Subtitle
-`
-	if err := os.WriteFile(srtFile, []byte(srtContent), 0644); err != nil {
-		t.Logf("Failed to write SRT file: %v", err)
-		return ""
+	out, err := NewDecoder(dstPath)
+	if err != nil {
+		t.Fatalf("Failed to open output: %v", err)
 	}
+	defer out.Close()
 
-	testFile := filepath.Join(tmpDir, "test_with_subs.mkv")
+	if lang, ok := srcMeta["language"]; ok {
+		if got := out.GetStreamMetadata(0)["language"]; got != lang {
+			t.Errorf("language = %q, want %q", got, lang)
+		}
+	}
+	disposition := avformat.GetStreamDisposition(avformat.GetStream(out.formatCtx, 0))
+	if disposition&avformat.AV_DISPOSITION_FORCED == 0 {
+		t.Errorf("disposition = %#x, want forced flag set", disposition)
+	}
+}
 
-	cmd := exec.Command("ffmpeg", "-y",
-		"-f", "lavfi", "-i", "testsrc=duration=2:size=160x120:rate=30",
-		"-i", srtFile,
-		"-c:v", "libx264", "-preset", "ultrafast",
-		"-c:s", "srt",
-		testFile)
+// writeRotatedMP4 copies the MP4 at src to dst with the video track's tkhd
+// matrix set to the 90-degree rotation phones write for portrait recordings.
+func writeRotatedMP4(t *testing.T, src, dst string) {
+	t.Helper()
+	data, err := os.ReadFile(src)
+	if err != nil {
+		t.Fatal(err)
+	}
+	matrix := [9]int32{0, 1 << 16, 0, -1 << 16, 0, 0, 0, 0, 1 << 30}
+	patched := false
+	for off := 0; ; {
+		i := bytes.Index(data[off:], []byte("tkhd"))
+		if i < 0 {
+			break
+		}
+		box := data[off+i+4:]
+		off += i + 4
+		start := 40 // version 0: 32-bit times
+		if box[0] == 1 {
+			start = 52
+		}
+		if len(box) < start+44 || binary.BigEndian.Uint32(box[start+36:]) == 0 {
+			continue // not a video track (zero width)
+		}
+		for j, v := range matrix {
+			binary.BigEndian.PutUint32(box[start+4*j:], uint32(v))
+		}
+		patched = true
+	}
+	if !patched {
+		t.Fatal("no video tkhd box found")
+	}
+	if err := os.WriteFile(dst, data, 0o644); err != nil {
+		t.Fatal(err)
+	}
+}
 
-	if err := cmd.Run(); err != nil {
-		t.Logf("ffmpeg with subtitles failed: %v", err)
-		return ""
+func TestRemuxerPreservesRotation(t *testing.T) {
+	if !requireFFmpeg(t) {
+		return
 	}
+	srcPath := filepath.Join(t.TempDir(), "portrait.mp4")
+	writeRotatedMP4(t, createTestVideo(t), srcPath)
 
-	if _, err := os.Stat(testFile); err != nil {
-		t.Logf("Test file not created: %v", err)
-		return ""
+	decoder, err := NewDecoder(srcPath)
+	if err != nil {
+		t.Fatalf("Failed to open source: %v", err)
+	}
+	defer decoder.Close()
+	video := decoder.VideoStream()
+	if video == nil {
+		t.Fatal("source has no video stream")
+	}
+	if video.Rotation != 90 {
+		t.Skipf("source rotation = %d; display matrix not readable with this FFmpeg setup", video.Rotation)
 	}
 
-	return testFile
+	dstPath := filepath.Join(t.TempDir(), "copy.mp4")
+	remuxer, err := NewRemuxer(dstPath, decoder, nil)
+	if err != nil {
+		t.Fatalf("Failed to create remuxer: %v", err)
+	}
+	if err := remuxer.Remux(decoder); err != nil {
+		remuxer.Close()
+		t.Fatalf("Remux failed: %v", err)
+	}
+	if err := remuxer.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+
+	out, err := NewDecoder(dstPath)
+	if err != nil {
+		t.Fatalf("Failed to open output: %v", err)
+	}
+	defer out.Close()
+	if got := out.VideoStream().Rotation; got != 90 {
+		t.Errorf("output rotation = %d, want 90", got)
+	}
 }
 
-func TestSubtitleDecoder(t *testing.T) {
+func TestRemuxContextCancel(t *testing.T) {
 	if !requireFFmpeg(t) {
 		return
 	}
-	testFile := createTestVideoWithSubtitles(t)
-	if testFile == "" {
-		t.Log("Could not create test file with subtitles")
+	srcPath := createTestVideo(t)
+
+	for _, finalize := range []bool{false, true} {
+		decoder, err := NewDecoder(srcPath)
+		if err != nil {
+			t.Fatalf("Failed to open source: %v", err)
+		}
+		defer decoder.Close()
+
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		dstPath := filepath.Join(t.TempDir(), "partial.mkv")
+		remuxer, err := NewRemuxer(dstPath, decoder, &RemuxerConfig{
+			FinalizeOnCancel: finalize,
+			OnProgress: func(p Progress) {
+				if p.FramesDone >= 5 {
+					cancel()
+				}
+			},
+		})
+		if err != nil {
+			t.Fatalf("Failed to create remuxer: %v", err)
+		}
+		if err := remuxer.RemuxContext(ctx, decoder); !errors.Is(err, context.Canceled) {
+			remuxer.Close()
+			t.Fatalf("FinalizeOnCancel=%v: RemuxContext = %v, want context.Canceled", finalize, err)
+		}
+		if err := remuxer.Close(); err != nil {
+			t.Fatalf("FinalizeOnCancel=%v: Close failed: %v", finalize, err)
+		}
+		if !finalize {
+			continue
+		}
+
+		out, err := NewDecoder(dstPath)
+		if err != nil {
+			t.Fatalf("finalized output does not open: %v", err)
+		}
+		defer out.Close()
+		if d := out.Duration(); d <= 0 || d >= decoder.Duration() {
+			t.Errorf("finalized output duration = %v, want less than the source's %v", d, decoder.Duration())
+		}
+	}
+}
+
+func TestRemuxerRegeneratePTS(t *testing.T) {
+	if !requireFFmpeg(t) {
 		return
 	}
+	srcPath := createTestVideo(t)
 
-	dec, err := NewDecoder(testFile)
+	decoder, err := NewDecoder(srcPath)
 	if err != nil {
-		t.Fatalf("Failed to open file: %v", err)
+		t.Fatalf("Failed to open source: %v", err)
 	}
-	defer dec.Close()
+	defer decoder.Close()
 
-	subStream := dec.SubtitleStream()
-	if subStream == nil {
-		t.Log("No subtitle stream found")
-		return
+	dstPath := filepath.Join(t.TempDir(), "genpts.mkv")
+	remuxer, err := NewRemuxer(dstPath, decoder, &RemuxerConfig{RegeneratePTS: true})
+	if err != nil {
+		t.Fatalf("Failed to create remuxer: %v", err)
+	}
+	if err := remuxer.Remux(decoder); err != nil {
+		remuxer.Close()
+		t.Fatalf("Remux failed: %v", err)
+	}
+	if err := remuxer.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
 	}
 
-	subDec, err := NewSubtitleDecoder(subStream)
+	out, err := NewDecoder(dstPath)
 	if err != nil {
-		t.Fatalf("Failed to create subtitle decoder: %v", err)
+		t.Fatalf("Failed to open output: %v", err)
 	}
-	defer subDec.Close()
-
-	t.Logf("Subtitle stream: %+v", subDec.StreamInfo())
+	defer out.Close()
 
-	// Decode subtitles by reading packets from the main decoder (docs-aligned flow)
-	subCount := 0
-	for subCount < 5 {
-		pkt, err := dec.ReadPacket()
+	lastDTS := make(map[int]int64)
+	for {
+		pkt, err := out.ReadPacket()
 		if err != nil {
 			t.Fatalf("ReadPacket failed: %v", err)
 		}
 		if pkt == nil {
 			break
 		}
-		if pkt.StreamIndex() != dec.SubtitleStreamIndex() {
-			continue
-		}
-
-		sub, err := subDec.Decode(pkt)
-		if err != nil {
-			t.Fatalf("Decode failed: %v", err)
+		idx, pts, dts := pkt.StreamIndex(), pkt.PTS(), pkt.DTS()
+		if last, ok := lastDTS[idx]; ok && dts != avutil.AV_NOPTS_VALUE && dts <= last {
+			t.Errorf("stream %d: DTS not increasing (%d after %d)", idx, dts, last)
 		}
-		if sub != nil {
-			subCount++
-			t.Logf("Subtitle %d: %s - %s, text: %q", subCount, sub.StartTime, sub.EndTime, sub.Text)
+		if pts != avutil.AV_NOPTS_VALUE && dts != avutil.AV_NOPTS_VALUE && pts < dts {
+			t.Errorf("stream %d: PTS %d earlier than DTS %d", idx, pts, dts)
 		}
+		lastDTS[idx] = dts
+	}
+	if len(lastDTS) == 0 {
+		t.Error("no packets in remuxed output")
 	}
-
-	t.Logf("Decoded %d subtitles", subCount)
 }
 
-func TestBitstreamFilterExists(t *testing.T) {
+func TestRemuxerTrim(t *testing.T) {
 	if !requireFFmpeg(t) {
 		return
 	}
-	filters := ListBitstreamFilters()
-	t.Logf("Checking %d known bitstream filters", len(filters))
+	srcPath := createTestVideo(t)
 
-	for _, name := range filters {
-		exists := BitstreamFilterExists(name)
-		t.Logf("  %s: %v", name, exists)
+	decoder, err := NewDecoder(srcPath)
+	if err != nil {
+		t.Fatalf("Failed to open source: %v", err)
+	}
+	defer decoder.Close()
+	total := decoder.Duration()
+	if total <= 0 {
+		t.Skip("source duration unknown")
 	}
+	start, end := total/4, total/2
 
-	// At least null filter should exist
-	if !BitstreamFilterExists(BSFNameNull) {
-		t.Error("Expected null BSF to exist")
+	if _, err := NewRemuxer(filepath.Join(t.TempDir(), "bad.mkv"), decoder, &RemuxerConfig{StartTime: end, EndTime: start}); err == nil {
+		t.Error("NewRemuxer with EndTime before StartTime succeeded, want error")
+	}
+
+	dstPath := filepath.Join(t.TempDir(), "clip.mkv")
+	remuxer, err := NewRemuxer(dstPath, decoder, &RemuxerConfig{StartTime: start, EndTime: end})
+	if err != nil {
+		t.Fatalf("Failed to create remuxer: %v", err)
+	}
+	if err := remuxer.Remux(decoder); err != nil {
+		remuxer.Close()
+		t.Fatalf("Remux failed: %v", err)
+	}
+	if err := remuxer.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+
+	out, err := NewDecoder(dstPath)
+	if err != nil {
+		t.Fatalf("Failed to open output: %v", err)
+	}
+	defer out.Close()
+
+	if got := out.Duration(); got <= 0 || got >= total-start {
+		t.Errorf("clip duration = %v, want less than %v", got, total-start)
+	}
+	pkt, err := out.ReadPacket()
+	if err != nil || pkt == nil {
+		t.Fatalf("ReadPacket = %v, %v", pkt, err)
+	}
+	tb := out.StreamInfoByIndex(pkt.StreamIndex()).TimeBase
+	if first := rescaleTS(pkt.DTS(), tb, avutil.TimeBaseMicro); first < -100000 || first > 100000 {
+		t.Errorf("first packet at %dus, want the clip to start near zero", first)
 	}
 }
 
-func TestBitstreamFilterNull(t *testing.T) {
+func TestRemuxerTrimStartOffset(t *testing.T) {
 	if !requireFFmpeg(t) {
 		return
 	}
-	// Create null filter (passthrough)
-	bsf, err := NewBitstreamFilter(BSFNameNull)
+	// MPEG-TS streams start well after zero; EndTime is measured from the
+	// start of the input, not from timestamp zero.
+	srcPath := filepath.Join(t.TempDir(), "offset.ts")
+	cmd := exec.Command("ffmpeg", "-y",
+		"-f", "lavfi", "-i", "testsrc=duration=2:size=160x120:rate=25",
+		"-c:v", "mpeg2video", "-output_ts_offset", "10",
+		srcPath)
+	if err := cmd.Run(); err != nil {
+		t.Skipf("ffmpeg not available or failed: %v", err)
+	}
+
+	decoder, err := NewDecoder(srcPath)
 	if err != nil {
-		t.Fatalf("Failed to create null BSF: %v", err)
+		t.Fatalf("Failed to open source: %v", err)
 	}
-	defer bsf.Close()
+	defer decoder.Close()
 
-	// Initialize (null filter doesn't need codec params)
-	if err := bsf.Init(); err != nil {
-		t.Fatalf("Failed to init BSF: %v", err)
+	dstPath := filepath.Join(t.TempDir(), "clip.mkv")
+	remuxer, err := NewRemuxer(dstPath, decoder, &RemuxerConfig{EndTime: time.Second})
+	if err != nil {
+		t.Fatalf("Failed to create remuxer: %v", err)
+	}
+	if err := remuxer.Remux(decoder); err != nil {
+		remuxer.Close()
+		t.Fatalf("Remux failed: %v", err)
+	}
+	if err := remuxer.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
 	}
 
-	t.Log("Null BSF initialized successfully")
+	out, err := NewDecoder(dstPath)
+	if err != nil {
+		t.Fatalf("Failed to open output: %v", err)
+	}
+	defer out.Close()
+	frames, err := countVideoFrames(out)
+	if err != nil {
+		t.Fatalf("countVideoFrames failed: %v", err)
+	}
+	// One second at 25 fps, give or take reordering at the cut.
+	if frames < 20 || frames > 27 {
+		t.Errorf("clip has %d frames, want about 25", frames)
+	}
 }
 
-func TestBitstreamFilterH264(t *testing.T) {
+func TestMetadataRead(t *testing.T) {
 	if !requireFFmpeg(t) {
 		return
 	}
-	if !BitstreamFilterExists(BSFNameH264Mp4ToAnnexB) {
-		t.Log("h264_mp4toannexb filter not available")
-		return
-	}
+	// Create test video with metadata using ffmpeg CLI
+	tmpDir := t.TempDir()
+	testFile := filepath.Join(tmpDir, "metadata_test.mp4")
 
-	// Create test video (H.264)
-	testFile := createTestVideo(t)
-	if testFile == "" {
+	cmd := exec.Command("ffmpeg", "-y",
+		"-f", "lavfi", "-i", "testsrc=duration=0.5:size=160x120:rate=30",
+		"-c:v", "libx264", "-preset", "ultrafast",
+		"-metadata", "title=Test Title",
+		"-metadata", "artist=Test Artist",
+		"-metadata", "album=Test Album",
+		"-metadata", "genre=Test Genre",
+		testFile)
+
+	if err := cmd.Run(); err != nil {
+		t.Logf("ffmpeg not available or failed: %v", err)
 		return
 	}
 
-	// Open decoder to get codec parameters
+	// Open and read metadata
 	decoder, err := NewDecoder(testFile)
 	if err != nil {
 		t.Fatalf("Failed to open file: %v", err)
 	}
 	defer decoder.Close()
 
-	videoStream := decoder.VideoStream()
-	if videoStream == nil {
-		t.Fatal("No video stream")
+	meta := decoder.GetMetadata()
+	if meta == nil {
+		t.Log("No metadata found (some containers may not store metadata)")
+		return
 	}
 
-	// Create BSF
-	bsf, err := NewBitstreamFilter(BSFNameH264Mp4ToAnnexB)
-	if err != nil {
-		t.Fatalf("Failed to create BSF: %v", err)
+	t.Logf("Metadata entries: %d", len(meta))
+	for k, v := range meta {
+		t.Logf("  %s = %s", k, v)
 	}
-	defer bsf.Close()
 
-	// We need to read the codec parameters from the stream
-	// For now, just test that creation works
-	t.Log("h264_mp4toannexb BSF created successfully")
+	// Check if we got some metadata
+	if len(meta) == 0 {
+		t.Log("Metadata map is empty")
+	}
 }
 
-func TestStreamMetadata(t *testing.T) {
+func TestMetadataWrite(t *testing.T) {
 	if !requireFFmpeg(t) {
 		return
 	}
 	tmpDir := t.TempDir()
-	testFile := filepath.Join(tmpDir, "stream_meta.mkv")
+	testFile := filepath.Join(tmpDir, "metadata_write.mkv")
 
-	// Create encoder
+	// Create encoder with metadata
 	enc, err := NewEncoderWithOptions(testFile, &EncoderOptions{
 		Video: &VideoEncoderConfig{
 			Width:       160,
@@ -2201,13 +3678,15 @@ func TestStreamMetadata(t *testing.T) {
 		t.Fatalf("Failed to create encoder: %v", err)
 	}
 
-	// Set stream metadata
-	if err := enc.SetStreamMetadata(0, Metadata{
-		MetadataLanguage: "eng",
-		"handler_name":   "Video Track",
-	}); err != nil {
+	// Set metadata before writing header
+	metadata := Metadata{
+		MetadataTitle:  "My Test Video",
+		MetadataArtist: "Test Author",
+		"custom_key":   "custom_value",
+	}
+	if err := enc.SetMetadata(metadata); err != nil {
 		enc.Close()
-		t.Fatalf("SetStreamMetadata failed: %v", err)
+		t.Fatalf("SetMetadata failed: %v", err)
 	}
 
 	// Write some frames
@@ -2221,266 +3700,2185 @@ func TestStreamMetadata(t *testing.T) {
 	AVUtil.SetFrameFormat(frame, int32(PixelFormatYUV420P))
 	_ = AVUtil.FrameGetBuffer(frame, 0)
 
-	for i := 0; i < 5; i++ {
+	for i := 0; i < 10; i++ {
 		_ = enc.WriteFrame(frame)
 	}
 	_ = FrameFree(&frame)
 	enc.Close()
 
-	// Read back
+	// Verify metadata using ffprobe
+	cmd := exec.Command("ffprobe", "-v", "quiet", "-print_format", "json", "-show_format", testFile)
+	output, err := cmd.Output()
+	if err != nil {
+		t.Logf("ffprobe not available, skipping verification: %v", err)
+	} else {
+		t.Logf("ffprobe output: %s", string(output))
+	}
+
+	// Also verify by opening with decoder
 	decoder, err := NewDecoder(testFile)
 	if err != nil {
 		t.Fatalf("Failed to open written file: %v", err)
 	}
 	defer decoder.Close()
 
-	streamMeta := decoder.GetStreamMetadata(0)
-	if streamMeta != nil {
-		t.Logf("Stream 0 metadata entries: %d", len(streamMeta))
-		for k, v := range streamMeta {
+	readMeta := decoder.GetMetadata()
+	if readMeta != nil {
+		t.Logf("Read back %d metadata entries", len(readMeta))
+		for k, v := range readMeta {
 			t.Logf("  %s = %s", k, v)
 		}
-	} else {
-		t.Log("No stream metadata found")
 	}
 }
 
-func TestSaveFrame(t *testing.T) {
+func TestMatroskaTagsRoundTrip(t *testing.T) {
 	if !requireFFmpeg(t) {
 		return
 	}
-	testFile := createTestVideo(t)
-	if testFile == "" {
-		return
+	testFile := filepath.Join(t.TempDir(), "tags.mkv")
+
+	enc, err := NewEncoderWithOptions(testFile, &EncoderOptions{
+		Video: &VideoEncoderConfig{
+			Width:       160,
+			Height:      120,
+			FrameRate:   Rational{Num: 30, Den: 1},
+			PixelFormat: PixelFormatYUV420P,
+		},
+	})
+	if err != nil {
+		t.Fatalf("Failed to create encoder: %v", err)
+	}
+	if err := enc.AddMatroskaTag(TagTarget{}, "TITLE", "Season One"); err != nil {
+		enc.Close()
+		t.Fatalf("AddMatroskaTag(global) failed: %v", err)
+	}
+	if err := enc.AddMatroskaTag(TagTarget{Kind: TagTargetTrack, Index: 0}, "NOTES", "track notes"); err != nil {
+		enc.Close()
+		t.Fatalf("AddMatroskaTag(track) failed: %v", err)
+	}
+	if err := enc.AddMatroskaTag(TagTarget{Kind: TagTargetTrack, Index: 5}, "NOTES", "x"); err == nil {
+		t.Error("expected error for invalid track index")
 	}
 
-	tmpDir := t.TempDir()
+	frame := FrameAlloc()
+	if frame.IsNil() {
+		enc.Close()
+		t.Fatal("Failed to allocate frame")
+	}
+	AVUtil.SetFrameWidth(frame, 160)
+	AVUtil.SetFrameHeight(frame, 120)
+	AVUtil.SetFrameFormat(frame, int32(PixelFormatYUV420P))
+	_ = AVUtil.FrameGetBuffer(frame, 0)
+	for i := 0; i < 5; i++ {
+		_ = enc.WriteFrame(frame)
+	}
+	_ = FrameFree(&frame)
+	if err := enc.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
 
-	// Test PNG with its own decoder and frame
-	// Note: Don't call FrameFree on frames from DecodeVideo - decoder owns them
-	t.Run("PNG", func(t *testing.T) {
-		decoder, err := NewDecoder(testFile)
-		if err != nil {
-			t.Fatalf("Failed to open file: %v", err)
-		}
-		defer decoder.Close()
+	decoder, err := NewDecoder(testFile)
+	if err != nil {
+		t.Fatalf("Failed to open written file: %v", err)
+	}
+	defer decoder.Close()
 
-		frame, err := decoder.DecodeVideo()
-		if err != nil {
-			t.Fatalf("Failed to decode frame: %v", err)
+	tags, err := decoder.MatroskaTags()
+	if err != nil {
+		t.Fatalf("MatroskaTags failed: %v", err)
+	}
+	var foundGlobal, foundTrack bool
+	for _, tag := range tags {
+		if tag.Target.Kind == TagTargetGlobal && strings.EqualFold(tag.Name, "TITLE") && tag.Value == "Season One" {
+			foundGlobal = true
 		}
-
-		pngFile := filepath.Join(tmpDir, "test.png")
-		if err := SaveFrame(frame, pngFile); err != nil {
-			t.Fatalf("SaveFrame PNG failed: %v", err)
+		if tag.Target.Kind == TagTargetTrack && tag.Target.Index == 0 &&
+			strings.EqualFold(tag.Name, "NOTES") && tag.Value == "track notes" {
+			foundTrack = true
 		}
+	}
+	if !foundGlobal {
+		t.Errorf("global tag not found in %+v", tags)
+	}
+	if !foundTrack {
+		t.Errorf("track tag not found in %+v", tags)
+	}
+}
 
-		if info, err := os.Stat(pngFile); err != nil {
-			t.Errorf("PNG file not created: %v", err)
-		} else {
-			t.Logf("Created PNG: %s (%d bytes)", pngFile, info.Size())
-		}
-	})
+func TestMatroskaCuesRoundTrip(t *testing.T) {
+	if !requireFFmpeg(t) {
+		return
+	}
+	testFile := filepath.Join(t.TempDir(), "cues.mkv")
 
-	// Test JPEG with its own decoder and frame
-	t.Run("JPEG", func(t *testing.T) {
-		decoder, err := NewDecoder(testFile)
-		if err != nil {
-			t.Fatalf("Failed to open file: %v", err)
+	enc, err := NewEncoderWithOptions(testFile, &EncoderOptions{
+		Video: &VideoEncoderConfig{
+			Width:       160,
+			Height:      120,
+			FrameRate:   Rational{Num: 10, Den: 1},
+			PixelFormat: PixelFormatYUV420P,
+			GOPSize:     10,
+		},
+		MatroskaCues: &MatroskaCueOptions{CuesToFront: true},
+	})
+	if err != nil {
+		t.Fatalf("Failed to create encoder: %v", err)
+	}
+	frame := FrameAlloc()
+	if frame.IsNil() {
+		enc.Close()
+		t.Fatal("Failed to allocate frame")
+	}
+	AVUtil.SetFrameWidth(frame, 160)
+	AVUtil.SetFrameHeight(frame, 120)
+	AVUtil.SetFrameFormat(frame, int32(PixelFormatYUV420P))
+	_ = AVUtil.FrameGetBuffer(frame, 0)
+	for i := 0; i < 30; i++ {
+		if err := enc.WriteFrame(frame); err != nil {
+			t.Fatalf("WriteFrame failed: %v", err)
 		}
-		defer decoder.Close()
+	}
+	_ = FrameFree(&frame)
+	if err := enc.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
 
-		frame, err := decoder.DecodeVideo()
-		if err != nil {
-			t.Fatalf("Failed to decode frame: %v", err)
-		}
+	decoder, err := NewDecoder(testFile)
+	if err != nil {
+		t.Fatalf("Failed to open written file: %v", err)
+	}
+	defer decoder.Close()
 
-		jpgFile := filepath.Join(tmpDir, "test.jpg")
-		if err := SaveFrame(frame, jpgFile); err != nil {
-			t.Fatalf("SaveFrame JPEG failed: %v", err)
+	cues, err := decoder.MatroskaCues()
+	if err != nil {
+		t.Fatalf("MatroskaCues failed: %v", err)
+	}
+	if len(cues) == 0 {
+		t.Fatal("no cue points read")
+	}
+	for i, c := range cues {
+		if c.StreamIndex != 0 || c.Position <= 0 {
+			t.Errorf("cue %d = %+v, want stream 0 at a positive position", i, c)
 		}
-
-		if info, err := os.Stat(jpgFile); err != nil {
-			t.Errorf("JPEG file not created: %v", err)
-		} else {
-			t.Logf("Created JPEG: %s (%d bytes)", jpgFile, info.Size())
+		if i > 0 && c.Time < cues[i-1].Time {
+			t.Errorf("cue %d at %v is before cue %d at %v", i, c.Time, i-1, cues[i-1].Time)
 		}
-	})
+	}
+	if cues[0].Time != 0 {
+		t.Errorf("first cue at %v, want 0", cues[0].Time)
+	}
 
-	// Test BMP with its own decoder and frame
-	t.Run("BMP", func(t *testing.T) {
-		decoder, err := NewDecoder(testFile)
-		if err != nil {
-			t.Fatalf("Failed to open file: %v", err)
-		}
-		defer decoder.Close()
+	if pkt, err := decoder.ReadPacket(); err != nil || pkt == nil {
+		t.Errorf("ReadPacket after MatroskaCues: pkt=%v err=%v", pkt, err)
+	}
+}
 
-		frame, err := decoder.DecodeVideo()
-		if err != nil {
-			t.Fatalf("Failed to decode frame: %v", err)
-		}
+func TestAvailableHWDeviceTypes(t *testing.T) {
+	if !requireFFmpeg(t) {
+		return
+	}
+	types := AvailableHWDeviceTypes()
+	t.Logf("Available hardware device types: %d", len(types))
+	for _, hwType := range types {
+		name := GetHWDeviceTypeName(hwType)
+		t.Logf("  - %s (%d)", name, hwType)
+	}
+}
 
-		bmpFile := filepath.Join(tmpDir, "test.bmp")
-		if err := SaveFrame(frame, bmpFile); err != nil {
-			t.Fatalf("SaveFrame BMP failed: %v", err)
+func TestAvailableHWConfigs(t *testing.T) {
+	if !requireFFmpeg(t) {
+		return
+	}
+	all := AvailableHWConfigs(CodecIDH264, HWDeviceTypeNone)
+	t.Logf("h264 decoder hardware configs: %d", len(all))
+	for _, cfg := range all {
+		if cfg.DeviceType == HWDeviceTypeNone {
+			t.Errorf("config %+v has no device type", cfg)
 		}
+		t.Logf("  - %s: %s (methods %#x)", GetHWDeviceTypeName(cfg.DeviceType), avutil.GetPixFmtName(cfg.PixelFormat), int(cfg.Methods))
+	}
 
-		if info, err := os.Stat(bmpFile); err != nil {
-			t.Errorf("BMP file not created: %v", err)
-		} else {
-			t.Logf("Created BMP: %s (%d bytes)", bmpFile, info.Size())
+	for _, cfg := range AvailableHWConfigs(CodecIDH264, HWDeviceTypeCUDA) {
+		if cfg.DeviceType != HWDeviceTypeCUDA {
+			t.Errorf("filtered config has device type %d, want CUDA", cfg.DeviceType)
 		}
-	})
+	}
+	if got := GetHWPixelFormats(CodecIDH264, HWDeviceTypeCUDA); len(got) != len(AvailableHWConfigs(CodecIDH264, HWDeviceTypeCUDA)) {
+		t.Errorf("GetHWPixelFormats returned %d formats", len(got))
+	}
+	if got := AvailableHWConfigs(CodecIDNone, HWDeviceTypeNone); len(got) != 0 {
+		t.Errorf("AvailableHWConfigs(CodecIDNone) = %v, want none", got)
+	}
 }
 
-func TestExtractFrameFunction(t *testing.T) {
+func TestHWDevice(t *testing.T) {
 	if !requireFFmpeg(t) {
 		return
 	}
-	testFile := createTestVideo(t)
-	if testFile == "" {
+	// Find available hardware device
+	types := AvailableHWDeviceTypes()
+	if len(types) == 0 {
+		t.Log("No hardware acceleration available")
 		return
 	}
 
-	tmpDir := t.TempDir()
-	outputFile := filepath.Join(tmpDir, "extracted.png")
+	hwType := types[0]
+	t.Logf("Testing with %s", GetHWDeviceTypeName(hwType))
 
-	// Extract frame at 500ms
-	if err := ExtractFrame(testFile, 500*time.Millisecond, outputFile); err != nil {
-		t.Fatalf("ExtractFrame failed: %v", err)
+	device, err := NewHWDevice(hwType, "")
+	if err != nil {
+		t.Logf("Failed to create HW device: %v", err)
+		return
+	}
+	defer device.Close()
+
+	if device.Type() != hwType {
+		t.Errorf("Expected type %d, got %d", hwType, device.Type())
 	}
 
-	// Verify file exists
-	if info, err := os.Stat(outputFile); err != nil {
-		t.Errorf("Output file not created: %v", err)
-	} else {
-		t.Logf("Extracted frame: %s (%d bytes)", outputFile, info.Size())
+	if device.TypeName() == "" {
+		t.Error("TypeName returned empty string")
+	}
+
+	if device.Context() == nil {
+		t.Error("Context returned nil")
+	}
+
+	t.Logf("HW device created: %s", device.TypeName())
+}
+
+func TestHWDecoder(t *testing.T) {
+	if !requireFFmpeg(t) {
+		return
+	}
+	// Find available hardware device
+	types := AvailableHWDeviceTypes()
+	if len(types) == 0 {
+		t.Log("No hardware acceleration available")
+		return
+	}
+
+	hwType := types[0]
+	t.Logf("Testing HW decoding with %s", GetHWDeviceTypeName(hwType))
+
+	// Create HW device
+	device, err := NewHWDevice(hwType, "")
+	if err != nil {
+		t.Logf("Failed to create HW device: %v", err)
+		return
+	}
+	defer device.Close()
+
+	// Create a test video file
+	testFile := createTestVideo(t)
+	if testFile == "" {
+		return
+	}
+
+	// Create HW decoder
+	hwDec, err := NewHWDecoder(testFile, &HWDecoderConfig{
+		HWDevice:             device,
+		OutputSoftwareFrames: true,
+	})
+	if err != nil {
+		t.Logf("HW decoder not supported for this codec/device: %v", err)
+		return
+	}
+	defer hwDec.Close()
+
+	t.Logf("Video: %dx%d", hwDec.VideoStream().Width, hwDec.VideoStream().Height)
+
+	// Decode a few frames
+	frameCount := 0
+	for frameCount < 5 {
+		frame, err := hwDec.DecodeVideo()
+		if err != nil {
+			if IsEOF(err) {
+				break
+			}
+			t.Logf("Decode error (may be expected for some HW decoders): %v", err)
+			break
+		}
+		if !frame.IsNil() {
+			frameCount++
+		}
+	}
+
+	t.Logf("Decoded %d frames using hardware acceleration", frameCount)
+}
+
+func TestSeekPrecise(t *testing.T) {
+	if !requireFFmpeg(t) {
+		return
+	}
+	testFile := createTestVideo(t)
+	if testFile == "" {
+		return
+	}
+
+	decoder, err := NewDecoder(testFile)
+	if err != nil {
+		t.Fatalf("Failed to open file: %v", err)
+	}
+	defer decoder.Close()
+
+	// Open video decoder
+	if err := decoder.OpenVideoDecoder(); err != nil {
+		t.Fatalf("Failed to open video decoder: %v", err)
+	}
+
+	// Test seeking to middle of file
+	duration := decoder.Duration()
+	t.Logf("Duration: %v", duration)
+
+	midPoint := duration / 2
+	if err := decoder.SeekPrecise(midPoint); err != nil {
+		t.Fatalf("SeekPrecise failed: %v", err)
+	}
+
+	// Decode a frame to verify seek worked
+	frame, err := decoder.DecodeVideo()
+	if err != nil {
+		t.Fatalf("Failed to decode after seek: %v", err)
+	}
+	if frame.IsNil() {
+		t.Fatal("Got nil frame after seek")
+	}
+	got := frameTimestamp(frame.ptr)
+
+	// The first frame after the seek must be the first frame at or after
+	// the target, found here by decoding from the start.
+	ref, err := NewDecoder(testFile)
+	if err != nil {
+		t.Fatalf("Failed to open file: %v", err)
+	}
+	defer ref.Close()
+	tb := ref.VideoStream().TimeBase
+	target := midPoint.Microseconds() * int64(tb.Den) / (int64(tb.Num) * 1000000)
+	want := avutil.NoPTSValue
+	for {
+		f, err := ref.DecodeVideo()
+		if err != nil {
+			t.Fatalf("DecodeVideo failed: %v", err)
+		}
+		if f.IsNil() {
+			break
+		}
+		if pts := frameTimestamp(f.ptr); pts != avutil.NoPTSValue && pts >= target {
+			want = pts
+			break
+		}
+	}
+	if got != want {
+		t.Errorf("first frame after SeekPrecise(%v) has PTS %d, want %d", midPoint, got, want)
+	}
+
+	// Seeking again after decoding must not return a stale frame.
+	if err := decoder.SeekPrecise(midPoint); err != nil {
+		t.Fatalf("second SeekPrecise failed: %v", err)
+	}
+	frame, err = decoder.DecodeVideo()
+	if err != nil || frame.IsNil() {
+		t.Fatalf("DecodeVideo after second seek: frame nil=%v err=%v", frame.IsNil(), err)
+	}
+	if pts := frameTimestamp(frame.ptr); pts != want {
+		t.Errorf("first frame after second SeekPrecise has PTS %d, want %d", pts, want)
+	}
+}
+
+func TestSeekWithOptions(t *testing.T) {
+	if !requireFFmpeg(t) {
+		return
+	}
+	testFile := createTestVideo(t)
+
+	decoder, err := NewDecoder(testFile)
+	if err != nil {
+		t.Fatalf("Failed to open file: %v", err)
+	}
+	defer decoder.Close()
+
+	if err := decoder.OpenVideoDecoder(); err != nil {
+		t.Fatalf("Failed to open video decoder: %v", err)
+	}
+
+	midPoint := decoder.Duration() / 2
+	if err := decoder.SeekWithOptions(midPoint, SeekOptions{Backward: true}); err != nil {
+		t.Fatalf("SeekWithOptions(Backward) failed: %v", err)
+	}
+	frame, err := decoder.DecodeVideo()
+	if err != nil {
+		t.Fatalf("Failed to decode after seek: %v", err)
+	}
+	if frame.IsNil() {
+		t.Error("Got nil frame after backward seek")
+	}
+
+	if err := decoder.SeekWithOptions(0, SeekOptions{Backward: true, AnyFrame: true}); err != nil {
+		t.Fatalf("SeekWithOptions(AnyFrame) failed: %v", err)
+	}
+}
+
+func TestSeekWithOptionsByByte(t *testing.T) {
+	if !requireFFmpeg(t) {
+		return
+	}
+	// MPEG-TS supports byte seeking, unlike MP4.
+	path := filepath.Join(t.TempDir(), "bytes.ts")
+	if err := exec.Command("ffmpeg", "-y",
+		"-f", "lavfi", "-i", "testsrc=duration=2:size=160x120:rate=10",
+		"-c:v", "mpeg2video", "-g", "5", path).Run(); err != nil {
+		t.Skipf("ffmpeg CLI not available: %v", err)
+	}
+
+	decoder, err := NewDecoder(path)
+	if err != nil {
+		t.Fatalf("Failed to open file: %v", err)
+	}
+	defer decoder.Close()
+	first, err := decoder.DecodeVideo()
+	if err != nil || first.IsNil() {
+		t.Fatalf("DecodeVideo failed: %v", err)
+	}
+	firstPTS := frameTimestamp(first.ptr)
+	for i := 0; i < 10; i++ {
+		if _, err := decoder.DecodeVideo(); err != nil {
+			t.Fatalf("DecodeVideo failed: %v", err)
+		}
+	}
+
+	// The time target is ignored; Position 0 goes back to the start.
+	if err := decoder.SeekWithOptions(time.Hour, SeekOptions{ByByte: true, Position: 0}); err != nil {
+		t.Fatalf("SeekWithOptions(ByByte) failed: %v", err)
+	}
+	frame, err := decoder.DecodeVideo()
+	if err != nil || frame.IsNil() {
+		t.Fatalf("DecodeVideo after byte seek failed: %v", err)
+	}
+	if pts := frameTimestamp(frame.ptr); pts != firstPTS {
+		t.Errorf("PTS after seeking to byte 0 = %d, want %d", pts, firstPTS)
+	}
+
+	if err := decoder.SeekWithOptions(0, SeekOptions{ByByte: true, Position: -1}); err == nil {
+		t.Error("expected error for a negative byte position")
+	}
+}
+
+func TestSeekToFrame(t *testing.T) {
+	if !requireFFmpeg(t) {
+		return
+	}
+	testFile := createTestVideo(t)
+	if testFile == "" {
+		return
+	}
+
+	decoder, err := NewDecoder(testFile)
+	if err != nil {
+		t.Fatalf("Failed to open file: %v", err)
+	}
+	defer decoder.Close()
+
+	// Get total frames estimate
+	totalFrames := decoder.TotalFrames()
+	t.Logf("Estimated total frames: %d", totalFrames)
+
+	if totalFrames <= 0 {
+		t.Log("Cannot determine total frames")
+		return
+	}
+
+	// Open video decoder
+	if err := decoder.OpenVideoDecoder(); err != nil {
+		t.Fatalf("Failed to open video decoder: %v", err)
+	}
+
+	// Seek to frame 10
+	targetFrame := int64(10)
+	if targetFrame >= totalFrames {
+		targetFrame = totalFrames / 2
+	}
+
+	if err := decoder.SeekToFrame(targetFrame); err != nil {
+		t.Fatalf("SeekToFrame failed: %v", err)
+	}
+
+	// Decode a frame
+	frame, err := decoder.DecodeVideo()
+	if err != nil {
+		t.Fatalf("Failed to decode after frame seek: %v", err)
+	}
+	if frame.IsNil() {
+		t.Error("Got nil frame after seek")
+	}
+
+	t.Logf("Successfully seeked to frame %d", targetFrame)
+}
+
+func TestExtractThumbnail(t *testing.T) {
+	if !requireFFmpeg(t) {
+		return
+	}
+	testFile := createTestVideo(t)
+	if testFile == "" {
+		return
+	}
+
+	decoder, err := NewDecoder(testFile)
+	if err != nil {
+		t.Fatalf("Failed to open file: %v", err)
+	}
+	defer decoder.Close()
+
+	duration := decoder.Duration()
+	midPoint := duration / 2
+
+	thumbnail, err := decoder.ExtractThumbnail(midPoint)
+	if err != nil {
+		t.Fatalf("ExtractThumbnail failed: %v", err)
+	}
+
+	if thumbnail.IsNil() {
+		t.Error("Got nil thumbnail")
+	}
+	defer func() { _ = FrameFree(&thumbnail) }()
+
+	t.Logf("Extracted thumbnail at %v", midPoint)
+}
+
+func TestFrameAt(t *testing.T) {
+	if !requireFFmpeg(t) {
+		return
+	}
+	// MPEG-TS output is shifted so the video stream starts well after zero.
+	path := filepath.Join(t.TempDir(), "offset.ts")
+	if err := exec.Command("ffmpeg", "-y",
+		"-f", "lavfi", "-i", "testsrc=duration=2:size=160x120:rate=10",
+		"-c:v", "mpeg2video", "-g", "5", "-output_ts_offset", "5", path).Run(); err != nil {
+		t.Skipf("ffmpeg CLI not available: %v", err)
+	}
+
+	decoder, err := NewDecoder(path)
+	if err != nil {
+		t.Fatalf("Failed to open file: %v", err)
+	}
+	defer decoder.Close()
+
+	info := decoder.VideoStream()
+	stream := avformat.GetStream(decoder.formatCtx, decoder.videoStreamIdx)
+	start := ptsToDuration(avformat.GetStreamStartTime(stream), info.TimeBase)
+	if start < 5*time.Second {
+		t.Fatalf("stream start time = %v, want at least 5s", start)
+	}
+
+	frameDur := 100 * time.Millisecond
+	for _, target := range []time.Duration{0, 700 * time.Millisecond, 1250 * time.Millisecond} {
+		frame, err := decoder.FrameAt(target)
+		if err != nil {
+			t.Fatalf("FrameAt(%v) failed: %v", target, err)
+		}
+		if frame.IsNil() {
+			t.Fatalf("FrameAt(%v) returned nil frame", target)
+		}
+		got := ptsToDuration(frameTimestamp(frame.ptr), info.TimeBase) - start
+		FrameFree(&frame)
+		if got < target || got >= target+frameDur {
+			t.Errorf("FrameAt(%v) returned frame at %v, want within [%v, %v)", target, got, target, target+frameDur)
+		}
+	}
+
+	if _, err := decoder.FrameAt(decoder.Duration() + time.Second); err == nil {
+		t.Error("FrameAt past duration should fail")
+	}
+}
+
+func TestExtractThumbnails(t *testing.T) {
+	if !requireFFmpeg(t) {
+		return
+	}
+	testFile := createTestVideo(t)
+	if testFile == "" {
+		return
+	}
+
+	decoder, err := NewDecoder(testFile)
+	if err != nil {
+		t.Fatalf("Failed to open file: %v", err)
+	}
+	defer decoder.Close()
+
+	// Extract 3 thumbnails
+	thumbnails, err := decoder.ExtractThumbnails(3)
+	if err != nil {
+		t.Fatalf("ExtractThumbnails failed: %v", err)
+	}
+
+	if len(thumbnails) != 3 {
+		t.Errorf("Expected 3 thumbnails, got %d", len(thumbnails))
+	}
+
+	// Free the thumbnails
+	for i, th := range thumbnails {
+		if th.IsNil() {
+			t.Errorf("Thumbnail %d is nil", i)
+		} else {
+			_ = FrameFree(&th)
+		}
+	}
+
+	t.Logf("Extracted %d thumbnails", len(thumbnails))
+}
+
+func TestExtractSmartThumbnails(t *testing.T) {
+	if !requireFFmpeg(t) {
+		return
+	}
+	testFile := createTestVideo(t)
+
+	thumbnails, err := GenerateSmartThumbnails(testFile, 3)
+	if err != nil {
+		t.Fatalf("GenerateSmartThumbnails failed: %v", err)
+	}
+	defer freeFrames(thumbnails)
+
+	if len(thumbnails) != 3 {
+		t.Fatalf("Expected 3 thumbnails, got %d", len(thumbnails))
+	}
+	var last int64 = -1
+	for i, th := range thumbnails {
+		if th.IsNil() {
+			t.Fatalf("Thumbnail %d is nil", i)
+		}
+		if avutil.GetFrameWidth(th.ptr) <= 0 || avutil.GetFrameHeight(th.ptr) <= 0 {
+			t.Errorf("Thumbnail %d has no dimensions", i)
+		}
+		pts := avutil.GetFramePTS(th.ptr)
+		if pts != avutil.AV_NOPTS_VALUE {
+			if pts <= last {
+				t.Errorf("Thumbnail %d PTS %d not after previous %d", i, pts, last)
+			}
+			last = pts
+		}
+	}
+
+	if _, err := GenerateSmartThumbnails(testFile, 0); err == nil {
+		t.Error("expected error for zero count")
+	}
+}
+
+func TestTotalFrames(t *testing.T) {
+	if !requireFFmpeg(t) {
+		return
+	}
+	testFile := createTestVideo(t)
+	if testFile == "" {
+		return
+	}
+
+	decoder, err := NewDecoder(testFile)
+	if err != nil {
+		t.Fatalf("Failed to open file: %v", err)
+	}
+	defer decoder.Close()
+
+	totalFrames := decoder.TotalFrames()
+	t.Logf("Total frames: %d", totalFrames)
+
+	// The test video is 1 second at 30fps, so should be ~30 frames
+	if totalFrames < 20 || totalFrames > 40 {
+		t.Logf("Warning: unexpected frame count %d for 1s@30fps video", totalFrames)
+	}
+}
+
+func TestSubtitleDetection(t *testing.T) {
+	if !requireFFmpeg(t) {
+		return
+	}
+	// Test video without subtitles
+	testFile := createTestVideo(t)
+	if testFile == "" {
+		return
+	}
+
+	decoder, err := NewDecoder(testFile)
+	if err != nil {
+		t.Fatalf("Failed to open file: %v", err)
+	}
+	defer decoder.Close()
+
+	hasSubtitle := decoder.HasSubtitle()
+	t.Logf("Has subtitle: %v", hasSubtitle)
+
+	// Our test video should not have subtitles
+	if hasSubtitle {
+		t.Log("Unexpectedly found subtitle stream")
+	}
+
+	subInfo := decoder.SubtitleStream()
+	if subInfo != nil {
+		t.Logf("Subtitle stream: %+v", subInfo)
+	} else {
+		t.Log("No subtitle stream (expected)")
+	}
+}
+
+func createTestVideoWithSubtitles(t *testing.T) string {
+	t.Helper()
+
+	tmpDir := t.TempDir()
+
+	// Create an SRT subtitle file
+	srtFile := filepath.Join(tmpDir, "test.srt")
+	srtContent := `1
+00:00:00,000 --> 00:00:01,000
+Hello World
+
+2
+00:00:01,000 --> 00:00:02,000
+Test Subtitle
+`
+	if err := os.WriteFile(srtFile, []byte(srtContent), 0644); err != nil {
+		t.Logf("Failed to write SRT file: %v", err)
+		return ""
+	}
+
+	testFile := filepath.Join(tmpDir, "test_with_subs.mkv")
+
+	cmd := exec.Command("ffmpeg", "-y",
+		"-f", "lavfi", "-i", "testsrc=duration=2:size=160x120:rate=30",
+		"-i", srtFile,
+		"-c:v", "libx264", "-preset", "ultrafast",
+		"-c:s", "srt",
+		testFile)
+
+	if err := cmd.Run(); err != nil {
+		t.Logf("ffmpeg with subtitles failed: %v", err)
+		return ""
+	}
+
+	if _, err := os.Stat(testFile); err != nil {
+		t.Logf("Test file not created: %v", err)
+		return ""
+	}
+
+	return testFile
+}
+
+func TestSubtitleDecoder(t *testing.T) {
+	if !requireFFmpeg(t) {
+		return
+	}
+	testFile := createTestVideoWithSubtitles(t)
+	if testFile == "" {
+		t.Log("Could not create test file with subtitles")
+		return
+	}
+
+	dec, err := NewDecoder(testFile)
+	if err != nil {
+		t.Fatalf("Failed to open file: %v", err)
+	}
+	defer dec.Close()
+
+	subStream := dec.SubtitleStream()
+	if subStream == nil {
+		t.Log("No subtitle stream found")
+		return
+	}
+
+	subDec, err := NewSubtitleDecoder(subStream)
+	if err != nil {
+		t.Fatalf("Failed to create subtitle decoder: %v", err)
+	}
+	defer subDec.Close()
+
+	t.Logf("Subtitle stream: %+v", subDec.StreamInfo())
+
+	// Decode subtitles by reading packets from the main decoder (docs-aligned flow)
+	subCount := 0
+	for subCount < 5 {
+		pkt, err := dec.ReadPacket()
+		if err != nil {
+			t.Fatalf("ReadPacket failed: %v", err)
+		}
+		if pkt == nil {
+			break
+		}
+		if pkt.StreamIndex() != dec.SubtitleStreamIndex() {
+			continue
+		}
+
+		sub, err := subDec.Decode(pkt)
+		if err != nil {
+			t.Fatalf("Decode failed: %v", err)
+		}
+		if sub != nil {
+			subCount++
+			t.Logf("Subtitle %d: %s - %s, text: %q", subCount, sub.StartTime, sub.EndTime, sub.Text)
+		}
+	}
+
+	t.Logf("Decoded %d subtitles", subCount)
+}
+
+func TestBitstreamFilterExists(t *testing.T) {
+	if !requireFFmpeg(t) {
+		return
+	}
+	filters := ListBitstreamFilters()
+	t.Logf("Checking %d known bitstream filters", len(filters))
+
+	for _, name := range filters {
+		exists := BitstreamFilterExists(name)
+		t.Logf("  %s: %v", name, exists)
+	}
+
+	// At least null filter should exist
+	if !BitstreamFilterExists(BSFNameNull) {
+		t.Error("Expected null BSF to exist")
+	}
+}
+
+func TestListCodecs(t *testing.T) {
+	if !requireFFmpeg(t) {
+		return
+	}
+	decoders := ListDecoders()
+	if len(decoders) == 0 {
+		t.Fatal("ListDecoders returned no codecs")
+	}
+	found := false
+	for _, c := range decoders {
+		if c.Name == "h264" {
+			found = true
+			if c.ID != CodecIDH264 || c.MediaType != MediaTypeVideo {
+				t.Errorf("h264 decoder info = %+v", c)
+			}
+		}
+	}
+	if !found {
+		t.Error("h264 decoder not listed")
+	}
+
+	encoders := ListEncoders()
+	t.Logf("%d encoders, %d decoders", len(encoders), len(decoders))
+	for _, c := range encoders {
+		if c.Name == "" || c.ID == CodecIDNone {
+			t.Errorf("incomplete encoder info: %+v", c)
+		}
+	}
+}
+
+func TestBitstreamFilterNull(t *testing.T) {
+	if !requireFFmpeg(t) {
+		return
+	}
+	// Create null filter (passthrough)
+	bsf, err := NewBitstreamFilter(BSFNameNull)
+	if err != nil {
+		t.Fatalf("Failed to create null BSF: %v", err)
+	}
+	defer bsf.Close()
+
+	// Initialize (null filter doesn't need codec params)
+	if err := bsf.Init(); err != nil {
+		t.Fatalf("Failed to init BSF: %v", err)
+	}
+
+	t.Log("Null BSF initialized successfully")
+}
+
+func TestBitstreamFilterH264(t *testing.T) {
+	if !requireFFmpeg(t) {
+		return
+	}
+	if !BitstreamFilterExists(BSFNameH264Mp4ToAnnexB) {
+		t.Log("h264_mp4toannexb filter not available")
+		return
+	}
+
+	// Create test video (H.264)
+	testFile := createTestVideo(t)
+	if testFile == "" {
+		return
+	}
+
+	// Open decoder to get codec parameters
+	decoder, err := NewDecoder(testFile)
+	if err != nil {
+		t.Fatalf("Failed to open file: %v", err)
+	}
+	defer decoder.Close()
+
+	videoStream := decoder.VideoStream()
+	if videoStream == nil {
+		t.Fatal("No video stream")
+	}
+
+	// Create BSF
+	bsf, err := NewBitstreamFilter(BSFNameH264Mp4ToAnnexB)
+	if err != nil {
+		t.Fatalf("Failed to create BSF: %v", err)
+	}
+	defer bsf.Close()
+
+	if err := bsf.InitFromParameters(videoStream.CodecParameters(), videoStream.TimeBase); err != nil {
+		t.Fatalf("InitFromParameters failed: %v", err)
+	}
+
+	var filtered []*Packet
+	defer func() {
+		for _, p := range filtered {
+			_ = p.Free()
+		}
+	}()
+	for len(filtered) == 0 {
+		pkt, err := decoder.ReadPacket()
+		if err != nil {
+			t.Fatalf("ReadPacket failed: %v", err)
+		}
+		if pkt == nil {
+			out, err := bsf.Apply(nil)
+			if err != nil {
+				t.Fatalf("Apply(nil) failed: %v", err)
+			}
+			filtered = append(filtered, out...)
+			break
+		}
+		if pkt.StreamIndex() != videoStream.Index {
+			continue
+		}
+		out, err := bsf.Apply(pkt)
+		if err != nil {
+			t.Fatalf("Apply failed: %v", err)
+		}
+		filtered = append(filtered, out...)
+	}
+	if len(filtered) == 0 {
+		t.Fatal("filter produced no packets")
+	}
+	data := filtered[0].Data()
+	if !bytes.HasPrefix(data, []byte{0, 0, 0, 1}) && !bytes.HasPrefix(data, []byte{0, 0, 1}) {
+		t.Errorf("filtered packet does not start with an Annex B start code: % x", data[:min(len(data), 8)])
+	}
+}
+
+func TestBitstreamFilterApplyNull(t *testing.T) {
+	if !requireFFmpeg(t) {
+		return
+	}
+	bsf, err := NewBitstreamFilter(BSFNameNull)
+	if err != nil {
+		t.Fatalf("Failed to create null BSF: %v", err)
+	}
+	defer bsf.Close()
+	if err := bsf.Init(); err != nil {
+		t.Fatalf("Failed to init BSF: %v", err)
+	}
+
+	// Nothing has been sent yet.
+	if _, err := bsf.ReceivePacket(); !IsAgain(err) {
+		t.Errorf("ReceivePacket before input = %v, want EAGAIN", err)
+	}
+
+	decoder, err := NewDecoder(createTestVideo(t))
+	if err != nil {
+		t.Fatalf("Failed to open file: %v", err)
+	}
+	defer decoder.Close()
+	pkt, err := decoder.ReadPacket()
+	if err != nil || pkt == nil {
+		t.Fatalf("ReadPacket = %v, %v", pkt, err)
+	}
+	want := string(pkt.Data())
+	wantPTS := pkt.PTS()
+	in, err := PacketClone(pkt)
+	if err != nil {
+		t.Fatalf("PacketClone failed: %v", err)
+	}
+	defer func() { _ = in.Free() }()
+
+	out, err := bsf.Apply(in)
+	if err != nil {
+		t.Fatalf("Apply failed: %v", err)
+	}
+	if len(out) != 1 {
+		t.Fatalf("Apply returned %d packets, want 1", len(out))
+	}
+	if got := string(out[0].Data()); got != want || out[0].PTS() != wantPTS {
+		t.Errorf("passthrough packet differs: %d bytes pts %d, want %d bytes pts %d", len(got), out[0].PTS(), len(want), wantPTS)
+	}
+	_ = out[0].Free()
+
+	flushed, err := bsf.Apply(nil)
+	if err != nil || len(flushed) != 0 {
+		t.Errorf("Apply(nil) = %d packets, %v; want none", len(flushed), err)
+	}
+	if _, err := bsf.ReceivePacket(); !IsEOF(err) {
+		t.Errorf("ReceivePacket after flush = %v, want EOF", err)
+	}
+}
+
+func TestStreamMetadata(t *testing.T) {
+	if !requireFFmpeg(t) {
+		return
+	}
+	tmpDir := t.TempDir()
+	testFile := filepath.Join(tmpDir, "stream_meta.mkv")
+
+	// Create encoder
+	enc, err := NewEncoderWithOptions(testFile, &EncoderOptions{
+		Video: &VideoEncoderConfig{
+			Width:       160,
+			Height:      120,
+			FrameRate:   Rational{Num: 30, Den: 1},
+			Bitrate:     500000,
+			PixelFormat: PixelFormatYUV420P,
+			GOPSize:     5,
+		},
+	})
+	if err != nil {
+		t.Fatalf("Failed to create encoder: %v", err)
+	}
+
+	// Set stream metadata
+	if err := enc.SetStreamMetadata(0, Metadata{
+		MetadataLanguage: "eng",
+		"handler_name":   "Video Track",
+	}); err != nil {
+		enc.Close()
+		t.Fatalf("SetStreamMetadata failed: %v", err)
+	}
+
+	// Write some frames
+	frame := FrameAlloc()
+	if frame.IsNil() {
+		enc.Close()
+		t.Fatal("Failed to allocate frame")
+	}
+	AVUtil.SetFrameWidth(frame, 160)
+	AVUtil.SetFrameHeight(frame, 120)
+	AVUtil.SetFrameFormat(frame, int32(PixelFormatYUV420P))
+	_ = AVUtil.FrameGetBuffer(frame, 0)
+
+	for i := 0; i < 5; i++ {
+		_ = enc.WriteFrame(frame)
+	}
+	_ = FrameFree(&frame)
+	enc.Close()
+
+	// Read back
+	decoder, err := NewDecoder(testFile)
+	if err != nil {
+		t.Fatalf("Failed to open written file: %v", err)
+	}
+	defer decoder.Close()
+
+	streamMeta := decoder.GetStreamMetadata(0)
+	if streamMeta != nil {
+		t.Logf("Stream 0 metadata entries: %d", len(streamMeta))
+		for k, v := range streamMeta {
+			t.Logf("  %s = %s", k, v)
+		}
+	} else {
+		t.Log("No stream metadata found")
+	}
+}
+
+func TestSaveFrame(t *testing.T) {
+	if !requireFFmpeg(t) {
+		return
+	}
+	testFile := createTestVideo(t)
+	if testFile == "" {
+		return
+	}
+
+	tmpDir := t.TempDir()
+
+	// Test PNG with its own decoder and frame
+	// Note: Don't call FrameFree on frames from DecodeVideo - decoder owns them
+	t.Run("PNG", func(t *testing.T) {
+		decoder, err := NewDecoder(testFile)
+		if err != nil {
+			t.Fatalf("Failed to open file: %v", err)
+		}
+		defer decoder.Close()
+
+		frame, err := decoder.DecodeVideo()
+		if err != nil {
+			t.Fatalf("Failed to decode frame: %v", err)
+		}
+
+		pngFile := filepath.Join(tmpDir, "test.png")
+		if err := SaveFrame(frame, pngFile); err != nil {
+			t.Fatalf("SaveFrame PNG failed: %v", err)
+		}
+
+		if info, err := os.Stat(pngFile); err != nil {
+			t.Errorf("PNG file not created: %v", err)
+		} else {
+			t.Logf("Created PNG: %s (%d bytes)", pngFile, info.Size())
+		}
+	})
+
+	// Test JPEG with its own decoder and frame
+	t.Run("JPEG", func(t *testing.T) {
+		decoder, err := NewDecoder(testFile)
+		if err != nil {
+			t.Fatalf("Failed to open file: %v", err)
+		}
+		defer decoder.Close()
+
+		frame, err := decoder.DecodeVideo()
+		if err != nil {
+			t.Fatalf("Failed to decode frame: %v", err)
+		}
+
+		jpgFile := filepath.Join(tmpDir, "test.jpg")
+		if err := SaveFrame(frame, jpgFile); err != nil {
+			t.Fatalf("SaveFrame JPEG failed: %v", err)
+		}
+
+		if info, err := os.Stat(jpgFile); err != nil {
+			t.Errorf("JPEG file not created: %v", err)
+		} else {
+			t.Logf("Created JPEG: %s (%d bytes)", jpgFile, info.Size())
+		}
+	})
+
+	// Test BMP with its own decoder and frame
+	t.Run("BMP", func(t *testing.T) {
+		decoder, err := NewDecoder(testFile)
+		if err != nil {
+			t.Fatalf("Failed to open file: %v", err)
+		}
+		defer decoder.Close()
+
+		frame, err := decoder.DecodeVideo()
+		if err != nil {
+			t.Fatalf("Failed to decode frame: %v", err)
+		}
+
+		bmpFile := filepath.Join(tmpDir, "test.bmp")
+		if err := SaveFrame(frame, bmpFile); err != nil {
+			t.Fatalf("SaveFrame BMP failed: %v", err)
+		}
+
+		if info, err := os.Stat(bmpFile); err != nil {
+			t.Errorf("BMP file not created: %v", err)
+		} else {
+			t.Logf("Created BMP: %s (%d bytes)", bmpFile, info.Size())
+		}
+	})
+}
+
+func TestExtractFrameFunction(t *testing.T) {
+	if !requireFFmpeg(t) {
+		return
+	}
+	testFile := createTestVideo(t)
+	if testFile == "" {
+		return
+	}
+
+	tmpDir := t.TempDir()
+	outputFile := filepath.Join(tmpDir, "extracted.png")
+
+	// Extract frame at 500ms
+	if err := ExtractFrame(testFile, 500*time.Millisecond, outputFile); err != nil {
+		t.Fatalf("ExtractFrame failed: %v", err)
+	}
+
+	// Verify file exists
+	if info, err := os.Stat(outputFile); err != nil {
+		t.Errorf("Output file not created: %v", err)
+	} else {
+		t.Logf("Extracted frame: %s (%d bytes)", outputFile, info.Size())
+	}
+}
+
+func TestGenerateThumbnails(t *testing.T) {
+	if !requireFFmpeg(t) {
+		return
+	}
+	testFile := createTestVideo(t)
+	if testFile == "" {
+		return
+	}
+
+	tmpDir := t.TempDir()
+	outputPattern := filepath.Join(tmpDir, "thumb_%02d.png")
+
+	// Generate thumbnails every 300ms, max 3
+	filenames, err := GenerateThumbnails(testFile, 300*time.Millisecond, 3, outputPattern)
+	if err != nil {
+		t.Fatalf("GenerateThumbnails failed: %v", err)
+	}
+
+	t.Logf("Generated %d thumbnails", len(filenames))
+	for _, f := range filenames {
+		if info, err := os.Stat(f); err != nil {
+			t.Errorf("Thumbnail not found: %s", f)
+		} else {
+			t.Logf("  %s (%d bytes)", f, info.Size())
+		}
+	}
+}
+
+func TestGetKeyframes(t *testing.T) {
+	if !requireFFmpeg(t) {
+		return
+	}
+	testFile := createTestVideo(t)
+	if testFile == "" {
+		return
+	}
+
+	decoder, err := NewDecoder(testFile)
+	if err != nil {
+		t.Fatalf("Failed to open file: %v", err)
+	}
+	defer decoder.Close()
+
+	keyframes, err := decoder.GetKeyframes()
+	if err != nil {
+		t.Fatalf("GetKeyframes failed: %v", err)
+	}
+
+	t.Logf("Found %d keyframes", len(keyframes))
+	for i, kf := range keyframes {
+		t.Logf("  Keyframe %d: PTS=%d, Time=%v, Pos=%d, Frame=%d", i, kf.PTS, kf.Time, kf.Position, kf.Frame)
+	}
+
+	// Should have at least one keyframe
+	if len(keyframes) == 0 {
+		t.Error("Expected at least one keyframe")
+	}
+}
+
+func TestBuildFrameIndex(t *testing.T) {
+	if !requireFFmpeg(t) {
+		return
+	}
+	testFile := createTestVideo(t)
+	if testFile == "" {
+		return
+	}
+
+	decoder, err := NewDecoder(testFile)
+	if err != nil {
+		t.Fatalf("Failed to open file: %v", err)
+	}
+	defer decoder.Close()
+
+	index, err := decoder.BuildFrameIndex()
+	if err != nil {
+		t.Fatalf("BuildFrameIndex failed: %v", err)
+	}
+	if len(index) == 0 {
+		t.Fatal("Expected index entries")
+	}
+	if !index[0].KeyFrame {
+		t.Error("Expected the first frame to be a keyframe")
+	}
+
+	keyframes, err := decoder.GetKeyframes()
+	if err != nil {
+		t.Fatalf("GetKeyframes failed: %v", err)
+	}
+	var nKey int
+	for i, e := range index {
+		if i > 0 && e.PTS < index[i-1].PTS {
+			t.Fatalf("entry %d: PTS %d before previous %d", i, e.PTS, index[i-1].PTS)
+		}
+		if e.KeyFrame {
+			nKey++
+		}
+	}
+	if nKey != len(keyframes) {
+		t.Errorf("index has %d keyframes, GetKeyframes found %d", nKey, len(keyframes))
+	}
+
+	// The decoder is left at the start.
+	frame, err := decoder.DecodeVideo()
+	if err != nil {
+		t.Fatalf("DecodeVideo after BuildFrameIndex failed: %v", err)
+	}
+	if pts := frameTimestamp(frame.ptr); pts != index[0].PTS {
+		t.Errorf("first decoded PTS = %d, want %d", pts, index[0].PTS)
+	}
+}
+
+func TestBuildFrameIndexDropsHeldFrame(t *testing.T) {
+	if !requireFFmpeg(t) {
+		return
+	}
+	decoder, err := NewDecoder(createTestVideo(t))
+	if err != nil {
+		t.Fatalf("Failed to open file: %v", err)
+	}
+	defer decoder.Close()
+
+	// SeekPrecise holds the frame at the target for the next decode; building
+	// the index rewinds, so that frame must not come back.
+	if err := decoder.SeekPrecise(decoder.Duration() / 2); err != nil {
+		t.Fatalf("SeekPrecise failed: %v", err)
+	}
+	index, err := decoder.BuildFrameIndex()
+	if err != nil {
+		t.Fatalf("BuildFrameIndex failed: %v", err)
+	}
+	frame, err := decoder.DecodeVideo()
+	if err != nil {
+		t.Fatalf("DecodeVideo after BuildFrameIndex failed: %v", err)
+	}
+	if pts := frameTimestamp(frame.ptr); pts != index[0].PTS {
+		t.Errorf("first decoded PTS = %d, want %d from the start", pts, index[0].PTS)
+	}
+}
+
+func TestSeekToFrameWithIndex(t *testing.T) {
+	if !requireFFmpeg(t) {
+		return
+	}
+	testFile := createTestVideo(t)
+	if testFile == "" {
+		return
+	}
+
+	decoder, err := NewDecoder(testFile)
+	if err != nil {
+		t.Fatalf("Failed to open file: %v", err)
+	}
+	defer decoder.Close()
+
+	index, err := decoder.BuildFrameIndex()
+	if err != nil {
+		t.Fatalf("BuildFrameIndex failed: %v", err)
+	}
+	decoder.UseFrameIndex(index)
+
+	for _, n := range []int64{int64(len(index)) / 2, 1, int64(len(index)) - 1, 0} {
+		if err := decoder.SeekToFrame(n); err != nil {
+			t.Fatalf("SeekToFrame(%d) failed: %v", n, err)
+		}
+		frame, err := decoder.DecodeVideo()
+		if err != nil || frame.IsNil() {
+			t.Fatalf("DecodeVideo after SeekToFrame(%d) failed: %v", n, err)
+		}
+		if got := frameTimestamp(frame.ptr); got != index[n].PTS {
+			t.Errorf("SeekToFrame(%d): got PTS %d, want %d", n, got, index[n].PTS)
+		}
+	}
+
+	if err := decoder.SeekToFrame(int64(len(index))); err == nil {
+		t.Error("expected error seeking past the frame index")
+	}
+}
+
+func TestNewNetworkDecoder(t *testing.T) {
+	if !requireFFmpeg(t) {
+		return
+	}
+	// Test that NewNetworkDecoder works with local files (simulating network behavior)
+	testFile := createTestVideo(t)
+	if testFile == "" {
+		return
+	}
+
+	// Test with protocol options using local file
+	// This tests that the options are properly passed through
+	decoder, err := NewNetworkDecoder("file://"+testFile, &ProtocolOptions{
+		Timeout:    5 * time.Second,
+		BufferSize: 32768,
+	})
+	if err != nil {
+		t.Fatalf("Failed to open with network decoder: %v", err)
+	}
+	defer decoder.Close()
+
+	// Verify we can read the file
+	if decoder.VideoStream() == nil {
+		t.Error("Expected video stream")
+	}
+
+	t.Logf("Video: %dx%d", decoder.VideoStream().Width, decoder.VideoStream().Height)
+
+	// Decode a frame to verify full functionality
+	frame, err := decoder.DecodeVideo()
+	if err != nil {
+		t.Errorf("Failed to decode frame: %v", err)
+	}
+	if frame.IsNil() {
+		t.Error("Expected a frame")
+	}
+}
+
+func TestGetAttachments(t *testing.T) {
+	if !requireFFmpeg(t) {
+		return
+	}
+	// Create a test video with an attachment using ffmpeg
+	tmpDir := t.TempDir()
+	testFile := filepath.Join(tmpDir, "with_attachment.mkv")
+
+	// Create a small attachment file
+	attachmentFile := filepath.Join(tmpDir, "test.txt")
+	attachmentData := []byte("This is a test attachment file for ffgo testing.")
+	if err := os.WriteFile(attachmentFile, attachmentData, 0644); err != nil {
+		t.Fatalf("Failed to write attachment file: %v", err)
+	}
+
+	// Create video with attachment
+	cmd := exec.Command("ffmpeg", "-y",
+		"-f", "lavfi", "-i", "testsrc=duration=1:size=320x240:rate=25",
+		"-attach", attachmentFile,
+		"-metadata:s:t:0", "mimetype=text/plain",
+		"-metadata:s:t:0", "filename=test.txt",
+		"-c:v", "libx264", "-preset", "ultrafast",
+		testFile)
+	if out, err := cmd.CombinedOutput(); err != nil {
+		t.Fatalf("Failed to create test video with attachment: %v\n%s", err, out)
+	}
+
+	decoder, err := NewDecoder(testFile)
+	if err != nil {
+		t.Fatalf("Failed to open file: %v", err)
+	}
+	defer decoder.Close()
+
+	// Check HasAttachments
+	if !decoder.HasAttachments() {
+		t.Error("Expected HasAttachments to be true")
+	}
+
+	attachments := decoder.Attachments()
+	t.Logf("Found %d attachments", len(attachments))
+
+	for i, att := range attachments {
+		t.Logf("  Attachment %d: filename=%q, mimetype=%q, size=%d bytes",
+			i, att.Filename, att.MimeType, len(att.Data))
+	}
+
+	if len(attachments) != 1 {
+		t.Errorf("Expected 1 attachment, got %d", len(attachments))
+	}
+
+	if len(attachments) >= 1 {
+		att := attachments[0]
+		if att.Filename != "test.txt" {
+			t.Errorf("Expected filename 'test.txt', got %q", att.Filename)
+		}
+		if att.MimeType != "text/plain" {
+			t.Errorf("Expected mimetype 'text/plain', got %q", att.MimeType)
+		}
+		if string(att.Data) != string(attachmentData) {
+			t.Errorf("Attachment data mismatch")
+		}
+	}
+
+	if got := decoder.GetAttachments(); len(got) != len(attachments) {
+		t.Errorf("GetAttachments returned %d attachments, Attachments %d", len(got), len(attachments))
+	}
+}
+
+func TestAttachmentIsFont(t *testing.T) {
+	tests := []struct {
+		att  Attachment
+		want bool
+	}{
+		{Attachment{Filename: "DejaVuSans.ttf", MimeType: "application/x-truetype-font"}, true},
+		{Attachment{Filename: "font.otf", MimeType: "application/vnd.ms-opentype"}, true},
+		{Attachment{Filename: "Arial.TTF"}, true},
+		{Attachment{Filename: "cover.jpg", MimeType: "image/jpeg"}, false},
+		{Attachment{Filename: "notes.txt", MimeType: "text/plain"}, false},
+	}
+	for _, tt := range tests {
+		if got := tt.att.IsFont(); got != tt.want {
+			t.Errorf("IsFont(%q, %q) = %v, want %v", tt.att.Filename, tt.att.MimeType, got, tt.want)
+		}
+	}
+}
+
+func TestChapterTime(t *testing.T) {
+	tests := []struct {
+		pts  int64
+		tb   Rational
+		want time.Duration
+	}{
+		{1500, Rational{Num: 1, Den: 1000}, 1500 * time.Millisecond},
+		{90000, Rational{Num: 1, Den: 90000}, time.Second},
+		// 3 hours at nanosecond precision would overflow pts*num*1e6.
+		{3 * 3600 * 1000000000, Rational{Num: 1, Den: 1000000000}, 3 * time.Hour},
+		{-1, Rational{Num: 1, Den: 1000}, 0},
+	}
+	for _, tt := range tests {
+		if got := chapterTime(tt.pts, tt.tb); got != tt.want {
+			t.Errorf("chapterTime(%d, %v) = %v, want %v", tt.pts, tt.tb, got, tt.want)
+		}
+	}
+}
+
+func TestGetChapters(t *testing.T) {
+	if !requireFFmpeg(t) {
+		return
+	}
+	// Create a test video with chapters using ffmpeg
+	tmpDir := t.TempDir()
+	testFile := filepath.Join(tmpDir, "chapters.mkv")
+
+	// Create a chapter file in FFmetadata format
+	chapterFile := filepath.Join(tmpDir, "chapters.txt")
+	chapterData := `;FFMETADATA1
+[CHAPTER]
+TIMEBASE=1/1000
+START=0
+END=500
+title=Introduction
+
+[CHAPTER]
+TIMEBASE=1/1000
+START=500
+END=1000
+title=Main Content
+`
+	if err := os.WriteFile(chapterFile, []byte(chapterData), 0644); err != nil {
+		t.Fatalf("Failed to write chapter file: %v", err)
+	}
+
+	// Create video with chapters
+	cmd := exec.Command("ffmpeg", "-y",
+		"-f", "lavfi", "-i", "testsrc=duration=1:size=320x240:rate=25",
+		"-i", chapterFile,
+		"-map_metadata", "1",
+		"-c:v", "libx264", "-preset", "ultrafast",
+		testFile)
+	if out, err := cmd.CombinedOutput(); err != nil {
+		t.Fatalf("Failed to create test video with chapters: %v\n%s", err, out)
+	}
+
+	decoder, err := NewDecoder(testFile)
+	if err != nil {
+		t.Fatalf("Failed to open file: %v", err)
+	}
+	defer decoder.Close()
+
+	chapters := decoder.GetChapters()
+	t.Logf("Found %d chapters", len(chapters))
+
+	for i, ch := range chapters {
+		t.Logf("  Chapter %d: %q (%v - %v)", i, ch.Title, ch.Start, ch.End)
+	}
+
+	if len(chapters) != 2 {
+		t.Errorf("Expected 2 chapters, got %d", len(chapters))
+	}
+	if got := decoder.Chapters(); len(got) != len(chapters) {
+		t.Errorf("Chapters() returned %d chapters, GetChapters() %d", len(got), len(chapters))
+	}
+
+	if len(chapters) >= 2 {
+		if chapters[0].Title != "Introduction" {
+			t.Errorf("Expected first chapter title 'Introduction', got %q", chapters[0].Title)
+		}
+		if chapters[1].Title != "Main Content" {
+			t.Errorf("Expected second chapter title 'Main Content', got %q", chapters[1].Title)
+		}
+	}
+}
+
+func TestImageSequenceDecoder(t *testing.T) {
+	if !requireFFmpeg(t) {
+		return
+	}
+	// Create test images using ffmpeg
+	tmpDir := t.TempDir()
+	pattern := filepath.Join(tmpDir, "frame_%04d.png")
+
+	// Generate 5 image frames using ffmpeg
+	cmd := exec.Command("ffmpeg", "-y",
+		"-f", "lavfi", "-i", "testsrc=duration=0.2:size=160x120:rate=25",
+		"-frames:v", "5",
+		pattern)
+	if out, err := cmd.CombinedOutput(); err != nil {
+		t.Fatalf("Failed to create test images: %v\n%s", err, out)
+	}
+
+	// Verify images were created
+	for i := 1; i <= 5; i++ {
+		imgPath := filepath.Join(tmpDir, "frame_"+padNumber(i, 4)+".png")
+		if _, err := os.Stat(imgPath); err != nil {
+			t.Fatalf("Expected image %s not found: %v", imgPath, err)
+		}
+	}
+
+	// Test image sequence decoder
+	config := ImageSequenceConfig{
+		Pattern:     pattern,
+		StartNumber: 1,
+		FrameRate:   NewRational(25, 1),
+	}
+
+	decoder, err := NewImageSequenceDecoder(config)
+	if err != nil {
+		t.Fatalf("NewImageSequenceDecoder failed: %v", err)
+	}
+	defer decoder.Close()
+
+	// Decode frames
+	frameCount := 0
+	for i := 0; i < 5; i++ {
+		frame, err := decoder.DecodeVideo()
+		if err != nil {
+			if IsEOF(err) {
+				break
+			}
+			t.Fatalf("DecodeVideo failed: %v", err)
+		}
+		if frame.IsNil() {
+			break
+		}
+		frameCount++
+		info := GetFrameInfo(frame)
+		t.Logf("Frame %d: %dx%d", frameCount, info.Width, info.Height)
+	}
+
+	if frameCount == 0 {
+		t.Error("Expected at least one frame to be decoded")
+	}
+	t.Logf("Decoded %d frames from image sequence", frameCount)
+}
+
+func TestImageSequenceEncoder(t *testing.T) {
+	if !requireFFmpeg(t) {
+		return
+	}
+	// Create output directory
+	tmpDir := t.TempDir()
+	pattern := filepath.Join(tmpDir, "out_%04d.png")
+
+	// Create test frame data
+	width, height := 160, 120
+
+	// Create encoder for image sequence
+	config := ImageSequenceConfig{
+		Pattern:   pattern,
+		FrameRate: NewRational(25, 1),
+	}
+
+	encoder, err := NewImageSequenceEncoder(config, width, height, PixelFormatRGB24)
+	if err != nil {
+		t.Fatalf("NewImageSequenceEncoder failed: %v", err)
+	}
+	defer encoder.Close()
+
+	// Create and write 3 test frames
+	for i := 0; i < 3; i++ {
+		frame := FrameAlloc()
+		if frame.IsNil() {
+			t.Fatal("Failed to allocate frame")
+		}
+
+		AVUtil.SetFrameWidth(frame, int32(width))
+		AVUtil.SetFrameHeight(frame, int32(height))
+		AVUtil.SetFrameFormat(frame, int32(PixelFormatRGB24))
+
+		if err := AVUtil.FrameGetBuffer(frame, 32); err != nil {
+			_ = FrameFree(&frame)
+			t.Fatalf("Failed to allocate frame buffer: %v", err)
+		}
+
+		// Fill frame with color based on frame number
+		fillTestFrameRGB(frame, uint8((i+1)*80), uint8((i+1)*40), uint8((i+1)*20))
+
+		avutil.SetFramePTS(frame.ptr, int64(i))
+
+		if err := encoder.WriteVideoFrame(frame); err != nil {
+			_ = FrameFree(&frame)
+			t.Fatalf("WriteVideoFrame failed: %v", err)
+		}
+		_ = FrameFree(&frame)
+	}
+
+	// Close flushes and finalizes the encoder
+	encoder.Close()
+
+	// Verify output images exist
+	for i := 1; i <= 3; i++ {
+		imgPath := filepath.Join(tmpDir, "out_"+padNumber(i, 4)+".png")
+		info, err := os.Stat(imgPath)
+		if err != nil {
+			t.Errorf("Expected output image %s not found: %v", imgPath, err)
+			continue
+		}
+		t.Logf("Output image %d: %s (%d bytes)", i, imgPath, info.Size())
+	}
+}
+
+func padNumber(n, width int) string {
+	format := "%0" + fmt.Sprint(width) + "d"
+	return fmt.Sprintf(format, n)
+}
+
+func TestSubtitleRenderer(t *testing.T) {
+	if !requireFFmpeg(t) {
+		return
+	}
+	// Create test video file
+	testFile := createTestVideo(t)
+	if testFile == "" {
+		return
+	}
+
+	tmpDir := t.TempDir()
+
+	// Create a simple SRT subtitle file
+	srtPath := filepath.Join(tmpDir, "test.srt")
+	srtContent := `1
+00:00:00,000 --> 00:00:02,000
+Hello World
+
+2
+00:00:02,000 --> 00:00:04,000
+This is a test
+`
+	if err := os.WriteFile(srtPath, []byte(srtContent), 0644); err != nil {
+		t.Fatalf("Failed to write SRT file: %v", err)
+	}
+
+	// Open video to get frame dimensions
+	decoder, err := NewDecoder(testFile)
+	if err != nil {
+		t.Fatalf("NewDecoder failed: %v", err)
+	}
+	defer decoder.Close()
+
+	videoInfo := decoder.VideoStream()
+	if videoInfo == nil {
+		t.Fatal("No video stream")
+	}
+
+	// Create subtitle renderer
+	renderer, err := NewSubtitleRenderer(srtPath, videoInfo.Width, videoInfo.Height)
+	if err != nil {
+		// Some FFmpeg builds (notably Homebrew builds without libass) don't ship the
+		// "subtitles" filter. Treat that as an environment limitation rather than a
+		// functional failure of ffgo.
+		if strings.Contains(err.Error(), `filter "subtitles" not found`) {
+			t.Logf("SubtitleRenderer unavailable in this FFmpeg build: %v", err)
+			return
+		}
+		t.Fatalf("NewSubtitleRenderer failed: %v", err)
+	}
+	defer renderer.Close()
+
+	// Verify subtitle path
+	if renderer.SubtitlePath() != srtPath {
+		t.Errorf("SubtitlePath() = %q, want %q", renderer.SubtitlePath(), srtPath)
+	}
+
+	// Decode a video frame
+	frame, err := decoder.DecodeVideo()
+	if err != nil {
+		t.Fatalf("DecodeVideo failed: %v", err)
+	}
+	if frame.IsNil() {
+		t.Fatal("No video frame decoded")
+	}
+
+	// Render subtitle onto frame
+	outFrame, err := renderer.Render(frame)
+	if err != nil {
+		t.Fatalf("Render failed: %v", err)
+	}
+
+	if outFrame.IsNil() {
+		t.Fatal("Render returned nil frame")
+	}
+	defer func() { _ = FrameFree(&outFrame) }()
+
+	// Verify output frame dimensions
+	outWidth := avutil.GetFrameWidth(outFrame.ptr)
+	outHeight := avutil.GetFrameHeight(outFrame.ptr)
+	if outWidth != int32(videoInfo.Width) || outHeight != int32(videoInfo.Height) {
+		t.Errorf("Output frame dimensions: %dx%d, want %dx%d", outWidth, outHeight, videoInfo.Width, videoInfo.Height)
+	}
+
+	t.Log("SubtitleRenderer test passed")
+}
+
+func TestSubtitleRendererWithOptions(t *testing.T) {
+	if !requireFFmpeg(t) {
+		return
+	}
+	tmpDir := t.TempDir()
+
+	// Create a simple SRT subtitle file
+	srtPath := filepath.Join(tmpDir, "test.srt")
+	srtContent := `1
+00:00:00,000 --> 00:00:01,000
+Test
+`
+	if err := os.WriteFile(srtPath, []byte(srtContent), 0644); err != nil {
+		t.Fatalf("Failed to write SRT file: %v", err)
+	}
+
+	// Create subtitle renderer with full options including force_style
+	renderer, err := NewSubtitleRendererWithOptions(srtPath, 320, 240, &SubtitleRendererOptions{
+		FontSize:     24,
+		PrimaryColor: "&HFFFFFF&",
+		MarginV:      20,
+		CharEncoding: "UTF-8",
+	})
+	if err != nil {
+		if strings.Contains(err.Error(), `filter "subtitles" not found`) {
+			t.Logf("SubtitleRenderer unavailable in this FFmpeg build: %v", err)
+			return
+		}
+		t.Fatalf("NewSubtitleRendererWithOptions failed: %v", err)
+	}
+	defer renderer.Close()
+
+	t.Log("SubtitleRendererWithOptions created successfully")
+}
+
+func TestSubtitleFormat(t *testing.T) {
+	if !requireFFmpeg(t) {
+		return
+	}
+	tests := []struct {
+		format SubtitleFormat
+		want   string
+	}{
+		{SubtitleFormatSRT, "srt"},
+		{SubtitleFormatASS, "ass"},
+		{SubtitleFormatSSA, "ssa"},
+		{SubtitleFormatWebVTT, "vtt"},
+		{SubtitleFormatMOVText, "mov_text"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.want, func(t *testing.T) {
+			if got := tt.format.String(); got != tt.want {
+				t.Errorf("SubtitleFormat.String() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMuxer(t *testing.T) {
+	if !requireFFmpeg(t) {
+		return
+	}
+	tmpDir := t.TempDir()
+	outputPath := filepath.Join(tmpDir, "muxer_output.mp4")
+
+	// Create muxer
+	muxer, err := NewMuxer(outputPath, "mp4")
+	if err != nil {
+		t.Fatalf("NewMuxer failed: %v", err)
+	}
+	defer muxer.Close()
+
+	// Add video stream
+	videoStream, err := muxer.AddVideoStream(&VideoStreamConfig{
+		Codec:       CodecIDH264,
+		Width:       160,
+		Height:      120,
+		PixelFormat: PixelFormatYUV420P,
+		FrameRate:   25,
+		BitRate:     500000,
+	})
+	if err != nil {
+		t.Fatalf("AddVideoStream failed: %v", err)
+	}
+
+	if videoStream.MediaType() != MediaTypeVideo {
+		t.Errorf("Expected video stream, got %v", videoStream.MediaType())
+	}
+
+	// Write header
+	if err := muxer.WriteHeader(); err != nil {
+		t.Fatalf("WriteHeader failed: %v", err)
+	}
+
+	// Create and write test frames
+	for i := 0; i < 10; i++ {
+		frame := FrameAlloc()
+		if frame.IsNil() {
+			t.Fatal("Failed to allocate frame")
+		}
+
+		AVUtil.SetFrameWidth(frame, 160)
+		AVUtil.SetFrameHeight(frame, 120)
+		AVUtil.SetFrameFormat(frame, int32(PixelFormatYUV420P))
+
+		if err := AVUtil.FrameGetBuffer(frame, 32); err != nil {
+			_ = FrameFree(&frame)
+			t.Fatalf("Failed to allocate frame buffer: %v", err)
+		}
+
+		// Fill frame with test pattern
+		fillTestFrameYUV420(frame, uint8(i*25))
+		avutil.SetFramePTS(frame.ptr, int64(i))
+
+		if err := muxer.WriteFrame(videoStream, frame); err != nil {
+			_ = FrameFree(&frame)
+			t.Fatalf("WriteFrame failed: %v", err)
+		}
+		_ = FrameFree(&frame)
+	}
+
+	// Write trailer
+	if err := muxer.WriteTrailer(); err != nil {
+		t.Fatalf("WriteTrailer failed: %v", err)
+	}
+
+	// Close muxer
+	muxer.Close()
+
+	// Verify output file exists and has reasonable size
+	info, err := os.Stat(outputPath)
+	if err != nil {
+		t.Fatalf("Output file not found: %v", err)
+	}
+	if info.Size() < 1000 {
+		t.Errorf("Output file too small: %d bytes", info.Size())
+	}
+	t.Logf("Created output file: %s (%d bytes)", outputPath, info.Size())
+
+	// Verify output file can be opened
+	decoder, err := NewDecoder(outputPath)
+	if err != nil {
+		t.Fatalf("Failed to open output: %v", err)
+	}
+	defer decoder.Close()
+
+	if !decoder.HasVideo() {
+		t.Error("Output has no video stream")
+	}
+	videoInfo := decoder.VideoStream()
+	if videoInfo.Width != 160 || videoInfo.Height != 120 {
+		t.Errorf("Unexpected output dimensions: %dx%d", videoInfo.Width, videoInfo.Height)
+	}
+	t.Logf("Output video: %dx%d", videoInfo.Width, videoInfo.Height)
+}
+
+func fillTestFrameYUV420(frame Frame, value uint8) {
+	width := int(avutil.GetFrameWidth(frame.ptr))
+	height := int(avutil.GetFrameHeight(frame.ptr))
+
+	// Y plane
+	yPtr := avutil.GetFrameDataPlane(frame.ptr, 0)
+	yLinesize := avutil.GetFrameLinesizePlane(frame.ptr, 0)
+	if yPtr != nil && yLinesize > 0 {
+		yData := unsafe.Slice((*byte)(yPtr), int(yLinesize)*height)
+		for y := 0; y < height; y++ {
+			for x := 0; x < width; x++ {
+				yData[y*int(yLinesize)+x] = value
+			}
+		}
+	}
+
+	// U and V planes (half resolution)
+	uvHeight := height / 2
+	uvWidth := width / 2
+
+	uPtr := avutil.GetFrameDataPlane(frame.ptr, 1)
+	uLinesize := avutil.GetFrameLinesizePlane(frame.ptr, 1)
+	if uPtr != nil && uLinesize > 0 {
+		uData := unsafe.Slice((*byte)(uPtr), int(uLinesize)*uvHeight)
+		for y := 0; y < uvHeight; y++ {
+			for x := 0; x < uvWidth; x++ {
+				uData[y*int(uLinesize)+x] = 128
+			}
+		}
+	}
+
+	vPtr := avutil.GetFrameDataPlane(frame.ptr, 2)
+	vLinesize := avutil.GetFrameLinesizePlane(frame.ptr, 2)
+	if vPtr != nil && vLinesize > 0 {
+		vData := unsafe.Slice((*byte)(vPtr), int(vLinesize)*uvHeight)
+		for y := 0; y < uvHeight; y++ {
+			for x := 0; x < uvWidth; x++ {
+				vData[y*int(vLinesize)+x] = 128
+			}
+		}
+	}
+}
+
+func fillTestFrameRGB(frame Frame, r, g, b uint8) {
+	width := int(avutil.GetFrameWidth(frame.ptr))
+	height := int(avutil.GetFrameHeight(frame.ptr))
+	dataPtr := avutil.GetFrameDataPlane(frame.ptr, 0)
+	linesize := avutil.GetFrameLinesizePlane(frame.ptr, 0)
+
+	if dataPtr == nil || linesize == 0 {
+		return
+	}
+
+	// Create a byte slice from the data pointer
+	// RGB24: 3 bytes per pixel, linesize bytes per row
+	totalSize := int(linesize) * height
+	data := unsafe.Slice((*byte)(dataPtr), totalSize)
+
+	// RGB24: 3 bytes per pixel
+	for y := 0; y < height; y++ {
+		rowOffset := y * int(linesize)
+		for x := 0; x < width; x++ {
+			pixelOffset := rowOffset + x*3
+			if pixelOffset+2 < totalSize {
+				data[pixelOffset] = r
+				data[pixelOffset+1] = g
+				data[pixelOffset+2] = b
+			}
+		}
+	}
+}
+
+func TestDiagnose(t *testing.T) {
+	if !requireFFmpeg(t) {
+		return
+	}
+	info := Diagnose()
+
+	// Platform should be set
+	if info.GOOS == "" {
+		t.Error("GOOS should not be empty")
+	}
+	if info.GOARCH == "" {
+		t.Error("GOARCH should not be empty")
+	}
+
+	// FFmpeg should be loaded
+	if !info.FFmpegLoaded {
+		t.Error("FFmpegLoaded should be true")
+	}
+	if info.AVUtilVersion == 0 {
+		t.Error("AVUtilVersion should not be 0")
+	}
+	if info.AVCodecVersion == 0 {
+		t.Error("AVCodecVersion should not be 0")
+	}
+	if info.AVFormatVersion == 0 {
+		t.Error("AVFormatVersion should not be 0")
+	}
+
+	// Test String() method
+	str := info.String()
+	if str == "" {
+		t.Error("String() should not return empty")
+	}
+	t.Log(str)
+
+	// Shim status should be populated
+	if info.ShimLoaded {
+		if info.ShimPath == "" {
+			t.Error("ShimPath should be set when ShimLoaded is true")
+		}
+		t.Logf("Shim loaded from: %s", info.ShimPath)
+	} else {
+		t.Logf("Shim not loaded: %s", info.ShimError)
+	}
+}
+
+func TestShimStatus(t *testing.T) {
+	if !requireFFmpeg(t) {
+		return
+	}
+	status := ShimStatus()
+	if status == "" {
+		t.Error("ShimStatus should not return empty")
 	}
+	t.Logf("Shim status: %s", status)
 }
 
-func TestGenerateThumbnails(t *testing.T) {
+func TestShimBuildInstructions(t *testing.T) {
 	if !requireFFmpeg(t) {
 		return
 	}
-	testFile := createTestVideo(t)
-	if testFile == "" {
-		return
-	}
-
-	tmpDir := t.TempDir()
-	outputPattern := filepath.Join(tmpDir, "thumb_%02d.png")
-
-	// Generate thumbnails every 300ms, max 3
-	filenames, err := GenerateThumbnails(testFile, 300*time.Millisecond, 3, outputPattern)
-	if err != nil {
-		t.Fatalf("GenerateThumbnails failed: %v", err)
+	instructions := ShimBuildInstructions()
+	if instructions == "" {
+		t.Error("ShimBuildInstructions should not return empty")
 	}
-
-	t.Logf("Generated %d thumbnails", len(filenames))
-	for _, f := range filenames {
-		if info, err := os.Stat(f); err != nil {
-			t.Errorf("Thumbnail not found: %s", f)
-		} else {
-			t.Logf("  %s (%d bytes)", f, info.Size())
-		}
+	// Verify it contains some expected content
+	if len(instructions) < 50 {
+		t.Error("ShimBuildInstructions seems too short")
 	}
+	t.Logf("Build instructions length: %d chars", len(instructions))
 }
 
-func TestGetKeyframes(t *testing.T) {
+func TestMuxerAddStreamPackets(t *testing.T) {
 	if !requireFFmpeg(t) {
 		return
 	}
 	testFile := createTestVideo(t)
-	if testFile == "" {
-		return
-	}
 
 	decoder, err := NewDecoder(testFile)
 	if err != nil {
-		t.Fatalf("Failed to open file: %v", err)
+		t.Fatalf("Failed to open input: %v", err)
 	}
 	defer decoder.Close()
 
-	keyframes, err := decoder.GetKeyframes()
+	outputPath := filepath.Join(t.TempDir(), "packets.mkv")
+	muxer, err := NewMuxerWithOptions(outputPath, MuxerOptions{})
 	if err != nil {
-		t.Fatalf("GetKeyframes failed: %v", err)
+		t.Fatalf("NewMuxerWithOptions failed: %v", err)
 	}
+	defer muxer.Close()
 
-	t.Logf("Found %d keyframes", len(keyframes))
-	for i, kf := range keyframes {
-		t.Logf("  Keyframe %d: PTS=%d, Time=%v, Pos=%d, Frame=%d", i, kf.PTS, kf.Time, kf.Position, kf.Frame)
+	video := decoder.VideoStream()
+	idx, err := muxer.AddStream(video.CodecParameters(), video.TimeBase)
+	if err != nil {
+		t.Fatalf("AddStream failed: %v", err)
 	}
-
-	// Should have at least one keyframe
-	if len(keyframes) == 0 {
-		t.Error("Expected at least one keyframe")
+	if err := muxer.WriteHeader(); err != nil {
+		t.Fatalf("WriteHeader failed: %v", err)
 	}
-}
 
-func TestNewNetworkDecoder(t *testing.T) {
-	if !requireFFmpeg(t) {
-		return
-	}
-	// Test that NewNetworkDecoder works with local files (simulating network behavior)
-	testFile := createTestVideo(t)
-	if testFile == "" {
-		return
+	written := 0
+	for {
+		pkt, err := decoder.ReadPacket()
+		if err != nil {
+			t.Fatalf("ReadPacket failed: %v", err)
+		}
+		if pkt == nil {
+			break
+		}
+		if pkt.StreamIndex() != video.Index {
+			continue
+		}
+		if err := muxer.WriteStreamPacket(idx, pkt); err != nil {
+			t.Fatalf("WriteStreamPacket failed: %v", err)
+		}
+		written++
 	}
-
-	// Test with protocol options using local file
-	// This tests that the options are properly passed through
-	decoder, err := NewNetworkDecoder("file://"+testFile, &ProtocolOptions{
-		Timeout:    5 * time.Second,
-		BufferSize: 32768,
-	})
-	if err != nil {
-		t.Fatalf("Failed to open with network decoder: %v", err)
+	if err := muxer.WriteStreamPacket(idx+1, &Packet{}); err == nil {
+		t.Error("WriteStreamPacket with invalid index should fail")
 	}
-	defer decoder.Close()
 
-	// Verify we can read the file
-	if decoder.VideoStream() == nil {
-		t.Error("Expected video stream")
+	// Close writes the trailer.
+	if err := muxer.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
 	}
 
-	t.Logf("Video: %dx%d", decoder.VideoStream().Width, decoder.VideoStream().Height)
-
-	// Decode a frame to verify full functionality
-	frame, err := decoder.DecodeVideo()
+	out, err := NewDecoder(outputPath)
 	if err != nil {
-		t.Errorf("Failed to decode frame: %v", err)
+		t.Fatalf("Failed to open muxed output: %v", err)
 	}
-	if frame.IsNil() {
-		t.Error("Expected a frame")
+	defer out.Close()
+	if !out.HasVideo() {
+		t.Error("Muxed output has no video stream")
 	}
+	t.Logf("Muxed %d packets", written)
 }
 
-func TestGetAttachments(t *testing.T) {
+func TestDecoderStreams(t *testing.T) {
 	if !requireFFmpeg(t) {
 		return
 	}
-	// Create a test video with an attachment using ffmpeg
-	tmpDir := t.TempDir()
-	testFile := filepath.Join(tmpDir, "with_attachment.mkv")
-
-	// Create a small attachment file
-	attachmentFile := filepath.Join(tmpDir, "test.txt")
-	attachmentData := []byte("This is a test attachment file for ffgo testing.")
-	if err := os.WriteFile(attachmentFile, attachmentData, 0644); err != nil {
-		t.Fatalf("Failed to write attachment file: %v", err)
-	}
-
-	// Create video with attachment
-	cmd := exec.Command("ffmpeg", "-y",
-		"-f", "lavfi", "-i", "testsrc=duration=1:size=320x240:rate=25",
-		"-attach", attachmentFile,
-		"-metadata:s:t:0", "mimetype=text/plain",
-		"-metadata:s:t:0", "filename=test.txt",
-		"-c:v", "libx264", "-preset", "ultrafast",
-		testFile)
-	if out, err := cmd.CombinedOutput(); err != nil {
-		t.Fatalf("Failed to create test video with attachment: %v\n%s", err, out)
-	}
+	testFile := createTestVideo(t)
 
 	decoder, err := NewDecoder(testFile)
 	if err != nil {
@@ -2488,619 +5886,694 @@ func TestGetAttachments(t *testing.T) {
 	}
 	defer decoder.Close()
 
-	// Check HasAttachments
-	if !decoder.HasAttachments() {
-		t.Error("Expected HasAttachments to be true")
-	}
-
-	attachments := decoder.GetAttachments()
-	t.Logf("Found %d attachments", len(attachments))
-
-	for i, att := range attachments {
-		t.Logf("  Attachment %d: filename=%q, mimetype=%q, size=%d bytes",
-			i, att.Filename, att.MimeType, len(att.Data))
-	}
-
-	if len(attachments) != 1 {
-		t.Errorf("Expected 1 attachment, got %d", len(attachments))
+	streams := decoder.Streams()
+	if len(streams) != decoder.NumStreams() {
+		t.Fatalf("Streams() returned %d streams, NumStreams() = %d", len(streams), decoder.NumStreams())
 	}
-
-	if len(attachments) >= 1 {
-		att := attachments[0]
-		if att.Filename != "test.txt" {
-			t.Errorf("Expected filename 'test.txt', got %q", att.Filename)
-		}
-		if att.MimeType != "text/plain" {
-			t.Errorf("Expected mimetype 'text/plain', got %q", att.MimeType)
+	for i, s := range streams {
+		if s.Index != i {
+			t.Errorf("stream %d has Index %d", i, s.Index)
 		}
-		if string(att.Data) != string(attachmentData) {
-			t.Errorf("Attachment data mismatch")
+		byIdx := decoder.StreamInfoByIndex(i)
+		if byIdx == nil || byIdx.Type != s.Type || byIdx.CodecID != s.CodecID {
+			t.Errorf("StreamInfoByIndex(%d) = %+v, want %+v", i, byIdx, s)
 		}
 	}
+	if decoder.StreamInfoByIndex(len(streams)) != nil {
+		t.Error("StreamInfoByIndex out of range should return nil")
+	}
 }
 
-func TestGetChapters(t *testing.T) {
+func TestVerifyOutput(t *testing.T) {
 	if !requireFFmpeg(t) {
 		return
 	}
-	// Create a test video with chapters using ffmpeg
-	tmpDir := t.TempDir()
-	testFile := filepath.Join(tmpDir, "chapters.mkv")
-
-	// Create a chapter file in FFmetadata format
-	chapterFile := filepath.Join(tmpDir, "chapters.txt")
-	chapterData := `;FFMETADATA1
-[CHAPTER]
-TIMEBASE=1/1000
-START=0
-END=500
-title=Introduction
-
-[CHAPTER]
-TIMEBASE=1/1000
-START=500
-END=1000
-title=Main Content
-`
-	if err := os.WriteFile(chapterFile, []byte(chapterData), 0644); err != nil {
-		t.Fatalf("Failed to write chapter file: %v", err)
-	}
-
-	// Create video with chapters
-	cmd := exec.Command("ffmpeg", "-y",
-		"-f", "lavfi", "-i", "testsrc=duration=1:size=320x240:rate=25",
-		"-i", chapterFile,
-		"-map_metadata", "1",
-		"-c:v", "libx264", "-preset", "ultrafast",
-		testFile)
-	if out, err := cmd.CombinedOutput(); err != nil {
-		t.Fatalf("Failed to create test video with chapters: %v\n%s", err, out)
-	}
+	testFile := createTestVideo(t)
 
-	decoder, err := NewDecoder(testFile)
+	d, err := NewDecoder(testFile)
 	if err != nil {
 		t.Fatalf("Failed to open file: %v", err)
 	}
-	defer decoder.Close()
-
-	chapters := decoder.GetChapters()
-	t.Logf("Found %d chapters", len(chapters))
+	duration := d.Duration()
+	d.Close()
 
-	for i, ch := range chapters {
-		t.Logf("  Chapter %d: %q (%v - %v)", i, ch.Title, ch.Start, ch.End)
+	report, err := VerifyOutput(testFile, ExpectedStreams{Video: 1, Duration: duration})
+	if err != nil {
+		t.Fatalf("VerifyOutput failed: %v", err)
 	}
-
-	if len(chapters) != 2 {
-		t.Errorf("Expected 2 chapters, got %d", len(chapters))
+	if !report.OK() {
+		t.Errorf("VerifyOutput reported problems: %v", report.Problems)
+	}
+	if report.Packets == 0 {
+		t.Error("VerifyOutput read no packets")
 	}
 
-	if len(chapters) >= 2 {
-		if chapters[0].Title != "Introduction" {
-			t.Errorf("Expected first chapter title 'Introduction', got %q", chapters[0].Title)
-		}
-		if chapters[1].Title != "Main Content" {
-			t.Errorf("Expected second chapter title 'Main Content', got %q", chapters[1].Title)
-		}
+	report, err = VerifyOutput(testFile, ExpectedStreams{Video: 2, Duration: duration + time.Hour})
+	if err != nil {
+		t.Fatalf("VerifyOutput failed: %v", err)
+	}
+	if len(report.Problems) != 2 {
+		t.Errorf("expected 2 problems, got %v", report.Problems)
 	}
 }
 
-func TestImageSequenceDecoder(t *testing.T) {
+func TestVerifyOutputTruncated(t *testing.T) {
 	if !requireFFmpeg(t) {
 		return
 	}
-	// Create test images using ffmpeg
-	tmpDir := t.TempDir()
-	pattern := filepath.Join(tmpDir, "frame_%04d.png")
-
-	// Generate 5 image frames using ffmpeg
+	// Matroska writes the duration into the header, so a copy cut in half
+	// still claims the full duration.
+	full := filepath.Join(t.TempDir(), "full.mkv")
 	cmd := exec.Command("ffmpeg", "-y",
-		"-f", "lavfi", "-i", "testsrc=duration=0.2:size=160x120:rate=25",
-		"-frames:v", "5",
-		pattern)
-	if out, err := cmd.CombinedOutput(); err != nil {
-		t.Fatalf("Failed to create test images: %v\n%s", err, out)
+		"-f", "lavfi", "-i", "testsrc=duration=4:size=160x120:rate=25",
+		"-c:v", "mpeg4", full)
+	if err := cmd.Run(); err != nil {
+		t.Skipf("ffmpeg CLI not available: %v", err)
+	}
+	data, err := os.ReadFile(full)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cut := filepath.Join(t.TempDir(), "cut.mkv")
+	if err := os.WriteFile(cut, data[:len(data)/2], 0o644); err != nil {
+		t.Fatal(err)
 	}
 
-	// Verify images were created
-	for i := 1; i <= 5; i++ {
-		imgPath := filepath.Join(tmpDir, "frame_"+padNumber(i, 4)+".png")
-		if _, err := os.Stat(imgPath); err != nil {
-			t.Fatalf("Expected image %s not found: %v", imgPath, err)
-		}
+	report, err := VerifyOutput(cut, ExpectedStreams{Video: 1, Duration: 4 * time.Second})
+	if err != nil {
+		t.Fatalf("VerifyOutput failed: %v", err)
+	}
+	if report.Duration < 3*time.Second {
+		t.Skipf("container duration %v not kept by truncation", report.Duration)
 	}
+	if report.OK() {
+		t.Errorf("truncated file passed: packets cover %v", report.PacketDuration)
+	}
+	if report.PacketDuration > 3*time.Second {
+		t.Errorf("PacketDuration = %v, want about half of 4s", report.PacketDuration)
+	}
+}
 
-	// Test image sequence decoder
-	config := ImageSequenceConfig{
-		Pattern:     pattern,
-		StartNumber: 1,
-		FrameRate:   NewRational(25, 1),
+func TestDecoderSelectStream(t *testing.T) {
+	if !requireFFmpeg(t) {
+		return
 	}
+	testFile := createTestVideo(t)
 
-	decoder, err := NewImageSequenceDecoder(config)
+	decoder, err := NewDecoder(testFile)
 	if err != nil {
-		t.Fatalf("NewImageSequenceDecoder failed: %v", err)
+		t.Fatalf("Failed to open file: %v", err)
 	}
 	defer decoder.Close()
 
-	// Decode frames
-	frameCount := 0
-	for i := 0; i < 5; i++ {
-		frame, err := decoder.DecodeVideo()
-		if err != nil {
-			if IsEOF(err) {
-				break
-			}
-			t.Fatalf("DecodeVideo failed: %v", err)
-		}
-		if frame.IsNil() {
-			break
-		}
-		frameCount++
-		info := GetFrameInfo(frame)
-		t.Logf("Frame %d: %dx%d", frameCount, info.Width, info.Height)
+	videoIdx := decoder.VideoStream().Index
+	if err := decoder.SelectAudioStream(videoIdx); err == nil {
+		t.Error("SelectAudioStream on a video stream should fail")
+	}
+	if err := decoder.SelectVideoStream(decoder.NumStreams()); err == nil {
+		t.Error("SelectVideoStream out of range should fail")
+	}
+
+	if _, err := decoder.DecodeVideo(); err != nil {
+		t.Fatalf("DecodeVideo failed: %v", err)
+	}
+	if err := decoder.SelectVideoStream(videoIdx); err == nil {
+		t.Error("SelectVideoStream mid-decode should fail")
 	}
 
-	if frameCount == 0 {
-		t.Error("Expected at least one frame to be decoded")
+	decoder.FlushDecoder()
+	if err := decoder.SelectVideoStream(videoIdx); err != nil {
+		t.Fatalf("SelectVideoStream after flush failed: %v", err)
+	}
+	frame, err := decoder.DecodeVideo()
+	if err != nil {
+		t.Fatalf("DecodeVideo after reselect failed: %v", err)
+	}
+	if frame.IsNil() {
+		t.Error("Got nil frame after reselecting stream")
 	}
-	t.Logf("Decoded %d frames from image sequence", frameCount)
 }
 
-func TestImageSequenceEncoder(t *testing.T) {
-	if !requireFFmpeg(t) {
-		return
+func TestNewHWScaler(t *testing.T) {
+	if _, err := NewHWScaler(nil, PixelFormatNV12, PixelFormatNV12, 640, 360); err == nil {
+		t.Error("NewHWScaler with nil device should fail")
 	}
-	// Create output directory
-	tmpDir := t.TempDir()
-	pattern := filepath.Join(tmpDir, "out_%04d.png")
 
-	// Create test frame data
-	width, height := 160, 120
+	unsupported := &HWDevice{deviceType: HWDeviceTypeVDPAU}
+	if _, err := NewHWScaler(unsupported, PixelFormatNV12, PixelFormatNV12, 640, 360); err == nil {
+		t.Error("NewHWScaler on VDPAU should fail")
+	}
 
-	// Create encoder for image sequence
-	config := ImageSequenceConfig{
-		Pattern:   pattern,
-		FrameRate: NewRational(25, 1),
+	cuda := &HWDevice{deviceType: HWDeviceTypeCUDA}
+	if _, err := NewHWScaler(cuda, PixelFormatNV12, PixelFormatNV12, 0, 360); err == nil {
+		t.Error("NewHWScaler with zero width should fail")
 	}
 
-	encoder, err := NewImageSequenceEncoder(config, width, height, PixelFormatRGB24)
+	s, err := NewHWScaler(cuda, PixelFormatNV12, PixelFormatNone, 640, 360)
 	if err != nil {
-		t.Fatalf("NewImageSequenceEncoder failed: %v", err)
+		t.Fatalf("NewHWScaler failed: %v", err)
 	}
-	defer encoder.Close()
-
-	// Create and write 3 test frames
-	for i := 0; i < 3; i++ {
-		frame := FrameAlloc()
-		if frame.IsNil() {
-			t.Fatal("Failed to allocate frame")
-		}
-
-		AVUtil.SetFrameWidth(frame, int32(width))
-		AVUtil.SetFrameHeight(frame, int32(height))
-		AVUtil.SetFrameFormat(frame, int32(PixelFormatRGB24))
-
-		if err := AVUtil.FrameGetBuffer(frame, 32); err != nil {
-			_ = FrameFree(&frame)
-			t.Fatalf("Failed to allocate frame buffer: %v", err)
-		}
-
-		// Fill frame with color based on frame number
-		fillTestFrameRGB(frame, uint8((i+1)*80), uint8((i+1)*40), uint8((i+1)*20))
+	if s.filterName != "scale_cuda" {
+		t.Errorf("filter = %q, want scale_cuda", s.filterName)
+	}
+	if _, err := s.Scale(Frame{}); err == nil {
+		t.Error("Scale with nil frame should fail")
+	}
+	s.Close()
+	if _, err := s.Scale(Frame{}); err != ErrHWScalerClosed {
+		t.Errorf("Scale after Close = %v, want ErrHWScalerClosed", err)
+	}
+}
 
-		avutil.SetFramePTS(frame.ptr, int64(i))
+func TestNewHWEncoder(t *testing.T) {
+	if _, err := NewHWEncoder("out.mp4", HWEncoderConfig{Width: 640, Height: 360}); err == nil {
+		t.Error("NewHWEncoder without device should fail")
+	}
 
-		if err := encoder.WriteVideoFrame(frame); err != nil {
-			_ = FrameFree(&frame)
-			t.Fatalf("WriteVideoFrame failed: %v", err)
-		}
-		_ = FrameFree(&frame)
+	cuda := &HWDevice{deviceType: HWDeviceTypeCUDA}
+	if _, err := NewHWEncoder("out.mp4", HWEncoderConfig{HWDevice: cuda, Width: 0, Height: 360}); err == nil {
+		t.Error("NewHWEncoder with zero width should fail")
+	}
+	if _, err := NewHWEncoder("out.mp4", HWEncoderConfig{HWDevice: cuda, Codec: "h264_vaapi", Width: 640, Height: 360}); err == nil {
+		t.Error("NewHWEncoder with a VAAPI encoder on a CUDA device should fail")
 	}
 
-	// Close flushes and finalizes the encoder
-	encoder.Close()
+	unsupported := &HWDevice{deviceType: HWDeviceTypeVDPAU}
+	if _, err := NewHWEncoder("out.mp4", HWEncoderConfig{HWDevice: unsupported, Width: 640, Height: 360}); err == nil {
+		t.Error("NewHWEncoder on VDPAU without a codec name should fail")
+	}
 
-	// Verify output images exist
-	for i := 1; i <= 3; i++ {
-		imgPath := filepath.Join(tmpDir, "out_"+padNumber(i, 4)+".png")
-		info, err := os.Stat(imgPath)
-		if err != nil {
-			t.Errorf("Expected output image %s not found: %v", imgPath, err)
-			continue
+	for name, want := range map[string]HWDeviceType{
+		"h264_nvenc":        HWDeviceTypeCUDA,
+		"hevc_nvenc":        HWDeviceTypeCUDA,
+		"h264_vaapi":        HWDeviceTypeVAAPI,
+		"hevc_qsv":          HWDeviceTypeQSV,
+		"h264_videotoolbox": HWDeviceTypeVideoToolbox,
+		"libx264":           HWDeviceTypeNone,
+	} {
+		if got := hwEncoderDeviceType(name); got != want {
+			t.Errorf("hwEncoderDeviceType(%q) = %d, want %d", name, got, want)
 		}
-		t.Logf("Output image %d: %s (%d bytes)", i, imgPath, info.Size())
 	}
 }
 
-func padNumber(n, width int) string {
-	format := "%0" + fmt.Sprint(width) + "d"
-	return fmt.Sprintf(format, n)
-}
-
-func TestSubtitleRenderer(t *testing.T) {
+func TestHWEncoder(t *testing.T) {
 	if !requireFFmpeg(t) {
 		return
 	}
-	// Create test video file
-	testFile := createTestVideo(t)
-	if testFile == "" {
+	var device *HWDevice
+	for _, hwType := range AvailableHWDeviceTypes() {
+		if defaultHWEncoderName(hwType) == "" {
+			continue
+		}
+		d, err := NewHWDevice(hwType, "")
+		if err == nil {
+			device = d
+			break
+		}
+	}
+	if device == nil {
+		t.Log("No hardware encoder device available")
 		return
 	}
+	defer device.Close()
 
-	tmpDir := t.TempDir()
-
-	// Create a simple SRT subtitle file
-	srtPath := filepath.Join(tmpDir, "test.srt")
-	srtContent := `1
-00:00:00,000 --> 00:00:02,000
-Hello World
-
-2
-00:00:02,000 --> 00:00:04,000
-This is a test
-`
-	if err := os.WriteFile(srtPath, []byte(srtContent), 0644); err != nil {
-		t.Fatalf("Failed to write SRT file: %v", err)
+	outPath := filepath.Join(t.TempDir(), "hw.mp4")
+	enc, err := NewHWEncoder(outPath, HWEncoderConfig{
+		HWDevice:  device,
+		Width:     320,
+		Height:    240,
+		FrameRate: NewRational(25, 1),
+	})
+	if err != nil {
+		t.Logf("Hardware encoder not supported on %s: %v", device.TypeName(), err)
+		return
 	}
 
-	// Open video to get frame dimensions
-	decoder, err := NewDecoder(testFile)
+	frame, err := newVideoFrame(320, 240, PixelFormatNV12)
 	if err != nil {
-		t.Fatalf("NewDecoder failed: %v", err)
+		t.Fatalf("newVideoFrame failed: %v", err)
 	}
-	defer decoder.Close()
+	defer FrameFree(&frame)
 
-	videoInfo := decoder.VideoStream()
-	if videoInfo == nil {
-		t.Fatal("No video stream")
+	for i := 0; i < 10; i++ {
+		if err := enc.WriteFrame(frame); err != nil {
+			t.Fatalf("WriteFrame %d failed: %v", i, err)
+		}
 	}
 
-	// Create subtitle renderer
-	renderer, err := NewSubtitleRenderer(srtPath, videoInfo.Width, videoInfo.Height)
+	// Software frames in another format and size are converted on upload.
+	yuv, err := newVideoFrame(640, 480, PixelFormatYUV420P)
 	if err != nil {
-		// Some FFmpeg builds (notably Homebrew builds without libass) don't ship the
-		// "subtitles" filter. Treat that as an environment limitation rather than a
-		// functional failure of ffgo.
-		if strings.Contains(err.Error(), `filter "subtitles" not found`) {
-			t.Logf("SubtitleRenderer unavailable in this FFmpeg build: %v", err)
-			return
-		}
-		t.Fatalf("NewSubtitleRenderer failed: %v", err)
+		t.Fatalf("newVideoFrame failed: %v", err)
 	}
-	defer renderer.Close()
-
-	// Verify subtitle path
-	if renderer.SubtitlePath() != srtPath {
-		t.Errorf("SubtitlePath() = %q, want %q", renderer.SubtitlePath(), srtPath)
+	defer FrameFree(&yuv)
+	if err := enc.WriteFrame(yuv); err != nil {
+		t.Fatalf("WriteFrame(yuv420p) failed: %v", err)
 	}
 
-	// Decode a video frame
-	frame, err := decoder.DecodeVideo()
+	hw, err := enc.UploadFrame(yuv)
 	if err != nil {
-		t.Fatalf("DecodeVideo failed: %v", err)
+		t.Fatalf("UploadFrame failed: %v", err)
 	}
-	if frame.IsNil() {
-		t.Fatal("No video frame decoded")
+	if avutil.GetFrameHWFramesCtx(hw.ptr) == nil {
+		t.Error("UploadFrame returned a frame without a frames context")
+	}
+	if err := enc.WriteFrame(hw); err != nil {
+		t.Fatalf("WriteFrame(hw) failed: %v", err)
 	}
+	_ = hw.Free()
 
-	// Render subtitle onto frame
-	outFrame, err := renderer.Render(frame)
-	if err != nil {
-		t.Fatalf("Render failed: %v", err)
+	if err := enc.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+	if enc.FrameCount() != 12 {
+		t.Errorf("FrameCount = %d, want 12", enc.FrameCount())
 	}
+	if _, err := enc.UploadFrame(frame); err == nil {
+		t.Error("UploadFrame after Close should fail")
+	}
+}
 
-	if outFrame.IsNil() {
-		t.Fatal("Render returned nil frame")
+func TestHWDecoderKeepHardwareFrames(t *testing.T) {
+	if !requireFFmpeg(t) {
+		return
 	}
-	defer func() { _ = FrameFree(&outFrame) }()
+	types := AvailableHWDeviceTypes()
+	if len(types) == 0 {
+		t.Log("No hardware acceleration available")
+		return
+	}
+	device, err := NewHWDevice(types[0], "")
+	if err != nil {
+		t.Logf("Failed to create HW device: %v", err)
+		return
+	}
+	defer device.Close()
 
-	// Verify output frame dimensions
-	outWidth := avutil.GetFrameWidth(outFrame.ptr)
-	outHeight := avutil.GetFrameHeight(outFrame.ptr)
-	if outWidth != int32(videoInfo.Width) || outHeight != int32(videoInfo.Height) {
-		t.Errorf("Output frame dimensions: %dx%d, want %dx%d", outWidth, outHeight, videoInfo.Width, videoInfo.Height)
+	testFile := createTestVideo(t)
+	if testFile == "" {
+		return
+	}
+	dec, err := NewHWDecoder(testFile, &HWDecoderConfig{
+		HWDevice:             device,
+		OutputSoftwareFrames: true,
+		KeepHardwareFrames:   true,
+	})
+	if err != nil {
+		t.Logf("KeepHardwareFrames not available: %v", err)
+		return
 	}
+	defer dec.Close()
 
-	t.Log("SubtitleRenderer test passed")
+	frame, err := dec.DecodeVideo()
+	if err != nil {
+		t.Logf("HW decode failed for this codec/device: %v", err)
+		return
+	}
+	if avutil.GetFrameHWFramesCtx(frame.ptr) == nil {
+		t.Error("DecodeVideo returned a software frame with KeepHardwareFrames")
+	}
 }
 
-func TestSubtitleRendererWithOptions(t *testing.T) {
+func TestHWTranscode(t *testing.T) {
 	if !requireFFmpeg(t) {
 		return
 	}
-	tmpDir := t.TempDir()
+	var device *HWDevice
+	for _, hwType := range AvailableHWDeviceTypes() {
+		if defaultHWEncoderName(hwType) == "" {
+			continue
+		}
+		d, err := NewHWDevice(hwType, "")
+		if err == nil {
+			device = d
+			break
+		}
+	}
+	if device == nil {
+		t.Log("No hardware encoder device available")
+		return
+	}
+	defer device.Close()
 
-	// Create a simple SRT subtitle file
-	srtPath := filepath.Join(tmpDir, "test.srt")
-	srtContent := `1
-00:00:00,000 --> 00:00:01,000
-Test
-`
-	if err := os.WriteFile(srtPath, []byte(srtContent), 0644); err != nil {
-		t.Fatalf("Failed to write SRT file: %v", err)
+	testFile := createTestVideo(t)
+	if testFile == "" {
+		return
+	}
+	dec, err := NewHWDecoder(testFile, &HWDecoderConfig{HWDevice: device})
+	if err != nil {
+		t.Logf("HW decoder not supported for this codec/device: %v", err)
+		return
 	}
+	defer dec.Close()
 
-	// Create subtitle renderer with full options including force_style
-	renderer, err := NewSubtitleRendererWithOptions(srtPath, 320, 240, &SubtitleRendererOptions{
-		FontSize:     24,
-		PrimaryColor: "&HFFFFFF&",
-		MarginV:      20,
-		CharEncoding: "UTF-8",
+	info := dec.VideoStream()
+	enc, err := NewHWEncoder(filepath.Join(t.TempDir(), "hw2hw.mp4"), HWEncoderConfig{
+		HWDevice: device,
+		Width:    info.Width,
+		Height:   info.Height,
 	})
 	if err != nil {
-		if strings.Contains(err.Error(), `filter "subtitles" not found`) {
-			t.Logf("SubtitleRenderer unavailable in this FFmpeg build: %v", err)
+		t.Logf("Hardware encoder not supported on %s: %v", device.TypeName(), err)
+		return
+	}
+	defer enc.Close()
+
+	if err := enc.SetFramesContext(nil); err == nil {
+		t.Error("SetFramesContext(nil) should fail")
+	}
+
+	for i := 0; i < 5; i++ {
+		frame, err := dec.ReadHWFrame()
+		if err != nil {
+			t.Logf("ReadHWFrame stopped: %v", err)
+			break
+		}
+		if avutil.GetFrameHWFramesCtx(frame.ptr) == nil {
+			t.Log("Decoder produced software frames; skipping HW-to-HW encode")
 			return
 		}
-		t.Fatalf("NewSubtitleRendererWithOptions failed: %v", err)
+		if err := enc.WriteFrame(frame); err != nil {
+			t.Fatalf("WriteFrame(hw) failed: %v", err)
+		}
+	}
+	if dec.FramesContext() == nil {
+		t.Error("FramesContext is nil after decoding hardware frames")
+	}
+	if err := enc.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
 	}
-	defer renderer.Close()
-
-	t.Log("SubtitleRendererWithOptions created successfully")
 }
 
-func TestSubtitleFormat(t *testing.T) {
+func TestASRFrontend(t *testing.T) {
 	if !requireFFmpeg(t) {
 		return
 	}
-	tests := []struct {
-		format SubtitleFormat
-		want   string
-	}{
-		{SubtitleFormatSRT, "srt"},
-		{SubtitleFormatASS, "ass"},
-		{SubtitleFormatSSA, "ssa"},
-		{SubtitleFormatWebVTT, "vtt"},
-		{SubtitleFormatMOVText, "mov_text"},
+
+	if _, err := NewASRFrontend(AudioFormat{SampleRate: 48000, Channels: 2, SampleFormat: SampleFormatFltP}, 0); err == nil {
+		t.Error("NewASRFrontend with zero window should fail")
 	}
 
-	for _, tt := range tests {
-		t.Run(tt.want, func(t *testing.T) {
-			if got := tt.format.String(); got != tt.want {
-				t.Errorf("SubtitleFormat.String() = %q, want %q", got, tt.want)
-			}
-		})
+	asr, err := NewASRFrontend(AudioFormat{SampleRate: 48000, Channels: 2, SampleFormat: SampleFormatFltP}, ASRSampleRate)
+	if err != nil {
+		t.Fatalf("NewASRFrontend failed: %v", err)
+	}
+	defer asr.Close()
+
+	// Push 1.5 seconds of 48kHz stereo audio in 1024-sample frames.
+	const frameSize = 1024
+	for pushed := 0; pushed < 72000; pushed += frameSize {
+		ptr := avutil.FrameAlloc()
+		avutil.FrameSetSampleRate(ptr, 48000)
+		avutil.FrameSetChannels(ptr, 2)
+		avutil.FrameSetFormat(ptr, int32(SampleFormatFltP))
+		avutil.FrameSetNbSamples(ptr, frameSize)
+		if err := avutil.FrameGetBufferErr(ptr, 0); err != nil {
+			t.Fatalf("FrameGetBuffer failed: %v", err)
+		}
+		frame := Frame{ptr: ptr, owned: true}
+		if err := asr.Push(frame); err != nil {
+			t.Fatalf("Push failed: %v", err)
+		}
+		frame.Free()
+	}
+
+	windows := 0
+	for {
+		w, ok, err := asr.Pull()
+		if err != nil {
+			t.Fatalf("Pull failed: %v", err)
+		}
+		if !ok {
+			break
+		}
+		if n := avutil.GetFrameNbSamples(w.ptr); n != ASRSampleRate {
+			t.Errorf("window has %d samples, want %d", n, ASRSampleRate)
+		}
+		w.Free()
+		windows++
+	}
+	if windows != 1 {
+		t.Errorf("got %d windows before flush, want 1", windows)
+	}
+
+	if err := asr.Flush(); err != nil {
+		t.Fatalf("Flush failed: %v", err)
+	}
+	w, ok, err := asr.Pull()
+	if err != nil || !ok {
+		t.Fatalf("expected padded final window after Flush, got ok=%v err=%v", ok, err)
+	}
+	w.Free()
+	if asr.Buffered() != 0 {
+		t.Errorf("Buffered = %d after draining, want 0", asr.Buffered())
+	}
+
+	asr.Close()
+	if _, ok, err := asr.Pull(); ok || err == nil {
+		t.Errorf("Pull after Close = ok %v, err %v; want an error", ok, err)
 	}
 }
 
-func TestMuxer(t *testing.T) {
+func TestEncoderSetChaptersRoundTrip(t *testing.T) {
 	if !requireFFmpeg(t) {
 		return
 	}
-	tmpDir := t.TempDir()
-	outputPath := filepath.Join(tmpDir, "muxer_output.mp4")
-
-	// Create muxer
-	muxer, err := NewMuxer(outputPath, "mp4")
-	if err != nil {
-		t.Fatalf("NewMuxer failed: %v", err)
+	if err := shim.Load(); err != nil {
+		t.Logf("Skipping chapter round-trip: shim not available: %v", err)
+		return
 	}
-	defer muxer.Close()
+	outFile := filepath.Join(t.TempDir(), "chapters.mkv")
 
-	// Add video stream
-	videoStream, err := muxer.AddVideoStream(&VideoStreamConfig{
-		Codec:       CodecIDH264,
+	encoder, err := NewEncoder(outFile, EncoderConfig{
 		Width:       160,
 		Height:      120,
 		PixelFormat: PixelFormatYUV420P,
-		FrameRate:   25,
+		CodecID:     CodecIDH264,
 		BitRate:     500000,
+		FrameRate:   10,
+		GOPSize:     10,
 	})
 	if err != nil {
-		t.Fatalf("AddVideoStream failed: %v", err)
+		t.Fatalf("NewEncoder failed: %v", err)
 	}
+	defer encoder.Close()
 
-	if videoStream.MediaType() != MediaTypeVideo {
-		t.Errorf("Expected video stream, got %v", videoStream.MediaType())
+	want := []Chapter{
+		{ID: 1, Start: 0, End: 500 * time.Millisecond, Title: "Intro"},
+		{ID: 2, Start: 500 * time.Millisecond, End: time.Second, Title: "Main", Metadata: Metadata{"comment": "second"}},
 	}
-
-	// Write header
-	if err := muxer.WriteHeader(); err != nil {
-		t.Fatalf("WriteHeader failed: %v", err)
+	if err := encoder.SetChapters([]Chapter{{Start: time.Second, End: 0}}); err == nil {
+		t.Error("SetChapters with End < Start should fail")
+	}
+	if err := encoder.SetChapters(want); err != nil {
+		t.Fatalf("SetChapters failed: %v", err)
 	}
 
-	// Create and write test frames
+	frame := FrameAlloc()
+	defer func() { _ = FrameFree(&frame) }()
+	AVUtil.SetFrameWidth(frame, 160)
+	AVUtil.SetFrameHeight(frame, 120)
+	AVUtil.SetFrameFormat(frame, int32(PixelFormatYUV420P))
+	if err := AVUtil.FrameGetBuffer(frame, 0); err != nil {
+		t.Fatalf("FrameGetBuffer failed: %v", err)
+	}
 	for i := 0; i < 10; i++ {
-		frame := FrameAlloc()
-		if frame.IsNil() {
-			t.Fatal("Failed to allocate frame")
-		}
-
-		AVUtil.SetFrameWidth(frame, 160)
-		AVUtil.SetFrameHeight(frame, 120)
-		AVUtil.SetFrameFormat(frame, int32(PixelFormatYUV420P))
-
-		if err := AVUtil.FrameGetBuffer(frame, 32); err != nil {
-			_ = FrameFree(&frame)
-			t.Fatalf("Failed to allocate frame buffer: %v", err)
+		if err := AVUtil.FrameMakeWritable(frame); err != nil {
+			t.Fatalf("FrameMakeWritable failed: %v", err)
 		}
-
-		// Fill frame with test pattern
-		fillTestFrameYUV420(frame, uint8(i*25))
-		avutil.SetFramePTS(frame.ptr, int64(i))
-
-		if err := muxer.WriteFrame(videoStream, frame); err != nil {
-			_ = FrameFree(&frame)
+		fillTestFrame(frame, i, 160, 120)
+		if err := encoder.WriteFrame(frame); err != nil {
 			t.Fatalf("WriteFrame failed: %v", err)
 		}
-		_ = FrameFree(&frame)
 	}
-
-	// Write trailer
-	if err := muxer.WriteTrailer(); err != nil {
-		t.Fatalf("WriteTrailer failed: %v", err)
-	}
-
-	// Close muxer
-	muxer.Close()
-
-	// Verify output file exists and has reasonable size
-	info, err := os.Stat(outputPath)
-	if err != nil {
-		t.Fatalf("Output file not found: %v", err)
+	if err := encoder.SetChapters(want); err == nil {
+		t.Error("SetChapters after WriteHeader should fail")
 	}
-	if info.Size() < 1000 {
-		t.Errorf("Output file too small: %d bytes", info.Size())
+	if err := encoder.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
 	}
-	t.Logf("Created output file: %s (%d bytes)", outputPath, info.Size())
 
-	// Verify output file can be opened
-	decoder, err := NewDecoder(outputPath)
+	decoder, err := NewDecoder(outFile)
 	if err != nil {
-		t.Fatalf("Failed to open output: %v", err)
+		t.Fatalf("NewDecoder failed: %v", err)
 	}
 	defer decoder.Close()
 
-	if !decoder.HasVideo() {
-		t.Error("Output has no video stream")
+	got := decoder.Chapters()
+	if len(got) != len(want) {
+		t.Fatalf("read %d chapters, want %d", len(got), len(want))
 	}
-	videoInfo := decoder.VideoStream()
-	if videoInfo.Width != 160 || videoInfo.Height != 120 {
-		t.Errorf("Unexpected output dimensions: %dx%d", videoInfo.Width, videoInfo.Height)
+	for i := range want {
+		if got[i].Title != want[i].Title {
+			t.Errorf("chapter %d title = %q, want %q", i, got[i].Title, want[i].Title)
+		}
+		if got[i].Start != want[i].Start || got[i].End != want[i].End {
+			t.Errorf("chapter %d range = %v-%v, want %v-%v", i, got[i].Start, got[i].End, want[i].Start, want[i].End)
+		}
+	}
+	if got[1].Metadata["comment"] != "second" {
+		t.Errorf("chapter 1 metadata = %v, want comment=second", got[1].Metadata)
 	}
-	t.Logf("Output video: %dx%d", videoInfo.Width, videoInfo.Height)
 }
 
-func fillTestFrameYUV420(frame Frame, value uint8) {
-	width := int(avutil.GetFrameWidth(frame.ptr))
-	height := int(avutil.GetFrameHeight(frame.ptr))
-
-	// Y plane
-	yPtr := avutil.GetFrameDataPlane(frame.ptr, 0)
-	yLinesize := avutil.GetFrameLinesizePlane(frame.ptr, 0)
-	if yPtr != nil && yLinesize > 0 {
-		yData := unsafe.Slice((*byte)(yPtr), int(yLinesize)*height)
-		for y := 0; y < height; y++ {
-			for x := 0; x < width; x++ {
-				yData[y*int(yLinesize)+x] = value
-			}
-		}
+func TestSampleAspectRatioForDisplay(t *testing.T) {
+	tests := []struct {
+		dar           Rational
+		width, height int
+		want          Rational
+	}{
+		{Rational{Num: 16, Den: 9}, 1440, 1080, Rational{Num: 4, Den: 3}},
+		{Rational{Num: 16, Den: 9}, 1920, 1080, Rational{Num: 1, Den: 1}},
+		{Rational{Num: 4, Den: 3}, 720, 480, Rational{Num: 8, Den: 9}},
+		{Rational{Num: 16, Den: 9}, 720, 576, Rational{Num: 64, Den: 45}},
 	}
-
-	// U and V planes (half resolution)
-	uvHeight := height / 2
-	uvWidth := width / 2
-
-	uPtr := avutil.GetFrameDataPlane(frame.ptr, 1)
-	uLinesize := avutil.GetFrameLinesizePlane(frame.ptr, 1)
-	if uPtr != nil && uLinesize > 0 {
-		uData := unsafe.Slice((*byte)(uPtr), int(uLinesize)*uvHeight)
-		for y := 0; y < uvHeight; y++ {
-			for x := 0; x < uvWidth; x++ {
-				uData[y*int(uLinesize)+x] = 128
-			}
+	for _, tt := range tests {
+		if got := sampleAspectRatioForDisplay(tt.dar, tt.width, tt.height); got != tt.want {
+			t.Errorf("DAR %d:%d at %dx%d: SAR = %d:%d, want %d:%d",
+				tt.dar.Num, tt.dar.Den, tt.width, tt.height, got.Num, got.Den, tt.want.Num, tt.want.Den)
 		}
 	}
+}
 
-	vPtr := avutil.GetFrameDataPlane(frame.ptr, 2)
-	vLinesize := avutil.GetFrameLinesizePlane(frame.ptr, 2)
-	if vPtr != nil && vLinesize > 0 {
-		vData := unsafe.Slice((*byte)(vPtr), int(vLinesize)*uvHeight)
-		for y := 0; y < uvHeight; y++ {
-			for x := 0; x < uvWidth; x++ {
-				vData[y*int(vLinesize)+x] = 128
-			}
+func TestStreamInfoDisplayAspectRatio(t *testing.T) {
+	tests := []struct {
+		info StreamInfo
+		want Rational
+	}{
+		{StreamInfo{Width: 720, Height: 480, SampleAspectRatio: Rational{Num: 8, Den: 9}}, Rational{Num: 4, Den: 3}},
+		{StreamInfo{Width: 720, Height: 576, SampleAspectRatio: Rational{Num: 64, Den: 45}}, Rational{Num: 16, Den: 9}},
+		{StreamInfo{Width: 1920, Height: 1080}, Rational{Num: 16, Den: 9}},
+		{StreamInfo{SampleRate: 48000}, Rational{Num: 0, Den: 1}},
+	}
+	for _, tt := range tests {
+		if got := tt.info.DisplayAspectRatio(); got != tt.want {
+			t.Errorf("%dx%d SAR %d:%d: DAR = %d:%d, want %d:%d", tt.info.Width, tt.info.Height,
+				tt.info.SampleAspectRatio.Num, tt.info.SampleAspectRatio.Den, got.Num, got.Den, tt.want.Num, tt.want.Den)
 		}
 	}
 }
 
-func fillTestFrameRGB(frame Frame, r, g, b uint8) {
-	width := int(avutil.GetFrameWidth(frame.ptr))
-	height := int(avutil.GetFrameHeight(frame.ptr))
-	dataPtr := avutil.GetFrameDataPlane(frame.ptr, 0)
-	linesize := avutil.GetFrameLinesizePlane(frame.ptr, 0)
-
-	if dataPtr == nil || linesize == 0 {
+func TestEncoderSampleAspectRatio(t *testing.T) {
+	if !requireFFmpeg(t) {
 		return
 	}
-
-	// Create a byte slice from the data pointer
-	// RGB24: 3 bytes per pixel, linesize bytes per row
-	totalSize := int(linesize) * height
-	data := unsafe.Slice((*byte)(dataPtr), totalSize)
-
-	// RGB24: 3 bytes per pixel
-	for y := 0; y < height; y++ {
-		rowOffset := y * int(linesize)
-		for x := 0; x < width; x++ {
-			pixelOffset := rowOffset + x*3
-			if pixelOffset+2 < totalSize {
-				data[pixelOffset] = r
-				data[pixelOffset+1] = g
-				data[pixelOffset+2] = b
-			}
+	out := filepath.Join(t.TempDir(), "anamorphic.mkv")
+	enc, err := NewEncoderWithOptions(out, &EncoderOptions{
+		Video: &VideoEncoderConfig{
+			Codec:             CodecIDMJPEG,
+			Width:             720,
+			Height:            480,
+			PixelFormat:       PixelFormatYUVJ420P,
+			FrameRate:         NewRational(25, 1),
+			SampleAspectRatio: NewRational(8, 9),
+		},
+	})
+	if err != nil {
+		t.Fatalf("NewEncoderWithOptions failed: %v", err)
+	}
+	frame, err := newVideoFrame(720, 480, PixelFormatYUVJ420P)
+	if err != nil {
+		enc.Close()
+		t.Fatalf("newVideoFrame failed: %v", err)
+	}
+	defer func() { _ = FrameFree(&frame) }()
+	for i := 0; i < 3; i++ {
+		if err := enc.WriteFrame(frame); err != nil {
+			enc.Close()
+			t.Fatalf("WriteFrame failed: %v", err)
 		}
 	}
+	if err := enc.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+
+	dec, err := NewDecoder(out)
+	if err != nil {
+		t.Fatalf("NewDecoder failed: %v", err)
+	}
+	defer dec.Close()
+	video := dec.VideoStream()
+	if got := video.SampleAspectRatio; got != NewRational(8, 9) {
+		t.Errorf("SampleAspectRatio = %d:%d, want 8:9", got.Num, got.Den)
+	}
+	if got := video.DisplayAspectRatio(); got != NewRational(4, 3) {
+		t.Errorf("DisplayAspectRatio = %d:%d, want 4:3", got.Num, got.Den)
+	}
+	decoded, err := dec.DecodeVideo()
+	if err != nil || decoded.IsNil() {
+		t.Fatalf("DecodeVideo failed: %v", err)
+	}
+	if got := decoded.SampleAspectRatio(); got != NewRational(8, 9) {
+		t.Errorf("frame SampleAspectRatio = %d:%d, want 8:9", got.Num, got.Den)
+	}
 }
 
-func TestDiagnose(t *testing.T) {
+func TestDecoderVideoFrames(t *testing.T) {
 	if !requireFFmpeg(t) {
 		return
 	}
-	info := Diagnose()
-
-	// Platform should be set
-	if info.GOOS == "" {
-		t.Error("GOOS should not be empty")
-	}
-	if info.GOARCH == "" {
-		t.Error("GOARCH should not be empty")
+	testFile := createTestVideo(t)
+	if testFile == "" {
+		return
 	}
 
-	// FFmpeg should be loaded
-	if !info.FFmpegLoaded {
-		t.Error("FFmpegLoaded should be true")
+	decoder, err := NewDecoder(testFile)
+	if err != nil {
+		t.Fatalf("NewDecoder failed: %v", err)
 	}
-	if info.AVUtilVersion == 0 {
-		t.Error("AVUtilVersion should not be 0")
+	defer decoder.Close()
+
+	frames, errc := decoder.VideoFrames(context.Background())
+	var got []Frame
+	for f := range frames {
+		got = append(got, f)
 	}
-	if info.AVCodecVersion == 0 {
-		t.Error("AVCodecVersion should not be 0")
+	if err := <-errc; err != nil {
+		t.Fatalf("VideoFrames failed: %v", err)
 	}
-	if info.AVFormatVersion == 0 {
-		t.Error("AVFormatVersion should not be 0")
+	if len(got) == 0 {
+		t.Fatal("no frames received")
 	}
-
-	// Test String() method
-	str := info.String()
-	if str == "" {
-		t.Error("String() should not return empty")
+	// Frames are clones, so earlier ones stay valid.
+	if info := GetFrameInfo(got[0]); info.Width != decoder.VideoStream().Width {
+		t.Errorf("first frame width = %d, want %d", info.Width, decoder.VideoStream().Width)
 	}
-	t.Log(str)
-
-	// Shim status should be populated
-	if info.ShimLoaded {
-		if info.ShimPath == "" {
-			t.Error("ShimPath should be set when ShimLoaded is true")
-		}
-		t.Logf("Shim loaded from: %s", info.ShimPath)
-	} else {
-		t.Logf("Shim not loaded: %s", info.ShimError)
+	for i := range got {
+		_ = FrameFree(&got[i])
 	}
 }
 
-func TestShimStatus(t *testing.T) {
+func TestDecoderVideoFramesCancel(t *testing.T) {
 	if !requireFFmpeg(t) {
 		return
 	}
-	status := ShimStatus()
-	if status == "" {
-		t.Error("ShimStatus should not return empty")
+	testFile := createTestVideo(t)
+	if testFile == "" {
+		return
 	}
-	t.Logf("Shim status: %s", status)
-}
 
-func TestShimBuildInstructions(t *testing.T) {
-	if !requireFFmpeg(t) {
-		return
+	decoder, err := NewDecoder(testFile)
+	if err != nil {
+		t.Fatalf("NewDecoder failed: %v", err)
 	}
-	instructions := ShimBuildInstructions()
-	if instructions == "" {
-		t.Error("ShimBuildInstructions should not return empty")
+	defer decoder.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	frames, errc := decoder.VideoFrames(ctx)
+	f, ok := <-frames
+	if !ok {
+		t.Fatal("no frame received")
 	}
-	// Verify it contains some expected content
-	if len(instructions) < 50 {
-		t.Error("ShimBuildInstructions seems too short")
+	_ = FrameFree(&f)
+	cancel()
+
+	// Drain anything sent before the cancellation was observed.
+	for f := range frames {
+		_ = FrameFree(&f)
+	}
+	if err := <-errc; !errors.Is(err, context.Canceled) {
+		t.Fatalf("err = %v, want context.Canceled", err)
 	}
-	t.Logf("Build instructions length: %d chars", len(instructions))
 }
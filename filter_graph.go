@@ -21,8 +21,14 @@ type FilterGraph struct {
 	bufferSink avfilter.Context
 	outFrame   unsafe.Pointer // reusable output frame
 	isVideo    bool
+	flushed    bool // EOF has been sent to buffersrc
 	closed     bool
 
+	// Multi-input graphs (NewFilterGraphMulti) have one buffersrc per named
+	// input instead of bufferSrc.
+	inputs   map[string]avfilter.Context
+	inputEOF map[string]bool
+
 	// Input format (for reference)
 	srcWidth  int
 	srcHeight int
@@ -47,6 +53,10 @@ type FilterGraphConfig struct {
 
 	// Filter string (e.g., "scale=320:240,transpose=1")
 	Filters string
+
+	// hwFramesCtx, when set, makes the buffersrc accept hardware frames
+	// from this frames context (used by HWScaler).
+	hwFramesCtx avutil.HWFramesContext
 }
 
 // ErrFilterGraphClosed is returned when operating on a closed filter graph.
@@ -144,16 +154,18 @@ func (g *FilterGraph) setupVideoFilters(cfg FilterGraphConfig) error {
 		return errors.New("ffgo: buffer filter not found")
 	}
 
-	srcArgs := fmt.Sprintf("video_size=%dx%d:pix_fmt=%d:time_base=%d/%d:pixel_aspect=%d/%d",
-		cfg.Width, cfg.Height, int(cfg.PixelFmt),
-		timeBase.Num, timeBase.Den,
-		sar.Num, sar.Den)
+	srcArgs := videoBufferArgs(cfg.Width, cfg.Height, cfg.PixelFmt, timeBase, sar)
 
 	var err error
 	g.bufferSrc, err = avfilter.GraphCreateFilter(g.graph, bufferSrc, "in", srcArgs)
 	if err != nil {
 		return fmt.Errorf("ffgo: failed to create buffersrc: %w", err)
 	}
+	if cfg.hwFramesCtx != nil {
+		if err := avfilter.BufferSrcSetHWFramesCtx(g.bufferSrc, cfg.hwFramesCtx); err != nil {
+			return fmt.Errorf("ffgo: failed to set buffersrc hardware frames context: %w", err)
+		}
+	}
 
 	// Create buffersink
 	bufferSink := avfilter.GetByName("buffersink")
@@ -188,6 +200,14 @@ func (g *FilterGraph) setupVideoFilters(cfg FilterGraphConfig) error {
 	return nil
 }
 
+// videoBufferArgs builds the argument string for a video buffersrc.
+func videoBufferArgs(width, height int, pixFmt PixelFormat, timeBase, sar Rational) string {
+	return fmt.Sprintf("video_size=%dx%d:pix_fmt=%d:time_base=%d/%d:pixel_aspect=%d/%d",
+		width, height, int(pixFmt),
+		timeBase.Num, timeBase.Den,
+		sar.Num, sar.Den)
+}
+
 // linkFilterChain parses a filter string and creates/links filters manually.
 // Filter string format: "filter1=args1,filter2=args2,..."
 func (g *FilterGraph) linkFilterChain(filters string) error {
@@ -422,81 +442,91 @@ func getSampleFormatName(fmt SampleFormat) string {
 	}
 }
 
-// Filter processes a frame through the filter graph and returns filtered frames.
-// The input frame is not modified. Multiple output frames may be returned for
-// filters that change frame timing (e.g., framerate conversion).
+// Filter pushes a frame into the filter graph and returns every output frame
+// that is available afterwards. The input frame is not modified.
+//
+// A filter may emit any number of frames per input: zero while it buffers
+// (e.g. fps dropping a frame, or a filter needing look-ahead), one for most
+// filters, or several (e.g. fps=60 on 30fps input). Callers should always
+// loop over the result. Output timestamps are in the buffersink time base,
+// which for most filters equals the input time base.
+//
+// Passing nil signals end of stream: the graph is flushed and the remaining
+// buffered frames are returned. After a flush, further nil calls return no
+// frames and non-nil frames are rejected.
+//
+// Returned frames are owned by the caller and must be freed.
 //
 // Example:
 //
-//	filtered, err := graph.Filter(inputFrame)
+//	filtered, err := graph.Filter(&inputFrame)
 //	if err != nil {
 //	    return err
 //	}
-//	for _, frame := range filtered {
-//	    // Process filtered frame
+//	for i := range filtered {
+//	    // Process filtered[i]
+//	    filtered[i].Free()
 //	}
-func (g *FilterGraph) Filter(frame *Frame) ([]*Frame, error) {
+func (g *FilterGraph) Filter(frame *Frame) ([]Frame, error) {
 	if g.closed {
 		return nil, ErrFilterGraphClosed
 	}
 
-	// Push frame to buffersrc
-	var framePtr unsafe.Pointer
-	if frame != nil {
-		framePtr = frame.ptr
+	if g.inputs != nil {
+		return nil, errors.New("ffgo: multi-input filter graph; use FilterFrames")
 	}
-
-	if err := avfilter.BufferSrcAddFrameFlags(g.bufferSrc, framePtr, avfilter.AV_BUFFERSRC_FLAG_KEEP_REF); err != nil {
-		return nil, fmt.Errorf("ffgo: failed to push frame to filter: %w", err)
+	if frame == nil || frame.ptr == nil {
+		return g.Flush()
 	}
-	if frame != nil {
-		runtime.KeepAlive(frame)
+	if g.flushed {
+		return nil, errors.New("ffgo: filter graph has been flushed")
 	}
 
-	// Pull frames from buffersink
-	var frames []*Frame
-	for {
-		avutil.FrameUnref(g.outFrame)
-		ret := avfilter.BufferSinkGetFrame(g.bufferSink, g.outFrame)
-		if ret == avutil.AVERROR_EAGAIN || ret == avutil.AVERROR_EOF {
-			break
-		}
-		if ret < 0 {
-			return frames, fmt.Errorf("ffgo: failed to get frame from filter: %d", ret)
-		}
-
-		// Clone the frame (avutil.FrameClone allocates a new frame and copies refs)
-		newFrame := avutil.FrameAlloc()
-		if newFrame == nil {
-			return frames, errors.New("ffgo: failed to allocate output frame")
-		}
-		if err := avutil.FrameRef(newFrame, g.outFrame); err != nil {
-			avutil.FrameFree(&newFrame)
-			return frames, fmt.Errorf("ffgo: failed to reference frame: %w", err)
-		}
-		// Allocate a Frame slot to hold the pointer (since we return []*Frame)
-		framePtr := new(Frame)
-		*framePtr = Frame{ptr: newFrame, owned: true}
-		frames = append(frames, framePtr)
+	// Push frame to buffersrc
+	if err := avfilter.BufferSrcAddFrameFlags(g.bufferSrc, frame.ptr, avfilter.AV_BUFFERSRC_FLAG_KEEP_REF); err != nil {
+		return nil, fmt.Errorf("ffgo: failed to push frame to filter: %w", err)
 	}
+	runtime.KeepAlive(frame)
 
-	return frames, nil
+	return g.drain()
 }
 
-// Flush drains any remaining frames from the filter graph.
-// Call this after sending all input frames to get any buffered output.
-func (g *FilterGraph) Flush() ([]*Frame, error) {
+// Flush signals end of stream and returns the frames still buffered in the
+// graph. It is equivalent to Filter(nil).
+func (g *FilterGraph) Flush() ([]Frame, error) {
 	if g.closed {
 		return nil, ErrFilterGraphClosed
 	}
+	if g.flushed {
+		return nil, nil
+	}
+	g.flushed = true
+
+	if g.inputs != nil {
+		for name, src := range g.inputs {
+			if g.inputEOF[name] {
+				continue
+			}
+			g.inputEOF[name] = true
+			if err := avfilter.BufferSrcAddFrameFlags(src, nil, 0); err != nil {
+				return nil, fmt.Errorf("ffgo: failed to flush filter input %q: %w", name, err)
+			}
+		}
+		return g.drain()
+	}
 
 	// Send NULL frame to signal EOF
 	if err := avfilter.BufferSrcAddFrameFlags(g.bufferSrc, nil, 0); err != nil {
 		return nil, fmt.Errorf("ffgo: failed to flush filter: %w", err)
 	}
 
-	// Collect remaining frames
-	var frames []*Frame
+	return g.drain()
+}
+
+// drain pulls frames from the buffersink until it reports EAGAIN (needs more
+// input) or EOF (fully flushed). On error, frames pulled so far are returned.
+func (g *FilterGraph) drain() ([]Frame, error) {
+	var frames []Frame
 	for {
 		avutil.FrameUnref(g.outFrame)
 		ret := avfilter.BufferSinkGetFrame(g.bufferSink, g.outFrame)
@@ -507,6 +537,7 @@ func (g *FilterGraph) Flush() ([]*Frame, error) {
 			return frames, fmt.Errorf("ffgo: failed to get frame from filter: %d", ret)
 		}
 
+		// Move the reference into a new caller-owned frame
 		newFrame := avutil.FrameAlloc()
 		if newFrame == nil {
 			return frames, errors.New("ffgo: failed to allocate output frame")
@@ -515,15 +546,38 @@ func (g *FilterGraph) Flush() ([]*Frame, error) {
 			avutil.FrameFree(&newFrame)
 			return frames, fmt.Errorf("ffgo: failed to reference frame: %w", err)
 		}
-		// Allocate a Frame slot to hold the pointer (since we return []*Frame)
-		framePtr := new(Frame)
-		*framePtr = Frame{ptr: newFrame, owned: true}
-		frames = append(frames, framePtr)
+		frames = append(frames, Frame{ptr: newFrame, owned: true})
 	}
 
 	return frames, nil
 }
 
+// SendCommand changes a filter parameter on a running graph without
+// rebuilding it, e.g. SendCommand("eq", "brightness", "0.2") or
+// SendCommand("volume", "volume", "0.5"). target is a filter type name
+// (matching every instance of that filter), a filter instance name, or "all".
+//
+// Only parameters the filter marks as runtime-settable accept commands; the
+// change applies to frames filtered after the call. An error is returned if
+// no matching filter supports the command.
+func (g *FilterGraph) SendCommand(target, cmd, arg string) error {
+	if g.closed {
+		return ErrFilterGraphClosed
+	}
+	if target == "" || cmd == "" {
+		return errors.New("ffgo: filter command target and name are required")
+	}
+
+	res, err := avfilter.GraphSendCommand(g.graph, target, cmd, arg, 0)
+	if err != nil {
+		if res != "" {
+			return fmt.Errorf("ffgo: filter command %s %s=%q failed (%s): %w", target, cmd, arg, res, err)
+		}
+		return fmt.Errorf("ffgo: filter command %s %s=%q failed: %w", target, cmd, arg, err)
+	}
+	return nil
+}
+
 // Close releases all resources associated with the filter graph.
 func (g *FilterGraph) Close() error {
 	if g.closed {
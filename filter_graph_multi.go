@@ -0,0 +1,244 @@
+//go:build !ios && !android && (amd64 || arm64)
+
+package ffgo
+
+import (
+	"errors"
+	"fmt"
+	"runtime"
+
+	"github.com/obinnaokechukwu/ffgo/avfilter"
+	"github.com/obinnaokechukwu/ffgo/avutil"
+)
+
+// FilterGraphInput describes one named video input of a multi-input filter graph.
+type FilterGraphInput struct {
+	// Name is the link label used for this input in the filter string,
+	// e.g. "main" for "[main][logo]overlay".
+	Name string
+
+	Width    int
+	Height   int
+	PixelFmt PixelFormat
+	TimeBase Rational // defaults to 1/90000
+	SAR      Rational // sample aspect ratio, defaults to 1/1
+}
+
+// MultiInputFilterGraphConfig configures a filter graph fed by several inputs.
+type MultiInputFilterGraphConfig struct {
+	Inputs []FilterGraphInput
+
+	// Filters is an FFmpeg filtergraph description whose unconnected input
+	// pads are labeled with the input names and which has exactly one
+	// unconnected output, e.g. "[main][logo]overlay=W-w-10:H-h-10" or
+	// "[left][right]hstack".
+	Filters string
+}
+
+// NewFilterGraphMulti creates a video filter graph with several named inputs,
+// for filters such as overlay, hstack, vstack, blend and concat that combine
+// streams. Feed it with FilterFrames.
+//
+// Multi-input filters synchronize their inputs by timestamp, so every input
+// frame must carry a PTS in its input's TimeBase, increasing monotonically
+// per input and on a common timeline across inputs. overlay, for example,
+// emits a main frame only once it has a secondary frame at or after the
+// main frame's PTS (or the secondary input has ended), so a still watermark
+// should be sent once with PTS 0 and its input then ended; overlay keeps
+// showing the last frame by default (eof_action=repeat).
+//
+// Example:
+//
+//	graph, err := ffgo.NewFilterGraphMulti(ffgo.MultiInputFilterGraphConfig{
+//	    Inputs: []ffgo.FilterGraphInput{
+//	        {Name: "main", Width: 1920, Height: 1080, PixelFmt: ffgo.PixelFormatYUV420P, TimeBase: ffgo.NewRational(1, 30)},
+//	        {Name: "logo", Width: 200, Height: 100, PixelFmt: ffgo.PixelFormatRGBA, TimeBase: ffgo.NewRational(1, 30)},
+//	    },
+//	    Filters: "[main][logo]overlay=W-w-10:10",
+//	})
+func NewFilterGraphMulti(cfg MultiInputFilterGraphConfig) (*FilterGraph, error) {
+	if len(cfg.Inputs) == 0 {
+		return nil, errors.New("ffgo: multi-input filter graph needs at least one input")
+	}
+	if cfg.Filters == "" {
+		return nil, errors.New("ffgo: filter string is required")
+	}
+	seen := make(map[string]bool, len(cfg.Inputs))
+	for _, in := range cfg.Inputs {
+		if in.Name == "" {
+			return nil, errors.New("ffgo: filter graph inputs must be named")
+		}
+		if seen[in.Name] {
+			return nil, fmt.Errorf("ffgo: duplicate filter graph input %q", in.Name)
+		}
+		seen[in.Name] = true
+		if in.Width <= 0 || in.Height <= 0 {
+			return nil, fmt.Errorf("ffgo: filter graph input %q needs width and height", in.Name)
+		}
+	}
+
+	if err := avfilter.Init(); err != nil {
+		return nil, fmt.Errorf("ffgo: failed to initialize avfilter: %w", err)
+	}
+
+	g := &FilterGraph{
+		isVideo:  true,
+		inputs:   make(map[string]avfilter.Context, len(cfg.Inputs)),
+		inputEOF: make(map[string]bool, len(cfg.Inputs)),
+	}
+
+	g.graph = avfilter.GraphAlloc()
+	if g.graph == nil {
+		return nil, errors.New("ffgo: failed to allocate filter graph")
+	}
+
+	if err := g.setupMultiInputFilters(cfg); err != nil {
+		avfilter.GraphFree(&g.graph)
+		return nil, err
+	}
+
+	g.outFrame = avutil.FrameAlloc()
+	if g.outFrame == nil {
+		avfilter.GraphFree(&g.graph)
+		return nil, errors.New("ffgo: failed to allocate output frame")
+	}
+
+	runtime.SetFinalizer(g, (*FilterGraph).cleanup)
+	return g, nil
+}
+
+func (g *FilterGraph) setupMultiInputFilters(cfg MultiInputFilterGraphConfig) error {
+	bufferSrc := avfilter.GetByName("buffer")
+	if bufferSrc == nil {
+		return errors.New("ffgo: buffer filter not found")
+	}
+	for _, in := range cfg.Inputs {
+		timeBase := in.TimeBase
+		if timeBase.Num == 0 {
+			timeBase = Rational{Num: 1, Den: 90000}
+		}
+		sar := in.SAR
+		if sar.Num == 0 {
+			sar = Rational{Num: 1, Den: 1}
+		}
+		ctx, err := avfilter.GraphCreateFilter(g.graph, bufferSrc, "in_"+in.Name,
+			videoBufferArgs(in.Width, in.Height, in.PixelFmt, timeBase, sar))
+		if err != nil {
+			return fmt.Errorf("ffgo: failed to create buffersrc for input %q: %w", in.Name, err)
+		}
+		g.inputs[in.Name] = ctx
+	}
+
+	bufferSink := avfilter.GetByName("buffersink")
+	if bufferSink == nil {
+		return errors.New("ffgo: buffersink filter not found")
+	}
+	var err error
+	g.bufferSink, err = avfilter.GraphCreateFilter(g.graph, bufferSink, "out", "")
+	if err != nil {
+		return fmt.Errorf("ffgo: failed to create buffersink: %w", err)
+	}
+
+	inputs, outputs, err := avfilter.GraphParse2(g.graph, cfg.Filters)
+	if err != nil {
+		return fmt.Errorf("ffgo: failed to parse filter graph: %w", err)
+	}
+	defer avfilter.InOutFree(&inputs)
+	defer avfilter.InOutFree(&outputs)
+
+	// Connect each open input pad to the buffersrc with the same label.
+	linked := make(map[string]bool, len(g.inputs))
+	for cur := inputs; cur != nil; cur = avfilter.InOutGetNext(cur) {
+		name := avfilter.InOutGetName(cur)
+		src, ok := g.inputs[name]
+		if !ok {
+			if name == "" {
+				return errors.New("ffgo: filter graph has an unlabeled input")
+			}
+			return fmt.Errorf("ffgo: filter graph input label %q does not match any input", name)
+		}
+		if linked[name] {
+			return fmt.Errorf("ffgo: filter graph input %q is used more than once", name)
+		}
+		if err := avfilter.Link(src, 0, avfilter.InOutGetFilterCtx(cur), uint32(avfilter.InOutGetPadIdx(cur))); err != nil {
+			return fmt.Errorf("ffgo: failed to link input %q: %w", name, err)
+		}
+		linked[name] = true
+	}
+	for name := range g.inputs {
+		if !linked[name] {
+			return fmt.Errorf("ffgo: input %q is not used by the filter graph", name)
+		}
+	}
+
+	// Connect the single open output pad to the buffersink.
+	if outputs == nil || avfilter.InOutGetNext(outputs) != nil {
+		return errors.New("ffgo: multi-input filter graph must have exactly one output")
+	}
+	if err := avfilter.Link(avfilter.InOutGetFilterCtx(outputs), uint32(avfilter.InOutGetPadIdx(outputs)), g.bufferSink, 0); err != nil {
+		return fmt.Errorf("ffgo: failed to link to buffersink: %w", err)
+	}
+
+	if err := avfilter.GraphConfig(g.graph); err != nil {
+		return fmt.Errorf("ffgo: failed to configure filter graph: %w", err)
+	}
+	return nil
+}
+
+// FilterFrames pushes one frame to each named input of a graph created with
+// NewFilterGraphMulti and returns every output frame available afterwards.
+// Inputs may be omitted from the map when they have no new frame. A nil
+// Frame (Frame{}) ends that input; call Flush to end all inputs.
+//
+// Input frames are not modified. Returned frames are owned by the caller and
+// must be freed.
+func (g *FilterGraph) FilterFrames(inputs map[string]Frame) ([]Frame, error) {
+	if g.closed {
+		return nil, ErrFilterGraphClosed
+	}
+	if g.inputs == nil {
+		return nil, errors.New("ffgo: FilterFrames requires a multi-input filter graph")
+	}
+
+	for name, frame := range inputs {
+		src, ok := g.inputs[name]
+		if !ok {
+			return nil, fmt.Errorf("ffgo: unknown filter graph input %q", name)
+		}
+		if g.inputEOF[name] {
+			if frame.IsNil() {
+				continue
+			}
+			return nil, fmt.Errorf("ffgo: filter graph input %q has ended", name)
+		}
+
+		if frame.IsNil() {
+			g.inputEOF[name] = true
+			if err := avfilter.BufferSrcAddFrameFlags(src, nil, 0); err != nil {
+				return nil, fmt.Errorf("ffgo: failed to end filter input %q: %w", name, err)
+			}
+			continue
+		}
+		if err := avfilter.BufferSrcAddFrameFlags(src, frame.ptr, avfilter.AV_BUFFERSRC_FLAG_KEEP_REF); err != nil {
+			return nil, fmt.Errorf("ffgo: failed to push frame to filter input %q: %w", name, err)
+		}
+		runtime.KeepAlive(frame)
+	}
+
+	frames, err := g.drain()
+	if err != nil {
+		return frames, err
+	}
+
+	allEnded := true
+	for name := range g.inputs {
+		if !g.inputEOF[name] {
+			allEnded = false
+			break
+		}
+	}
+	if allEnded {
+		g.flushed = true
+	}
+	return frames, nil
+}
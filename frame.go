@@ -3,9 +3,12 @@
 package ffgo
 
 import (
+	"errors"
 	"unsafe"
 
+	"github.com/obinnaokechukwu/ffgo/avformat"
 	"github.com/obinnaokechukwu/ffgo/avutil"
+	"github.com/obinnaokechukwu/ffgo/internal/shim"
 )
 
 // FrameWrapper provides a high-level interface to an FFmpeg AVFrame.
@@ -182,3 +185,50 @@ func (f *FrameWrapper) Free() error {
 	}
 	return f.frame.Free()
 }
+
+// SampleAspectRatio returns the frame's sample (pixel) aspect ratio, or 0/1
+// if unknown.
+func (f Frame) SampleAspectRatio() Rational {
+	num, den := avutil.GetFrameSampleAspectRatio(f.ptr)
+	return avutil.NewRational(num, den)
+}
+
+// SetSampleAspectRatio sets the frame's sample (pixel) aspect ratio.
+func (f Frame) SetSampleAspectRatio(sar Rational) {
+	avutil.SetFrameSampleAspectRatio(f.ptr, sar.Num, sar.Den)
+}
+
+// Metadata returns a copy of the frame's metadata, such as the lavfi.*
+// values analysis filters (ebur128, cropdetect, blackdetect, ...) attach to
+// their output frames. It returns nil if the frame has no metadata or the
+// shim, which is needed to locate the field, is not available.
+func (f Frame) Metadata() map[string]string {
+	if f.ptr == nil {
+		return nil
+	}
+	_ = shim.Load()
+	dict, err := shim.AVFrameMetadata(f.ptr)
+	if err != nil || dict == nil {
+		return nil
+	}
+	md := make(map[string]string)
+	var prev unsafe.Pointer
+	for {
+		entry := avformat.DictGet(avutil.Dictionary(dict), "", prev, avformat.AV_DICT_IGNORE_SUFFIX)
+		if entry == nil {
+			return md
+		}
+		md[avformat.DictEntryKey(entry)] = avformat.DictEntryValue(entry)
+		prev = entry
+	}
+}
+
+// errNoFrameMetadata is returned by analysis helpers that read filter
+// results from frame metadata when Frame.Metadata cannot work.
+var errNoFrameMetadata = errors.New("ffgo: reading filter results needs frame metadata, which requires the shim")
+
+// frameMetadataAvailable reports whether Frame.Metadata can read metadata.
+func frameMetadataAvailable() bool {
+	_ = shim.Load()
+	return shim.HasAVFrameMetadata()
+}
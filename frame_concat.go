@@ -0,0 +1,611 @@
+//go:build !ios && !android && (amd64 || arm64)
+
+package ffgo
+
+import (
+	"errors"
+	"math"
+	"time"
+
+	"github.com/obinnaokechukwu/ffgo/avutil"
+)
+
+// VideoFormat describes the picture format of decoded video frames.
+type VideoFormat struct {
+	Width       int
+	Height      int
+	PixelFormat PixelFormat // default: PixelFormatYUV420P
+	FrameRate   Rational    // output time base is 1/FrameRate (default: 30/1)
+}
+
+// FrameConcatenator joins clips at the frame level: each clip is decoded,
+// scaled and resampled to a common target format, and the frames are emitted
+// as one continuous stream with rebased timestamps.
+//
+// Unlike NewConcatDecoder, which concatenates packets with FFmpeg's concat
+// demuxer and therefore requires identical codec parameters, clips may
+// differ in codec, resolution, pixel format, sample rate and layout.
+//
+// Clips are joined with hard cuts unless SetCrossfade is used, in which case
+// each clip fades into the next over the crossfade duration.
+//
+// Example:
+//
+//	cat, err := ffgo.NewFrameConcatenator(
+//	    ffgo.VideoFormat{Width: 1280, Height: 720, FrameRate: ffgo.NewRational(30, 1)},
+//	    ffgo.AudioFormat{SampleRate: 48000, Channels: 2, SampleFormat: ffgo.SampleFormatFLTP},
+//	)
+//	if err != nil {
+//	    return err
+//	}
+//	defer cat.Close()
+//	cat.AddClip(intro)
+//	cat.AddClip(main)
+//
+//	for {
+//	    frame, err := cat.Next()
+//	    if err != nil || frame.IsNil() {
+//	        break
+//	    }
+//	    if cat.MediaType() == ffgo.MediaTypeVideo {
+//	        enc.WriteVideoFrame(frame)
+//	    } else {
+//	        enc.WriteAudioFrame(frame)
+//	    }
+//	}
+type FrameConcatenator struct {
+	video    VideoFormat
+	audio    AudioFormat
+	hasAudio bool
+
+	clips []*Decoder
+	cur   int
+
+	// Per-clip converters, created from the first frame of each clip
+	scaler    *Scaler
+	resampler *Resampler
+	out       Frame // last owned frame returned, released on the next call
+
+	mediaType MediaType // type of the frame last returned by Next
+	started   bool
+
+	// Crossfade state. With a crossfade, converted frames are held back until
+	// they are older than the crossfade duration, so the end of a clip is
+	// still available to blend with the start of the next one. Held frames
+	// are owned and carry output PTS; audio is held as interleaved float64
+	// samples starting at heldPTS.
+	crossfade  time.Duration
+	held       []Frame
+	heldAudio  []float64
+	heldPTS    int64
+	tail       []Frame   // previous clip's held video, blended into this clip
+	tailAudio  []float64 // previous clip's held audio, mixed into this clip
+	mixed      int       // samples of this clip mixed with tailAudio so far
+	fadeStart  int64     // output video PTS at which this clip fades in
+	fadeFrames int64     // crossfade length in video frames
+	fadeLen    int       // crossfade length in audio samples
+	ready      []readyFrame
+
+	// Timestamp rebasing. Video PTS are in 1/FrameRate, audio PTS in samples.
+	clipVideoStart int64 // source PTS of the clip's first video frame
+	videoOffset    int64 // output PTS at which the current clip starts
+	nextVideoPTS   int64
+	nextAudioPTS   int64
+
+	closed bool
+}
+
+// NewFrameConcatenator creates a concatenator that converts every clip to
+// target. Audio is converted to audioTarget; pass a zero AudioFormat to drop
+// audio and emit video frames only.
+func NewFrameConcatenator(target VideoFormat, audioTarget AudioFormat) (*FrameConcatenator, error) {
+	if target.Width <= 0 || target.Height <= 0 {
+		return nil, errors.New("ffgo: width and height must be positive")
+	}
+	if target.PixelFormat == PixelFormatNone {
+		target.PixelFormat = PixelFormatYUV420P
+	}
+	if target.FrameRate.Num <= 0 || target.FrameRate.Den <= 0 {
+		target.FrameRate = Rational{Num: 30, Den: 1}
+	}
+
+	c := &FrameConcatenator{video: target, clipVideoStart: avutil.AV_NOPTS_VALUE}
+	if audioTarget != (AudioFormat{}) {
+		if audioTarget.SampleRate <= 0 || audioTarget.Channels <= 0 {
+			return nil, errors.New("ffgo: audio target needs a sample rate and channel count")
+		}
+		c.audio = audioTarget
+		c.hasAudio = true
+	}
+	return c, nil
+}
+
+// AddClip appends a clip. Clips play in the order they are added. The
+// concatenator reads from d but does not close it; keep it open until Next
+// has moved past it.
+func (c *FrameConcatenator) AddClip(d *Decoder) error {
+	if c.closed {
+		return errors.New("ffgo: concatenator is closed")
+	}
+	if d == nil {
+		return errors.New("ffgo: decoder cannot be nil")
+	}
+	if !d.HasVideo() {
+		return errors.New("ffgo: clip has no video stream")
+	}
+	c.clips = append(c.clips, d)
+	return nil
+}
+
+// VideoTimeBase returns the time base of emitted video frame PTS (1/FrameRate).
+func (c *FrameConcatenator) VideoTimeBase() Rational {
+	return Rational{Num: c.video.FrameRate.Den, Den: c.video.FrameRate.Num}
+}
+
+// AudioTimeBase returns the time base of emitted audio frame PTS
+// (1/SampleRate of the audio target).
+func (c *FrameConcatenator) AudioTimeBase() Rational {
+	return Rational{Num: 1, Den: int32(c.audio.SampleRate)}
+}
+
+// readyFrame is a converted frame waiting to be returned by Next.
+type readyFrame struct {
+	frame     Frame
+	mediaType MediaType
+}
+
+// SetCrossfade makes each clip fade into the next over d instead of cutting:
+// the last d of a clip's video is blended with the first d of the next
+// clip's video, and its audio is mixed with the next clip's audio, so each
+// join shortens the output by d. A clip shorter than d shortens the
+// crossfade at its joins. Zero restores hard cuts.
+//
+// It must be called before the first call to Next. Blending is done on the
+// target formats, so the target pixel format needs 8-bit components or
+// little-endian components of up to 16 bits, as the usual YUV, RGB and GBR
+// formats have.
+func (c *FrameConcatenator) SetCrossfade(d time.Duration) error {
+	if c.closed {
+		return errors.New("ffgo: concatenator is closed")
+	}
+	if c.started {
+		return errors.New("ffgo: crossfade must be set before the first call to Next")
+	}
+	if d < 0 {
+		return errors.New("ffgo: crossfade duration must not be negative")
+	}
+	if d > 0 {
+		if err := checkBlendable(c.video.PixelFormat); err != nil {
+			return err
+		}
+		if c.hasAudio {
+			if err := checkMixable(c.audio.SampleFormat); err != nil {
+				return err
+			}
+		}
+	}
+	c.crossfade = d
+	return nil
+}
+
+// Next returns the next converted frame, video or audio, in decode order;
+// MediaType reports which. It returns a nil Frame (IsNil reports true) and
+// a nil error after the last clip is exhausted.
+//
+// The returned frame is owned by the concatenator and is only valid until
+// the next call to Next or Close; clone it to keep it longer.
+//
+// Each clip starts where the previous one ended (the later of its video and
+// audio end), so audio and video stay aligned across clip boundaries. Audio
+// timestamps are contiguous within a clip; gaps are not filled with silence.
+// With a crossfade, frames are returned up to the crossfade duration after
+// they are decoded.
+func (c *FrameConcatenator) Next() (Frame, error) {
+	if c.closed {
+		return Frame{}, errors.New("ffgo: concatenator is closed")
+	}
+	c.releaseOut()
+	c.mediaType = MediaTypeUnknown
+	c.started = true
+
+	for {
+		if len(c.ready) > 0 {
+			r := c.ready[0]
+			c.ready = c.ready[1:]
+			c.out = r.frame
+			c.mediaType = r.mediaType
+			return r.frame, nil
+		}
+		if c.cur >= len(c.clips) {
+			return Frame{}, nil
+		}
+
+		d := c.clips[c.cur]
+		fw, err := d.ReadFrame()
+		if err != nil {
+			if !IsEOF(err) {
+				return Frame{}, err
+			}
+			fw = nil
+		}
+
+		if fw == nil {
+			// Clip finished: drain buffered audio, then move to the next one.
+			if c.resampler != nil {
+				frame, err := c.resampler.Flush()
+				_ = c.resampler.Close()
+				c.resampler = nil
+				if err != nil {
+					return Frame{}, err
+				}
+				if !frame.IsNil() && avutil.GetFrameNbSamples(frame.ptr) > 0 {
+					out, err := c.emitAudio(frame)
+					if err != nil || !out.IsNil() {
+						return out, err
+					}
+				} else {
+					_ = FrameFree(&frame)
+				}
+			}
+			if err := c.nextClip(); err != nil {
+				return Frame{}, err
+			}
+			continue
+		}
+
+		switch fw.MediaType() {
+		case MediaTypeVideo:
+			frame, err := c.convertVideo(d, fw.Raw())
+			if err != nil || c.crossfade <= 0 {
+				return frame, err
+			}
+			c.mediaType = MediaTypeUnknown
+			if err := c.holdVideo(frame); err != nil {
+				return Frame{}, err
+			}
+		case MediaTypeAudio:
+			if !c.hasAudio {
+				continue
+			}
+			out, err := c.convertAudio(fw)
+			if err != nil {
+				return Frame{}, err
+			}
+			if !out.IsNil() {
+				return out, nil
+			}
+		}
+	}
+}
+
+// MediaType returns the media type of the frame last returned by Next, or
+// MediaTypeUnknown if it returned none.
+func (c *FrameConcatenator) MediaType() MediaType {
+	return c.mediaType
+}
+
+// convertVideo scales frame to the target format and rebases its PTS.
+func (c *FrameConcatenator) convertVideo(d *Decoder, frame Frame) (Frame, error) {
+	w := int(avutil.GetFrameWidth(frame.ptr))
+	h := int(avutil.GetFrameHeight(frame.ptr))
+	pixFmt := PixelFormat(avutil.GetFrameFormat(frame.ptr))
+
+	if c.scaler == nil || c.scaler.SrcWidth() != w || c.scaler.SrcHeight() != h || c.scaler.SrcFormat() != pixFmt {
+		if c.scaler != nil {
+			_ = c.scaler.Close()
+		}
+		scaler, err := NewScaler(w, h, pixFmt, c.video.Width, c.video.Height, c.video.PixelFormat, ScaleBilinear)
+		if err != nil {
+			c.scaler = nil
+			return Frame{}, err
+		}
+		c.scaler = scaler
+	}
+
+	out, err := c.scaler.Scale(frame)
+	if err != nil {
+		return Frame{}, err
+	}
+
+	outTB := c.VideoTimeBase()
+	pts := avutil.GetFramePTS(frame.ptr)
+	outPTS := c.nextVideoPTS
+	if pts != avutil.AV_NOPTS_VALUE {
+		if c.clipVideoStart == avutil.AV_NOPTS_VALUE {
+			c.clipVideoStart = pts
+		}
+		var srcTB Rational
+		if vs := d.VideoStream(); vs != nil {
+			srcTB = vs.TimeBase
+		}
+		if srcTB.Num > 0 && srcTB.Den > 0 {
+			rel := ptsToDuration(pts-c.clipVideoStart, srcTB)
+			outPTS = c.videoOffset + durationToPTS(rel, outTB)
+		}
+	}
+	// Keep PTS strictly increasing across reordering glitches and clip joins.
+	if outPTS < c.nextVideoPTS {
+		outPTS = c.nextVideoPTS
+	}
+	c.nextVideoPTS = outPTS + 1
+
+	avutil.SetFramePTS(out.ptr, outPTS)
+	c.mediaType = MediaTypeVideo
+	return out, nil
+}
+
+// convertAudio resamples an audio frame to the target format. It returns a
+// nil Frame if the resampler buffered all input or the output is held for
+// a crossfade.
+func (c *FrameConcatenator) convertAudio(fw *FrameWrapper) (Frame, error) {
+	if c.resampler == nil {
+		src := AudioFormat{
+			SampleRate:   fw.SampleRate(),
+			Channels:     int(avutil.GetFrameChannels(fw.Raw().ptr)),
+			SampleFormat: fw.SampleFormat(),
+		}
+		resampler, err := NewResampler(src, c.audio)
+		if err != nil {
+			return Frame{}, err
+		}
+		c.resampler = resampler
+	}
+
+	frame, err := c.resampler.Resample(fw.Raw())
+	if err != nil {
+		return Frame{}, err
+	}
+	if frame.IsNil() || avutil.GetFrameNbSamples(frame.ptr) <= 0 {
+		_ = FrameFree(&frame)
+		return Frame{}, nil
+	}
+	return c.emitAudio(frame)
+}
+
+// emitAudio stamps an owned resampled frame with the next audio PTS and
+// keeps it until the next call. With a crossfade the frame is held instead
+// and a nil Frame is returned.
+func (c *FrameConcatenator) emitAudio(frame Frame) (Frame, error) {
+	if c.crossfade > 0 {
+		return Frame{}, c.holdAudio(frame)
+	}
+	avutil.SetFramePTS(frame.ptr, c.nextAudioPTS)
+	c.nextAudioPTS += int64(avutil.GetFrameNbSamples(frame.ptr))
+	c.out = frame
+	c.mediaType = MediaTypeAudio
+	return frame, nil
+}
+
+// holdVideo keeps a copy of a converted video frame, blended with the
+// previous clip's tail frame at the same PTS, and queues the held frames
+// that are older than the crossfade.
+func (c *FrameConcatenator) holdVideo(frame Frame) error {
+	held, err := FrameClone(frame)
+	if err != nil {
+		return err
+	}
+	pts := avutil.GetFramePTS(held.ptr)
+
+	// Tail frames this clip has no frame for pass through unblended.
+	for len(c.tail) > 0 && avutil.GetFramePTS(c.tail[0].ptr) < pts {
+		c.held = append(c.held, c.tail[0])
+		c.tail = c.tail[1:]
+	}
+	if len(c.tail) > 0 && avutil.GetFramePTS(c.tail[0].ptr) == pts {
+		// The clone shares the scaler's buffer until made writable.
+		if err := avutil.FrameMakeWritable(held.ptr); err != nil {
+			_ = FrameFree(&held)
+			return err
+		}
+		gain := math.Min(1, float64(pts-c.fadeStart+1)/float64(c.fadeFrames+1))
+		blendVideo(held, c.tail[0], c.video.PixelFormat, c.video.Height, gain)
+		_ = FrameFree(&c.tail[0])
+		c.tail = c.tail[1:]
+	}
+	c.held = append(c.held, held)
+
+	limit := int(math.Ceil(float64(c.crossfade) / float64(ptsToDuration(1, c.VideoTimeBase()))))
+	for len(c.held) > limit {
+		c.queue(c.held[0], MediaTypeVideo)
+		c.held = c.held[1:]
+	}
+	return nil
+}
+
+// holdAudio appends an owned resampled frame to the held audio, mixed with
+// the previous clip's tail, frees it, and queues the held samples that are
+// older than the crossfade.
+func (c *FrameConcatenator) holdAudio(frame Frame) error {
+	start := len(c.heldAudio)
+	c.heldAudio = appendSamples(c.heldAudio, frame, c.audio)
+	_ = FrameFree(&frame)
+	c.mixTail(c.heldAudio[start:])
+	c.nextAudioPTS += int64((len(c.heldAudio) - start) / c.audio.Channels)
+	return c.queueHeldAudio(int(durationToPTS(c.crossfade, c.AudioTimeBase())))
+}
+
+// mixTail fades the interleaved samples, which continue this clip's audio,
+// in over the previous clip's tail.
+func (c *FrameConcatenator) mixTail(samples []float64) {
+	ch := c.audio.Channels
+	for i := 0; i < len(samples)/ch && (c.mixed+1)*ch <= len(c.tailAudio); i++ {
+		gain := math.Min(1, (float64(c.mixed)+0.5)/float64(c.fadeLen))
+		for j := 0; j < ch; j++ {
+			samples[i*ch+j] = samples[i*ch+j]*gain + c.tailAudio[c.mixed*ch+j]*(1-gain)
+		}
+		c.mixed++
+	}
+}
+
+// queueHeldAudio queues all but the last keep held samples as one frame.
+func (c *FrameConcatenator) queueHeldAudio(keep int) error {
+	ch := c.audio.Channels
+	if ch <= 0 {
+		return nil
+	}
+	n := len(c.heldAudio)/ch - max(keep, 0)
+	if n <= 0 {
+		return nil
+	}
+	frame, err := newAudioFrame(c.audio, n)
+	if err != nil {
+		return err
+	}
+	writeSamples(frame, c.audio, c.heldAudio[:n*ch])
+	avutil.SetFramePTS(frame.ptr, c.heldPTS)
+	c.heldPTS += int64(n)
+	c.heldAudio = append(c.heldAudio[:0], c.heldAudio[n*ch:]...)
+	c.queue(frame, MediaTypeAudio)
+	return nil
+}
+
+// queue appends an owned frame to the frames Next returns.
+func (c *FrameConcatenator) queue(frame Frame, mediaType MediaType) {
+	c.ready = append(c.ready, readyFrame{frame: frame, mediaType: mediaType})
+}
+
+// nextClip moves to the next clip, starting it where the longer of the
+// previous clip's video and audio ended, or the crossfade duration before.
+func (c *FrameConcatenator) nextClip() error {
+	if c.crossfade > 0 {
+		c.endTail()
+	}
+
+	end := ptsToDuration(c.nextVideoPTS, c.VideoTimeBase())
+	if c.hasAudio {
+		if audioEnd := ptsToDuration(c.nextAudioPTS, c.AudioTimeBase()); audioEnd > end {
+			end = audioEnd
+		}
+	}
+
+	if c.crossfade > 0 {
+		if err := c.startCrossfade(end); err != nil {
+			return err
+		}
+	} else {
+		c.videoOffset = int64(math.Ceil(float64(end) / float64(ptsToDuration(1, c.VideoTimeBase()))))
+		if c.videoOffset < c.nextVideoPTS {
+			c.videoOffset = c.nextVideoPTS
+		}
+		c.nextVideoPTS = c.videoOffset
+		if c.hasAudio {
+			c.nextAudioPTS = durationToPTS(end, c.AudioTimeBase())
+		}
+	}
+
+	if c.scaler != nil {
+		_ = c.scaler.Close()
+		c.scaler = nil
+	}
+	c.clipVideoStart = avutil.AV_NOPTS_VALUE
+	c.cur++
+	return nil
+}
+
+// endTail holds what is left of the previous clip's tail once the clip
+// fading in over it has ended, so it plays out after that clip.
+func (c *FrameConcatenator) endTail() {
+	c.held = append(c.held, c.tail...)
+	c.tail = nil
+	if n := len(c.held); n > 0 {
+		c.nextVideoPTS = max(c.nextVideoPTS, avutil.GetFramePTS(c.held[n-1].ptr)+1)
+	}
+
+	if ch := c.audio.Channels; ch > 0 {
+		if rest := len(c.tailAudio)/ch - c.mixed; rest > 0 {
+			start := len(c.heldAudio)
+			c.heldAudio = append(c.heldAudio, make([]float64, rest*ch)...)
+			c.mixTail(c.heldAudio[start:])
+			c.nextAudioPTS += int64(rest)
+		}
+	}
+	c.tailAudio, c.mixed = nil, 0
+}
+
+// startCrossfade starts the next clip the crossfade duration before end,
+// the end of the finished clip, queues the held frames before that point
+// and keeps the rest as the tail to blend with. After the last clip all
+// held frames are queued.
+func (c *FrameConcatenator) startCrossfade(end time.Duration) error {
+	if c.cur+1 >= len(c.clips) {
+		for _, f := range c.held {
+			c.queue(f, MediaTypeVideo)
+		}
+		c.held = nil
+		return c.queueHeldAudio(0)
+	}
+
+	frameDur := ptsToDuration(1, c.VideoTimeBase())
+	overlap := min(c.crossfade, time.Duration(len(c.held))*frameDur)
+	if ch := c.audio.Channels; ch > 0 && len(c.heldAudio) > 0 {
+		overlap = min(overlap, ptsToDuration(int64(len(c.heldAudio)/ch), c.AudioTimeBase()))
+	}
+	start := end - overlap
+
+	c.fadeStart = int64(math.Ceil(float64(start) / float64(frameDur)))
+	c.fadeFrames = int64(math.Round(float64(overlap) / float64(frameDur)))
+	i := 0
+	for ; i < len(c.held) && avutil.GetFramePTS(c.held[i].ptr) < c.fadeStart; i++ {
+		c.queue(c.held[i], MediaTypeVideo)
+	}
+	c.tail = c.held[i:]
+	c.held = nil
+	c.videoOffset = c.fadeStart
+	c.nextVideoPTS = c.fadeStart
+
+	if c.hasAudio {
+		startPTS := durationToPTS(start, c.AudioTimeBase())
+		if err := c.queueHeldAudio(int(c.nextAudioPTS - startPTS)); err != nil {
+			return err
+		}
+		c.tailAudio, c.heldAudio = c.heldAudio, nil
+		c.fadeLen = int(durationToPTS(overlap, c.AudioTimeBase()))
+		c.heldPTS, c.nextAudioPTS = startPTS, startPTS
+	}
+	return nil
+}
+
+func (c *FrameConcatenator) releaseOut() {
+	if !c.out.IsNil() {
+		_ = FrameFree(&c.out)
+	}
+	c.out = Frame{}
+}
+
+// Close releases the converters. It does not close the clip decoders.
+func (c *FrameConcatenator) Close() error {
+	if c.closed {
+		return nil
+	}
+	c.closed = true
+	c.releaseOut()
+	for _, frames := range [][]Frame{c.held, c.tail} {
+		for i := range frames {
+			_ = FrameFree(&frames[i])
+		}
+	}
+	for i := range c.ready {
+		_ = FrameFree(&c.ready[i].frame)
+	}
+	c.held, c.tail, c.ready = nil, nil, nil
+	c.heldAudio, c.tailAudio = nil, nil
+	if c.scaler != nil {
+		_ = c.scaler.Close()
+		c.scaler = nil
+	}
+	if c.resampler != nil {
+		_ = c.resampler.Close()
+		c.resampler = nil
+	}
+	c.clips = nil
+	return nil
+}
+
+// durationToPTS converts a time.Duration to a timestamp in tb units,
+// rounding to the nearest unit.
+func durationToPTS(d time.Duration, tb Rational) int64 {
+	if tb.Num <= 0 {
+		return 0
+	}
+	return int64(math.Round(d.Seconds() * float64(tb.Den) / float64(tb.Num)))
+}
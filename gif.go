@@ -0,0 +1,164 @@
+//go:build !ios && !android && (amd64 || arm64)
+
+package ffgo
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/obinnaokechukwu/ffgo/avutil"
+)
+
+// GIFOptions configures ExportGIF.
+type GIFOptions struct {
+	// FrameRate is the GIF frame rate in frames per second (default 10).
+	FrameRate int
+
+	// Width is the output width in pixels; the height follows the source
+	// aspect ratio. Zero keeps the source width.
+	Width int
+}
+
+// ExportGIF writes the clip of input starting at start and lasting duration
+// as an animated GIF to output, e.g. for a short preview loop.
+//
+// The range is frame-accurate: decoding starts at the keyframe before start
+// and frames before start are dropped. Frames are resampled to
+// opts.FrameRate and scaled to opts.Width, then quantized with a palette
+// generated from the whole clip (palettegen followed by paletteuse), which
+// looks far better than a fixed palette. The palette pass buffers the clip
+// until its end, so keep clips short.
+func ExportGIF(input, output string, start, duration time.Duration, opts GIFOptions) error {
+	if start < 0 {
+		return errors.New("ffgo: start must not be negative")
+	}
+	if duration <= 0 {
+		return errors.New("ffgo: duration must be positive")
+	}
+	if opts.FrameRate < 0 || opts.Width < 0 {
+		return errors.New("ffgo: GIF frame rate and width must not be negative")
+	}
+	if opts.FrameRate == 0 {
+		opts.FrameRate = 10
+	}
+
+	dec, err := NewDecoder(input)
+	if err != nil {
+		return err
+	}
+	defer dec.Close()
+	if err := dec.OpenVideoDecoder(); err != nil {
+		return err
+	}
+	info := dec.VideoStream()
+	if info == nil {
+		return ErrNoVideoStream
+	}
+	if start > 0 {
+		if err := dec.SeekWithOptions(start, SeekOptions{Backward: true}); err != nil {
+			return err
+		}
+	}
+
+	graph, err := NewFilterGraphMulti(MultiInputFilterGraphConfig{
+		Inputs: []FilterGraphInput{{
+			Name:     "in",
+			Width:    info.Width,
+			Height:   info.Height,
+			PixelFmt: info.PixelFmt,
+			TimeBase: info.TimeBase,
+		}},
+		Filters: gifFilter(opts),
+	})
+	if err != nil {
+		return err
+	}
+	defer graph.Close()
+
+	var enc *Encoder
+	defer func() {
+		if enc != nil {
+			_ = enc.Close()
+		}
+	}()
+	write := func(frames []Frame) error {
+		defer func() {
+			for i := range frames {
+				_ = frames[i].Free()
+			}
+		}()
+		for _, f := range frames {
+			if enc == nil {
+				enc, err = NewEncoderWithOptions(output, &EncoderOptions{
+					Format: "gif",
+					Video: &VideoEncoderConfig{
+						Codec:       CodecIDGIF,
+						Width:       int(avutil.GetFrameWidth(f.ptr)),
+						Height:      int(avutil.GetFrameHeight(f.ptr)),
+						FrameRate:   NewRational(int32(opts.FrameRate), 1),
+						PixelFormat: PixelFormatPAL8,
+					},
+				})
+				if err != nil {
+					return err
+				}
+			}
+			if err := enc.WriteFrame(f); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	startPTS := rescaleTS(start.Microseconds(), NewRational(1, 1000000), info.TimeBase)
+	end := start + duration
+	for {
+		frame, err := dec.DecodeVideo()
+		if err != nil {
+			return err
+		}
+		if frame.IsNil() {
+			break
+		}
+		pts := frameTimestamp(frame.ptr)
+		if pts == avutil.AV_NOPTS_VALUE {
+			continue
+		}
+		t := ptsToDuration(pts, info.TimeBase)
+		if t < start {
+			continue
+		}
+		if t >= end {
+			break
+		}
+		// Start the clip at zero so the fps filter doesn't pad the front.
+		avutil.SetFramePTS(frame.ptr, pts-startPTS)
+		out, err := graph.FilterFrames(map[string]Frame{"in": frame})
+		if err := errors.Join(err, write(out)); err != nil {
+			return err
+		}
+	}
+
+	out, err := graph.Flush()
+	if err := errors.Join(err, write(out)); err != nil {
+		return err
+	}
+	if enc == nil {
+		return errors.New("ffgo: no frames in the GIF range")
+	}
+	err = enc.Close()
+	enc = nil
+	return err
+}
+
+// gifFilter returns the filter graph ExportGIF runs: frame rate conversion
+// and scaling, then a two-pass palette split so that paletteuse quantizes
+// the clip with the palette palettegen computed over all of it.
+func gifFilter(opts GIFOptions) string {
+	chain := fmt.Sprintf("fps=%d", opts.FrameRate)
+	if opts.Width > 0 {
+		chain += fmt.Sprintf(",scale=%d:-1:flags=lanczos", opts.Width)
+	}
+	return "[in]" + chain + ",split[a][b];[a]palettegen[p];[b][p]paletteuse"
+}
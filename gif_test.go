@@ -0,0 +1,54 @@
+//go:build !ios && !android && (amd64 || arm64)
+
+package ffgo
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestGIFFilter(t *testing.T) {
+	if got, want := gifFilter(GIFOptions{FrameRate: 12, Width: 320}),
+		"[in]fps=12,scale=320:-1:flags=lanczos,split[a][b];[a]palettegen[p];[b][p]paletteuse"; got != want {
+		t.Errorf("gifFilter = %q, want %q", got, want)
+	}
+	if got, want := gifFilter(GIFOptions{FrameRate: 10}),
+		"[in]fps=10,split[a][b];[a]palettegen[p];[b][p]paletteuse"; got != want {
+		t.Errorf("gifFilter = %q, want %q", got, want)
+	}
+}
+
+func TestExportGIF(t *testing.T) {
+	if !requireFFmpeg(t) {
+		return
+	}
+	input := createTestVideo(t)
+	output := filepath.Join(t.TempDir(), "clip.gif")
+
+	if err := ExportGIF(input, output, 0, 0, GIFOptions{}); err == nil {
+		t.Error("ExportGIF with zero duration succeeded, want error")
+	}
+
+	if err := ExportGIF(input, output, 200*time.Millisecond, 500*time.Millisecond, GIFOptions{FrameRate: 10, Width: 64}); err != nil {
+		t.Fatalf("ExportGIF failed: %v", err)
+	}
+	data, err := os.ReadFile(output)
+	if err != nil {
+		t.Fatalf("reading output: %v", err)
+	}
+	if !bytes.HasPrefix(data, []byte("GIF8")) {
+		t.Fatalf("output does not start with a GIF signature")
+	}
+
+	dec, err := NewDecoder(output)
+	if err != nil {
+		t.Fatalf("NewDecoder on GIF failed: %v", err)
+	}
+	defer dec.Close()
+	if info := dec.VideoStream(); info == nil || info.Width != 64 {
+		t.Errorf("GIF stream = %+v, want width 64", info)
+	}
+}
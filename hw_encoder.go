@@ -0,0 +1,512 @@
+//go:build !ios && !android && (amd64 || arm64)
+
+package ffgo
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"unsafe"
+
+	"github.com/obinnaokechukwu/ffgo/avutil"
+	"github.com/obinnaokechukwu/ffgo/internal/bindings"
+)
+
+// HWEncoderConfig configures a hardware-accelerated video encoder.
+type HWEncoderConfig struct {
+	// HWDevice is the hardware device to encode on. Required. It is not
+	// closed by HWEncoder.Close and must outlive the encoder.
+	HWDevice *HWDevice
+
+	// Codec is the FFmpeg encoder name, e.g. "h264_nvenc", "hevc_nvenc",
+	// "h264_vaapi", "hevc_vaapi", "h264_qsv" or "h264_videotoolbox".
+	// It must match the device type. Empty selects the device's H.264
+	// encoder.
+	Codec string
+
+	// Format optionally overrides the output container (muxer short name).
+	// If empty, it is guessed from the output path.
+	Format string
+
+	// Width and Height are the video dimensions in pixels.
+	Width  int
+	Height int
+
+	// FrameRate is the target frame rate (default: 30/1).
+	FrameRate Rational
+
+	// Bitrate is the target bit rate in bits/second (default: 2000000).
+	Bitrate int64
+
+	// GOPSize is the group of pictures size (default: 12).
+	GOPSize int
+
+	// MaxBFrames is the maximum number of B-frames (default: 0).
+	MaxBFrames int
+
+	// SWPixelFormat is the software pixel format of the hardware surfaces.
+	// Software frames in this format and at Width x Height are uploaded
+	// directly; others are converted first. The zero value selects NV12,
+	// which every supported device handles.
+	SWPixelFormat PixelFormat
+
+	// PoolSize is the number of surfaces preallocated in the encoder's
+	// frames pool (default: 20). VAAPI and QSV need a fixed-size pool.
+	PoolSize int
+
+	// CodecOptions are passed to av_opt_set on the codec context, e.g.
+	// {"preset": "p4"} for NVENC or {"rc_mode": "CQP"} for VAAPI.
+	CodecOptions map[string]string
+}
+
+// hwEncoderSetup carries the hardware contexts from NewHWEncoder into
+// NewEncoderWithOptions.
+type hwEncoderSetup struct {
+	codecName string
+	device    avutil.HWDeviceContext
+	frames    avutil.HWFramesContext
+}
+
+// HWEncoder encodes video on a hardware device (NVENC, VAAPI, QSV,
+// VideoToolbox) and muxes it to a file.
+//
+// The codec context is opened with the device's hardware pixel format and a
+// frames context (hw_frames_ctx) on the device. WriteFrame accepts either
+// hardware frames from that device or software frames, which are uploaded
+// to a surface from the encoder's pool first.
+type HWEncoder struct {
+	mu sync.Mutex
+
+	enc      *Encoder
+	path     string
+	cfg      HWEncoderConfig // Codec resolved to the encoder name
+	hwFmt    PixelFormat
+	device   *HWDevice
+	frames   avutil.HWFramesContext
+	hwFrame  avutil.Frame // reusable upload surface
+	swFormat PixelFormat
+	closed   bool
+
+	// Converts software frames to swFormat and the encoder size before upload
+	scaler    *Scaler
+	scalerCfg ScalerConfig
+}
+
+// NewHWEncoder creates a hardware encoder writing to path.
+func NewHWEncoder(path string, cfg HWEncoderConfig) (*HWEncoder, error) {
+	if cfg.HWDevice == nil {
+		return nil, errors.New("ffgo: HWDevice is required for hardware encoding")
+	}
+	if cfg.Width <= 0 || cfg.Height <= 0 {
+		return nil, errors.New("ffgo: width and height must be positive")
+	}
+
+	deviceType := cfg.HWDevice.Type()
+	codecName := cfg.Codec
+	if codecName == "" {
+		codecName = defaultHWEncoderName(deviceType)
+		if codecName == "" {
+			return nil, fmt.Errorf("ffgo: no default hardware encoder for device type %s", cfg.HWDevice.TypeName())
+		}
+	}
+	if want := hwEncoderDeviceType(codecName); want != HWDeviceTypeNone && want != deviceType {
+		return nil, fmt.Errorf("ffgo: encoder %s requires a %s device, got %s",
+			codecName, GetHWDeviceTypeName(want), cfg.HWDevice.TypeName())
+	}
+
+	if err := bindings.Load(); err != nil {
+		return nil, err
+	}
+
+	hwFmtName := hwPixelFormatName(deviceType)
+	if hwFmtName == "" {
+		return nil, fmt.Errorf("ffgo: hardware encoding not supported for device type %s", cfg.HWDevice.TypeName())
+	}
+	hwFmt := avutil.GetPixFmt(hwFmtName)
+	if hwFmt == PixelFormatNone {
+		return nil, fmt.Errorf("ffgo: pixel format %s not available", hwFmtName)
+	}
+
+	swFormat := cfg.SWPixelFormat
+	if swFormat == PixelFormatNone || swFormat == PixelFormatYUV420P {
+		swFormat = PixelFormatNV12
+	}
+	poolSize := cfg.PoolSize
+	if poolSize <= 0 {
+		poolSize = 20
+	}
+
+	frames, err := avutil.HWFramesCtxCreate(cfg.HWDevice.Context(), avutil.HWFramesConfig{
+		Format:          hwFmt,
+		SWFormat:        swFormat,
+		Width:           cfg.Width,
+		Height:          cfg.Height,
+		InitialPoolSize: poolSize,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	cfg.Codec = codecName
+	e := &HWEncoder{
+		path:     path,
+		cfg:      cfg,
+		hwFmt:    hwFmt,
+		device:   cfg.HWDevice,
+		frames:   frames,
+		swFormat: swFormat,
+	}
+	if err := e.openLocked(); err != nil {
+		avutil.FreeBufferRef(&e.frames)
+		return nil, err
+	}
+
+	e.hwFrame = avutil.FrameAlloc()
+	if e.hwFrame == nil {
+		_ = e.enc.Close()
+		avutil.FreeBufferRef(&e.frames)
+		return nil, errors.New("ffgo: failed to allocate frame")
+	}
+	return e, nil
+}
+
+// openLocked creates the underlying Encoder with the current frames context.
+func (e *HWEncoder) openLocked() error {
+	enc, err := NewEncoderWithOptions(e.path, &EncoderOptions{
+		Format: e.cfg.Format,
+		Video: &VideoEncoderConfig{
+			Width:        e.cfg.Width,
+			Height:       e.cfg.Height,
+			FrameRate:    e.cfg.FrameRate,
+			Bitrate:      e.cfg.Bitrate,
+			PixelFormat:  e.hwFmt,
+			GOPSize:      e.cfg.GOPSize,
+			MaxBFrames:   e.cfg.MaxBFrames,
+			CodecOptions: e.cfg.CodecOptions,
+		},
+		hw: &hwEncoderSetup{
+			codecName: e.cfg.Codec,
+			device:    e.device.Context(),
+			frames:    e.frames,
+		},
+	})
+	if err != nil {
+		return err
+	}
+	e.enc = enc
+	return nil
+}
+
+// SetFramesContext makes the encoder use an existing hardware frames
+// context, typically the pool of a HWDecoder (see HWDecoder.FramesContext),
+// so decoded surfaces are encoded without a GPU->CPU->GPU round trip.
+//
+// The frames context must belong to the encoder's device: create the
+// decoder and the encoder from the same HWDevice. Its hardware pixel format
+// must be the one the encoder was created with, and its software format
+// replaces SWPixelFormat for uploads.
+//
+// It must be called before the first frame is written, because the codec
+// is reopened with the new context. WriteFrame does this automatically when
+// the first frame is a hardware frame from another pool. If reopening
+// fails the encoder is closed.
+func (e *HWEncoder) SetFramesContext(fctx avutil.HWFramesContext) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	if e.closed {
+		return errors.New("ffgo: encoder is closed")
+	}
+	return e.setFramesContextLocked(fctx)
+}
+
+func (e *HWEncoder) setFramesContextLocked(fctx avutil.HWFramesContext) error {
+	if fctx == nil {
+		return errors.New("ffgo: frames context is nil")
+	}
+	if e.enc.FrameCount() > 0 {
+		return errors.New("ffgo: SetFramesContext must be called before the first frame is written")
+	}
+	if bufferRefData(fctx) == bufferRefData(e.frames) {
+		return nil
+	}
+
+	params, device := avutil.GetHWFramesParams(fctx)
+	if device != bufferRefData(e.device.Context()) {
+		return errors.New("ffgo: frames context belongs to a different hardware device")
+	}
+	if params.Format != e.hwFmt {
+		return fmt.Errorf("ffgo: frames context has pixel format %s, encoder expects %s",
+			avutil.GetPixFmtName(params.Format), avutil.GetPixFmtName(e.hwFmt))
+	}
+
+	ref := avutil.NewBufferRef(fctx)
+	if ref == nil {
+		return errors.New("ffgo: failed to reference frames context")
+	}
+
+	// Nothing has been written, so closing only releases the codec and
+	// output; reopening truncates the output file.
+	_ = e.enc.Close()
+	e.enc = nil
+	avutil.FreeBufferRef(&e.frames)
+	e.frames = ref
+	e.swFormat = params.SWFormat
+
+	if err := e.openLocked(); err != nil {
+		e.closeLocked()
+		return err
+	}
+	return nil
+}
+
+// bufferRefData returns the data an AVBufferRef points at, so that two
+// references to the same buffer compare equal.
+func bufferRefData(ref unsafe.Pointer) unsafe.Pointer {
+	if ref == nil {
+		return nil
+	}
+	return *(*unsafe.Pointer)(unsafe.Add(ref, 8))
+}
+
+// defaultHWEncoderName returns the H.264 encoder for a device type.
+func defaultHWEncoderName(deviceType HWDeviceType) string {
+	switch deviceType {
+	case HWDeviceTypeCUDA:
+		return "h264_nvenc"
+	case HWDeviceTypeVAAPI:
+		return "h264_vaapi"
+	case HWDeviceTypeQSV:
+		return "h264_qsv"
+	case HWDeviceTypeVideoToolbox:
+		return "h264_videotoolbox"
+	default:
+		return ""
+	}
+}
+
+// hwEncoderDeviceType returns the device type a hardware encoder name
+// requires, or HWDeviceTypeNone if it is not recognized.
+func hwEncoderDeviceType(codecName string) HWDeviceType {
+	switch {
+	case strings.HasSuffix(codecName, "_nvenc"):
+		return HWDeviceTypeCUDA
+	case strings.HasSuffix(codecName, "_vaapi"):
+		return HWDeviceTypeVAAPI
+	case strings.HasSuffix(codecName, "_qsv"):
+		return HWDeviceTypeQSV
+	case strings.HasSuffix(codecName, "_videotoolbox"):
+		return HWDeviceTypeVideoToolbox
+	default:
+		return HWDeviceTypeNone
+	}
+}
+
+// hwPixelFormatName returns the FFmpeg name of the hardware pixel format
+// for surfaces on a device type.
+func hwPixelFormatName(deviceType HWDeviceType) string {
+	switch deviceType {
+	case HWDeviceTypeCUDA:
+		return "cuda"
+	case HWDeviceTypeVAAPI:
+		return "vaapi"
+	case HWDeviceTypeQSV:
+		return "qsv"
+	case HWDeviceTypeVideoToolbox:
+		return "videotoolbox_vld"
+	case HWDeviceTypeD3D11VA:
+		return "d3d11"
+	case HWDeviceTypeDXVA2:
+		return "dxva2_vld"
+	case HWDeviceTypeVulkan:
+		return "vulkan"
+	case HWDeviceTypeVDPAU:
+		return "vdpau"
+	case HWDeviceTypeDRM:
+		return "drm_prime"
+	case HWDeviceTypeOpenCL:
+		return "opencl"
+	case HWDeviceTypeMediaCodec:
+		return "mediacodec"
+	default:
+		return ""
+	}
+}
+
+// WriteFrame encodes a frame. Hardware frames are sent as is. When the
+// codec context expects a hardware pixel format and frame is a software
+// frame, it is uploaded to a surface from the encoder's pool first (see
+// UploadFrame). Timestamps follow Encoder.WriteFrame.
+func (e *HWEncoder) WriteFrame(frame Frame) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	if e.closed {
+		return errors.New("ffgo: encoder is closed")
+	}
+	if frame.ptr == nil {
+		return errors.New("ffgo: frame is nil; use Close to flush the encoder")
+	}
+	if framesCtx := avutil.GetFrameHWFramesCtx(frame.ptr); framesCtx != nil {
+		// Adopt the pool of the first hardware frame (e.g. from a HWDecoder)
+		if e.enc.FrameCount() == 0 && bufferRefData(framesCtx) != bufferRefData(e.frames) {
+			if err := e.setFramesContextLocked(framesCtx); err != nil {
+				return err
+			}
+		}
+		return e.enc.WriteFrame(frame)
+	}
+	if !isHWPixelFormat(e.enc.PixelFormat()) || isHWPixelFormat(PixelFormat(avutil.GetFrameFormat(frame.ptr))) {
+		return e.enc.WriteFrame(frame)
+	}
+
+	avutil.FrameUnref(e.hwFrame)
+	defer avutil.FrameUnref(e.hwFrame)
+	if err := e.uploadLocked(e.hwFrame, frame); err != nil {
+		return err
+	}
+	return e.enc.WriteFrame(Frame{ptr: e.hwFrame})
+}
+
+// UploadFrame copies a software frame into a new hardware frame allocated
+// from the encoder's frames pool. Frames in a format other than
+// SWPixelFormat, or at another size, are converted with a Scaler first.
+// The PTS is carried over. The returned frame is owned by the caller and
+// must be freed with Frame.Free; it can be passed to WriteFrame.
+//
+// WriteFrame uploads software frames automatically; UploadFrame is for
+// callers that want to upload ahead of time or reuse a surface.
+func (e *HWEncoder) UploadFrame(sw Frame) (Frame, error) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	if e.closed {
+		return Frame{}, errors.New("ffgo: encoder is closed")
+	}
+	if sw.ptr == nil {
+		return Frame{}, errors.New("ffgo: frame is nil")
+	}
+	if isHWPixelFormat(PixelFormat(avutil.GetFrameFormat(sw.ptr))) {
+		return Frame{}, errors.New("ffgo: UploadFrame requires a software frame")
+	}
+
+	hw := avutil.FrameAlloc()
+	if hw == nil {
+		return Frame{}, errors.New("ffgo: failed to allocate frame")
+	}
+	if err := e.uploadLocked(hw, sw); err != nil {
+		avutil.FrameFree(&hw)
+		return Frame{}, err
+	}
+	return Frame{ptr: hw, owned: true}, nil
+}
+
+// uploadLocked allocates a surface from the frames pool into dst and
+// transfers src to it, converting src to the surface format and size first
+// if needed.
+func (e *HWEncoder) uploadLocked(dst avutil.Frame, src Frame) error {
+	data := src
+	width := int(avutil.GetFrameWidth(src.ptr))
+	height := int(avutil.GetFrameHeight(src.ptr))
+	format := PixelFormat(avutil.GetFrameFormat(src.ptr))
+	if format != e.swFormat || width != e.cfg.Width || height != e.cfg.Height {
+		cfg := ScalerConfig{
+			SrcWidth: width, SrcHeight: height, SrcFormat: format,
+			DstWidth: e.cfg.Width, DstHeight: e.cfg.Height, DstFormat: e.swFormat,
+		}
+		var err error
+		if e.scaler == nil {
+			e.scaler, err = NewScalerWithConfig(cfg)
+		} else if cfg != e.scalerCfg {
+			err = e.scaler.Reconfigure(cfg)
+			if err != nil {
+				_ = e.scaler.Close()
+				e.scaler = nil
+			}
+		}
+		if err != nil {
+			return fmt.Errorf("ffgo: converting %dx%d %s frame for upload: %w",
+				width, height, avutil.GetPixFmtName(format), err)
+		}
+		e.scalerCfg = cfg
+		if data, err = e.scaler.Scale(src); err != nil {
+			return err
+		}
+	}
+
+	if err := avutil.HWFrameGetBuffer(e.frames, dst); err != nil {
+		return err
+	}
+	// A shared pool may have larger (aligned) surfaces than the picture
+	avutil.SetFrameWidth(dst, int32(e.cfg.Width))
+	avutil.SetFrameHeight(dst, int32(e.cfg.Height))
+	if err := avutil.HWFrameTransferData(dst, data.ptr, 0); err != nil {
+		return err
+	}
+	avutil.SetFramePTS(dst, avutil.GetFramePTS(src.ptr))
+	return nil
+}
+
+// isHWPixelFormat reports whether pixFmt is a hardware surface format.
+func isHWPixelFormat(pixFmt PixelFormat) bool {
+	desc := avutil.GetPixFmtDescriptor(pixFmt)
+	return desc != nil && desc.Flags&avutil.PixFmtFlagHWAccel != 0
+}
+
+// Device returns the hardware device the encoder runs on.
+func (e *HWEncoder) Device() *HWDevice {
+	return e.device
+}
+
+// Width returns the video width.
+func (e *HWEncoder) Width() int {
+	return e.cfg.Width
+}
+
+// Height returns the video height.
+func (e *HWEncoder) Height() int {
+	return e.cfg.Height
+}
+
+// FrameCount returns the number of frames written.
+func (e *HWEncoder) FrameCount() int64 {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if e.enc == nil {
+		return 0
+	}
+	return e.enc.FrameCount()
+}
+
+// Close flushes the encoder, writes the trailer and releases the encoder's
+// resources. The device is not closed.
+func (e *HWEncoder) Close() error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	if e.closed {
+		return nil
+	}
+	return e.closeLocked()
+}
+
+func (e *HWEncoder) closeLocked() error {
+	e.closed = true
+
+	var err error
+	if e.enc != nil {
+		err = e.enc.Close()
+	}
+	if e.scaler != nil {
+		_ = e.scaler.Close()
+		e.scaler = nil
+	}
+	if e.hwFrame != nil {
+		avutil.FrameFree(&e.hwFrame)
+	}
+	if e.frames != nil {
+		avutil.FreeBufferRef(&e.frames)
+	}
+	return err
+}
@@ -0,0 +1,196 @@
+//go:build !ios && !android && (amd64 || arm64)
+
+package ffgo
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"unsafe"
+
+	"github.com/obinnaokechukwu/ffgo/avutil"
+)
+
+// HWScaler resizes hardware frames on the GPU without downloading them to
+// system memory. It drives FFmpeg's scale_cuda, scale_vaapi or scale_qsv
+// filter depending on the device type.
+//
+// The filter graph is built lazily from the first frame passed to Scale,
+// since it needs the frames context the hardware frames were allocated from.
+type HWScaler struct {
+	mu sync.Mutex
+
+	device     *HWDevice
+	filterName string
+	srcFmt     PixelFormat
+	dstFmt     PixelFormat
+	dstWidth   int
+	dstHeight  int
+
+	graph       *FilterGraph
+	framesData  unsafe.Pointer // AVHWFramesContext the graph was built for
+	inputWidth  int
+	inputHeight int
+	closed      bool
+}
+
+// ErrHWScalerClosed is returned when operating on a closed HWScaler.
+var ErrHWScalerClosed = errors.New("ffgo: hardware scaler is closed")
+
+// NewHWScaler creates a GPU scaler for frames produced on device.
+//
+// srcFmt is the software pixel format backing the input hardware frames
+// (e.g., PixelFormatNV12). dstFmt selects the software format of the output
+// frames; pass PixelFormatNone or srcFmt to keep the input format.
+// dstW and dstH are the output dimensions.
+//
+// Supported device types are CUDA (scale_cuda), VAAPI (scale_vaapi) and
+// QSV (scale_qsv).
+func NewHWScaler(device *HWDevice, srcFmt, dstFmt PixelFormat, dstW, dstH int) (*HWScaler, error) {
+	if device == nil {
+		return nil, errors.New("ffgo: hardware device is nil")
+	}
+	if dstW <= 0 || dstH <= 0 {
+		return nil, errors.New("ffgo: invalid output dimensions")
+	}
+
+	filterName := hwScaleFilterName(device.Type())
+	if filterName == "" {
+		return nil, fmt.Errorf("ffgo: hardware scaling not supported for device type %s", device.TypeName())
+	}
+
+	return &HWScaler{
+		device:     device,
+		filterName: filterName,
+		srcFmt:     srcFmt,
+		dstFmt:     dstFmt,
+		dstWidth:   dstW,
+		dstHeight:  dstH,
+	}, nil
+}
+
+// hwScaleFilterName returns the GPU scale filter for a device type.
+func hwScaleFilterName(deviceType HWDeviceType) string {
+	switch deviceType {
+	case HWDeviceTypeCUDA:
+		return "scale_cuda"
+	case HWDeviceTypeVAAPI:
+		return "scale_vaapi"
+	case HWDeviceTypeQSV:
+		return "scale_qsv"
+	default:
+		return ""
+	}
+}
+
+// Scale resizes a hardware frame and returns a new hardware frame.
+// The input frame is not modified. The returned frame is owned by the caller
+// and must be freed with Frame.Free.
+//
+// If the input dimensions or frames context change between calls, the
+// underlying filter graph is rebuilt.
+func (s *HWScaler) Scale(hwFrame Frame) (Frame, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.closed {
+		return Frame{}, ErrHWScalerClosed
+	}
+	if hwFrame.ptr == nil {
+		return Frame{}, errors.New("ffgo: frame is nil")
+	}
+
+	framesCtx := avutil.GetFrameHWFramesCtx(hwFrame.ptr)
+	if framesCtx == nil {
+		return Frame{}, errors.New("ffgo: HWScaler requires a hardware frame")
+	}
+
+	// Each frame carries its own reference, so compare the referenced
+	// AVHWFramesContext (AVBufferRef.data) rather than the ref itself.
+	framesData := *(*unsafe.Pointer)(unsafe.Pointer(uintptr(framesCtx) + 8))
+	width := int(avutil.GetFrameWidth(hwFrame.ptr))
+	height := int(avutil.GetFrameHeight(hwFrame.ptr))
+	if s.graph == nil || framesData != s.framesData || width != s.inputWidth || height != s.inputHeight {
+		if err := s.buildGraphLocked(hwFrame, framesCtx, width, height); err != nil {
+			return Frame{}, err
+		}
+	}
+
+	frames, err := s.graph.Filter(&hwFrame)
+	if err != nil {
+		freeFrames(frames)
+		return Frame{}, err
+	}
+	if len(frames) == 0 {
+		return Frame{}, errors.New("ffgo: hardware scaler produced no output")
+	}
+
+	// Scale filters are 1:1; drop anything beyond the first frame.
+	out := frames[0]
+	freeFrames(frames[1:])
+	return out, nil
+}
+
+// buildGraphLocked (re)creates the filter graph for the given input frame.
+func (s *HWScaler) buildGraphLocked(hwFrame Frame, framesCtx avutil.HWFramesContext, width, height int) error {
+	if s.graph != nil {
+		s.graph.Close()
+		s.graph = nil
+	}
+
+	args := fmt.Sprintf("w=%d:h=%d", s.dstWidth, s.dstHeight)
+	if s.dstFmt != PixelFormatNone && s.dstFmt != s.srcFmt {
+		name := avutil.GetPixFmtName(s.dstFmt)
+		if name == "" {
+			return fmt.Errorf("ffgo: unknown output pixel format %d", s.dstFmt)
+		}
+		args += ":format=" + name
+	}
+
+	graph, err := NewFilterGraph(FilterGraphConfig{
+		Width:       width,
+		Height:      height,
+		PixelFmt:    PixelFormat(avutil.GetFrameFormat(hwFrame.ptr)),
+		Filters:     s.filterName + "=" + args,
+		hwFramesCtx: framesCtx,
+	})
+	if err != nil {
+		return err
+	}
+
+	s.graph = graph
+	s.framesData = *(*unsafe.Pointer)(unsafe.Pointer(uintptr(framesCtx) + 8))
+	s.inputWidth = width
+	s.inputHeight = height
+	return nil
+}
+
+// Device returns the hardware device the scaler runs on.
+func (s *HWScaler) Device() *HWDevice {
+	return s.device
+}
+
+// Close releases the scaler's filter graph. The device is not closed.
+func (s *HWScaler) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.closed {
+		return nil
+	}
+	s.closed = true
+
+	if s.graph != nil {
+		s.graph.Close()
+		s.graph = nil
+	}
+	s.framesData = nil
+	return nil
+}
+
+// freeFrames frees owned frames returned by FilterGraph.
+func freeFrames(frames []Frame) {
+	for i := range frames {
+		_ = frames[i].Free()
+	}
+}
@@ -4,6 +4,7 @@ package ffgo
 
 import (
 	"errors"
+	"fmt"
 	"sync"
 
 	"github.com/obinnaokechukwu/ffgo/avcodec"
@@ -115,6 +116,13 @@ type HWDecoderConfig struct {
 	// returns software frames that can be processed normally.
 	// If false, frames remain in GPU memory and must be transferred manually.
 	OutputSoftwareFrames bool
+
+	// KeepHardwareFrames installs a get_format callback that selects the
+	// device's hardware pixel format, so decoding fails instead of silently
+	// falling back to software when the codec cannot decode on the device.
+	// DecodeVideo then returns hardware frames even if OutputSoftwareFrames
+	// is set, ready for HWScaler or HWEncoder. Requires the ffgo shim.
+	KeepHardwareFrames bool
 }
 
 // HWDecoder is a hardware-accelerated video decoder.
@@ -193,6 +201,23 @@ func NewHWDecoder(inputPath string, cfg *HWDecoderConfig) (*HWDecoder, error) {
 	// Set hardware device context BEFORE opening the codec
 	avcodec.SetCtxHWDeviceCtx(codecCtx, cfg.HWDevice.Context())
 
+	outputSoftwareFrames := cfg.OutputSoftwareFrames
+	if cfg.KeepHardwareFrames {
+		outputSoftwareFrames = false
+		hwFmt := hwDecoderPixelFormat(decoder, cfg.HWDevice.Type())
+		if hwFmt == PixelFormatNone {
+			avcodec.FreeContext(&codecCtx)
+			avformat.CloseInput(&formatCtx)
+			return nil, fmt.Errorf("ffgo: %s decoder does not support %s hardware decoding",
+				avcodec.GetCodecName(decoder), cfg.HWDevice.TypeName())
+		}
+		if err := avcodec.SetCtxHWGetFormat(codecCtx, hwFmt); err != nil {
+			avcodec.FreeContext(&codecCtx)
+			avformat.CloseInput(&formatCtx)
+			return nil, fmt.Errorf("ffgo: KeepHardwareFrames requires the ffgo shim: %w", err)
+		}
+	}
+
 	// Open codec
 	if err := avcodec.Open2(codecCtx, decoder, nil); err != nil {
 		avcodec.FreeContext(&codecCtx)
@@ -220,7 +245,7 @@ func NewHWDecoder(inputPath string, cfg *HWDecoderConfig) (*HWDecoder, error) {
 
 	// Allocate software frame for transfers if needed
 	var swFrame avutil.Frame
-	if cfg.OutputSoftwareFrames {
+	if outputSoftwareFrames {
 		swFrame = avutil.FrameAlloc()
 		if swFrame == nil {
 			avutil.FrameFree(&frame)
@@ -255,7 +280,7 @@ func NewHWDecoder(inputPath string, cfg *HWDecoderConfig) (*HWDecoder, error) {
 		videoStreamIdx:      int(videoStreamIdx),
 		videoInfo:           videoInfo,
 		hwDevice:            cfg.HWDevice,
-		outputSoftwareFrame: cfg.OutputSoftwareFrames,
+		outputSoftwareFrame: outputSoftwareFrames,
 	}, nil
 }
 
@@ -367,6 +392,21 @@ func (d *HWDecoder) ReadHWFrame() (Frame, error) {
 	}
 }
 
+// FramesContext returns the hardware frames context the decoder allocates
+// its surfaces from, or nil before the first frame has been decoded.
+// Pass it to HWEncoder.SetFramesContext to encode frames from ReadHWFrame
+// on the GPU without downloading them; the encoder must use the same
+// HWDevice as the decoder.
+func (d *HWDecoder) FramesContext() avutil.HWFramesContext {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if d.closed {
+		return nil
+	}
+	return avcodec.GetCtxHWFramesCtx(d.videoCodecCtx)
+}
+
 // TransferToSystem transfers a hardware frame to a software frame in CPU memory.
 // Use this if you called ReadHWFrame and need to process the frame on the CPU.
 // The returned frame must be freed by the caller when no longer needed.
@@ -459,3 +499,86 @@ func AvailableHWDeviceTypes() []HWDeviceType {
 func GetHWDeviceTypeName(t HWDeviceType) string {
 	return avutil.HWDeviceGetTypeName(t)
 }
+
+// HWConfigMethod describes how a codec uses a hardware device
+// (AV_CODEC_HW_CONFIG_METHOD_* flags).
+type HWConfigMethod int32
+
+// Hardware configuration methods.
+const (
+	// HWConfigMethodHWDeviceCtx means the codec works with a device context
+	// (HWDecoderConfig.HWDevice); this is the method NewHWDecoder uses.
+	HWConfigMethodHWDeviceCtx HWConfigMethod = avcodec.HWConfigMethodHWDeviceCtx
+	// HWConfigMethodHWFramesCtx means the codec accepts a frames context.
+	HWConfigMethodHWFramesCtx HWConfigMethod = avcodec.HWConfigMethodHWFramesCtx
+	// HWConfigMethodInternal means the codec sets up the hardware itself.
+	HWConfigMethodInternal HWConfigMethod = avcodec.HWConfigMethodInternal
+	// HWConfigMethodAdHoc is a deprecated codec-specific setup.
+	HWConfigMethodAdHoc HWConfigMethod = avcodec.HWConfigMethodAdHoc
+)
+
+// HWConfig is one hardware configuration supported by a decoder.
+type HWConfig struct {
+	// PixelFormat is the hardware pixel format frames are decoded to.
+	PixelFormat PixelFormat
+	// Methods is the set of supported setup methods.
+	Methods HWConfigMethod
+	// DeviceType is the device type the configuration applies to.
+	DeviceType HWDeviceType
+}
+
+// AvailableHWConfigs returns the hardware configurations the default
+// decoder for codec supports on deviceType, in the decoder's order of
+// preference. Pass HWDeviceTypeNone to list configurations for all device
+// types. An empty result means the decoder cannot use the device and
+// HWDecoder would fall back to software decoding.
+func AvailableHWConfigs(codec CodecID, deviceType HWDeviceType) []HWConfig {
+	if err := bindings.Load(); err != nil {
+		return nil
+	}
+	dec := avcodec.FindDecoder(codec)
+	if dec == nil {
+		return nil
+	}
+
+	var configs []HWConfig
+	for i := 0; ; i++ {
+		cfg, ok := avcodec.GetHWConfig(dec, i)
+		if !ok {
+			break
+		}
+		if deviceType != HWDeviceTypeNone && cfg.DeviceType != deviceType {
+			continue
+		}
+		configs = append(configs, HWConfig{
+			PixelFormat: cfg.PixFmt,
+			Methods:     HWConfigMethod(cfg.Methods),
+			DeviceType:  cfg.DeviceType,
+		})
+	}
+	return configs
+}
+
+// GetHWPixelFormats returns the hardware pixel formats the default decoder
+// for codec can produce on deviceType.
+func GetHWPixelFormats(codec CodecID, deviceType HWDeviceType) []PixelFormat {
+	var formats []PixelFormat
+	for _, cfg := range AvailableHWConfigs(codec, deviceType) {
+		formats = append(formats, cfg.PixelFormat)
+	}
+	return formats
+}
+
+// hwDecoderPixelFormat returns the hardware pixel format decoder uses with
+// a device context of deviceType, or PixelFormatNone if it has none.
+func hwDecoderPixelFormat(decoder avcodec.Codec, deviceType HWDeviceType) PixelFormat {
+	for i := 0; ; i++ {
+		cfg, ok := avcodec.GetHWConfig(decoder, i)
+		if !ok {
+			return PixelFormatNone
+		}
+		if cfg.DeviceType == deviceType && cfg.Methods&avcodec.HWConfigMethodHWDeviceCtx != 0 {
+			return cfg.PixFmt
+		}
+	}
+}
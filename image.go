@@ -199,6 +199,13 @@ func ExtractFrame(inputPath string, ts time.Duration, outputPath string) error {
 // pattern should contain a format specifier like %02d for the frame number.
 // interval is the time between thumbnails, maxCount limits the number of thumbnails.
 func GenerateThumbnails(inputPath string, interval time.Duration, maxCount int, outputPattern string) ([]string, error) {
+	return GenerateThumbnailsWithProgress(inputPath, interval, maxCount, outputPattern, nil)
+}
+
+// GenerateThumbnailsWithProgress is like GenerateThumbnails but calls
+// onProgress, if non-nil, after each thumbnail position is processed.
+// FramesDone and TotalFrames count thumbnails.
+func GenerateThumbnailsWithProgress(inputPath string, interval time.Duration, maxCount int, outputPattern string, onProgress func(Progress)) ([]string, error) {
 	decoder, err := NewDecoder(inputPath)
 	if err != nil {
 		return nil, err
@@ -219,12 +226,14 @@ func GenerateThumbnails(inputPath string, interval time.Duration, maxCount int,
 		count = 1
 	}
 
+	progress := newProgressTracker(onProgress, int64(count), duration)
 	var filenames []string
 	for i := 0; i < count; i++ {
 		ts := interval * time.Duration(i)
 		if ts >= duration {
 			break
 		}
+		progress.update(int64(i), ts)
 
 		// Seek to the timestamp
 		if err := decoder.Seek(ts); err != nil {
@@ -245,10 +254,25 @@ func GenerateThumbnails(inputPath string, interval time.Duration, maxCount int,
 		}
 		filenames = append(filenames, filename)
 	}
+	progress.update(int64(count), duration)
 
 	return filenames, nil
 }
 
+// GenerateSmartThumbnails opens inputPath and returns count representative
+// frames chosen by FFmpeg's thumbnail filter; see
+// Decoder.ExtractSmartThumbnails. The returned frames are owned by the
+// caller and must be freed when done.
+func GenerateSmartThumbnails(inputPath string, count int) ([]Frame, error) {
+	decoder, err := NewDecoder(inputPath)
+	if err != nil {
+		return nil, err
+	}
+	defer decoder.Close()
+
+	return decoder.ExtractSmartThumbnails(count)
+}
+
 // Keyframe represents a keyframe position in the video
 type Keyframe struct {
 	PTS      int64         // Presentation timestamp
@@ -303,6 +327,14 @@ func (d *Decoder) GetKeyframes() ([]Keyframe, error) {
 		flags := avcodec.GetPacketFlags(d.packet)
 		if flags&1 != 0 {
 			pts := avcodec.GetPacketPTS(d.packet)
+			if pts == avutil.NoPTSValue {
+				// Some demuxers only timestamp keyframes by DTS.
+				pts = avcodec.GetPacketDTS(d.packet)
+			}
+			if pts == avutil.NoPTSValue {
+				avcodec.PacketUnref(d.packet)
+				continue
+			}
 			pos := avcodec.GetPacketPos(d.packet)
 
 			// Convert PTS to time
@@ -53,6 +53,7 @@ var (
 
 	// Function bindings
 	shimLogSetCallback func(cb uintptr)
+	shimLogSetCompCB   func(cb uintptr)
 	shimLogSetLevel    func(level int32)
 	shimLog            func(avcl uintptr, level int32, msg string)
 	shimNewChapter     func(ctx uintptr, id int64, tbNum, tbDen int32, start, end int64, metadata uintptr) uintptr
@@ -63,6 +64,8 @@ var (
 
 	// AVFrame offset discovery helpers
 	shimAVFrameColorOffsets func(outRange, outSpace, outPrimaries, outTransfer *int32) int32
+	shimAVFrameMetadata     func(frame uintptr) uintptr
+	shimAVFrameTimingOffs   func(outBestEffort, outDuration *int32) int32
 
 	// AVCodecParameters field helpers (optional)
 	shimCodecParWidth      func(par uintptr) int32
@@ -84,12 +87,20 @@ var (
 	shimCodecCtxSetTimeBase  func(ctx uintptr, num, den int32)
 	shimCodecCtxFramerate    func(ctx uintptr, outNum, outDen *int32)
 	shimCodecCtxSetFramerate func(ctx uintptr, num, den int32)
+	shimCodecCtxSAR          func(ctx uintptr, outNum, outDen *int32)
+	shimCodecCtxSetSAR       func(ctx uintptr, num, den int32)
 	shimCodecCtxSetChLayout  func(ctx uintptr, nbChannels int32)
 	shimCodecCtxHWDeviceCtx  func(ctx uintptr) uintptr
 	shimCodecCtxSetHWDevice  func(ctx uintptr, ref uintptr)
 	shimCodecCtxHWFramesCtx  func(ctx uintptr) uintptr
 	shimCodecCtxSetHWFrames  func(ctx uintptr, ref uintptr)
 
+	shimCodecCtxSetHWGetFormat func(ctx uintptr, hwPixFmt int32)
+
+	// AVHWFramesContext setup (optional)
+	shimHWFramesCtxSetParams func(ref uintptr, format, swFormat, width, height, initialPoolSize int32)
+	shimHWFramesCtxParams    func(ref uintptr, format, swFormat, width, height, initialPoolSize *int32) uintptr
+
 	// AVFormatContext / chapter / program helpers (optional)
 	shimFormatCtxDuration    func(ctx uintptr) int64
 	shimFormatCtxBitRate     func(ctx uintptr) int64
@@ -97,6 +108,9 @@ var (
 	shimFormatCtxChapter     func(ctx uintptr, index int32) uintptr
 	shimFormatCtxNbPrograms  func(ctx uintptr) uint32
 	shimFormatCtxProgram     func(ctx uintptr, index int32) uintptr
+	shimFormatCtxSetIntFlag  func(ctx uintptr, flag uintptr)
+
+	shimStreamDisplayMatrix func(stream uintptr) uintptr
 
 	shimChapterID       func(ch uintptr) int64
 	shimChapterTimeBase func(ch uintptr, outNum, outDen *int32)
@@ -268,6 +282,7 @@ func registerBindings() {
 	// The shim library can exist in partial form depending on how it was built.
 	// Treat all symbols as optional and expose feature-level errors when missing.
 	registerOptionalLibFunc(&shimLogSetCallback, libShim, "ffshim_log_set_callback")
+	registerOptionalLibFunc(&shimLogSetCompCB, libShim, "ffshim_log_set_component_callback")
 	registerOptionalLibFunc(&shimLogSetLevel, libShim, "ffshim_log_set_level")
 	registerOptionalLibFunc(&shimLog, libShim, "ffshim_log")
 	registerOptionalLibFunc(&shimNewChapter, libShim, "ffshim_new_chapter")
@@ -276,6 +291,8 @@ func registerBindings() {
 	registerOptionalLibFunc(&shimAVDeviceListInputSources, libShim, "ffshim_avdevice_list_input_sources")
 	registerOptionalLibFunc(&shimAVDeviceFreeStringArray, libShim, "ffshim_avdevice_free_string_array")
 	registerOptionalLibFunc(&shimAVFrameColorOffsets, libShim, "ffshim_avframe_color_offsets")
+	registerOptionalLibFunc(&shimAVFrameMetadata, libShim, "ffshim_avframe_metadata")
+	registerOptionalLibFunc(&shimAVFrameTimingOffs, libShim, "ffshim_avframe_timing_offsets")
 
 	// AVCodecParameters field helpers (optional)
 	registerOptionalLibFunc(&shimCodecParWidth, libShim, "ffshim_codecpar_width")
@@ -297,12 +314,20 @@ func registerBindings() {
 	registerOptionalLibFunc(&shimCodecCtxSetTimeBase, libShim, "ffshim_codecctx_set_time_base")
 	registerOptionalLibFunc(&shimCodecCtxFramerate, libShim, "ffshim_codecctx_framerate")
 	registerOptionalLibFunc(&shimCodecCtxSetFramerate, libShim, "ffshim_codecctx_set_framerate")
+	registerOptionalLibFunc(&shimCodecCtxSAR, libShim, "ffshim_codecctx_sample_aspect_ratio")
+	registerOptionalLibFunc(&shimCodecCtxSetSAR, libShim, "ffshim_codecctx_set_sample_aspect_ratio")
 	registerOptionalLibFunc(&shimCodecCtxSetChLayout, libShim, "ffshim_codecctx_set_ch_layout_default")
 	registerOptionalLibFunc(&shimCodecCtxHWDeviceCtx, libShim, "ffshim_codecctx_hw_device_ctx")
 	registerOptionalLibFunc(&shimCodecCtxSetHWDevice, libShim, "ffshim_codecctx_set_hw_device_ctx")
 	registerOptionalLibFunc(&shimCodecCtxHWFramesCtx, libShim, "ffshim_codecctx_hw_frames_ctx")
 	registerOptionalLibFunc(&shimCodecCtxSetHWFrames, libShim, "ffshim_codecctx_set_hw_frames_ctx")
 
+	registerOptionalLibFunc(&shimCodecCtxSetHWGetFormat, libShim, "ffshim_codecctx_set_hw_get_format")
+
+	// AVHWFramesContext setup (optional)
+	registerOptionalLibFunc(&shimHWFramesCtxSetParams, libShim, "ffshim_hwframes_ctx_set_params")
+	registerOptionalLibFunc(&shimHWFramesCtxParams, libShim, "ffshim_hwframes_ctx_params")
+
 	// AVFormatContext / chapter / program helpers (optional)
 	registerOptionalLibFunc(&shimFormatCtxDuration, libShim, "ffshim_formatctx_duration")
 	registerOptionalLibFunc(&shimFormatCtxBitRate, libShim, "ffshim_formatctx_bit_rate")
@@ -310,6 +335,9 @@ func registerBindings() {
 	registerOptionalLibFunc(&shimFormatCtxChapter, libShim, "ffshim_formatctx_chapter")
 	registerOptionalLibFunc(&shimFormatCtxNbPrograms, libShim, "ffshim_formatctx_nb_programs")
 	registerOptionalLibFunc(&shimFormatCtxProgram, libShim, "ffshim_formatctx_program")
+	registerOptionalLibFunc(&shimFormatCtxSetIntFlag, libShim, "ffshim_formatctx_set_interrupt_flag")
+
+	registerOptionalLibFunc(&shimStreamDisplayMatrix, libShim, "ffshim_stream_display_matrix")
 
 	registerOptionalLibFunc(&shimChapterID, libShim, "ffshim_chapter_id")
 	registerOptionalLibFunc(&shimChapterTimeBase, libShim, "ffshim_chapter_time_base")
@@ -343,6 +371,27 @@ func SetLogCallback(cb uintptr) error {
 	return nil
 }
 
+// SetLogComponentCallback sets an FFmpeg log callback that also receives the
+// name of the logging component, replacing any SetLogCallback callback.
+// cb is a purego callback with the signature
+// func(avcl uintptr, level int32, component, msg *byte).
+func SetLogComponentCallback(cb uintptr) error {
+	if !loaded {
+		return fmt.Errorf("%w: SetLogComponentCallback requires shim; %s", ErrShimNotLoaded, BuildInstructions())
+	}
+	if shimLogSetCompCB == nil {
+		return errors.New("ffgo: shimLogSetCompCB symbol not available in shim")
+	}
+	shimLogSetCompCB(cb)
+	return nil
+}
+
+// HasLogComponentCallback reports whether the loaded shim supports
+// SetLogComponentCallback.
+func HasLogComponentCallback() bool {
+	return loaded && shimLogSetCompCB != nil
+}
+
 // SetLogLevel sets the FFmpeg log level via the shim.
 func SetLogLevel(level int32) error {
 	if !loaded {
@@ -436,6 +485,39 @@ func AVFrameColorOffsets() (rangeOff, spaceOff, primariesOff, transferOff int32,
 	return r, s, p, t, nil
 }
 
+// AVFrameTimingOffsets returns AVFrame field offsets (bytes) for
+// best_effort_timestamp and duration (pkt_duration before FFmpeg 6).
+func AVFrameTimingOffsets() (bestEffortOff, durationOff int32, err error) {
+	if !loaded {
+		return 0, 0, fmt.Errorf("%w: AVFrameTimingOffsets requires shim", ErrShimNotLoaded)
+	}
+	if shimAVFrameTimingOffs == nil {
+		return 0, 0, errors.New("ffgo: ffshim_avframe_timing_offsets symbol not available in shim")
+	}
+	var b, d int32
+	if ret := shimAVFrameTimingOffs(&b, &d); ret < 0 {
+		return 0, 0, errors.New("ffgo: ffshim_avframe_timing_offsets failed")
+	}
+	return b, d, nil
+}
+
+// HasAVFrameMetadata reports whether AVFrameMetadata is available.
+func HasAVFrameMetadata() bool {
+	return loaded && shimAVFrameMetadata != nil
+}
+
+// AVFrameMetadata returns the AVFrame's metadata dictionary (AVDictionary*),
+// which may be nil.
+func AVFrameMetadata(frame unsafe.Pointer) (unsafe.Pointer, error) {
+	if frame == nil {
+		return nil, nil
+	}
+	if !loaded || shimAVFrameMetadata == nil {
+		return nil, ErrShimNotLoaded
+	}
+	return unsafe.Pointer(shimAVFrameMetadata(uintptr(frame))), nil
+}
+
 func CodecParWidth(par unsafe.Pointer) (int32, error) {
 	if par == nil {
 		return 0, nil
@@ -625,6 +707,28 @@ func CodecCtxSetFramerate(ctx unsafe.Pointer, num, den int32) error {
 	return nil
 }
 
+func CodecCtxSampleAspectRatio(ctx unsafe.Pointer) (num, den int32, err error) {
+	if ctx == nil {
+		return 0, 0, nil
+	}
+	if !loaded || shimCodecCtxSAR == nil {
+		return 0, 0, ErrShimNotLoaded
+	}
+	shimCodecCtxSAR(uintptr(ctx), &num, &den)
+	return num, den, nil
+}
+
+func CodecCtxSetSampleAspectRatio(ctx unsafe.Pointer, num, den int32) error {
+	if ctx == nil {
+		return nil
+	}
+	if !loaded || shimCodecCtxSetSAR == nil {
+		return ErrShimNotLoaded
+	}
+	shimCodecCtxSetSAR(uintptr(ctx), num, den)
+	return nil
+}
+
 func CodecCtxHWDeviceCtx(ctx unsafe.Pointer) (unsafe.Pointer, error) {
 	if ctx == nil {
 		return nil, nil
@@ -667,6 +771,47 @@ func CodecCtxSetHWFramesCtx(ctx unsafe.Pointer, ref unsafe.Pointer) error {
 	return nil
 }
 
+// CodecCtxSetHWGetFormat installs a get_format callback on a decoder
+// context that selects hwPixFmt, failing decoder setup if the codec does not
+// offer it. The callback keeps its state in AVCodecContext.opaque.
+func CodecCtxSetHWGetFormat(ctx unsafe.Pointer, hwPixFmt int32) error {
+	if ctx == nil {
+		return nil
+	}
+	if !loaded || shimCodecCtxSetHWGetFormat == nil {
+		return ErrShimNotLoaded
+	}
+	shimCodecCtxSetHWGetFormat(uintptr(ctx), hwPixFmt)
+	return nil
+}
+
+// HWFramesCtxSetParams sets the format, sw_format, width, height and
+// initial_pool_size fields of the AVHWFramesContext referenced by ref
+// (an AVBufferRef from av_hwframe_ctx_alloc).
+func HWFramesCtxSetParams(ref unsafe.Pointer, format, swFormat, width, height, initialPoolSize int32) error {
+	if ref == nil {
+		return nil
+	}
+	if !loaded || shimHWFramesCtxSetParams == nil {
+		return ErrShimNotLoaded
+	}
+	shimHWFramesCtxSetParams(uintptr(ref), format, swFormat, width, height, initialPoolSize)
+	return nil
+}
+
+// HWFramesCtxParams reads back the fields set by HWFramesCtxSetParams and
+// returns the frames context's AVHWDeviceContext.
+func HWFramesCtxParams(ref unsafe.Pointer) (device unsafe.Pointer, format, swFormat, width, height, initialPoolSize int32, err error) {
+	if ref == nil {
+		return nil, 0, 0, 0, 0, 0, nil
+	}
+	if !loaded || shimHWFramesCtxParams == nil {
+		return nil, 0, 0, 0, 0, 0, ErrShimNotLoaded
+	}
+	device = unsafe.Pointer(shimHWFramesCtxParams(uintptr(ref), &format, &swFormat, &width, &height, &initialPoolSize))
+	return device, format, swFormat, width, height, initialPoolSize, nil
+}
+
 func FormatCtxDuration(ctx unsafe.Pointer) (int64, error) {
 	if ctx == nil {
 		return 0, nil
@@ -727,6 +872,32 @@ func FormatCtxProgram(ctx unsafe.Pointer, index int) (unsafe.Pointer, error) {
 	return unsafe.Pointer(shimFormatCtxProgram(uintptr(ctx), int32(index))), nil
 }
 
+// FormatCtxSetInterruptFlag installs an interrupt callback on ctx that
+// aborts blocking I/O once the int pointed to by flag is non-zero. flag must
+// point to C memory; a nil flag removes the callback.
+func FormatCtxSetInterruptFlag(ctx unsafe.Pointer, flag unsafe.Pointer) error {
+	if ctx == nil {
+		return nil
+	}
+	if !loaded || shimFormatCtxSetIntFlag == nil {
+		return ErrShimNotLoaded
+	}
+	shimFormatCtxSetIntFlag(uintptr(ctx), uintptr(flag))
+	return nil
+}
+
+// StreamDisplayMatrix returns a pointer to the stream's nine-element
+// display matrix side data, or nil if the stream has none.
+func StreamDisplayMatrix(stream unsafe.Pointer) (unsafe.Pointer, error) {
+	if stream == nil {
+		return nil, nil
+	}
+	if !loaded || shimStreamDisplayMatrix == nil {
+		return nil, ErrShimNotLoaded
+	}
+	return unsafe.Pointer(shimStreamDisplayMatrix(uintptr(stream))), nil
+}
+
 func ChapterID(ch unsafe.Pointer) (int64, error) {
 	if ch == nil {
 		return 0, nil
@@ -176,6 +176,30 @@ func TestSetLogCallback_WithoutShim(t *testing.T) {
 	}
 }
 
+func TestSetLogComponentCallback_WithoutShim(t *testing.T) {
+	loadMu.Lock()
+	wasLoaded := loaded
+	loaded = false
+	loadMu.Unlock()
+
+	defer func() {
+		loadMu.Lock()
+		loaded = wasLoaded
+		loadMu.Unlock()
+	}()
+
+	if HasLogComponentCallback() {
+		t.Error("HasLogComponentCallback should be false when shim is not loaded")
+	}
+	err := SetLogComponentCallback(0)
+	if err == nil {
+		t.Error("SetLogComponentCallback should fail when shim is not loaded")
+	}
+	if !strings.Contains(err.Error(), "shim") {
+		t.Errorf("error should mention shim: %v", err)
+	}
+}
+
 func TestSetLogLevel_WithoutShim(t *testing.T) {
 	loadMu.Lock()
 	wasLoaded := loaded
@@ -266,6 +290,23 @@ func TestAVFrameColorOffsets_WithoutShim(t *testing.T) {
 	}
 }
 
+func TestAVFrameTimingOffsets_WithoutShim(t *testing.T) {
+	loadMu.Lock()
+	wasLoaded := loaded
+	loaded = false
+	loadMu.Unlock()
+
+	defer func() {
+		loadMu.Lock()
+		loaded = wasLoaded
+		loadMu.Unlock()
+	}()
+
+	if _, _, err := AVFrameTimingOffsets(); err == nil {
+		t.Error("AVFrameTimingOffsets should fail when shim is not loaded")
+	}
+}
+
 // Integration test - only runs if shim is available
 func TestLoad_Integration(t *testing.T) {
 	if testing.Short() {
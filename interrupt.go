@@ -0,0 +1,198 @@
+//go:build !ios && !android && (amd64 || arm64)
+
+package ffgo
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"unsafe"
+
+	"github.com/obinnaokechukwu/ffgo/avformat"
+	"github.com/obinnaokechukwu/ffgo/avutil"
+)
+
+// decoderInterrupt ties a context to the AVIOInterruptCB of a decoder's
+// format context. FFmpeg polls flag (C memory) from blocking I/O; the
+// context's AfterFunc sets it.
+type decoderInterrupt struct {
+	mu   sync.Mutex
+	flag unsafe.Pointer // *int32 allocated with av_malloc
+	ctx  context.Context
+	stop func() bool
+	gen  uint64
+}
+
+// SetInterruptContext makes blocking I/O on the decoder's input abort when
+// ctx is cancelled or its deadline passes, so a stalled network read in
+// ReadPacket, ReadFrame, DecodeVideo and friends returns instead of hanging.
+// The returned error wraps ctx.Err(), so errors.Is(err,
+// context.DeadlineExceeded) identifies a timeout.
+//
+// avformat_open_input copies the interrupt callback into the I/O contexts
+// it creates, so decoders install a cleared interrupt flag before opening
+// whether or not WithInterruptContext was given; SetInterruptContext only
+// arms it.
+//
+// Cancelling ctx does not need the decoder's lock, so it works while
+// another goroutine is blocked in a read. A nil ctx removes the interrupt.
+// After an interrupted read the input is usually left in an error state,
+// so close the decoder and reopen it rather than installing a new context.
+//
+// Without the ffshim library this is only supported on FFmpeg 6 (avformat
+// 60); other versions return an error.
+func (d *Decoder) SetInterruptContext(ctx context.Context) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if d.closed {
+		return errors.New("ffgo: decoder is closed")
+	}
+	if d.formatCtx == nil {
+		return errors.New("ffgo: decoder has no input")
+	}
+
+	in := &d.interrupt
+	in.mu.Lock()
+	defer in.mu.Unlock()
+
+	if in.flag == nil {
+		// The flag is installed before opening when supported; adding it
+		// now would not reach the I/O contexts already open.
+		if ctx == nil {
+			return nil
+		}
+		if err := interruptSupported(); err != nil {
+			return err
+		}
+		return errors.New("ffgo: decoder was opened without an interrupt callback")
+	}
+
+	in.resetLocked()
+	if ctx == nil {
+		// Leave the (now clear) flag installed: the I/O contexts opened
+		// with it keep polling it until the input is closed.
+		atomic.StoreInt32((*int32)(in.flag), 0)
+		return nil
+	}
+	return in.watchLocked(ctx)
+}
+
+var (
+	interruptSupportOnce sync.Once
+	interruptSupportErr  error
+)
+
+// interruptSupported reports whether an interrupt callback can be installed
+// with the loaded FFmpeg, by trying it on a scratch format context.
+func interruptSupported() error {
+	interruptSupportOnce.Do(func() {
+		ctx := avformat.AllocContext()
+		if ctx == nil {
+			interruptSupportErr = ErrOutOfMemory
+			return
+		}
+		defer avformat.FreeContext(ctx)
+		interruptSupportErr = avformat.SetInterruptFlag(ctx, nil)
+	})
+	return interruptSupportErr
+}
+
+// prepare allocates a cleared flag with no context attached, for a decoder
+// opened without one, so SetInterruptContext can arm it later. It returns
+// nil if the interrupt callback is unsupported.
+func (in *decoderInterrupt) prepare() (unsafe.Pointer, error) {
+	if interruptSupported() != nil {
+		return nil, nil
+	}
+	in.mu.Lock()
+	defer in.mu.Unlock()
+
+	in.resetLocked()
+	if in.flag == nil {
+		in.flag = avutil.Malloc(unsafe.Sizeof(int32(0)))
+		if in.flag == nil {
+			return nil, ErrOutOfMemory
+		}
+	}
+	atomic.StoreInt32((*int32)(in.flag), 0)
+	return in.flag, nil
+}
+
+// arm ties the interrupt to ctx ahead of opening the input and returns the
+// flag to install on the format context passed to avformat_open_input.
+func (in *decoderInterrupt) arm(ctx context.Context) (unsafe.Pointer, error) {
+	in.mu.Lock()
+	defer in.mu.Unlock()
+
+	in.resetLocked()
+	if err := in.watchLocked(ctx); err != nil {
+		return nil, err
+	}
+	return in.flag, nil
+}
+
+// watchLocked allocates the flag if needed, clears it and arranges for ctx
+// to set it. in.mu must be held.
+func (in *decoderInterrupt) watchLocked(ctx context.Context) error {
+	if in.flag == nil {
+		in.flag = avutil.Malloc(unsafe.Sizeof(int32(0)))
+		if in.flag == nil {
+			return ErrOutOfMemory
+		}
+	}
+	atomic.StoreInt32((*int32)(in.flag), 0)
+
+	gen := in.gen
+	in.ctx = ctx
+	in.stop = context.AfterFunc(ctx, func() { in.fire(gen) })
+	return nil
+}
+
+// fire sets the flag if the context that scheduled it is still installed.
+func (in *decoderInterrupt) fire(gen uint64) {
+	in.mu.Lock()
+	defer in.mu.Unlock()
+	if in.gen == gen && in.flag != nil {
+		atomic.StoreInt32((*int32)(in.flag), 1)
+	}
+}
+
+// resetLocked detaches the current context, if any. A pending AfterFunc
+// that already started is made a no-op by bumping gen.
+func (in *decoderInterrupt) resetLocked() {
+	if in.stop != nil {
+		in.stop()
+		in.stop = nil
+	}
+	in.ctx = nil
+	in.gen++
+}
+
+// err maps a read error caused by the interrupt to one wrapping the
+// context's error. Other errors are returned unchanged.
+func (in *decoderInterrupt) err(err error) error {
+	if err == nil {
+		return nil
+	}
+	in.mu.Lock()
+	defer in.mu.Unlock()
+	if in.ctx == nil || in.flag == nil || atomic.LoadInt32((*int32)(in.flag)) == 0 {
+		return err
+	}
+	return fmt.Errorf("ffgo: read interrupted: %w (%v)", in.ctx.Err(), err)
+}
+
+// free detaches the context and releases the flag. It must be called after
+// the format context is closed, since FFmpeg may poll the flag until then.
+func (in *decoderInterrupt) free() {
+	in.mu.Lock()
+	defer in.mu.Unlock()
+	in.resetLocked()
+	if in.flag != nil {
+		avutil.Free(in.flag)
+		in.flag = nil
+	}
+}
@@ -0,0 +1,242 @@
+//go:build !ios && !android && (amd64 || arm64)
+
+package ffgo
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// stalledServer accepts TCP connections, sends an optional prefix and then
+// never sends another byte, so reads past the prefix block until the
+// connection is closed.
+type stalledServer struct {
+	ln    net.Listener
+	mu    sync.Mutex
+	conns []net.Conn
+}
+
+func newStalledServer(t *testing.T) *stalledServer {
+	t.Helper()
+	return newStalledServerWithPrefix(t, nil)
+}
+
+func newStalledServerWithPrefix(t *testing.T, prefix []byte) *stalledServer {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Skipf("cannot listen on loopback: %v", err)
+	}
+	s := &stalledServer{ln: ln}
+	go func() {
+		for {
+			c, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			s.mu.Lock()
+			s.conns = append(s.conns, c)
+			s.mu.Unlock()
+			if len(prefix) > 0 {
+				go c.Write(prefix)
+			}
+		}
+	}()
+	// Closing the connections unblocks FFmpeg if the interrupt failed.
+	t.Cleanup(func() {
+		ln.Close()
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		for _, c := range s.conns {
+			c.Close()
+		}
+	})
+	return s
+}
+
+func (s *stalledServer) url() string {
+	return "tcp://" + s.ln.Addr().String()
+}
+
+func skipIfInterruptUnsupported(t *testing.T, err error) {
+	t.Helper()
+	if err != nil && strings.Contains(err.Error(), "requires the ffshim library") {
+		t.Skipf("interrupt callback unavailable: %v", err)
+	}
+}
+
+func TestDecoderInterruptContextAbortsStalledOpen(t *testing.T) {
+	if !requireFFmpeg(t) {
+		return
+	}
+	srv := newStalledServer(t)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	time.AfterFunc(200*time.Millisecond, cancel)
+
+	done := make(chan error, 1)
+	go func() {
+		dec, err := NewDecoder(srv.url(), WithInterruptContext(ctx))
+		if dec != nil {
+			dec.Close()
+		}
+		done <- err
+	}()
+
+	select {
+	case err := <-done:
+		skipIfInterruptUnsupported(t, err)
+		if !errors.Is(err, context.Canceled) {
+			t.Fatalf("expected context.Canceled, got %v", err)
+		}
+	case <-time.After(10 * time.Second):
+		t.Fatal("opening a stalled input was not interrupted")
+	}
+}
+
+// y4mPrefix returns a YUV4MPEG2 header followed by n tiny gray frames.
+func y4mPrefix(n int) []byte {
+	const w, h = 16, 16
+	buf := []byte(fmt.Sprintf("YUV4MPEG2 W%d H%d F25:1 Ip A1:1 C420jpeg\n", w, h))
+	frame := bytes.Repeat([]byte{128}, w*h*3/2)
+	for i := 0; i < n; i++ {
+		buf = append(buf, "FRAME\n"...)
+		buf = append(buf, frame...)
+	}
+	return buf
+}
+
+func TestDecoderSetInterruptContextAbortsStalledRead(t *testing.T) {
+	if !requireFFmpeg(t) {
+		return
+	}
+	srv := newStalledServerWithPrefix(t, y4mPrefix(30))
+
+	// Opened without WithInterruptContext: the context set afterwards must
+	// still reach the already open connection.
+	opened := make(chan *Decoder, 1)
+	go func() {
+		dec, err := NewDecoder(srv.url(),
+			WithFormat("yuv4mpegpipe"),
+			WithAVOptions(map[string]string{"analyzeduration": "100000"}))
+		if err != nil {
+			t.Errorf("NewDecoder failed: %v", err)
+		}
+		opened <- dec
+	}()
+	var dec *Decoder
+	select {
+	case dec = <-opened:
+		if dec == nil {
+			return
+		}
+	case <-time.After(10 * time.Second):
+		t.Fatal("opening the stream prefix did not complete")
+	}
+	defer dec.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+	defer cancel()
+	err := dec.SetInterruptContext(ctx)
+	skipIfInterruptUnsupported(t, err)
+	if err != nil {
+		t.Fatalf("SetInterruptContext failed: %v", err)
+	}
+
+	done := make(chan error, 1)
+	go func() {
+		for {
+			if _, err := dec.ReadPacket(); err != nil {
+				done <- err
+				return
+			}
+		}
+	}()
+
+	select {
+	case err := <-done:
+		if !errors.Is(err, context.DeadlineExceeded) {
+			t.Fatalf("expected context.DeadlineExceeded, got %v", err)
+		}
+	case <-time.After(10 * time.Second):
+		t.Fatal("a stalled read was not interrupted")
+	}
+}
+
+func TestDecoderInterruptContextDeadline(t *testing.T) {
+	if !requireFFmpeg(t) {
+		return
+	}
+	srv := newStalledServer(t)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+	defer cancel()
+
+	done := make(chan error, 1)
+	go func() {
+		_, err := Probe(srv.url(), &DecoderOptions{InterruptContext: ctx})
+		done <- err
+	}()
+
+	select {
+	case err := <-done:
+		skipIfInterruptUnsupported(t, err)
+		if !errors.Is(err, context.DeadlineExceeded) {
+			t.Fatalf("expected context.DeadlineExceeded, got %v", err)
+		}
+	case <-time.After(10 * time.Second):
+		t.Fatal("probing a stalled input was not interrupted")
+	}
+}
+
+func TestDecoderInterruptContextLive(t *testing.T) {
+	if !requireFFmpeg(t) {
+		return
+	}
+
+	// A live context must not disturb normal reads.
+	dec, err := NewDecoder(createTestVideo(t), WithInterruptContext(context.Background()))
+	skipIfInterruptUnsupported(t, err)
+	if err != nil {
+		t.Fatalf("NewDecoder failed: %v", err)
+	}
+	defer dec.Close()
+
+	if pkt, err := dec.ReadPacket(); err != nil || pkt == nil {
+		t.Fatalf("ReadPacket with live context: pkt=%v err=%v", pkt, err)
+	}
+	if err := dec.SetInterruptContext(nil); err != nil {
+		t.Fatalf("SetInterruptContext(nil) failed: %v", err)
+	}
+	if pkt, err := dec.ReadPacket(); err != nil || pkt == nil {
+		t.Fatalf("ReadPacket after removing context: pkt=%v err=%v", pkt, err)
+	}
+}
+
+func TestDecoderSetInterruptContextClosed(t *testing.T) {
+	if !requireFFmpeg(t) {
+		return
+	}
+
+	dec, err := NewDecoder(createTestVideo(t))
+	if err != nil {
+		t.Fatalf("NewDecoder failed: %v", err)
+	}
+	if err := dec.SetInterruptContext(context.Background()); err != nil {
+		t.Skipf("interrupt callback unavailable: %v", err)
+	}
+	if err := dec.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+	if err := dec.SetInterruptContext(context.Background()); err == nil {
+		t.Fatalf("expected error on closed decoder")
+	}
+}
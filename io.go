@@ -422,6 +422,139 @@ func NewDecoderFromReaderWithOptions(r io.Reader, opts *DecoderOptions) (*Decode
 	return NewDecoderFromIOWithOptions(callbacks, opts)
 }
 
+// ErrSeekBufferExceeded is returned by a seekable decoder's reader when the
+// source stream grows beyond the configured maxBuffer.
+var ErrSeekBufferExceeded = errors.New("ffgo: stream exceeds seek buffer limit")
+
+// NewSeekableDecoder creates a decoder from a non-seekable io.Reader by
+// buffering everything read so far in memory, so the demuxer can seek back
+// (and forward, by reading ahead) as needed. This makes formats such as MP4
+// with the moov atom at the end usable from pipes and network bodies.
+//
+// maxBuffer caps how many bytes are kept; reads or seeks that would exceed
+// it fail with ErrSeekBufferExceeded. A maxBuffer <= 0 means no limit.
+// format is the format hint (e.g., "mp4", "mkv") - can be empty for auto-detection.
+func NewSeekableDecoder(r io.Reader, maxBuffer int, format string) (*Decoder, error) {
+	if r == nil {
+		return nil, errors.New("ffgo: reader cannot be nil")
+	}
+
+	br := &bufferedSeekReader{src: r, max: maxBuffer}
+	callbacks := &IOCallbacks{
+		Read: br.Read,
+		Seek: br.Seek,
+	}
+
+	return NewDecoderFromIO(callbacks, format)
+}
+
+// bufferedSeekReader adapts an io.Reader to io.ReadSeeker by retaining all
+// bytes read from the source in a growable buffer.
+type bufferedSeekReader struct {
+	src    io.Reader
+	buf    []byte
+	pos    int64
+	max    int
+	srcEOF bool
+	srcErr error
+}
+
+// fill reads from the source until the buffer holds at least n bytes or the
+// source is exhausted.
+func (b *bufferedSeekReader) fill(n int64) error {
+	for int64(len(b.buf)) < n && !b.srcEOF {
+		if b.srcErr != nil {
+			return b.srcErr
+		}
+		if b.max > 0 && len(b.buf) >= b.max {
+			if err := b.probeEnd(); err != nil {
+				return err
+			}
+			continue
+		}
+
+		chunk := defaultIOBufferSize
+		if b.max > 0 && len(b.buf)+chunk > b.max {
+			chunk = b.max - len(b.buf)
+		}
+		if cap(b.buf)-len(b.buf) < chunk {
+			grown := make([]byte, len(b.buf), 2*cap(b.buf)+chunk)
+			copy(grown, b.buf)
+			b.buf = grown
+		}
+
+		m, err := b.src.Read(b.buf[len(b.buf) : len(b.buf)+chunk])
+		b.buf = b.buf[:len(b.buf)+m]
+		if err == io.EOF {
+			b.srcEOF = true
+		} else if err != nil {
+			b.srcErr = err
+		}
+	}
+	return nil
+}
+
+// probeEnd is called with a full buffer. It reads one more byte to tell a
+// source that ends exactly at the limit, which sets srcEOF, from one that
+// exceeds it, which fails with ErrSeekBufferExceeded.
+func (b *bufferedSeekReader) probeEnd() error {
+	var one [1]byte
+	for {
+		m, err := b.src.Read(one[:])
+		switch {
+		case m > 0:
+			b.srcErr = ErrSeekBufferExceeded
+			return b.srcErr
+		case err == io.EOF:
+			b.srcEOF = true
+			return nil
+		case err != nil:
+			b.srcErr = err
+			return err
+		}
+	}
+}
+
+func (b *bufferedSeekReader) Read(p []byte) (int, error) {
+	if err := b.fill(b.pos + int64(len(p))); err != nil && b.pos >= int64(len(b.buf)) {
+		return 0, err
+	}
+	if b.pos >= int64(len(b.buf)) {
+		return 0, io.EOF
+	}
+	n := copy(p, b.buf[b.pos:])
+	b.pos += int64(n)
+	return n, nil
+}
+
+func (b *bufferedSeekReader) Seek(offset int64, whence int) (int64, error) {
+	var target int64
+	switch whence {
+	case io.SeekStart:
+		target = offset
+	case io.SeekCurrent:
+		target = b.pos + offset
+	case io.SeekEnd:
+		// The size is only known once the whole source has been buffered.
+		for !b.srcEOF {
+			if err := b.fill(int64(len(b.buf)) + defaultIOBufferSize); err != nil {
+				return 0, err
+			}
+		}
+		target = int64(len(b.buf)) + offset
+	default:
+		return 0, errors.New("ffgo: invalid whence")
+	}
+	if target < 0 {
+		return 0, errors.New("ffgo: negative seek position")
+	}
+	if err := b.fill(target); err != nil {
+		return 0, err
+	}
+	b.pos = target
+	return target, nil
+}
+
 // NewEncoderToWriter creates an encoder that writes to an io.Writer.
 // If w implements io.Seeker, seeking will be supported.
 // format is the output format (e.g., "mp4", "mkv", "avi").
@@ -447,47 +580,80 @@ func NewEncoderToWriter(w io.Writer, format string, config EncoderConfig) (*Enco
 }
 
 // NewEncoderToWriterWithOptions creates an encoder that writes to an io.Writer
-// using the EncoderOptions configuration.
-// If w implements io.Seeker, seeking will be supported.
-// format is the output format (e.g., "mp4", "mkv", "avi").
+// using the full EncoderOptions configuration (video, audio, stream copy).
+// If w implements io.Seeker, seeking will be supported; otherwise the muxer
+// sees a non-seekable output (see NewEncoderToIO).
+// format is the output format (e.g., "mp4", "matroska", "mpegts"); if empty,
+// opts.Format is used.
 func NewEncoderToWriterWithOptions(w io.Writer, format string, opts *EncoderOptions) (*Encoder, error) {
-	if opts == nil || opts.Video == nil {
-		return nil, errors.New("ffgo: EncoderOptions.Video is required")
+	if w == nil {
+		return nil, errors.New("ffgo: writer cannot be nil")
+	}
+	if opts == nil {
+		return nil, errors.New("ffgo: EncoderOptions is required")
 	}
 
-	video := opts.Video
-
-	// Convert VideoEncoderConfig to EncoderConfig
-	cfg := EncoderConfig{
-		Width:       video.Width,
-		Height:      video.Height,
-		PixelFormat: video.PixelFormat,
-		CodecID:     video.Codec,
-		BitRate:     video.Bitrate,
-		GOPSize:     video.GOPSize,
-		MaxBFrames:  video.MaxBFrames,
+	callbacks := &IOCallbacks{
+		Write: func(buf []byte) (int, error) {
+			return w.Write(buf)
+		},
 	}
 
-	// Handle frame rate
-	if video.FrameRate.Den > 0 {
-		cfg.FrameRate = int(video.FrameRate.Num / video.FrameRate.Den)
+	if seeker, ok := w.(io.Seeker); ok {
+		callbacks.Seek = func(offset int64, whence int) (int64, error) {
+			return seeker.Seek(offset, whence)
+		}
 	}
-	if cfg.FrameRate <= 0 {
-		cfg.FrameRate = 30
+
+	if format != "" {
+		o := *opts
+		o.Format = format
+		opts = &o
 	}
 
-	// Apply defaults
-	if cfg.CodecID == CodecIDNone {
-		cfg.CodecID = CodecIDH264
+	return NewEncoderToIO(callbacks, opts)
+}
+
+// NewEncoderToIO creates an encoder that writes muxed output through custom
+// I/O callbacks, using the full EncoderOptions configuration.
+//
+// callbacks.Write is required. callbacks.Seek is optional: when nil the
+// output is non-seekable, so muxers that rewrite earlier data on close
+// (e.g. MP4 without fragmentation) will fail. For MP4 to a non-seekable
+// sink, set MuxerOptions["movflags"] = "frag_keyframe+empty_moov".
+//
+// opts.Format must name the output format since there is no filename to
+// guess it from. The returned encoder owns the I/O context and releases it
+// on Close, after the trailer has been written.
+func NewEncoderToIO(callbacks *IOCallbacks, opts *EncoderOptions) (*Encoder, error) {
+	if opts == nil {
+		return nil, errors.New("ffgo: EncoderOptions is required")
+	}
+	if opts.Format == "" {
+		return nil, errors.New("ffgo: EncoderOptions.Format is required for custom I/O")
 	}
-	if cfg.BitRate <= 0 {
-		cfg.BitRate = 2000000
+
+	ioCtx, err := NewCustomIOContext(callbacks, true)
+	if err != nil {
+		return nil, err
 	}
-	if cfg.PixelFormat == PixelFormatNone {
-		cfg.PixelFormat = PixelFormatYUV420P
+
+	o := *opts
+	o.customIO = ioCtx
+	e, err := NewEncoderWithOptions("", &o)
+	if err != nil {
+		ioCtx.Close()
+		return nil, err
 	}
+	return e, nil
+}
 
-	return NewEncoderToWriter(w, format, cfg)
+// attachCustomIO makes e write through ioCtx. The encoder takes ownership of
+// ioCtx and closes it in cleanup.
+func (e *Encoder) attachCustomIO(ioCtx *CustomIOContext) {
+	e.customIO = ioCtx
+	avformat.SetIOContext(e.formatCtx, ioCtx.AVIOContext())
+	avformat.AddFlags(e.formatCtx, avformat.AVFMT_FLAG_CUSTOM_IO)
 }
 
 // NewEncoderFromIO creates an encoder with custom I/O.
@@ -516,8 +682,9 @@ func NewEncoderFromIO(callbacks *IOCallbacks, format string, config EncoderConfi
 		return nil, errors.New("ffgo: failed to allocate output context")
 	}
 
-	// Set custom I/O
+	// Set custom I/O and tell FFmpeg we own the I/O context
 	avformat.SetIOContext(formatCtx, ioCtx.AVIOContext())
+	avformat.AddFlags(formatCtx, avformat.AVFMT_FLAG_CUSTOM_IO)
 
 	// Create a new stream in the output container
 	stream := avformat.NewStream(formatCtx, nil)
@@ -630,5 +797,6 @@ func NewEncoderFromIO(callbacks *IOCallbacks, format string, config EncoderConfi
 		timeBaseNum:   1,
 		timeBaseDen:   int32(frameRate),
 		headerWritten: true, // Header was already written above
+		customIO:      ioCtx,
 	}, nil
 }
@@ -3,10 +3,15 @@
 package ffgo
 
 import (
+	"context"
+	"fmt"
+	"log/slog"
+	"os"
 	"sync"
 	"unsafe"
 
 	"github.com/ebitengine/purego"
+	"github.com/obinnaokechukwu/ffgo/avutil"
 	"github.com/obinnaokechukwu/ffgo/internal/shim"
 )
 
@@ -54,10 +59,18 @@ func (l LogLevel) String() string {
 // level is the log level, message is the formatted message.
 type LogCallback func(level LogLevel, message string)
 
+// LogHandler is called for each FFmpeg log message with the name of the
+// component that logged it, e.g. "h264" for a decoder or
+// "mov,mp4,m4a,3gp,3g2,mj2" for a demuxer, or "" for messages logged without
+// a context.
+type LogHandler func(level LogLevel, component, message string)
+
 var (
-	logCallbackMu sync.Mutex
-	logCallback   LogCallback
-	logCBHandle   uintptr
+	logCallbackMu   sync.Mutex
+	logCallback     LogCallback
+	logHandler      LogHandler
+	logCBHandle     uintptr
+	logCompCBHandle uintptr
 )
 
 // SetLogLevel sets the FFmpeg log level.
@@ -81,52 +94,182 @@ func SetLogCallback(cb LogCallback) error {
 	logCallbackMu.Lock()
 	defer logCallbackMu.Unlock()
 
-	if cb == nil {
-		// Restore default callback
-		logCallback = nil
-		return shim.SetLogCallback(0)
+	logHandler = nil
+	logCallback = cb
+	return installLogCallbackLocked()
+}
+
+// SetLogHandler sets a handler for FFmpeg messages that also receives the
+// name of the component that logged each one. It replaces any callback set
+// with SetLogCallback; pass nil to restore the default logging behavior.
+// This requires the ffshim library to be available. With an older shim the
+// component is the FFmpeg class name (e.g. "AVCodecContext") instead.
+func SetLogHandler(h LogHandler) error {
+	if err := shim.Load(); err != nil {
+		return err
 	}
 
-	logCallback = cb
+	logCallbackMu.Lock()
+	defer logCallbackMu.Unlock()
 
-	// Create a purego callback if we haven't yet
+	logCallback = nil
+	logHandler = h
+	return installLogCallbackLocked()
+}
+
+// installLogCallbackLocked points FFmpeg's log callback at the Go
+// trampolines while a callback, handler or decoder log capture needs the
+// messages, and restores FFmpeg's default callback otherwise. Must be
+// called with logCallbackMu held.
+func installLogCallbackLocked() error {
+	if logCallback == nil && logHandler == nil && !logCaptureActive() {
+		return shim.SetLogCallback(0)
+	}
+	if shim.HasLogComponentCallback() {
+		if logCompCBHandle == 0 {
+			logCompCBHandle = purego.NewCallback(logComponentTrampoline)
+		}
+		return shim.SetLogComponentCallback(logCompCBHandle)
+	}
 	if logCBHandle == 0 {
 		logCBHandle = purego.NewCallback(logCallbackTrampoline)
 	}
-
 	return shim.SetLogCallback(logCBHandle)
 }
 
-// logCallbackTrampoline is called by the shim and forwards to the Go callback.
+// logCallbackTrampoline is called by the shim and forwards to dispatchLog.
 // Signature: void (*)(void *avcl, int level, const char *msg)
-func logCallbackTrampoline(_ purego.CDecl, _ uintptr, level int32, msg *byte) {
-	logCallbackMu.Lock()
-	cb := logCallback
-	logCallbackMu.Unlock()
+func logCallbackTrampoline(_ purego.CDecl, avcl unsafe.Pointer, level int32, msg *byte) {
+	dispatchLog(avcl, parseLogLevel(level), avClassName(avcl), goString(unsafe.Pointer(msg)))
+}
 
-	if cb == nil {
+// logComponentTrampoline is called by the shim and forwards to dispatchLog.
+// Signature: void (*)(void *avcl, int level, const char *component, const char *msg)
+func logComponentTrampoline(_ purego.CDecl, avcl unsafe.Pointer, level int32, component, msg *byte) {
+	dispatchLog(avcl, parseLogLevel(level), goString(unsafe.Pointer(component)), goString(unsafe.Pointer(msg)))
+}
+
+// dispatchLog records a message for the decoder capturing avcl's log, if
+// any, and passes it to the handler or callback. Without either, the
+// callback is installed only for capturing, so the message is printed to
+// stderr as FFmpeg's default callback would.
+//
+// FFmpeg hands every message to a custom callback whatever the log level,
+// so messages above the level set with SetLogLevel are dropped here.
+func dispatchLog(avcl unsafe.Pointer, level LogLevel, component, msg string) {
+	if c := capturedLog(avcl); c != nil {
+		c.record(level, msg)
+	}
+	if level > LogLevel(avutil.LogGetLevel()) {
 		return
 	}
 
-	// Convert C string to Go string
-	goMsg := ""
-	if msg != nil {
-		// Find the length
-		ptr := unsafe.Pointer(msg)
-		for i := 0; ; i++ {
-			b := *(*byte)(unsafe.Pointer(uintptr(ptr) + uintptr(i)))
-			if b == 0 {
-				goMsg = string(unsafe.Slice(msg, i))
-				break
-			}
-			if i > 4096 { // Safety limit
-				goMsg = string(unsafe.Slice(msg, i))
-				break
-			}
+	logCallbackMu.Lock()
+	cb, h := logCallback, logHandler
+	logCallbackMu.Unlock()
+
+	switch {
+	case h != nil:
+		h(level, component, msg)
+	case cb != nil:
+		cb(level, msg)
+	default:
+		if component != "" {
+			fmt.Fprintf(os.Stderr, "[%s @ %p] %s\n", component, avcl, msg)
+		} else {
+			fmt.Fprintln(os.Stderr, msg)
 		}
 	}
+}
+
+// parseLogLevel strips the color bits (AV_LOG_C) FFmpeg may add to a
+// message's level.
+func parseLogLevel(level int32) LogLevel {
+	if level < 0 {
+		return LogLevel(level)
+	}
+	return LogLevel(level & 0xff)
+}
+
+// avClassName returns the class name of an FFmpeg logging context, whose
+// first field points to its AVClass, whose first field is the name.
+func avClassName(avcl unsafe.Pointer) string {
+	if avcl == nil {
+		return ""
+	}
+	class := *(*unsafe.Pointer)(avcl)
+	if class == nil {
+		return ""
+	}
+	return goString(*(*unsafe.Pointer)(class))
+}
+
+// SetLogLevelFromSlog sets the FFmpeg log level to pass the messages a
+// logger at the given slog level would record: slog.LevelError maps to
+// LogError, slog.LevelWarn to LogWarning, slog.LevelInfo to LogInfo,
+// slog.LevelDebug to LogDebug and anything lower to LogTrace.
+// This requires the ffshim library to be available.
+func SetLogLevelFromSlog(level slog.Level) error {
+	return SetLogLevel(logLevelFromSlog(level))
+}
+
+// logLevelFromSlog maps a slog level to the FFmpeg level that passes the
+// same messages.
+func logLevelFromSlog(level slog.Level) LogLevel {
+	switch {
+	case level > slog.LevelError:
+		return LogFatal
+	case level > slog.LevelWarn:
+		return LogError
+	case level > slog.LevelInfo:
+		return LogWarning
+	case level > slog.LevelDebug:
+		return LogInfo
+	case level == slog.LevelDebug:
+		return LogDebug
+	default:
+		return LogTrace
+	}
+}
 
-	cb(LogLevel(level), goMsg)
+// slogLevel maps an FFmpeg log level to a slog level: panic, fatal and
+// error to slog.LevelError, warning to slog.LevelWarn, info to
+// slog.LevelInfo, and verbose and below to slog.LevelDebug.
+func slogLevel(level LogLevel) slog.Level {
+	switch {
+	case level <= LogError:
+		return slog.LevelError
+	case level <= LogWarning:
+		return slog.LevelWarn
+	case level <= LogInfo:
+		return slog.LevelInfo
+	case level <= LogDebug:
+		return slog.LevelDebug
+	default:
+		return slog.LevelDebug - 4
+	}
+}
+
+// RedirectLogToSlog sends FFmpeg's log messages to logger instead of
+// stderr, at the slog level matching each message's FFmpeg level and with
+// the logging component as a "component" attribute. Messages less severe
+// than the FFmpeg log level are dropped before they reach logger, so pair
+// it with SetLogLevelFromSlog.
+// Pass nil to restore the default logging behavior.
+// This requires the ffshim library to be available.
+//
+//	ffgo.RedirectLogToSlog(slog.Default())
+//	ffgo.SetLogLevelFromSlog(slog.LevelWarn)
+func RedirectLogToSlog(logger *slog.Logger) error {
+	if logger == nil {
+		return SetLogHandler(nil)
+	}
+	return SetLogHandler(func(level LogLevel, component, message string) {
+		if message == "" {
+			return
+		}
+		logger.Log(context.Background(), slogLevel(level), message, "component", component)
+	})
 }
 
 // IsLoggingAvailable returns true if logging functionality is available.
@@ -0,0 +1,187 @@
+//go:build !ios && !android && (amd64 || arm64)
+
+package ffgo
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"unsafe"
+
+	"github.com/obinnaokechukwu/ffgo/internal/shim"
+)
+
+// logCapture keeps the latest warning or error FFmpeg logged for the
+// contexts of one Decoder or Encoder (DecoderOptions.CaptureLog,
+// EncoderOptions.CaptureLog). Messages are matched to it by the logging
+// context pointer FFmpeg passes to the log callback.
+type logCapture struct {
+	mu   sync.Mutex
+	last string
+	seq  uint64 // number of messages recorded
+	ctxs []unsafe.Pointer
+
+	stopped bool
+}
+
+var (
+	logCapturesMu sync.Mutex
+	logCaptures   = make(map[unsafe.Pointer]*logCapture)
+	logCaptureN   int // live captures; the log callback stays installed while > 0
+)
+
+// startLogCapture installs the log callback and returns a new capture. It
+// requires the ffshim library.
+func startLogCapture() (*logCapture, error) {
+	if err := shim.Load(); err != nil {
+		return nil, err
+	}
+
+	logCapturesMu.Lock()
+	logCaptureN++
+	logCapturesMu.Unlock()
+
+	logCallbackMu.Lock()
+	defer logCallbackMu.Unlock()
+	if err := installLogCallbackLocked(); err != nil {
+		logCapturesMu.Lock()
+		logCaptureN--
+		logCapturesMu.Unlock()
+		return nil, err
+	}
+	return &logCapture{}, nil
+}
+
+// logCaptureActive reports whether any decoder or encoder is capturing its
+// log.
+func logCaptureActive() bool {
+	logCapturesMu.Lock()
+	defer logCapturesMu.Unlock()
+	return logCaptureN > 0
+}
+
+// capturedLog returns the capture watching the logging context avcl, or nil.
+func capturedLog(avcl unsafe.Pointer) *logCapture {
+	if avcl == nil {
+		return nil
+	}
+	logCapturesMu.Lock()
+	defer logCapturesMu.Unlock()
+	return logCaptures[avcl]
+}
+
+// watch attributes messages logged with ctx (an AVFormatContext or
+// AVCodecContext) to c. It is a no-op on a nil capture.
+func (c *logCapture) watch(ctx unsafe.Pointer) {
+	if c == nil || ctx == nil {
+		return
+	}
+	logCapturesMu.Lock()
+	logCaptures[ctx] = c
+	logCapturesMu.Unlock()
+
+	c.mu.Lock()
+	c.ctxs = append(c.ctxs, ctx)
+	c.mu.Unlock()
+}
+
+// unwatch stops attributing messages logged with ctx to c, for a context
+// freed before the capture stops.
+func (c *logCapture) unwatch(ctx unsafe.Pointer) {
+	if c == nil || ctx == nil {
+		return
+	}
+	logCapturesMu.Lock()
+	if logCaptures[ctx] == c {
+		delete(logCaptures, ctx)
+	}
+	logCapturesMu.Unlock()
+
+	c.mu.Lock()
+	for i, p := range c.ctxs {
+		if p == ctx {
+			c.ctxs = append(c.ctxs[:i], c.ctxs[i+1:]...)
+			break
+		}
+	}
+	c.mu.Unlock()
+}
+
+// stop detaches c from its contexts, which must happen before they are
+// freed, and removes the log callback if nothing else needs it. The last
+// message stays available. Calling stop again does nothing.
+func (c *logCapture) stop() {
+	if c == nil {
+		return
+	}
+	c.mu.Lock()
+	if c.stopped {
+		c.mu.Unlock()
+		return
+	}
+	c.stopped = true
+	ctxs := c.ctxs
+	c.ctxs = nil
+	c.mu.Unlock()
+
+	logCapturesMu.Lock()
+	for _, ctx := range ctxs {
+		if logCaptures[ctx] == c {
+			delete(logCaptures, ctx)
+		}
+	}
+	logCaptureN--
+	logCapturesMu.Unlock()
+
+	logCallbackMu.Lock()
+	defer logCallbackMu.Unlock()
+	_ = installLogCallbackLocked()
+}
+
+// record keeps msg if it is a warning or an error.
+func (c *logCapture) record(level LogLevel, msg string) {
+	msg = strings.TrimSpace(msg)
+	if level > LogWarning || msg == "" {
+		return
+	}
+	c.mu.Lock()
+	c.last = msg
+	c.seq++
+	c.mu.Unlock()
+}
+
+// message returns the last recorded message.
+func (c *logCapture) message() string {
+	if c == nil {
+		return ""
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.last
+}
+
+// mark returns a position to pass to annotate after an FFmpeg call.
+func (c *logCapture) mark() uint64 {
+	if c == nil {
+		return 0
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.seq
+}
+
+// annotate appends the last message recorded since mark to err, keeping
+// err in the chain for errors.Is and errors.As. Without such a message err
+// is returned unchanged.
+func (c *logCapture) annotate(err error, mark uint64) error {
+	if c == nil || err == nil {
+		return err
+	}
+	c.mu.Lock()
+	msg, seq := c.last, c.seq
+	c.mu.Unlock()
+	if seq == mark {
+		return err
+	}
+	return fmt.Errorf("%w: %s", err, msg)
+}
@@ -0,0 +1,281 @@
+//go:build !ios && !android && (amd64 || arm64)
+
+package ffgo
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"unsafe"
+
+	"github.com/ebitengine/purego"
+	"github.com/obinnaokechukwu/ffgo/avutil"
+)
+
+func TestParseLogLevel(t *testing.T) {
+	tests := map[int32]LogLevel{
+		int32(LogError):                LogError,
+		int32(LogWarning) | 0x0c00:     LogWarning, // AV_LOG_C(12)
+		int32(LogQuiet):                LogQuiet,
+		int32(LogInfo) | 0x7f<<8 | 0x1: LogLevel(33),
+	}
+	for in, want := range tests {
+		if got := parseLogLevel(in); got != want {
+			t.Errorf("parseLogLevel(%#x) = %v, want %v", in, got, want)
+		}
+	}
+}
+
+func TestSlogLevelMapping(t *testing.T) {
+	toSlog := map[LogLevel]slog.Level{
+		LogPanic:   slog.LevelError,
+		LogFatal:   slog.LevelError,
+		LogError:   slog.LevelError,
+		LogWarning: slog.LevelWarn,
+		LogInfo:    slog.LevelInfo,
+		LogVerbose: slog.LevelDebug,
+		LogDebug:   slog.LevelDebug,
+		LogTrace:   slog.LevelDebug - 4,
+	}
+	for in, want := range toSlog {
+		if got := slogLevel(in); got != want {
+			t.Errorf("slogLevel(%v) = %v, want %v", in, got, want)
+		}
+	}
+
+	fromSlog := map[slog.Level]LogLevel{
+		slog.LevelError + 4: LogFatal,
+		slog.LevelError:     LogError,
+		slog.LevelWarn:      LogWarning,
+		slog.LevelInfo:      LogInfo,
+		slog.LevelDebug:     LogDebug,
+		slog.LevelDebug - 4: LogTrace,
+	}
+	for in, want := range fromSlog {
+		if got := logLevelFromSlog(in); got != want {
+			t.Errorf("logLevelFromSlog(%v) = %v, want %v", in, got, want)
+		}
+		// A message at the slog level must pass the FFmpeg level it maps to.
+		if in >= slog.LevelDebug && in <= slog.LevelError && slogLevel(logLevelFromSlog(in)) < in {
+			t.Errorf("slog level %v maps to FFmpeg %v, which logs below it", in, logLevelFromSlog(in))
+		}
+	}
+}
+
+func TestLogHandlerTrampoline(t *testing.T) {
+	name := []byte("AVCodecContext\x00")
+	class := struct{ className *byte }{&name[0]}
+	ctx := struct{ class unsafe.Pointer }{unsafe.Pointer(&class)}
+	msg := []byte("Invalid NAL unit size\x00")
+
+	var buf bytes.Buffer
+	logger := slog.New(slog.NewTextHandler(&buf, &slog.HandlerOptions{Level: slog.LevelDebug}))
+	logCallbackMu.Lock()
+	logHandler = func(level LogLevel, component, message string) {
+		logger.Log(context.Background(), slogLevel(level), message, "component", component)
+	}
+	logCallbackMu.Unlock()
+	defer func() {
+		logCallbackMu.Lock()
+		logHandler = nil
+		logCallbackMu.Unlock()
+	}()
+
+	logCallbackTrampoline(purego.CDecl{}, unsafe.Pointer(&ctx), int32(LogError), &msg[0])
+	out := buf.String()
+	for _, want := range []string{"level=ERROR", `msg="Invalid NAL unit size"`, "component=AVCodecContext"} {
+		if !strings.Contains(out, want) {
+			t.Errorf("log output %q does not contain %q", out, want)
+		}
+	}
+
+	buf.Reset()
+	logCallbackTrampoline(purego.CDecl{}, nil, int32(LogInfo), &msg[0])
+	if !strings.Contains(buf.String(), "component=\"\"") {
+		t.Errorf("message without context logged as %q, want an empty component", buf.String())
+	}
+}
+
+func TestLogCaptureAnnotate(t *testing.T) {
+	base := avutil.NewError(avutil.AVERROR_INVALIDDATA, "avcodec_send_packet")
+
+	var none *logCapture
+	if err := none.annotate(base, none.mark()); err != base {
+		t.Errorf("nil capture changed the error to %v", err)
+	}
+	if msg := none.message(); msg != "" {
+		t.Errorf("nil capture message = %q", msg)
+	}
+
+	c := &logCapture{}
+	mark := c.mark()
+	c.record(LogInfo, "Stream #0: not relevant")
+	if err := c.annotate(base, mark); err != base {
+		t.Errorf("info message annotated the error: %v", err)
+	}
+
+	c.record(LogError, "Invalid NAL unit size (1234 > 567).\n")
+	err := c.annotate(base, mark)
+	if !strings.HasSuffix(err.Error(), ": Invalid NAL unit size (1234 > 567).") {
+		t.Errorf("annotated error = %q", err)
+	}
+	if !errors.Is(err, base) || !avutil.IsInvalidData(err) {
+		t.Errorf("annotated error %v lost the FFmpeg error", err)
+	}
+	if err := c.annotate(base, c.mark()); err != base {
+		t.Errorf("message logged before the mark annotated the error: %v", err)
+	}
+	if got := c.message(); got != "Invalid NAL unit size (1234 > 567)." {
+		t.Errorf("message = %q", got)
+	}
+}
+
+func TestDispatchLogAttributesContexts(t *testing.T) {
+	var ctxA, ctxB [8]byte
+	a := unsafe.Pointer(&ctxA)
+	b := unsafe.Pointer(&ctxB)
+
+	c := &logCapture{}
+	c.watch(a)
+	defer c.unwatch(a)
+
+	var handled int
+	logCallbackMu.Lock()
+	logHandler = func(LogLevel, string, string) { handled++ }
+	logCallbackMu.Unlock()
+	defer func() {
+		logCallbackMu.Lock()
+		logHandler = nil
+		logCallbackMu.Unlock()
+	}()
+
+	dispatchLog(a, LogWarning, "h264", "mmco: unref short failure")
+	dispatchLog(b, LogError, "hevc", "other decoder")
+	dispatchLog(nil, LogError, "", "no context")
+
+	if got := c.message(); got != "mmco: unref short failure" {
+		t.Errorf("captured %q, want the message logged for the watched context", got)
+	}
+	if handled != 3 {
+		t.Errorf("handler saw %d messages, want 3", handled)
+	}
+
+	c.unwatch(a)
+	dispatchLog(a, LogError, "h264", "after unwatch")
+	if got := c.message(); got == "after unwatch" {
+		t.Error("message logged after unwatch was captured")
+	}
+}
+
+func TestDispatchLogDropsMessagesAboveLevel(t *testing.T) {
+	var got []string
+	logCallbackMu.Lock()
+	logHandler = func(_ LogLevel, _, message string) { got = append(got, message) }
+	logCallbackMu.Unlock()
+	defer func() {
+		logCallbackMu.Lock()
+		logHandler = nil
+		logCallbackMu.Unlock()
+	}()
+
+	level := LogLevel(avutil.LogGetLevel())
+	dispatchLog(nil, level, "", "at level")
+	dispatchLog(nil, level+8, "", "above level")
+	if len(got) != 1 || got[0] != "at level" {
+		t.Errorf("handler saw %q, want only the message at the log level", got)
+	}
+
+	var called bool
+	logCallbackMu.Lock()
+	logHandler = nil
+	logCallback = func(LogLevel, string) { called = true }
+	logCallbackMu.Unlock()
+	defer func() {
+		logCallbackMu.Lock()
+		logCallback = nil
+		logCallbackMu.Unlock()
+	}()
+	dispatchLog(nil, level+8, "", "above level")
+	if called {
+		t.Error("callback saw a message above the log level")
+	}
+}
+
+func TestDecoderLogCapture(t *testing.T) {
+	if !requireFFmpeg(t) {
+		return
+	}
+	if !IsLoggingAvailable() {
+		t.Skip("log capture requires the ffshim library")
+	}
+
+	data, err := os.ReadFile(createTestVideo(t))
+	if err != nil {
+		t.Fatal(err)
+	}
+	mdat := bytes.Index(data, []byte("mdat"))
+	if mdat < 0 {
+		t.Fatal("test video has no mdat box")
+	}
+	// Garble the middle of the media data so the decoder reports errors.
+	for i := mdat + 2048; i < len(data) && i < mdat+6144; i++ {
+		data[i] = 0xA5
+	}
+	path := filepath.Join(t.TempDir(), "corrupt.mp4")
+	if err := os.WriteFile(path, data, 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	dec, err := NewDecoder(path, WithLogCapture())
+	if err != nil {
+		t.Fatalf("NewDecoder failed: %v", err)
+	}
+	defer dec.Close()
+	for i := 0; i < 1000; i++ {
+		f, err := dec.DecodeVideo()
+		if err != nil || f.IsNil() {
+			break
+		}
+	}
+	if dec.LastLogMessage() == "" {
+		t.Error("no log message captured while decoding corrupt data")
+	}
+}
+
+func TestEncoderLogCapture(t *testing.T) {
+	if !requireFFmpeg(t) {
+		return
+	}
+	if !IsLoggingAvailable() {
+		t.Skip("log capture requires the ffshim library")
+	}
+
+	// The ADTS muxer only accepts AAC, so writing the header of a video
+	// stream fails with an error logged against the format context.
+	enc, err := NewEncoderWithOptions(filepath.Join(t.TempDir(), "out.aac"), &EncoderOptions{
+		Format:     "adts",
+		Video:      &VideoEncoderConfig{Width: 160, Height: 120, FrameRate: NewRational(15, 1)},
+		CaptureLog: true,
+	})
+	if err != nil {
+		t.Skipf("encoder unavailable: %v", err)
+	}
+	defer enc.Close()
+
+	err = enc.WriteHeader()
+	if err == nil {
+		t.Fatal("WriteHeader succeeded for video in an ADTS output")
+	}
+	msg := enc.LastLogMessage()
+	if msg == "" {
+		t.Fatal("no log message captured while writing the header")
+	}
+	if !strings.Contains(err.Error(), msg) {
+		t.Errorf("WriteHeader error %q does not carry the logged message %q", err, msg)
+	}
+}
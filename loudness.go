@@ -0,0 +1,162 @@
+//go:build !ios && !android && (amd64 || arm64)
+
+package ffgo
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/obinnaokechukwu/ffgo/avcodec"
+)
+
+// LoudnessStats is an EBU R128 loudness measurement.
+type LoudnessStats struct {
+	IntegratedLUFS float64 // integrated loudness, LUFS
+	LoudnessRange  float64 // loudness range (LRA), LU
+	TruePeakDBTP   float64 // maximum true peak over all channels, dBTP
+}
+
+// ebur128Filter is the filter MeasureLoudness runs; metadata=1 makes it
+// attach its running measurements to every output frame.
+const ebur128Filter = "ebur128=metadata=1:peak=true"
+
+// MeasureLoudness decodes the remaining audio stream through FFmpeg's
+// ebur128 filter and returns the integrated loudness, loudness range and
+// true peak of the whole stream, e.g. to pick the gain for podcast
+// normalization.
+//
+// The values are read from the metadata the filter attaches to its output
+// frames, which requires the shim (see Frame.Metadata).
+func (d *Decoder) MeasureLoudness() (LoudnessStats, error) {
+	if !d.HasAudio() {
+		return LoudnessStats{}, ErrNoAudioStream
+	}
+	if !frameMetadataAvailable() {
+		return LoudnessStats{}, errNoFrameMetadata
+	}
+
+	stats := LoudnessStats{TruePeakDBTP: math.Inf(-1)}
+	found := false
+	err := d.runAnalysisFilter(false, ebur128Filter, 0, func(f Frame) {
+		if parseLoudnessMetadata(f.Metadata(), &stats) {
+			found = true
+		}
+	})
+	if err != nil {
+		return LoudnessStats{}, err
+	}
+	if !found {
+		return LoudnessStats{}, errors.New("ffgo: ebur128 produced no measurements")
+	}
+	return stats, nil
+}
+
+// parseLoudnessMetadata updates stats from the lavfi.r128.* metadata of one
+// ebur128 output frame and reports whether any measurement was present.
+// Integrated loudness and LRA are running values, so the last frame's win;
+// true peaks are linear amplitudes, so the maximum is kept and converted to
+// dBTP.
+func parseLoudnessMetadata(md map[string]string, stats *LoudnessStats) bool {
+	found := false
+	for key, value := range md {
+		name, ok := strings.CutPrefix(key, "lavfi.r128.")
+		if !ok {
+			continue
+		}
+		v, err := strconv.ParseFloat(strings.TrimSpace(value), 64)
+		if err != nil {
+			continue
+		}
+		switch {
+		case name == "I":
+			stats.IntegratedLUFS = v
+			found = true
+		case name == "LRA":
+			stats.LoudnessRange = v
+			found = true
+		case strings.HasPrefix(name, "true_peaks_ch"):
+			if db := 20 * math.Log10(v); db > stats.TruePeakDBTP {
+				stats.TruePeakDBTP = db
+			}
+			found = true
+		}
+	}
+	return found
+}
+
+// LoudnormOptions are the targets for NormalizeLoudness. Zero values use
+// loudnorm's defaults.
+type LoudnormOptions struct {
+	// TargetI is the integrated loudness target in LUFS (default -24;
+	// -16 is common for podcasts).
+	TargetI float64
+	// TargetLRA is the loudness range target in LU. The default is 7, raised
+	// to the measured range so that the linear mode is not lost.
+	TargetLRA float64
+	// TargetTP is the maximum true peak in dBTP (default -2).
+	TargetTP float64
+}
+
+// NormalizeLoudness measures the loudness of input's audio stream with
+// ebur128 and returns an audio filter graph running loudnorm configured
+// with the measured values, for an accurate second pass. Run the stream's
+// decoded frames through the returned graph (with Filter, then Flush)
+// while encoding. The graph expects frames in the format of the input's
+// decoded audio and outputs them at the input sample rate.
+//
+// Given the measurements, loudnorm applies a single linear gain when the
+// result stays within TargetTP and the measured range is within
+// TargetLRA; otherwise it falls back to dynamic normalization. The caller
+// must Close the returned graph.
+func NormalizeLoudness(input string, opts LoudnormOptions) (*FilterGraph, error) {
+	dec, err := NewDecoder(input)
+	if err != nil {
+		return nil, err
+	}
+	defer dec.Close()
+
+	stats, err := dec.MeasureLoudness()
+	if err != nil {
+		return nil, err
+	}
+
+	rate := int(avcodec.GetCtxSampleRate(dec.audioCodecCtx))
+	channels := int(avcodec.GetCtxChannels(dec.audioCodecCtx))
+	return NewFilterGraph(FilterGraphConfig{
+		SampleRate: rate,
+		Channels:   channels,
+		SampleFmt:  SampleFormat(avcodec.GetCtxSampleFmt(dec.audioCodecCtx)),
+		// loudnorm works at 192 kHz internally; resample back to the input rate.
+		Filters: fmt.Sprintf("%s,aresample=%d", loudnormFilter(stats, opts), rate),
+	})
+}
+
+// loudnormFilter returns the loudnorm filter description for a second pass
+// over audio with the given measurements.
+func loudnormFilter(measured LoudnessStats, opts LoudnormOptions) string {
+	targetI, targetLRA, targetTP := opts.TargetI, opts.TargetLRA, opts.TargetTP
+	if targetI == 0 {
+		targetI = -24
+	}
+	if targetTP == 0 {
+		targetTP = -2
+	}
+	// loudnorm only runs linearly when measured_LRA is non-zero and within
+	// the target, so keep a steady signal's 0 LU range just above zero.
+	lra := max(measured.LoudnessRange, 0.1)
+	if targetLRA == 0 {
+		targetLRA = max(7, math.Ceil(lra))
+	}
+	tp := measured.TruePeakDBTP
+	if math.IsInf(tp, -1) {
+		tp = -99 // digital silence
+	}
+	// ebur128 does not export the gating threshold; the relative gate sits
+	// 10 LU below the integrated loudness.
+	thresh := measured.IntegratedLUFS - 10
+	return fmt.Sprintf("loudnorm=I=%.1f:LRA=%.1f:TP=%.1f:measured_I=%.2f:measured_LRA=%.2f:measured_TP=%.2f:measured_thresh=%.2f:linear=true",
+		targetI, targetLRA, targetTP, measured.IntegratedLUFS, lra, tp, thresh)
+}
@@ -0,0 +1,127 @@
+//go:build !ios && !android && (amd64 || arm64)
+
+package ffgo
+
+import (
+	"math"
+	"testing"
+
+	"github.com/obinnaokechukwu/ffgo/avutil"
+)
+
+func TestParseLoudnessMetadata(t *testing.T) {
+	stats := LoudnessStats{TruePeakDBTP: math.Inf(-1)}
+	if parseLoudnessMetadata(map[string]string{"lavfi.cropdetect.w": "640"}, &stats) {
+		t.Error("non-ebur128 metadata reported as a measurement")
+	}
+
+	parseLoudnessMetadata(map[string]string{
+		"lavfi.r128.I":              "-20.0",
+		"lavfi.r128.LRA":            "3.0",
+		"lavfi.r128.true_peaks_ch0": "0.25",
+	}, &stats)
+	if !parseLoudnessMetadata(map[string]string{
+		"lavfi.r128.M":              "-17.5",
+		"lavfi.r128.I":              "-18.2",
+		"lavfi.r128.LRA":            "4.5",
+		"lavfi.r128.true_peaks_ch0": "0.125",
+		"lavfi.r128.true_peaks_ch1": "0.5",
+	}, &stats) {
+		t.Fatal("ebur128 metadata not recognized")
+	}
+
+	if stats.IntegratedLUFS != -18.2 || stats.LoudnessRange != 4.5 {
+		t.Errorf("I/LRA = %v/%v, want the last frame's -18.2/4.5", stats.IntegratedLUFS, stats.LoudnessRange)
+	}
+	if want := 20 * math.Log10(0.5); math.Abs(stats.TruePeakDBTP-want) > 1e-9 {
+		t.Errorf("TruePeakDBTP = %v, want %v", stats.TruePeakDBTP, want)
+	}
+}
+
+func TestMeasureLoudness(t *testing.T) {
+	if !requireFFmpeg(t) {
+		return
+	}
+	dec, err := NewDecoder(createSineWAV(t, 0.5))
+	if err != nil {
+		t.Fatalf("NewDecoder failed: %v", err)
+	}
+	defer dec.Close()
+
+	stats, err := dec.MeasureLoudness()
+	if err != nil {
+		t.Skipf("MeasureLoudness unavailable: %v", err)
+	}
+	if math.Abs(stats.TruePeakDBTP+6) > 1 {
+		t.Errorf("TruePeakDBTP = %.2f, want about -6 for a half-scale sine", stats.TruePeakDBTP)
+	}
+	if stats.IntegratedLUFS > -3 || stats.IntegratedLUFS < -15 {
+		t.Errorf("IntegratedLUFS = %.2f, want around -9 for a half-scale stereo sine", stats.IntegratedLUFS)
+	}
+}
+
+func TestLoudnormFilter(t *testing.T) {
+	measured := LoudnessStats{IntegratedLUFS: -30.5, LoudnessRange: 0, TruePeakDBTP: -12.25}
+	got := loudnormFilter(measured, LoudnormOptions{TargetI: -16})
+	want := "loudnorm=I=-16.0:LRA=7.0:TP=-2.0:measured_I=-30.50:measured_LRA=0.10:measured_TP=-12.25:measured_thresh=-40.50:linear=true"
+	if got != want {
+		t.Errorf("loudnormFilter =\n%s\nwant\n%s", got, want)
+	}
+
+	measured.LoudnessRange = 11.2
+	got = loudnormFilter(measured, LoudnormOptions{})
+	want = "loudnorm=I=-24.0:LRA=12.0:TP=-2.0:measured_I=-30.50:measured_LRA=11.20:measured_TP=-12.25:measured_thresh=-40.50:linear=true"
+	if got != want {
+		t.Errorf("loudnormFilter =\n%s\nwant\n%s", got, want)
+	}
+}
+
+func TestNormalizeLoudness(t *testing.T) {
+	if !requireFFmpeg(t) {
+		return
+	}
+	input := createSineWAV(t, 0.1)
+	graph, err := NormalizeLoudness(input, LoudnormOptions{TargetI: -16})
+	if err != nil {
+		t.Skipf("NormalizeLoudness unavailable: %v", err)
+	}
+	defer graph.Close()
+	if !graph.IsAudio() {
+		t.Fatal("NormalizeLoudness returned a non-audio graph")
+	}
+
+	dec, err := NewDecoder(input)
+	if err != nil {
+		t.Fatalf("NewDecoder failed: %v", err)
+	}
+	defer dec.Close()
+	if err := dec.OpenAudioDecoder(); err != nil {
+		t.Fatalf("OpenAudioDecoder failed: %v", err)
+	}
+	var samples int
+	for {
+		frame, err := dec.DecodeAudio()
+		if err != nil || frame.IsNil() {
+			break
+		}
+		out, err := graph.Filter(&frame)
+		if err != nil {
+			t.Fatalf("Filter failed: %v", err)
+		}
+		for i := range out {
+			samples += int(avutil.GetFrameNbSamples(out[i].ptr))
+			_ = out[i].Free()
+		}
+	}
+	out, err := graph.Flush()
+	if err != nil {
+		t.Fatalf("Flush failed: %v", err)
+	}
+	for i := range out {
+		samples += int(avutil.GetFrameNbSamples(out[i].ptr))
+		_ = out[i].Free()
+	}
+	if samples < 40000 {
+		t.Errorf("normalized %d samples, want about 44100", samples)
+	}
+}
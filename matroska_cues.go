@@ -0,0 +1,104 @@
+//go:build !ios && !android && (amd64 || arm64)
+
+package ffgo
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/obinnaokechukwu/ffgo/avformat"
+)
+
+// CuePoint is an entry of a Matroska file's Cues element, the seek index
+// that lets players jump to a time without scanning the file.
+type CuePoint struct {
+	StreamIndex int
+	PTS         int64         // in the stream's time base
+	Time        time.Duration // PTS as a time
+	Position    int64         // byte position of the cluster holding the frame
+}
+
+// MatroskaCues returns the cue points of a Matroska/WebM input, sorted by
+// time and then stream index. It returns an empty slice for files written
+// without Cues (e.g. live WebM).
+//
+// FFmpeg reads Cues stored after the clusters on the first seek, so
+// MatroskaCues seeks to the start of the input and leaves the decoder
+// positioned there.
+func (d *Decoder) MatroskaCues() ([]CuePoint, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if d.closed || d.formatCtx == nil {
+		return nil, errors.New("ffgo: decoder is closed")
+	}
+	name := avformat.InputFormatName(avformat.GetInputFormat(d.formatCtx))
+	if !strings.Contains(name, "matroska") && !strings.Contains(name, "webm") {
+		return nil, fmt.Errorf("ffgo: input format %q is not Matroska", name)
+	}
+
+	if err := avformat.SeekFrame(d.formatCtx, -1, 0, avformat.SeekFlagBackward); err != nil {
+		return nil, err
+	}
+	d.flushCodecsLocked()
+
+	cues := []CuePoint{}
+	for i := 0; i < avformat.GetNumStreams(d.formatCtx); i++ {
+		stream := avformat.GetStream(d.formatCtx, i)
+		if stream == nil {
+			continue
+		}
+		entries, err := avformat.StreamIndexEntries(stream)
+		if err != nil {
+			return nil, err
+		}
+		tb := NewRational(avformat.GetStreamTimeBase(stream))
+		for _, e := range entries {
+			cues = append(cues, CuePoint{
+				StreamIndex: i,
+				PTS:         e.Timestamp,
+				Time:        ptsToDuration(e.Timestamp, tb),
+				Position:    e.Pos,
+			})
+		}
+	}
+	sort.SliceStable(cues, func(i, j int) bool {
+		if cues[i].Time != cues[j].Time {
+			return cues[i].Time < cues[j].Time
+		}
+		return cues[i].StreamIndex < cues[j].StreamIndex
+	})
+	return cues, nil
+}
+
+// MatroskaCueOptions configures the Cues FFmpeg's Matroska muxer writes
+// (EncoderOptions.MatroskaCues). By default the Cues follow the clusters at
+// the end of the file.
+type MatroskaCueOptions struct {
+	// CuesToFront moves the Cues in front of the first cluster when the
+	// trailer is written ("cues_to_front"), so players can seek without
+	// reading the end of the file first. The trailer rewrites the file, so
+	// the output must be seekable.
+	CuesToFront bool
+
+	// ReserveIndexSpace reserves this many bytes after the header for the
+	// Cues ("reserve_index_space"). If they do not fit, they are written at
+	// the end instead. Zero reserves nothing.
+	ReserveIndexSpace int
+}
+
+// muxerOptions returns the matroska muxer options for o.
+func (o *MatroskaCueOptions) muxerOptions() map[string]string {
+	opts := make(map[string]string, 2)
+	if o.CuesToFront {
+		opts["cues_to_front"] = "1"
+	}
+	if o.ReserveIndexSpace > 0 {
+		opts["reserve_index_space"] = strconv.Itoa(o.ReserveIndexSpace)
+	}
+	return opts
+}
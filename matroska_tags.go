@@ -0,0 +1,141 @@
+//go:build !ios && !android && (amd64 || arm64)
+
+package ffgo
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/obinnaokechukwu/ffgo/avformat"
+)
+
+// TagTargetKind identifies the kind of element a Matroska tag applies to.
+type TagTargetKind int
+
+const (
+	// TagTargetGlobal tags apply to the whole segment (file).
+	TagTargetGlobal TagTargetKind = iota
+
+	// TagTargetTrack tags apply to a single track (stream).
+	TagTargetTrack
+
+	// TagTargetChapter tags apply to a single chapter.
+	TagTargetChapter
+)
+
+// TagTarget identifies what a Matroska tag describes.
+//
+// Tag levels (Matroska TargetType/TargetTypeValue, e.g. SEASON versus
+// EPISODE) are not represented: FFmpeg's demuxer does not report them and
+// its muxer writes every tag at TargetTypeValue 50 (album, movie, episode).
+type TagTarget struct {
+	// Kind selects the segment, a track or a chapter.
+	Kind TagTargetKind
+
+	// Index is the stream index for TagTargetTrack, or the chapter's
+	// position (as returned by Decoder.Chapters) for TagTargetChapter.
+	Index int
+}
+
+// Tag is a single Matroska SimpleTag together with its target.
+type Tag struct {
+	Target TagTarget
+
+	// Name is the tag name (e.g. "TITLE", "DATE_RELEASED"). Nested
+	// SimpleTags are joined with "/", e.g. "ACTOR/CHARACTER".
+	Name string
+
+	Value string
+}
+
+// MatroskaTags returns the tags of a Matroska/WebM input with their
+// targets, in a stable order: global tags first, then tracks, then chapters,
+// each sorted by name.
+//
+// FFmpeg flattens Matroska tags into metadata dictionaries; this reverses
+// that mapping so global, per-track and per-chapter tags can be told apart.
+func (d *Decoder) MatroskaTags() ([]Tag, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if d.formatCtx == nil {
+		return nil, errors.New("ffgo: decoder is closed")
+	}
+	name := avformat.InputFormatName(avformat.GetInputFormat(d.formatCtx))
+	if !strings.Contains(name, "matroska") && !strings.Contains(name, "webm") {
+		return nil, fmt.Errorf("ffgo: input format %q is not Matroska", name)
+	}
+
+	var tags []Tag
+	add := func(target TagTarget, meta Metadata) {
+		keys := make([]string, 0, len(meta))
+		for k := range meta {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			tags = append(tags, Tag{Target: target, Name: k, Value: meta[k]})
+		}
+	}
+
+	add(TagTarget{Kind: TagTargetGlobal}, getMetadataFromDict(avformat.GetMetadata(d.formatCtx)))
+
+	for i := 0; i < avformat.GetNumStreams(d.formatCtx); i++ {
+		stream := avformat.GetStream(d.formatCtx, i)
+		if stream == nil {
+			continue
+		}
+		add(TagTarget{Kind: TagTargetTrack, Index: i}, getMetadataFromDict(avformat.GetStreamMetadata(stream)))
+	}
+
+	for i := 0; i < avformat.GetNumChapters(d.formatCtx); i++ {
+		ch := avformat.GetChapter(d.formatCtx, i)
+		if ch == nil {
+			continue
+		}
+		add(TagTarget{Kind: TagTargetChapter, Index: i}, getChapterMetadata(ch))
+	}
+
+	return tags, nil
+}
+
+// AddMatroskaTag adds a tag to a Matroska/WebM output.
+// Must be called before WriteHeader.
+//
+// Tags are written at the default level (TargetTypeValue 50); see TagTarget.
+// Chapter tags cannot be added here; pass them in Chapter.Metadata to
+// SetChapters instead.
+func (e *Encoder) AddMatroskaTag(target TagTarget, name, value string) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	if e.formatCtx == nil {
+		return ErrEncoderClosed
+	}
+	if e.headerWritten {
+		return ErrHeaderAlreadyWritten
+	}
+	if name == "" {
+		return errors.New("ffgo: tag name cannot be empty")
+	}
+	format := avformat.OutputFormatName(avformat.GetOutputFormat(e.formatCtx))
+	if format != "matroska" && format != "webm" {
+		return fmt.Errorf("ffgo: output format %q is not Matroska", format)
+	}
+
+	switch target.Kind {
+	case TagTargetGlobal:
+		return avformat.SetMetadata(e.formatCtx, name, value)
+	case TagTargetTrack:
+		if target.Index < 0 || target.Index >= avformat.GetNumStreams(e.formatCtx) {
+			return ErrInvalidStream
+		}
+		return avformat.SetStreamMetadata(avformat.GetStream(e.formatCtx, target.Index), name, value)
+	case TagTargetChapter:
+		return errors.New("ffgo: chapter tags must be set via Chapter.Metadata in SetChapters")
+	default:
+		return fmt.Errorf("ffgo: invalid tag target kind %d", target.Kind)
+	}
+}
@@ -3,6 +3,9 @@
 package ffgo
 
 import (
+	"fmt"
+	"strings"
+	"time"
 	"unsafe"
 
 	"github.com/obinnaokechukwu/ffgo/avformat"
@@ -108,6 +111,136 @@ func (e *Encoder) SetStreamMetadata(streamIndex int, metadata Metadata) error {
 	return nil
 }
 
+// SetTitle sets the output file's title. Must be called before WriteHeader.
+func (e *Encoder) SetTitle(title string) error {
+	return e.SetMetadata(Metadata{MetadataTitle: title})
+}
+
+// SetArtist sets the output file's artist. Must be called before WriteHeader.
+func (e *Encoder) SetArtist(artist string) error {
+	return e.SetMetadata(Metadata{MetadataArtist: artist})
+}
+
+// SetDate records t as the output file's date. It sets creation_time to t
+// in ISO 8601 UTC with microseconds (e.g. "2024-05-01T12:00:00.000000Z"),
+// the form FFmpeg itself writes and muxers parse into their native date
+// fields, and date to the calendar day ("2024-05-01").
+// Must be called before WriteHeader.
+func (e *Encoder) SetDate(t time.Time) error {
+	if t.IsZero() {
+		return fmt.Errorf("ffgo: date is zero")
+	}
+	return e.SetMetadata(Metadata{
+		MetadataCreationTime: formatCreationTime(t),
+		MetadataDate:         t.UTC().Format("2006-01-02"),
+	})
+}
+
+// SetLanguage sets the language of a stream (0 is video, 1 is audio, as for
+// SetStreamMetadata). lang may be an ISO 639-1 two-letter code ("en"), an
+// ISO 639-2 code in either its bibliographic or terminology form ("ger",
+// "deu"), or a BCP 47 tag whose primary subtag is one of those ("pt-BR").
+// It is stored as the ISO 639-2 code the output format expects: the
+// terminology form ("deu") for MP4 and MOV, whose mdhd language follows
+// ISO 639-2/T, and the bibliographic form ("ger") that Matroska requires
+// otherwise; "und" marks an undetermined language.
+// Must be called before WriteHeader.
+func (e *Encoder) SetLanguage(stream int, lang string) error {
+	terminology := isMOVFamilyFormat(avformat.OutputFormatName(avformat.GetOutputFormat(e.formatCtx)))
+	code, err := normalizeLanguage(lang, terminology)
+	if err != nil {
+		return err
+	}
+	return e.SetStreamMetadata(stream, Metadata{MetadataLanguage: code})
+}
+
+// formatCreationTime formats t as FFmpeg's creation_time (ISO 8601 UTC).
+func formatCreationTime(t time.Time) string {
+	return t.UTC().Format("2006-01-02T15:04:05.000000Z")
+}
+
+// normalizeLanguage converts lang to a lowercase ISO 639-2 code, in its
+// terminology (ISO 639-2/T) form if terminology is set and its
+// bibliographic (ISO 639-2/B) form otherwise.
+func normalizeLanguage(lang string, terminology bool) (string, error) {
+	code := strings.ToLower(strings.TrimSpace(lang))
+	if i := strings.IndexAny(code, "-_"); i >= 0 {
+		code = code[:i]
+	}
+	for _, c := range code {
+		if c < 'a' || c > 'z' {
+			return "", fmt.Errorf("ffgo: invalid language code %q", lang)
+		}
+	}
+	invalid := fmt.Errorf("ffgo: invalid language code %q (want ISO 639)", lang)
+	switch len(code) {
+	case 2:
+		b, ok := iso639_1To2B[code]
+		if !ok {
+			return "", invalid
+		}
+		code = b
+	case 3:
+		if b, ok := iso639_2TTo2B[code]; ok {
+			code = b
+		}
+	default:
+		return "", invalid
+	}
+	if terminology {
+		for t, b := range iso639_2TTo2B {
+			if b == code {
+				return t, nil
+			}
+		}
+	}
+	return code, nil
+}
+
+// iso639_1To2B maps ISO 639-1 codes to ISO 639-2/B.
+var iso639_1To2B = map[string]string{
+	"aa": "aar", "ab": "abk", "ae": "ave", "af": "afr", "ak": "aka", "am": "amh",
+	"an": "arg", "ar": "ara", "as": "asm", "av": "ava", "ay": "aym", "az": "aze",
+	"ba": "bak", "be": "bel", "bg": "bul", "bh": "bih", "bi": "bis", "bm": "bam",
+	"bn": "ben", "bo": "tib", "br": "bre", "bs": "bos", "ca": "cat", "ce": "che",
+	"ch": "cha", "co": "cos", "cr": "cre", "cs": "cze", "cu": "chu", "cv": "chv",
+	"cy": "wel", "da": "dan", "de": "ger", "dv": "div", "dz": "dzo", "ee": "ewe",
+	"el": "gre", "en": "eng", "eo": "epo", "es": "spa", "et": "est", "eu": "baq",
+	"fa": "per", "ff": "ful", "fi": "fin", "fj": "fij", "fo": "fao", "fr": "fre",
+	"fy": "fry", "ga": "gle", "gd": "gla", "gl": "glg", "gn": "grn", "gu": "guj",
+	"gv": "glv", "ha": "hau", "he": "heb", "hi": "hin", "ho": "hmo", "hr": "hrv",
+	"ht": "hat", "hu": "hun", "hy": "arm", "hz": "her", "ia": "ina", "id": "ind",
+	"ie": "ile", "ig": "ibo", "ii": "iii", "ik": "ipk", "io": "ido", "is": "ice",
+	"it": "ita", "iu": "iku", "ja": "jpn", "jv": "jav", "ka": "geo", "kg": "kon",
+	"ki": "kik", "kj": "kua", "kk": "kaz", "kl": "kal", "km": "khm", "kn": "kan",
+	"ko": "kor", "kr": "kau", "ks": "kas", "ku": "kur", "kv": "kom", "kw": "cor",
+	"ky": "kir", "la": "lat", "lb": "ltz", "lg": "lug", "li": "lim", "ln": "lin",
+	"lo": "lao", "lt": "lit", "lu": "lub", "lv": "lav", "mg": "mlg", "mh": "mah",
+	"mi": "mao", "mk": "mac", "ml": "mal", "mn": "mon", "mr": "mar", "ms": "may",
+	"mt": "mlt", "my": "bur", "na": "nau", "nb": "nob", "nd": "nde", "ne": "nep",
+	"ng": "ndo", "nl": "dut", "nn": "nno", "no": "nor", "nr": "nbl", "nv": "nav",
+	"ny": "nya", "oc": "oci", "oj": "oji", "om": "orm", "or": "ori", "os": "oss",
+	"pa": "pan", "pi": "pli", "pl": "pol", "ps": "pus", "pt": "por", "qu": "que",
+	"rm": "roh", "rn": "run", "ro": "rum", "ru": "rus", "rw": "kin", "sa": "san",
+	"sc": "srd", "sd": "snd", "se": "sme", "sg": "sag", "si": "sin", "sk": "slo",
+	"sl": "slv", "sm": "smo", "sn": "sna", "so": "som", "sq": "alb", "sr": "srp",
+	"ss": "ssw", "st": "sot", "su": "sun", "sv": "swe", "sw": "swa", "ta": "tam",
+	"te": "tel", "tg": "tgk", "th": "tha", "ti": "tir", "tk": "tuk", "tl": "tgl",
+	"tn": "tsn", "to": "ton", "tr": "tur", "ts": "tso", "tt": "tat", "tw": "twi",
+	"ty": "tah", "ug": "uig", "uk": "ukr", "ur": "urd", "uz": "uzb", "ve": "ven",
+	"vi": "vie", "vo": "vol", "wa": "wln", "wo": "wol", "xh": "xho", "yi": "yid",
+	"yo": "yor", "za": "zha", "zh": "chi", "zu": "zul",
+}
+
+// iso639_2TTo2B maps the ISO 639-2/T codes that differ from their
+// bibliographic form.
+var iso639_2TTo2B = map[string]string{
+	"bod": "tib", "ces": "cze", "cym": "wel", "deu": "ger", "ell": "gre",
+	"eus": "baq", "fas": "per", "fra": "fre", "hye": "arm", "isl": "ice",
+	"kat": "geo", "mkd": "mac", "mri": "mao", "msa": "may", "mya": "bur",
+	"nld": "dut", "ron": "rum", "slk": "slo", "sqi": "alb", "zho": "chi",
+}
+
 // Common metadata keys
 const (
 	MetadataTitle       = "title"
@@ -125,6 +258,9 @@ const (
 	MetadataEncoder     = "encoder"
 	MetadataLanguage    = "language"
 	MetadataCopyright   = "copyright"
+
+	// MetadataCreationTime holds an ISO 8601 UTC timestamp; see Encoder.SetDate.
+	MetadataCreationTime = "creation_time"
 )
 
 // Helper to convert AVDictionary to Metadata map
@@ -0,0 +1,183 @@
+//go:build !ios && !android && (amd64 || arm64)
+
+package ffgo
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestNormalizeLanguage(t *testing.T) {
+	tests := []struct {
+		in, bibl, term string
+	}{
+		{"eng", "eng", "eng"},
+		{"en", "eng", "eng"},
+		{"EN", "eng", "eng"},
+		{" de ", "ger", "deu"},
+		{"deu", "ger", "deu"},
+		{"ger", "ger", "deu"},
+		{"fra", "fre", "fra"},
+		{"fr", "fre", "fra"},
+		{"pt-BR", "por", "por"},
+		{"zh_Hant", "chi", "zho"},
+		{"und", "und", "und"},
+	}
+	for _, tt := range tests {
+		for _, terminology := range []bool{false, true} {
+			want := tt.bibl
+			if terminology {
+				want = tt.term
+			}
+			got, err := normalizeLanguage(tt.in, terminology)
+			if err != nil {
+				t.Errorf("normalizeLanguage(%q, %v) failed: %v", tt.in, terminology, err)
+				continue
+			}
+			if got != want {
+				t.Errorf("normalizeLanguage(%q, %v) = %q, want %q", tt.in, terminology, got, want)
+			}
+		}
+	}
+
+	for _, bad := range []string{"", "e", "xx", "english", "e1g", "-en"} {
+		if got, err := normalizeLanguage(bad, false); err == nil {
+			t.Errorf("normalizeLanguage(%q) = %q, want error", bad, got)
+		}
+	}
+}
+
+func TestFormatCreationTime(t *testing.T) {
+	loc := time.FixedZone("UTC+2", 2*60*60)
+	ts := time.Date(2024, 5, 1, 14, 30, 15, 123456789, loc)
+	if got, want := formatCreationTime(ts), "2024-05-01T12:30:15.123456Z"; got != want {
+		t.Fatalf("formatCreationTime = %q, want %q", got, want)
+	}
+}
+
+func TestEncoderTypedMetadata(t *testing.T) {
+	if !requireFFmpeg(t) {
+		return
+	}
+	testFile := filepath.Join(t.TempDir(), "typed_meta.mkv")
+
+	enc, err := NewEncoderWithOptions(testFile, &EncoderOptions{
+		Video: &VideoEncoderConfig{
+			Width:       160,
+			Height:      120,
+			FrameRate:   Rational{Num: 30, Den: 1},
+			PixelFormat: PixelFormatYUV420P,
+		},
+	})
+	if err != nil {
+		t.Fatalf("Failed to create encoder: %v", err)
+	}
+
+	date := time.Date(2023, 11, 7, 8, 9, 10, 0, time.UTC)
+	if err := enc.SetTitle("Typed Title"); err != nil {
+		enc.Close()
+		t.Fatalf("SetTitle failed: %v", err)
+	}
+	if err := enc.SetArtist("Typed Artist"); err != nil {
+		enc.Close()
+		t.Fatalf("SetArtist failed: %v", err)
+	}
+	if err := enc.SetDate(date); err != nil {
+		enc.Close()
+		t.Fatalf("SetDate failed: %v", err)
+	}
+	if err := enc.SetLanguage(0, "de"); err != nil {
+		enc.Close()
+		t.Fatalf("SetLanguage failed: %v", err)
+	}
+	if err := enc.SetLanguage(0, "not a language"); err == nil {
+		enc.Close()
+		t.Fatal("expected SetLanguage to reject an invalid code")
+	}
+
+	frame, err := newVideoFrame(160, 120, PixelFormatYUV420P)
+	if err != nil {
+		enc.Close()
+		t.Fatalf("newVideoFrame failed: %v", err)
+	}
+	for i := 0; i < 5; i++ {
+		if err := enc.WriteFrame(frame); err != nil {
+			t.Fatalf("WriteFrame failed: %v", err)
+		}
+	}
+	_ = FrameFree(&frame)
+	if err := enc.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+
+	dec, err := NewDecoder(testFile)
+	if err != nil {
+		t.Fatalf("Failed to open written file: %v", err)
+	}
+	defer dec.Close()
+
+	meta := dec.GetMetadata()
+	if meta[MetadataTitle] != "Typed Title" {
+		t.Errorf("title = %q", meta[MetadataTitle])
+	}
+	if meta[MetadataArtist] != "Typed Artist" {
+		t.Errorf("artist = %q", meta[MetadataArtist])
+	}
+	if got := meta[MetadataCreationTime]; !strings.HasPrefix(got, "2023-11-07T08:09:10") {
+		t.Errorf("creation_time = %q", got)
+	}
+	if got := dec.GetStreamMetadata(0)[MetadataLanguage]; got != "ger" {
+		t.Errorf("language = %q, want ger", got)
+	}
+}
+
+func TestEncoderSetLanguageMP4(t *testing.T) {
+	if !requireFFmpeg(t) {
+		return
+	}
+	testFile := filepath.Join(t.TempDir(), "language.mp4")
+
+	enc, err := NewEncoderWithOptions(testFile, &EncoderOptions{
+		Video: &VideoEncoderConfig{
+			Width:       160,
+			Height:      120,
+			FrameRate:   Rational{Num: 30, Den: 1},
+			PixelFormat: PixelFormatYUV420P,
+		},
+	})
+	if err != nil {
+		t.Fatalf("Failed to create encoder: %v", err)
+	}
+	if err := enc.SetLanguage(0, "ger"); err != nil {
+		enc.Close()
+		t.Fatalf("SetLanguage failed: %v", err)
+	}
+
+	frame, err := newVideoFrame(160, 120, PixelFormatYUV420P)
+	if err != nil {
+		enc.Close()
+		t.Fatalf("newVideoFrame failed: %v", err)
+	}
+	for i := 0; i < 5; i++ {
+		if err := enc.WriteFrame(frame); err != nil {
+			t.Fatalf("WriteFrame failed: %v", err)
+		}
+	}
+	_ = FrameFree(&frame)
+	if err := enc.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+
+	dec, err := NewDecoder(testFile)
+	if err != nil {
+		t.Fatalf("Failed to open written file: %v", err)
+	}
+	defer dec.Close()
+
+	// The mdhd language is ISO 639-2/T.
+	if got := dec.GetStreamMetadata(0)[MetadataLanguage]; got != "deu" {
+		t.Errorf("language = %q, want deu", got)
+	}
+}
@@ -21,10 +21,23 @@ type Muxer struct {
 	ioCtx         avformat.IOContext
 	streams       []*MuxerStream
 	headerWritten bool
+	trailerDone   bool
 	path          string
+	options       map[string]string
 	closed        bool
 }
 
+// MuxerOptions configures a Muxer created with NewMuxerWithOptions.
+type MuxerOptions struct {
+	// Format is the FFmpeg mux format name (e.g., "matroska", "mp4").
+	// If empty, it is guessed from the output path.
+	Format string
+
+	// Options are muxer private options passed to avformat_write_header
+	// (e.g., {"movflags": "+faststart"}). Used by WriteHeader.
+	Options map[string]string
+}
+
 // MuxerStream represents a stream being muxed.
 type MuxerStream struct {
 	muxer     *Muxer
@@ -77,6 +90,21 @@ func NewMuxer(path string, format string) (*Muxer, error) {
 	return m, nil
 }
 
+// NewMuxerWithOptions creates a muxer for the given output path.
+//
+// Together with AddStream and WriteStreamPacket this is a container writer
+// independent of any Encoder or Decoder: packets produced elsewhere (custom
+// encoders, network sources) can be muxed directly. Close writes the trailer
+// if WriteTrailer was not called.
+func NewMuxerWithOptions(path string, opts MuxerOptions) (*Muxer, error) {
+	m, err := NewMuxer(path, opts.Format)
+	if err != nil {
+		return nil, err
+	}
+	m.options = opts.Options
+	return m, nil
+}
+
 // VideoStreamConfig configures a video stream for the muxer.
 type VideoStreamConfig struct {
 	Codec       CodecID     // Video codec (e.g., CodecIDH264)
@@ -333,6 +361,20 @@ func (m *Muxer) AddCopyStream(config *CopyStreamConfig) (*MuxerStream, error) {
 	return ms, nil
 }
 
+// AddStream adds a stream described by params and returns its index.
+// Packets written to it with WriteStreamPacket carry timestamps in timeBase
+// and are rescaled to the muxer-chosen stream time base.
+func (m *Muxer) AddStream(params avcodec.Parameters, timeBase Rational) (int, error) {
+	ms, err := m.AddCopyStream(&CopyStreamConfig{
+		CodecParameters: params,
+		TimeBase:        timeBase,
+	})
+	if err != nil {
+		return -1, err
+	}
+	return ms.index, nil
+}
+
 // WriteHeader writes the container header.
 // Must be called after all streams are added and before writing any frames/packets.
 func (m *Muxer) WriteHeader() error {
@@ -349,6 +391,9 @@ func (m *Muxer) WriteHeader() error {
 		return errors.New("ffgo: no streams added")
 	}
 
+	if len(m.options) > 0 {
+		return m.writeHeaderWithOptionsLocked(m.options)
+	}
 	return m.writeHeaderLocked(nil)
 }
 
@@ -368,6 +413,10 @@ func (m *Muxer) WriteHeaderWithOptions(opts map[string]string) error {
 		return errors.New("ffgo: no streams added")
 	}
 
+	return m.writeHeaderWithOptionsLocked(opts)
+}
+
+func (m *Muxer) writeHeaderWithOptionsLocked(opts map[string]string) error {
 	var dict avutil.Dictionary
 	for k, v := range opts {
 		if v == "" {
@@ -473,6 +522,30 @@ func (m *Muxer) WritePacket(ms *MuxerStream, packet *Packet) error {
 	if ms == nil || ms.muxer != m {
 		return errors.New("ffgo: invalid stream")
 	}
+
+	return m.writePacketLocked(ms, packet)
+}
+
+// WriteStreamPacket writes a packet to the stream at streamIndex (as returned
+// by AddStream). Timestamps are rescaled from the stream's source time base.
+func (m *Muxer) WriteStreamPacket(streamIndex int, packet *Packet) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.closed {
+		return errors.New("ffgo: muxer is closed")
+	}
+	if !m.headerWritten {
+		return errors.New("ffgo: header not written")
+	}
+	if streamIndex < 0 || streamIndex >= len(m.streams) {
+		return ErrInvalidStream
+	}
+
+	return m.writePacketLocked(m.streams[streamIndex], packet)
+}
+
+func (m *Muxer) writePacketLocked(ms *MuxerStream, packet *Packet) error {
 	if packet == nil || packet.ptr == nil {
 		return errors.New("ffgo: packet cannot be nil")
 	}
@@ -503,7 +576,14 @@ func (m *Muxer) WriteTrailer() error {
 	if !m.headerWritten {
 		return errors.New("ffgo: header not written")
 	}
+	if m.trailerDone {
+		return errors.New("ffgo: trailer already written")
+	}
+
+	return m.writeTrailerLocked()
+}
 
+func (m *Muxer) writeTrailerLocked() error {
 	// Flush encoders
 	for _, ms := range m.streams {
 		if ms.encoder != nil && ms.codecCtx != nil {
@@ -511,6 +591,7 @@ func (m *Muxer) WriteTrailer() error {
 		}
 	}
 
+	m.trailerDone = true
 	return avformat.WriteTrailer(m.formatCtx)
 }
 
@@ -535,7 +616,8 @@ func (m *Muxer) flushEncoder(ms *MuxerStream) {
 	}
 }
 
-// Close releases all resources.
+// Close writes the trailer if the header was written but WriteTrailer was
+// not called, then releases all resources.
 func (m *Muxer) Close() error {
 	m.mu.Lock()
 	defer m.mu.Unlock()
@@ -545,6 +627,11 @@ func (m *Muxer) Close() error {
 	}
 	m.closed = true
 
+	var trailerErr error
+	if m.headerWritten && !m.trailerDone {
+		trailerErr = m.writeTrailerLocked()
+	}
+
 	// Free encoder resources
 	for _, ms := range m.streams {
 		if ms.encoder != nil {
@@ -571,7 +658,7 @@ func (m *Muxer) Close() error {
 		m.formatCtx = nil
 	}
 
-	return nil
+	return trailerErr
 }
 
 // Streams returns all streams in the muxer.
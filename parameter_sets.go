@@ -0,0 +1,241 @@
+//go:build !ios && !android && (amd64 || arm64)
+
+package ffgo
+
+import (
+	"errors"
+	"unsafe"
+
+	"github.com/obinnaokechukwu/ffgo/avcodec"
+	"github.com/obinnaokechukwu/ffgo/avformat"
+)
+
+// pktDataNewExtradata is AV_PKT_DATA_NEW_EXTRADATA, the packet side data
+// the extract_extradata filter attaches.
+const pktDataNewExtradata = 1
+
+// VideoExtradata returns a copy of the video stream's codec extradata, e.g.
+// the avcC/hvcC record of H.264/HEVC in MP4 or the Annex B parameter sets
+// of other containers. Returns nil if there is no video stream or it has no
+// extradata.
+func (d *Decoder) VideoExtradata() []byte {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if d.closed || d.videoInfo == nil {
+		return nil
+	}
+	return avformat.GetCodecParExtradata(d.videoInfo.codecPar)
+}
+
+// ExtractParameterSets returns the H.264 (SPS, PPS) or HEVC (VPS, SPS, PPS)
+// parameter set NAL units of a stream, without start codes, as needed for
+// RTP packetization and SDP sprop parameters.
+//
+// They are taken from the extradata in params when present. Otherwise, as
+// with MPEG-TS or raw input, first must be the stream's first packet; it is
+// run through the extract_extradata bitstream filter, which pulls the
+// parameter sets out of the in-band data. first itself is left untouched.
+func ExtractParameterSets(params avcodec.Parameters, first *Packet) ([][]byte, error) {
+	if params == nil {
+		return nil, errors.New("ffgo: codec parameters are required")
+	}
+	codecID := avformat.GetCodecParCodecID(params)
+	if codecID != CodecIDH264 && codecID != CodecIDHEVC {
+		return nil, errors.New("ffgo: parameter sets are only supported for H.264 and HEVC")
+	}
+
+	if extradata := avformat.GetCodecParExtradata(params); len(extradata) > 0 {
+		return parseParameterSets(codecID, extradata)
+	}
+	if first.IsNil() {
+		return nil, errors.New("ffgo: stream has no extradata; the first packet is required")
+	}
+
+	extradata, err := extractExtradata(params, first)
+	if err != nil {
+		return nil, err
+	}
+	if len(extradata) == 0 {
+		return nil, errors.New("ffgo: packet carries no parameter sets")
+	}
+	return parseParameterSets(codecID, extradata)
+}
+
+// extractExtradata runs a clone of pkt through extract_extradata and returns
+// the extradata it found.
+func extractExtradata(params avcodec.Parameters, pkt *Packet) ([]byte, error) {
+	bsf, err := NewBitstreamFilter(BSFNameExtractExtradata)
+	if err != nil {
+		return nil, err
+	}
+	defer bsf.Close()
+	if err := bsf.InitFromParameters(params, NewRational(1, 90000)); err != nil {
+		return nil, err
+	}
+
+	in, err := PacketClone(pkt)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = in.Free() }()
+
+	out, err := bsf.Apply(in)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		for _, p := range out {
+			_ = p.Free()
+		}
+	}()
+
+	if avPacketGetSideData == nil {
+		return nil, ErrNotLoaded
+	}
+	for _, p := range out {
+		var size uint64
+		data := unsafe.Pointer(avPacketGetSideData(uintptr(p.ptr), pktDataNewExtradata, &size))
+		if data != nil && size > 0 {
+			return append([]byte(nil), unsafe.Slice((*byte)(data), int(size))...), nil
+		}
+	}
+	return nil, nil
+}
+
+// parseParameterSets splits extradata into parameter set NAL units. It
+// accepts Annex B byte streams as well as avcC (H.264) and hvcC (HEVC)
+// configuration records.
+func parseParameterSets(codecID CodecID, extradata []byte) ([][]byte, error) {
+	if len(extradata) >= 3 && extradata[0] == 0 && extradata[1] == 0 {
+		var sets [][]byte
+		for _, nal := range splitAnnexB(extradata) {
+			if isParameterSetNAL(codecID, nal) {
+				sets = append(sets, nal)
+			}
+		}
+		if len(sets) == 0 {
+			return nil, errors.New("ffgo: extradata contains no parameter sets")
+		}
+		return sets, nil
+	}
+	if codecID == CodecIDHEVC {
+		return parseHVCC(extradata)
+	}
+	return parseAVCC(extradata)
+}
+
+// splitAnnexB returns the NAL units of an Annex B byte stream.
+func splitAnnexB(data []byte) [][]byte {
+	var nals [][]byte
+	start := -1
+	for i := 0; i+2 < len(data); i++ {
+		if data[i] != 0 || data[i+1] != 0 || data[i+2] != 1 {
+			continue
+		}
+		if start >= 0 {
+			nals = append(nals, trimTrailingZeros(data[start:i]))
+		}
+		start = i + 3
+		i += 2
+	}
+	if start >= 0 && start < len(data) {
+		nals = append(nals, data[start:])
+	}
+	return nals
+}
+
+// trimTrailingZeros drops the leading zero of a following 4-byte start code.
+func trimTrailingZeros(nal []byte) []byte {
+	for len(nal) > 0 && nal[len(nal)-1] == 0 {
+		nal = nal[:len(nal)-1]
+	}
+	return nal
+}
+
+func isParameterSetNAL(codecID CodecID, nal []byte) bool {
+	if len(nal) == 0 {
+		return false
+	}
+	if codecID == CodecIDHEVC {
+		switch (nal[0] >> 1) & 0x3f {
+		case 32, 33, 34: // VPS, SPS, PPS
+			return true
+		}
+		return false
+	}
+	switch nal[0] & 0x1f {
+	case 7, 8: // SPS, PPS
+		return true
+	}
+	return false
+}
+
+var errBadConfigRecord = errors.New("ffgo: malformed decoder configuration record")
+
+// parseAVCC extracts the SPS and PPS NAL units of an AVCDecoderConfigurationRecord.
+func parseAVCC(b []byte) ([][]byte, error) {
+	if len(b) < 7 || b[0] != 1 {
+		return nil, errBadConfigRecord
+	}
+	var sets [][]byte
+	pos := 5
+	for _, countMask := range []byte{0x1f, 0xff} {
+		if pos >= len(b) {
+			return nil, errBadConfigRecord
+		}
+		count := int(b[pos] & countMask)
+		pos++
+		for i := 0; i < count; i++ {
+			nal, next, ok := readLengthPrefixed(b, pos)
+			if !ok {
+				return nil, errBadConfigRecord
+			}
+			sets = append(sets, nal)
+			pos = next
+		}
+	}
+	return sets, nil
+}
+
+// parseHVCC extracts the VPS, SPS and PPS NAL units of an HEVCDecoderConfigurationRecord.
+func parseHVCC(b []byte) ([][]byte, error) {
+	if len(b) < 23 || b[0] != 1 {
+		return nil, errBadConfigRecord
+	}
+	var sets [][]byte
+	numArrays := int(b[22])
+	pos := 23
+	for a := 0; a < numArrays; a++ {
+		if pos+3 > len(b) {
+			return nil, errBadConfigRecord
+		}
+		nalType := b[pos] & 0x3f
+		count := int(b[pos+1])<<8 | int(b[pos+2])
+		pos += 3
+		for i := 0; i < count; i++ {
+			nal, next, ok := readLengthPrefixed(b, pos)
+			if !ok {
+				return nil, errBadConfigRecord
+			}
+			if nalType >= 32 && nalType <= 34 {
+				sets = append(sets, nal)
+			}
+			pos = next
+		}
+	}
+	return sets, nil
+}
+
+// readLengthPrefixed reads a NAL unit preceded by a 16-bit big-endian length.
+func readLengthPrefixed(b []byte, pos int) (nal []byte, next int, ok bool) {
+	if pos+2 > len(b) {
+		return nil, 0, false
+	}
+	n := int(b[pos])<<8 | int(b[pos+1])
+	pos += 2
+	if pos+n > len(b) {
+		return nil, 0, false
+	}
+	return append([]byte(nil), b[pos:pos+n]...), pos + n, true
+}
@@ -0,0 +1,85 @@
+//go:build !ios && !android && (amd64 || arm64)
+
+package ffgo
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestParseParameterSets(t *testing.T) {
+	sps := []byte{0x67, 0x42, 0xc0, 0x1e, 0xd9}
+	pps := []byte{0x68, 0xce, 0x3c, 0x80}
+
+	avcc := []byte{1, 0x42, 0xc0, 0x1e, 0xff, 0xe1, 0, byte(len(sps))}
+	avcc = append(avcc, sps...)
+	avcc = append(avcc, 1, 0, byte(len(pps)))
+	avcc = append(avcc, pps...)
+
+	var annexB []byte
+	annexB = append(annexB, 0, 0, 0, 1)
+	annexB = append(annexB, sps...)
+	annexB = append(annexB, 0, 0, 1)
+	annexB = append(annexB, pps...)
+	annexB = append(annexB, 0, 0, 0, 1, 0x65, 0x88) // IDR slice, not a parameter set
+
+	for name, extradata := range map[string][]byte{"avcC": avcc, "Annex B": annexB} {
+		sets, err := parseParameterSets(CodecIDH264, extradata)
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		if len(sets) != 2 || !bytes.Equal(sets[0], sps) || !bytes.Equal(sets[1], pps) {
+			t.Errorf("%s: got % x, want SPS and PPS", name, sets)
+		}
+	}
+
+	vps := []byte{0x40, 0x01, 0x0c}
+	hvcc := make([]byte, 22, 64)
+	hvcc[0] = 1
+	hvcc = append(hvcc, 1, 0x20, 0, 1, 0, byte(len(vps)))
+	hvcc = append(hvcc, vps...)
+	sets, err := parseParameterSets(CodecIDHEVC, hvcc)
+	if err != nil || len(sets) != 1 || !bytes.Equal(sets[0], vps) {
+		t.Errorf("hvcC: got % x, %v; want VPS", sets, err)
+	}
+
+	if _, err := parseParameterSets(CodecIDH264, avcc[:len(avcc)-2]); err == nil {
+		t.Error("truncated avcC parsed without error")
+	}
+}
+
+func TestExtractParameterSets(t *testing.T) {
+	if !requireFFmpeg(t) {
+		return
+	}
+	decoder, err := NewDecoder(createTestVideo(t))
+	if err != nil {
+		t.Fatalf("Failed to open file: %v", err)
+	}
+	defer decoder.Close()
+	video := decoder.VideoStream()
+	if video == nil || video.CodecID != CodecIDH264 {
+		t.Log("test input is not H.264")
+		return
+	}
+
+	if len(decoder.VideoExtradata()) == 0 {
+		t.Fatal("VideoExtradata returned nothing for an MP4 H.264 stream")
+	}
+	sets, err := ExtractParameterSets(video.CodecParameters(), nil)
+	if err != nil {
+		t.Fatalf("ExtractParameterSets failed: %v", err)
+	}
+	var sawSPS, sawPPS bool
+	for _, nal := range sets {
+		switch nal[0] & 0x1f {
+		case 7:
+			sawSPS = true
+		case 8:
+			sawPPS = true
+		}
+	}
+	if !sawSPS || !sawPPS {
+		t.Errorf("got NAL types % x, want an SPS and a PPS", sets)
+	}
+}
@@ -0,0 +1,174 @@
+//go:build !ios && !android && (amd64 || arm64)
+
+package ffgo
+
+import (
+	"errors"
+	"math"
+	"math/bits"
+	"sort"
+	"unsafe"
+
+	"github.com/obinnaokechukwu/ffgo/avutil"
+)
+
+// pHash parameters: the frame is reduced to a 32x32 grayscale image and the
+// hash is built from the 8x8 lowest DCT frequencies.
+const (
+	phashSize     = 32
+	phashLowFreqs = 8
+)
+
+// phashCos[u][x] = cos((2x+1)uπ / 2N), the DCT-II basis.
+var phashCos = func() (t [phashLowFreqs][phashSize]float64) {
+	for u := 0; u < phashLowFreqs; u++ {
+		for x := 0; x < phashSize; x++ {
+			t[u][x] = math.Cos(float64(2*x+1) * float64(u) * math.Pi / (2 * phashSize))
+		}
+	}
+	return t
+}()
+
+// PerceptualHash computes a 64-bit DCT-based perceptual hash of a video
+// frame. Visually similar frames (re-encoded, rescaled, slightly color
+// shifted) produce hashes with a small Hamming distance; compare them with
+// PerceptualHashDistance.
+//
+// The frame is scaled to 32x32 grayscale, transformed with a 2D DCT, and
+// each of the 8x8 lowest-frequency coefficients contributes one bit: set if
+// it is above the median of the 64. The frame is not modified.
+func (f Frame) PerceptualHash() (uint64, error) {
+	if f.ptr == nil {
+		return 0, errors.New("ffgo: frame is nil")
+	}
+	w := int(avutil.GetFrameWidth(f.ptr))
+	h := int(avutil.GetFrameHeight(f.ptr))
+	if w <= 0 || h <= 0 {
+		return 0, errors.New("ffgo: PerceptualHash requires a video frame")
+	}
+
+	scaler, err := NewScaler(w, h, PixelFormat(avutil.GetFrameFormat(f.ptr)), phashSize, phashSize, PixelFormatGray8, ScaleBilinear)
+	if err != nil {
+		return 0, err
+	}
+	defer scaler.Close()
+
+	gray, err := scaler.Scale(f)
+	if err != nil {
+		return 0, err
+	}
+	data := avutil.GetFrameDataPlane(gray.ptr, 0)
+	stride := int(avutil.GetFrameLinesizePlane(gray.ptr, 0))
+	if data == nil || stride < phashSize {
+		return 0, errors.New("ffgo: failed to read scaled frame")
+	}
+	pixels := unsafe.Slice((*byte)(data), stride*phashSize)
+
+	var img [phashSize][phashSize]float64
+	for y := 0; y < phashSize; y++ {
+		for x := 0; x < phashSize; x++ {
+			img[y][x] = float64(pixels[y*stride+x])
+		}
+	}
+	return phashFromPixels(&img), nil
+}
+
+// phashFromPixels computes the hash from a 32x32 grayscale image.
+func phashFromPixels(img *[phashSize][phashSize]float64) uint64 {
+	// Separable DCT-II, computing only the low frequencies we keep:
+	// rows first, then columns.
+	var rows [phashSize][phashLowFreqs]float64
+	for y := 0; y < phashSize; y++ {
+		for u := 0; u < phashLowFreqs; u++ {
+			var sum float64
+			for x := 0; x < phashSize; x++ {
+				sum += img[y][x] * phashCos[u][x]
+			}
+			rows[y][u] = sum
+		}
+	}
+
+	var coeffs [phashLowFreqs * phashLowFreqs]float64
+	for v := 0; v < phashLowFreqs; v++ {
+		for u := 0; u < phashLowFreqs; u++ {
+			var sum float64
+			for y := 0; y < phashSize; y++ {
+				sum += rows[y][u] * phashCos[v][y]
+			}
+			coeffs[v*phashLowFreqs+u] = sum
+		}
+	}
+
+	sorted := coeffs
+	sort.Float64s(sorted[:])
+	median := (sorted[len(sorted)/2-1] + sorted[len(sorted)/2]) / 2
+
+	var hash uint64
+	for i, c := range coeffs {
+		if c > median {
+			hash |= 1 << uint(i)
+		}
+	}
+	return hash
+}
+
+// PerceptualHashDistance returns the Hamming distance between two perceptual
+// hashes (0 = identical, 64 = maximally different). Distances below about 10
+// usually indicate the same picture.
+func PerceptualHashDistance(a, b uint64) int {
+	return bits.OnesCount64(a ^ b)
+}
+
+// VideoFingerprint returns the perceptual hashes of up to samples keyframes
+// spread evenly across the video, in presentation order. Fewer hashes are
+// returned if the video has fewer keyframes.
+//
+// Keyframes are used because they decode without reference to other frames
+// and are placed at similar content positions by most encoders, which makes
+// fingerprints of re-encoded copies line up. The decoder is left positioned
+// after the last sampled keyframe.
+func (d *Decoder) VideoFingerprint(samples int) ([]uint64, error) {
+	if samples <= 0 {
+		return nil, errors.New("ffgo: samples must be positive")
+	}
+	if err := d.OpenVideoDecoder(); err != nil {
+		return nil, err
+	}
+
+	keyframes, err := d.GetKeyframes()
+	if err != nil {
+		return nil, err
+	}
+	if len(keyframes) == 0 {
+		return nil, errors.New("ffgo: no keyframes found")
+	}
+
+	picks := keyframes
+	if len(keyframes) > samples {
+		picks = make([]Keyframe, samples)
+		for i := range picks {
+			picks[i] = keyframes[i*len(keyframes)/samples]
+		}
+	}
+
+	hashes := make([]uint64, 0, len(picks))
+	for _, kf := range picks {
+		if err := d.SeekKeyframe(kf.Time); err != nil {
+			return nil, err
+		}
+		frame, err := d.DecodeVideo()
+		if err != nil {
+			return nil, err
+		}
+		if frame.IsNil() {
+			break
+		}
+		hash, err := frame.PerceptualHash()
+		if err != nil {
+			return nil, err
+		}
+		hashes = append(hashes, hash)
+	}
+
+	return hashes, nil
+}
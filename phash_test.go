@@ -0,0 +1,66 @@
+//go:build !ios && !android && (amd64 || arm64)
+
+package ffgo
+
+import (
+	"math"
+	"path/filepath"
+	"testing"
+)
+
+func TestPerceptualHashFromPixels(t *testing.T) {
+	var scene, brighter, checker [phashSize][phashSize]float64
+	for y := 0; y < phashSize; y++ {
+		for x := 0; x < phashSize; x++ {
+			scene[y][x] = 100 + 60*math.Sin(float64(x)/5)*math.Cos(float64(y)/7) + float64(2*x)
+			brighter[y][x] = scene[y][x]*0.9 + 20
+			if (x/4+y/4)%2 == 0 {
+				checker[y][x] = 255
+			}
+		}
+	}
+
+	a := phashFromPixels(&scene)
+	if d := PerceptualHashDistance(a, phashFromPixels(&scene)); d != 0 {
+		t.Errorf("identical images have distance %d", d)
+	}
+	if d := PerceptualHashDistance(a, phashFromPixels(&brighter)); d > 10 {
+		t.Errorf("brightness-shifted image has distance %d, want <= 10", d)
+	}
+	if d := PerceptualHashDistance(a, phashFromPixels(&checker)); d < 20 {
+		t.Errorf("different images have distance %d, want >= 20", d)
+	}
+}
+
+func TestVideoFingerprint(t *testing.T) {
+	if !requireFFmpeg(t) {
+		return
+	}
+	in := filepath.Join("testdata", "test.mp4")
+
+	fingerprint := func() []uint64 {
+		d, err := NewDecoder(in)
+		if err != nil {
+			t.Fatalf("NewDecoder failed: %v", err)
+		}
+		defer d.Close()
+		hashes, err := d.VideoFingerprint(4)
+		if err != nil {
+			t.Fatalf("VideoFingerprint failed: %v", err)
+		}
+		return hashes
+	}
+
+	first, second := fingerprint(), fingerprint()
+	if len(first) == 0 || len(first) > 4 {
+		t.Fatalf("got %d hashes, want 1..4", len(first))
+	}
+	if len(first) != len(second) {
+		t.Fatalf("fingerprint length changed: %d vs %d", len(first), len(second))
+	}
+	for i := range first {
+		if first[i] != second[i] {
+			t.Errorf("hash %d differs between runs: %016x vs %016x", i, first[i], second[i])
+		}
+	}
+}
@@ -4,6 +4,7 @@ package ffgo
 
 import (
 	"errors"
+	"fmt"
 	"sync"
 	"sync/atomic"
 	"unsafe"
@@ -16,12 +17,22 @@ import (
 // FramePool reuses AVFrame allocations to reduce GC/FFmpeg allocation churn.
 //
 // Frames returned from Get() are OWNED by the caller and must be returned via Put().
+//
+// Frames obtained with GetVideoFrame or GetAudioFrame keep their data
+// buffers when put back, as long as nothing else still references them, so
+// a later request for the same geometry reuses both the AVFrame and its
+// buffers. This is what Scaler.ScalePooled and Resampler.ConvertPooled rely
+// on to run without per-frame allocations.
 type FramePool struct {
 	mu       sync.Mutex
 	idle     []avutil.Frame
 	closed   bool
 	inUse    int
 	maxInUse int
+
+	// bufs records data[0] of the buffers the pool allocated for each
+	// frame, so Put only keeps buffers it handed out itself.
+	bufs map[avutil.Frame]unsafe.Pointer
 }
 
 // NewFramePool creates a new pool. If maxInUse <= 0, the pool is unbounded.
@@ -29,36 +40,147 @@ func NewFramePool(maxInUse int) *FramePool {
 	return &FramePool{maxInUse: maxInUse}
 }
 
-// Get returns an owned frame from the pool.
+// Get returns an owned, empty frame from the pool.
 func (p *FramePool) Get() (Frame, error) {
 	p.mu.Lock()
 	defer p.mu.Unlock()
 
+	fr, err := p.takeLocked(nil)
+	if err != nil {
+		return Frame{}, err
+	}
+	p.inUse++
+	return Frame{ptr: fr, owned: true}, nil
+}
+
+// GetVideoFrame returns an owned video frame with buffers allocated for the
+// given size and pixel format. An idle frame with matching buffers is reused
+// as is, so the pixel contents are undefined; other fields such as PTS are
+// reset.
+func (p *FramePool) GetVideoFrame(width, height int, pixFmt PixelFormat) (Frame, error) {
+	if width <= 0 || height <= 0 {
+		return Frame{}, errors.New("ffgo: invalid dimensions")
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	fr, err := p.takeLocked(func(fr avutil.Frame) bool {
+		return int(avutil.GetFrameWidth(fr)) == width &&
+			int(avutil.GetFrameHeight(fr)) == height &&
+			PixelFormat(avutil.GetFrameFormat(fr)) == pixFmt
+	})
+	if err != nil {
+		return Frame{}, err
+	}
+	if avutil.GetFrameDataPlane(fr, 0) == nil {
+		avutil.SetFrameWidth(fr, int32(width))
+		avutil.SetFrameHeight(fr, int32(height))
+		avutil.SetFrameFormat(fr, int32(pixFmt))
+		if err := p.allocBuffersLocked(fr); err != nil {
+			return Frame{}, err
+		}
+	}
+	p.inUse++
+	return Frame{ptr: fr, owned: true}, nil
+}
+
+// GetAudioFrame returns an owned audio frame in format f with buffers for at
+// least capacity samples per channel, suitable for Resampler.ConvertInto.
+// An idle frame with matching buffers is reused as is.
+func (p *FramePool) GetAudioFrame(f AudioFormat, capacity int) (Frame, error) {
+	if capacity <= 0 {
+		return Frame{}, fmt.Errorf("ffgo: invalid frame capacity: %d", capacity)
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	fr, err := p.takeLocked(func(fr avutil.Frame) bool {
+		return SampleFormat(avutil.GetFrameFormat(fr)) == f.SampleFormat &&
+			int(avutil.GetFrameChannels(fr)) == f.Channels &&
+			int(avutil.GetFrameSampleRate(fr)) == f.SampleRate &&
+			audioFrameCapacity(Frame{ptr: fr}, f) >= capacity
+	})
+	if err != nil {
+		return Frame{}, err
+	}
+	if avutil.GetFrameDataPlane(fr, 0) == nil {
+		avutil.FrameSetSampleRate(fr, int32(f.SampleRate))
+		setFrameChannelLayout(fr, f)
+		avutil.FrameSetFormat(fr, int32(f.SampleFormat))
+		avutil.FrameSetNbSamples(fr, int32(capacity))
+		if err := p.allocBuffersLocked(fr); err != nil {
+			return Frame{}, err
+		}
+	} else {
+		avutil.FrameSetNbSamples(fr, int32(capacity))
+	}
+	p.inUse++
+	return Frame{ptr: fr, owned: true}, nil
+}
+
+// takeLocked removes a frame from the idle list, or allocates one. If match
+// is non-nil, the most recently returned idle frame that still has buffers
+// and satisfies match is taken with its buffers intact; otherwise the frame
+// is unreferenced before it is returned. It does not count the frame as in
+// use.
+func (p *FramePool) takeLocked(match func(avutil.Frame) bool) (avutil.Frame, error) {
 	if p.closed {
-		return Frame{}, errors.New("ffgo: frame pool is closed")
+		return nil, errors.New("ffgo: frame pool is closed")
 	}
 	if p.maxInUse > 0 && p.inUse >= p.maxInUse {
-		return Frame{}, errors.New("ffgo: frame pool exhausted")
+		return nil, errors.New("ffgo: frame pool exhausted")
+	}
+
+	if match != nil {
+		for i := len(p.idle) - 1; i >= 0; i-- {
+			fr := p.idle[i]
+			if p.bufs[fr] == nil || !match(fr) {
+				continue
+			}
+			p.idle = append(p.idle[:i], p.idle[i+1:]...)
+			avutil.SetFramePTS(fr, avutil.NoPTSValue)
+			return fr, nil
+		}
 	}
 
 	var fr avutil.Frame
-	n := len(p.idle)
-	if n > 0 {
+	if n := len(p.idle); n > 0 {
 		fr = p.idle[n-1]
 		p.idle = p.idle[:n-1]
 	} else {
 		fr = avutil.FrameAlloc()
 		if fr == nil {
-			return Frame{}, ErrOutOfMemory
+			return nil, ErrOutOfMemory
 		}
 	}
-
 	avutil.FrameUnref(fr)
-	p.inUse++
-	return Frame{ptr: fr, owned: true}, nil
+	delete(p.bufs, fr)
+	return fr, nil
+}
+
+// allocBuffersLocked allocates buffers for the blank frame fr, whose
+// geometry is already set, and records them as pool-owned. On failure fr
+// goes back to the idle list.
+func (p *FramePool) allocBuffersLocked(fr avutil.Frame) error {
+	if err := avutil.FrameGetBufferErr(fr, 0); err != nil {
+		avutil.FrameUnref(fr)
+		p.idle = append(p.idle, fr)
+		return err
+	}
+	if p.bufs == nil {
+		p.bufs = make(map[avutil.Frame]unsafe.Pointer)
+	}
+	p.bufs[fr] = avutil.GetFrameDataPlane(fr, 0)
+	return nil
 }
 
 // Put returns an owned frame to the pool and clears the caller's reference.
+//
+// Buffers the pool allocated are kept for reuse if no other frame still
+// references them; anything else (e.g. references taken with av_frame_ref
+// or buffers attached by WrapBuffer) is released.
 func (p *FramePool) Put(f *Frame) error {
 	if p == nil {
 		return nil
@@ -75,13 +197,18 @@ func (p *FramePool) Put(f *Frame) error {
 
 	if p.closed {
 		// Pool is closed: free the frame.
+		delete(p.bufs, f.ptr)
 		avutil.FrameFree(&f.ptr)
 		f.ptr = nil
 		f.owned = false
 		return nil
 	}
 
-	avutil.FrameUnref(f.ptr)
+	data := p.bufs[f.ptr]
+	if data == nil || avutil.GetFrameDataPlane(f.ptr, 0) != data || !avutil.FrameIsWritable(f.ptr) {
+		avutil.FrameUnref(f.ptr)
+		delete(p.bufs, f.ptr)
+	}
 	p.idle = append(p.idle, f.ptr)
 	p.inUse--
 
@@ -90,7 +217,8 @@ func (p *FramePool) Put(f *Frame) error {
 	return nil
 }
 
-// Close releases all idle frames in the pool. Frames still in use are not affected.
+// Close releases all idle frames in the pool, including their buffers.
+// Frames still in use are not affected; they are freed when they are Put.
 func (p *FramePool) Close() error {
 	p.mu.Lock()
 	defer p.mu.Unlock()
@@ -102,6 +230,7 @@ func (p *FramePool) Close() error {
 	for i := range p.idle {
 		fr := p.idle[i]
 		if fr != nil {
+			delete(p.bufs, fr)
 			avutil.FrameFree(&fr)
 		}
 	}
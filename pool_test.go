@@ -100,3 +100,209 @@ func TestFrameWrapBuffer_MemoryLimit(t *testing.T) {
 		t.Fatalf("expected WrapBuffer to fail due to memory limit")
 	}
 }
+
+func TestRefFrame_RetainRelease(t *testing.T) {
+	if !requireFFmpeg(t) {
+		return
+	}
+
+	src := FrameAlloc()
+	defer FrameFree(&src)
+	avutil.SetFrameWidth(src.ptr, 16)
+	avutil.SetFrameHeight(src.ptr, 16)
+	avutil.SetFrameFormat(src.ptr, int32(PixelFormatYUV420P))
+	if err := avutil.FrameGetBufferErr(src.ptr, 0); err != nil {
+		t.Fatalf("FrameGetBuffer failed: %v", err)
+	}
+
+	p := NewFramePool(1)
+	defer p.Close()
+
+	rf, err := p.GetRef(src)
+	if err != nil {
+		t.Fatalf("GetRef failed: %v", err)
+	}
+	if got := avutil.GetFrameDataPlane(rf.Frame().ptr, 0); got != avutil.GetFrameDataPlane(src.ptr, 0) {
+		t.Fatalf("RefFrame does not share source buffers")
+	}
+
+	if err := rf.Retain(); err != nil {
+		t.Fatalf("Retain failed: %v", err)
+	}
+	if rf.RefCount() != 2 {
+		t.Fatalf("RefCount: got %d want 2", rf.RefCount())
+	}
+	if err := rf.Release(); err != nil {
+		t.Fatalf("Release failed: %v", err)
+	}
+	// Still referenced, so the pool's only frame is in use.
+	if _, err := p.Get(); err == nil {
+		t.Fatalf("expected pool exhausted while RefFrame is held")
+	}
+	if err := rf.Release(); err != nil {
+		t.Fatalf("final Release failed: %v", err)
+	}
+	if err := rf.Release(); err == nil {
+		t.Fatalf("expected error on over-release")
+	}
+	if err := rf.Retain(); err == nil {
+		t.Fatalf("expected error retaining a released RefFrame")
+	}
+	if rf.RefCount() != 0 {
+		t.Fatalf("RefCount after failed Retain: got %d want 0", rf.RefCount())
+	}
+	if _, err := p.Get(); err != nil {
+		t.Fatalf("Get after final Release failed: %v", err)
+	}
+}
+
+func TestFramePool_ReusesBuffers(t *testing.T) {
+	if !requireFFmpeg(t) {
+		return
+	}
+
+	p := NewFramePool(0)
+	defer p.Close()
+
+	f1, err := p.GetVideoFrame(32, 16, PixelFormatRGBA)
+	if err != nil {
+		t.Fatalf("GetVideoFrame failed: %v", err)
+	}
+	data := avutil.GetFrameDataPlane(f1.ptr, 0)
+	if data == nil {
+		t.Fatalf("GetVideoFrame returned frame without buffers")
+	}
+	if err := p.Put(&f1); err != nil {
+		t.Fatalf("Put failed: %v", err)
+	}
+
+	f2, err := p.GetVideoFrame(32, 16, PixelFormatRGBA)
+	if err != nil {
+		t.Fatalf("GetVideoFrame failed: %v", err)
+	}
+	if got := avutil.GetFrameDataPlane(f2.ptr, 0); got != data {
+		t.Fatalf("expected buffers to be reused")
+	}
+
+	// A different geometry must not get the cached buffers.
+	f3, err := p.GetVideoFrame(16, 16, PixelFormatRGBA)
+	if err != nil {
+		t.Fatalf("GetVideoFrame failed: %v", err)
+	}
+	if got := int(avutil.GetFrameWidth(f3.ptr)); got != 16 {
+		t.Fatalf("width: got %d want 16", got)
+	}
+	_ = p.Put(&f3)
+
+	// A frame whose buffers are shared must not keep them.
+	ref, err := FrameClone(f2)
+	if err != nil {
+		t.Fatalf("FrameClone failed: %v", err)
+	}
+	defer FrameFree(&ref)
+	_ = p.Put(&f2)
+	f4, err := p.GetVideoFrame(32, 16, PixelFormatRGBA)
+	if err != nil {
+		t.Fatalf("GetVideoFrame failed: %v", err)
+	}
+	defer p.Put(&f4)
+	if got := avutil.GetFrameDataPlane(f4.ptr, 0); got == data {
+		t.Fatalf("shared buffers were reused")
+	}
+}
+
+func TestScalerScalePooled(t *testing.T) {
+	if !requireFFmpeg(t) {
+		return
+	}
+
+	s, err := NewScaler(64, 48, PixelFormatYUV420P, 32, 24, PixelFormatRGBA, ScaleBilinear)
+	if err != nil {
+		t.Fatalf("NewScaler failed: %v", err)
+	}
+	defer s.Close()
+
+	src, err := newVideoFrame(64, 48, PixelFormatYUV420P)
+	if err != nil {
+		t.Fatalf("newVideoFrame failed: %v", err)
+	}
+	defer FrameFree(&src)
+	avutil.SetFramePTS(src.ptr, 42)
+
+	p := NewFramePool(2)
+	defer p.Close()
+
+	var data unsafe.Pointer
+	for i := 0; i < 3; i++ {
+		out, err := s.ScalePooled(p, src)
+		if err != nil {
+			t.Fatalf("ScalePooled failed: %v", err)
+		}
+		if w, h := avutil.GetFrameWidth(out.ptr), avutil.GetFrameHeight(out.ptr); w != 32 || h != 24 {
+			t.Fatalf("size: got %dx%d want 32x24", w, h)
+		}
+		if got := avutil.GetFramePTS(out.ptr); got != 42 {
+			t.Fatalf("pts: got %d want 42", got)
+		}
+		if i == 0 {
+			data = avutil.GetFrameDataPlane(out.ptr, 0)
+		} else if avutil.GetFrameDataPlane(out.ptr, 0) != data {
+			t.Fatalf("iteration %d did not reuse pooled buffers", i)
+		}
+		if err := p.Put(&out); err != nil {
+			t.Fatalf("Put failed: %v", err)
+		}
+	}
+}
+
+func TestResamplerConvertPooled(t *testing.T) {
+	if !requireFFmpeg(t) {
+		return
+	}
+
+	src := AudioFormat{SampleRate: 48000, Channels: 2, SampleFormat: SampleFormatS16}
+	dst := AudioFormat{SampleRate: 48000, Channels: 1, SampleFormat: SampleFormatFLTP}
+	r, err := NewResampler(src, dst)
+	if err != nil {
+		t.Fatalf("NewResampler failed: %v", err)
+	}
+	defer r.Close()
+
+	in, err := newAudioFrame(src, 1024)
+	if err != nil {
+		t.Fatalf("newAudioFrame failed: %v", err)
+	}
+	defer FrameFree(&in)
+
+	p := NewFramePool(1)
+	defer p.Close()
+
+	total := 0
+	for i := 0; i < 4; i++ {
+		out, err := r.ConvertPooled(p, in)
+		if err != nil {
+			t.Fatalf("ConvertPooled failed: %v", err)
+		}
+		if out.IsNil() {
+			continue
+		}
+		total += int(avutil.GetFrameNbSamples(out.ptr))
+		if err := p.Put(&out); err != nil {
+			t.Fatalf("Put failed: %v", err)
+		}
+	}
+	for {
+		out, err := r.ConvertPooled(p, Frame{})
+		if err != nil {
+			t.Fatalf("ConvertPooled drain failed: %v", err)
+		}
+		if out.IsNil() {
+			break
+		}
+		total += int(avutil.GetFrameNbSamples(out.ptr))
+		_ = p.Put(&out)
+	}
+	if total != 4*1024 {
+		t.Fatalf("converted %d samples, want %d", total, 4*1024)
+	}
+}
@@ -6,6 +6,8 @@ import (
 	"errors"
 	"sort"
 	"strings"
+	"time"
+	"unsafe"
 
 	"github.com/obinnaokechukwu/ffgo/avformat"
 	"github.com/obinnaokechukwu/ffgo/avutil"
@@ -61,10 +63,19 @@ func (d *Decoder) ProbeScore() int {
 	return avformat.GetProbeScore(d.formatCtx)
 }
 
-func openInputWithRetries(path string, opts *DecoderOptions) (avformat.FormatContext, error) {
+// openInputWithRetries opens path as opts describe. A non-nil interrupt flag
+// is installed on the format context before avformat_open_input, which
+// copies the interrupt callback into the I/O contexts it opens.
+func openInputWithRetries(path string, opts *DecoderOptions, interrupt unsafe.Pointer) (avformat.FormatContext, error) {
 	var (
 		avOpts = buildDecoderAVOptions(opts)
 	)
+	if looksLikeURL(path) {
+		avformat.NetworkInit()
+		if avOpts != nil {
+			addNetworkAVOptions(avOpts, path, opts, bindings.AVFormatVersion()>>16)
+		}
+	}
 
 	// Optional explicit format hint (no retries).
 	var forcedFmt avformat.InputFormat
@@ -73,7 +84,7 @@ func openInputWithRetries(path string, opts *DecoderOptions) (avformat.FormatCon
 		if forcedFmt == nil {
 			return nil, errors.New("ffgo: input format not found")
 		}
-		ctx, err := openInputOnce(path, forcedFmt, avOpts)
+		ctx, err := openInputOnce(path, forcedFmt, avOpts, interrupt)
 		if err != nil {
 			return nil, err
 		}
@@ -87,7 +98,7 @@ func openInputWithRetries(path string, opts *DecoderOptions) (avformat.FormatCon
 	}
 
 	// First try auto-detection.
-	ctx, err := openInputOnce(path, nil, avOpts)
+	ctx, err := openInputOnce(path, nil, avOpts, interrupt)
 	if err == nil {
 		if opts == nil || opts.ProbeScore <= 0 {
 			return ctx, nil
@@ -112,7 +123,7 @@ func openInputWithRetries(path string, opts *DecoderOptions) (avformat.FormatCon
 		if fmt == nil {
 			continue
 		}
-		ctx2, err2 := openInputOnce(path, fmt, avOpts)
+		ctx2, err2 := openInputOnce(path, fmt, avOpts, interrupt)
 		if err2 != nil {
 			err = err2
 			continue
@@ -131,7 +142,7 @@ func openInputWithRetries(path string, opts *DecoderOptions) (avformat.FormatCon
 	return nil, err
 }
 
-func openInputOnce(path string, fmt avformat.InputFormat, avOpts map[string]string) (avformat.FormatContext, error) {
+func openInputOnce(path string, fmt avformat.InputFormat, avOpts map[string]string, interrupt unsafe.Pointer) (avformat.FormatContext, error) {
 	var dict avutil.Dictionary
 	for k, v := range avOpts {
 		if v == "" {
@@ -151,6 +162,17 @@ func openInputOnce(path string, fmt avformat.InputFormat, avOpts map[string]stri
 	}()
 
 	var ctx avformat.FormatContext
+	if interrupt != nil {
+		ctx = avformat.AllocContext()
+		if ctx == nil {
+			return nil, ErrOutOfMemory
+		}
+		if err := avformat.SetInterruptFlag(ctx, interrupt); err != nil {
+			avformat.FreeContext(ctx)
+			return nil, err
+		}
+	}
+	// On failure avformat_open_input frees a pre-allocated context.
 	if err := avformat.OpenInput(&ctx, path, fmt, &dict); err != nil {
 		return nil, err
 	}
@@ -206,3 +228,90 @@ func candidateDemuxers(opts *DecoderOptions) []string {
 	}
 	return out
 }
+
+// ProbeResult is a lightweight summary of a media file produced by Probe.
+type ProbeResult struct {
+	// Path is the probed input URL/path.
+	Path string
+
+	// Format is the demuxer short name (e.g. "mov", "matroska").
+	Format string
+
+	// FormatLongName is the demuxer's long name (if available).
+	FormatLongName string
+
+	// ProbeScore is FFmpeg's probe confidence score for the selected demuxer.
+	ProbeScore int
+
+	// Duration is the container duration, or 0 if unknown.
+	Duration time.Duration
+
+	// BitRate is the container bit rate in bits/s, or 0 if unknown.
+	BitRate int64
+
+	// Streams summarizes every stream in the container. The entries are
+	// detached from FFmpeg, so CodecParameters returns nil.
+	Streams []*StreamInfo
+}
+
+// Probe opens path, reads the container header and returns a summary of the
+// format and its streams, then closes the input immediately.
+//
+// Unlike NewDecoder it does not call avformat_find_stream_info and does not
+// allocate any codecs, which makes it considerably cheaper for network
+// sources and library scans. The trade-off is that fields FFmpeg only learns
+// by decoding (e.g. duration of some raw streams, frame rate of elementary
+// streams) may be zero. opts may be nil.
+func Probe(path string, opts *DecoderOptions) (*ProbeResult, error) {
+	if err := bindings.Load(); err != nil {
+		return nil, err
+	}
+	if strings.TrimSpace(path) == "" {
+		return nil, errors.New("ffgo: path cannot be empty")
+	}
+
+	var (
+		in   decoderInterrupt
+		flag unsafe.Pointer
+		err  error
+	)
+	if opts != nil && opts.InterruptContext != nil {
+		if flag, err = in.arm(opts.InterruptContext); err != nil {
+			return nil, err
+		}
+	}
+	defer in.free()
+
+	ctx, err := openInputWithRetries(path, opts, flag)
+	if err != nil {
+		return nil, in.err(err)
+	}
+	defer avformat.CloseInput(&ctx)
+
+	ifmt := avformat.GetInputFormat(ctx)
+	result := &ProbeResult{
+		Path:           path,
+		Format:         avformat.InputFormatName(ifmt),
+		FormatLongName: avformat.InputFormatLongName(ifmt),
+		ProbeScore:     avformat.GetProbeScore(ctx),
+	}
+	if us := avformat.GetDuration(ctx); us > 0 {
+		result.Duration = time.Duration(us) * time.Microsecond
+	}
+	if br := avformat.GetBitRate(ctx); br > 0 {
+		result.BitRate = br
+	}
+
+	n := avformat.GetNumStreams(ctx)
+	result.Streams = make([]*StreamInfo, 0, n)
+	for i := 0; i < n; i++ {
+		info := streamInfoFromContext(ctx, i)
+		if info == nil {
+			continue
+		}
+		info.codecPar = nil
+		result.Streams = append(result.Streams, info)
+	}
+
+	return result, nil
+}
@@ -0,0 +1,239 @@
+//go:build !ios && !android && (amd64 || arm64)
+
+package ffgo
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+
+	"github.com/obinnaokechukwu/ffgo/avcodec"
+	"github.com/obinnaokechukwu/ffgo/avformat"
+	"github.com/obinnaokechukwu/ffgo/avutil"
+)
+
+// ffprobeOutput mirrors the top-level object of `ffprobe -print_format json
+// -show_format -show_streams`.
+type ffprobeOutput struct {
+	Streams []ffprobeStream `json:"streams"`
+	Format  ffprobeFormat   `json:"format"`
+}
+
+// ffprobeStream mirrors an entry of ffprobe's "streams" array. Like ffprobe,
+// numeric values that may be fractional or unknown are encoded as strings.
+type ffprobeStream struct {
+	Index        int               `json:"index"`
+	CodecName    string            `json:"codec_name,omitempty"`
+	CodecType    string            `json:"codec_type"`
+	CodecTag     string            `json:"codec_tag"`
+	Width        int               `json:"width,omitempty"`
+	Height       int               `json:"height,omitempty"`
+	PixFmt       string            `json:"pix_fmt,omitempty"`
+	SampleFmt    string            `json:"sample_fmt,omitempty"`
+	SampleRate   string            `json:"sample_rate,omitempty"`
+	Channels     int               `json:"channels,omitempty"`
+	RFrameRate   string            `json:"r_frame_rate"`
+	AvgFrameRate string            `json:"avg_frame_rate"`
+	TimeBase     string            `json:"time_base"`
+	StartPTS     *int64            `json:"start_pts,omitempty"`
+	StartTime    string            `json:"start_time,omitempty"`
+	DurationTS   *int64            `json:"duration_ts,omitempty"`
+	Duration     string            `json:"duration,omitempty"`
+	BitRate      string            `json:"bit_rate,omitempty"`
+	NbFrames     string            `json:"nb_frames,omitempty"`
+	Disposition  map[string]int    `json:"disposition"`
+	Tags         map[string]string `json:"tags,omitempty"`
+}
+
+// ffprobeFormat mirrors ffprobe's "format" object.
+type ffprobeFormat struct {
+	Filename       string            `json:"filename"`
+	NbStreams      int               `json:"nb_streams"`
+	NbPrograms     int               `json:"nb_programs"`
+	FormatName     string            `json:"format_name"`
+	FormatLongName string            `json:"format_long_name,omitempty"`
+	StartTime      string            `json:"start_time,omitempty"`
+	Duration       string            `json:"duration,omitempty"`
+	BitRate        string            `json:"bit_rate,omitempty"`
+	ProbeScore     int               `json:"probe_score"`
+	Tags           map[string]string `json:"tags,omitempty"`
+}
+
+// ffprobeDispositions lists the disposition keys ffprobe prints, in order.
+var ffprobeDispositions = []struct {
+	name string
+	flag int32
+}{
+	{"default", avformat.AV_DISPOSITION_DEFAULT},
+	{"dub", avformat.AV_DISPOSITION_DUB},
+	{"original", avformat.AV_DISPOSITION_ORIGINAL},
+	{"comment", avformat.AV_DISPOSITION_COMMENT},
+	{"lyrics", avformat.AV_DISPOSITION_LYRICS},
+	{"karaoke", avformat.AV_DISPOSITION_KARAOKE},
+	{"forced", avformat.AV_DISPOSITION_FORCED},
+	{"hearing_impaired", avformat.AV_DISPOSITION_HEARING_IMPAIRED},
+	{"visual_impaired", avformat.AV_DISPOSITION_VISUAL_IMPAIRED},
+	{"clean_effects", avformat.AV_DISPOSITION_CLEAN_EFFECTS},
+	{"attached_pic", avformat.AV_DISPOSITION_ATTACHED_PIC},
+	{"timed_thumbnails", avformat.AV_DISPOSITION_TIMED_THUMBNAILS},
+	{"non_diegetic", avformat.AV_DISPOSITION_NON_DIEGETIC},
+	{"captions", avformat.AV_DISPOSITION_CAPTIONS},
+	{"descriptions", avformat.AV_DISPOSITION_DESCRIPTIONS},
+	{"metadata", avformat.AV_DISPOSITION_METADATA},
+	{"dependent", avformat.AV_DISPOSITION_DEPENDENT},
+	{"still_image", avformat.AV_DISPOSITION_STILL_IMAGE},
+}
+
+// ProbeJSON returns a description of the input's format and streams in the
+// JSON layout produced by `ffprobe -print_format json -show_format
+// -show_streams`, so tooling that consumes ffprobe output can be fed directly
+// from an open Decoder.
+//
+// Only the commonly used fields are populated; fields ffgo cannot determine
+// are omitted, as ffprobe does for unknown values.
+func (d *Decoder) ProbeJSON() ([]byte, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if d.closed || d.formatCtx == nil {
+		return nil, errors.New("ffgo: decoder is closed")
+	}
+
+	out := ffprobeOutput{
+		Streams: []ffprobeStream{},
+		Format:  d.ffprobeFormatLocked(),
+	}
+	for i := 0; i < out.Format.NbStreams; i++ {
+		stream := avformat.GetStream(d.formatCtx, i)
+		info := streamInfoFromContext(d.formatCtx, i)
+		if stream == nil || info == nil {
+			continue
+		}
+		out.Streams = append(out.Streams, ffprobeStreamFor(stream, info))
+	}
+
+	return json.MarshalIndent(out, "", "    ")
+}
+
+func (d *Decoder) ffprobeFormatLocked() ffprobeFormat {
+	ifmt := avformat.GetInputFormat(d.formatCtx)
+	f := ffprobeFormat{
+		Filename:       avformat.GetURL(d.formatCtx),
+		NbStreams:      avformat.GetNumStreams(d.formatCtx),
+		NbPrograms:     avformat.GetNumPrograms(d.formatCtx),
+		FormatName:     avformat.InputFormatName(ifmt),
+		FormatLongName: avformat.InputFormatLongName(ifmt),
+		ProbeScore:     avformat.GetProbeScore(d.formatCtx),
+		Tags:           getMetadataFromDict(avformat.GetMetadata(d.formatCtx)),
+	}
+	microseconds := Rational{Num: 1, Den: 1000000}
+	if v := avformat.GetStartTime(d.formatCtx); v != avutil.AV_NOPTS_VALUE {
+		f.StartTime = ffprobeSeconds(v, microseconds)
+	}
+	if v := avformat.GetDuration(d.formatCtx); v > 0 && v != avutil.AV_NOPTS_VALUE {
+		f.Duration = ffprobeSeconds(v, microseconds)
+	}
+	if v := avformat.GetBitRate(d.formatCtx); v > 0 {
+		f.BitRate = strconv.FormatInt(v, 10)
+	}
+	return f
+}
+
+func ffprobeStreamFor(stream avformat.Stream, info *StreamInfo) ffprobeStream {
+	par := info.codecPar
+	tag := avcodec.GetCodecParTag(par)
+
+	s := ffprobeStream{
+		Index:       info.Index,
+		CodecName:   ffprobeCodecName(info),
+		CodecType:   ffprobeMediaType(info.Type),
+		CodecTag:    fmt.Sprintf("0x%04x", tag),
+		TimeBase:    ffprobeRational(info.TimeBase),
+		Disposition: make(map[string]int, len(ffprobeDispositions)),
+		Tags:        getMetadataFromDict(avformat.GetStreamMetadata(stream)),
+	}
+
+	rNum, rDen := avformat.GetStreamRFrameRate(stream)
+	s.RFrameRate = ffprobeRational(avutil.NewRational(rNum, rDen))
+	aNum, aDen := avformat.GetStreamAvgFrameRate(stream)
+	s.AvgFrameRate = ffprobeRational(avutil.NewRational(aNum, aDen))
+
+	switch info.Type {
+	case MediaTypeVideo:
+		s.Width = info.Width
+		s.Height = info.Height
+		s.PixFmt = avutil.GetPixFmtName(info.PixelFmt)
+	case MediaTypeAudio:
+		if fmtID := SampleFormat(avformat.GetCodecParFormat(par)); fmtID >= 0 {
+			s.SampleFmt = getSampleFormatName(fmtID)
+		}
+		s.SampleRate = strconv.Itoa(info.SampleRate)
+		s.Channels = info.Channels
+	}
+
+	if v := avformat.GetStreamStartTime(stream); v != avutil.AV_NOPTS_VALUE {
+		s.StartPTS = &v
+		s.StartTime = ffprobeSeconds(v, info.TimeBase)
+	}
+	if v := avformat.GetStreamDuration(stream); v != avutil.AV_NOPTS_VALUE {
+		s.DurationTS = &v
+		s.Duration = ffprobeSeconds(v, info.TimeBase)
+	}
+	if v := avformat.GetCodecParBitRate(par); v > 0 {
+		s.BitRate = strconv.FormatInt(v, 10)
+	}
+	if v := avformat.GetStreamNbFrames(stream); v > 0 {
+		s.NbFrames = strconv.FormatInt(v, 10)
+	}
+
+	disposition := avformat.GetStreamDisposition(stream)
+	for _, d := range ffprobeDispositions {
+		if disposition&d.flag != 0 {
+			s.Disposition[d.name] = 1
+		} else {
+			s.Disposition[d.name] = 0
+		}
+	}
+
+	return s
+}
+
+// ffprobeCodecName returns the short codec name ffprobe would print.
+func ffprobeCodecName(info *StreamInfo) string {
+	if name := info.CodecID.String(); name != "unknown" && name != "none" {
+		return name
+	}
+	return info.CodecName
+}
+
+// ffprobeMediaType returns ffprobe's codec_type string for a media type.
+func ffprobeMediaType(t MediaType) string {
+	switch t {
+	case MediaTypeVideo:
+		return "video"
+	case MediaTypeAudio:
+		return "audio"
+	case MediaTypeData:
+		return "data"
+	case MediaTypeSubtitle:
+		return "subtitle"
+	case MediaTypeAttachment:
+		return "attachment"
+	default:
+		return "unknown"
+	}
+}
+
+// ffprobeRational formats a rational as "num/den" (ffprobe prints "0/0" when unset).
+func ffprobeRational(r Rational) string {
+	return fmt.Sprintf("%d/%d", r.Num, r.Den)
+}
+
+// ffprobeSeconds formats a timestamp in seconds with ffprobe's 6-digit precision.
+func ffprobeSeconds(ts int64, tb Rational) string {
+	if tb.Den == 0 {
+		return ""
+	}
+	return strconv.FormatFloat(float64(ts)*float64(tb.Num)/float64(tb.Den), 'f', 6, 64)
+}
@@ -3,6 +3,7 @@
 package ffgo
 
 import (
+	"encoding/json"
 	"path/filepath"
 	"testing"
 )
@@ -27,6 +28,53 @@ func TestProbeFormat(t *testing.T) {
 	// ProbeScore can be 0 on some builds/inputs, but mp4 should typically be >0.
 }
 
+func TestProbe(t *testing.T) {
+	if testing.Short() {
+		t.Log("Skipping probe integration test in short mode")
+		return
+	}
+	if !requireFFmpeg(t) {
+		return
+	}
+
+	in := filepath.Join("testdata", "test.mp4")
+	r, err := Probe(in, nil)
+	if err != nil {
+		t.Fatalf("Probe failed: %v", err)
+	}
+	if r.Format == "" {
+		t.Fatalf("expected non-empty format name")
+	}
+	if r.Duration <= 0 {
+		t.Errorf("expected positive duration, got %v", r.Duration)
+	}
+	if len(r.Streams) == 0 {
+		t.Fatalf("expected at least one stream")
+	}
+	var sawVideo bool
+	for i, s := range r.Streams {
+		if s.Index != i {
+			t.Errorf("stream %d has index %d", i, s.Index)
+		}
+		if s.CodecParameters() != nil {
+			t.Errorf("stream %d should not retain codec parameters", i)
+		}
+		if s.Type == MediaTypeVideo {
+			sawVideo = true
+			if s.Width <= 0 || s.Height <= 0 {
+				t.Errorf("video stream has invalid dimensions %dx%d", s.Width, s.Height)
+			}
+		}
+	}
+	if !sawVideo {
+		t.Error("expected a video stream")
+	}
+
+	if _, err := Probe("", nil); err == nil {
+		t.Error("Probe with empty path should fail")
+	}
+}
+
 func TestDecoderOptions_ProbeScoreThreshold(t *testing.T) {
 	if testing.Short() {
 		t.Log("Skipping probe score threshold test in short mode")
@@ -81,3 +129,61 @@ func TestCandidateDemuxers_BlacklistFiltersWhitelist(t *testing.T) {
 		t.Fatalf("unexpected candidates: %#v", c)
 	}
 }
+
+func TestDecoderProbeJSON(t *testing.T) {
+	if !requireFFmpeg(t) {
+		return
+	}
+
+	d, err := NewDecoder(createTestVideo(t))
+	if err != nil {
+		t.Fatalf("NewDecoder failed: %v", err)
+	}
+	defer d.Close()
+
+	data, err := d.ProbeJSON()
+	if err != nil {
+		t.Fatalf("ProbeJSON failed: %v", err)
+	}
+
+	var out struct {
+		Streams []map[string]any `json:"streams"`
+		Format  map[string]any   `json:"format"`
+	}
+	if err := json.Unmarshal(data, &out); err != nil {
+		t.Fatalf("ProbeJSON produced invalid JSON: %v", err)
+	}
+	if out.Format["format_name"] == "" || out.Format["duration"] == nil {
+		t.Errorf("format section incomplete: %v", out.Format)
+	}
+	if int(out.Format["nb_streams"].(float64)) != len(out.Streams) {
+		t.Errorf("nb_streams = %v, streams = %d", out.Format["nb_streams"], len(out.Streams))
+	}
+	var sawVideo bool
+	for _, s := range out.Streams {
+		if _, ok := s["disposition"].(map[string]any); !ok {
+			t.Errorf("stream %v missing disposition", s["index"])
+		}
+		if s["codec_type"] == "video" {
+			sawVideo = true
+			if s["width"] == nil || s["height"] == nil || s["avg_frame_rate"] == nil {
+				t.Errorf("video stream missing fields: %v", s)
+			}
+		}
+	}
+	if !sawVideo {
+		t.Error("expected a video stream")
+	}
+}
+
+func TestFFprobeFormatting(t *testing.T) {
+	if got := ffprobeSeconds(15360, Rational{Num: 1, Den: 15360}); got != "1.000000" {
+		t.Errorf("ffprobeSeconds = %q, want 1.000000", got)
+	}
+	if got := ffprobeRational(Rational{Num: 30000, Den: 1001}); got != "30000/1001" {
+		t.Errorf("ffprobeRational = %q", got)
+	}
+	if got := ffprobeMediaType(MediaTypeSubtitle); got != "subtitle" {
+		t.Errorf("ffprobeMediaType = %q", got)
+	}
+}
@@ -22,23 +22,41 @@ type ProgramInfo struct {
 }
 
 // Programs returns the programs present in the input, if any.
+//
+// Multi-program inputs such as broadcast MPEG-TS muxes carry several
+// programs (channels); use the result to pick one and then open it with
+// DecoderOptions.ProgramID. Stream indexes that fall outside the input's
+// stream list are dropped.
 func (d *Decoder) Programs() []ProgramInfo {
-	if d == nil || d.formatCtx == nil {
+	if d == nil {
+		return nil
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if d.closed || d.formatCtx == nil {
 		return nil
 	}
 	n := avformat.GetNumPrograms(d.formatCtx)
 	if n <= 0 {
 		return nil
 	}
+	numStreams := avformat.GetNumStreams(d.formatCtx)
 	out := make([]ProgramInfo, 0, n)
 	for i := 0; i < n; i++ {
 		p := avformat.GetProgram(d.formatCtx, i)
 		if p == nil {
 			continue
 		}
+		var indexes []int
+		for _, si := range avformat.GetProgramStreamIndexes(p) {
+			if si >= 0 && si < numStreams {
+				indexes = append(indexes, si)
+			}
+		}
 		out = append(out, ProgramInfo{
 			ID:            avformat.GetProgramID(p),
-			StreamIndexes: avformat.GetProgramStreamIndexes(p),
+			StreamIndexes: indexes,
 			Metadata:      getMetadataFromDict(avformat.GetProgramMetadata(p)),
 		})
 	}
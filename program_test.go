@@ -78,6 +78,11 @@ func TestDecoderPrograms_ListAndSelect(t *testing.T) {
 	if _, ok := ids[2]; !ok {
 		t.Fatalf("expected program id 2 to exist, got %#v", progs)
 	}
+	for _, id := range []int{1, 2} {
+		if got := len(ids[id].StreamIndexes); got != 2 {
+			t.Errorf("program %d: expected 2 streams, got %d (%v)", id, got, ids[id].StreamIndexes)
+		}
+	}
 	// Program metadata is muxer/build dependent; don't require it, but log it for debugging.
 	t.Logf("program 1 metadata: %#v", ids[1].Metadata)
 
@@ -103,3 +108,18 @@ func TestDecoderPrograms_ListAndSelect(t *testing.T) {
 		t.Fatalf("expected audio stream index 2, got %d", got)
 	}
 }
+
+func TestDecoderPrograms_Closed(t *testing.T) {
+	if !requireFFmpeg(t) {
+		return
+	}
+
+	dec, err := NewDecoder(filepath.Join("testdata", "test.mp4"))
+	if err != nil {
+		t.Fatalf("NewDecoder failed: %v", err)
+	}
+	dec.Close()
+	if progs := dec.Programs(); progs != nil {
+		t.Fatalf("expected nil programs after Close, got %#v", progs)
+	}
+}
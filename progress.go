@@ -0,0 +1,90 @@
+//go:build !ios && !android && (amd64 || arm64)
+
+package ffgo
+
+import "time"
+
+// Progress reports how far a long-running operation (Transcoder.Run,
+// Remuxer.Remux, GenerateThumbnailsWithProgress) has got. Totals are
+// estimated from the input's duration and frame rate and are zero when
+// unknown; Percent and ETA are zero until they can be estimated.
+type Progress struct {
+	// FramesDone is the number of video frames (or thumbnails) processed.
+	FramesDone int64
+	// TotalFrames is the expected number of frames, as Decoder.TotalFrames.
+	TotalFrames int64
+
+	// TimeDone is the media time processed so far.
+	TimeDone time.Duration
+	// TotalTime is the media time to process, as Decoder.Duration.
+	TotalTime time.Duration
+
+	// Percent is the completion in [0, 100], from TimeDone/TotalTime when
+	// the duration is known and FramesDone/TotalFrames otherwise.
+	Percent float64
+
+	// ETA is the estimated wall-clock time remaining, extrapolated from the
+	// elapsed time and Percent.
+	ETA time.Duration
+}
+
+// estimate fills in Percent and ETA from the counters and the wall-clock
+// time elapsed since the operation started.
+func (p *Progress) estimate(elapsed time.Duration) {
+	p.Percent, p.ETA = 0, 0
+	switch {
+	case p.TotalTime > 0:
+		p.Percent = 100 * float64(p.TimeDone) / float64(p.TotalTime)
+	case p.TotalFrames > 0:
+		p.Percent = 100 * float64(p.FramesDone) / float64(p.TotalFrames)
+	default:
+		return
+	}
+	p.Percent = min(max(p.Percent, 0), 100)
+	if p.Percent > 0 && elapsed > 0 {
+		p.ETA = time.Duration(float64(elapsed) * (100 - p.Percent) / p.Percent)
+	}
+}
+
+// progressTracker accumulates progress for an operation and reports it to
+// a callback whenever the frame count or media position advances.
+type progressTracker struct {
+	cb    func(Progress)
+	start time.Time
+	p     Progress
+}
+
+// newProgressTracker starts tracking an operation now. It returns nil when
+// cb is nil; a nil tracker ignores updates.
+func newProgressTracker(cb func(Progress), totalFrames int64, totalTime time.Duration) *progressTracker {
+	if cb == nil {
+		return nil
+	}
+	return &progressTracker{
+		cb:    cb,
+		start: time.Now(),
+		p:     Progress{TotalFrames: max(totalFrames, 0), TotalTime: max(totalTime, 0)},
+	}
+}
+
+// update records frames processed and the media position reached, and
+// reports them if either advanced. A negative timeDone leaves the position
+// unchanged.
+func (t *progressTracker) update(frames int64, timeDone time.Duration) {
+	if t == nil {
+		return
+	}
+	advanced := frames > t.p.FramesDone
+	if advanced {
+		t.p.FramesDone = frames
+	}
+	if timeDone > t.p.TimeDone {
+		t.p.TimeDone = timeDone
+		advanced = true
+	}
+	if !advanced {
+		return
+	}
+	t.p.estimate(time.Since(t.start))
+	t.cb(t.p)
+}
@@ -0,0 +1,101 @@
+//go:build !ios && !android && (amd64 || arm64)
+
+package ffgo
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestProgressEstimate(t *testing.T) {
+	tests := []struct {
+		name    string
+		p       Progress
+		elapsed time.Duration
+		percent float64
+		eta     time.Duration
+	}{
+		{"by time", Progress{TimeDone: 30 * time.Second, TotalTime: 120 * time.Second}, 10 * time.Second, 25, 30 * time.Second},
+		{"by frames", Progress{FramesDone: 50, TotalFrames: 100}, 4 * time.Second, 50, 4 * time.Second},
+		{"time wins", Progress{FramesDone: 10, TotalFrames: 100, TimeDone: 60 * time.Second, TotalTime: 80 * time.Second}, 3 * time.Second, 75, time.Second},
+		{"overrun", Progress{TimeDone: 11 * time.Second, TotalTime: 10 * time.Second}, time.Second, 100, 0},
+		{"unknown total", Progress{FramesDone: 10, TimeDone: time.Second}, time.Second, 0, 0},
+		{"nothing done", Progress{TotalTime: time.Minute}, time.Second, 0, 0},
+	}
+	for _, tt := range tests {
+		p := tt.p
+		p.estimate(tt.elapsed)
+		if p.Percent != tt.percent || p.ETA != tt.eta {
+			t.Errorf("%s: Percent, ETA = %v, %v, want %v, %v", tt.name, p.Percent, p.ETA, tt.percent, tt.eta)
+		}
+	}
+}
+
+func TestProgressTrackerReportsAdvances(t *testing.T) {
+	var got []Progress
+	tr := newProgressTracker(func(p Progress) { got = append(got, p) }, 10, 10*time.Second)
+	tr.update(1, time.Second)
+	tr.update(1, time.Second)          // no change
+	tr.update(1, 500*time.Millisecond) // going backwards
+	tr.update(2, -1)
+	tr.update(2, 3*time.Second)
+
+	if len(got) != 3 {
+		t.Fatalf("got %d reports, want 3: %+v", len(got), got)
+	}
+	last := got[2]
+	if last.FramesDone != 2 || last.TimeDone != 3*time.Second || last.TotalFrames != 10 || last.TotalTime != 10*time.Second {
+		t.Errorf("last report = %+v", last)
+	}
+	if last.Percent != 30 {
+		t.Errorf("Percent = %v, want 30", last.Percent)
+	}
+
+	nilTracker := newProgressTracker(nil, 10, time.Second)
+	nilTracker.update(1, time.Second) // must not panic
+}
+
+func TestRemuxerProgress(t *testing.T) {
+	if !requireFFmpeg(t) {
+		return
+	}
+	dec, err := NewDecoder(createTestVideo(t))
+	if err != nil {
+		t.Fatalf("NewDecoder failed: %v", err)
+	}
+	defer dec.Close()
+
+	var reports []Progress
+	r, err := NewRemuxer(filepath.Join(t.TempDir(), "out.mkv"), dec, &RemuxerConfig{
+		OnProgress: func(p Progress) { reports = append(reports, p) },
+	})
+	if err != nil {
+		t.Fatalf("NewRemuxer failed: %v", err)
+	}
+	if err := r.Remux(dec); err != nil {
+		t.Fatalf("Remux failed: %v", err)
+	}
+	if err := r.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+
+	if len(reports) == 0 {
+		t.Fatal("OnProgress was never called")
+	}
+	last := reports[len(reports)-1]
+	if last.TotalTime != dec.Duration() {
+		t.Errorf("TotalTime = %v, want %v", last.TotalTime, dec.Duration())
+	}
+	if last.FramesDone == 0 {
+		t.Error("no video frames counted")
+	}
+	if last.Percent < 90 {
+		t.Errorf("final Percent = %v, want close to 100", last.Percent)
+	}
+	for i := 1; i < len(reports); i++ {
+		if reports[i].Percent < reports[i-1].Percent {
+			t.Fatalf("Percent went backwards: %v then %v", reports[i-1].Percent, reports[i].Percent)
+		}
+	}
+}
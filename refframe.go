@@ -0,0 +1,101 @@
+//go:build !ios && !android && (amd64 || arm64)
+
+package ffgo
+
+import (
+	"errors"
+	"sync"
+
+	"github.com/obinnaokechukwu/ffgo/avutil"
+)
+
+// RefFrame is a reference-counted frame for holding decoded frames in
+// several places at once (e.g. a playback queue and an analysis worker).
+//
+// A RefFrame starts with one reference. Each holder calls Retain before
+// sharing it and Release when done; the frame's buffers are unreferenced
+// (av_frame_unref) and the AVFrame freed, or returned to its pool, when the
+// last reference is released.
+type RefFrame struct {
+	mu    sync.Mutex
+	frame Frame
+	refs  int
+	pool  *FramePool
+}
+
+// NewRefFrame creates a RefFrame that references src's buffers via av_frame_ref.
+// src is not modified; it may be a borrowed decoder frame.
+func NewRefFrame(src Frame) (*RefFrame, error) {
+	if src.IsNil() {
+		return nil, errors.New("ffgo: source frame is nil")
+	}
+	dst, err := FrameClone(src)
+	if err != nil {
+		return nil, err
+	}
+	return &RefFrame{frame: dst, refs: 1}, nil
+}
+
+// GetRef takes a frame from the pool, references src's buffers into it and
+// wraps it in a RefFrame. When the last reference is released the AVFrame
+// goes back to the pool instead of being freed.
+func (p *FramePool) GetRef(src Frame) (*RefFrame, error) {
+	if src.IsNil() {
+		return nil, errors.New("ffgo: source frame is nil")
+	}
+	dst, err := p.Get()
+	if err != nil {
+		return nil, err
+	}
+	if err := avutil.FrameRef(dst.ptr, src.ptr); err != nil {
+		_ = p.Put(&dst)
+		return nil, err
+	}
+	return &RefFrame{frame: dst, refs: 1, pool: p}, nil
+}
+
+// Frame returns a borrowed view of the underlying frame.
+// It is valid only while the caller holds a reference.
+func (r *RefFrame) Frame() Frame {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return Frame{ptr: r.frame.ptr}
+}
+
+// Retain adds a reference. Retaining a fully released RefFrame returns an
+// error, since its frame has already been freed.
+func (r *RefFrame) Retain() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.refs <= 0 {
+		return errors.New("ffgo: RefFrame already released")
+	}
+	r.refs++
+	return nil
+}
+
+// Release drops a reference. When the count reaches zero the frame is
+// freed or returned to its pool. Releasing more times than retained
+// returns an error instead of double-freeing.
+func (r *RefFrame) Release() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.refs <= 0 {
+		return errors.New("ffgo: RefFrame already released")
+	}
+	r.refs--
+	if r.refs > 0 {
+		return nil
+	}
+	if r.pool != nil {
+		return r.pool.Put(&r.frame)
+	}
+	return r.frame.Free()
+}
+
+// RefCount returns the current number of references.
+func (r *RefFrame) RefCount() int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.refs
+}
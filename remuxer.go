@@ -3,8 +3,12 @@
 package ffgo
 
 import (
+	"context"
 	"errors"
+	"fmt"
 	"sync"
+	"time"
+	"unsafe"
 
 	"github.com/obinnaokechukwu/ffgo/avcodec"
 	"github.com/obinnaokechukwu/ffgo/avformat"
@@ -28,9 +32,31 @@ type Remuxer struct {
 	inputTimeBases  map[int]avutil.Rational
 	outputTimeBases map[int]avutil.Rational
 
+	// inputStartTimes holds each input stream's start_time in its time
+	// base (0 if unknown), so trimming and progress measure from it.
+	inputStartTimes map[int]int64
+
 	// Reusable packet
 	packet avcodec.Packet
 
+	// Timestamp regeneration (RemuxerConfig.RegeneratePTS)
+	regeneratePTS bool
+	lastDTS       map[int]int64
+
+	// Trimming (RemuxerConfig.StartTime/EndTime). tsOffset is the source
+	// time, in microseconds, that becomes zero in the output.
+	startTime time.Duration
+	endTime   time.Duration
+	tsOffset  int64
+	offsetSet bool
+
+	onProgress func(Progress)
+
+	// Cancellation (RemuxerConfig.FinalizeOnCancel). A cancelled remux
+	// skips the trailer in Close unless finalizeOnCancel is set.
+	finalizeOnCancel bool
+	cancelled        bool
+
 	headerWritten bool
 	closed        bool
 }
@@ -40,6 +66,78 @@ type RemuxerConfig struct {
 	// InputStreams specifies which input stream indices to copy.
 	// If empty, all streams are copied.
 	InputStreams []int
+
+	// OutputMap explicitly maps input streams to output stream positions,
+	// e.g. to put audio first. Only the listed streams are copied, and their
+	// Output positions must cover 0..len(OutputMap)-1 exactly once. It
+	// replaces InputStreams; set one or the other.
+	OutputMap []StreamMapping
+
+	// RegeneratePTS rewrites output timestamps so that they are clean and
+	// monotonic, similar to FFmpeg's -fflags +genpts: missing PTS/DTS are
+	// filled in, DTS is forced to strictly increase per stream, and PTS is
+	// never earlier than DTS. Use this when remuxing sources with broken or
+	// heavily reordered timestamps for players that cannot handle them.
+	RegeneratePTS bool
+
+	// StartTime and EndTime cut a clip out of the input without re-encoding.
+	// Remux seeks to StartTime and stops once every copied stream has passed
+	// EndTime; zero EndTime copies to the end of the input. Both are
+	// measured from the start of the input, so streams with a start offset
+	// (e.g. MPEG-TS) trim the same range. Output timestamps are shifted so
+	// the clip starts at zero.
+	//
+	// The start cut is keyframe-aligned: the seek lands on the keyframe at or
+	// before StartTime, since packets before it cannot be decoded, so the
+	// clip may begin up to one GOP early.
+	StartTime time.Duration
+	EndTime   time.Duration
+
+	// CopyStreamMetadata copies each source stream's metadata (language,
+	// title, handler name, ...) and disposition flags to its output stream.
+	CopyStreamMetadata bool
+
+	// Dispositions sets the AV_DISPOSITION_* flags (avformat.AV_DISPOSITION_DEFAULT,
+	// avformat.AV_DISPOSITION_FORCED, ...) of output streams, keyed by output
+	// stream index. Entries replace any disposition copied from the source.
+	Dispositions map[int]int
+
+	// OnProgress, if set, is called by Remux as packets are copied. Frames
+	// count the video packets copied; times are relative to StartTime.
+	OnProgress func(Progress)
+
+	// FinalizeOnCancel makes Close write the trailer after RemuxContext was
+	// cancelled, leaving a valid output that ends where copying stopped. By
+	// default a cancelled output is abandoned without a trailer.
+	FinalizeOnCancel bool
+}
+
+// StreamMapping routes input stream Input to output stream index Output.
+type StreamMapping struct {
+	Input  int
+	Output int
+}
+
+// orderedInputStreams returns the input stream indices of m in output order.
+func orderedInputStreams(m []StreamMapping) ([]int, error) {
+	ordered := make([]int, len(m))
+	filled := make([]bool, len(m))
+	seen := make(map[int]bool, len(m))
+	for _, sm := range m {
+		if sm.Output < 0 || sm.Output >= len(m) {
+			return nil, fmt.Errorf("ffgo: output position %d out of range [0,%d)", sm.Output, len(m))
+		}
+		if filled[sm.Output] {
+			return nil, fmt.Errorf("ffgo: output position %d mapped more than once", sm.Output)
+		}
+		if seen[sm.Input] {
+			return nil, fmt.Errorf("ffgo: input stream %d mapped more than once", sm.Input)
+		}
+		ordered[sm.Output] = sm.Input
+		filled[sm.Output] = true
+		seen[sm.Input] = true
+	}
+	return ordered, nil
 }
 
 // NewRemuxer creates a new remuxer that copies packets from decoder to output file.
@@ -56,7 +154,22 @@ func NewRemuxer(outputPath string, decoder *Decoder, cfg *RemuxerConfig) (*Remux
 	r := &Remuxer{
 		streamMap:       make(map[int]int),
 		inputTimeBases:  make(map[int]avutil.Rational),
+		inputStartTimes: make(map[int]int64),
 		outputTimeBases: make(map[int]avutil.Rational),
+		lastDTS:         make(map[int]int64),
+	}
+	if cfg != nil {
+		if cfg.StartTime < 0 || cfg.EndTime < 0 {
+			return nil, errors.New("ffgo: RemuxerConfig StartTime and EndTime must not be negative")
+		}
+		if cfg.EndTime > 0 && cfg.EndTime <= cfg.StartTime {
+			return nil, errors.New("ffgo: RemuxerConfig.EndTime must be after StartTime")
+		}
+		r.regeneratePTS = cfg.RegeneratePTS
+		r.startTime = cfg.StartTime
+		r.endTime = cfg.EndTime
+		r.onProgress = cfg.OnProgress
+		r.finalizeOnCancel = cfg.FinalizeOnCancel
 	}
 
 	// Determine output format from filename
@@ -72,7 +185,18 @@ func NewRemuxer(outputPath string, decoder *Decoder, cfg *RemuxerConfig) (*Remux
 
 	// Determine which streams to copy
 	var streamsToCopy []int
-	if cfg != nil && len(cfg.InputStreams) > 0 {
+	if cfg != nil && len(cfg.OutputMap) > 0 {
+		if len(cfg.InputStreams) > 0 {
+			r.cleanup()
+			return nil, errors.New("ffgo: RemuxerConfig InputStreams and OutputMap are mutually exclusive")
+		}
+		ordered, err := orderedInputStreams(cfg.OutputMap)
+		if err != nil {
+			r.cleanup()
+			return nil, err
+		}
+		streamsToCopy = ordered
+	} else if cfg != nil && len(cfg.InputStreams) > 0 {
 		streamsToCopy = cfg.InputStreams
 	} else {
 		// Copy all streams
@@ -110,11 +234,29 @@ func NewRemuxer(outputPath string, decoder *Decoder, cfg *RemuxerConfig) (*Remux
 		// Clear codec tag for compatibility with different containers
 		avcodec.SetCodecParTag(outputCodecPar, 0)
 
+		// Keep the display matrix and other stream side data so players
+		// still orient the output correctly.
+		if err := avformat.CopyStreamSideData(outputStream, inputStream); err != nil {
+			r.cleanup()
+			return nil, err
+		}
+
+		if cfg != nil && cfg.CopyStreamMetadata {
+			if err := copyStreamMetadata(outputStream, inputStream); err != nil {
+				r.cleanup()
+				return nil, err
+			}
+			avformat.SetStreamDisposition(outputStream, avformat.GetStreamDisposition(inputStream))
+		}
+
 		// Store stream mapping and time bases
 		r.streamMap[inputIdx] = outputStreamIdx
 
 		inTbNum, inTbDen := avformat.GetStreamTimeBase(inputStream)
 		r.inputTimeBases[inputIdx] = avutil.NewRational(inTbNum, inTbDen)
+		if st := avformat.GetStreamStartTime(inputStream); st != avutil.AV_NOPTS_VALUE {
+			r.inputStartTimes[inputIdx] = st
+		}
 
 		outTbNum, outTbDen := avformat.GetStreamTimeBase(outputStream)
 		r.outputTimeBases[inputIdx] = avutil.NewRational(outTbNum, outTbDen)
@@ -122,6 +264,16 @@ func NewRemuxer(outputPath string, decoder *Decoder, cfg *RemuxerConfig) (*Remux
 		outputStreamIdx++
 	}
 
+	if cfg != nil {
+		for outputIdx, disposition := range cfg.Dispositions {
+			if outputIdx < 0 || outputIdx >= outputStreamIdx {
+				r.cleanup()
+				return nil, fmt.Errorf("ffgo: disposition for output stream %d, which does not exist", outputIdx)
+			}
+			avformat.SetStreamDisposition(avformat.GetStream(r.outputCtx, outputIdx), int32(disposition))
+		}
+	}
+
 	// Open output file if needed
 	if !avformat.HasNoFile(r.outputCtx) {
 		if err := avformat.IOOpen(&r.outputIO, outputPath, avformat.IOFlagWrite); err != nil {
@@ -141,6 +293,22 @@ func NewRemuxer(outputPath string, decoder *Decoder, cfg *RemuxerConfig) (*Remux
 	return r, nil
 }
 
+// copyStreamMetadata copies every metadata entry of src to dst.
+func copyStreamMetadata(dst, src avformat.Stream) error {
+	dict := avformat.GetStreamMetadata(src)
+	var prev unsafe.Pointer
+	for {
+		entry := avformat.DictGet(dict, "", prev, avformat.AV_DICT_IGNORE_SUFFIX)
+		if entry == nil {
+			return nil
+		}
+		if err := avformat.SetStreamMetadata(dst, avformat.DictEntryKey(entry), avformat.DictEntryValue(entry)); err != nil {
+			return err
+		}
+		prev = entry
+	}
+}
+
 // WriteHeader writes the output file header.
 // Must be called before WritePacket.
 func (r *Remuxer) WriteHeader() error {
@@ -218,8 +386,15 @@ func (r *Remuxer) WritePacket(pkt avcodec.Packet, inputStreamIdx int) error {
 	// Rescale timestamps from input to output time base
 	inputTB := r.inputTimeBases[inputStreamIdx]
 	outputTB := r.outputTimeBases[inputStreamIdx]
+	if r.startTime > 0 {
+		r.shiftTimestamps(r.packet, inputTB)
+	}
 	avcodec.RescalePacketTS(r.packet, inputTB, outputTB)
 
+	if r.regeneratePTS {
+		r.regenerateTimestamps(r.packet, outputIdx)
+	}
+
 	// Write the packet
 	err := avformat.InterleavedWriteFrame(r.outputCtx, r.packet)
 
@@ -229,14 +404,102 @@ func (r *Remuxer) WritePacket(pkt avcodec.Packet, inputStreamIdx int) error {
 	return err
 }
 
+// shiftTimestamps moves a packet, still in the input time base tb, so the
+// first packet written for a trimmed clip lands at zero.
+func (r *Remuxer) shiftTimestamps(pkt avcodec.Packet, tb avutil.Rational) {
+	pts := avcodec.GetPacketPTS(pkt)
+	dts := avcodec.GetPacketDTS(pkt)
+	if !r.offsetSet {
+		ts := dts
+		if ts == avutil.AV_NOPTS_VALUE {
+			ts = pts
+		}
+		if ts == avutil.AV_NOPTS_VALUE {
+			return
+		}
+		r.tsOffset = rescaleTS(ts, tb, avutil.TimeBaseMicro)
+		r.offsetSet = true
+	}
+	offset := rescaleTS(r.tsOffset, avutil.TimeBaseMicro, tb)
+	if pts != avutil.AV_NOPTS_VALUE {
+		avcodec.SetPacketPTS(pkt, pts-offset)
+	}
+	if dts != avutil.AV_NOPTS_VALUE {
+		avcodec.SetPacketDTS(pkt, dts-offset)
+	}
+}
+
+// regenerateTimestamps fills in missing timestamps and enforces monotonic
+// DTS (and PTS >= DTS) for a packet already in the output time base.
+func (r *Remuxer) regenerateTimestamps(pkt avcodec.Packet, outputIdx int) {
+	pts := avcodec.GetPacketPTS(pkt)
+	dts := avcodec.GetPacketDTS(pkt)
+	last, seen := r.lastDTS[outputIdx]
+
+	if dts == avutil.AV_NOPTS_VALUE {
+		switch {
+		case pts != avutil.AV_NOPTS_VALUE && (!seen || pts > last):
+			dts = pts
+		case seen:
+			step := avcodec.GetPacketDuration(pkt)
+			if step <= 0 {
+				step = 1
+			}
+			dts = last + step
+		default:
+			dts = 0
+		}
+	}
+	if seen && dts <= last {
+		dts = last + 1
+	}
+	if pts == avutil.AV_NOPTS_VALUE || pts < dts {
+		pts = dts
+	}
+
+	avcodec.SetPacketDTS(pkt, dts)
+	avcodec.SetPacketPTS(pkt, pts)
+	r.lastDTS[outputIdx] = dts
+}
+
 // Remux copies all packets from a decoder to the output.
 // This is a convenience method that reads all packets and writes them.
+// With RemuxerConfig.StartTime or EndTime set, only the packets of that
+// range are copied.
 func (r *Remuxer) Remux(decoder *Decoder) error {
+	return r.RemuxContext(context.Background(), decoder)
+}
+
+// RemuxContext is like Remux but stops when ctx is done, checking it before
+// each packet, and returns ctx.Err(). See RemuxerConfig.FinalizeOnCancel for
+// what Close then does with the output.
+func (r *Remuxer) RemuxContext(ctx context.Context, decoder *Decoder) error {
 	if err := r.WriteHeader(); err != nil {
 		return err
 	}
 
+	if r.startTime > 0 {
+		// Seek takes an absolute timestamp; StartTime is relative to the
+		// start of the input.
+		seekTo := r.startTime
+		if st := avformat.GetStartTime(decoder.formatCtx); st != avutil.AV_NOPTS_VALUE && st > 0 {
+			seekTo += time.Duration(st) * time.Microsecond
+		}
+		if err := decoder.Seek(seekTo); err != nil {
+			return err
+		}
+	}
+	ended := make(map[int]bool)
+	progress := r.newProgressTracker(decoder)
+	var frames int64
+
 	for {
+		if err := ctx.Err(); err != nil {
+			r.mu.Lock()
+			r.cancelled = true
+			r.mu.Unlock()
+			return err
+		}
 		pkt, err := decoder.ReadPacket()
 		if err != nil {
 			return err
@@ -246,14 +509,87 @@ func (r *Remuxer) Remux(decoder *Decoder) error {
 		}
 
 		streamIdx := pkt.StreamIndex()
+		if r.endTime > 0 {
+			if _, ok := r.streamMap[streamIdx]; !ok || ended[streamIdx] {
+				continue
+			}
+			if r.pastEnd(pkt) {
+				ended[streamIdx] = true
+				if len(ended) == len(r.streamMap) {
+					break
+				}
+				continue
+			}
+		}
+		// Read the position before WritePacket rescales the timestamps
+		pos := r.packetTime(pkt)
 		if err := r.WritePacket(pkt.ptr, streamIdx); err != nil {
 			return err
 		}
+		if streamIdx == decoder.videoStreamIdx {
+			frames++
+		}
+		progress.update(frames, pos)
 	}
 
 	return nil
 }
 
+// newProgressTracker returns a tracker for RemuxerConfig.OnProgress, or nil
+// if it is not set. The totals cover the StartTime..EndTime range.
+func (r *Remuxer) newProgressTracker(decoder *Decoder) *progressTracker {
+	if r.onProgress == nil {
+		return nil
+	}
+	total := decoder.Duration()
+	if r.endTime > 0 && (total <= 0 || r.endTime < total) {
+		total = r.endTime
+	}
+	if total > 0 {
+		total = max(total-r.startTime, 0)
+	}
+	var frames int64
+	if full := decoder.Duration(); full > 0 {
+		frames = decoder.TotalFrames() * int64(total) / int64(full)
+	}
+	return newProgressTracker(r.onProgress, frames, total)
+}
+
+// streamTime returns the presentation time of pkt (its DTS if it has no
+// PTS) measured from the start of its stream, and false if it has no
+// timestamp.
+func (r *Remuxer) streamTime(pkt *Packet) (time.Duration, bool) {
+	ts := pkt.PTS()
+	if ts == avutil.AV_NOPTS_VALUE {
+		ts = pkt.DTS()
+	}
+	if ts == avutil.AV_NOPTS_VALUE {
+		return 0, false
+	}
+	r.mu.Lock()
+	tb := r.inputTimeBases[pkt.StreamIndex()]
+	start := r.inputStartTimes[pkt.StreamIndex()]
+	r.mu.Unlock()
+	return time.Duration(rescaleTS(ts-start, tb, avutil.TimeBaseMicro)) * time.Microsecond, true
+}
+
+// packetTime returns the input position of pkt relative to
+// RemuxerConfig.StartTime, or -1 if it has no timestamp.
+func (r *Remuxer) packetTime(pkt *Packet) time.Duration {
+	t, ok := r.streamTime(pkt)
+	if !ok {
+		return -1
+	}
+	return t - r.startTime
+}
+
+// pastEnd reports whether pkt is presented at or after
+// RemuxerConfig.EndTime.
+func (r *Remuxer) pastEnd(pkt *Packet) bool {
+	t, ok := r.streamTime(pkt)
+	return ok && t >= r.endTime
+}
+
 // Close finalizes and closes the remuxer.
 func (r *Remuxer) Close() error {
 	r.mu.Lock()
@@ -266,8 +602,8 @@ func (r *Remuxer) Close() error {
 
 	var firstErr error
 
-	// Write trailer
-	if r.outputCtx != nil && r.headerWritten {
+	// Write trailer, unless a cancelled remux is being abandoned
+	if r.outputCtx != nil && r.headerWritten && (!r.cancelled || r.finalizeOnCancel) {
 		if err := avformat.WriteTrailer(r.outputCtx); err != nil && firstErr == nil {
 			firstErr = err
 		}
@@ -2,16 +2,22 @@ package ffgo
 
 import (
 	"fmt"
+	"unsafe"
 
 	"github.com/obinnaokechukwu/ffgo/avutil"
 	"github.com/obinnaokechukwu/ffgo/swresample"
 )
 
 // AudioFormat describes audio characteristics for resampling
+//
+// ChannelLayout selects which speakers the channels map to, which matters
+// when the channel count changes (e.g. downmixing ChannelLayout5Point1 to
+// ChannelLayoutStereo). If it is zero, FFmpeg's default layout for Channels
+// is used; if Channels is zero, it is derived from ChannelLayout.
 type AudioFormat struct {
 	SampleRate    int           // e.g., 44100, 48000
 	Channels      int           // e.g., 1, 2, 6
-	ChannelLayout ChannelLayout // e.g., ChannelLayoutStereo
+	ChannelLayout ChannelLayout // e.g., ChannelLayoutStereo (optional)
 	SampleFormat  SampleFormat  // e.g., SampleFormatS16, SampleFormatFLTP
 }
 
@@ -24,7 +30,9 @@ const (
 	ChannelLayout2Point1     ChannelLayout = 0xB   // AV_CH_LAYOUT_2POINT1
 	ChannelLayoutSurround    ChannelLayout = 0x7   // AV_CH_LAYOUT_SURROUND
 	ChannelLayout5Point0     ChannelLayout = 0x607 // AV_CH_LAYOUT_5POINT0
-	ChannelLayout5Point1     ChannelLayout = 0x60F // AV_CH_LAYOUT_5POINT1
+	ChannelLayoutQuad        ChannelLayout = 0x33  // AV_CH_LAYOUT_QUAD
+	ChannelLayout5Point1     ChannelLayout = 0x60F // AV_CH_LAYOUT_5POINT1 (side surrounds)
+	ChannelLayout5Point1Back ChannelLayout = 0x3F  // AV_CH_LAYOUT_5POINT1_BACK
 	ChannelLayout6Point1     ChannelLayout = 0x70F // AV_CH_LAYOUT_6POINT1
 	ChannelLayout7Point1     ChannelLayout = 0x63F // AV_CH_LAYOUT_7POINT1
 	ChannelLayout7Point1Wide ChannelLayout = 0xFF  // AV_CH_LAYOUT_7POINT1_WIDE
@@ -47,6 +55,21 @@ type Resampler struct {
 //	    ffgo.AudioFormat{SampleRate: 48000, Channels: 2, SampleFormat: ffgo.SampleFormatFLTP},
 //	)
 func NewResampler(src, dst AudioFormat) (*Resampler, error) {
+	// Derive channel counts from explicit layouts
+	for _, f := range []*AudioFormat{&src, &dst} {
+		if f.ChannelLayout == 0 {
+			continue
+		}
+		if f.Channels == 0 {
+			f.Channels = f.ChannelLayout.NumChannels()
+		} else if f.Channels != f.ChannelLayout.NumChannels() {
+			return nil, fmt.Errorf("channel layout %s has %d channels, but Channels is %d",
+				f.ChannelLayout, f.ChannelLayout.NumChannels(), f.Channels)
+		}
+	}
+	srcExplicit := src.ChannelLayout != 0
+	dstExplicit := dst.ChannelLayout != 0
+
 	// Validate inputs
 	if src.SampleRate <= 0 || dst.SampleRate <= 0 {
 		return nil, fmt.Errorf("invalid sample rate: src=%d, dst=%d", src.SampleRate, dst.SampleRate)
@@ -77,10 +100,9 @@ func NewResampler(src, dst AudioFormat) (*Resampler, error) {
 
 		outLayout := avutil.Malloc(avChannelLayoutBufSize)
 		inLayout := avutil.Malloc(avChannelLayoutBufSize)
-		if outLayout != nil && inLayout != nil {
-			avutil.ChannelLayoutDefault(outLayout, int32(dst.Channels))
-			avutil.ChannelLayoutDefault(inLayout, int32(src.Channels))
-
+		if outLayout != nil && inLayout != nil &&
+			initChannelLayout(outLayout, dst, dstExplicit) == nil &&
+			initChannelLayout(inLayout, src, srcExplicit) == nil {
 			if err := swresample.AllocSetOpts2(&ctx, outLayout, inLayout,
 				int32(dst.SampleFormat), int32(src.SampleFormat),
 				int32(dst.SampleRate), int32(src.SampleRate)); err == nil {
@@ -146,7 +168,7 @@ func (r *Resampler) Resample(frame Frame) (Frame, error) {
 
 	// Set output frame parameters
 	avutil.FrameSetSampleRate(outFrame, int32(r.dstFormat.SampleRate))
-	avutil.FrameSetChannels(outFrame, int32(r.dstFormat.Channels))
+	setFrameChannelLayout(outFrame, r.dstFormat)
 	avutil.FrameSetFormat(outFrame, int32(r.dstFormat.SampleFormat))
 
 	// Calculate output samples
@@ -193,7 +215,7 @@ func (r *Resampler) Flush() (Frame, error) {
 
 	// Set output frame parameters
 	avutil.FrameSetSampleRate(outFrame, int32(r.dstFormat.SampleRate))
-	avutil.FrameSetChannels(outFrame, int32(r.dstFormat.Channels))
+	setFrameChannelLayout(outFrame, r.dstFormat)
 	avutil.FrameSetFormat(outFrame, int32(r.dstFormat.SampleFormat))
 	avutil.FrameSetNbSamples(outFrame, int32(delay))
 
@@ -213,6 +235,163 @@ func (r *Resampler) Flush() (Frame, error) {
 	return Frame{ptr: outFrame, owned: true}, nil
 }
 
+// OutSamples returns an upper bound on the number of output samples produced
+// for inSamples input samples, including samples buffered from earlier
+// calls. Use it to size frames passed to ConvertInto.
+func (r *Resampler) OutSamples(inSamples int) int {
+	if r.closed {
+		return 0
+	}
+	return swresample.GetOutSamples(r.ctx, inSamples)
+}
+
+// NewOutputFrame allocates an owned frame in the destination format with room
+// for capacity samples, suitable for reuse with ConvertInto.
+func (r *Resampler) NewOutputFrame(capacity int) (Frame, error) {
+	return newAudioFrame(r.dstFormat, capacity)
+}
+
+// newAudioFrame allocates an owned audio frame in format f with buffers for
+// capacity samples.
+func newAudioFrame(f AudioFormat, capacity int) (Frame, error) {
+	if capacity <= 0 {
+		return Frame{}, fmt.Errorf("invalid frame capacity: %d", capacity)
+	}
+	outFrame := avutil.FrameAlloc()
+	if outFrame == nil {
+		return Frame{}, fmt.Errorf("failed to allocate output frame")
+	}
+	avutil.FrameSetSampleRate(outFrame, int32(f.SampleRate))
+	setFrameChannelLayout(outFrame, f)
+	avutil.FrameSetFormat(outFrame, int32(f.SampleFormat))
+	avutil.FrameSetNbSamples(outFrame, int32(capacity))
+	if err := avutil.FrameGetBufferErr(outFrame, 0); err != nil {
+		avutil.FrameFree(&outFrame)
+		return Frame{}, fmt.Errorf("failed to allocate output frame buffer: %w", err)
+	}
+	return Frame{ptr: outFrame, owned: true}, nil
+}
+
+// ConvertInto resamples src into the caller-managed frame dst and returns the
+// number of samples written per channel, which is also stored as dst's
+// nb_samples. It avoids the per-call allocation of Resample, for real-time
+// pipelines that reuse one output frame.
+//
+// dst must already have buffers allocated in the destination format and
+// channel count (see NewOutputFrame). Its capacity is derived from the
+// allocated buffer, not from nb_samples, so a frame can be reused after a
+// call shrank nb_samples. Size it with OutSamples for the largest expected
+// input; if it is too small, the excess stays buffered in the resampler and
+// is returned by later calls. A nil src drains buffered samples.
+func (r *Resampler) ConvertInto(dst Frame, src Frame) (int, error) {
+	if r.closed {
+		return 0, fmt.Errorf("resampler is closed")
+	}
+	if dst.IsNil() {
+		return 0, fmt.Errorf("destination frame is nil")
+	}
+
+	if got := SampleFormat(avutil.GetFrameFormat(dst.ptr)); got != r.dstFormat.SampleFormat {
+		return 0, fmt.Errorf("destination frame sample format %d does not match resampler output %d", got, r.dstFormat.SampleFormat)
+	}
+	if got := int(avutil.GetFrameChannels(dst.ptr)); got != r.dstFormat.Channels {
+		return 0, fmt.Errorf("destination frame has %d channels, resampler outputs %d", got, r.dstFormat.Channels)
+	}
+	switch rate := int(avutil.GetFrameSampleRate(dst.ptr)); rate {
+	case 0:
+		avutil.FrameSetSampleRate(dst.ptr, int32(r.dstFormat.SampleRate))
+	case r.dstFormat.SampleRate:
+	default:
+		return 0, fmt.Errorf("destination frame sample rate %d does not match resampler output %d", rate, r.dstFormat.SampleRate)
+	}
+
+	capacity := audioFrameCapacity(dst, r.dstFormat)
+	if capacity <= 0 {
+		return 0, fmt.Errorf("destination frame has no sample buffer")
+	}
+
+	// swr_convert_frame treats nb_samples of an allocated output frame as its
+	// capacity and sets it to the number of samples written.
+	avutil.FrameSetNbSamples(dst.ptr, int32(capacity))
+	if err := swresample.ConvertFrame(r.ctx, dst.ptr, src.ptr); err != nil {
+		avutil.FrameSetNbSamples(dst.ptr, 0)
+		return 0, fmt.Errorf("failed to convert frame: %w", err)
+	}
+	return int(avutil.GetFrameNbSamples(dst.ptr)), nil
+}
+
+// ConvertPooled resamples src into a frame taken from pool, sized with
+// OutSamples, and returns it. The result is owned by the caller, who returns
+// it with pool.Put once done; its buffers are then reused by the next call.
+// It returns a nil frame if no samples were produced. A nil src drains
+// buffered samples.
+func (r *Resampler) ConvertPooled(pool *FramePool, src Frame) (Frame, error) {
+	if r.closed {
+		return Frame{}, fmt.Errorf("resampler is closed")
+	}
+	if pool == nil {
+		return Frame{}, fmt.Errorf("frame pool is nil")
+	}
+
+	inSamples := 0
+	if !src.IsNil() {
+		inSamples = int(avutil.GetFrameNbSamples(src.ptr))
+	}
+	capacity := r.OutSamples(inSamples)
+	if capacity <= 0 {
+		return Frame{}, nil
+	}
+
+	dst, err := pool.GetAudioFrame(r.dstFormat, capacity)
+	if err != nil {
+		return Frame{}, err
+	}
+	n, err := r.ConvertInto(dst, src)
+	if err != nil || n == 0 {
+		_ = pool.Put(&dst)
+		return Frame{}, err
+	}
+	return dst, nil
+}
+
+// audioFrameCapacity returns how many samples per channel fit in the
+// frame's allocated buffer, from the plane size in linesize[0].
+func audioFrameCapacity(f Frame, format AudioFormat) int {
+	bytes, planar := sampleFormatSize(format.SampleFormat)
+	if bytes == 0 || avutil.GetFrameDataPlane(f.ptr, 0) == nil {
+		return 0
+	}
+	perSample := bytes
+	if !planar {
+		perSample *= format.Channels
+	}
+	return int(avutil.GetFrameLinesizePlane(f.ptr, 0)) / perSample
+}
+
+// sampleFormatSize returns the bytes per sample and whether the format is planar.
+func sampleFormatSize(f SampleFormat) (bytes int, planar bool) {
+	switch f {
+	case SampleFormatU8:
+		return 1, false
+	case SampleFormatU8P:
+		return 1, true
+	case SampleFormatS16:
+		return 2, false
+	case SampleFormatS16P:
+		return 2, true
+	case SampleFormatS32, SampleFormatFlt:
+		return 4, false
+	case SampleFormatS32P, SampleFormatFLTP:
+		return 4, true
+	case SampleFormatDbl, SampleFormatS64:
+		return 8, false
+	case SampleFormatDblP, SampleFormatS64P:
+		return 8, true
+	default:
+		return 0, false
+	}
+}
+
 // Close releases resources
 func (r *Resampler) Close() error {
 	if r.closed {
@@ -235,6 +414,33 @@ func (r *Resampler) DstFormat() AudioFormat {
 	return r.dstFormat
 }
 
+// initChannelLayout fills an AVChannelLayout for f: from the explicit mask
+// if the caller set one, otherwise FFmpeg's default for the channel count.
+func initChannelLayout(chLayout unsafe.Pointer, f AudioFormat, explicit bool) error {
+	if explicit {
+		return avutil.ChannelLayoutFromMask(chLayout, uint64(f.ChannelLayout))
+	}
+	avutil.ChannelLayoutDefault(chLayout, int32(f.Channels))
+	return nil
+}
+
+// setFrameChannelLayout sets the channel layout of an output frame to the
+// one the resampler was configured with for f: the ChannelLayout mask when
+// it has f.Channels channels, otherwise FFmpeg's default layout for the
+// count. Where ch_layout cannot be reached only the channel count is set.
+func setFrameChannelLayout(frame avutil.Frame, f AudioFormat) {
+	avutil.FrameSetChannels(frame, int32(f.Channels))
+	chLayout := avutil.GetFrameChLayoutPtr(frame)
+	if chLayout == nil {
+		return
+	}
+	if f.ChannelLayout != 0 && f.ChannelLayout.NumChannels() == f.Channels &&
+		avutil.ChannelLayoutFromMask(chLayout, uint64(f.ChannelLayout)) == nil {
+		return
+	}
+	avutil.ChannelLayoutDefault(chLayout, int32(f.Channels))
+}
+
 // defaultChannelLayout returns a default channel layout for the given number of channels
 func defaultChannelLayout(channels int) ChannelLayout {
 	switch channels {
@@ -267,8 +473,12 @@ func (cl ChannelLayout) String() string {
 		return "surround"
 	case ChannelLayout5Point0:
 		return "5.0"
+	case ChannelLayoutQuad:
+		return "quad"
 	case ChannelLayout5Point1:
 		return "5.1"
+	case ChannelLayout5Point1Back:
+		return "5.1(back)"
 	case ChannelLayout6Point1:
 		return "6.1"
 	case ChannelLayout7Point1:
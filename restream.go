@@ -0,0 +1,183 @@
+//go:build !ios && !android && (amd64 || arm64)
+
+package ffgo
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/obinnaokechukwu/ffgo/avutil"
+)
+
+// StreamFromDecoder restreams the video and audio of dec to outURL, e.g. a
+// file or camera to an RTMP server. It decodes both streams in container
+// order, converts video to the encoder's size and pixel format, resamples
+// audio to the encoder's format, and writes them interleaved through a
+// NewStreamingEncoder until the input ends.
+//
+// A nil vcfg or acfg leaves that stream out; at least one is required.
+// Zero Width, Height, FrameRate, SampleRate and Channels are taken from the
+// input stream. Pacing and reconnection are controlled by opts (typically
+// with Realtime set for file inputs); opts may be nil.
+//
+// Video and audio keep the source timing, offset by a shared start so that
+// the earlier stream starts at zero and any offset between them is kept.
+// StreamFromDecoder does not close dec.
+func StreamFromDecoder(dec *Decoder, outURL string, vcfg *VideoEncoderConfig, acfg *AudioEncoderConfig, opts *StreamingOptions) error {
+	if dec == nil {
+		return errors.New("ffgo: decoder cannot be nil")
+	}
+	if vcfg == nil && acfg == nil {
+		return errors.New("ffgo: at least one of video and audio must be configured")
+	}
+
+	options := []EncoderOption{WithStreamingOptions(opts)}
+	var srcTB Rational
+	if vcfg != nil {
+		v := dec.VideoStream()
+		if v == nil {
+			return errors.New("ffgo: decoder has no video stream")
+		}
+		cfg := *vcfg
+		if cfg.Width == 0 || cfg.Height == 0 {
+			cfg.Width, cfg.Height = v.Width, v.Height
+		}
+		if cfg.FrameRate.Num <= 0 || cfg.FrameRate.Den <= 0 {
+			cfg.FrameRate = v.FrameRate
+		}
+		srcTB = v.TimeBase
+		options = append(options, WithVideoEncoder(&cfg))
+	}
+	if acfg != nil {
+		a := dec.AudioStream()
+		if a == nil {
+			return errors.New("ffgo: decoder has no audio stream")
+		}
+		cfg := *acfg
+		if cfg.SampleRate == 0 {
+			cfg.SampleRate = a.SampleRate
+		}
+		if cfg.Channels == 0 {
+			cfg.Channels = a.Channels
+		}
+		options = append(options, WithAudioEncoder(&cfg))
+	}
+
+	enc, err := NewStreamingEncoder(outURL, options...)
+	if err != nil {
+		return err
+	}
+
+	r := &restreamer{dec: dec, enc: enc, srcTB: srcTB, start: mediaStartTime(dec)}
+	err = r.run(vcfg != nil, acfg != nil)
+	r.close()
+	if cerr := enc.Close(); err == nil {
+		err = cerr
+	}
+	return err
+}
+
+// restreamer holds the conversion state of a StreamFromDecoder run.
+type restreamer struct {
+	dec *Decoder
+	enc *Encoder
+
+	srcTB Rational      // video stream time base
+	start time.Duration // shared origin of the video and audio timestamps
+
+	scaler    *Scaler
+	scalerCfg ScalerConfig
+	audio     *AudioTranscoder
+}
+
+func (r *restreamer) run(video, audio bool) error {
+	if audio {
+		var err error
+		if r.audio, err = NewAudioTranscoder(r.dec, r.enc); err != nil {
+			return err
+		}
+		r.audio.syncTo(r.start)
+	}
+
+	for {
+		fw, err := r.dec.ReadFrame()
+		if err != nil {
+			if IsEOF(err) {
+				break
+			}
+			return err
+		}
+		switch fw.MediaType() {
+		case MediaTypeVideo:
+			if video {
+				err = r.writeVideo(fw.Raw())
+			}
+		case MediaTypeAudio:
+			if audio {
+				err = r.audio.feed(fw.Raw())
+			}
+		}
+		if err != nil {
+			return err
+		}
+	}
+
+	if r.audio != nil {
+		return r.audio.finish()
+	}
+	return nil
+}
+
+// writeVideo converts frame to the encoder's format if needed and writes it
+// with its source timestamp.
+func (r *restreamer) writeVideo(frame Frame) error {
+	pts := frameTimestamp(frame.ptr)
+	if pts != avutil.AV_NOPTS_VALUE {
+		pts -= durationToPTS(r.start, r.srcTB)
+	}
+
+	width := int(avutil.GetFrameWidth(frame.ptr))
+	height := int(avutil.GetFrameHeight(frame.ptr))
+	format := PixelFormat(avutil.GetFrameFormat(frame.ptr))
+	if width != r.enc.Width() || height != r.enc.Height() || format != r.enc.PixelFormat() {
+		cfg := ScalerConfig{
+			SrcWidth: width, SrcHeight: height, SrcFormat: format,
+			DstWidth: r.enc.Width(), DstHeight: r.enc.Height(), DstFormat: r.enc.PixelFormat(),
+		}
+		var err error
+		if r.scaler == nil {
+			r.scaler, err = NewScalerWithConfig(cfg)
+		} else if cfg != r.scalerCfg {
+			err = r.scaler.Reconfigure(cfg)
+			if err != nil {
+				_ = r.scaler.Close()
+				r.scaler = nil
+			}
+		}
+		if err != nil {
+			return fmt.Errorf("ffgo: converting %dx%d %s frame: %w",
+				width, height, avutil.GetPixFmtName(format), err)
+		}
+		r.scalerCfg = cfg
+		if frame, err = r.scaler.Scale(frame); err != nil {
+			return err
+		}
+	}
+
+	if r.srcTB.Num <= 0 || r.srcTB.Den <= 0 {
+		return r.enc.WriteFrame(frame)
+	}
+	return r.enc.WriteFrameWithPTS(frame, pts, r.srcTB)
+}
+
+func (r *restreamer) close() {
+	if r.scaler != nil {
+		_ = r.scaler.Close()
+		r.scaler = nil
+	}
+	if r.audio != nil {
+		_ = r.audio.Close()
+		r.audio = nil
+	}
+}
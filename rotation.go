@@ -0,0 +1,89 @@
+//go:build !ios && !android && (amd64 || arm64)
+
+package ffgo
+
+import (
+	"errors"
+	"math"
+
+	"github.com/obinnaokechukwu/ffgo/avutil"
+)
+
+// displayMatrixRotation returns the clockwise rotation in degrees, in
+// [0, 360), needed to show frames carrying display matrix m upright. It mirrors
+// FFmpeg's -round(av_display_rotation_get(m)), normalized the way the ffmpeg
+// CLI does before choosing a transpose.
+func displayMatrixRotation(m [9]int32) int {
+	a, b := float64(m[0])/65536, float64(m[1])/65536
+	c, d := float64(m[3])/65536, float64(m[4])/65536
+	scale0, scale1 := math.Hypot(a, c), math.Hypot(b, d)
+	if scale0 == 0 || scale1 == 0 {
+		return 0
+	}
+	theta := int(math.Round(math.Atan2(b/scale1, a/scale0) * 180 / math.Pi))
+	theta %= 360
+	if theta < 0 {
+		theta += 360
+	}
+	return theta
+}
+
+// rotationFilter returns the filter chain that turns frames with the given
+// clockwise rotation upright, or "" for 0 and angles that are not a multiple
+// of 90 degrees.
+func rotationFilter(rotation int) string {
+	switch rotation {
+	case 90:
+		return "transpose=clock"
+	case 180:
+		return "hflip,vflip"
+	case 270:
+		return "transpose=cclock"
+	}
+	return ""
+}
+
+// autoRotateLocked replaces the decoder frame with an upright copy when
+// AutoRotate is enabled and the video stream is rotated. The rotation graph
+// is rebuilt if the frame geometry changes. Must be called with d.mu held.
+func (d *Decoder) autoRotateLocked() error {
+	if !d.autoRotate || d.videoInfo == nil {
+		return nil
+	}
+	filters := rotationFilter(d.videoInfo.Rotation)
+	if filters == "" {
+		return nil
+	}
+
+	w := int(avutil.GetFrameWidth(d.frame))
+	h := int(avutil.GetFrameHeight(d.frame))
+	pixFmt := PixelFormat(avutil.GetFrameFormat(d.frame))
+	if g := d.rotateGraph; g != nil && (g.srcWidth != w || g.srcHeight != h || g.srcPixFmt != pixFmt) {
+		_ = g.Close()
+		d.rotateGraph = nil
+	}
+	if d.rotateGraph == nil {
+		g, err := NewFilterGraph(FilterGraphConfig{
+			Width:    w,
+			Height:   h,
+			PixelFmt: pixFmt,
+			TimeBase: d.videoInfo.TimeBase,
+			Filters:  filters,
+		})
+		if err != nil {
+			return err
+		}
+		d.rotateGraph = g
+	}
+
+	out, err := d.rotateGraph.Filter(&Frame{ptr: d.frame})
+	defer freeFrames(out)
+	if err != nil {
+		return err
+	}
+	if len(out) != 1 {
+		return errors.New("ffgo: rotation filter did not produce a frame")
+	}
+	avutil.FrameUnref(d.frame)
+	return avutil.FrameRef(d.frame, out[0].ptr)
+}
@@ -0,0 +1,163 @@
+//go:build !ios && !android && (amd64 || arm64)
+
+package ffgo
+
+import (
+	"os/exec"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/obinnaokechukwu/ffgo/avutil"
+)
+
+func TestDisplayMatrixRotation(t *testing.T) {
+	const one = 1 << 16
+	tests := []struct {
+		name string
+		m    [9]int32
+		want int
+	}{
+		{"identity", [9]int32{one, 0, 0, 0, one, 0, 0, 0, 1 << 30}, 0},
+		// Portrait phone recording: ffprobe reports "rotation of -90.00 degrees".
+		{"portrait", [9]int32{0, one, 0, -one, 0, 0, 0, 0, 1 << 30}, 90},
+		{"upside down", [9]int32{-one, 0, 0, 0, -one, 0, 0, 0, 1 << 30}, 180},
+		{"portrait flipped", [9]int32{0, -one, 0, one, 0, 0, 0, 0, 1 << 30}, 270},
+		{"degenerate", [9]int32{}, 0},
+	}
+	for _, tt := range tests {
+		if got := displayMatrixRotation(tt.m); got != tt.want {
+			t.Errorf("%s: displayMatrixRotation = %d, want %d", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestRotationFilter(t *testing.T) {
+	tests := map[int]string{
+		0:   "",
+		90:  "transpose=clock",
+		180: "hflip,vflip",
+		270: "transpose=cclock",
+		45:  "",
+	}
+	for rotation, want := range tests {
+		if got := rotationFilter(rotation); got != want {
+			t.Errorf("rotationFilter(%d) = %q, want %q", rotation, got, want)
+		}
+	}
+}
+
+func TestDecoderAutoRotateUnrotated(t *testing.T) {
+	if !requireFFmpeg(t) {
+		return
+	}
+
+	path := createTestVideo(t)
+	dec, err := NewDecoder(path, WithAutoRotate())
+	if err != nil {
+		t.Fatalf("NewDecoder failed: %v", err)
+	}
+	defer dec.Close()
+
+	info := dec.VideoStream()
+	if info == nil {
+		t.Fatal("no video stream")
+	}
+	if info.Rotation != 0 {
+		t.Fatalf("Rotation = %d, want 0", info.Rotation)
+	}
+
+	frame, err := dec.DecodeVideo()
+	if err != nil {
+		t.Fatalf("DecodeVideo failed: %v", err)
+	}
+	if frame.IsNil() {
+		t.Fatal("no frame decoded")
+	}
+	if w, h := int(avutil.GetFrameWidth(frame.ptr)), int(avutil.GetFrameHeight(frame.ptr)); w != info.Width || h != info.Height {
+		t.Errorf("frame is %dx%d, want %dx%d", w, h, info.Width, info.Height)
+	}
+}
+
+func TestDecoderAutoRotateAfterSeek(t *testing.T) {
+	if !requireFFmpeg(t) {
+		return
+	}
+
+	tmpDir := t.TempDir()
+	src := filepath.Join(tmpDir, "src.mp4")
+	path := filepath.Join(tmpDir, "rotated.mp4")
+	if err := exec.Command("ffmpeg", "-y",
+		"-f", "lavfi", "-i", "testsrc=duration=2:size=160x120:rate=10",
+		"-c:v", "mpeg4", "-g", "10", src).Run(); err != nil {
+		t.Skipf("ffmpeg CLI not available: %v", err)
+	}
+	if err := exec.Command("ffmpeg", "-y", "-display_rotation", "90", "-i", src,
+		"-c", "copy", path).Run(); err != nil {
+		t.Skipf("ffmpeg CLI cannot set a display rotation: %v", err)
+	}
+
+	dec, err := NewDecoder(path, WithAutoRotate())
+	if err != nil {
+		t.Fatalf("NewDecoder failed: %v", err)
+	}
+	defer dec.Close()
+	info := dec.VideoStream()
+	if info == nil || info.Rotation%180 != 90 {
+		t.Skipf("input is not rotated by 90 degrees: %+v", info)
+	}
+	if info.Width != 120 || info.Height != 160 {
+		t.Fatalf("StreamInfo is %dx%d, want 120x160", info.Width, info.Height)
+	}
+	if err := dec.OpenVideoDecoder(); err != nil {
+		t.Fatalf("OpenVideoDecoder failed: %v", err)
+	}
+
+	check := func(name string, frame Frame) {
+		t.Helper()
+		if frame.IsNil() {
+			t.Fatalf("%s: no frame", name)
+		}
+		if w, h := int(avutil.GetFrameWidth(frame.ptr)), int(avutil.GetFrameHeight(frame.ptr)); w != info.Width || h != info.Height {
+			t.Errorf("%s: frame is %dx%d, want %dx%d", name, w, h, info.Width, info.Height)
+		}
+	}
+
+	if err := dec.SeekPrecise(1250 * time.Millisecond); err != nil {
+		t.Fatalf("SeekPrecise failed: %v", err)
+	}
+	frame, err := dec.DecodeVideo()
+	if err != nil {
+		t.Fatalf("DecodeVideo after SeekPrecise failed: %v", err)
+	}
+	check("SeekPrecise", frame)
+
+	if err := dec.SeekToFrame(7); err != nil {
+		t.Fatalf("SeekToFrame failed: %v", err)
+	}
+	if frame, err = dec.DecodeVideo(); err != nil {
+		t.Fatalf("DecodeVideo after SeekToFrame failed: %v", err)
+	}
+	check("SeekToFrame", frame)
+
+	idx, err := dec.BuildFrameIndex()
+	if err != nil {
+		t.Fatalf("BuildFrameIndex failed: %v", err)
+	}
+	dec.UseFrameIndex(idx)
+	if err := dec.SeekToFrame(12); err != nil {
+		t.Fatalf("indexed SeekToFrame failed: %v", err)
+	}
+	if frame, err = dec.DecodeVideo(); err != nil {
+		t.Fatalf("DecodeVideo after indexed SeekToFrame failed: %v", err)
+	}
+	check("indexed SeekToFrame", frame)
+	dec.UseFrameIndex(nil)
+
+	at, err := dec.FrameAt(500 * time.Millisecond)
+	if err != nil {
+		t.Fatalf("FrameAt failed: %v", err)
+	}
+	defer func() { _ = FrameFree(&at) }()
+	check("FrameAt", at)
+}
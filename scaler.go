@@ -4,6 +4,8 @@ package ffgo
 
 import (
 	"errors"
+	"fmt"
+	"image"
 	"unsafe"
 
 	"github.com/obinnaokechukwu/ffgo/avutil"
@@ -12,6 +14,17 @@ import (
 )
 
 // ScaleFlags controls the scaling algorithm.
+//
+// Exactly one algorithm should be selected; modifier flags such as
+// ScaleAccurateRnd may be OR'ed in. Roughly from fastest to slowest:
+// ScalePoint, ScaleFastBilinear, ScaleBilinear, ScaleArea, ScaleBicubic,
+// ScaleLanczos. The differences matter most when downscaling by large
+// factors (thumbnails, contact sheets): point and fast bilinear skip source
+// pixels and alias badly, area averages every covered pixel and is cheap,
+// and bicubic or Lanczos give the sharpest result at the highest cost. For
+// upscaling, bicubic is usually the best tradeoff. When only the pixel
+// format changes and the size stays the same, the algorithm has no effect
+// on quality, so ScalePoint is the cheapest choice.
 type ScaleFlags int32
 
 const (
@@ -29,6 +42,34 @@ const (
 
 	// ScalePoint uses nearest neighbor (fastest, no interpolation).
 	ScalePoint ScaleFlags = swscale.FlagPoint
+
+	// ScaleArea uses area averaging (fast, good for large downscales).
+	ScaleArea ScaleFlags = swscale.FlagArea
+
+	// ScaleAccurateRnd enables accurate rounding in the conversion paths.
+	// It removes small biases (visible as banding or drift after repeated
+	// conversions) at some cost in speed. Combine it with an algorithm,
+	// e.g. ScaleBicubic | ScaleAccurateRnd.
+	ScaleAccurateRnd ScaleFlags = swscale.FlagAccurateRnd
+)
+
+// ScaleDither selects how swscale dithers when converting to a pixel format
+// with fewer bits per component (e.g. yuv420p10 to yuv420p, or to rgb565).
+type ScaleDither string
+
+const (
+	// DitherAuto lets swscale choose (the default).
+	DitherAuto ScaleDither = "auto"
+	// DitherNone disables dithering; output may show banding.
+	DitherNone ScaleDither = "none"
+	// DitherBayer uses an ordered Bayer matrix.
+	DitherBayer ScaleDither = "bayer"
+	// DitherErrorDiffusion uses error diffusion (best quality, slowest).
+	DitherErrorDiffusion ScaleDither = "ed"
+	// DitherArithmeticAdd uses arithmetic addition dithering.
+	DitherArithmeticAdd ScaleDither = "a_dither"
+	// DitherArithmeticXor uses arithmetic xor dithering.
+	DitherArithmeticXor ScaleDither = "x_dither"
 )
 
 // Scaler converts between pixel formats and scales video frames.
@@ -43,6 +84,15 @@ type Scaler struct {
 	dstHeight int
 	dstFormat PixelFormat
 
+	// Source crop and destination picture rectangles; used instead of
+	// sws_scale_frame when region is set.
+	crop, pic image.Rectangle
+	region    bool
+
+	// Configuration and dither mode the context was built with.
+	cfg    ScalerConfig
+	dither ScaleDither
+
 	// Reusable destination frame
 	dstFrame avutil.Frame
 }
@@ -58,6 +108,20 @@ type ScalerConfig struct {
 	DstFormat PixelFormat
 
 	Flags ScaleFlags
+
+	// SrcCropX, SrcCropY, SrcCropWidth and SrcCropHeight select the region
+	// of the source frame to scale, e.g. the picture inside letterbox bars
+	// (see Decoder.DetectCrop). A zero width or height extends the region to
+	// the right or bottom edge. X and Y must be multiples of the source
+	// format's chroma subsampling (even for yuv420p).
+	SrcCropX, SrcCropY          int
+	SrcCropWidth, SrcCropHeight int
+
+	// DstPadX and DstPadY pad the output: the picture is scaled to
+	// DstWidth-2*DstPadX by DstHeight-2*DstPadY, centered, and the borders
+	// are filled with black (e.g. pillarboxing 4:3 into 16:9). They must be
+	// multiples of the destination format's chroma subsampling.
+	DstPadX, DstPadY int
 }
 
 // NewScaler creates a new scaler with the specified parameters.
@@ -85,12 +149,47 @@ func NewScalerWithConfig(cfg ScalerConfig) (*Scaler, error) {
 		return nil, errors.New("ffgo: swscale library not available")
 	}
 
+	s := &Scaler{}
+	if err := s.configure(cfg); err != nil {
+		_ = s.Close()
+		return nil, err
+	}
+	return s, nil
+}
+
+// Reconfigure changes the scaler's source and destination parameters in
+// place, e.g. to cycle through several output sizes for one source without
+// a Close/NewScaler round trip. The swscale context is reused when only
+// parameters it does not depend on change (sws_getCachedContext), and the
+// destination frame is reallocated only when the output size or format
+// changes.
+//
+// It may be called between Scale calls; frames previously returned by
+// Scale are invalidated. The dither mode set with SetDither is kept, but
+// settings applied with SetColorConversion are lost if the context is
+// recreated. An invalid cfg leaves the scaler unchanged,
+// but if FFmpeg fails to set up the new context or frame the scaler is closed.
+func (s *Scaler) Reconfigure(cfg ScalerConfig) error {
+	if s.ctx == nil {
+		return errors.New("ffgo: scaler is closed")
+	}
+	return s.configure(cfg)
+}
+
+// configure validates cfg and creates or updates the swscale context and
+// the reusable destination frame to match it.
+func (s *Scaler) configure(cfg ScalerConfig) error {
 	// Validate parameters
 	if cfg.SrcWidth <= 0 || cfg.SrcHeight <= 0 {
-		return nil, errors.New("ffgo: invalid source dimensions")
+		return errors.New("ffgo: invalid source dimensions")
 	}
 	if cfg.DstWidth <= 0 || cfg.DstHeight <= 0 {
-		return nil, errors.New("ffgo: invalid destination dimensions")
+		return errors.New("ffgo: invalid destination dimensions")
+	}
+
+	crop, pic, err := scalerRegions(cfg)
+	if err != nil {
+		return err
 	}
 
 	// Default to bilinear if no flags specified
@@ -99,31 +198,47 @@ func NewScalerWithConfig(cfg ScalerConfig) (*Scaler, error) {
 		flags = ScaleBilinear
 	}
 
-	// Create swscale context
-	ctx := swscale.GetContext(
-		cfg.SrcWidth, cfg.SrcHeight, cfg.SrcFormat,
-		cfg.DstWidth, cfg.DstHeight, cfg.DstFormat,
-		int32(flags), nil, nil, nil,
-	)
-	if ctx == nil {
-		return nil, errors.New("ffgo: failed to create scaler context")
+	if s.dither == "" || s.dither == DitherAuto {
+		// Create the swscale context, reusing the current one if it matches.
+		// On failure sws_getCachedContext has already freed the old context.
+		s.ctx = swscale.GetCachedContext(s.ctx,
+			crop.Dx(), crop.Dy(), cfg.SrcFormat,
+			pic.Dx(), pic.Dy(), cfg.DstFormat,
+			int32(flags), nil, nil, nil,
+		)
+	} else {
+		// sws_getCachedContext cannot set the dither mode, so build the
+		// context from options instead.
+		swscale.FreeContext(s.ctx)
+		s.ctx = newDitheredScaleContext(crop.Size(), cfg.SrcFormat, pic.Size(), cfg.DstFormat, flags, s.dither)
 	}
+	if s.ctx == nil {
+		_ = s.Close()
+		return errors.New("ffgo: failed to create scaler context")
+	}
+
+	resize := s.dstFrame == nil || cfg.DstWidth != s.dstWidth ||
+		cfg.DstHeight != s.dstHeight || cfg.DstFormat != s.dstFormat
+
+	s.cfg = cfg
+	s.srcWidth, s.srcHeight, s.srcFormat = cfg.SrcWidth, cfg.SrcHeight, cfg.SrcFormat
+	s.dstWidth, s.dstHeight, s.dstFormat = cfg.DstWidth, cfg.DstHeight, cfg.DstFormat
+	s.crop, s.pic = crop, pic
+	s.region = crop != image.Rect(0, 0, cfg.SrcWidth, cfg.SrcHeight) ||
+		pic != image.Rect(0, 0, cfg.DstWidth, cfg.DstHeight)
 
-	s := &Scaler{
-		ctx:       ctx,
-		srcWidth:  cfg.SrcWidth,
-		srcHeight: cfg.SrcHeight,
-		srcFormat: cfg.SrcFormat,
-		dstWidth:  cfg.DstWidth,
-		dstHeight: cfg.DstHeight,
-		dstFormat: cfg.DstFormat,
+	if !resize {
+		return nil
 	}
 
 	// Allocate destination frame
-	s.dstFrame = avutil.FrameAlloc()
 	if s.dstFrame == nil {
-		swscale.FreeContext(ctx)
-		return nil, errors.New("ffgo: failed to allocate destination frame")
+		s.dstFrame = avutil.FrameAlloc()
+		if s.dstFrame == nil {
+			return errors.New("ffgo: failed to allocate destination frame")
+		}
+	} else {
+		avutil.FrameUnref(s.dstFrame)
 	}
 
 	// Set up destination frame
@@ -133,12 +248,11 @@ func NewScalerWithConfig(cfg ScalerConfig) (*Scaler, error) {
 
 	// Allocate buffer
 	if err := avutil.FrameGetBufferErr(s.dstFrame, 0); err != nil {
-		avutil.FrameFree(&s.dstFrame)
-		swscale.FreeContext(ctx)
-		return nil, err
+		_ = s.Close()
+		return err
 	}
 
-	return s, nil
+	return nil
 }
 
 // Scale converts and scales the source frame.
@@ -154,9 +268,8 @@ func (s *Scaler) Scale(src Frame) (Frame, error) {
 	}
 
 	// Perform scaling
-	ret := swscale.ScaleFrame(s.ctx, s.dstFrame, src.ptr)
-	if ret < 0 {
-		return Frame{}, avutil.NewError(ret, "sws_scale_frame")
+	if err := s.scaleFrame(s.dstFrame, src.ptr); err != nil {
+		return Frame{}, err
 	}
 
 	// Returned frame is owned by the scaler (reused); clone if you need to keep it.
@@ -171,14 +284,245 @@ func (s *Scaler) ScaleTo(dst, src Frame) error {
 		return errors.New("ffgo: scaler is closed")
 	}
 
-	ret := swscale.ScaleFrame(s.ctx, dst.ptr, src.ptr)
-	if ret < 0 {
-		return avutil.NewError(ret, "sws_scale_frame")
+	return s.scaleFrame(dst.ptr, src.ptr)
+}
+
+// ScalePooled scales src into a frame taken from pool and returns it.
+// Unlike Scale, the result is owned by the caller, who returns it with
+// pool.Put once done; its buffers are then reused by the next call.
+// The source PTS is carried over to the result.
+func (s *Scaler) ScalePooled(pool *FramePool, src Frame) (Frame, error) {
+	if s.ctx == nil {
+		return Frame{}, errors.New("ffgo: scaler is closed")
 	}
+	if pool == nil {
+		return Frame{}, errors.New("ffgo: frame pool is nil")
+	}
+
+	dst, err := pool.GetVideoFrame(s.dstWidth, s.dstHeight, s.dstFormat)
+	if err != nil {
+		return Frame{}, err
+	}
+	if err := s.scaleFrame(dst.ptr, src.ptr); err != nil {
+		_ = pool.Put(&dst)
+		return Frame{}, err
+	}
+	avutil.SetFramePTS(dst.ptr, avutil.GetFramePTS(src.ptr))
+	return dst, nil
+}
 
+// scaleFrame scales src into dst, applying the crop and padding if set.
+func (s *Scaler) scaleFrame(dst, src avutil.Frame) error {
+	if !s.region {
+		if ret := swscale.ScaleFrame(s.ctx, dst, src); ret < 0 {
+			return avutil.NewError(ret, "sws_scale_frame")
+		}
+		return nil
+	}
+
+	if int(avutil.GetFrameWidth(src)) < s.crop.Max.X || int(avutil.GetFrameHeight(src)) < s.crop.Max.Y {
+		return errors.New("ffgo: source frame is smaller than the crop region")
+	}
+	if int(avutil.GetFrameWidth(dst)) < s.dstWidth || int(avutil.GetFrameHeight(dst)) < s.dstHeight {
+		return errors.New("ffgo: destination frame is smaller than the scaler output")
+	}
+
+	srcData, srcLinesize := avutil.GetFrameData(src), avutil.GetFrameLinesize(src)
+	dstData, dstLinesize := avutil.GetFrameData(dst), avutil.GetFrameLinesize(dst)
+
+	// Fill the borders around the picture.
+	full := image.Rect(0, 0, s.dstWidth, s.dstHeight)
+	for _, band := range []image.Rectangle{
+		image.Rect(0, 0, s.dstWidth, s.pic.Min.Y),                     // top
+		image.Rect(0, s.pic.Max.Y, s.dstWidth, s.dstHeight),           // bottom
+		image.Rect(0, s.pic.Min.Y, s.pic.Min.X, s.pic.Max.Y),          // left
+		image.Rect(s.pic.Max.X, s.pic.Min.Y, s.dstWidth, s.pic.Max.Y), // right
+	} {
+		band = band.Intersect(full)
+		if band.Empty() {
+			continue
+		}
+		data, err := planeOffsets(s.dstFormat, dstData, dstLinesize, band.Min)
+		if err != nil {
+			return err
+		}
+		var fillData [4]unsafe.Pointer
+		var fillLinesize [4]int64
+		for i := range fillData {
+			fillData[i] = data[i]
+			fillLinesize[i] = int64(dstLinesize[i])
+		}
+		if err := avutil.ImageFillBlack(fillData, fillLinesize, s.dstFormat, 0, band.Dx(), band.Dy()); err != nil {
+			return err
+		}
+	}
+
+	srcPlanes, err := planeOffsets(s.srcFormat, srcData, srcLinesize, s.crop.Min)
+	if err != nil {
+		return err
+	}
+	dstPlanes, err := planeOffsets(s.dstFormat, dstData, dstLinesize, s.pic.Min)
+	if err != nil {
+		return err
+	}
+	if ret := swscale.Scale(s.ctx, &srcPlanes, &srcLinesize, 0, int32(s.crop.Dy()), &dstPlanes, &dstLinesize); ret < 0 {
+		return avutil.NewError(ret, "sws_scale")
+	}
+	return nil
+}
+
+// scalerRegions validates the crop and padding of cfg and returns the
+// source region to scale and the destination rectangle it is scaled into.
+func scalerRegions(cfg ScalerConfig) (crop, pic image.Rectangle, err error) {
+	if cfg.SrcCropX < 0 || cfg.SrcCropY < 0 || cfg.SrcCropWidth < 0 || cfg.SrcCropHeight < 0 {
+		return crop, pic, errors.New("ffgo: crop values must not be negative")
+	}
+	w, h := cfg.SrcCropWidth, cfg.SrcCropHeight
+	if w == 0 {
+		w = cfg.SrcWidth - cfg.SrcCropX
+	}
+	if h == 0 {
+		h = cfg.SrcHeight - cfg.SrcCropY
+	}
+	crop = image.Rect(cfg.SrcCropX, cfg.SrcCropY, cfg.SrcCropX+w, cfg.SrcCropY+h)
+	if crop.Empty() || !crop.In(image.Rect(0, 0, cfg.SrcWidth, cfg.SrcHeight)) {
+		return crop, pic, fmt.Errorf("ffgo: crop %dx%d+%d+%d is outside the %dx%d source",
+			w, h, cfg.SrcCropX, cfg.SrcCropY, cfg.SrcWidth, cfg.SrcHeight)
+	}
+
+	if cfg.DstPadX < 0 || cfg.DstPadY < 0 {
+		return crop, pic, errors.New("ffgo: padding must not be negative")
+	}
+	pic = image.Rect(cfg.DstPadX, cfg.DstPadY, cfg.DstWidth-cfg.DstPadX, cfg.DstHeight-cfg.DstPadY)
+	if pic.Empty() {
+		return crop, pic, errors.New("ffgo: padding leaves no room for the picture")
+	}
+
+	if crop.Min != (image.Point{}) {
+		if err := checkChromaAligned(cfg.SrcFormat, crop.Min, "crop offset"); err != nil {
+			return crop, pic, err
+		}
+	}
+	if pic.Min != (image.Point{}) {
+		if err := checkChromaAligned(cfg.DstFormat, pic.Min, "padding"); err != nil {
+			return crop, pic, err
+		}
+	}
+	return crop, pic, nil
+}
+
+// checkChromaAligned reports an error if p does not fall on a chroma sample
+// of pixFmt, or if pixFmt's planes cannot be addressed at an offset.
+func checkChromaAligned(pixFmt PixelFormat, p image.Point, what string) error {
+	desc := avutil.GetPixFmtDescriptor(pixFmt)
+	if desc == nil {
+		return fmt.Errorf("ffgo: unknown pixel format %d", pixFmt)
+	}
+	if desc.Flags&(avutil.PixFmtFlagBitstream|avutil.PixFmtFlagHWAccel) != 0 {
+		return fmt.Errorf("ffgo: %s is not supported for pixel format %s", what, avutil.GetPixFmtName(pixFmt))
+	}
+	if p.X%(1<<desc.Log2ChromaW) != 0 || p.Y%(1<<desc.Log2ChromaH) != 0 {
+		return fmt.Errorf("ffgo: %s %d,%d is not aligned to the chroma subsampling of %s",
+			what, p.X, p.Y, avutil.GetPixFmtName(pixFmt))
+	}
 	return nil
 }
 
+// planeOffsets returns data with each plane pointer advanced to pixel p, as
+// av_frame_apply_cropping does. Palettes are left in place.
+func planeOffsets(pixFmt PixelFormat, data [8]unsafe.Pointer, linesize [8]int32, p image.Point) ([8]unsafe.Pointer, error) {
+	desc := avutil.GetPixFmtDescriptor(pixFmt)
+	if desc == nil {
+		return data, fmt.Errorf("ffgo: unknown pixel format %d", pixFmt)
+	}
+	for i := 0; i < len(data) && data[i] != nil; i++ {
+		if desc.Flags&avutil.PixFmtFlagPAL != 0 && i == 1 {
+			break
+		}
+		shiftX, shiftY := 0, 0
+		if i == 1 || i == 2 {
+			shiftX, shiftY = desc.Log2ChromaW, desc.Log2ChromaH
+		}
+		step := -1
+		for c := 0; c < desc.NbComponents; c++ {
+			if int(desc.Comp[c].Plane) == i {
+				step = int(desc.Comp[c].Step)
+				break
+			}
+		}
+		if step < 0 {
+			return data, fmt.Errorf("ffgo: no component for plane %d of %s", i, avutil.GetPixFmtName(pixFmt))
+		}
+		data[i] = unsafe.Add(data[i], (p.Y>>shiftY)*int(linesize[i])+(p.X>>shiftX)*step)
+	}
+	return data, nil
+}
+
+// newDitheredScaleContext creates a swscale context with an explicit dither
+// mode via sws_alloc_context, AVOptions and sws_init_context. Returns nil
+// on failure.
+func newDitheredScaleContext(src image.Point, srcFmt PixelFormat, dst image.Point, dstFmt PixelFormat, flags ScaleFlags, dither ScaleDither) swscale.Context {
+	ctx := swscale.AllocContext()
+	if ctx == nil {
+		return nil
+	}
+	opts := []struct {
+		name string
+		val  int64
+	}{
+		{"srcw", int64(src.X)},
+		{"srch", int64(src.Y)},
+		{"src_format", int64(srcFmt)},
+		{"dstw", int64(dst.X)},
+		{"dsth", int64(dst.Y)},
+		{"dst_format", int64(dstFmt)},
+		{"sws_flags", int64(flags)},
+	}
+	for _, o := range opts {
+		if err := avutil.OptSetInt(ctx, o.name, o.val, 0); err != nil {
+			swscale.FreeContext(ctx)
+			return nil
+		}
+	}
+	if err := avutil.OptSet(ctx, "sws_dither", string(dither), 0); err != nil {
+		swscale.FreeContext(ctx)
+		return nil
+	}
+	if swscale.InitContext(ctx, nil, nil) < 0 {
+		swscale.FreeContext(ctx)
+		return nil
+	}
+	return ctx
+}
+
+// SetDither sets the dither mode used when reducing bit depth and rebuilds
+// the swscale context with it. The mode is kept across Reconfigure calls.
+// Settings applied with SetColorConversion are lost. If the context cannot
+// be rebuilt the scaler is closed.
+func (s *Scaler) SetDither(d ScaleDither) error {
+	if s.ctx == nil {
+		return errors.New("ffgo: scaler is closed")
+	}
+	switch d {
+	case DitherAuto, DitherNone, DitherBayer, DitherErrorDiffusion, DitherArithmeticAdd, DitherArithmeticXor:
+	default:
+		return fmt.Errorf("ffgo: unknown dither mode %q", d)
+	}
+	s.dither = d
+	// Drop the current context so a cached one with the old mode is not reused.
+	swscale.FreeContext(s.ctx)
+	s.ctx = nil
+	return s.configure(s.cfg)
+}
+
+// Dither returns the dither mode set with SetDither (DitherAuto by default).
+func (s *Scaler) Dither() ScaleDither {
+	if s.dither == "" {
+		return DitherAuto
+	}
+	return s.dither
+}
+
 // SetColorConversion configures the scaler's color range handling (limited/full).
 //
 // Note: This is a best-effort helper. If the underlying swscale build does not expose
@@ -4,6 +4,8 @@ package ffgo
 
 import (
 	"errors"
+	"fmt"
+	"sort"
 	"time"
 
 	"github.com/obinnaokechukwu/ffgo/avcodec"
@@ -11,10 +13,71 @@ import (
 	"github.com/obinnaokechukwu/ffgo/avutil"
 )
 
+// SeekOptions controls how SeekWithOptions positions the demuxer.
+type SeekOptions struct {
+	// Backward seeks to the nearest seek point at or before the target
+	// instead of at or after it.
+	Backward bool
+
+	// AnyFrame allows landing on a non-keyframe. Without decoding forward
+	// from a keyframe, the frames that follow may decode as garbage until
+	// the next keyframe arrives.
+	AnyFrame bool
+
+	// ByByte seeks to the byte offset Position instead of a time; the
+	// target passed to SeekWithOptions is ignored.
+	ByByte bool
+
+	// Position is the byte offset to seek to. It is used only with ByByte.
+	Position int64
+}
+
+// SeekWithOptions seeks to target using the direction and keyframe
+// behaviour described by opts. Codec buffers are flushed afterwards so no
+// frames from before the seek are returned.
+//
+// When opts.ByByte is set, the seek goes to the byte offset opts.Position
+// and target is ignored.
+func (d *Decoder) SeekWithOptions(target time.Duration, opts SeekOptions) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if d.closed {
+		return errors.New("ffgo: decoder is closed")
+	}
+
+	var flags int32
+	if opts.Backward {
+		flags |= avformat.SeekFlagBackward
+	}
+	if opts.AnyFrame {
+		flags |= avformat.SeekFlagAny
+	}
+
+	timestamp := target.Microseconds()
+	if opts.ByByte {
+		if opts.Position < 0 {
+			return errors.New("ffgo: byte position must not be negative")
+		}
+		flags |= avformat.SeekFlagByte
+		timestamp = opts.Position
+	}
+
+	if err := avformat.SeekFrame(d.formatCtx, -1, timestamp, flags); err != nil {
+		return err
+	}
+
+	// Flush decoder buffers
+	d.flushCodecsLocked()
+
+	return nil
+}
+
 // SeekPrecise performs frame-accurate seeking to the specified timestamp.
 // Unlike Seek which seeks to the nearest keyframe, SeekPrecise decodes
 // frames from the keyframe until reaching the exact target frame.
-// This is slower but guarantees frame-accurate positioning.
+// This is slower but guarantees frame-accurate positioning: the next
+// DecodeVideo or ReadFrame returns the first frame at or after ts.
 func (d *Decoder) SeekPrecise(ts time.Duration) error {
 	d.mu.Lock()
 	defer d.mu.Unlock()
@@ -30,12 +93,7 @@ func (d *Decoder) SeekPrecise(ts time.Duration) error {
 	}
 
 	// Flush decoder buffers
-	if d.videoCodecCtx != nil {
-		avcodec.FlushBuffers(d.videoCodecCtx)
-	}
-	if d.audioCodecCtx != nil {
-		avcodec.FlushBuffers(d.audioCodecCtx)
-	}
+	d.flushCodecsLocked()
 
 	// If no video stream, we're done
 	if d.videoStreamIdx < 0 || !d.videoDecoderOpen {
@@ -68,7 +126,7 @@ func (d *Decoder) SeekPrecise(ts time.Duration) error {
 			if avutil.IsEOF(err) {
 				return nil // Reached end, stop
 			}
-			return err
+			return d.interrupt.err(err)
 		}
 
 		streamIdx := avcodec.GetPacketStreamIndex(d.packet)
@@ -86,6 +144,7 @@ func (d *Decoder) SeekPrecise(ts time.Duration) error {
 			return err
 		}
 		avcodec.PacketUnref(d.packet)
+		d.videoPending = true
 
 		// Receive frames
 		for {
@@ -95,26 +154,148 @@ func (d *Decoder) SeekPrecise(ts time.Duration) error {
 					break // Need more packets
 				}
 				if avutil.IsEOF(err) {
+					d.videoPending = false
 					return nil
 				}
 				return err
 			}
 
-			// Check if we've reached the target
-			framePTS := avutil.GetFramePTS(d.frame)
-			if framePTS >= targetPTS {
-				// We've reached or passed the target
-				// Unref the frame so next decode gets this frame
+			// Check if we've reached the target. Frames without any
+			// timestamp cannot be placed and are skipped.
+			framePTS := frameTimestamp(d.frame)
+			if framePTS != avutil.NoPTSValue && framePTS >= targetPTS {
+				// Keep the target frame for the next DecodeVideo or
+				// ReadFrame, as indexed SeekToFrame does, upright like
+				// the frames DecodeVideoPacket returns.
+				if err := d.autoRotateLocked(); err != nil {
+					avutil.FrameUnref(d.frame)
+					return err
+				}
+				err := d.holdVideoLocked(d.frame)
 				avutil.FrameUnref(d.frame)
-				return nil
+				return err
 			}
 		}
 	}
 }
 
+// FrameAt returns the decoded video frame at or immediately after t, which
+// is measured from the start of the video stream.
+//
+// It seeks backward to the keyframe preceding t and decodes forward,
+// discarding frames whose presentation time is before t. If the stream ends
+// first, the last decoded frame is returned. Unlike ExtractThumbnail, no
+// scaling or conversion is applied.
+//
+// The returned frame is owned by the caller and must be freed with FrameFree.
+func (d *Decoder) FrameAt(t time.Duration) (Frame, error) {
+	if t < 0 {
+		return Frame{}, errors.New("ffgo: timestamp must not be negative")
+	}
+	if err := d.OpenVideoDecoder(); err != nil {
+		return Frame{}, err
+	}
+	if dur := d.Duration(); dur > 0 && t > dur {
+		return Frame{}, errors.New("ffgo: timestamp is past the end of the stream")
+	}
+
+	// Frame timestamps and seek targets are absolute; t is not.
+	var tb Rational
+	if info := d.VideoStream(); info != nil {
+		tb = info.TimeBase
+	}
+	if stream := avformat.GetStream(d.formatCtx, d.videoStreamIdx); stream != nil {
+		if st := avformat.GetStreamStartTime(stream); st != avutil.AV_NOPTS_VALUE {
+			t += ptsToDuration(st, tb)
+		}
+	}
+
+	if err := d.SeekWithOptions(t, SeekOptions{Backward: true}); err != nil {
+		return Frame{}, err
+	}
+	var prev Frame
+	for {
+		frame, err := d.DecodeVideo()
+		if err != nil {
+			_ = FrameFree(&prev)
+			return Frame{}, err
+		}
+		if frame.IsNil() {
+			// EOF: the last frame is the closest one to t.
+			if prev.IsNil() {
+				return Frame{}, errors.New("ffgo: no frame decoded at timestamp")
+			}
+			return prev, nil
+		}
+
+		pts := frameTimestamp(frame.ptr)
+		if pts != avutil.NoPTSValue && ptsToDuration(pts, tb) >= t {
+			_ = FrameFree(&prev)
+			return FrameClone(frame)
+		}
+
+		// Keep a reference so EOF can still return the closest frame.
+		_ = FrameFree(&prev)
+		if prev, err = FrameClone(frame); err != nil {
+			return Frame{}, err
+		}
+	}
+}
+
+// frameTimestamp returns the frame's best_effort_timestamp, which the
+// decoder fills in for frames whose PTS is missing, falling back to the PTS
+// when the estimate is unavailable.
+func frameTimestamp(frame avutil.Frame) int64 {
+	if ts := avutil.GetFrameBestEffortTimestamp(frame); ts != avutil.NoPTSValue {
+		return ts
+	}
+	return avutil.GetFramePTS(frame)
+}
+
+// mediaStartTime returns the earliest start time of the decoder's video and
+// audio streams, the common origin to time both from so that an offset
+// between them survives re-encoding. It is 0 if neither has a start time.
+func mediaStartTime(d *Decoder) time.Duration {
+	var (
+		start time.Duration
+		found bool
+	)
+	for _, idx := range []int{d.videoStreamIdx, d.audioStreamIdx} {
+		if idx < 0 {
+			continue
+		}
+		stream := avformat.GetStream(d.formatCtx, idx)
+		if stream == nil {
+			continue
+		}
+		st := avformat.GetStreamStartTime(stream)
+		if st == avutil.AV_NOPTS_VALUE {
+			continue
+		}
+		num, den := avformat.GetStreamTimeBase(stream)
+		if t := ptsToDuration(st, NewRational(num, den)); !found || t < start {
+			start, found = t, true
+		}
+	}
+	return start
+}
+
+// ptsToDuration converts a timestamp in tb units to a time.Duration.
+func ptsToDuration(pts int64, tb Rational) time.Duration {
+	if tb.Den == 0 {
+		return 0
+	}
+	return time.Duration(float64(pts) * float64(tb.Num) / float64(tb.Den) * float64(time.Second))
+}
+
 // SeekToFrame seeks to a specific frame number.
 // frameNum is 0-based (first frame is 0).
 // This method uses frame-accurate seeking internally.
+//
+// Without a frame index the frame number is converted to a time using the
+// average frame rate, which is approximate for variable-frame-rate input.
+// After UseFrameIndex, the seek is exact and the next DecodeVideo returns
+// frame frameNum.
 func (d *Decoder) SeekToFrame(frameNum int64) error {
 	d.mu.Lock()
 	defer d.mu.Unlock()
@@ -127,6 +308,13 @@ func (d *Decoder) SeekToFrame(frameNum int64) error {
 		return errors.New("ffgo: no video stream")
 	}
 
+	if d.frameIndex != nil {
+		d.mu.Unlock()
+		err := d.seekToIndexedFrame(frameNum)
+		d.mu.Lock()
+		return err
+	}
+
 	// Get frame rate to calculate timestamp
 	stream := avformat.GetStream(d.formatCtx, d.videoStreamIdx)
 	if stream == nil {
@@ -206,11 +394,7 @@ func (d *Decoder) ExtractThumbnails(count int) ([]Frame, error) {
 	}
 
 	frames := make([]Frame, 0, count)
-	interval := duration / time.Duration(count+1)
-
-	for i := 1; i <= count; i++ {
-		ts := interval * time.Duration(i)
-
+	for _, ts := range thumbnailTimes(duration, count) {
 		frame, err := d.ExtractThumbnail(ts)
 		if err != nil {
 			// Free already extracted frames
@@ -226,6 +410,90 @@ func (d *Decoder) ExtractThumbnails(count int) ([]Frame, error) {
 	return frames, nil
 }
 
+// maxSmartThumbnailWindow caps how many frames of each segment
+// ExtractSmartThumbnails scores; it is the thumbnail filter's default.
+const maxSmartThumbnailWindow = 100
+
+// ExtractSmartThumbnails extracts count representative frames using FFmpeg's
+// thumbnail filter. The video is split into count equal segments and, from
+// the start of each, up to 100 frames (fewer if the segment is shorter) are
+// scored by how close each is to the window's average color histogram; the
+// most representative one is kept. This avoids most of the black, faded or
+// blurry frames that evenly spaced ExtractThumbnails can land on, at the
+// cost of decoding each window.
+//
+// Frames are returned in segment order; segments past the end of the stream
+// are skipped. The returned frames must be freed by the caller when done.
+func (d *Decoder) ExtractSmartThumbnails(count int) ([]Frame, error) {
+	if count <= 0 {
+		return nil, errors.New("ffgo: count must be positive")
+	}
+	if err := d.OpenVideoDecoder(); err != nil {
+		return nil, err
+	}
+
+	duration := d.Duration()
+	if duration <= 0 {
+		return nil, errors.New("ffgo: cannot determine duration")
+	}
+	segment := duration / time.Duration(count)
+	window := maxSmartThumbnailWindow
+	if fr := d.VideoStream().FrameRate; fr.Num > 0 && fr.Den > 0 {
+		if n := int(segment.Seconds() * float64(fr.Num) / float64(fr.Den)); n < window {
+			window = max(n, 1)
+		}
+	}
+	filter := fmt.Sprintf("thumbnail=n=%d", window)
+
+	frames := make([]Frame, 0, count)
+	for i := 0; i < count; i++ {
+		if err := d.Seek(segment * time.Duration(i)); err != nil {
+			freeFrames(frames)
+			return nil, err
+		}
+
+		var (
+			best    Frame
+			keepErr error
+		)
+		err := d.runAnalysisFilter(true, filter, window, func(f Frame) {
+			if !best.IsNil() || keepErr != nil {
+				return
+			}
+			best, keepErr = FrameClone(f)
+		})
+		if err == nil {
+			err = keepErr
+		}
+		if errors.Is(err, errNoFramesDecoded) {
+			break // segment starts past the last frame
+		}
+		if err != nil {
+			_ = FrameFree(&best)
+			freeFrames(frames)
+			return nil, err
+		}
+		if !best.IsNil() {
+			frames = append(frames, best)
+		}
+	}
+	if len(frames) == 0 {
+		return nil, errNoFramesDecoded
+	}
+	return frames, nil
+}
+
+// thumbnailTimes returns count timestamps evenly spaced over duration,
+// excluding the very start and end.
+func thumbnailTimes(duration time.Duration, count int) []time.Duration {
+	interval := duration / time.Duration(count+1)
+	times := make([]time.Duration, count)
+	for i := range times {
+		times[i] = interval * time.Duration(i+1)
+	}
+	return times
+}
+
 // SeekKeyframe seeks to the nearest keyframe at or before the specified timestamp.
 // This is faster than SeekPrecise but may not land exactly on the target.
 func (d *Decoder) SeekKeyframe(ts time.Duration) error {
@@ -249,12 +517,7 @@ func (d *Decoder) SeekAny(ts time.Duration) error {
 	}
 
 	// Flush decoder buffers
-	if d.videoCodecCtx != nil {
-		avcodec.FlushBuffers(d.videoCodecCtx)
-	}
-	if d.audioCodecCtx != nil {
-		avcodec.FlushBuffers(d.audioCodecCtx)
-	}
+	d.flushCodecsLocked()
 
 	return nil
 }
@@ -274,12 +537,7 @@ func (d *Decoder) SeekByBytes(bytePos int64) error {
 	}
 
 	// Flush decoder buffers
-	if d.videoCodecCtx != nil {
-		avcodec.FlushBuffers(d.videoCodecCtx)
-	}
-	if d.audioCodecCtx != nil {
-		avcodec.FlushBuffers(d.audioCodecCtx)
-	}
+	d.flushCodecsLocked()
 
 	return nil
 }
@@ -313,3 +571,154 @@ func (d *Decoder) TotalFrames() int64 {
 	durationUS := d.DurationMicroseconds()
 	return durationUS * int64(fpsNum) / (int64(fpsDen) * 1000000)
 }
+
+// UseFrameIndex makes SeekToFrame use idx, as returned by BuildFrameIndex
+// for this input, for frame-accurate seeking: SeekToFrame(n) seeks to the
+// keyframe at or before entry n (by byte position when the demuxer allows
+// it) and decodes forward to frame n. This works even for containers with
+// poor internal indexes, at the cost of the initial pass over the file that
+// builds the index. Pass nil to return to time-based seeking.
+func (d *Decoder) UseFrameIndex(idx []FrameIndexEntry) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.frameIndex = idx
+}
+
+// seekToIndexedFrame positions the decoder so that the next DecodeVideo
+// returns entry frameNum of the frame index.
+func (d *Decoder) seekToIndexedFrame(frameNum int64) error {
+	if err := d.OpenVideoDecoder(); err != nil {
+		return err
+	}
+
+	d.mu.Lock()
+	idx := d.frameIndex
+	if frameNum < 0 || frameNum >= int64(len(idx)) {
+		d.mu.Unlock()
+		return fmt.Errorf("ffgo: frame %d is outside the frame index (%d frames)", frameNum, len(idx))
+	}
+	target := idx[frameNum]
+	key := frameNum
+	for key > 0 && !idx[key].KeyFrame {
+		key--
+	}
+	kf := idx[key]
+
+	// A byte seek lands exactly on the keyframe packet; demuxers that
+	// cannot seek by bytes (e.g. MP4) seek by its timestamp instead.
+	err := errors.New("ffgo: no keyframe position")
+	if kf.Position >= 0 {
+		err = avformat.SeekFrame(d.formatCtx, -1, kf.Position, avformat.SeekFlagByte)
+	}
+	if err != nil {
+		err = avformat.SeekFrame(d.formatCtx, int32(d.videoStreamIdx), kf.PTS, avformat.SeekFlagBackward)
+	}
+	if err != nil {
+		d.mu.Unlock()
+		return err
+	}
+	d.flushCodecsLocked()
+	d.mu.Unlock()
+
+	for {
+		frame, err := d.DecodeVideo()
+		if err != nil {
+			return err
+		}
+		if frame.IsNil() {
+			return fmt.Errorf("ffgo: frame %d not reached before end of stream", frameNum)
+		}
+		if pts := frameTimestamp(frame.ptr); pts != avutil.NoPTSValue && pts >= target.PTS {
+			d.mu.Lock()
+			err := d.holdVideoLocked(frame.ptr)
+			d.mu.Unlock()
+			return err
+		}
+	}
+}
+
+// FrameIndexEntry describes one video packet (one frame for most codecs)
+// as recorded by BuildFrameIndex.
+type FrameIndexEntry struct {
+	PTS         int64         // Presentation timestamp (DTS if the packet has none)
+	DTS         int64         // Decoding timestamp
+	Time        time.Duration // PTS as a time
+	Duration    int64         // Packet duration in stream time base (0 if unknown)
+	Position    int64         // Byte position in file (-1 if unknown)
+	Size        int           // Packet size in bytes
+	KeyFrame    bool          // Packet starts a keyframe
+	StreamIndex int           // Index of the video stream
+}
+
+// BuildFrameIndex reads every packet of the video stream, without decoding,
+// and returns one entry per packet in presentation order, so entry i is
+// frame number i. Use it to map frame numbers to timestamps and byte
+// positions for frame-accurate seeking. Packets without any timestamp are
+// skipped.
+//
+// Like GetKeyframes, it reads the whole file and leaves the decoder
+// positioned at the start.
+func (d *Decoder) BuildFrameIndex() ([]FrameIndexEntry, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if d.closed {
+		return nil, errors.New("ffgo: decoder is closed")
+	}
+	if d.videoStreamIdx < 0 {
+		return nil, errors.New("ffgo: no video stream")
+	}
+
+	stream := avformat.GetStream(d.formatCtx, d.videoStreamIdx)
+	if stream == nil {
+		return nil, errors.New("ffgo: failed to get video stream")
+	}
+	tbNum, tbDen := avformat.GetStreamTimeBase(stream)
+	tb := NewRational(tbNum, tbDen)
+
+	_ = avformat.SeekFrame(d.formatCtx, -1, 0, avformat.SeekFlagBackward)
+	defer func() {
+		// Seek back to beginning (errors are non-fatal)
+		_ = avformat.SeekFrame(d.formatCtx, -1, 0, avformat.SeekFlagBackward)
+		d.flushCodecsLocked()
+	}()
+
+	var entries []FrameIndexEntry
+	for {
+		if err := avformat.ReadFrame(d.formatCtx, d.packet); err != nil {
+			if avutil.IsEOF(err) {
+				break
+			}
+			return nil, d.interrupt.err(err)
+		}
+
+		if int(avcodec.GetPacketStreamIndex(d.packet)) != d.videoStreamIdx {
+			avcodec.PacketUnref(d.packet)
+			continue
+		}
+
+		pts := avcodec.GetPacketPTS(d.packet)
+		dts := avcodec.GetPacketDTS(d.packet)
+		if pts == avutil.NoPTSValue {
+			pts = dts
+		}
+		if pts != avutil.NoPTSValue {
+			entries = append(entries, FrameIndexEntry{
+				PTS:         pts,
+				DTS:         dts,
+				Time:        ptsToDuration(pts, tb),
+				Duration:    avcodec.GetPacketDuration(d.packet),
+				Position:    avcodec.GetPacketPos(d.packet),
+				Size:        int(avcodec.GetPacketSize(d.packet)),
+				KeyFrame:    avcodec.GetPacketFlags(d.packet)&avcodec.PacketFlagKey != 0,
+				StreamIndex: d.videoStreamIdx,
+			})
+		}
+		avcodec.PacketUnref(d.packet)
+	}
+
+	// Packets arrive in decoding order; B-frames make it differ from
+	// presentation order.
+	sort.SliceStable(entries, func(i, j int) bool { return entries[i].PTS < entries[j].PTS })
+	return entries, nil
+}
@@ -3,6 +3,7 @@
 package ffgo
 
 import (
+	"errors"
 	"fmt"
 	"strconv"
 	"strings"
@@ -51,6 +52,77 @@ func (cfg *HLSSegmenterConfig) HeaderOptions() (map[string]string, error) {
 	return opts, nil
 }
 
+// HLSOptions configures HLS output from an Encoder (EncoderOptions.HLS).
+type HLSOptions struct {
+	// SegmentTime is the target segment duration (hls_time). Segments are
+	// cut on keyframes, so keep GOPSize at or below it. Zero uses FFmpeg's
+	// default (2s).
+	SegmentTime time.Duration
+
+	// ListSize is the maximum number of playlist entries (hls_list_size).
+	// Zero uses FFmpeg's default (5).
+	ListSize int
+
+	// SegmentFilename is the segment path pattern (hls_segment_filename),
+	// e.g. "out/seg_%03d.ts". If empty, segments are named after the playlist.
+	SegmentFilename string
+
+	// PlaylistType is "vod" or "event" (hls_playlist_type). Both keep every
+	// segment in the playlist; empty writes a live sliding-window playlist.
+	PlaylistType string
+
+	// Flags are hls_flags values, e.g. "delete_segments", "independent_segments".
+	Flags []string
+}
+
+func (o *HLSOptions) validate() error {
+	if o.SegmentTime < 0 {
+		return errors.New("ffgo: HLSOptions.SegmentTime must not be negative")
+	}
+	if o.ListSize < 0 {
+		return errors.New("ffgo: HLSOptions.ListSize must not be negative")
+	}
+	switch o.PlaylistType {
+	case "", "vod", "event":
+	default:
+		return fmt.Errorf("ffgo: HLSOptions.PlaylistType %q is not \"vod\" or \"event\"", o.PlaylistType)
+	}
+	return nil
+}
+
+// muxerOptions returns the hls muxer options for o.
+func (o *HLSOptions) muxerOptions() map[string]string {
+	opts := make(map[string]string, 5)
+	if o.SegmentTime > 0 {
+		opts["hls_time"] = strconv.FormatFloat(o.SegmentTime.Seconds(), 'f', -1, 64)
+	}
+	if o.ListSize > 0 {
+		opts["hls_list_size"] = strconv.Itoa(o.ListSize)
+	}
+	if o.SegmentFilename != "" {
+		opts["hls_segment_filename"] = o.SegmentFilename
+	}
+	if o.PlaylistType != "" {
+		opts["hls_playlist_type"] = o.PlaylistType
+	}
+	if len(o.Flags) > 0 {
+		opts["hls_flags"] = strings.Join(o.Flags, ",")
+	}
+	return opts
+}
+
+// NewHLSEncoder creates an Encoder that writes the playlist at playlistPath
+// and its media segments, encoding video with the given settings.
+func NewHLSEncoder(playlistPath string, hls HLSOptions, video *VideoEncoderConfig) (*Encoder, error) {
+	if video == nil {
+		return nil, errors.New("ffgo: video config is required")
+	}
+	return NewEncoderWithOptions(playlistPath, &EncoderOptions{
+		HLS:   &hls,
+		Video: video,
+	})
+}
+
 type DASHSegmenterConfig struct {
 	SegmentTime time.Duration
 	InitName    string
@@ -85,3 +157,117 @@ func (cfg *DASHSegmenterConfig) HeaderOptions() map[string]string {
 	return opts
 }
 
+// DASHOptions configures MPEG-DASH output from an Encoder (EncoderOptions.DASH).
+type DASHOptions struct {
+	// SegmentDuration is the target segment duration (seg_duration).
+	// Segments are cut on keyframes, so keep GOPSize at or below it. Zero
+	// uses FFmpeg's default (5s).
+	SegmentDuration time.Duration
+
+	// InitSegName and MediaSegName are the segment file name templates
+	// (init_seg_name, media_seg_name), relative to the manifest. With more
+	// than one stream they must contain $RepresentationID$ so the streams
+	// don't overwrite each other. Empty uses FFmpeg's defaults.
+	InitSegName  string
+	MediaSegName string
+
+	// NoTemplate lists segments individually in the manifest
+	// (SegmentList) instead of using a SegmentTemplate (use_template=0).
+	NoTemplate bool
+}
+
+func (o *DASHOptions) validate(streams int) error {
+	if o.SegmentDuration < 0 {
+		return errors.New("ffgo: DASHOptions.SegmentDuration must not be negative")
+	}
+	for _, name := range []struct{ field, value string }{
+		{"InitSegName", o.InitSegName},
+		{"MediaSegName", o.MediaSegName},
+	} {
+		if name.value == "" {
+			continue
+		}
+		if strings.ContainsAny(name.value, "/\\") {
+			return fmt.Errorf("ffgo: DASHOptions.%s must be a file name relative to the manifest", name.field)
+		}
+		if streams > 1 && !strings.Contains(name.value, "$RepresentationID$") {
+			return fmt.Errorf("ffgo: DASHOptions.%s must contain $RepresentationID$ when writing %d streams", name.field, streams)
+		}
+	}
+	return nil
+}
+
+// muxerOptions returns the dash muxer options for o.
+func (o *DASHOptions) muxerOptions() map[string]string {
+	opts := make(map[string]string, 4)
+	if o.SegmentDuration > 0 {
+		opts["seg_duration"] = strconv.FormatFloat(o.SegmentDuration.Seconds(), 'f', -1, 64)
+	}
+	if o.InitSegName != "" {
+		opts["init_seg_name"] = o.InitSegName
+	}
+	if o.MediaSegName != "" {
+		opts["media_seg_name"] = o.MediaSegName
+	}
+	if o.NoTemplate {
+		opts["use_template"] = "0"
+	}
+	return opts
+}
+
+// NewDASHEncoder creates an Encoder that writes the manifest at mpdPath and
+// its segments, encoding video and, if audio is non-nil, audio. Both streams
+// are set up before the header is written, so they share one manifest.
+func NewDASHEncoder(mpdPath string, opts DASHOptions, video *VideoEncoderConfig, audio *AudioEncoderConfig) (*Encoder, error) {
+	if video == nil {
+		return nil, errors.New("ffgo: video config is required")
+	}
+	return NewEncoderWithOptions(mpdPath, &EncoderOptions{
+		DASH:  &opts,
+		Video: video,
+		Audio: audio,
+	})
+}
+
+// segmentMuxerOptions returns the segment muxer options for writing
+// segmentDuration-long files named after pattern. The per-segment container
+// is taken from the pattern's extension.
+func segmentMuxerOptions(pattern string, segmentDuration time.Duration) (map[string]string, error) {
+	if segmentDuration <= 0 {
+		return nil, errors.New("ffgo: segment duration must be positive")
+	}
+	if !isImageSequencePattern(pattern) {
+		return nil, fmt.Errorf("ffgo: segment pattern %q must contain a %%d specifier", pattern)
+	}
+	format := formatFromExtension(pattern)
+	switch format {
+	case "", "image2", "hls", "dash":
+		return nil, fmt.Errorf("ffgo: cannot determine segment format from %q", pattern)
+	}
+	return map[string]string{
+		"segment_time":   strconv.FormatFloat(segmentDuration.Seconds(), 'f', -1, 64),
+		"segment_format": format,
+		// Start every file at zero so each one plays on its own.
+		"reset_timestamps": "1",
+	}, nil
+}
+
+// NewSegmentEncoder creates an Encoder that splits its output into files of
+// roughly segmentDuration each using FFmpeg's "segment" muxer. pattern is a
+// printf-style path such as "out/chunk_%03d.mp4"; its extension selects the
+// container of each file. Files are cut on keyframes, so keep GOPSize at or
+// below segmentDuration.
+func NewSegmentEncoder(pattern string, segmentDuration time.Duration, video *VideoEncoderConfig) (*Encoder, error) {
+	if video == nil {
+		return nil, errors.New("ffgo: video config is required")
+	}
+	muxerOpts, err := segmentMuxerOptions(pattern, segmentDuration)
+	if err != nil {
+		return nil, err
+	}
+	return NewEncoderWithOptions(pattern, &EncoderOptions{
+		Format:       "segment",
+		MuxerOptions: muxerOpts,
+		Video:        video,
+	})
+}
@@ -5,6 +5,7 @@ package ffgo
 import (
 	"os"
 	"path/filepath"
+	"strings"
 	"testing"
 	"time"
 
@@ -95,6 +96,305 @@ func TestHLSSegmenter_Integration(t *testing.T) {
 	}
 }
 
+func TestHLSOptionsMuxerOptions(t *testing.T) {
+	opts := &EncoderOptions{
+		HLS: &HLSOptions{
+			SegmentTime:     1500 * time.Millisecond,
+			ListSize:        3,
+			SegmentFilename: "seg_%03d.ts",
+			PlaylistType:    "vod",
+			Flags:           []string{"independent_segments", "program_date_time"},
+		},
+		MuxerOptions: map[string]string{"hls_list_size": "0"},
+	}
+	got := muxerHeaderOptions("hls", opts)
+	want := map[string]string{
+		"hls_time":             "1.5",
+		"hls_list_size":        "0", // MuxerOptions wins
+		"hls_segment_filename": "seg_%03d.ts",
+		"hls_playlist_type":    "vod",
+		"hls_flags":            "independent_segments,program_date_time",
+	}
+	if len(got) != len(want) {
+		t.Fatalf("got %v, want %v", got, want)
+	}
+	for k, v := range want {
+		if got[k] != v {
+			t.Errorf("%s = %q, want %q", k, got[k], v)
+		}
+	}
+
+	for _, bad := range []*EncoderOptions{
+		{Format: "mp4", HLS: &HLSOptions{}, Video: &VideoEncoderConfig{Width: 160, Height: 120}},
+		{HLS: &HLSOptions{SegmentTime: -time.Second}, Video: &VideoEncoderConfig{Width: 160, Height: 120}},
+		{HLS: &HLSOptions{PlaylistType: "live"}, Video: &VideoEncoderConfig{Width: 160, Height: 120}},
+	} {
+		if _, err := NewEncoderWithOptions("out.m3u8", bad); err == nil {
+			t.Errorf("NewEncoderWithOptions(%+v) succeeded, want error", bad.HLS)
+		}
+	}
+}
+
+func TestHLSEncoder_Integration(t *testing.T) {
+	if testing.Short() {
+		t.Log("Skipping HLS encoder integration test in short mode")
+		return
+	}
+	if !requireFFmpeg(t) {
+		return
+	}
+
+	tmpDir := t.TempDir()
+	playlist := filepath.Join(tmpDir, "out.m3u8")
+	enc, err := NewHLSEncoder(playlist, HLSOptions{
+		SegmentTime:     500 * time.Millisecond,
+		SegmentFilename: filepath.Join(tmpDir, "seg_%03d.ts"),
+		PlaylistType:    "vod",
+	}, &VideoEncoderConfig{
+		Width:     160,
+		Height:    120,
+		FrameRate: NewRational(25, 1),
+		GOPSize:   10,
+	})
+	if err != nil {
+		t.Logf("hls encoder not available: %v", err)
+		return
+	}
+
+	frame := FrameAlloc()
+	defer func() { _ = FrameFree(&frame) }()
+	AVUtil.SetFrameWidth(frame, 160)
+	AVUtil.SetFrameHeight(frame, 120)
+	AVUtil.SetFrameFormat(frame, int32(PixelFormatYUV420P))
+	if err := AVUtil.FrameGetBuffer(frame, 32); err != nil {
+		t.Fatalf("Failed to allocate frame buffer: %v", err)
+	}
+	for i := 0; i < 50; i++ {
+		if err := AVUtil.FrameMakeWritable(frame); err != nil {
+			t.Fatalf("FrameMakeWritable failed: %v", err)
+		}
+		fillTestFrameYUV420(frame, uint8(i*3))
+		if err := enc.WriteFrame(frame); err != nil {
+			t.Fatalf("WriteFrame failed: %v", err)
+		}
+	}
+	if err := enc.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+
+	data, err := os.ReadFile(playlist)
+	if err != nil {
+		t.Fatalf("playlist not found: %v", err)
+	}
+	segs, _ := filepath.Glob(filepath.Join(tmpDir, "seg_*.ts"))
+	if len(segs) < 2 {
+		t.Fatalf("expected at least 2 segments for 2s of video, got %v", segs)
+	}
+	for _, seg := range segs {
+		if !strings.Contains(string(data), filepath.Base(seg)) {
+			t.Errorf("playlist does not list %s:\n%s", filepath.Base(seg), data)
+		}
+	}
+}
+
+func TestDASHOptionsMuxerOptions(t *testing.T) {
+	opts := &EncoderOptions{
+		DASH: &DASHOptions{
+			SegmentDuration: 2 * time.Second,
+			InitSegName:     "init-$RepresentationID$.m4s",
+			MediaSegName:    "chunk-$RepresentationID$-$Number%05d$.m4s",
+			NoTemplate:      true,
+		},
+		MuxerOptions: map[string]string{"seg_duration": "4"},
+	}
+	got := muxerHeaderOptions("dash", opts)
+	want := map[string]string{
+		"seg_duration":   "4", // MuxerOptions wins
+		"init_seg_name":  "init-$RepresentationID$.m4s",
+		"media_seg_name": "chunk-$RepresentationID$-$Number%05d$.m4s",
+		"use_template":   "0",
+	}
+	if len(got) != len(want) {
+		t.Fatalf("got %v, want %v", got, want)
+	}
+	for k, v := range want {
+		if got[k] != v {
+			t.Errorf("%s = %q, want %q", k, got[k], v)
+		}
+	}
+
+	video := &VideoEncoderConfig{Width: 160, Height: 120}
+	audio := &AudioEncoderConfig{SampleRate: 48000, Channels: 2}
+	for _, bad := range []*EncoderOptions{
+		{Format: "mp4", DASH: &DASHOptions{}, Video: video},
+		{HLS: &HLSOptions{}, DASH: &DASHOptions{}, Video: video},
+		{DASH: &DASHOptions{SegmentDuration: -time.Second}, Video: video},
+		{DASH: &DASHOptions{InitSegName: "sub/init.m4s"}, Video: video},
+		{DASH: &DASHOptions{MediaSegName: "chunk-$Number$.m4s"}, Video: video, Audio: audio},
+	} {
+		if _, err := NewEncoderWithOptions("out.mpd", bad); err == nil {
+			t.Errorf("NewEncoderWithOptions(%+v) succeeded, want error", bad.DASH)
+		}
+	}
+	if _, err := NewDASHEncoder("out.mpd", DASHOptions{}, nil, audio); err == nil {
+		t.Error("NewDASHEncoder without video succeeded, want error")
+	}
+}
+
+func TestDASHEncoder_Integration(t *testing.T) {
+	if testing.Short() {
+		t.Log("Skipping DASH encoder integration test in short mode")
+		return
+	}
+	if !requireFFmpeg(t) {
+		return
+	}
+
+	tmpDir := t.TempDir()
+	mpd := filepath.Join(tmpDir, "out.mpd")
+	enc, err := NewDASHEncoder(mpd, DASHOptions{
+		SegmentDuration: 500 * time.Millisecond,
+		InitSegName:     "init-$RepresentationID$.m4s",
+		MediaSegName:    "chunk-$RepresentationID$-$Number%05d$.m4s",
+	}, &VideoEncoderConfig{
+		Width:     160,
+		Height:    120,
+		FrameRate: NewRational(25, 1),
+		GOPSize:   10,
+	}, &AudioEncoderConfig{
+		Codec:      CodecIDAAC,
+		SampleRate: 48000,
+		Channels:   2,
+	})
+	if err != nil {
+		t.Logf("dash encoder not available: %v", err)
+		return
+	}
+	if !enc.HasVideo() || !enc.HasAudio() {
+		t.Fatalf("HasVideo=%v HasAudio=%v, want both", enc.HasVideo(), enc.HasAudio())
+	}
+
+	frame := FrameAlloc()
+	defer func() { _ = FrameFree(&frame) }()
+	AVUtil.SetFrameWidth(frame, 160)
+	AVUtil.SetFrameHeight(frame, 120)
+	AVUtil.SetFrameFormat(frame, int32(PixelFormatYUV420P))
+	if err := AVUtil.FrameGetBuffer(frame, 32); err != nil {
+		t.Fatalf("Failed to allocate frame buffer: %v", err)
+	}
+	for i := 0; i < 50; i++ {
+		if err := AVUtil.FrameMakeWritable(frame); err != nil {
+			t.Fatalf("FrameMakeWritable failed: %v", err)
+		}
+		fillTestFrameYUV420(frame, uint8(i*3))
+		if err := enc.WriteFrame(frame); err != nil {
+			t.Fatalf("WriteFrame failed: %v", err)
+		}
+	}
+	if err := enc.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+
+	data, err := os.ReadFile(mpd)
+	if err != nil {
+		t.Fatalf("mpd not found: %v", err)
+	}
+	if n := strings.Count(string(data), "<AdaptationSet"); n != 2 {
+		t.Errorf("manifest has %d AdaptationSets, want 2:\n%s", n, data)
+	}
+	inits, _ := filepath.Glob(filepath.Join(tmpDir, "init-*.m4s"))
+	if len(inits) != 2 {
+		t.Errorf("expected one init segment per stream, got %v", inits)
+	}
+}
+
+func TestSegmentMuxerOptions(t *testing.T) {
+	got, err := segmentMuxerOptions("out/chunk_%03d.mp4", 500*time.Millisecond)
+	if err != nil {
+		t.Fatalf("segmentMuxerOptions failed: %v", err)
+	}
+	want := map[string]string{
+		"segment_time":     "0.5",
+		"segment_format":   "mp4",
+		"reset_timestamps": "1",
+	}
+	if len(got) != len(want) {
+		t.Fatalf("got %v, want %v", got, want)
+	}
+	for k, v := range want {
+		if got[k] != v {
+			t.Errorf("%s = %q, want %q", k, got[k], v)
+		}
+	}
+
+	for _, tc := range []struct {
+		pattern string
+		d       time.Duration
+	}{
+		{"chunk_%03d.mp4", 0},
+		{"chunk.mp4", time.Second},
+		{"chunk_%03d", time.Second},
+		{"chunk_%03d.png", time.Second},
+	} {
+		if _, err := segmentMuxerOptions(tc.pattern, tc.d); err == nil {
+			t.Errorf("segmentMuxerOptions(%q, %v) succeeded, want error", tc.pattern, tc.d)
+		}
+	}
+}
+
+func TestSegmentEncoder_Integration(t *testing.T) {
+	if testing.Short() {
+		t.Log("Skipping segment encoder integration test in short mode")
+		return
+	}
+	if !requireFFmpeg(t) {
+		return
+	}
+
+	tmpDir := t.TempDir()
+	enc, err := NewSegmentEncoder(filepath.Join(tmpDir, "chunk_%03d.ts"), 500*time.Millisecond, &VideoEncoderConfig{
+		Width:     160,
+		Height:    120,
+		FrameRate: NewRational(25, 1),
+		GOPSize:   5,
+	})
+	if err != nil {
+		t.Logf("segment encoder not available: %v", err)
+		return
+	}
+
+	frame := FrameAlloc()
+	defer func() { _ = FrameFree(&frame) }()
+	AVUtil.SetFrameWidth(frame, 160)
+	AVUtil.SetFrameHeight(frame, 120)
+	AVUtil.SetFrameFormat(frame, int32(PixelFormatYUV420P))
+	if err := AVUtil.FrameGetBuffer(frame, 32); err != nil {
+		t.Fatalf("Failed to allocate frame buffer: %v", err)
+	}
+	for i := 0; i < 25; i++ {
+		if err := AVUtil.FrameMakeWritable(frame); err != nil {
+			t.Fatalf("FrameMakeWritable failed: %v", err)
+		}
+		fillTestFrameYUV420(frame, uint8(i*3))
+		if err := enc.WriteFrame(frame); err != nil {
+			t.Fatalf("WriteFrame failed: %v", err)
+		}
+	}
+	if err := enc.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+
+	segs, _ := filepath.Glob(filepath.Join(tmpDir, "chunk_*.ts"))
+	if len(segs) < 2 {
+		t.Fatalf("expected at least 2 files for 1s of video in 0.5s segments, got %v", segs)
+	}
+	for _, seg := range segs {
+		if fi, err := os.Stat(seg); err != nil || fi.Size() == 0 {
+			t.Errorf("segment %s is missing or empty", seg)
+		}
+	}
+}
+
 func TestDASHSegmenter_Integration(t *testing.T) {
 	if testing.Short() {
 		t.Log("Skipping DASH segmenter integration test in short mode")
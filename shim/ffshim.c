@@ -14,10 +14,12 @@
 #include <libavutil/version.h>
 #include <libavutil/mem.h>
 #include <libavutil/frame.h>
+#include <libavutil/hwcontext.h>
 #include <libavcodec/avcodec.h>
 #include <libavformat/avformat.h>
 #include <libavformat/avio.h>
 
+#include <stdint.h>
 #include <stdio.h>
 #include <stdarg.h>
 #include <string.h>
@@ -26,12 +28,13 @@
  * LOGGING SUBSYSTEM
  * ============================================================================ */
 
-/* Global callback pointer - set by Go */
+/* Global callback pointers - set by Go; at most one is non-NULL */
 static ffshim_log_callback_t g_log_callback = NULL;
+static ffshim_log_component_callback_t g_log_component_callback = NULL;
 
 /* Internal callback that FFmpeg calls - formats the message then calls Go */
 static void internal_log_callback(void *avcl, int level, const char *fmt, va_list vl) {
-    if (g_log_callback == NULL) {
+    if (g_log_callback == NULL && g_log_component_callback == NULL) {
         return;
     }
 
@@ -43,11 +46,21 @@ static void internal_log_callback(void *avcl, int level, const char *fmt, va_lis
         buf[len-1] = '\0';
     }
 
+    if (g_log_component_callback != NULL) {
+        const char *component = NULL;
+        AVClass *cls = avcl ? *(AVClass **)avcl : NULL;
+        if (cls != NULL) {
+            component = cls->item_name ? cls->item_name(avcl) : cls->class_name;
+        }
+        g_log_component_callback(avcl, level, component, buf);
+        return;
+    }
     g_log_callback(avcl, level, buf);
 }
 
 /* Called by Go to set up logging */
 void ffshim_log_set_callback(ffshim_log_callback_t cb) {
+    g_log_component_callback = NULL;
     g_log_callback = cb;
     if (cb != NULL) {
         av_log_set_callback(internal_log_callback);
@@ -56,6 +69,17 @@ void ffshim_log_set_callback(ffshim_log_callback_t cb) {
     }
 }
 
+/* Called by Go to set up logging with component names */
+void ffshim_log_set_component_callback(ffshim_log_component_callback_t cb) {
+    g_log_callback = NULL;
+    g_log_component_callback = cb;
+    if (cb != NULL) {
+        av_log_set_callback(internal_log_callback);
+    } else {
+        av_log_set_callback(av_log_default_callback);
+    }
+}
+
 /* Called by Go to set log level */
 void ffshim_log_set_level(int level) {
     av_log_set_level(level);
@@ -333,6 +357,27 @@ int ffshim_avframe_color_offsets(
     return 0;
 }
 
+void* ffshim_avframe_metadata(void *frame) {
+    if (frame == NULL) {
+        return NULL;
+    }
+    return (void*)((AVFrame*)frame)->metadata;
+}
+
+int ffshim_avframe_timing_offsets(int *out_best_effort_timestamp, int *out_duration) {
+    if (out_best_effort_timestamp == NULL || out_duration == NULL) {
+        return -1;
+    }
+
+    *out_best_effort_timestamp = (int)offsetof(AVFrame, best_effort_timestamp);
+#if LIBAVUTIL_VERSION_MAJOR >= 58
+    *out_duration = (int)offsetof(AVFrame, duration);
+#else
+    *out_duration = (int)offsetof(AVFrame, pkt_duration);
+#endif
+    return 0;
+}
+
 /* ============================================================================
  * CODEC FIELD HELPERS (OPTIONAL)
  * ============================================================================ */
@@ -458,6 +503,21 @@ void ffshim_codecctx_set_framerate(void *ctx, int num, int den) {
     ((AVCodecContext*)ctx)->framerate = (AVRational){num, den};
 }
 
+void ffshim_codecctx_sample_aspect_ratio(void *ctx, int *out_num, int *out_den) {
+    if (ctx == NULL || out_num == NULL || out_den == NULL) {
+        return;
+    }
+    *out_num = ((AVCodecContext*)ctx)->sample_aspect_ratio.num;
+    *out_den = ((AVCodecContext*)ctx)->sample_aspect_ratio.den;
+}
+
+void ffshim_codecctx_set_sample_aspect_ratio(void *ctx, int num, int den) {
+    if (ctx == NULL) {
+        return;
+    }
+    ((AVCodecContext*)ctx)->sample_aspect_ratio = (AVRational){num, den};
+}
+
 void ffshim_codecctx_set_ch_layout_default(void *ctx, int nb_channels) {
 #if LIBAVUTIL_VERSION_MAJOR >= 57
     if (ctx == NULL) {
@@ -499,6 +559,53 @@ void ffshim_codecctx_set_hw_frames_ctx(void *ctx, void *ref) {
     ((AVCodecContext*)ctx)->hw_frames_ctx = (AVBufferRef*)ref;
 }
 
+/* get_format callback for hardware decoding. The wanted hardware pixel
+ * format is stored in ctx->opaque by ffshim_codecctx_set_hw_get_format. */
+static enum AVPixelFormat ffshim_hw_get_format(AVCodecContext *ctx, const enum AVPixelFormat *fmts) {
+    enum AVPixelFormat want = (enum AVPixelFormat)(intptr_t)ctx->opaque;
+    const enum AVPixelFormat *p;
+    for (p = fmts; *p != AV_PIX_FMT_NONE; p++) {
+        if (*p == want) {
+            return *p;
+        }
+    }
+    return AV_PIX_FMT_NONE;
+}
+
+void ffshim_codecctx_set_hw_get_format(void *ctx, int hw_pix_fmt) {
+    if (ctx == NULL) {
+        return;
+    }
+    AVCodecContext *c = (AVCodecContext*)ctx;
+    c->opaque = (void*)(intptr_t)hw_pix_fmt;
+    c->get_format = ffshim_hw_get_format;
+}
+
+void ffshim_hwframes_ctx_set_params(void *ref, int format, int sw_format, int width, int height, int initial_pool_size) {
+    if (ref == NULL) {
+        return;
+    }
+    AVHWFramesContext *fc = (AVHWFramesContext*)((AVBufferRef*)ref)->data;
+    fc->format = format;
+    fc->sw_format = sw_format;
+    fc->width = width;
+    fc->height = height;
+    fc->initial_pool_size = initial_pool_size;
+}
+
+void* ffshim_hwframes_ctx_params(void *ref, int *format, int *sw_format, int *width, int *height, int *initial_pool_size) {
+    if (ref == NULL) {
+        return NULL;
+    }
+    AVHWFramesContext *fc = (AVHWFramesContext*)((AVBufferRef*)ref)->data;
+    if (format) *format = fc->format;
+    if (sw_format) *sw_format = fc->sw_format;
+    if (width) *width = fc->width;
+    if (height) *height = fc->height;
+    if (initial_pool_size) *initial_pool_size = fc->initial_pool_size;
+    return (void*)fc->device_ctx;
+}
+
 /* ============================================================================
  * FORMAT FIELD HELPERS (OPTIONAL)
  * ============================================================================ */
@@ -559,6 +666,51 @@ void* ffshim_formatctx_program(void *ctx, int index) {
     return (void*)fc->programs[index];
 }
 
+static int ffshim_interrupt_flag_cb(void *opaque) {
+    return opaque != NULL && *(volatile int*)opaque != 0;
+}
+
+void ffshim_formatctx_set_interrupt_flag(void *ctx, int *flag) {
+    if (ctx == NULL) {
+        return;
+    }
+    AVFormatContext *fc = (AVFormatContext*)ctx;
+    if (flag == NULL) {
+        fc->interrupt_callback.callback = NULL;
+        fc->interrupt_callback.opaque = NULL;
+        return;
+    }
+    fc->interrupt_callback.callback = ffshim_interrupt_flag_cb;
+    fc->interrupt_callback.opaque = flag;
+}
+
+const int32_t* ffshim_stream_display_matrix(void *stream) {
+    if (stream == NULL) {
+        return NULL;
+    }
+    AVStream *st = (AVStream*)stream;
+#if LIBAVCODEC_VERSION_INT >= AV_VERSION_INT(60, 30, 100)
+    const AVPacketSideData *sd = av_packet_side_data_get(st->codecpar->coded_side_data,
+                                                         st->codecpar->nb_coded_side_data,
+                                                         AV_PKT_DATA_DISPLAYMATRIX);
+    if (sd == NULL || sd->size < 9 * sizeof(int32_t)) {
+        return NULL;
+    }
+    return (const int32_t*)sd->data;
+#else
+#if LIBAVFORMAT_VERSION_MAJOR >= 59
+    size_t size = 0;
+#else
+    int size = 0;
+#endif
+    const uint8_t *data = av_stream_get_side_data(st, AV_PKT_DATA_DISPLAYMATRIX, &size);
+    if (data == NULL || (size_t)size < 9 * sizeof(int32_t)) {
+        return NULL;
+    }
+    return (const int32_t*)data;
+#endif
+}
+
 int64_t ffshim_chapter_id(void *ch) {
     if (ch == NULL) {
         return 0;
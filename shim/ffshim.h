@@ -20,6 +20,10 @@ extern "C" {
 /* Callback type that Go can implement (no va_list) */
 typedef void (*ffshim_log_callback_t)(void *avcl, int level, const char *msg);
 
+/* Like ffshim_log_callback_t, plus the logging context's item name
+ * (e.g. "h264", "mov,mp4,m4a,3gp,3g2,mj2"), or NULL without a context. */
+typedef void (*ffshim_log_component_callback_t)(void *avcl, int level, const char *component, const char *msg);
+
 /* ============================================================================
  * LOGGING SUBSYSTEM
  * ============================================================================ */
@@ -27,6 +31,11 @@ typedef void (*ffshim_log_callback_t)(void *avcl, int level, const char *msg);
 /* Set a custom log callback. Pass NULL to restore default. */
 void ffshim_log_set_callback(ffshim_log_callback_t cb);
 
+/* Set a custom log callback that also receives the component name.
+ * Replaces any callback set with ffshim_log_set_callback. Pass NULL to
+ * restore default. */
+void ffshim_log_set_component_callback(ffshim_log_component_callback_t cb);
+
 /* Set the log level */
 void ffshim_log_set_level(int level);
 
@@ -115,6 +124,17 @@ int ffshim_avframe_color_offsets(
     int *out_color_trc
 );
 
+/* Returns the AVFrame's metadata dictionary (may be NULL). */
+void* ffshim_avframe_metadata(void *frame);
+
+/*
+ * Returns offsets (in bytes) for the AVFrame best_effort_timestamp and
+ * duration (pkt_duration before FFmpeg 6) fields.
+ *
+ * Returns 0 on success, -1 on failure.
+ */
+int ffshim_avframe_timing_offsets(int *out_best_effort_timestamp, int *out_duration);
+
 /* ============================================================================
  * CODEC FIELD HELPERS (OPTIONAL)
  * ============================================================================ */
@@ -139,12 +159,23 @@ void ffshim_codecctx_time_base(void *ctx, int *out_num, int *out_den);
 void ffshim_codecctx_set_time_base(void *ctx, int num, int den);
 void ffshim_codecctx_framerate(void *ctx, int *out_num, int *out_den);
 void ffshim_codecctx_set_framerate(void *ctx, int num, int den);
+void ffshim_codecctx_sample_aspect_ratio(void *ctx, int *out_num, int *out_den);
+void ffshim_codecctx_set_sample_aspect_ratio(void *ctx, int num, int den);
 void ffshim_codecctx_set_ch_layout_default(void *ctx, int nb_channels);
 void* ffshim_codecctx_hw_device_ctx(void *ctx);
 void ffshim_codecctx_set_hw_device_ctx(void *ctx, void *ref);
 void* ffshim_codecctx_hw_frames_ctx(void *ctx);
 void ffshim_codecctx_set_hw_frames_ctx(void *ctx, void *ref);
 
+/* Installs a get_format callback that selects hw_pix_fmt (and fails the
+ * decoder if it is not offered). Uses AVCodecContext.opaque. */
+void ffshim_codecctx_set_hw_get_format(void *ctx, int hw_pix_fmt);
+
+/* AVHWFramesContext setup (ref is the AVBufferRef from av_hwframe_ctx_alloc) */
+void ffshim_hwframes_ctx_set_params(void *ref, int format, int sw_format, int width, int height, int initial_pool_size);
+/* Reads the same fields back; returns the frames context's AVHWDeviceContext* */
+void* ffshim_hwframes_ctx_params(void *ref, int *format, int *sw_format, int *width, int *height, int *initial_pool_size);
+
 /* ============================================================================
  * FORMAT FIELD HELPERS (OPTIONAL)
  * ============================================================================ */
@@ -157,6 +188,19 @@ void* ffshim_formatctx_chapter(void *ctx, int index);
 unsigned int ffshim_formatctx_nb_programs(void *ctx);
 void* ffshim_formatctx_program(void *ctx, int index);
 
+/*
+ * Installs an AVIOInterruptCB on the format context that aborts blocking I/O
+ * once *flag is non-zero. The flag must stay valid until the callback is
+ * cleared (flag == NULL) or the context is closed.
+ */
+void ffshim_formatctx_set_interrupt_flag(void *ctx, int *flag);
+
+/*
+ * Returns the stream's AV_PKT_DATA_DISPLAYMATRIX side data (nine 16.16/2.30
+ * fixed-point values), or NULL if the stream has none.
+ */
+const int32_t* ffshim_stream_display_matrix(void *stream);
+
 /* AVChapter field accessors */
 int64_t ffshim_chapter_id(void *ch);
 void ffshim_chapter_time_base(void *ch, int *out_num, int *out_den);
@@ -4,9 +4,13 @@ package ffgo
 
 import (
 	"errors"
+	"fmt"
 	"net/url"
 	"strings"
 	"time"
+
+	"github.com/obinnaokechukwu/ffgo/avcodec"
+	"github.com/obinnaokechukwu/ffgo/avformat"
 )
 
 // EncoderOption configures EncoderOptions using the functional options pattern.
@@ -58,6 +62,106 @@ type StreamingOptions struct {
 
 	// MuxerOptions are options passed to avformat_write_header (muxer-specific).
 	MuxerOptions map[string]string
+
+	// Reconnect makes the encoder recover from output write errors, e.g. a
+	// dropped RTMP connection: the output is torn down, re-opened and the
+	// header re-written, and encoding resumes with a keyframe. The packet
+	// that failed is dropped. Attempts back off exponentially from 1s up to
+	// ReconnectDelay (default 30s). Only encoding (not stream copy) outputs
+	// reconnect.
+	Reconnect bool
+
+	// MaxReconnectAttempts limits consecutive reconnection attempts per
+	// write error; the write then fails with the last error. Zero retries
+	// indefinitely.
+	MaxReconnectAttempts int
+
+	// OnReconnect, if set, is called before each reconnection attempt with
+	// the 1-based attempt number and the error that caused it. It runs with
+	// the encoder locked and must not call the encoder.
+	OnReconnect func(attempt int, err error)
+
+	// Realtime paces video output to wall-clock time based on frame PTS,
+	// so frames read from a file are emitted like a live source instead of
+	// bursting at the server. WriteVideoFrame blocks as needed; outputs
+	// without video are paced by WriteAudioFrame instead.
+	Realtime bool
+
+	// MaxAheadBuffer is how far output may run ahead of wall-clock time
+	// before Realtime pacing blocks, absorbing jitter. Default 0.
+	MaxAheadBuffer time.Duration
+
+	// SRT configures srt:// outputs; see SRTOptions.
+	SRT *SRTOptions
+}
+
+// SRTOptions configures the SRT protocol for srt:// outputs. SRT support
+// requires FFmpeg built with libsrt (--enable-libsrt); NewStreamingEncoder
+// reports an error when the loaded libraries lack it.
+type SRTOptions struct {
+	// Latency is the receiver buffer delay used to recover lost packets.
+	// Zero uses the libsrt default (120ms).
+	Latency time.Duration
+
+	// Passphrase enables AES encryption. It must be 10 to 79 characters.
+	Passphrase string
+
+	// PBKeyLen is the encryption key length in bytes: 16, 24 or 32.
+	// Zero uses the default (16) when a Passphrase is set.
+	PBKeyLen int
+
+	// StreamID identifies the stream to the receiver, e.g.
+	// "#!::r=live/feed,m=publish". At most 512 characters.
+	StreamID string
+}
+
+// ioOptions validates o and returns the matching srt protocol options.
+func (o *SRTOptions) ioOptions() (map[string]string, error) {
+	out := make(map[string]string)
+	if o.Latency < 0 {
+		return nil, errors.New("ffgo: srt latency cannot be negative")
+	}
+	if o.Latency > 0 {
+		out["latency"] = int64ToString(o.Latency.Microseconds())
+	}
+	if o.Passphrase != "" {
+		if n := len(o.Passphrase); n < 10 || n > 79 {
+			return nil, fmt.Errorf("ffgo: srt passphrase must be 10 to 79 characters, got %d", n)
+		}
+		out["passphrase"] = o.Passphrase
+	}
+	switch o.PBKeyLen {
+	case 0:
+	case 16, 24, 32:
+		if o.Passphrase == "" {
+			return nil, errors.New("ffgo: srt pbkeylen requires a passphrase")
+		}
+		out["pbkeylen"] = int64ToString(int64(o.PBKeyLen))
+	default:
+		return nil, fmt.Errorf("ffgo: srt pbkeylen must be 16, 24 or 32, got %d", o.PBKeyLen)
+	}
+	if o.StreamID != "" {
+		if len(o.StreamID) > 512 {
+			return nil, errors.New("ffgo: srt streamid is longer than 512 characters")
+		}
+		out["streamid"] = o.StreamID
+	}
+	return out, nil
+}
+
+// protocolAvailable reports whether the loaded libavformat can write to
+// protocol name. It assumes it can if the protocol list is unavailable.
+func protocolAvailable(name string) bool {
+	names := avformat.ProtocolNames(true)
+	if names == nil {
+		return true
+	}
+	for _, n := range names {
+		if n == name {
+			return true
+		}
+	}
+	return false
 }
 
 // WithStreamingOptions applies streaming protocol/muxer options.
@@ -98,9 +202,149 @@ func WithStreamingOptions(s *StreamingOptions) EncoderOption {
 		if s.MaxDelay > 0 {
 			o.IOOptions["max_delay"] = int64ToString(s.MaxDelay.Microseconds())
 		}
+		if s.SRT != nil {
+			o.srt = s.SRT
+		}
+		if s.Realtime {
+			o.realtime = &realtimePacer{maxAhead: s.MaxAheadBuffer}
+		}
+		if s.Reconnect {
+			o.reconnect = &reconnectPolicy{
+				maxAttempts: s.MaxReconnectAttempts,
+				maxDelay:    s.ReconnectDelay,
+				onReconnect: s.OnReconnect,
+			}
+		}
 	}
 }
 
+// realtimePacer throttles output to wall-clock time. The first paced
+// timestamp is anchored to the time it is written.
+type realtimePacer struct {
+	maxAhead time.Duration
+
+	started bool
+	start   time.Time
+	base    time.Duration
+}
+
+// realtimeNow and realtimeSleep are the pacer's clock; replaced in tests.
+var (
+	realtimeNow   = time.Now
+	realtimeSleep = time.Sleep
+)
+
+// wait blocks until the stream position pos is no more than maxAhead
+// ahead of the wall-clock time elapsed since the first call.
+func (p *realtimePacer) wait(pos time.Duration) {
+	now := realtimeNow()
+	if !p.started {
+		p.started = true
+		p.start = now
+		p.base = pos
+		return
+	}
+	target := p.start.Add(pos - p.base - p.maxAhead)
+	if d := target.Sub(now); d > 0 {
+		realtimeSleep(d)
+	}
+}
+
+// reconnectPolicy controls how an Encoder recovers from output write errors.
+type reconnectPolicy struct {
+	maxAttempts int
+	maxDelay    time.Duration
+	onReconnect func(attempt int, err error)
+}
+
+// reconnectSleep waits between reconnection attempts; replaced in tests.
+var reconnectSleep = time.Sleep
+
+// backoff returns the delay before the given 1-based attempt.
+func (p *reconnectPolicy) backoff(attempt int) time.Duration {
+	max := p.maxDelay
+	if max <= 0 {
+		max = 30 * time.Second
+	}
+	d := time.Second
+	for i := 1; i < attempt && d < max; i++ {
+		d *= 2
+	}
+	if d > max {
+		d = max
+	}
+	return d
+}
+
+// reconnectLocked handles an output write error. Without a reconnect
+// policy it returns cause. Otherwise it re-opens the output until it
+// succeeds or the attempts are exhausted.
+func (e *Encoder) reconnectLocked(cause error) error {
+	p := e.reconnect
+	if p == nil || e.copyVideo || e.copyAudio || e.customIO != nil {
+		return cause
+	}
+
+	err := cause
+	for attempt := 1; p.maxAttempts <= 0 || attempt <= p.maxAttempts; attempt++ {
+		if p.onReconnect != nil {
+			p.onReconnect(attempt, err)
+		}
+		reconnectSleep(p.backoff(attempt))
+		if err = e.reopenOutputLocked(); err == nil {
+			// Decoders joining the new connection need a keyframe.
+			e.forceKey = true
+			return nil
+		}
+	}
+	return fmt.Errorf("ffgo: reconnect failed after %d attempts: %w", p.maxAttempts, err)
+}
+
+// reopenOutputLocked replaces the muxer and its I/O with fresh ones for the
+// same output and writes the header again. The codec contexts are kept, so
+// encoding continues where it left off.
+func (e *Encoder) reopenOutputLocked() error {
+	if e.ioCtx != nil {
+		_ = avformat.IOCloseP(&e.ioCtx)
+	}
+	if e.formatCtx != nil {
+		e.logCapture.unwatch(e.formatCtx)
+		avformat.FreeContext(e.formatCtx)
+		e.formatCtx = nil
+	}
+	e.headerWritten = false
+
+	if err := avformat.AllocOutputContext2(&e.formatCtx, nil, e.formatName, e.path); err != nil {
+		return err
+	}
+	e.logCapture.watch(e.formatCtx)
+	if e.videoCodecCtx != nil {
+		st := avformat.NewStream(e.formatCtx, nil)
+		if st == nil {
+			return errors.New("ffgo: failed to create stream")
+		}
+		if err := avcodec.ParametersFromContext(avformat.GetStreamCodecPar(st), e.videoCodecCtx); err != nil {
+			return err
+		}
+		avformat.SetStreamTimeBase(st, e.timeBaseNum, e.timeBaseDen)
+		e.videoStream = st
+		e.stream = st
+	}
+	if e.audioCodecCtx != nil {
+		st := avformat.NewStream(e.formatCtx, nil)
+		if st == nil {
+			return errors.New("ffgo: failed to create audio stream")
+		}
+		if err := avcodec.ParametersFromContext(avformat.GetStreamCodecPar(st), e.audioCodecCtx); err != nil {
+			return err
+		}
+		tb := avcodec.GetCtxTimeBase(e.audioCodecCtx)
+		avformat.SetStreamTimeBase(st, tb.Num, tb.Den)
+		e.audioStream = st
+	}
+	return e.writeHeaderLocked()
+}
+
 // NewStreamingEncoder creates an encoder configured for network streaming outputs (RTMP/UDP/RTP/etc).
 //
 // ffgo selects a sensible default muxer based on the URL scheme:
@@ -110,6 +354,14 @@ func WithStreamingOptions(s *StreamingOptions) EncoderOption {
 //   - rtsp       -> rtsp
 //
 // You can override the muxer via WithEncoderFormat.
+//
+// srt:// outputs take their protocol settings from StreamingOptions.SRT and
+// fail with an error if FFmpeg was built without libsrt.
+//
+// Add an audio stream with WithAudioEncoder and write it with
+// WriteAudioFrame; packets from both streams are interleaved by timestamp.
+// When restreaming decoded input, prefer WriteFrameWithPTS and
+// WriteAudioFrameWithPTS so both streams keep the source timing.
 func NewStreamingEncoder(outURL string, options ...EncoderOption) (*Encoder, error) {
 	if strings.TrimSpace(outURL) == "" {
 		return nil, errors.New("ffgo: output url cannot be empty")
@@ -144,6 +396,23 @@ func NewStreamingEncoder(outURL string, options ...EncoderOption) (*Encoder, err
 		encOpts.IOOptions = map[string]string{}
 	}
 
+	if strings.EqualFold(u.Scheme, "srt") {
+		if !protocolAvailable("srt") {
+			return nil, errors.New("ffgo: srt protocol is not available in the loaded FFmpeg libraries (requires --enable-libsrt)")
+		}
+		if encOpts.srt != nil {
+			srtOpts, err := encOpts.srt.ioOptions()
+			if err != nil {
+				return nil, err
+			}
+			for k, v := range srtOpts {
+				encOpts.IOOptions[k] = v
+			}
+		}
+	} else if encOpts.srt != nil {
+		return nil, errors.New("ffgo: SRT options require an srt:// url")
+	}
+
 	return NewEncoderWithOptions(outURL, encOpts)
 }
 
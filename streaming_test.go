@@ -3,8 +3,14 @@
 package ffgo
 
 import (
+	"errors"
+	"os/exec"
+	"path/filepath"
 	"testing"
 	"time"
+	"unsafe"
+
+	"github.com/obinnaokechukwu/ffgo/avutil"
 )
 
 func TestNewStreamingEncoder_URLMappingAndLazyIO(t *testing.T) {
@@ -69,3 +75,358 @@ func TestWithStreamingOptions_SetsIOOptions(t *testing.T) {
 		t.Fatalf("expected buffer_size=12345, got %q", opts.IOOptions["buffer_size"])
 	}
 }
+
+func TestWithStreamingOptions_Reconnect(t *testing.T) {
+	opts := &EncoderOptions{}
+	WithStreamingOptions(&StreamingOptions{ReconnectCount: 1})(opts)
+	if opts.reconnect != nil {
+		t.Fatalf("expected no reconnect policy without Reconnect")
+	}
+
+	called := false
+	WithStreamingOptions(&StreamingOptions{
+		Reconnect:            true,
+		MaxReconnectAttempts: 3,
+		ReconnectDelay:       5 * time.Second,
+		OnReconnect:          func(int, error) { called = true },
+	})(opts)
+	p := opts.reconnect
+	if p == nil {
+		t.Fatalf("expected reconnect policy")
+	}
+	if p.maxAttempts != 3 {
+		t.Fatalf("maxAttempts = %d, want 3", p.maxAttempts)
+	}
+	p.onReconnect(1, nil)
+	if !called {
+		t.Fatalf("expected OnReconnect to be wired")
+	}
+
+	want := []time.Duration{time.Second, 2 * time.Second, 4 * time.Second, 5 * time.Second, 5 * time.Second}
+	for i, w := range want {
+		if got := p.backoff(i + 1); got != w {
+			t.Fatalf("backoff(%d) = %v, want %v", i+1, got, w)
+		}
+	}
+}
+
+func TestEncoderReconnect_GivesUp(t *testing.T) {
+	if !requireFFmpeg(t) {
+		return
+	}
+
+	oldSleep := reconnectSleep
+	reconnectSleep = func(time.Duration) {}
+	defer func() { reconnectSleep = oldSleep }()
+
+	var attempts []int
+	enc, err := NewStreamingEncoder("rtmp://127.0.0.1:1/live/test",
+		WithVideoEncoder(&VideoEncoderConfig{
+			Codec:       CodecIDH264,
+			Width:       160,
+			Height:      120,
+			FrameRate:   NewRational(30, 1),
+			PixelFormat: PixelFormatYUV420P,
+		}),
+		WithStreamingOptions(&StreamingOptions{
+			Timeout:              time.Second,
+			Reconnect:            true,
+			MaxReconnectAttempts: 2,
+			OnReconnect:          func(attempt int, err error) { attempts = append(attempts, attempt) },
+		}),
+	)
+	if err != nil {
+		t.Skipf("NewStreamingEncoder unavailable: %v", err)
+	}
+	defer enc.Close()
+
+	enc.mu.Lock()
+	err = enc.reconnectLocked(errors.New("broken pipe"))
+	enc.mu.Unlock()
+	if err == nil {
+		t.Fatalf("expected reconnect to fail against a closed port")
+	}
+	if len(attempts) != 2 || attempts[0] != 1 || attempts[1] != 2 {
+		t.Fatalf("OnReconnect attempts = %v, want [1 2]", attempts)
+	}
+}
+
+func TestRealtimePacer(t *testing.T) {
+	oldNow, oldSleep := realtimeNow, realtimeSleep
+	defer func() { realtimeNow, realtimeSleep = oldNow, oldSleep }()
+
+	clock := time.Unix(1000, 0)
+	var slept []time.Duration
+	realtimeNow = func() time.Time { return clock }
+	realtimeSleep = func(d time.Duration) {
+		slept = append(slept, d)
+		clock = clock.Add(d)
+	}
+
+	opts := &EncoderOptions{}
+	WithStreamingOptions(&StreamingOptions{Realtime: true, MaxAheadBuffer: 100 * time.Millisecond})(opts)
+	if opts.realtime == nil {
+		t.Fatalf("expected Realtime to set a pacer")
+	}
+	p := &realtimePacer{maxAhead: opts.realtime.maxAhead}
+
+	// First frame anchors the clock at its (non-zero) timestamp.
+	p.wait(10 * time.Second)
+	// Within the ahead buffer: no sleep.
+	p.wait(10*time.Second + 100*time.Millisecond)
+	if len(slept) != 0 {
+		t.Fatalf("unexpected sleeps %v", slept)
+	}
+	// One second ahead: sleep until only the buffer remains.
+	p.wait(11 * time.Second)
+	if len(slept) != 1 || slept[0] != 900*time.Millisecond {
+		t.Fatalf("slept %v, want [900ms]", slept)
+	}
+	// Late frames are not delayed.
+	clock = clock.Add(5 * time.Second)
+	p.wait(12 * time.Second)
+	if len(slept) != 1 {
+		t.Fatalf("late frame slept: %v", slept)
+	}
+}
+
+func TestStreamingEncoder_AudioAndVideo(t *testing.T) {
+	if !requireFFmpeg(t) {
+		return
+	}
+
+	outPath := filepath.Join(t.TempDir(), "out.flv")
+	enc, err := NewStreamingEncoder("file:"+outPath,
+		WithEncoderFormat("flv"),
+		WithVideoEncoder(&VideoEncoderConfig{
+			Codec:       CodecIDH264,
+			Width:       160,
+			Height:      120,
+			FrameRate:   NewRational(25, 1),
+			PixelFormat: PixelFormatYUV420P,
+		}),
+		WithAudioEncoder(&AudioEncoderConfig{
+			Codec:      CodecIDAAC,
+			SampleRate: 48000,
+			Channels:   2,
+		}),
+	)
+	if err != nil {
+		t.Fatalf("NewStreamingEncoder failed: %v", err)
+	}
+	defer enc.Close()
+
+	video, err := newVideoFrame(160, 120, PixelFormatYUV420P)
+	if err != nil {
+		t.Fatalf("newVideoFrame failed: %v", err)
+	}
+	defer video.Free()
+
+	frameSize := enc.AudioFrameSize()
+	if frameSize <= 0 {
+		frameSize = 1024
+	}
+	audio, err := newAudioFrame(AudioFormat{SampleRate: 48000, Channels: 2, SampleFormat: SampleFormatFLTP}, frameSize)
+	if err != nil {
+		t.Fatalf("newAudioFrame failed: %v", err)
+	}
+	defer audio.Free()
+	for ch := 0; ch < 2; ch++ {
+		plane := unsafe.Slice((*float32)(avutil.GetFrameDataPlane(audio.ptr, ch)), frameSize)
+		clear(plane)
+	}
+
+	// One second of each, timestamped in milliseconds like a demuxed source.
+	ms := NewRational(1, 1000)
+	for i := 0; i < 25; i++ {
+		if err := enc.WriteFrameWithPTS(video, int64(i*40), ms); err != nil {
+			t.Fatalf("WriteFrameWithPTS failed: %v", err)
+		}
+	}
+	for pts := 0; pts < 1000; pts += frameSize * 1000 / 48000 {
+		if err := enc.WriteAudioFrameWithPTS(audio, int64(pts), ms); err != nil {
+			t.Fatalf("WriteAudioFrameWithPTS failed: %v", err)
+		}
+	}
+	if err := enc.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+
+	dec, err := NewDecoder(outPath)
+	if err != nil {
+		t.Fatalf("NewDecoder failed: %v", err)
+	}
+	defer dec.Close()
+	if !dec.HasVideo() || !dec.HasAudio() {
+		t.Fatalf("expected audio and video streams, got video=%v audio=%v", dec.HasVideo(), dec.HasAudio())
+	}
+}
+
+func TestStreamFromDecoder(t *testing.T) {
+	if !requireFFmpeg(t) {
+		return
+	}
+
+	tmpDir := t.TempDir()
+	srcPath := filepath.Join(tmpDir, "src.mp4")
+	cmd := exec.Command("ffmpeg", "-y",
+		"-f", "lavfi", "-i", "testsrc=duration=1:size=160x120:rate=10",
+		"-f", "lavfi", "-i", "sine=frequency=440:duration=1:sample_rate=44100",
+		"-pix_fmt", "yuv420p", "-c:a", "aac", "-shortest", srcPath)
+	if err := cmd.Run(); err != nil {
+		t.Skipf("ffmpeg CLI not available: %v", err)
+	}
+
+	dec, err := NewDecoder(srcPath)
+	if err != nil {
+		t.Fatalf("NewDecoder failed: %v", err)
+	}
+	defer dec.Close()
+
+	outPath := filepath.Join(tmpDir, "out.flv")
+	err = StreamFromDecoder(dec, "file:"+outPath,
+		&VideoEncoderConfig{Codec: CodecIDH264, Width: 96, Height: 64},
+		&AudioEncoderConfig{Codec: CodecIDAAC, SampleRate: 48000},
+		nil,
+	)
+	if err != nil {
+		t.Fatalf("StreamFromDecoder failed: %v", err)
+	}
+
+	out, err := NewDecoder(outPath)
+	if err != nil {
+		t.Fatalf("NewDecoder(output) failed: %v", err)
+	}
+	defer out.Close()
+	if v := out.VideoStream(); v == nil || v.Width != 96 || v.Height != 64 {
+		t.Fatalf("unexpected output video stream: %+v", v)
+	}
+	if a := out.AudioStream(); a == nil || a.SampleRate != 48000 {
+		t.Fatalf("unexpected output audio stream: %+v", a)
+	}
+}
+
+// firstPacketTimes returns the timestamps of the first video and audio
+// packets of path.
+func firstPacketTimes(t *testing.T, path string) (video, audio time.Duration) {
+	t.Helper()
+	dec, err := NewDecoder(path)
+	if err != nil {
+		t.Fatalf("NewDecoder(%s) failed: %v", path, err)
+	}
+	defer dec.Close()
+
+	v, a := dec.VideoStream(), dec.AudioStream()
+	if v == nil || a == nil {
+		t.Fatalf("%s: expected video and audio streams", path)
+	}
+	haveVideo, haveAudio := false, false
+	for !haveVideo || !haveAudio {
+		pkt, err := dec.ReadPacket()
+		if err != nil || pkt == nil {
+			t.Fatalf("%s: no packet for each stream: %v", path, err)
+		}
+		switch pts := pkt.PTS(); {
+		case pts == avutil.AV_NOPTS_VALUE:
+		case pkt.StreamIndex() == v.Index && !haveVideo:
+			video, haveVideo = ptsToDuration(pts, v.TimeBase), true
+		case pkt.StreamIndex() == a.Index && !haveAudio:
+			audio, haveAudio = ptsToDuration(pts, a.TimeBase), true
+		}
+	}
+	return video, audio
+}
+
+func TestStreamFromDecoder_KeepsAVOffset(t *testing.T) {
+	if !requireFFmpeg(t) {
+		return
+	}
+
+	// MPEG-TS starts at 1.4s; audio starts another 0.5s after video.
+	tmpDir := t.TempDir()
+	srcPath := filepath.Join(tmpDir, "src.ts")
+	cmd := exec.Command("ffmpeg", "-y",
+		"-f", "lavfi", "-i", "testsrc=duration=2:size=160x120:rate=10",
+		"-itsoffset", "0.5",
+		"-f", "lavfi", "-i", "sine=frequency=440:duration=1.5:sample_rate=44100",
+		"-pix_fmt", "yuv420p", "-c:v", "mpeg2video", "-c:a", "mp2", srcPath)
+	if err := cmd.Run(); err != nil {
+		t.Skipf("ffmpeg CLI not available: %v", err)
+	}
+
+	dec, err := NewDecoder(srcPath)
+	if err != nil {
+		t.Fatalf("NewDecoder failed: %v", err)
+	}
+	defer dec.Close()
+
+	outPath := filepath.Join(tmpDir, "out.flv")
+	err = StreamFromDecoder(dec, "file:"+outPath,
+		&VideoEncoderConfig{Codec: CodecIDH264},
+		&AudioEncoderConfig{Codec: CodecIDAAC},
+		nil,
+	)
+	if err != nil {
+		t.Fatalf("StreamFromDecoder failed: %v", err)
+	}
+
+	video, audio := firstPacketTimes(t, outPath)
+	if video > 50*time.Millisecond {
+		t.Errorf("video starts at %v, want 0", video)
+	}
+	if off := audio - video; off < 400*time.Millisecond || off > 600*time.Millisecond {
+		t.Errorf("audio starts %v after video, want about 500ms", off)
+	}
+}
+
+func TestStreamFromDecoder_Validation(t *testing.T) {
+	if err := StreamFromDecoder(nil, "rtmp://localhost/live", &VideoEncoderConfig{}, nil, nil); err == nil {
+		t.Fatalf("expected error for nil decoder")
+	}
+	if err := StreamFromDecoder(&Decoder{}, "rtmp://localhost/live", nil, nil, nil); err == nil {
+		t.Fatalf("expected error without video or audio config")
+	}
+}
+
+func TestSRTOptions_IOOptions(t *testing.T) {
+	got, err := (&SRTOptions{
+		Latency:    200 * time.Millisecond,
+		Passphrase: "0123456789abcdef",
+		PBKeyLen:   32,
+		StreamID:   "#!::r=live/feed,m=publish",
+	}).ioOptions()
+	if err != nil {
+		t.Fatalf("ioOptions failed: %v", err)
+	}
+	want := map[string]string{
+		"latency":    "200000",
+		"passphrase": "0123456789abcdef",
+		"pbkeylen":   "32",
+		"streamid":   "#!::r=live/feed,m=publish",
+	}
+	for k, v := range want {
+		if got[k] != v {
+			t.Errorf("%s = %q, want %q", k, got[k], v)
+		}
+	}
+
+	for _, bad := range []*SRTOptions{
+		{Latency: -time.Second},
+		{Passphrase: "short"},
+		{PBKeyLen: 16},
+		{Passphrase: "0123456789", PBKeyLen: 20},
+		{StreamID: string(make([]byte, 513))},
+	} {
+		if _, err := bad.ioOptions(); err == nil {
+			t.Errorf("expected error for %+v", bad)
+		}
+	}
+}
+
+func TestNewStreamingEncoder_SRTOptionsRequireSRTURL(t *testing.T) {
+	_, err := NewStreamingEncoder("rtmp://example.com/live/stream",
+		WithStreamingOptions(&StreamingOptions{SRT: &SRTOptions{Latency: time.Second}}))
+	if err == nil {
+		t.Fatalf("expected error for SRT options on an rtmp url")
+	}
+}
@@ -189,10 +189,8 @@ func (r *SubtitleRenderer) Render(frame Frame) (Frame, error) {
 
 	// Return the first frame (subtitles filter outputs one frame per input).
 	// The returned frame is owned by the caller and must be freed.
-	out := *frames[0]
-	for i := 1; i < len(frames); i++ {
-		_ = frames[i].Free()
-	}
+	out := frames[0]
+	freeFrames(frames[1:])
 	return out, nil
 }
 
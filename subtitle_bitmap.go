@@ -0,0 +1,168 @@
+//go:build !ios && !android && (amd64 || arm64)
+
+package ffgo
+
+import (
+	"errors"
+	"fmt"
+	"image"
+	"unsafe"
+
+	"github.com/obinnaokechukwu/ffgo/avutil"
+)
+
+// RGBA converts the rectangle's paletted bitmap (DVD, PGS, DVB subtitles)
+// into an RGBA image of Width x Height. The palette alpha is kept, so
+// transparent entries stay transparent; the image origin is (0, 0), and
+// the rectangle's position on the video is (X, Y).
+func (r *SubtitleRect) RGBA() (*image.RGBA, error) {
+	if r.Width <= 0 || r.Height <= 0 {
+		return nil, errors.New("ffgo: subtitle rect has no size")
+	}
+	if r.LineSize < r.Width || len(r.Data) < r.LineSize*(r.Height-1)+r.Width {
+		return nil, errors.New("ffgo: subtitle rect bitmap is truncated")
+	}
+	if len(r.Palette) == 0 || len(r.Palette)%4 != 0 {
+		return nil, errors.New("ffgo: subtitle rect has no palette")
+	}
+
+	// Palette entries are native-endian uint32 ARGB with straight alpha,
+	// i.e. B, G, R, A bytes; image.RGBA stores premultiplied R, G, B, A.
+	colors := len(r.Palette) / 4
+	lut := make([][4]uint8, colors)
+	for i := range lut {
+		p := r.Palette[i*4 : i*4+4]
+		a := uint16(p[3])
+		lut[i] = [4]uint8{
+			uint8(uint16(p[2]) * a / 255),
+			uint8(uint16(p[1]) * a / 255),
+			uint8(uint16(p[0]) * a / 255),
+			p[3],
+		}
+	}
+
+	img := image.NewRGBA(image.Rect(0, 0, r.Width, r.Height))
+	for y := 0; y < r.Height; y++ {
+		src := r.Data[y*r.LineSize : y*r.LineSize+r.Width]
+		dst := img.Pix[y*img.Stride : y*img.Stride+r.Width*4]
+		for x, idx := range src {
+			if int(idx) >= colors {
+				continue // out-of-palette indices render transparent
+			}
+			copy(dst[x*4:x*4+4], lut[idx][:])
+		}
+	}
+	return img, nil
+}
+
+// RenderRGBA converts every bitmap rectangle of a bitmap subtitle to an RGBA
+// image, returning the images together with their top-left positions in the
+// video frame. Text subtitles have no bitmaps and return an error.
+func (s *Subtitle) RenderRGBA() ([]image.Image, []image.Point, error) {
+	if s.Type != SubtitleTypeBitmap {
+		return nil, nil, errors.New("ffgo: subtitle is not a bitmap subtitle")
+	}
+	images := make([]image.Image, 0, len(s.Rects))
+	points := make([]image.Point, 0, len(s.Rects))
+	for i := range s.Rects {
+		img, err := s.Rects[i].RGBA()
+		if err != nil {
+			return nil, nil, fmt.Errorf("ffgo: subtitle rect %d: %w", i, err)
+		}
+		images = append(images, img)
+		points = append(points, image.Pt(s.Rects[i].X, s.Rects[i].Y))
+	}
+	return images, points, nil
+}
+
+// OverlaySubtitle burns the bitmap rectangles of sub into a copy of
+// videoFrame, alpha-blending each one at its position using the palette's
+// per-entry alpha. Rectangles extending past the frame are clipped.
+//
+// The frame is converted to RGBA for blending and back to its own pixel
+// format, so the result can go straight to the encoder that videoFrame was
+// destined for. The returned frame carries videoFrame's PTS, is owned by the
+// caller and must be freed. Text subtitles need a font renderer; use
+// SubtitleRenderer for those.
+func OverlaySubtitle(videoFrame Frame, sub *Subtitle) (Frame, error) {
+	if videoFrame.IsNil() {
+		return Frame{}, errors.New("ffgo: input frame is nil")
+	}
+	if sub == nil {
+		return Frame{}, errors.New("ffgo: subtitle is nil")
+	}
+	images, points, err := sub.RenderRGBA()
+	if err != nil {
+		return Frame{}, err
+	}
+
+	width := int(avutil.GetFrameWidth(videoFrame.ptr))
+	height := int(avutil.GetFrameHeight(videoFrame.ptr))
+	pixFmt := PixelFormat(avutil.GetFrameFormat(videoFrame.ptr))
+	if width <= 0 || height <= 0 || pixFmt == PixelFormatNone {
+		return Frame{}, errors.New("ffgo: input frame is not a video frame")
+	}
+
+	rgba, err := convertFrame(videoFrame, width, height, pixFmt, PixelFormatRGBA)
+	if err != nil {
+		return Frame{}, err
+	}
+
+	stride := int(avutil.GetFrameLinesizePlane(rgba.ptr, 0))
+	pix := unsafe.Slice((*byte)(avutil.GetFrameDataPlane(rgba.ptr, 0)), stride*height)
+	bounds := image.Rect(0, 0, width, height)
+	for i, img := range images {
+		blendRGBA(pix, stride, bounds, img.(*image.RGBA), points[i])
+	}
+
+	if pixFmt == PixelFormatRGBA {
+		avutil.SetFramePTS(rgba.ptr, avutil.GetFramePTS(videoFrame.ptr))
+		return rgba, nil
+	}
+	out, err := convertFrame(rgba, width, height, PixelFormatRGBA, pixFmt)
+	_ = FrameFree(&rgba)
+	if err != nil {
+		return Frame{}, err
+	}
+	avutil.SetFramePTS(out.ptr, avutil.GetFramePTS(videoFrame.ptr))
+	return out, nil
+}
+
+// convertFrame returns a new owned frame holding src converted from srcFmt
+// to dstFmt at the same size.
+func convertFrame(src Frame, width, height int, srcFmt, dstFmt PixelFormat) (Frame, error) {
+	scaler, err := NewScaler(width, height, srcFmt, width, height, dstFmt, ScalePoint)
+	if err != nil {
+		return Frame{}, err
+	}
+	defer scaler.Close()
+
+	dst, err := newVideoFrame(width, height, dstFmt)
+	if err != nil {
+		return Frame{}, err
+	}
+	if err := scaler.ScaleTo(dst, src); err != nil {
+		_ = FrameFree(&dst)
+		return Frame{}, err
+	}
+	return dst, nil
+}
+
+// blendRGBA composites the premultiplied image img at pos over the opaque
+// RGBA pixels pix (stride bytes per row) covering bounds.
+func blendRGBA(pix []byte, stride int, bounds image.Rectangle, img *image.RGBA, pos image.Point) {
+	area := img.Bounds().Add(pos).Intersect(bounds)
+	for y := area.Min.Y; y < area.Max.Y; y++ {
+		for x := area.Min.X; x < area.Max.X; x++ {
+			s := img.PixOffset(x-pos.X, y-pos.Y)
+			a := uint16(img.Pix[s+3])
+			if a == 0 {
+				continue
+			}
+			d := y*stride + x*4
+			for c := 0; c < 3; c++ {
+				pix[d+c] = uint8(uint16(img.Pix[s+c]) + uint16(pix[d+c])*(255-a)/255)
+			}
+		}
+	}
+}
@@ -0,0 +1,148 @@
+//go:build !ios && !android && (amd64 || arm64)
+
+package ffgo
+
+import (
+	"image"
+	"image/color"
+	"testing"
+	"unsafe"
+
+	"github.com/obinnaokechukwu/ffgo/avutil"
+)
+
+// testBitmapSubtitle returns a 3x2 bitmap subtitle at (10, 20) using a
+// transparent, an opaque red and a half-transparent white palette entry.
+func testBitmapSubtitle() *Subtitle {
+	return &Subtitle{
+		Type: SubtitleTypeBitmap,
+		Rects: []SubtitleRect{{
+			X: 10, Y: 20, Width: 3, Height: 2,
+			LineSize: 4,
+			Data: []byte{
+				0, 1, 2, 9,
+				2, 1, 0, 9,
+			},
+			Palette: []byte{
+				0, 0, 0, 0, // transparent
+				0, 0, 255, 255, // opaque red (B, G, R, A)
+				255, 255, 255, 128, // half-transparent white
+			},
+		}},
+	}
+}
+
+func TestSubtitleRenderRGBA(t *testing.T) {
+	images, points, err := testBitmapSubtitle().RenderRGBA()
+	if err != nil {
+		t.Fatalf("RenderRGBA failed: %v", err)
+	}
+	if len(images) != 1 || len(points) != 1 {
+		t.Fatalf("got %d images and %d points, want 1", len(images), len(points))
+	}
+	if points[0] != image.Pt(10, 20) {
+		t.Errorf("position = %v, want (10,20)", points[0])
+	}
+	img := images[0]
+	if img.Bounds() != image.Rect(0, 0, 3, 2) {
+		t.Errorf("bounds = %v, want 3x2", img.Bounds())
+	}
+
+	want := map[image.Point]color.RGBA{
+		{0, 0}: {0, 0, 0, 0},
+		{1, 0}: {255, 0, 0, 255},
+		{2, 0}: {128, 128, 128, 128},
+		{0, 1}: {128, 128, 128, 128},
+		{2, 1}: {0, 0, 0, 0},
+	}
+	for p, c := range want {
+		if got := img.At(p.X, p.Y).(color.RGBA); got != c {
+			t.Errorf("pixel %v = %v, want %v", p, got, c)
+		}
+	}
+
+	if _, _, err := (&Subtitle{Type: SubtitleTypeText, Text: "hi"}).RenderRGBA(); err == nil {
+		t.Error("RenderRGBA on a text subtitle succeeded, want error")
+	}
+	bad := testBitmapSubtitle()
+	bad.Rects[0].Data = bad.Rects[0].Data[:4]
+	if _, _, err := bad.RenderRGBA(); err == nil {
+		t.Error("RenderRGBA with a truncated bitmap succeeded, want error")
+	}
+}
+
+func TestBlendRGBA(t *testing.T) {
+	const w, h = 4, 2
+	stride := w * 4
+	pix := make([]byte, stride*h)
+	for i := 0; i < len(pix); i += 4 {
+		pix[i], pix[i+1], pix[i+2], pix[i+3] = 0, 0, 200, 255 // opaque blue
+	}
+	images, _, err := testBitmapSubtitle().RenderRGBA()
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Place the 3x2 bitmap at (2,0) so its last column is clipped.
+	blendRGBA(pix, stride, image.Rect(0, 0, w, h), images[0].(*image.RGBA), image.Pt(2, 0))
+
+	at := func(x, y int) [4]byte {
+		o := y*stride + x*4
+		return [4]byte{pix[o], pix[o+1], pix[o+2], pix[o+3]}
+	}
+	if got := at(2, 0); got != [4]byte{0, 0, 200, 255} {
+		t.Errorf("transparent entry changed pixel to %v", got)
+	}
+	if got := at(3, 0); got != [4]byte{255, 0, 0, 255} {
+		t.Errorf("opaque red entry gave %v", got)
+	}
+	if got := at(2, 1); got != [4]byte{128, 128, 227, 255} {
+		t.Errorf("half-transparent white over blue gave %v", got)
+	}
+	if got := at(1, 1); got != [4]byte{0, 0, 200, 255} {
+		t.Errorf("pixel outside the bitmap changed to %v", got)
+	}
+}
+
+func TestOverlaySubtitle(t *testing.T) {
+	if !requireFFmpeg(t) {
+		return
+	}
+	frame := FrameAlloc()
+	defer func() { _ = FrameFree(&frame) }()
+	AVUtil.SetFrameWidth(frame, 64)
+	AVUtil.SetFrameHeight(frame, 48)
+	AVUtil.SetFrameFormat(frame, int32(PixelFormatYUV420P))
+	if err := AVUtil.FrameGetBuffer(frame, 32); err != nil {
+		t.Fatalf("Failed to allocate frame buffer: %v", err)
+	}
+	fillTestFrameYUV420(frame, 16)
+	avutil.SetFramePTS(frame.ptr, 7)
+
+	out, err := OverlaySubtitle(frame, testBitmapSubtitle())
+	if err != nil {
+		t.Fatalf("OverlaySubtitle failed: %v", err)
+	}
+	defer func() { _ = FrameFree(&out) }()
+
+	if PixelFormat(avutil.GetFrameFormat(out.ptr)) != PixelFormatYUV420P {
+		t.Errorf("output format = %v, want the input's yuv420p", avutil.GetFrameFormat(out.ptr))
+	}
+	if avutil.GetFramePTS(out.ptr) != 7 {
+		t.Errorf("output PTS = %d, want 7", avutil.GetFramePTS(out.ptr))
+	}
+	// The opaque red pixel at (11,20) must differ from the untouched frame.
+	luma := func(f Frame, x, y int) byte {
+		stride := int(avutil.GetFrameLinesizePlane(f.ptr, 0))
+		return unsafe.Slice((*byte)(avutil.GetFrameDataPlane(f.ptr, 0)), stride*48)[y*stride+x]
+	}
+	if luma(out, 11, 20) == luma(frame, 11, 20) {
+		t.Error("subtitle pixel was not blended into the frame")
+	}
+	if luma(out, 40, 40) != luma(frame, 40, 40) {
+		t.Error("pixel outside the subtitle changed")
+	}
+
+	if _, err := OverlaySubtitle(frame, &Subtitle{Type: SubtitleTypeText, Text: "hi"}); err == nil {
+		t.Error("OverlaySubtitle with a text subtitle succeeded, want error")
+	}
+}
@@ -0,0 +1,135 @@
+//go:build !ios && !android && (amd64 || arm64)
+
+package ffgo
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/obinnaokechukwu/ffgo/avutil"
+)
+
+// SubtitleWriter writes decoded text subtitles to a standalone SubRip (.srt)
+// or WebVTT (.vtt) file, e.g. to extract an embedded track to a sidecar.
+type SubtitleWriter struct {
+	file   *os.File
+	w      *bufio.Writer
+	format SubtitleFormat
+	cues   int
+	closed bool
+}
+
+// NewSubtitleWriter creates path and prepares it for cues in format, which
+// must be SubtitleFormatSRT or SubtitleFormatWebVTT.
+func NewSubtitleWriter(path string, format SubtitleFormat) (*SubtitleWriter, error) {
+	if format != SubtitleFormatSRT && format != SubtitleFormatWebVTT {
+		return nil, fmt.Errorf("ffgo: subtitle writer does not support %s", format)
+	}
+	f, err := os.Create(path)
+	if err != nil {
+		return nil, err
+	}
+	sw := &SubtitleWriter{file: f, w: bufio.NewWriter(f), format: format}
+	if format == SubtitleFormatWebVTT {
+		sw.w.WriteString("WEBVTT\n\n")
+	}
+	return sw, nil
+}
+
+// Write appends sub as the next cue. Its display window is StartTime to
+// EndTime relative to PTS, as returned by SubtitleDecoder.Decode. ASS
+// markup is reduced to plain text; subtitles without any text are skipped.
+// Bitmap subtitles cannot be written and return an error.
+func (sw *SubtitleWriter) Write(sub *Subtitle) error {
+	if sw.closed {
+		return errors.New("ffgo: subtitle writer is closed")
+	}
+	if sub == nil {
+		return nil
+	}
+	if sub.Type == SubtitleTypeBitmap {
+		return errors.New("ffgo: bitmap subtitles cannot be written as text")
+	}
+	text := subtitlePlainText(sub)
+	if text == "" {
+		return nil
+	}
+
+	var base time.Duration
+	if sub.PTS != avutil.AV_NOPTS_VALUE {
+		base = time.Duration(sub.PTS) * time.Microsecond
+	}
+	start, end := base+sub.StartTime, base+sub.EndTime
+	if end < start {
+		end = start
+	}
+
+	sw.cues++
+	sep := '.'
+	if sw.format == SubtitleFormatSRT {
+		sep = ','
+		fmt.Fprintf(sw.w, "%d\n", sw.cues)
+	}
+	_, err := fmt.Fprintf(sw.w, "%s --> %s\n%s\n\n", formatCueTime(start, sep), formatCueTime(end, sep), text)
+	return err
+}
+
+// Close flushes the remaining cues and closes the file.
+func (sw *SubtitleWriter) Close() error {
+	if sw.closed {
+		return nil
+	}
+	sw.closed = true
+
+	err := sw.w.Flush()
+	if cerr := sw.file.Close(); err == nil {
+		err = cerr
+	}
+	return err
+}
+
+// formatCueTime formats d as HH:MM:SS followed by sep and milliseconds, the
+// cue timing syntax shared by SRT (sep ',') and WebVTT (sep '.').
+func formatCueTime(d time.Duration, sep rune) string {
+	if d < 0 {
+		d = 0
+	}
+	ms := d.Milliseconds()
+	return fmt.Sprintf("%02d:%02d:%02d%c%03d", ms/3600000, ms/60000%60, ms/1000%60, sep, ms%1000)
+}
+
+// subtitlePlainText returns the text of sub with ASS event fields and
+// override tags removed and ASS line breaks turned into newlines.
+func subtitlePlainText(sub *Subtitle) string {
+	text := sub.Text
+	if sub.Type == SubtitleTypeASS {
+		// Decoders emit "ReadOrder,Layer,Style,Name,MarginL,MarginR,MarginV,Effect,Text";
+		// older ones a full "Dialogue: Layer,Start,End,Style,...,Effect,Text" line.
+		fields := 9
+		if strings.HasPrefix(text, "Dialogue:") {
+			fields = 10
+		}
+		if parts := strings.SplitN(text, ",", fields); len(parts) == fields {
+			text = parts[fields-1]
+		}
+
+		var b strings.Builder
+		depth := 0
+		for _, r := range text {
+			switch {
+			case r == '{':
+				depth++
+			case r == '}' && depth > 0:
+				depth--
+			case depth == 0:
+				b.WriteRune(r)
+			}
+		}
+		text = strings.NewReplacer(`\N`, "\n", `\n`, "\n", `\h`, " ").Replace(b.String())
+	}
+	return strings.TrimSpace(strings.ReplaceAll(text, "\r\n", "\n"))
+}
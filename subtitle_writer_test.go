@@ -0,0 +1,123 @@
+//go:build !ios && !android && (amd64 || arm64)
+
+package ffgo
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/obinnaokechukwu/ffgo/avutil"
+)
+
+func TestSubtitleWriter(t *testing.T) {
+	subs := []*Subtitle{
+		{PTS: avutil.AV_NOPTS_VALUE, StartTime: 1500 * time.Millisecond, EndTime: 3 * time.Second, Type: SubtitleTypeText, Text: "Hello"},
+		{PTS: 3723004000, EndTime: 2 * time.Second, Type: SubtitleTypeASS, Text: `0,0,Default,,0,0,0,,{\i1}Second{\i0}\Nline, with comma`},
+		{PTS: 0, Type: SubtitleTypeText, Text: "  "},
+	}
+
+	tests := []struct {
+		format SubtitleFormat
+		want   string
+	}{
+		{SubtitleFormatSRT, "1\n00:00:01,500 --> 00:00:03,000\nHello\n\n" +
+			"2\n01:02:03,004 --> 01:02:05,004\nSecond\nline, with comma\n\n"},
+		{SubtitleFormatWebVTT, "WEBVTT\n\n00:00:01.500 --> 00:00:03.000\nHello\n\n" +
+			"01:02:03.004 --> 01:02:05.004\nSecond\nline, with comma\n\n"},
+	}
+	for _, tt := range tests {
+		path := filepath.Join(t.TempDir(), "out."+tt.format.String())
+		w, err := NewSubtitleWriter(path, tt.format)
+		if err != nil {
+			t.Fatalf("NewSubtitleWriter(%s) failed: %v", tt.format, err)
+		}
+		for _, sub := range subs {
+			if err := w.Write(sub); err != nil {
+				t.Fatalf("Write failed: %v", err)
+			}
+		}
+		if err := w.Close(); err != nil {
+			t.Fatalf("Close failed: %v", err)
+		}
+		got, err := os.ReadFile(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(got) != tt.want {
+			t.Errorf("%s output:\n%s\nwant:\n%s", tt.format, got, tt.want)
+		}
+	}
+
+	if _, err := NewSubtitleWriter(filepath.Join(t.TempDir(), "out.ass"), SubtitleFormatASS); err == nil {
+		t.Error("NewSubtitleWriter(ASS) succeeded, want error")
+	}
+	w, err := NewSubtitleWriter(filepath.Join(t.TempDir(), "out.srt"), SubtitleFormatSRT)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer w.Close()
+	if err := w.Write(&Subtitle{Type: SubtitleTypeBitmap}); err == nil {
+		t.Error("Write(bitmap) succeeded, want error")
+	}
+}
+
+func TestSubtitleWriter_Extract(t *testing.T) {
+	if !requireFFmpeg(t) {
+		return
+	}
+	testFile := createTestVideoWithSubtitles(t)
+	if testFile == "" {
+		return
+	}
+	dec, err := NewDecoder(testFile)
+	if err != nil {
+		t.Fatalf("Failed to open file: %v", err)
+	}
+	defer dec.Close()
+	subStream := dec.SubtitleStream()
+	if subStream == nil {
+		t.Log("No subtitle stream found")
+		return
+	}
+	subDec, err := NewSubtitleDecoder(subStream)
+	if err != nil {
+		t.Fatalf("Failed to create subtitle decoder: %v", err)
+	}
+	defer subDec.Close()
+
+	path := filepath.Join(t.TempDir(), "extracted.srt")
+	w, err := NewSubtitleWriter(path, SubtitleFormatSRT)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for {
+		pkt, err := dec.ReadPacket()
+		if err != nil {
+			t.Fatalf("ReadPacket failed: %v", err)
+		}
+		if pkt == nil {
+			break
+		}
+		sub, err := subDec.Decode(pkt)
+		if err != nil {
+			t.Fatalf("Decode failed: %v", err)
+		}
+		if err := w.Write(sub); err != nil {
+			t.Fatalf("Write failed: %v", err)
+		}
+	}
+	if err := w.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(string(data), "1\n") || !strings.Contains(string(data), " --> ") {
+		t.Errorf("extracted SRT is malformed:\n%s", data)
+	}
+}
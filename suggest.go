@@ -0,0 +1,228 @@
+//go:build !ios && !android && (amd64 || arm64)
+
+package ffgo
+
+import (
+	"errors"
+	"math"
+	"unsafe"
+
+	"github.com/obinnaokechukwu/ffgo/avutil"
+)
+
+// QualityTarget selects the size/quality trade-off for SuggestEncodeSettings.
+type QualityTarget int
+
+const (
+	// QualitySmall favors small files; some artifacts are acceptable.
+	QualitySmall QualityTarget = iota
+
+	// QualityBalanced is visually good at a reasonable size.
+	QualityBalanced
+
+	// QualityHigh is close to visually lossless.
+	QualityHigh
+)
+
+// String returns the string representation of the quality target.
+func (q QualityTarget) String() string {
+	switch q {
+	case QualitySmall:
+		return "small"
+	case QualityBalanced:
+		return "balanced"
+	case QualityHigh:
+		return "high"
+	default:
+		return "unknown"
+	}
+}
+
+// Analysis window for SuggestEncodeSettings.
+const (
+	suggestSampleFrames = 90 // frames decoded from the start of the input
+	suggestThumbWidth   = 64 // analysis resolution (grayscale)
+	suggestThumbHeight  = 36
+)
+
+// SuggestEncodeSettings analyzes the start of inputPath and returns an H.264
+// configuration suited to target.
+//
+// It reads the resolution and frame rate of the video stream and decodes a
+// short sample to estimate content complexity (spatial detail and motion).
+// The result uses CRF rate control capped by MaxBitrate/BufferSize, so simple
+// content stays small and complex content does not blow past a sane bitrate.
+//
+// The suggestion is a heuristic starting point, not an optimum; callers can
+// adjust any field before passing it to NewEncoderWithOptions.
+func SuggestEncodeSettings(inputPath string, target QualityTarget) (*VideoEncoderConfig, error) {
+	if target < QualitySmall || target > QualityHigh {
+		return nil, errors.New("ffgo: invalid quality target")
+	}
+
+	d, err := NewDecoder(inputPath)
+	if err != nil {
+		return nil, err
+	}
+	defer d.Close()
+
+	vs := d.VideoStream()
+	if vs == nil {
+		return nil, errors.New("ffgo: no video stream found")
+	}
+	if vs.Width <= 0 || vs.Height <= 0 {
+		return nil, errors.New("ffgo: video stream has no dimensions")
+	}
+
+	frameRate := vs.FrameRate
+	if frameRate.Num <= 0 || frameRate.Den <= 0 {
+		frameRate = Rational{Num: 30, Den: 1}
+	}
+
+	complexity, err := sampleComplexity(d, vs)
+	if err != nil {
+		return nil, err
+	}
+
+	return suggestConfig(vs.Width, vs.Height, frameRate, complexity, target), nil
+}
+
+// suggestConfig maps the probed properties to an encoder configuration.
+// complexity is in [0, 1].
+func suggestConfig(width, height int, frameRate Rational, complexity float64, target QualityTarget) *VideoEncoderConfig {
+	fps := float64(frameRate.Num) / float64(frameRate.Den)
+
+	var crf int
+	var preset EncoderPreset
+	var bitsPerPixel float64
+	switch target {
+	case QualitySmall:
+		crf, preset, bitsPerPixel = 28, PresetSlow, 0.05
+	case QualityHigh:
+		crf, preset, bitsPerPixel = 19, PresetSlow, 0.20
+	default:
+		crf, preset, bitsPerPixel = 23, PresetMedium, 0.10
+	}
+
+	// Busy content hides fewer artifacts per bit; spend a little more on it
+	// and a little less on static, flat content.
+	switch {
+	case complexity >= 0.6:
+		crf--
+	case complexity < 0.15:
+		crf++
+	}
+
+	maxBitrate := int64(float64(width*height) * fps * bitsPerPixel * (0.5 + complexity))
+	if maxBitrate < 100000 {
+		maxBitrate = 100000
+	}
+
+	gop := int(math.Round(fps * 2))
+	if gop < 1 {
+		gop = 1
+	}
+
+	return &VideoEncoderConfig{
+		Codec:       CodecIDH264,
+		Width:       width,
+		Height:      height,
+		FrameRate:   frameRate,
+		PixelFormat: PixelFormatYUV420P,
+		GOPSize:     gop,
+		MaxBFrames:  2,
+		Preset:      preset,
+		RateControl: RateControlCRF,
+		CRF:         crf,
+		MaxBitrate:  maxBitrate,
+		BufferSize:  maxBitrate * 2,
+	}
+}
+
+// sampleComplexity decodes up to suggestSampleFrames frames and returns a
+// complexity score in [0, 1] combining spatial detail (mean horizontal
+// gradient) and motion (mean difference between consecutive frames), both
+// measured on a small grayscale thumbnail.
+func sampleComplexity(d *Decoder, vs *StreamInfo) (float64, error) {
+	if err := d.OpenVideoDecoder(); err != nil {
+		return 0, err
+	}
+
+	scaler, err := NewScaler(vs.Width, vs.Height, vs.PixelFmt, suggestThumbWidth, suggestThumbHeight, PixelFormatGray8, ScaleFastBilinear)
+	if err != nil {
+		return 0, err
+	}
+	defer scaler.Close()
+
+	var spatial, temporal float64
+	var frames, diffs int
+	prev := make([]byte, suggestThumbWidth*suggestThumbHeight)
+	cur := make([]byte, suggestThumbWidth*suggestThumbHeight)
+
+	for frames < suggestSampleFrames {
+		frame, err := d.DecodeVideo()
+		if err != nil {
+			if IsEOF(err) {
+				break
+			}
+			return 0, err
+		}
+		if frame.IsNil() {
+			break
+		}
+
+		gray, err := scaler.Scale(frame)
+		if err != nil {
+			return 0, err
+		}
+		copyGrayPlane(cur, gray)
+
+		var grad float64
+		for y := 0; y < suggestThumbHeight; y++ {
+			row := cur[y*suggestThumbWidth : (y+1)*suggestThumbWidth]
+			for x := 1; x < suggestThumbWidth; x++ {
+				grad += math.Abs(float64(row[x]) - float64(row[x-1]))
+			}
+		}
+		spatial += grad / float64(suggestThumbHeight*(suggestThumbWidth-1))
+
+		if frames > 0 {
+			var diff float64
+			for i := range cur {
+				diff += math.Abs(float64(cur[i]) - float64(prev[i]))
+			}
+			temporal += diff / float64(len(cur))
+			diffs++
+		}
+
+		prev, cur = cur, prev
+		frames++
+	}
+
+	if frames == 0 {
+		return 0, errors.New("ffgo: no video frames decoded")
+	}
+
+	// Normalize: a mean gradient of ~24 or a mean frame difference of ~16
+	// (out of 255) already corresponds to very busy content.
+	spatialScore := math.Min(spatial/float64(frames)/24, 1)
+	temporalScore := 0.0
+	if diffs > 0 {
+		temporalScore = math.Min(temporal/float64(diffs)/16, 1)
+	}
+	return 0.4*spatialScore + 0.6*temporalScore, nil
+}
+
+// copyGrayPlane copies the tightly packed luma plane of a GRAY8 thumbnail
+// into dst, dropping any line padding.
+func copyGrayPlane(dst []byte, frame Frame) {
+	data := avutil.GetFrameDataPlane(frame.ptr, 0)
+	stride := int(avutil.GetFrameLinesizePlane(frame.ptr, 0))
+	if data == nil || stride < suggestThumbWidth {
+		return
+	}
+	src := unsafe.Slice((*byte)(data), stride*suggestThumbHeight)
+	for y := 0; y < suggestThumbHeight; y++ {
+		copy(dst[y*suggestThumbWidth:(y+1)*suggestThumbWidth], src[y*stride:])
+	}
+}
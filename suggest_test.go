@@ -0,0 +1,58 @@
+//go:build !ios && !android && (amd64 || arm64)
+
+package ffgo
+
+import "testing"
+
+func TestSuggestConfig(t *testing.T) {
+	fps := Rational{Num: 30, Den: 1}
+
+	small := suggestConfig(1920, 1080, fps, 0.3, QualitySmall)
+	high := suggestConfig(1920, 1080, fps, 0.3, QualityHigh)
+	if small.CRF <= high.CRF {
+		t.Errorf("small CRF %d should be higher than high CRF %d", small.CRF, high.CRF)
+	}
+	if small.MaxBitrate >= high.MaxBitrate {
+		t.Errorf("small maxrate %d should be lower than high maxrate %d", small.MaxBitrate, high.MaxBitrate)
+	}
+	if high.RateControl != RateControlCRF || high.BufferSize != 2*high.MaxBitrate {
+		t.Errorf("unexpected rate control: %v, bufsize %d", high.RateControl, high.BufferSize)
+	}
+	if high.GOPSize != 60 {
+		t.Errorf("GOPSize = %d, want 60", high.GOPSize)
+	}
+
+	static := suggestConfig(1920, 1080, fps, 0.05, QualityBalanced)
+	busy := suggestConfig(1920, 1080, fps, 0.9, QualityBalanced)
+	if static.CRF <= busy.CRF {
+		t.Errorf("static CRF %d should be higher than busy CRF %d", static.CRF, busy.CRF)
+	}
+	if static.MaxBitrate >= busy.MaxBitrate {
+		t.Errorf("static maxrate %d should be lower than busy maxrate %d", static.MaxBitrate, busy.MaxBitrate)
+	}
+}
+
+func TestSuggestEncodeSettings(t *testing.T) {
+	if !requireFFmpeg(t) {
+		return
+	}
+	testFile := createTestVideo(t)
+	if testFile == "" {
+		return
+	}
+
+	cfg, err := SuggestEncodeSettings(testFile, QualityBalanced)
+	if err != nil {
+		t.Fatalf("SuggestEncodeSettings failed: %v", err)
+	}
+	if cfg.Width <= 0 || cfg.Height <= 0 {
+		t.Errorf("invalid dimensions %dx%d", cfg.Width, cfg.Height)
+	}
+	if cfg.CRF <= 0 || cfg.MaxBitrate <= 0 {
+		t.Errorf("invalid rate control: CRF %d maxrate %d", cfg.CRF, cfg.MaxBitrate)
+	}
+
+	if _, err := SuggestEncodeSettings(testFile, QualityTarget(99)); err == nil {
+		t.Error("expected error for invalid quality target")
+	}
+}
@@ -31,18 +31,25 @@ const (
 	FlagSinc         = 0x100
 	FlagLanczos      = 0x200 // Lanczos scaling
 	FlagSpline       = 0x400 // Natural bicubic spline
+
+	FlagFullChrHInt = 0x2000  // Full chroma interpolation
+	FlagAccurateRnd = 0x40000 // Accurate rounding
+	FlagBitExact    = 0x80000 // Bit-exact output
 )
 
 // Function bindings
 var (
-	swsGetContext     func(srcW, srcH int32, srcFormat int32, dstW, dstH int32, dstFormat int32, flags int32, srcFilter, dstFilter, param uintptr) uintptr
-	swsScale          func(ctx, srcSlice, srcStride uintptr, srcSliceY, srcSliceH int32, dst, dstStride uintptr) int32
-	swsFreeContext    func(ctx uintptr)
-	swsScaleFrame     func(ctx, dst, src uintptr) int32
-	swsFrameStart     func(ctx, dst, src uintptr) int32
-	swsFrameEnd       func(ctx uintptr)
-	swsIsSupportedIn  func(format int32) int32
-	swsIsSupportedOut func(format int32) int32
+	swsGetContext       func(srcW, srcH int32, srcFormat int32, dstW, dstH int32, dstFormat int32, flags int32, srcFilter, dstFilter, param uintptr) uintptr
+	swsGetCachedContext func(ctx uintptr, srcW, srcH int32, srcFormat int32, dstW, dstH int32, dstFormat int32, flags int32, srcFilter, dstFilter, param uintptr) uintptr
+	swsScale            func(ctx, srcSlice, srcStride uintptr, srcSliceY, srcSliceH int32, dst, dstStride uintptr) int32
+	swsFreeContext      func(ctx uintptr)
+	swsAllocContext     func() uintptr
+	swsInitContext      func(ctx, srcFilter, dstFilter uintptr) int32
+	swsScaleFrame       func(ctx, dst, src uintptr) int32
+	swsFrameStart       func(ctx, dst, src uintptr) int32
+	swsFrameEnd         func(ctx uintptr)
+	swsIsSupportedIn    func(format int32) int32
+	swsIsSupportedOut   func(format int32) int32
 
 	swsGetColorspaceDetails func(ctx uintptr, invTable *unsafe.Pointer, srcRange *int32, table *unsafe.Pointer, dstRange *int32, brightness, contrast, saturation *int32) int32
 	swsSetColorspaceDetails func(ctx, invTable uintptr, srcRange int32, table uintptr, dstRange int32, brightness, contrast, saturation int32) int32
@@ -70,8 +77,11 @@ func registerBindings() {
 	}
 
 	purego.RegisterLibFunc(&swsGetContext, lib, "sws_getContext")
+	purego.RegisterLibFunc(&swsGetCachedContext, lib, "sws_getCachedContext")
 	purego.RegisterLibFunc(&swsScale, lib, "sws_scale")
 	purego.RegisterLibFunc(&swsFreeContext, lib, "sws_freeContext")
+	purego.RegisterLibFunc(&swsAllocContext, lib, "sws_alloc_context")
+	purego.RegisterLibFunc(&swsInitContext, lib, "sws_init_context")
 
 	// sws_scale_frame was added in FFmpeg 5.0
 	purego.RegisterLibFunc(&swsScaleFrame, lib, "sws_scale_frame")
@@ -115,6 +125,41 @@ func GetContext(srcW, srcH int, srcFormat avutil.PixelFormat, dstW, dstH int, ds
 	))
 }
 
+// GetCachedContext returns a scaling context for the given parameters,
+// reusing ctx if it was created with the same ones and otherwise freeing it
+// and allocating a new one. ctx may be nil. On failure it returns nil, and
+// ctx has been freed.
+func GetCachedContext(ctx Context, srcW, srcH int, srcFormat avutil.PixelFormat, dstW, dstH int, dstFormat avutil.PixelFormat, flags int32, srcFilter, dstFilter Filter, param unsafe.Pointer) Context {
+	if swsGetCachedContext == nil {
+		return nil
+	}
+	return unsafe.Pointer(swsGetCachedContext(uintptr(ctx),
+		int32(srcW), int32(srcH), int32(srcFormat),
+		int32(dstW), int32(dstH), int32(dstFormat),
+		flags,
+		uintptr(srcFilter), uintptr(dstFilter), uintptr(param),
+	))
+}
+
+// AllocContext allocates an uninitialized scaling context. Configure it with
+// AVOptions (srcw, srch, src_format, dstw, dsth, dst_format, sws_flags,
+// sws_dither, ...) and then call InitContext.
+func AllocContext() Context {
+	if swsAllocContext == nil {
+		return nil
+	}
+	return unsafe.Pointer(swsAllocContext())
+}
+
+// InitContext initializes a context from AllocContext with the options set
+// on it. Returns a negative error code on failure.
+func InitContext(ctx Context, srcFilter, dstFilter Filter) int32 {
+	if ctx == nil || swsInitContext == nil {
+		return -1
+	}
+	return swsInitContext(uintptr(ctx), uintptr(srcFilter), uintptr(dstFilter))
+}
+
 // FreeContext frees a scaling context.
 // Safe to call with nil.
 func FreeContext(ctx Context) {
@@ -6,6 +6,8 @@ import (
 	"errors"
 	"fmt"
 	"math"
+	"math/big"
+	"slices"
 
 	"github.com/obinnaokechukwu/ffgo/avutil"
 )
@@ -53,7 +55,92 @@ func (t *FrameTiming) Next() (pts, dts int64) {
 	return pts, dts
 }
 
+// PTSMapper rescales timestamps from a source time base (e.g. a decoder's
+// stream) to a destination time base (e.g. an encoder's), keeping the output
+// strictly increasing as encoders require.
+//
+// Use it in transcode loops instead of numbering frames, so that
+// variable-frame-rate input keeps its timing:
+//
+//	m := ffgo.NewPTSMapper(decoder.VideoStream().TimeBase, ffgo.NewRational(1, 30))
+//	pts := m.Map(ffgo.GetFrameInfo(frame).PTS)
+type PTSMapper struct {
+	src Rational
+	dst Rational
+
+	next    int64 // smallest PTS the next Map may return
+	started bool
+}
+
+// NewPTSMapper creates a mapper from src to dst time base.
+func NewPTSMapper(src, dst Rational) *PTSMapper {
+	return &PTSMapper{src: src, dst: dst}
+}
+
+// SrcTimeBase returns the source time base.
+func (m *PTSMapper) SrcTimeBase() Rational { return m.src }
+
+// DstTimeBase returns the destination time base.
+func (m *PTSMapper) DstTimeBase() Rational { return m.dst }
+
+// Map rescales pts to the destination time base, rounding to the nearest
+// tick. If the result would not be after the previous output (duplicate or
+// out-of-order source timestamps, or several source frames within one
+// destination tick) it is bumped to previous+1. AV_NOPTS_VALUE maps to
+// previous+1 (0 for the first frame).
+func (m *PTSMapper) Map(pts int64) int64 {
+	out := m.next
+	if pts != avutil.AV_NOPTS_VALUE {
+		out = rescaleTS(pts, m.src, m.dst)
+		if m.started && out < m.next {
+			out = m.next
+		}
+	}
+	m.next = out + 1
+	m.started = true
+	return out
+}
+
+// SetMinPTS makes the next mapped PTS at least pts, for example to continue
+// after frames that were timestamped by other means.
+func (m *PTSMapper) SetMinPTS(pts int64) {
+	if !m.started || m.next < pts {
+		m.next = pts
+		m.started = true
+	}
+}
+
+// Reset forgets previous outputs so the next PTS is not constrained.
+func (m *PTSMapper) Reset() {
+	m.next = 0
+	m.started = false
+}
+
+// rescaleTS converts ts from src to dst time base with round-to-nearest,
+// like av_rescale_q, without overflowing for large timestamps.
+func rescaleTS(ts int64, src, dst Rational) int64 {
+	if src.Den == 0 || dst.Num == 0 {
+		return ts
+	}
+	num := new(big.Int).Mul(big.NewInt(ts), big.NewInt(int64(src.Num)*int64(dst.Den)))
+	den := big.NewInt(int64(src.Den) * int64(dst.Num))
+	if den.Sign() < 0 {
+		num.Neg(num)
+		den.Neg(den)
+	}
+	// Round half away from zero.
+	half := new(big.Int).Rsh(den, 1)
+	if num.Sign() >= 0 {
+		num.Add(num, half)
+	} else {
+		num.Sub(num, half)
+	}
+	return num.Quo(num, den).Int64()
+}
+
 // GenerateTimestamps generates count PTS values in the given time base for a nominal fps.
+// It assumes a constant frame rate; use ConvertToCFR to fit variable-frame-rate
+// source timestamps onto a fixed rate.
 func GenerateTimestamps(count int, timebase Rational, fps float64) []int64 {
 	if count <= 0 {
 		return nil
@@ -70,6 +157,80 @@ func GenerateTimestamps(count int, timebase Rational, fps float64) []int64 {
 	return out
 }
 
+// CFRFrame is one output slot of a constant-frame-rate conversion.
+type CFRFrame struct {
+	// Source is the index in srcPTS of the frame to show in this slot.
+	Source int
+
+	// PTS is the slot's presentation time in the source time base.
+	PTS int64
+
+	// Duplicate is true if Source was already shown in an earlier slot.
+	Duplicate bool
+}
+
+// ConvertToCFR maps variable-frame-rate source frames onto a constant
+// targetFPS grid, deciding which frames to duplicate and which to drop the
+// way FFmpeg's fps filter does. srcPTS holds the source presentation
+// timestamps in srcTB, in presentation order.
+//
+// The result has one entry per output slot, starting at the first source
+// timestamp and ending at the slot of the last source frame. Each source
+// timestamp is rounded to the nearest slot; a slot shows the latest frame
+// rounded to it or earlier, so gaps repeat the previous frame and frames
+// sharing a slot are dropped except for the last. Frames with
+// AV_NOPTS_VALUE, or not later than the frame before them, are dropped.
+//
+// Encode the Source frame of each slot in order with Encoder.WriteFrame at
+// targetFPS, or pass PTS to WriteFrameWithPTS. It returns nil for invalid
+// arguments or when no frame has a timestamp.
+func ConvertToCFR(srcPTS []int64, srcTB Rational, targetFPS float64) []CFRFrame {
+	if srcTB.Num <= 0 || srcTB.Den <= 0 || targetFPS <= 0 {
+		return nil
+	}
+	ticksPerSlot := float64(srcTB.Den) / (float64(srcTB.Num) * targetFPS)
+
+	// Slot of each usable source frame, in order.
+	type sourceSlot struct {
+		index int
+		slot  int64
+	}
+	var (
+		sources = make([]sourceSlot, 0, len(srcPTS))
+		start   int64
+		last    = avutil.NoPTSValue
+	)
+	for i, pts := range srcPTS {
+		if pts == avutil.NoPTSValue || (last != avutil.NoPTSValue && pts <= last) {
+			continue
+		}
+		if last == avutil.NoPTSValue {
+			start = pts
+		}
+		last = pts
+		sources = append(sources, sourceSlot{i, int64(math.Round(float64(pts-start) / ticksPerSlot))})
+	}
+	if len(sources) == 0 {
+		return nil
+	}
+
+	lastSlot := sources[len(sources)-1].slot
+	out := make([]CFRFrame, 0, lastSlot+1)
+	cur, shown := 0, -1
+	for n := int64(0); n <= lastSlot; n++ {
+		for cur+1 < len(sources) && sources[cur+1].slot <= n {
+			cur++
+		}
+		out = append(out, CFRFrame{
+			Source:    sources[cur].index,
+			PTS:       start + int64(math.Round(float64(n)*ticksPerSlot)),
+			Duplicate: cur == shown,
+		})
+		shown = cur
+	}
+	return out
+}
+
 // ValidateTimestamps checks that frame PTS values are non-decreasing (ignoring AV_NOPTS_VALUE).
 func ValidateTimestamps(frames []*Frame) error {
 	var last int64 = avutil.NoPTSValue
@@ -131,7 +292,7 @@ func FrameRateDetect(decoder *Decoder) (float64, error) {
 		if f.IsNil() {
 			break
 		}
-		pts := avutil.GetFramePTS(f.ptr)
+		pts := frameTimestamp(f.ptr)
 		if pts == avutil.NoPTSValue {
 			continue
 		}
@@ -152,3 +313,100 @@ func FrameRateDetect(decoder *Decoder) (float64, error) {
 	}
 	return float64(count-1) / seconds, nil
 }
+
+// vfrSamplePackets is how many video packets IsVFR inspects.
+const vfrSamplePackets = 64
+
+// maxReorderDepth bounds how far a packet's PTS can be from its decode
+// position (H.264/HEVC allow at most 16 frames of reordering).
+const maxReorderDepth = 16
+
+// IsVFR reports whether the video stream has a variable frame rate. It
+// decides whether an encoder should take timestamps from the source
+// (WriteFrameWithPTS) or number frames at a fixed rate.
+//
+// The stream is considered VFR if its average frame rate (FrameRate)
+// differs from its base rate (RFrameRate) by more than 1%, or if the
+// spacing of the timestamps of its first packets varies by more than
+// rounding. Sampling reads packets and then seeks back to the start, so call
+// IsVFR before decoding; inputs that cannot seek are judged by the frame
+// rates alone. It returns false if there is no video stream.
+func (d *Decoder) IsVFR() bool {
+	info := d.VideoStream()
+	if info == nil {
+		return false
+	}
+	if ratesDiffer(info.FrameRate, info.RFrameRate) {
+		return true
+	}
+
+	// Only sample inputs we can rewind, so the caller still sees every packet.
+	if err := d.SeekTimestamp(0); err != nil {
+		return false
+	}
+	defer func() { _ = d.SeekTimestamp(0) }()
+
+	pts := make([]int64, 0, vfrSamplePackets)
+	complete := false
+	for len(pts) < vfrSamplePackets {
+		pkt, err := d.ReadPacket()
+		if err != nil || pkt == nil {
+			complete = err == nil
+			break
+		}
+		if pkt.StreamIndex() != info.Index {
+			continue
+		}
+		if ts := pkt.PTS(); ts != avutil.NoPTSValue {
+			pts = append(pts, ts)
+		}
+	}
+	return timestampsVary(pts, complete)
+}
+
+// ratesDiffer reports whether two frame rates differ by more than 1%. A rate
+// that is unknown, or a base rate of exactly twice the average (field rate
+// of interlaced video), does not count as a difference.
+func ratesDiffer(avg, base Rational) bool {
+	if avg.Num <= 0 || avg.Den <= 0 || base.Num <= 0 || base.Den <= 0 {
+		return false
+	}
+	a, b := avg.Float64(), base.Float64()
+	if math.Abs(b-2*a) <= a*0.01 {
+		return false
+	}
+	return math.Abs(a-b) > math.Max(a, b)*0.01
+}
+
+// timestampsVary reports whether the gaps between consecutive presentation
+// timestamps are uneven. pts is in decode order; unless complete (the whole
+// stream was read), the highest timestamps are ignored because the frames
+// that fall between them may not have been read yet. Gaps within one tick
+// or 5% of the median, whichever is larger, count as equal so that
+// timestamps rounded to a coarse time base stay CFR.
+func timestampsVary(pts []int64, complete bool) bool {
+	sorted := slices.Clone(pts)
+	slices.Sort(sorted)
+	if !complete {
+		if len(sorted) <= maxReorderDepth {
+			return false
+		}
+		sorted = sorted[:len(sorted)-maxReorderDepth]
+	}
+	if len(sorted) < 3 {
+		return false
+	}
+	gaps := make([]int64, 0, len(sorted)-1)
+	for i := 1; i < len(sorted); i++ {
+		if g := sorted[i] - sorted[i-1]; g > 0 {
+			gaps = append(gaps, g)
+		}
+	}
+	if len(gaps) < 2 {
+		return false
+	}
+	slices.Sort(gaps)
+	median := gaps[len(gaps)/2]
+	tolerance := max(1, median/20)
+	return median-gaps[0] > tolerance || gaps[len(gaps)-1]-median > tolerance
+}
@@ -3,6 +3,7 @@
 package ffgo
 
 import (
+	"math"
 	"path/filepath"
 	"testing"
 
@@ -23,6 +24,33 @@ func TestGenerateTimestamps(t *testing.T) {
 	}
 }
 
+func TestPTSMapper(t *testing.T) {
+	// 90kHz source with irregular spacing mapped to a 1/1000 encoder time base.
+	m := NewPTSMapper(NewRational(1, 90000), NewRational(1, 1000))
+	in := []int64{0, 3003, 3003, 9000, 9010, avutil.AV_NOPTS_VALUE, 90000}
+	want := []int64{0, 33, 34, 100, 101, 102, 1000}
+	for i, pts := range in {
+		if got := m.Map(pts); got != want[i] {
+			t.Errorf("Map(%d) = %d, want %d", pts, got, want[i])
+		}
+	}
+
+	m.Reset()
+	if got := m.Map(avutil.AV_NOPTS_VALUE); got != 0 {
+		t.Errorf("first NOPTS after Reset = %d, want 0", got)
+	}
+	m.SetMinPTS(50)
+	if got := m.Map(90); got != 50 {
+		t.Errorf("Map after SetMinPTS(50) = %d, want 50", got)
+	}
+
+	// Large timestamps must not overflow.
+	fine := NewPTSMapper(NewRational(1, 1000000000), NewRational(1, 90000))
+	if got := fine.Map(1 << 50); got != 101330991616 {
+		t.Errorf("large Map = %d, want 101330991616", got)
+	}
+}
+
 func TestValidateTimestamps(t *testing.T) {
 	if !requireFFmpeg(t) {
 		return
@@ -76,3 +104,135 @@ func TestFrameRateDetect(t *testing.T) {
 		t.Fatalf("fps too high: %f", fps)
 	}
 }
+
+func TestRatesDiffer(t *testing.T) {
+	tests := []struct {
+		avg, base Rational
+		want      bool
+	}{
+		{NewRational(30, 1), NewRational(30, 1), false},
+		{NewRational(30000, 1001), NewRational(30000, 1001), false},
+		{NewRational(25, 1), NewRational(50, 1), false}, // field rate
+		{NewRational(2997, 100), NewRational(30, 1), false},
+		{NewRational(24, 1), NewRational(30, 1), true},
+		{NewRational(0, 1), NewRational(30, 1), false},
+	}
+	for _, tt := range tests {
+		if got := ratesDiffer(tt.avg, tt.base); got != tt.want {
+			t.Errorf("ratesDiffer(%d/%d, %d/%d) = %v, want %v",
+				tt.avg.Num, tt.avg.Den, tt.base.Num, tt.base.Den, got, tt.want)
+		}
+	}
+}
+
+func TestTimestampsVary(t *testing.T) {
+	cfr := make([]int64, 40)
+	for i := range cfr {
+		cfr[i] = int64(i) * 3003
+	}
+	// 29.97fps in a 1ms time base rounds to alternating 33/34 gaps.
+	rounded := make([]int64, 40)
+	for i := range rounded {
+		rounded[i] = int64(math.Round(float64(i) * 1001 / 30))
+	}
+	// Decode order with B-frames: I0 P3 B1 B2 P6 B4 B5 ...
+	reordered := make([]int64, 0, 40)
+	for i := 0; len(reordered) < 39; i += 3 {
+		reordered = append(reordered, int64(i+3)*100, int64(i+1)*100, int64(i+2)*100)
+	}
+	reordered = append([]int64{0}, reordered...)
+	vfr := []int64{0, 33, 66, 100, 166, 200, 233, 300, 333, 366}
+
+	tests := []struct {
+		name     string
+		pts      []int64
+		complete bool
+		want     bool
+	}{
+		{"cfr", cfr, false, false},
+		{"rounded", rounded, false, false},
+		{"reordered", reordered, false, false},
+		{"vfr", vfr, true, true},
+		{"too short", vfr, false, false},
+		{"two frames", []int64{0, 1000}, true, false},
+	}
+	for _, tt := range tests {
+		if got := timestampsVary(tt.pts, tt.complete); got != tt.want {
+			t.Errorf("%s: timestampsVary = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestDecoderIsVFR(t *testing.T) {
+	if !requireFFmpeg(t) {
+		return
+	}
+
+	dec, err := NewDecoder(filepath.Join("testdata", "test.mp4"))
+	if err != nil {
+		t.Fatalf("NewDecoder failed: %v", err)
+	}
+	defer dec.Close()
+
+	if r := dec.VideoStream().RFrameRate; r.Num <= 0 || r.Den <= 0 {
+		t.Errorf("RFrameRate = %d/%d, want a valid rate", r.Num, r.Den)
+	}
+	if dec.IsVFR() {
+		t.Error("IsVFR() = true for a constant frame rate file")
+	}
+
+	// Sampling must leave the decoder at the start of the stream.
+	frame, err := dec.DecodeVideo()
+	if err != nil || frame.IsNil() {
+		t.Fatalf("DecodeVideo after IsVFR failed: %v", err)
+	}
+	if pts := GetFrameInfo(frame).PTS; pts > 0 {
+		t.Errorf("first frame after IsVFR has PTS %d, want the first frame", pts)
+	}
+}
+
+func TestConvertToCFR(t *testing.T) {
+	// Source in a 1ms time base: a gap after 100ms and a burst from 200ms.
+	tb := NewRational(1, 1000)
+	src := []int64{1000, 1050, 1100, 1200, 1210, 1230, 1250}
+	got := ConvertToCFR(src, tb, 20) // 50ms slots
+
+	want := []CFRFrame{
+		{Source: 0, PTS: 1000},
+		{Source: 1, PTS: 1050},
+		{Source: 2, PTS: 1100},
+		{Source: 2, PTS: 1150, Duplicate: true},
+		{Source: 4, PTS: 1200}, // 1200 dropped for 1210
+		{Source: 6, PTS: 1250}, // 1230 rounds up and is dropped for 1250
+	}
+	if len(got) != len(want) {
+		t.Fatalf("got %d slots %+v, want %d", len(got), got, len(want))
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("slot %d = %+v, want %+v", i, got[i], want[i])
+		}
+	}
+}
+
+func TestConvertToCFRSkipsInvalid(t *testing.T) {
+	tb := NewRational(1, 30)
+	src := []int64{avutil.NoPTSValue, 0, 1, 1, 2}
+	got := ConvertToCFR(src, tb, 30)
+	wantSources := []int{1, 2, 4}
+	if len(got) != len(wantSources) {
+		t.Fatalf("got %d slots %+v, want %d", len(got), got, len(wantSources))
+	}
+	for i, s := range wantSources {
+		if got[i].Source != s || got[i].PTS != int64(i) || got[i].Duplicate {
+			t.Errorf("slot %d = %+v, want source %d at PTS %d", i, got[i], s, i)
+		}
+	}
+
+	if ConvertToCFR(src, tb, 0) != nil {
+		t.Error("ConvertToCFR with zero fps returned slots")
+	}
+	if ConvertToCFR([]int64{avutil.NoPTSValue}, tb, 30) != nil {
+		t.Error("ConvertToCFR without timestamps returned slots")
+	}
+}
@@ -0,0 +1,382 @@
+//go:build !ios && !android && (amd64 || arm64)
+
+package ffgo
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/obinnaokechukwu/ffgo/avutil"
+)
+
+// TranscodeConfig configures a Transcoder.
+type TranscodeConfig struct {
+	// Video configures the output video encoder. Zero Width, Height and
+	// FrameRate are taken from the input. Nil uses the encoder defaults
+	// (H.264, yuv420p) at the input's size and rate. Ignored when the input
+	// has no video.
+	Video *VideoEncoderConfig
+
+	// Audio configures the output audio encoder. Zero SampleRate and
+	// Channels are taken from the input. Nil uses the encoder defaults
+	// (AAC, 128 kb/s) at the input's rate and channel count. Ignored when
+	// the input has no audio.
+	Audio *AudioEncoderConfig
+
+	// TargetFrameRate, when set, makes the output constant frame rate: an
+	// "fps" filter between decoder and encoder duplicates and drops frames
+	// to fill a fixed grid at this rate, so variable-frame-rate sources
+	// play back smoothly. It overrides Video.FrameRate. Zero keeps the
+	// source timing.
+	TargetFrameRate Rational
+
+	// OnProgress, if set, is called as output is written with the frames
+	// and time processed, the estimated totals, the percentage done and
+	// the ETA.
+	OnProgress func(Progress)
+
+	// FinalizeOnCancel makes RunContext flush the encoders and write the
+	// trailer when its context is cancelled, leaving a valid output that
+	// ends where transcoding stopped. By default a cancelled output is
+	// abandoned without a trailer.
+	FinalizeOnCancel bool
+}
+
+// Transcoder re-encodes the video and audio streams of a file. Video goes
+// decode → optional fps filter → scale/convert when the output size or
+// pixel format differs → encode; audio goes decode → resample → encode.
+// Both keep their source timestamps, measured from the earlier of the two
+// stream start times, so the output starts at zero with audio and video in
+// sync.
+//
+// Example:
+//
+//	t, err := ffgo.NewTranscoder("input.mov", "output.mp4", ffgo.TranscodeConfig{
+//	    Video: &ffgo.VideoEncoderConfig{Width: 1280, Height: 720},
+//	    OnProgress: func(p ffgo.Progress) {
+//	        fmt.Printf("\r%v / %v", p.TimeDone, p.TotalTime)
+//	    },
+//	})
+//	if err != nil {
+//	    return err
+//	}
+//	defer t.Close()
+//	return t.Run()
+type Transcoder struct {
+	dec    *Decoder
+	enc    *Encoder
+	fps    *FilterGraph     // nil unless TargetFrameRate is set
+	scaler *Scaler          // nil when no conversion is needed
+	audio  *AudioTranscoder // nil when the input has no audio
+
+	progress *progressTracker // nil unless TranscodeConfig.OnProgress is set
+	frames   int64            // video frames decoded
+
+	// start is the shared origin of the video and audio streams: output
+	// timestamps and progress are measured from it.
+	start time.Duration
+	fpsTB Rational // output time base of the fps filter
+
+	finalizeOnCancel bool
+
+	video  *StreamInfo // nil when the input has no video
+	width  int
+	height int
+	pixFmt PixelFormat
+
+	done   bool
+	closed bool
+}
+
+// NewTranscoder opens input and creates output for transcoding. The input
+// must have a video or an audio stream.
+func NewTranscoder(input, output string, cfg TranscodeConfig) (*Transcoder, error) {
+	if input == "" || output == "" {
+		return nil, errors.New("ffgo: input and output are required")
+	}
+	target := cfg.TargetFrameRate
+	if target.Num < 0 || target.Den < 0 || (target.Num > 0) != (target.Den > 0) {
+		return nil, fmt.Errorf("ffgo: invalid target frame rate %d/%d", target.Num, target.Den)
+	}
+
+	dec, err := NewDecoder(input)
+	if err != nil {
+		return nil, err
+	}
+	t := &Transcoder{
+		dec:              dec,
+		video:            dec.VideoStream(),
+		start:            mediaStartTime(dec),
+		finalizeOnCancel: cfg.FinalizeOnCancel,
+	}
+	if t.video == nil && !dec.HasAudio() {
+		dec.Close()
+		return nil, errors.New("ffgo: input has no video or audio stream")
+	}
+
+	opts := &EncoderOptions{}
+	if t.video != nil {
+		if err := t.setupVideo(cfg); err != nil {
+			t.Close()
+			return nil, err
+		}
+		opts.Video = &VideoEncoderConfig{}
+		if cfg.Video != nil {
+			*opts.Video = *cfg.Video
+		}
+		opts.Video.Width, opts.Video.Height = t.width, t.height
+		if target.Num > 0 {
+			opts.Video.FrameRate = target
+		} else if opts.Video.FrameRate.Num <= 0 || opts.Video.FrameRate.Den <= 0 {
+			opts.Video.FrameRate = t.video.FrameRate
+		}
+	}
+	if info := dec.AudioStream(); info != nil {
+		opts.Audio = &AudioEncoderConfig{}
+		if cfg.Audio != nil {
+			*opts.Audio = *cfg.Audio
+		}
+		if opts.Audio.SampleRate <= 0 {
+			opts.Audio.SampleRate = info.SampleRate
+		}
+		if opts.Audio.Channels <= 0 {
+			opts.Audio.Channels = info.Channels
+		}
+	}
+	if cfg.OnProgress != nil {
+		t.progress = newProgressTracker(cfg.OnProgress, dec.TotalFrames(), dec.Duration())
+	}
+
+	t.enc, err = NewEncoderWithOptions(output, opts)
+	if err != nil {
+		t.Close()
+		return nil, err
+	}
+	if opts.Audio != nil {
+		if t.audio, err = NewAudioTranscoder(dec, t.enc); err != nil {
+			t.Close()
+			return nil, err
+		}
+		t.audio.syncTo(t.start)
+	}
+	return t, nil
+}
+
+// setupVideo opens the video decoder, resolves the output size and pixel
+// format and creates the fps filter when a target frame rate is set.
+func (t *Transcoder) setupVideo(cfg TranscodeConfig) error {
+	if err := t.dec.OpenVideoDecoder(); err != nil {
+		return err
+	}
+	t.width, t.height, t.pixFmt = t.video.Width, t.video.Height, PixelFormatYUV420P
+	if v := cfg.Video; v != nil {
+		if v.Width > 0 {
+			t.width = v.Width
+		}
+		if v.Height > 0 {
+			t.height = v.Height
+		}
+		if v.PixelFormat != PixelFormatNone {
+			t.pixFmt = v.PixelFormat
+		}
+	}
+
+	target := cfg.TargetFrameRate
+	if target.Num <= 0 {
+		return nil
+	}
+	// fps emits frames on a grid of 1/target ticks.
+	t.fpsTB = NewRational(target.Den, target.Num)
+	var err error
+	t.fps, err = NewFilterGraph(FilterGraphConfig{
+		Width:     t.video.Width,
+		Height:    t.video.Height,
+		PixelFmt:  t.video.PixelFmt,
+		TimeBase:  t.video.TimeBase,
+		FrameRate: t.video.FrameRate,
+		Filters:   fmt.Sprintf("fps=%d/%d", target.Num, target.Den),
+	})
+	return err
+}
+
+// Run transcodes the whole input and finalizes the output.
+func (t *Transcoder) Run() error {
+	return t.RunContext(context.Background())
+}
+
+// RunContext is like Run but stops when ctx is done, checking it before
+// each frame, and returns ctx.Err(). See TranscodeConfig.FinalizeOnCancel
+// for what happens to the output.
+func (t *Transcoder) RunContext(ctx context.Context) error {
+	if t.closed {
+		return errors.New("ffgo: transcoder is closed")
+	}
+	if t.done {
+		return nil
+	}
+	t.done = true
+
+	for {
+		if err := ctx.Err(); err != nil {
+			return t.cancel(err)
+		}
+		fw, err := t.dec.ReadFrame()
+		if err != nil && !IsEOF(err) {
+			return err
+		}
+		if err != nil || fw == nil {
+			break
+		}
+		pos := time.Duration(-1)
+		switch fw.MediaType() {
+		case MediaTypeVideo:
+			t.frames++
+			pos = t.position(fw.Raw(), t.video.TimeBase)
+			err = t.process(fw.Raw())
+		case MediaTypeAudio:
+			pos = t.position(fw.Raw(), t.dec.AudioStream().TimeBase)
+			err = t.audio.feed(fw.Raw())
+		}
+		if err != nil {
+			return err
+		}
+		t.progress.update(t.frames, pos)
+	}
+
+	if t.fps != nil {
+		frames, err := t.fps.Flush()
+		err = errors.Join(err, t.writeFiltered(frames))
+		if err != nil {
+			return err
+		}
+	}
+	if t.audio != nil {
+		if err := t.audio.finish(); err != nil {
+			return err
+		}
+	}
+	if err := t.enc.Close(); err != nil {
+		return err
+	}
+	t.progress.update(t.frames, -1)
+	return nil
+}
+
+// cancel ends a run stopped by its context and returns err. The output is
+// finalized with what was written if FinalizeOnCancel is set and abandoned
+// otherwise.
+func (t *Transcoder) cancel(err error) error {
+	if !t.finalizeOnCancel {
+		t.enc.abort()
+		return err
+	}
+	return errors.Join(err, t.enc.Close())
+}
+
+// position returns how far into the input a decoded frame is, or -1 if it
+// has no timestamp or progress is not being reported.
+func (t *Transcoder) position(frame Frame, tb Rational) time.Duration {
+	if t.progress == nil {
+		return -1
+	}
+	ts := frameTimestamp(frame.ptr)
+	if ts == avutil.NoPTSValue {
+		return -1
+	}
+	return ptsToDuration(ts, tb) - t.start
+}
+
+// process passes a decoded frame through the fps filter, if any, and
+// encodes the result.
+func (t *Transcoder) process(frame Frame) error {
+	if t.fps == nil {
+		return t.write(frame, frameTimestamp(frame.ptr), t.video.TimeBase)
+	}
+	frames, err := t.fps.Filter(&frame)
+	return errors.Join(err, t.writeFiltered(frames))
+}
+
+// writeFiltered encodes and frees the output of the fps filter. Its frames
+// sit on a gapless grid of 1/TargetFrameRate ticks.
+func (t *Transcoder) writeFiltered(frames []Frame) error {
+	defer freeFrames(frames)
+	for _, f := range frames {
+		if err := t.write(f, avutil.GetFramePTS(f.ptr), t.fpsTB); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// write converts frame to the encoder's size and format if needed and
+// encodes it. pts is in tb and is written relative to the input's start,
+// like the audio; AV_NOPTS_VALUE continues from the previous frame.
+func (t *Transcoder) write(frame Frame, pts int64, tb Rational) error {
+	info := GetFrameInfo(frame)
+	w, h, pixFmt := info.Width, info.Height, PixelFormat(info.Format)
+	if w != t.width || h != t.height || pixFmt != t.pixFmt {
+		cfg := ScalerConfig{
+			SrcWidth:  w,
+			SrcHeight: h,
+			SrcFormat: pixFmt,
+			DstWidth:  t.width,
+			DstHeight: t.height,
+			DstFormat: t.pixFmt,
+			Flags:     ScaleBilinear,
+		}
+		var err error
+		if t.scaler == nil {
+			t.scaler, err = NewScalerWithConfig(cfg)
+		} else if w != t.scaler.SrcWidth() || h != t.scaler.SrcHeight() || pixFmt != t.scaler.SrcFormat() {
+			// The input changed size or format mid-stream
+			if err = t.scaler.Reconfigure(cfg); err != nil {
+				_ = t.scaler.Close()
+				t.scaler = nil
+			}
+		}
+		if err != nil {
+			return fmt.Errorf("ffgo: converting %dx%d %s frame: %w",
+				w, h, avutil.GetPixFmtName(pixFmt), err)
+		}
+		scaled, err := t.scaler.Scale(frame)
+		if err != nil {
+			return err
+		}
+		frame = scaled
+	}
+
+	if pts != avutil.AV_NOPTS_VALUE {
+		pts -= durationToPTS(t.start, tb)
+	}
+	return t.enc.WriteFrameWithPTS(frame, pts, tb)
+}
+
+// Close releases the decoder, filter, scaler, resampler and encoder. If Run
+// did not complete, the output is finalized with whatever was written,
+// unless a cancelled RunContext already abandoned it.
+func (t *Transcoder) Close() error {
+	if t.closed {
+		return nil
+	}
+	t.closed = true
+
+	var err error
+	if t.enc != nil {
+		err = t.enc.Close()
+	}
+	if t.scaler != nil {
+		_ = t.scaler.Close()
+	}
+	if t.audio != nil {
+		_ = t.audio.Close()
+	}
+	if t.fps != nil {
+		_ = t.fps.Close()
+	}
+	if t.dec != nil {
+		_ = t.dec.Close()
+	}
+	return err
+}
@@ -0,0 +1,311 @@
+//go:build !ios && !android && (amd64 || arm64)
+
+package ffgo
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestTranscoderTargetFrameRate(t *testing.T) {
+	if !requireFFmpeg(t) {
+		return
+	}
+	out := filepath.Join(t.TempDir(), "cfr.mkv")
+	tr, err := NewTranscoder(createTestVideo(t), out, TranscodeConfig{
+		Video: &VideoEncoderConfig{
+			Codec:       CodecIDMJPEG,
+			Width:       160,
+			Height:      120,
+			PixelFormat: PixelFormatYUVJ420P,
+		},
+		TargetFrameRate: NewRational(10, 1),
+	})
+	if err != nil {
+		t.Fatalf("NewTranscoder failed: %v", err)
+	}
+	defer tr.Close()
+	if err := tr.Run(); err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+
+	dec, err := NewDecoder(out)
+	if err != nil {
+		t.Fatalf("NewDecoder failed: %v", err)
+	}
+	defer dec.Close()
+	video := dec.VideoStream()
+	if video.Width != 160 || video.Height != 120 {
+		t.Errorf("output is %dx%d, want 160x120", video.Width, video.Height)
+	}
+
+	var pts []time.Duration
+	for {
+		f, err := dec.DecodeVideo()
+		if err != nil || f.IsNil() {
+			break
+		}
+		pts = append(pts, ptsToDuration(GetFrameInfo(f).PTS, video.TimeBase))
+	}
+	if len(pts) < 2 {
+		t.Fatalf("decoded %d frames, want several", len(pts))
+	}
+	for i := 1; i < len(pts); i++ {
+		if d := pts[i] - pts[i-1]; d < 98*time.Millisecond || d > 102*time.Millisecond {
+			t.Errorf("frame %d is %v after the previous one, want 100ms", i, d)
+		}
+	}
+}
+
+func TestNewTranscoderValidation(t *testing.T) {
+	if _, err := NewTranscoder("", "out.mp4", TranscodeConfig{}); err == nil {
+		t.Error("NewTranscoder without input succeeded")
+	}
+	if _, err := NewTranscoder("in.mp4", "out.mp4", TranscodeConfig{TargetFrameRate: NewRational(30, 0)}); err == nil {
+		t.Error("NewTranscoder with an invalid target frame rate succeeded")
+	}
+}
+
+func TestTranscoderAudioAndProgress(t *testing.T) {
+	if !requireFFmpeg(t) {
+		return
+	}
+	input := createTestVideo(t)
+	out := filepath.Join(t.TempDir(), "out.mkv")
+
+	var calls int
+	var last Progress
+	tr, err := NewTranscoder(input, out, TranscodeConfig{
+		Video: &VideoEncoderConfig{Codec: CodecIDMJPEG, PixelFormat: PixelFormatYUVJ420P},
+		Audio: &AudioEncoderConfig{SampleRate: 44100},
+		OnProgress: func(p Progress) {
+			calls++
+			last = p
+		},
+	})
+	if err != nil {
+		t.Fatalf("NewTranscoder failed: %v", err)
+	}
+	defer tr.Close()
+	if err := tr.Run(); err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+
+	if calls == 0 {
+		t.Fatal("OnProgress was never called")
+	}
+	if last.TotalTime <= 0 {
+		t.Errorf("TotalTime = %v, want the input duration", last.TotalTime)
+	}
+	if last.TimeDone < last.TotalTime/2 {
+		t.Errorf("final progress %v is far short of total %v", last.TimeDone, last.TotalTime)
+	}
+
+	dec, err := NewDecoder(out)
+	if err != nil {
+		t.Fatalf("NewDecoder failed: %v", err)
+	}
+	defer dec.Close()
+	src, err := NewDecoder(input)
+	if err != nil {
+		t.Fatalf("NewDecoder failed: %v", err)
+	}
+	defer src.Close()
+
+	if !dec.HasVideo() {
+		t.Error("output has no video stream")
+	}
+	if src.HasAudio() {
+		audio := dec.AudioStream()
+		if audio == nil {
+			t.Fatal("output has no audio stream")
+		}
+		if audio.SampleRate != 44100 {
+			t.Errorf("audio sample rate = %d, want 44100", audio.SampleRate)
+		}
+		if audio.Channels != src.AudioStream().Channels {
+			t.Errorf("audio channels = %d, want %d", audio.Channels, src.AudioStream().Channels)
+		}
+	}
+}
+
+func TestTranscoderProgressStartOffset(t *testing.T) {
+	if !requireFFmpeg(t) {
+		return
+	}
+	// MPEG-TS timestamps start well after zero; progress must count from
+	// the start of the stream.
+	input := filepath.Join(t.TempDir(), "offset.ts")
+	cmd := exec.Command("ffmpeg", "-y",
+		"-f", "lavfi", "-i", "testsrc=duration=2:size=160x120:rate=25",
+		"-c:v", "mpeg2video", "-output_ts_offset", "10",
+		input)
+	if err := cmd.Run(); err != nil {
+		t.Skipf("ffmpeg not available or failed: %v", err)
+	}
+
+	var reports []Progress
+	tr, err := NewTranscoder(input, filepath.Join(t.TempDir(), "out.mkv"), TranscodeConfig{
+		Video:      &VideoEncoderConfig{Codec: CodecIDMJPEG, PixelFormat: PixelFormatYUVJ420P},
+		OnProgress: func(p Progress) { reports = append(reports, p) },
+	})
+	if err != nil {
+		t.Fatalf("NewTranscoder failed: %v", err)
+	}
+	defer tr.Close()
+	if err := tr.Run(); err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+
+	if len(reports) < 2 {
+		t.Fatalf("got %d progress reports, want several", len(reports))
+	}
+	if first := reports[0]; first.TimeDone > 500*time.Millisecond {
+		t.Errorf("first report at %v, want near the start", first.TimeDone)
+	}
+	last := reports[len(reports)-1]
+	if last.TotalTime <= 0 {
+		t.Fatalf("TotalTime = %v, want the input duration", last.TotalTime)
+	}
+	if last.TimeDone < last.TotalTime/2 || last.TimeDone > last.TotalTime+500*time.Millisecond {
+		t.Errorf("final progress %v, want close to total %v", last.TimeDone, last.TotalTime)
+	}
+}
+
+func TestTranscoderKeepsAVOffset(t *testing.T) {
+	if !requireFFmpeg(t) {
+		return
+	}
+	// MPEG-TS starts at 1.4s; audio starts another 0.5s after video.
+	input := filepath.Join(t.TempDir(), "offset.ts")
+	cmd := exec.Command("ffmpeg", "-y",
+		"-f", "lavfi", "-i", "testsrc=duration=2:size=160x120:rate=25",
+		"-itsoffset", "0.5",
+		"-f", "lavfi", "-i", "sine=frequency=440:duration=1.5:sample_rate=44100",
+		"-c:v", "mpeg2video", "-c:a", "mp2", input)
+	if err := cmd.Run(); err != nil {
+		t.Skipf("ffmpeg not available or failed: %v", err)
+	}
+
+	for _, target := range []Rational{{}, NewRational(10, 1)} {
+		out := filepath.Join(t.TempDir(), "out.mkv")
+		tr, err := NewTranscoder(input, out, TranscodeConfig{
+			Video:           &VideoEncoderConfig{Codec: CodecIDMJPEG, PixelFormat: PixelFormatYUVJ420P},
+			TargetFrameRate: target,
+		})
+		if err != nil {
+			t.Fatalf("NewTranscoder failed: %v", err)
+		}
+		err = tr.Run()
+		tr.Close()
+		if err != nil {
+			t.Fatalf("Run failed: %v", err)
+		}
+
+		video, audio := firstPacketTimes(t, out)
+		if video > 50*time.Millisecond {
+			t.Errorf("target %v: video starts at %v, want 0", target, video)
+		}
+		if off := audio - video; off < 400*time.Millisecond || off > 600*time.Millisecond {
+			t.Errorf("target %v: audio starts %v after video, want about 500ms", target, off)
+		}
+	}
+}
+
+func TestTranscoderRunContextCancelled(t *testing.T) {
+	if !requireFFmpeg(t) {
+		return
+	}
+	out := filepath.Join(t.TempDir(), "out.mkv")
+	tr, err := NewTranscoder(createTestVideo(t), out, TranscodeConfig{
+		Video: &VideoEncoderConfig{Codec: CodecIDMJPEG, PixelFormat: PixelFormatYUVJ420P},
+	})
+	if err != nil {
+		t.Fatalf("NewTranscoder failed: %v", err)
+	}
+	defer tr.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := tr.RunContext(ctx); !errors.Is(err, context.Canceled) {
+		t.Fatalf("RunContext = %v, want context.Canceled", err)
+	}
+	if tr.frames != 0 {
+		t.Errorf("decoded %d frames after cancellation", tr.frames)
+	}
+	if err := tr.Close(); err != nil {
+		t.Errorf("Close after cancellation failed: %v", err)
+	}
+}
+
+func TestTranscoderResolutionChange(t *testing.T) {
+	if !requireFFmpeg(t) {
+		return
+	}
+	// Two MPEG-TS segments of different sizes, joined byte for byte, give
+	// a stream whose resolution changes after one second.
+	dir := t.TempDir()
+	var data []byte
+	for i, size := range []string{"160x120", "320x240"} {
+		seg := filepath.Join(dir, fmt.Sprintf("seg%d.ts", i))
+		cmd := exec.Command("ffmpeg", "-y",
+			"-f", "lavfi", "-i", "testsrc=duration=1:size="+size+":rate=25",
+			"-output_ts_offset", fmt.Sprint(i),
+			"-c:v", "mpeg2video", seg)
+		if err := cmd.Run(); err != nil {
+			t.Skipf("ffmpeg not available or failed: %v", err)
+		}
+		b, err := os.ReadFile(seg)
+		if err != nil {
+			t.Fatal(err)
+		}
+		data = append(data, b...)
+	}
+	input := filepath.Join(dir, "resize.ts")
+	if err := os.WriteFile(input, data, 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	out := filepath.Join(dir, "out.mkv")
+	tr, err := NewTranscoder(input, out, TranscodeConfig{
+		Video: &VideoEncoderConfig{
+			Codec:       CodecIDMJPEG,
+			Width:       160,
+			Height:      120,
+			PixelFormat: PixelFormatYUVJ420P,
+		},
+	})
+	if err != nil {
+		t.Fatalf("NewTranscoder failed: %v", err)
+	}
+	defer tr.Close()
+	if err := tr.Run(); err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+
+	dec, err := NewDecoder(out)
+	if err != nil {
+		t.Fatalf("NewDecoder failed: %v", err)
+	}
+	defer dec.Close()
+	n := 0
+	for {
+		f, err := dec.DecodeVideo()
+		if err != nil || f.IsNil() {
+			break
+		}
+		if info := GetFrameInfo(f); info.Width != 160 || info.Height != 120 {
+			t.Fatalf("frame %d is %dx%d, want 160x120", n, info.Width, info.Height)
+		}
+		n++
+	}
+	if n < 40 {
+		t.Errorf("decoded %d frames, want about 50 from both segments", n)
+	}
+}
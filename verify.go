@@ -0,0 +1,170 @@
+//go:build !ios && !android && (amd64 || arm64)
+
+package ffgo
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/obinnaokechukwu/ffgo/avcodec"
+	"github.com/obinnaokechukwu/ffgo/avutil"
+)
+
+// ExpectedStreams describes what a finished output file should contain.
+// Zero-valued fields are not checked.
+type ExpectedStreams struct {
+	// Video, Audio and Subtitle are the expected number of streams of each type.
+	Video    int
+	Audio    int
+	Subtitle int
+
+	// Duration is the expected media duration.
+	Duration time.Duration
+
+	// DurationTolerance is the allowed absolute difference from Duration
+	// (default: 5% of Duration, at least 500ms).
+	DurationTolerance time.Duration
+}
+
+// VerifyReport is the result of VerifyOutput.
+type VerifyReport struct {
+	// Streams describes every stream found in the file.
+	Streams []*StreamInfo
+
+	// VideoStreams, AudioStreams and SubtitleStreams count streams by type.
+	VideoStreams    int
+	AudioStreams    int
+	SubtitleStreams int
+
+	// Duration is the container duration, or the duration covered by the
+	// packets read if the container does not report one.
+	Duration time.Duration
+
+	// PacketDuration is the time covered by the packets actually read, from
+	// the earliest packet start to the furthest packet end.
+	PacketDuration time.Duration
+
+	// Packets is the number of packets read before EOF or the first error.
+	Packets int
+
+	// Truncated is true if reading stopped with an error before EOF.
+	Truncated bool
+
+	// Problems lists every failed check in human-readable form.
+	Problems []string
+}
+
+// OK reports whether all checks passed.
+func (r *VerifyReport) OK() bool {
+	return r != nil && len(r.Problems) == 0
+}
+
+// VerifyOutput re-opens a finished output file and checks that it is
+// complete: the expected streams are present, every packet can be read up
+// to EOF, and the duration is plausible.
+//
+// The expected duration is checked against PacketDuration, so a file cut
+// short after its header was written fails even though the header still
+// claims the full duration. The container duration is checked as well.
+//
+// An error is returned only if the file cannot be opened at all (e.g. an MP4
+// whose trailer was never written). Failed checks are reported in
+// VerifyReport.Problems.
+func VerifyOutput(path string, expected ExpectedStreams) (*VerifyReport, error) {
+	d, err := NewDecoder(path)
+	if err != nil {
+		return nil, err
+	}
+	defer d.Close()
+
+	report := &VerifyReport{Streams: d.Streams()}
+	for _, s := range report.Streams {
+		switch s.Type {
+		case MediaTypeVideo:
+			report.VideoStreams++
+		case MediaTypeAudio:
+			report.AudioStreams++
+		case MediaTypeSubtitle:
+			report.SubtitleStreams++
+		}
+	}
+
+	checkCount := func(kind string, got, want int) {
+		if want > 0 && got != want {
+			report.Problems = append(report.Problems, fmt.Sprintf("expected %d %s stream(s), found %d", want, kind, got))
+		}
+	}
+	checkCount("video", report.VideoStreams, expected.Video)
+	checkCount("audio", report.AudioStreams, expected.Audio)
+	checkCount("subtitle", report.SubtitleStreams, expected.Subtitle)
+
+	// Read every packet; a demux error before EOF means the file is cut short.
+	var first, last time.Duration
+	seen := false
+	for {
+		pkt, err := d.ReadPacket()
+		if err != nil {
+			report.Truncated = true
+			report.Problems = append(report.Problems, fmt.Sprintf("read error after %d packets: %v", report.Packets, err))
+			break
+		}
+		if pkt == nil {
+			break
+		}
+		report.Packets++
+
+		idx := pkt.StreamIndex()
+		if idx < 0 || idx >= len(report.Streams) {
+			continue
+		}
+		pts := pkt.PTS()
+		if pts == avutil.NoPTSValue {
+			continue
+		}
+		tb := report.Streams[idx].TimeBase
+		start := ptsToDuration(pts, tb)
+		end := ptsToDuration(pts+avcodec.GetPacketDuration(pkt.ptr), tb)
+		if !seen || start < first {
+			first = start
+		}
+		if !seen || end > last {
+			last = end
+		}
+		seen = true
+	}
+	if seen {
+		report.PacketDuration = last - first
+	}
+
+	if report.Packets == 0 {
+		report.Problems = append(report.Problems, "no packets in file")
+	}
+
+	report.Duration = d.Duration()
+	if report.Duration <= 0 {
+		report.Duration = report.PacketDuration
+	}
+
+	if expected.Duration > 0 {
+		tol := expected.DurationTolerance
+		if tol <= 0 {
+			tol = expected.Duration / 20
+			if tol < 500*time.Millisecond {
+				tol = 500 * time.Millisecond
+			}
+		}
+		off := func(d time.Duration) bool {
+			diff := d - expected.Duration
+			return diff > tol || diff < -tol
+		}
+		// The packets read are what a player gets; the container duration
+		// comes from the header and survives truncation.
+		if off(report.PacketDuration) {
+			report.Problems = append(report.Problems, fmt.Sprintf("packets cover %v, which differs from expected %v by more than %v", report.PacketDuration, expected.Duration, tol))
+		} else if container := d.Duration(); container > 0 && off(container) {
+			report.Problems = append(report.Problems, fmt.Sprintf("container duration %v differs from expected %v by more than %v", container, expected.Duration, tol))
+		}
+	}
+
+	return report, nil
+}